@@ -1,14 +1,20 @@
 package cli
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
+	"gnorm.org/gnorm/database"
 	"gnorm.org/gnorm/environ"
 	"gnorm.org/gnorm/run"
 )
@@ -19,10 +25,15 @@ var (
 	commitHash = "no hash, did you build with make.go?"
 )
 
-func previewCmd(env environ.Values) *cobra.Command {
+func previewCmd(ctx context.Context, env environ.Values) *cobra.Command {
 	var cfgFile string
+	var profile string
 	var verbose bool
 	var format string
+	var from string
+	var dialect string
+	var schemas []string
+	var tables []string
 	preview := &cobra.Command{
 		Use:   "preview",
 		Short: "Preview the data that will be sent to your templates",
@@ -31,9 +42,19 @@ Reads your gnorm.toml file and connects to your database, translating the schema
 just as it would be during a full run.  It is then printed out in an
 easy-to-read format.  By default it prints out the data in a human-readable
 plaintext tabular format.  You may specify a different format using the -format
-flag, in which case you can print json, yaml, or types, where types is a list of
-all types used by columns in your database.  The latter is useful when setting
-up TypeMaps.
+flag, in which case you can print json, yaml, markdown, csv, sql, or types.
+json and yaml print all the data, with keys in a stable order, for other
+programs to read.  markdown prints tables you can paste into docs or pull
+requests.  csv prints a row for each column of each table.  sql prints the
+CREATE statements rebuilt from what gnorm read, the same as gnorm export
+--format ddl, in the SQL dialect given with --dialect, for reviewing what gnorm
+sees or keeping a snapshot of your schema as SQL.
+types is a list of all types used by columns in your database, which is useful
+when setting up TypeMaps.  With --from, the schema is read from a snapshot saved
+with gnorm dump instead of from your database.  To only see some of your
+database, use --schema and --table to pick schemas and tables, on top of the
+filters in your config.  Tables may be given as table or schema.table, and may
+be patterns such as "audit_*".
 `[1:],
 		RunE: func(cmd *cobra.Command, args []string) error {
 			env.InitLog(verbose)
@@ -47,81 +68,934 @@ up TypeMaps.
 				pformat = run.PreviewJSON
 			case "types":
 				pformat = run.PreviewTypes
+			case "markdown":
+				pformat = run.PreviewMarkdown
+			case "csv":
+				pformat = run.PreviewCSV
+			case "sql":
+				pformat = run.PreviewSQL
 			default:
 				return codeErr{errors.Errorf("unknown preview format %q", format), 2}
 			}
-			cfg, err := parseFile(env, cfgFile)
+			ddialect, err := parseDialect(dialect)
 			if err != nil {
 				return codeErr{err, 2}
 			}
-			if err := run.Preview(env, cfg, pformat); err != nil {
+			cfg, err := parseFile(env, cfgFile, profile)
+			if err != nil {
+				return codeErr{err, 2}
+			}
+			cfg.Snapshot = from
+			cfg.OnlySchemas = schemas
+			cfg.OnlyTables = tables
+			if err := run.Preview(ctx, env, cfg, pformat, ddialect); err != nil {
 				return codeErr{err, 1}
 			}
 			return nil
 		},
 		Args: cobra.ExactArgs(0),
 	}
-	preview.Flags().StringVarP(&cfgFile, "config", "c", "gnorm.toml", "relative path to gnorm config file")
-	preview.Flags().StringVarP(&format, "format", "f", "tabular", "Specify output format: tabular, yaml, json, or types")
+	preview.Flags().StringVarP(&cfgFile, "config", "c", "gnorm.toml", "relative path to gnorm config file (toml, yaml, or json)")
+	preview.Flags().StringVarP(&profile, "profile", "p", "", "name of the profile in the config file to use")
+	preview.Flags().StringVarP(&format, "format", "f", "tabular", "Specify output format: tabular, yaml, json, markdown, csv, sql, or types")
+	preview.Flags().StringVar(&from, "from", "", "snapshot file to read the schema from, instead of the database")
+	preview.Flags().StringVar(&dialect, "dialect", "standard", "SQL dialect of the sql format: standard, postgres, or mysql")
+	preview.Flags().StringSliceVar(&schemas, "schema", nil, "only show these schemas (may be repeated)")
+	preview.Flags().StringSliceVar(&tables, "table", nil, "only show tables matching these patterns, as table or schema.table (may be repeated)")
 	preview.Flags().BoolVarP(&verbose, "verbose", "v", false, "show debugging output")
 	return preview
 }
 
-func genCmd(env environ.Values) *cobra.Command {
+func genCmd(ctx context.Context, env environ.Values) *cobra.Command {
 	var cfgFile string
+	var profile string
+	var outputDir string
 	var verbose bool
+	var stdout bool
+	var dryRun bool
+	var prune bool
+	var atomic bool
+	var archive string
+	var report bool
+	var reportJSON string
+	var parallel int
+	var from string
+	var schemas []string
+	var tables []string
 	gen := &cobra.Command{
 		Use:   "gen",
 		Short: "Generate code from DB schema",
 		Long: `
 Reads your gnorm.toml file and connects to your database, translating the schema
 into in-memory objects.  Then reads your templates and writes files to disk
-based on those templates.`[1:],
+based on those templates.  The files written are listed in .gnorm-manifest.json
+in the output directory, with the template and table each was generated from
+and a SHA-256 hash of its contents, so they can be deleted with gnorm clean and
+tracked by other build tools.  With --from, the schema is read from a snapshot
+saved with gnorm dump instead of from your database, so code can be generated
+without a database connection.  If two templates render to the same file, such
+as for two tables whose names convert to the same name, gen fails with an error
+naming both instead of letting one overwrite the other.
+
+Filtering: --schemas and --tables only generate the files for some schemas and
+tables, picked from those your config already includes.  Tables may be given as
+table or schema.table, and may be patterns such as "audit_*".  The whole
+database is still read, so templates see foreign keys to tables that weren't
+picked.  With --tables, the SchemaPaths and EnumPaths templates are not
+rendered, and with either flag, the DBPaths templates are not rendered, since
+they cover more than what was picked.
+
+Dry run: with --dry-run, nothing is written, and a unified diff of the changes
+gen would make to your output directory is printed instead, followed by a list
+of the new, changed, and removed files.  Removed files are ones the last run
+generated that this run wouldn't, which gen leaves in place unless --prune is
+given.
+
+Parallel: --parallel renders and writes that many files at the same time, which
+speeds up large schemas.  It has no effect with --stdout or --dry-run, which
+keep their output in order.  With --log-level info, runs that take a while log
+their progress every few seconds: reading the database, generating files, and
+running PostRun.
+
+Output: with --stdout, the generated output is written to stdout instead, with
+each file preceded by a "==> path <==" separator line.  Static files are not
+copied and PostRun is not run in that case.  With --prune, the orphaned files
+listed in the manifest are deleted after generating, the same as running gnorm
+clean --orphans, so files for tables that were renamed, dropped, or filtered out
+don't linger.  When only some schemas or tables are generated, files for the
+other tables are kept.  The path of each deleted file is printed.  With
+--atomic, files are written to a staging directory in the output directory
+first, and only moved into place once every template and PostRun command has
+succeeded, so a failed run leaves your output directory as it was.  PostRun
+commands are run on the files in the staging directory.  With --archive, the
+generated and static files are written to a .tar.gz, .tgz, .tar, or .zip file
+instead of the output directory, which is left alone.  Every file is generated
+as if the output directory were empty, and PostRun is run on each of them
+before they're added to the archive.
+
+Reporting: with --report, a summary of the run is printed when it finishes: how
+many files were written, left unchanged, skipped because of NoOverwriteGlobs,
+copied from static directories, and deleted by --prune, how many PostRun
+commands ran and failed, and how long it took, followed by the files written
+and deleted.  --report-json writes the same summary, with every file listed, to
+a JSON file, for CI to read.`[1:],
 		RunE: func(cmd *cobra.Command, args []string) error {
 			env.InitLog(verbose)
-			cfg, err := parseFile(env, cfgFile)
+			if stdout && dryRun {
+				return codeErr{errors.New("--stdout and --dry-run can't be used together"), 2}
+			}
+			if stdout && prune {
+				return codeErr{errors.New("--stdout and --prune can't be used together"), 2}
+			}
+			if stdout && atomic {
+				return codeErr{errors.New("--stdout and --atomic can't be used together"), 2}
+			}
+			if (report || reportJSON != "") && (stdout || dryRun) {
+				return codeErr{errors.New("--report and --report-json can't be used with --stdout or --dry-run"), 2}
+			}
+			if archive != "" && (stdout || dryRun || prune || atomic || outputDir != "") {
+				return codeErr{errors.New("--archive can't be used with --stdout, --dry-run, --prune, --atomic, or --output-dir"), 2}
+			}
+			cfg, err := parseFile(env, cfgFile, profile)
 			if err != nil {
 				return codeErr{err, 2}
 			}
-			if err := run.Generate(env, cfg); err != nil {
+			cfg.Stdout = stdout
+			cfg.DryRun = dryRun
+			cfg.Prune = prune
+			cfg.Atomic = atomic
+			cfg.Archive = archive
+			cfg.Report = report
+			cfg.ReportFile = reportJSON
+			cfg.Parallel = parallel
+			cfg.Snapshot = from
+			cfg.OnlySchemas = schemas
+			cfg.OnlyTables = tables
+			if outputDir != "" {
+				cfg.OutputDir = outputDir
+			}
+			if err := run.Generate(ctx, env, cfg); err != nil {
 				return codeErr{err, 1}
 			}
 			return nil
 		},
 		Args: cobra.ExactArgs(0),
 	}
-	gen.Flags().StringVarP(&cfgFile, "config", "c", "gnorm.toml", "relative path to gnorm config file")
+	gen.Flags().StringVarP(&cfgFile, "config", "c", "gnorm.toml", "relative path to gnorm config file (toml, yaml, or json)")
+	gen.Flags().StringVarP(&profile, "profile", "p", "", "name of the profile in the config file to use")
+	gen.Flags().BoolVar(&stdout, "stdout", false, "write generated output to stdout instead of to files")
+	gen.Flags().BoolVar(&dryRun, "dry-run", false, "print a diff of the changes to the output directory instead of writing files")
+	gen.Flags().IntVar(&parallel, "parallel", 1, "number of files to render and write at the same time")
+	gen.Flags().BoolVar(&prune, "prune", false, "delete files generated by earlier runs that this run didn't generate")
+	gen.Flags().BoolVar(&atomic, "atomic", false, "only update the output directory if every file is generated successfully")
+	gen.Flags().StringVar(&archive, "archive", "", "write the generated files to this .tar.gz, .tgz, .tar, or .zip file instead of the output directory")
+	gen.Flags().BoolVar(&report, "report", false, "print a summary of the files written, skipped, and deleted when the run finishes")
+	gen.Flags().StringVar(&reportJSON, "report-json", "", "write a summary of the run to this file as JSON")
+	gen.Flags().StringVarP(&outputDir, "output-dir", "o", "", "directory to write generated files to, overriding OutputDir in the config file")
+	gen.Flags().StringVar(&from, "from", "", "snapshot file to read the schema from, instead of the database")
+	gen.Flags().StringSliceVar(&schemas, "schemas", nil, "only generate files for these schemas (may be repeated)")
+	gen.Flags().StringSliceVar(&tables, "tables", nil, "only generate files for tables matching these patterns, as table or schema.table (may be repeated)")
 	gen.Flags().BoolVarP(&verbose, "verbose", "v", false, "show debugging output")
 	return gen
 }
 
-func versionCmd(env environ.Values) *cobra.Command {
+func cleanCmd(env environ.Values) *cobra.Command {
+	var cfgFile string
+	var profile string
+	var outputDir string
+	var verbose bool
+	var orphans bool
+	var dryRun bool
+	clean := &cobra.Command{
+		Use:   "clean",
+		Short: "Delete the files gnorm generated",
+		Long: `
+Deletes the files that gen wrote to your output directory, which gen lists in
+the .gnorm-manifest.json file there, and then deletes the manifest.  The
+manifest also lists orphans: files written by earlier runs that the last run
+didn't write, such as the files for tables that no longer exist.  With
+--orphans, only those are deleted.  Files matching NoOverwriteGlobs are never
+deleted.  The path of each deleted file is printed.  With --dry-run, the files
+are printed but not deleted.`[1:],
+		RunE: func(cmd *cobra.Command, args []string) error {
+			env.InitLog(verbose)
+			cfg, err := parseFile(env, cfgFile, profile)
+			if err != nil {
+				return codeErr{err, 2}
+			}
+			if outputDir != "" {
+				cfg.OutputDir = outputDir
+			}
+			if err := run.Clean(env, cfg, orphans, dryRun); err != nil {
+				return codeErr{err, 1}
+			}
+			return nil
+		},
+		Args: cobra.ExactArgs(0),
+	}
+	clean.Flags().StringVarP(&cfgFile, "config", "c", "gnorm.toml", "relative path to gnorm config file (toml, yaml, or json)")
+	clean.Flags().StringVarP(&profile, "profile", "p", "", "name of the profile in the config file to use")
+	clean.Flags().StringVarP(&outputDir, "output-dir", "o", "", "directory to delete generated files from, overriding OutputDir in the config file")
+	clean.Flags().BoolVar(&orphans, "orphans", false, "only delete files the last run didn't generate")
+	clean.Flags().BoolVar(&dryRun, "dry-run", false, "print the files that would be deleted without deleting them")
+	clean.Flags().BoolVarP(&verbose, "verbose", "v", false, "show debugging output")
+	return clean
+}
+
+func lintCmd(env environ.Values) *cobra.Command {
+	var cfgFile string
+	var profile string
+	var verbose bool
+	lint := &cobra.Command{
+		Use:   "lint",
+		Short: "Check your config and templates for problems",
+		Long: `
+Reads your gnorm.toml file and parses all your templates, without connecting to
+your database.  Reports problems with the config, such as missing template files
+or conflicting settings, and references in your go templates to fields that
+don't exist on the data those templates will receive.`[1:],
+		RunE: func(cmd *cobra.Command, args []string) error {
+			env.InitLog(verbose)
+			cfg, err := parseFile(env, cfgFile, profile)
+			if err != nil {
+				return codeErr{err, 2}
+			}
+			problems := lint(cfg)
+			for _, p := range problems {
+				fmt.Fprintln(env.Stdout, p)
+			}
+			if len(problems) > 0 {
+				return codeErr{errors.Errorf("found %d problem(s)", len(problems)), 1}
+			}
+			return nil
+		},
+		Args: cobra.ExactArgs(0),
+	}
+	lint.Flags().StringVarP(&cfgFile, "config", "c", "gnorm.toml", "relative path to gnorm config file (toml, yaml, or json)")
+	lint.Flags().StringVarP(&profile, "profile", "p", "", "name of the profile in the config file to use")
+	lint.Flags().BoolVarP(&verbose, "verbose", "v", false, "show debugging output")
+	return lint
+}
+
+func validateCmd(ctx context.Context, env environ.Values) *cobra.Command {
+	var cfgFile string
+	var profile string
+	var verbose bool
+	var ping bool
+	validate := &cobra.Command{
+		Use:   "validate",
+		Short: "Check that your config is valid",
+		Long: `
+Fully parses your gnorm.toml file and all your templates, reporting unknown
+keys, conflicting settings, and missing or broken template files, along with
+the problems that lint finds.  Problems in a toml config file are reported with
+the line they were found on, where possible.  With --ping, also connects to your
+database and reads it, to check your connection settings.  Nothing is
+generated.`[1:],
+		RunE: func(cmd *cobra.Command, args []string) error {
+			env.InitLog(verbose)
+			problems := validate(ctx, env, cfgFile, profile, ping)
+			for _, p := range problems {
+				fmt.Fprintln(env.Stdout, p)
+			}
+			if len(problems) > 0 {
+				return codeErr{errors.Errorf("found %d problem(s)", len(problems)), 1}
+			}
+			return nil
+		},
+		Args: cobra.ExactArgs(0),
+	}
+	validate.Flags().StringVarP(&cfgFile, "config", "c", "gnorm.toml", "relative path to gnorm config file (toml, yaml, or json)")
+	validate.Flags().StringVarP(&profile, "profile", "p", "", "name of the profile in the config file to use")
+	validate.Flags().BoolVar(&ping, "ping", false, "also connect to and read the database")
+	validate.Flags().BoolVarP(&verbose, "verbose", "v", false, "show debugging output")
+	return validate
+}
+
+func doctorCmd(ctx context.Context, env environ.Values) *cobra.Command {
+	var cfgFile string
+	var profile string
+	var verbose bool
+	doctor := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check that gnorm is set up to generate code",
+		Long: `
+Checks everything gen needs in order to work, and prints whether each check
+passed or failed.  It checks that your gnorm.toml file can be read, that your
+templates parse, that gnorm can connect to your database and read the catalogs
+(such as information_schema and pg_catalog) that it reads your schema from,
+that the database user may use each of your schemas, and that files can be
+written to your output directory.  If the config file or templates can't be
+parsed, the checks after them are skipped.  Nothing is generated.`[1:],
+		RunE: func(cmd *cobra.Command, args []string) error {
+			env.InitLog(verbose)
+			failed := 0
+			for _, c := range doctor(ctx, env, cfgFile, profile) {
+				if c.Err != nil {
+					failed++
+					fmt.Fprintf(env.Stdout, "FAIL  %s: %v\n", c.Name, c.Err)
+				} else {
+					fmt.Fprintf(env.Stdout, "PASS  %s\n", c.Name)
+				}
+			}
+			if failed > 0 {
+				return codeErr{errors.Errorf("%d check(s) failed", failed), 1}
+			}
+			return nil
+		},
+		Args: cobra.ExactArgs(0),
+	}
+	doctor.Flags().StringVarP(&cfgFile, "config", "c", "gnorm.toml", "relative path to gnorm config file (toml, yaml, or json)")
+	doctor.Flags().StringVarP(&profile, "profile", "p", "", "name of the profile in the config file to use")
+	doctor.Flags().BoolVarP(&verbose, "verbose", "v", false, "show debugging output")
+	return doctor
+}
+
+func graphCmd(ctx context.Context, env environ.Values) *cobra.Command {
+	var cfgFile string
+	var profile string
+	var verbose bool
+	var format string
+	var group string
+	var markdown bool
+	var from string
+	graph := &cobra.Command{
+		Use:   "graph",
+		Short: "Draw an entity-relationship diagram of your database",
+		Long: `
+Reads your database the same way gen does, using the same filters, and prints
+an entity-relationship diagram of its tables, their columns, and the foreign
+keys between them.  The diagram is written in the format given with --format:
+dot (for graphviz), mermaid, or plantuml.  PlantUML diagrams can draw the
+tables of each schema together in a container, such as a package or frame,
+given with --group.  With --markdown, the diagram is wrapped in a fenced code
+block, so that it can be pasted into markdown, such as a README on GitHub or
+GitLab, which render mermaid diagrams inline.  With --from, the schema is read
+from a snapshot saved with gnorm dump instead of from your database.`[1:],
+		RunE: func(cmd *cobra.Command, args []string) error {
+			env.InitLog(verbose)
+			var gformat run.GraphFormat
+			switch strings.ToLower(format) {
+			case "dot":
+				gformat = run.GraphDOT
+			case "mermaid":
+				gformat = run.GraphMermaid
+			case "plantuml":
+				gformat = run.GraphPlantUML
+			default:
+				return codeErr{errors.Errorf("unknown graph format %q", format), 2}
+			}
+			opts := run.GraphOptions{Group: strings.ToLower(group), Markdown: markdown}
+			if opts.Group != "" && gformat != run.GraphPlantUML {
+				return codeErr{errors.New("--group is only supported by the plantuml format"), 2}
+			}
+			cfg, err := parseFile(env, cfgFile, profile)
+			if err != nil {
+				return codeErr{err, 2}
+			}
+			cfg.Snapshot = from
+			if err := run.Graph(ctx, env, cfg, gformat, opts); err != nil {
+				return codeErr{err, 1}
+			}
+			return nil
+		},
+		Args: cobra.ExactArgs(0),
+	}
+	graph.Flags().StringVarP(&cfgFile, "config", "c", "gnorm.toml", "relative path to gnorm config file (toml, yaml, or json)")
+	graph.Flags().StringVarP(&profile, "profile", "p", "", "name of the profile in the config file to use")
+	graph.Flags().StringVarP(&format, "format", "f", "dot", "diagram format: dot, mermaid, or plantuml")
+	graph.Flags().StringVarP(&group, "group", "g", "", "container to group each schema's tables in, for plantuml: "+strings.Join(run.PlantUMLGroups, ", "))
+	graph.Flags().BoolVarP(&markdown, "markdown", "m", false, "wrap the diagram in a markdown code block")
+	graph.Flags().StringVar(&from, "from", "", "snapshot file to read the schema from, instead of the database")
+	graph.Flags().BoolVarP(&verbose, "verbose", "v", false, "show debugging output")
+	return graph
+}
+
+func openapiCmd(ctx context.Context, env environ.Values) *cobra.Command {
+	var cfgFile string
+	var profile string
+	var verbose bool
+	var format string
+	var from string
+	openapi := &cobra.Command{
+		Use:   "openapi",
+		Short: "Print OpenAPI schemas for your database's tables",
+		Long: `
+Reads your database the same way gen does, using the same filters, and prints
+an OpenAPI 3 document with a component schema for each table and enum.  Each
+column becomes a property named after the column, with a type and format mapped
+from its database type, and columns that aren't nullable are required.  Columns
+of an enum type refer to the enum's schema, which lists its values.  The
+document is written as json or yaml, as given with --format.  With --from, the
+schema is read from a snapshot saved with gnorm dump instead of from your
+database.`[1:],
+		RunE: func(cmd *cobra.Command, args []string) error {
+			env.InitLog(verbose)
+			var oformat run.OpenAPIFormat
+			switch strings.ToLower(format) {
+			case "json":
+				oformat = run.OpenAPIJSON
+			case "yaml":
+				oformat = run.OpenAPIYAML
+			default:
+				return codeErr{errors.Errorf("unknown openapi format %q", format), 2}
+			}
+			cfg, err := parseFile(env, cfgFile, profile)
+			if err != nil {
+				return codeErr{err, 2}
+			}
+			cfg.Snapshot = from
+			if err := run.OpenAPI(ctx, env, cfg, oformat); err != nil {
+				return codeErr{err, 1}
+			}
+			return nil
+		},
+		Args: cobra.ExactArgs(0),
+	}
+	openapi.Flags().StringVarP(&cfgFile, "config", "c", "gnorm.toml", "relative path to gnorm config file (toml, yaml, or json)")
+	openapi.Flags().StringVarP(&profile, "profile", "p", "", "name of the profile in the config file to use")
+	openapi.Flags().StringVarP(&format, "format", "f", "json", "document format: json or yaml")
+	openapi.Flags().StringVar(&from, "from", "", "snapshot file to read the schema from, instead of the database")
+	openapi.Flags().BoolVarP(&verbose, "verbose", "v", false, "show debugging output")
+	return openapi
+}
+
+func graphqlCmd(ctx context.Context, env environ.Values) *cobra.Command {
+	var cfgFile string
+	var profile string
+	var verbose bool
+	var from string
+	graphql := &cobra.Command{
+		Use:   "graphql",
+		Short: "Print a GraphQL schema for your database",
+		Long: `
+Reads your database the same way gen does, using the same filters, and prints
+a GraphQL schema for it in the schema definition language.  Each table gets an
+object type, with a field for each of its columns, and each enum gets an enum
+type.  Each foreign key adds a field for the row it references to its table,
+and a field for the list of rows that reference it to the table it references.
+Types that GraphQL's built-in scalars can't hold, such as timestamps, use
+custom scalars, which are declared at the top.  With --from, the schema is read
+from a snapshot saved with gnorm dump instead of from your database.`[1:],
+		RunE: func(cmd *cobra.Command, args []string) error {
+			env.InitLog(verbose)
+			cfg, err := parseFile(env, cfgFile, profile)
+			if err != nil {
+				return codeErr{err, 2}
+			}
+			cfg.Snapshot = from
+			if err := run.GraphQL(ctx, env, cfg); err != nil {
+				return codeErr{err, 1}
+			}
+			return nil
+		},
+		Args: cobra.ExactArgs(0),
+	}
+	graphql.Flags().StringVarP(&cfgFile, "config", "c", "gnorm.toml", "relative path to gnorm config file (toml, yaml, or json)")
+	graphql.Flags().StringVarP(&profile, "profile", "p", "", "name of the profile in the config file to use")
+	graphql.Flags().StringVar(&from, "from", "", "snapshot file to read the schema from, instead of the database")
+	graphql.Flags().BoolVarP(&verbose, "verbose", "v", false, "show debugging output")
+	return graphql
+}
+
+func protoCmd(ctx context.Context, env environ.Values) *cobra.Command {
+	var cfgFile string
+	var profile string
+	var verbose bool
+	var from string
+	var pkg string
+	var options []string
+	proto := &cobra.Command{
+		Use:   "proto",
+		Short: "Print protobuf messages for your database's tables",
+		Long: `
+Reads your database the same way gen does, using the same filters, and prints
+a proto3 file with a message for each table and an enum for each enum, so that
+gRPC services can share types with code generated from the database.  Each
+column becomes a field named after the column, numbered by the column's
+ordinal position, and enum values are numbered by their sort order, so that
+dropping a column or adding a value doesn't renumber the rest.  Where those
+aren't all distinct valid numbers, such as from drivers that don't report them,
+fields and values are numbered in order instead.  Note that MySQL renumbers
+columns after one is dropped.  Nullable columns are optional, array columns are
+repeated, and timestamps use google.protobuf.Timestamp.  The package is set with
+--package, and file options with --option name=value, e.g.
+--option go_package=example.com/app/pb.  With --from, the schema is read from a
+snapshot saved with gnorm dump instead of from your database.`[1:],
+		RunE: func(cmd *cobra.Command, args []string) error {
+			env.InitLog(verbose)
+			opts := run.ProtoOptions{Package: pkg}
+			for _, s := range options {
+				o, err := run.ParseProtoOption(s)
+				if err != nil {
+					return codeErr{err, 2}
+				}
+				opts.Options = append(opts.Options, o)
+			}
+			cfg, err := parseFile(env, cfgFile, profile)
+			if err != nil {
+				return codeErr{err, 2}
+			}
+			cfg.Snapshot = from
+			if err := run.Proto(ctx, env, cfg, opts); err != nil {
+				return codeErr{err, 1}
+			}
+			return nil
+		},
+		Args: cobra.ExactArgs(0),
+	}
+	proto.Flags().StringVarP(&cfgFile, "config", "c", "gnorm.toml", "relative path to gnorm config file (toml, yaml, or json)")
+	proto.Flags().StringVarP(&profile, "profile", "p", "", "name of the profile in the config file to use")
+	proto.Flags().StringVar(&pkg, "package", "", "protobuf package of the messages")
+	proto.Flags().StringArrayVar(&options, "option", nil, "file option as name=value (may be repeated)")
+	proto.Flags().StringVar(&from, "from", "", "snapshot file to read the schema from, instead of the database")
+	proto.Flags().BoolVarP(&verbose, "verbose", "v", false, "show debugging output")
+	return proto
+}
+
+func jsonschemaCmd(ctx context.Context, env environ.Values) *cobra.Command {
+	var cfgFile string
+	var profile string
+	var verbose bool
+	var from string
+	var outputDir string
+	jsonschema := &cobra.Command{
+		Use:   "jsonschema",
+		Short: "Write JSON Schema documents for your database's tables",
+		Long: `
+Reads your database the same way gen does, using the same filters, and writes a
+JSON Schema (draft 2020-12) document for each table, for validating rows in
+other layers such as API gateways.  Each column becomes a property named after
+the column, columns that aren't nullable are required, columns of an enum type
+list the enum's values, and text columns with a length have a maxLength.  The
+documents are named after the tables, e.g. users.schema.json, and written to
+the directory given with --output-dir, or the OutputDir in the config file.
+With --from, the schema is read from a snapshot saved with gnorm dump instead
+of from your database.`[1:],
+		RunE: func(cmd *cobra.Command, args []string) error {
+			env.InitLog(verbose)
+			cfg, err := parseFile(env, cfgFile, profile)
+			if err != nil {
+				return codeErr{err, 2}
+			}
+			cfg.Snapshot = from
+			if outputDir == "" {
+				outputDir = cfg.OutputDir
+			}
+			if err := run.JSONSchema(ctx, env, cfg, run.DirSink(outputDir)); err != nil {
+				return codeErr{err, 1}
+			}
+			return nil
+		},
+		Args: cobra.ExactArgs(0),
+	}
+	jsonschema.Flags().StringVarP(&cfgFile, "config", "c", "gnorm.toml", "relative path to gnorm config file (toml, yaml, or json)")
+	jsonschema.Flags().StringVarP(&profile, "profile", "p", "", "name of the profile in the config file to use")
+	jsonschema.Flags().StringVarP(&outputDir, "output-dir", "o", "", "directory to write the documents to, overriding OutputDir in the config file")
+	jsonschema.Flags().StringVar(&from, "from", "", "snapshot file to read the schema from, instead of the database")
+	jsonschema.Flags().BoolVarP(&verbose, "verbose", "v", false, "show debugging output")
+	return jsonschema
+}
+
+func avroCmd(ctx context.Context, env environ.Values) *cobra.Command {
+	var cfgFile string
+	var profile string
+	var verbose bool
+	var from string
+	var outputDir string
+	var namespace string
+	avro := &cobra.Command{
+		Use:   "avro",
+		Short: "Write Avro schemas for your database's tables",
+		Long: `
+Reads your database the same way gen does, using the same filters, and writes
+an Avro schema for each table, for pipelines that stream changes to the
+database, such as through Kafka.  Each table is a record, in the namespace given
+with --namespace, with a field for each column.  Nullable columns are unions
+with null that default to null.  Timestamps, dates, and uuids use Avro's logical
+types, as do decimals declared with a precision.  The schemas are named after
+the tables, e.g. users.avsc, and written to the directory given with
+--output-dir, or the OutputDir in the config file.  With --from, the schema is
+read from a snapshot saved with gnorm dump instead of from your database.`[1:],
+		RunE: func(cmd *cobra.Command, args []string) error {
+			env.InitLog(verbose)
+			cfg, err := parseFile(env, cfgFile, profile)
+			if err != nil {
+				return codeErr{err, 2}
+			}
+			cfg.Snapshot = from
+			if outputDir == "" {
+				outputDir = cfg.OutputDir
+			}
+			if err := run.Avro(ctx, env, cfg, run.DirSink(outputDir), namespace); err != nil {
+				return codeErr{err, 1}
+			}
+			return nil
+		},
+		Args: cobra.ExactArgs(0),
+	}
+	avro.Flags().StringVarP(&cfgFile, "config", "c", "gnorm.toml", "relative path to gnorm config file (toml, yaml, or json)")
+	avro.Flags().StringVarP(&profile, "profile", "p", "", "name of the profile in the config file to use")
+	avro.Flags().StringVarP(&outputDir, "output-dir", "o", "", "directory to write the schemas to, overriding OutputDir in the config file")
+	avro.Flags().StringVar(&namespace, "namespace", "", "namespace of the records, e.g. com.example.db")
+	avro.Flags().StringVar(&from, "from", "", "snapshot file to read the schema from, instead of the database")
+	avro.Flags().BoolVarP(&verbose, "verbose", "v", false, "show debugging output")
+	return avro
+}
+
+func exportCmd(ctx context.Context, env environ.Values) *cobra.Command {
+	var cfgFile string
+	var profile string
+	var verbose bool
+	var format string
+	var dialect string
+	var from string
+	export := &cobra.Command{
+		Use:   "export",
+		Short: "Print your database's schema in another form",
+		Long: `
+Reads your database the same way gen does, using the same filters, and prints
+its schema in the format given with --format.  The ddl format prints the CREATE
+statements that would make the schema, in the SQL dialect given with --dialect:
+standard (driver-neutral), postgres, or mysql.  The liquibase-xml and
+liquibase-yaml formats print a Liquibase changelog that creates the schema, with
+a changeset for each table.  The output is canonical: tables, indexes, and
+foreign keys are sorted by name, so it only changes when the shape of the schema
+does, which makes it useful for snapshot tests.  Views are left out, or written
+as comments in ddl, since their definitions aren't read.  With --from, the
+schema is read from a snapshot saved with gnorm dump instead of from your
+database.`[1:],
+		RunE: func(cmd *cobra.Command, args []string) error {
+			env.InitLog(verbose)
+			var eformat run.ExportFormat
+			switch strings.ToLower(format) {
+			case "ddl":
+				eformat = run.ExportDDL
+			case "liquibase-xml":
+				eformat = run.ExportLiquibaseXML
+			case "liquibase-yaml":
+				eformat = run.ExportLiquibaseYAML
+			default:
+				return codeErr{errors.Errorf("unknown export format %q", format), 2}
+			}
+			ddialect, err := parseDialect(dialect)
+			if err != nil {
+				return codeErr{err, 2}
+			}
+			cfg, err := parseFile(env, cfgFile, profile)
+			if err != nil {
+				return codeErr{err, 2}
+			}
+			cfg.Snapshot = from
+			if err := run.Export(ctx, env, cfg, eformat, ddialect); err != nil {
+				return codeErr{err, 1}
+			}
+			return nil
+		},
+		Args: cobra.ExactArgs(0),
+	}
+	export.Flags().StringVarP(&cfgFile, "config", "c", "gnorm.toml", "relative path to gnorm config file (toml, yaml, or json)")
+	export.Flags().StringVarP(&profile, "profile", "p", "", "name of the profile in the config file to use")
+	export.Flags().StringVarP(&format, "format", "f", "ddl", "export format: ddl, liquibase-xml, or liquibase-yaml")
+	export.Flags().StringVar(&dialect, "dialect", "standard", "SQL dialect of the ddl: standard, postgres, or mysql")
+	export.Flags().StringVar(&from, "from", "", "snapshot file to read the schema from, instead of the database")
+	export.Flags().BoolVarP(&verbose, "verbose", "v", false, "show debugging output")
+	return export
+}
+
+func dumpCmd(ctx context.Context, env environ.Values) *cobra.Command {
+	var cfgFile string
+	var profile string
+	var verbose bool
+	var output string
+	var format string
+	dump := &cobra.Command{
+		Use:   "dump",
+		Short: "Save a snapshot of your database's schema",
+		Long: `
+Reads your database the same way gen does, and writes a snapshot of its schema
+to the file given with -o, or to stdout if no file is given.  The snapshot holds
+the schema info read from the database, along with the data that would be
+passed to your templates, in a versioned json or yaml file.  The format is
+taken from the file's extension, unless it's given with --format.  Snapshots
+can be compared against with gnorm diff, and generated from with gen --from.`[1:],
+		RunE: func(cmd *cobra.Command, args []string) error {
+			env.InitLog(verbose)
+			sformat := run.SnapshotFormatOf(output)
+			switch strings.ToLower(format) {
+			case "":
+			case "json":
+				sformat = run.SnapshotJSON
+			case "yaml":
+				sformat = run.SnapshotYAML
+			default:
+				return codeErr{errors.Errorf("unknown snapshot format %q", format), 2}
+			}
+			cfg, err := parseFile(env, cfgFile, profile)
+			if err != nil {
+				return codeErr{err, 2}
+			}
+			if output == "" {
+				if err := run.Dump(ctx, env, cfg, env.Stdout, sformat); err != nil {
+					return codeErr{err, 1}
+				}
+				return nil
+			}
+			// write the file only once the whole snapshot has been made, so a
+			// failed dump doesn't clobber an existing snapshot.
+			buf := &bytes.Buffer{}
+			if err := run.Dump(ctx, env, cfg, buf, sformat); err != nil {
+				return codeErr{err, 1}
+			}
+			if err := ioutil.WriteFile(output, buf.Bytes(), 0644); err != nil {
+				return codeErr{errors.WithMessage(err, "can't write snapshot"), 1}
+			}
+			return nil
+		},
+		Args: cobra.ExactArgs(0),
+	}
+	dump.Flags().StringVarP(&cfgFile, "config", "c", "gnorm.toml", "relative path to gnorm config file (toml, yaml, or json)")
+	dump.Flags().StringVarP(&profile, "profile", "p", "", "name of the profile in the config file to use")
+	dump.Flags().StringVarP(&output, "output", "o", "", "file to write the snapshot to, instead of stdout")
+	dump.Flags().StringVarP(&format, "format", "f", "", "snapshot format: json or yaml (default based on the output file's extension, or json)")
+	dump.Flags().BoolVarP(&verbose, "verbose", "v", false, "show debugging output")
+	return dump
+}
+
+func diffCmd(ctx context.Context, env environ.Values) *cobra.Command {
+	var cfgFile string
+	var profile string
+	var verbose bool
+	var snapshot string
+	var againstConfig string
+	var againstProfile string
+	var migration string
+	var migrationName string
+	var migrationDir string
+	var dialect string
+	diff := &cobra.Command{
+		Use:   "diff",
+		Short: "Compare your database's schema against a snapshot or another database",
+		Long: `
+Reads your database the same way gen does, and compares its schema against a
+snapshot saved with gnorm dump (with --snapshot), or against the database of
+another config file or profile (with --against-config and --against-profile).
+Each added, removed, or changed schema, table, column, index, or enum is printed
+on its own line, prefixed with +, -, or ~.  Changes to a column's type,
+nullability, default, primary key, or foreign key are reported.  If there are
+any differences, gnorm exits with a non-zero code, so diff can be used in CI to
+check for schema drift.
+
+With --migration, the differences are also written as a skeleton migration, in
+the layout of goose or golang-migrate, to the directory given with
+--migration-dir, named with the current time and --migration-name.  The up
+migration changes the schema you compare against into your database's schema,
+and the down migration changes it back.  Added and removed tables, columns,
+indexes, and enums get the statements that add or remove them, in the SQL
+dialect given with --dialect, and other changes get TODO comments to fill in.
+The foreign keys of added tables are added once all the tables are created, and
+those of removed tables are dropped first, so tables may reference each other.
+When a migration is written, gnorm exits with a zero code.`[1:],
+		RunE: func(cmd *cobra.Command, args []string) error {
+			env.InitLog(verbose)
+			if (snapshot == "") == (againstConfig == "" && againstProfile == "") {
+				return codeErr{errors.New("either --snapshot or --against-config/--against-profile must be given"), 2}
+			}
+			var layout run.MigrationLayout
+			switch strings.ToLower(migration) {
+			case "":
+			case "goose":
+				layout = run.MigrationGoose
+			case "golang-migrate", "migrate":
+				layout = run.MigrationGolangMigrate
+			default:
+				return codeErr{errors.Errorf("unknown migration layout %q", migration), 2}
+			}
+			ddialect, err := parseDialect(dialect)
+			if err != nil {
+				return codeErr{err, 2}
+			}
+			cfg, err := parseFile(env, cfgFile, profile)
+			if err != nil {
+				return codeErr{err, 2}
+			}
+			var old *database.Info
+			if snapshot != "" {
+				old, err = run.ReadSnapshot(snapshot)
+				if err != nil {
+					return codeErr{err, 2}
+				}
+			} else {
+				if againstConfig == "" {
+					againstConfig = cfgFile
+				}
+				other, err := parseFile(env, againstConfig, againstProfile)
+				if err != nil {
+					return codeErr{err, 2}
+				}
+				old, err = run.ReadSchema(ctx, env, other)
+				if err != nil {
+					return codeErr{errors.WithMessage(err, "error reading database to compare against"), 1}
+				}
+			}
+			info, err := run.ReadSchema(ctx, env, cfg)
+			if err != nil {
+				return codeErr{err, 1}
+			}
+			diffs := run.DiffSchemas(old, info)
+			for _, d := range diffs {
+				fmt.Fprintln(env.Stdout, d)
+			}
+			if migration != "" && len(diffs) > 0 {
+				up, down := run.MigrationSQL(old, info, ddialect)
+				version := time.Now().UTC().Format("20060102150405")
+				if err := run.WriteMigration(run.DirSink(migrationDir), layout, version, migrationName, up, down); err != nil {
+					return codeErr{err, 1}
+				}
+				return nil
+			}
+			if len(diffs) > 0 {
+				return codeErr{errors.Errorf("found %d difference(s)", len(diffs)), 1}
+			}
+			return nil
+		},
+		Args: cobra.ExactArgs(0),
+	}
+	diff.Flags().StringVarP(&cfgFile, "config", "c", "gnorm.toml", "relative path to gnorm config file (toml, yaml, or json)")
+	diff.Flags().StringVarP(&profile, "profile", "p", "", "name of the profile in the config file to use")
+	diff.Flags().StringVar(&snapshot, "snapshot", "", "snapshot file to compare the database against")
+	diff.Flags().StringVar(&againstConfig, "against-config", "", "config file for the database to compare against (defaults to --config)")
+	diff.Flags().StringVar(&againstProfile, "against-profile", "", "profile for the database to compare against")
+	diff.Flags().StringVar(&migration, "migration", "", "write the differences as a migration, in the layout of goose or golang-migrate")
+	diff.Flags().StringVar(&migrationName, "migration-name", "schema_changes", "name of the migration, after its version")
+	diff.Flags().StringVar(&migrationDir, "migration-dir", ".", "directory to write the migration to")
+	diff.Flags().StringVar(&dialect, "dialect", "standard", "SQL dialect of the migration: standard, postgres, or mysql")
+	diff.Flags().BoolVarP(&verbose, "verbose", "v", false, "show debugging output")
+	return diff
+}
+
+func driversCmd(env environ.Values) *cobra.Command {
 	return &cobra.Command{
-		Use:   "version",
-		Short: "Displays the version of GNORM.",
+		Use:   "drivers",
+		Short: "List the database drivers and what they support",
 		Long: `
-Shows the build date and commit hash used to build this binary.`[1:],
-		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Fprintf(env.Stdout, "version: %s\nbuilt at: %s\ncommit hash: %s", version, timestamp, commitHash)
+Lists the database drivers compiled into gnorm, which are the values DBType may
+have, including any that third-party packages register with drivers.Register in
+a custom build of gnorm, along with what each of them reads from the database
+and which features they support, so you know what data your templates can rely
+on.  Enums, Views, Comments, Indexes, Foreign Keys, and Sequences say whether
+that part of the schema is read and passed to your templates.  Queries says
+whether Queries can be used, TLS whether the TLS settings can be used, and
+Catalog Checks whether gnorm doctor can check that the database user can read
+the driver's catalogs.  Driver plugins, which DBType names with "plugin:" and
+the path to their executable, aren't listed.`[1:],
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fmt.Fprint(env.Stdout, renderMatrix(driverMatrix()))
+			return nil
 		},
+		Args: cobra.ExactArgs(0),
 	}
 }
 
+func configCmd(env environ.Values) *cobra.Command {
+	config := &cobra.Command{
+		Use:   "config",
+		Short: "Tools for working with gnorm config files",
+		Args:  cobra.NoArgs,
+	}
+	config.AddCommand(&cobra.Command{
+		Use:   "schema",
+		Short: "Print a JSON Schema for the config file",
+		Long: `
+Prints a JSON Schema that describes all the fields of the gnorm config file,
+with their documentation.  Editors can use it to offer autocompletion and
+validation while you edit your config (for toml files, through extensions like
+Even Better TOML), and CI can use it to check config files.`[1:],
+		RunE: func(cmd *cobra.Command, args []string) error {
+			b, err := json.MarshalIndent(configSchema(), "", "  ")
+			if err != nil {
+				return codeErr{err, 1}
+			}
+			fmt.Fprintln(env.Stdout, string(b))
+			return nil
+		},
+		Args: cobra.ExactArgs(0),
+	})
+	return config
+}
+
 func initCmd(env environ.Values) *cobra.Command {
-	return &cobra.Command{
+	var driver string
+	var lang string
+	var zod bool
+	init := &cobra.Command{
 		Use:   "init",
 		Short: "Generates the files needed to run GNORM.",
 		Long: `
-Creates a default gnorm.toml and the various template files needed to run GNORM.`[1:],
+Creates a default gnorm.toml and the various template files needed to run GNORM.
+With --driver, the gnorm.toml is set up for that database, with a connection
+string in the driver's format and TypeMaps for its common types.  With --lang,
+the templates are a starter kit that generates types for your tables, schemas,
+and enums in that language, and the TypeMaps map to that language's types.
+With --lang typescript and --zod, the table and enum templates also generate zod
+schemas that validate rows and enum values, and the table types are inferred
+from them.`[1:],
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return initFunc(".")
+			if zod && strings.ToLower(lang) != "typescript" {
+				return codeErr{errors.New("--zod can only be used with --lang typescript"), 2}
+			}
+			return initFunc(".", driver, lang, zod)
 		},
 		Args: cobra.ExactArgs(0),
 	}
+	init.Flags().StringVar(&driver, "driver", "", "database driver to set up the config for: "+strings.Join(initDrivers(), " or "))
+	init.Flags().StringVar(&lang, "lang", "", "language of the starter templates: "+strings.Join(initLangs(), ", "))
+	init.Flags().BoolVar(&zod, "zod", false, "generate zod schemas with the typescript templates")
+	return init
 }
 
-func docCmd(env environ.Values) *cobra.Command {
-	return &cobra.Command{
+func docCmd(ctx context.Context, env environ.Values) *cobra.Command {
+	docs := &cobra.Command{
 		Use:   "docs",
 		Short: "Runs a local webserver serving gnorm documentation.",
 		Long: `
@@ -131,22 +1005,140 @@ of Gnorm.`[1:],
 			return showDocs(env, cmd, args)
 		},
 	}
+	docs.AddCommand(docsSchemaCmd(ctx, env))
+	docs.AddCommand(docsCSVCmd(ctx, env))
+	return docs
 }
 
-func initFunc(dir string) error {
+func docsCSVCmd(ctx context.Context, env environ.Values) *cobra.Command {
+	var cfgFile string
+	var profile string
+	var verbose bool
+	var from string
+	csv := &cobra.Command{
+		Use:   "csv",
+		Short: "Print a data dictionary of your database as CSV",
+		Long: `
+Reads your database the same way gen does, using the same filters, and prints a
+data dictionary of it as CSV, with a header and then a row for each column of
+each table, giving its schema, table, name, type, nullability, default, and
+comment.  Spreadsheets such as Excel and Google Sheets can open it directly.
+With --from, the schema is read from a snapshot saved with gnorm dump instead of
+from your database.`[1:],
+		RunE: func(cmd *cobra.Command, args []string) error {
+			env.InitLog(verbose)
+			cfg, err := parseFile(env, cfgFile, profile)
+			if err != nil {
+				return codeErr{err, 2}
+			}
+			cfg.Snapshot = from
+			if err := run.DictionaryCSV(ctx, env, cfg); err != nil {
+				return codeErr{err, 1}
+			}
+			return nil
+		},
+		Args: cobra.ExactArgs(0),
+	}
+	csv.Flags().StringVarP(&cfgFile, "config", "c", "gnorm.toml", "relative path to gnorm config file (toml, yaml, or json)")
+	csv.Flags().StringVarP(&profile, "profile", "p", "", "name of the profile in the config file to use")
+	csv.Flags().StringVar(&from, "from", "", "snapshot file to read the schema from, instead of the database")
+	csv.Flags().BoolVarP(&verbose, "verbose", "v", false, "show debugging output")
+	return csv
+}
+
+func docsSchemaCmd(ctx context.Context, env environ.Values) *cobra.Command {
+	var cfgFile string
+	var profile string
+	var verbose bool
+	var from string
+	var outputDir string
+	schema := &cobra.Command{
+		Use:   "schema",
+		Short: "Write a markdown data dictionary of your database",
+		Long: `
+Reads your database the same way gen does, using the same filters, and writes a
+markdown data dictionary of it, with a page for each schema, e.g. public.md.
+Each page lists the schema's tables, with the type, nullability, default, and
+comment of each of their columns, and its enums, with their values.  Foreign
+keys link to the tables they reference, and each table links to the tables
+that reference it, across pages.  The pages are written to the directory given
+with --output-dir, or the OutputDir in the config file.  With --from, the
+schema is read from a snapshot saved with gnorm dump instead of from your
+database.`[1:],
+		RunE: func(cmd *cobra.Command, args []string) error {
+			env.InitLog(verbose)
+			cfg, err := parseFile(env, cfgFile, profile)
+			if err != nil {
+				return codeErr{err, 2}
+			}
+			cfg.Snapshot = from
+			if outputDir == "" {
+				outputDir = cfg.OutputDir
+			}
+			if err := run.Dictionary(ctx, env, cfg, run.DirSink(outputDir)); err != nil {
+				return codeErr{err, 1}
+			}
+			return nil
+		},
+		Args: cobra.ExactArgs(0),
+	}
+	schema.Flags().StringVarP(&cfgFile, "config", "c", "gnorm.toml", "relative path to gnorm config file (toml, yaml, or json)")
+	schema.Flags().StringVarP(&profile, "profile", "p", "", "name of the profile in the config file to use")
+	schema.Flags().StringVarP(&outputDir, "output-dir", "o", "", "directory to write the pages to, overriding OutputDir in the config file")
+	schema.Flags().StringVar(&from, "from", "", "snapshot file to read the schema from, instead of the database")
+	schema.Flags().BoolVarP(&verbose, "verbose", "v", false, "show debugging output")
+	return schema
+}
+
+// parseDialect returns the SQL dialect named by a --dialect flag.
+func parseDialect(dialect string) (run.DDLDialect, error) {
+	switch strings.ToLower(dialect) {
+	case "standard":
+		return run.DDLStandard, nil
+	case "postgres":
+		return run.DDLPostgres, nil
+	case "mysql":
+		return run.DDLMySQL, nil
+	}
+	return 0, errors.Errorf("unknown SQL dialect %q", dialect)
+}
+
+func initFunc(dir, driver, lang string, zod bool) error {
+	cfg, err := sampleConfig(driver, lang)
+	if err != nil {
+		return codeErr{err, 2}
+	}
+	templates := map[string]string{
+		"table.gotmpl":  "Table: {{.Table.Name}}\n{{printf \"%#v\" .}}",
+		"enum.gotmpl":   "Enum: {{.Enum.Name}}\n{{printf \"%#v\" .}}",
+		"schema.gotmpl": "Schema: {{.Schema.Name}}\n{{printf \"%#v\" .}}",
+	}
+	if lang != "" {
+		for name := range templates {
+			starter := lang
+			if zod && name != "schema.gotmpl" {
+				// the zod templates replace the table and enum templates.
+				starter += "/zod"
+			}
+			t, err := starterTemplate(starter, name)
+			if err != nil {
+				return codeErr{err, 1}
+			}
+			templates[name] = t
+		}
+	}
 	if err := os.MkdirAll(filepath.Join(dir, "static"), 0700); err != nil {
 		return codeErr{err, 1}
 	}
-	if err := createFile(filepath.Join(dir, "gnorm.toml"), sample); err != nil {
-		return err
-	}
-	if err := createFile(filepath.Join(dir, "templates/table.gotmpl"), "Table: {{.Table.Name}}\n{{printf \"%#v\" .}}"); err != nil {
+	if err := createFile(filepath.Join(dir, "gnorm.toml"), cfg); err != nil {
 		return err
 	}
-	if err := createFile(filepath.Join(dir, "templates/enum.gotmpl"), "Enum: {{.Enum.Name}}\n{{printf \"%#v\" .}}"); err != nil {
-		return err
+	for _, name := range []string{"table.gotmpl", "enum.gotmpl", "schema.gotmpl"} {
+		if err := createFile(filepath.Join(dir, "templates", name), templates[name]); err != nil {
+			return err
+		}
 	}
-	return createFile(filepath.Join(dir, "templates/schema.gotmpl"), "Schema: {{.Schema.Name}}\n{{printf \"%#v\" .}}")
+	return nil
 }
 
 func createFile(name, contents string) error {
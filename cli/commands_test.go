@@ -2,16 +2,19 @@ package cli
 
 import (
 	"bytes"
+	"go/format"
 	"io/ioutil"
-	"log"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/BurntSushi/toml"
 	"github.com/google/go-cmp/cmp"
 
 	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run"
+	"gnorm.org/gnorm/run/data"
 )
 
 func makeEnv() (stderr, stdout *bytes.Buffer, env environ.Values) {
@@ -20,7 +23,6 @@ func makeEnv() (stderr, stdout *bytes.Buffer, env environ.Values) {
 	return stderr, stdout, environ.Values{
 		Stdout: stdout,
 		Stderr: stderr,
-		Log:    log.New(stdout, "", 0),
 		Env:    map[string]string{},
 	}
 }
@@ -48,7 +50,7 @@ func TestInitCmd(t *testing.T) {
 		t.Fatal(err)
 	}
 	defer os.Remove(d)
-	if err := initFunc(d); err != nil {
+	if err := initFunc(d, "", "", false); err != nil {
 		t.Fatalf("error running initfunc: %v", err)
 	}
 	cfgFile := filepath.Join(d, "gnorm.toml")
@@ -84,3 +86,113 @@ func TestInitCmd(t *testing.T) {
 		t.Errorf("missing enum template")
 	}
 }
+
+func TestInitDriver(t *testing.T) {
+	for _, driver := range initDrivers() {
+		d := t.TempDir()
+		if err := initFunc(d, driver, "", false); err != nil {
+			t.Fatalf("error running initfunc for %s: %v", driver, err)
+		}
+		b, err := ioutil.ReadFile(filepath.Join(d, "gnorm.toml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var c Config
+		if _, err := toml.Decode(string(b), &c); err != nil {
+			t.Fatalf("error parsing gnorm.toml for %s: %v", driver, err)
+		}
+		expected := driverSamples[driver]
+		if c.DBType != driver || c.ConnStr != expected.ConnStr {
+			t.Fatalf("expected DBType %q and ConnStr %q, but got %q and %q", driver, expected.ConnStr, c.DBType, c.ConnStr)
+		}
+		if _, err := getDriver(c.DBType); err != nil {
+			t.Fatal(err)
+		}
+		if len(expected.Types) != len(c.TypeMap) || len(c.TypeMap) != len(c.NullableTypeMap) {
+			t.Fatalf("expected the %s type maps, but got %v and %v", driver, c.TypeMap, c.NullableTypeMap)
+		}
+	}
+	if err := initFunc(t.TempDir(), "oracle", "", false); err == nil {
+		t.Fatal("expected an error for an unknown driver, but got nil")
+	}
+}
+
+func TestInitLang(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	schema := &data.Schema{Name: "Public", DBName: "public"}
+	table := &data.Table{Name: "UserAccount", DBName: "user_account", Schema: schema, Comment: "people who log in"}
+	table.Columns = data.Columns{
+		{Table: table, Name: "ID", DBName: "id", Type: "uuid.UUID", IsPrimaryKey: true},
+		{Table: table, Name: "Created", DBName: "created", Type: "time.Time"},
+		{Table: table, Name: "Nickname", DBName: "nickname", Type: "sql.NullString", Nullable: true},
+		{Table: table, Name: "Mood", DBName: "mood", DBType: "mood", UserDefined: true},
+	}
+	enum := &data.Enum{Name: "Mood", DBName: "mood", Schema: schema, Values: []*data.EnumValue{
+		{Name: "Happy", DBName: "happy"}, {Name: "Sad", DBName: "sad", Value: 1},
+	}}
+	schema.Tables = data.Tables{table}
+	schema.Enums = data.Enums{enum}
+	db := &data.DBData{Schemas: []*data.Schema{schema}}
+
+	type starter struct {
+		lang string
+		zod  bool
+	}
+	var starters []starter
+	for _, lang := range initLangs() {
+		starters = append(starters, starter{lang: lang})
+	}
+	starters = append(starters, starter{lang: "typescript", zod: true})
+	for _, s := range starters {
+		lang := s.lang
+		d := t.TempDir()
+		if err := initFunc(d, "", lang, s.zod); err != nil {
+			t.Fatalf("error running initfunc for %s: %v", lang, err)
+		}
+		if err := os.Chdir(d); err != nil {
+			t.Fatal(err)
+		}
+		_, _, env := makeEnv()
+		cfg, err := parseFile(env, "gnorm.toml", "")
+		if err != nil {
+			t.Fatalf("error parsing gnorm.toml for %s: %v", lang, err)
+		}
+		if problems := lint(cfg); len(problems) > 0 {
+			t.Fatalf("unexpected problems in the %s templates: %q", lang, problems)
+		}
+		targets := []struct {
+			targets []run.OutputTarget
+			data    interface{}
+		}{
+			{cfg.TablePaths, data.TableData{Table: table, DB: db}},
+			{cfg.SchemaPaths, data.SchemaData{Schema: schema, DB: db}},
+			{cfg.EnumPaths, data.EnumData{Enum: enum, DB: db}},
+		}
+		for _, tt := range targets {
+			if len(tt.targets) != 1 {
+				t.Fatalf("expected 1 target for %s, but got %d", lang, len(tt.targets))
+			}
+			var buf bytes.Buffer
+			if err := tt.targets[0].Contents.Execute(&buf, tt.data); err != nil {
+				t.Fatalf("error rendering %s template: %v", lang, err)
+			}
+			if lang == "go" {
+				if _, err := format.Source(buf.Bytes()); err != nil {
+					t.Fatalf("generated go doesn't parse: %v\n%s", err, buf.Bytes())
+				}
+			}
+			if _, ok := tt.data.(data.TableData); ok && s.zod {
+				for _, want := range []string{`import { moodSchema } from "./mood.enum";`, "  mood: moodSchema,\n", "  nickname: z.unknown().nullable(),\n"} {
+					if !strings.Contains(buf.String(), want) {
+						t.Fatalf("expected the zod table template to contain %q, but got:\n%s", want, buf.String())
+					}
+				}
+			}
+		}
+	}
+}
@@ -2,12 +2,87 @@ package cli
 
 // Config holds the schema that is expected to exist in the gnorm.toml file.
 type Config struct {
+	// Extends, if specified, is the path to another config file (which may be
+	// toml, yaml, or json) that this config file is based on.  Values from that
+	// file are used unless this file sets them.  Tables such as TypeMap are
+	// merged, with keys in this file overriding the same keys in the base file.
+	// A relative path is relative to the directory of this config file.
+	Extends string
+
+	// Profiles holds sets of values that override the values in the rest of
+	// the config, so one config file can be used for different environments.
+	// A profile is selected with the --profile flag.
+	Profiles map[string]Profile
+
 	// ConnStr is the connection string for the database.  Environment variables
-	// in $FOO form will be expanded.
+	// in $FOO form will be expanded.  The special $GNORM_PASSWORD variable
+	// will expand to the password read from the source given in Password.
+	//
+	// ConnStr may instead refer to a secret holding the connection string,
+	// which is read when gnorm runs: "vault://path#key" reads key from the
+	// secret at path from Vault at $VAULT_ADDR using $VAULT_TOKEN,
+	// "awssm://secret-id" reads from AWS Secrets Manager using the aws CLI,
+	// and "gcpsm://secret" reads from Google Cloud Secret Manager using the
+	// gcloud CLI.  For the latter two, #key may be added to read key from a
+	// secret that holds a json object.
 	ConnStr string
 
-	// The type of DB you're connecting to.  Currently the possible values are
-	// "postgres" or "mysql".
+	// ConnStrFile, if specified, is the path to a file containing the
+	// connection string, which is used instead of ConnStr, so that it can be
+	// kept out of the config file.  You cannot set both ConnStr and
+	// ConnStrFile.
+	ConnStrFile string
+
+	// Password describes where to read the database password from, so it can
+	// be kept out of the config file and your shell history.  The password is
+	// used in ConnStr as $GNORM_PASSWORD.
+	Password Password
+
+	// SSHTunnel, if specified, is an SSH server (such as a bastion host) that
+	// gnorm connects to the database through, for databases that aren't
+	// directly reachable.  Gnorm opens the tunnel itself, and $GNORM_TUNNEL in
+	// ConnStr is replaced with the local address of the tunnel.
+	SSHTunnel *SSHTunnel
+
+	// TLS, if specified, holds the TLS settings used to connect to the
+	// database, which the driver adds to the connection string.
+	TLS *TLS
+
+	// ConnectTimeout and QueryTimeout are durations, like "10s", that limit how
+	// long connecting to the database and each query made while reading it
+	// may take.  Retries is the number of times to retry reading the
+	// database if it fails.
+	ConnectTimeout string
+	QueryTimeout   string
+	Retries        int
+
+	// ReadOnly, if true, reads the database in a read-only transaction, so the
+	// credentials gnorm uses can't be used to change the database.
+	ReadOnly bool
+
+	// Overlays, if specified, are paths to JSON or YAML files in the form of
+	// a snapshot from gnorm dump, which are layered in order over the schema
+	// read from the database, to add or change tables, columns, comments, and
+	// enums that aren't in the database yet, such as while a migration is
+	// still in review.  Only the fields an overlay sets are changed.
+	Overlays []string
+
+	// Database, if specified, lists several databases to read in one run,
+	// each with its own DBType, ConnStr, and Schemas, which then must not be
+	// set at the top level.  Their schemas are all passed to the templates
+	// together, each with the Name of the database it's from.
+	Database []DatabaseConfig
+
+	// The type of DB you're connecting to.  The built-in types are "postgres",
+	// "mysql", "ddl", "dbml", and "prisma".  The last three don't connect to a
+	// database: "ddl" reads the CREATE TABLE, CREATE TYPE, and CREATE INDEX
+	// statements in the .sql file or directory of .sql files that ConnStr
+	// names, "dbml" reads the tables, enums, and refs in the DBML file that
+	// ConnStr names, and "prisma" reads the models and enums in the Prisma
+	// schema file that ConnStr names.
+	// Builds of gnorm that import third-party drivers may have others, which
+	// "gnorm drivers" lists.  "plugin:" followed by the path to an executable
+	// uses that executable as the driver.
 	DBType string
 
 	// Schemas holds the names of schemas to generate code for.
@@ -17,20 +92,51 @@ type Config struct {
 	// in this list will not be included in data geenrated by gnorm. You cannot
 	// set IncludeTables if ExcludeTables is set.  By default, tables will be
 	// included in all schemas.  To specify tables for a specific schema only,
-	// use the schema.tablenmae format.
+	// use the schema.tablenmae format.  Table names may be glob patterns, like
+	// "audit_*", or regular expressions surrounded by slashes, like "/^tmp_/".
 	IncludeTables []string
 
 	// ExcludeTables is a blacklist of tables to ignore while generating data.
 	// All tables in a schema that are not in this list will be used for
 	// generation. You cannot set ExcludeTables if IncludeTables is set.  By
 	// default, tables will be excluded from all schemas.  To specify tables for
-	// a specific schema only, use the schema.tablenmae format.
+	// a specific schema only, use the schema.tablenmae format.  Patterns may be
+	// used as in IncludeTables.
 	ExcludeTables []string
 
+	// ExcludeColumns maps "schema.table", or just "table" for tables of that
+	// name in any schema, to lists of columns to leave out of the data
+	// generated by gnorm, such as password hashes.  Column names may be
+	// patterns, as in IncludeTables.
+	ExcludeColumns map[string][]string
+
+	// IncludeExpr is a CEL expression (https://github.com/google/cel-spec)
+	// that is evaluated for each table.  Only tables for which it is true will
+	// be included in the data generated by gnorm.  The table is available as
+	// the value table, with the fields name, schema, type, comment, isView,
+	// isInsertable, columns, primaryKeys (lists of column names), foreignKeys
+	// (a list of referenced table names), and isJoinTable (true if the table
+	// has two foreign keys and no other columns besides primary keys).  For
+	// example, "table.name.startsWith('app_') && !table.isJoinTable".
+	IncludeExpr string
+
+	// ExcludeExpr is a CEL expression like IncludeExpr.  Tables for which it
+	// is true will be left out of the data generated by gnorm.  Both
+	// expressions are applied after IncludeTables and ExcludeTables.
+	ExcludeExpr string
+
 	// TemplateEngine, if specified, describes a command line tool to run to
 	// render your templates, allowing you to use your preferred templating
 	// engine.  If not specified, go's text/template will be used to render.
 	TemplateEngine struct {
+		// Name is the name of the built-in template engine used to render your
+		// templates.  The possible values are "text/template" (the default),
+		// "pongo2", which uses Jinja/Django-style templates, and "mustache",
+		// which uses logic-less mustache templates.  Handlebars helpers and
+		// blocks such as {{#each}} and {{#if}} are not supported.  You cannot
+		// set both Name and CommandLine.
+		Name string
+
 		// CommandLine is the command to run to render the template.  You may
 		// pass the following variables to the command line - {{.Data}} the name
 		// of a .json file containing the gnorm data serialized into json,
@@ -49,9 +155,17 @@ type Config struct {
 	// generated by GNORM.  It is generally used to reformat the file, but it
 	// can be for any use. Environment variables will be expanded, and the
 	// special $GNORMFILE environment variable may be used, which will expand to
-	// the name of the file that was just generated.
+	// the name of the file that was just generated.  Files whose generated
+	// contents are the same as the existing file are not rewritten, and
+	// PostRun is not run for them.
 	PostRun []string
 
+	// PostRunWarnOnly, if true, logs a warning when PostRun fails for a file
+	// instead of aborting the run.  PostRunWorkers is the number of PostRun
+	// commands run at the same time, which defaults to one at a time.
+	PostRunWarnOnly bool
+	PostRunWorkers  int
+
 	// NameConversion defines how the DBName of tables, schemas, and enums are
 	// converted into their Name value.  This is a template that may use all the
 	// regular functions.  The "." value is the DB name of the item. Thus, to
@@ -60,6 +174,63 @@ type Config struct {
 	// "{{pascal .}}".
 	NameConversion string
 
+	// NameConversionCommand, if specified, is a command with arguments that is
+	// used instead of NameConversion to convert DBNames into Names.  GNORM writes
+	// all the DBNames to the command's stdin, one per line, and the command must
+	// write the converted names to stdout, one per line, in the same order.
+	// Environment variables will be expanded.  You cannot set both
+	// NameConversion and NameConversionCommand.
+	NameConversionCommand []string
+
+	// NameConversionFormat is how names are sent to the NameConversionCommand,
+	// either "lines", the default, or "json".  With "json", GNORM writes the
+	// DBNames as a JSON array of strings, and the command must write the
+	// converted names as a JSON array of strings, in the same order.
+	NameConversionFormat string
+
+	// LuaScript, if specified, is the path to a lua script that is run over the
+	// database data before any templates are rendered.  The script sees the
+	// data as the global value db and the Params as the global value params.
+	// It may modify the data, e.g. renaming tables or changing column types,
+	// and may add its own values to the Meta map of any schema, table, column,
+	// or enum, which templates can then read as e.g. .Table.Meta.audited.
+	LuaScript string
+
+	// DataHook, if specified, is a command with arguments that is run over the
+	// database data after the LuaScript, before any templates are rendered,
+	// e.g. to add ownership tags or ACLs from other systems to the Meta maps.
+	// GNORM writes the data to the command's stdin as JSON, in the same form as
+	// gnorm preview -format json, and the command must write it back to stdout
+	// in the same form.  Changes to the Name, Comment, and Meta of schemas,
+	// tables, columns, and enums, and to the Type of columns, are kept.
+	// Environment variables will be expanded.
+	DataHook []string
+
+	// Header, if specified, is a template that is rendered and written at the
+	// start of every generated file.  It may reference .Version (the version of
+	// gnorm), .Schema (the DBName of the schema the file was generated from,
+	// empty for DBPaths), .Timestamp (the time the file was generated, only set
+	// if HeaderTimestamp is true), and .DoNotEdit (the text "Code generated by
+	// gnorm. DO NOT EDIT.", which go tools recognize).
+	Header string
+
+	// HeaderTimestamp, if true, sets the .Timestamp value passed to Header.
+	// Note that this makes every generated file change each time gnorm is run.
+	HeaderTimestamp bool
+
+	// LicenseHeaderFile, if specified, is the path to a file of license text
+	// that's written at the start of every generated file, before the Header,
+	// as a comment in the language of the file, picked from its extension.
+	// Files in languages gnorm doesn't know the comments of, like JSON, are
+	// left without it.
+	LicenseHeaderFile string
+
+	// Queries maps names to SQL queries that are run against the database when
+	// it is read, on the same connection.  The rows each query returns are
+	// available to all templates as .DB.Queries.name, where each row is a map
+	// of column names to values.
+	Queries map[string]string
+
 	// TablePaths is a set of "output-path" = "template-path" pairs that tells
 	// Gnorm how to render and output its table info.  Each template will be
 	// rendered with each table in turn and written out to the given output
@@ -97,13 +268,53 @@ type Config struct {
 	// the "public.book_type" enum to ./gnorm/public/enums/users.go.
 	EnumPaths map[string]string
 
+	// DBPaths is a set of "output-path" = "template-path" pairs that tells
+	// Gnorm how to render and output the data for the whole database.  Each
+	// template is rendered once, with all the schemas, and written out to the
+	// given output path.  This is useful for files that span schemas, like a
+	// registry of all tables.  If no pairs are specified, no whole-database
+	// files will be rendered.
+	DBPaths map[string]string
+
+	// PartialsDir is a directory of templates that are shared by all your
+	// contents templates.  Each .gotmpl file in the directory is parsed as a
+	// template named after the file without its extension, so
+	// column_field.gotmpl may be used in any template with {{template
+	// "column_field" .}}.  Templates defined in those files are available as
+	// well.  For pongo2 and mustache templates, the directory is searched for
+	// included templates and partials.
+	PartialsDir string
+
+	// TableOptions, SchemaOptions, EnumOptions, and DBOptions hold settings
+	// that apply to all the TablePaths, SchemaPaths, EnumPaths, and DBPaths
+	// targets respectively.
+	TableOptions  TargetOptions
+	SchemaOptions TargetOptions
+	EnumOptions   TargetOptions
+	DBOptions     TargetOptions
+
+	// TableTemplates, SchemaTemplates, EnumTemplates, and DBTemplates are
+	// lists of output targets that are rendered in addition to the TablePaths,
+	// SchemaPaths, EnumPaths, and DBPaths targets respectively.  Unlike the
+	// Paths maps, targets in these lists are rendered in order, and each may
+	// set its own options.
+	TableTemplates  []TemplateTarget
+	SchemaTemplates []TemplateTarget
+	EnumTemplates   []TemplateTarget
+	DBTemplates     []TemplateTarget
+
 	// TypeMap is a mapping of database type names to replacement type names
 	// (generally types from your language for deserialization).  Types not in
 	// this list will remain in their database form.  In the data sent to your
 	// template, this is the Column.Type, and the original type is in
 	// Column.OrigType.  Note that because of the way tables in TOML work,
 	// TypeMap and NullableTypeMap must be at the end of your configuration
-	// file.
+	// file.  Keys surrounded by slashes are regular expressions that must match
+	// the whole database type, and their values may use the expression's
+	// capture groups, e.g. "/varchar\\(\\d+\\)/" = "string" or
+	// "/(\\w+)\\[\\]/" = "[]$1".  Exact keys are checked first.  When several
+	// expressions match, the longest one wins, and expressions of the same
+	// length are tried in sorted order.
 	TypeMap map[string]string
 
 	// NullableTypeMap is a mapping of database type names to replacement type
@@ -113,13 +324,36 @@ type Config struct {
 	// template, this is the Column.Type, and the original type is in
 	// Column.OrigType.   Note that because of the way tables in TOML work,
 	// TypeMap and NullableTypeMap must be at the end of your configuration
-	// file.
+	// file.  Keys may be regular expressions, as in TypeMap.
 	NullableTypeMap map[string]string
 
+	// NullableWrapper, if specified, is a template that gives the type of
+	// nullable columns whose database type isn't in NullableTypeMap, by
+	// wrapping the type it's mapped to in TypeMap, e.g. "*{{.Type}}" or
+	// "sql.Null[{{.Type}}]".  The template may also use .DBType, the column's
+	// database type.  Entries in NullableTypeMap take precedence over it.
+	NullableWrapper string
+
+	// ColumnTypes maps "schema.table.column" to the type of that column, for
+	// one-off columns that need a different type than their database type is
+	// mapped to, such as a text column that holds json.  It takes precedence
+	// over TypeMap, NullableTypeMap, and the type maps in Schema.
+	ColumnTypes map[string]string
+
+	// Schema holds TypeMaps and NullableTypeMaps that apply only to the tables
+	// of a single schema, or to a single table in that schema, keyed by schema
+	// name.  Their entries override the same types in TypeMap and
+	// NullableTypeMap, so the same database type may map to different types in
+	// different parts of your database.  For example, [Schema.billing.TypeMap]
+	// applies to the billing schema, and [Schema.billing.Table.invoices.TypeMap]
+	// applies to the invoices table in the billing schema.
+	Schema map[string]SchemaConfig
+
 	// Params contains any data you may want to pass to your templates.  This is
 	// a good way to make templates reusable with different configuration values
 	// for different situations.  The values in this field will be available in
-	// the .Params value for all templates.
+	// the .Params value for all templates, merged with the Params of the
+	// target's options, if any.
 	Params map[string]interface{}
 
 	// PluginDirs a list of paths that will be used for finding plugins.  The
@@ -127,12 +361,21 @@ type Config struct {
 	// The first plugin that is found will be the one used.
 	PluginDirs []string
 
+	// WasmPlugins maps plugin names to the paths of WebAssembly modules that
+	// may be called from templates with the wasm function, e.g. {{wasm "name"
+	// "function" .}}.  WebAssembly plugins use the same protocol as regular
+	// plugins, but run in a sandbox without access to the filesystem, network,
+	// or environment.
+	WasmPlugins map[string]string
+
 	// OutputDir is the directory relative to the project root (where the
 	// gnorm.toml file is located) in which all the generated files are written
 	// to.
 	//
 	// This defaults to the current working directory i.e the directory in which
-	// gnorm.toml is found.
+	// gnorm.toml is found.  Environment variables in it are expanded, as they
+	// are in the other paths in the config, and in the string values of
+	// Params.
 	OutputDir string
 
 	// StaticDir is the directory relative to the project root (where the
@@ -143,8 +386,216 @@ type Config struct {
 	// OutputDir
 	StaticDir string
 
+	// StaticTemplates, if true, renders each file in the StaticDir as a
+	// template before it's written to the OutputDir, so that mostly static
+	// files, like a Makefile or a go.mod stub, can use a few values from the
+	// config.  The templates are given the Config and Params, but no database
+	// data, and use the built-in engine named by TemplateEngine Name.
+	StaticTemplates bool
+
+	// StaticDirs are more directories of static files, each copied to its own
+	// destination under the OutputDir, after the files in StaticDir.
+	StaticDirs []StaticDir
+
 	// NoOverwriteGlobs is a list of globs
 	// (https://golang.org/pkg/path/filepath/#Match). If a filename matches a glob
 	// *and* a file exists with that name, it will not be generated.
 	NoOverwriteGlobs []string
+
+	// FileMode is the permissions, in octal, that generated files are given,
+	// e.g. "0644".  It's set on existing files too, whatever the umask.  By
+	// default new files are created with "0600", and existing files keep
+	// their permissions.
+	FileMode string
+
+	// DirMode is the permissions, in octal, that the directories created for
+	// generated files are given, e.g. "0755", before the umask is applied.
+	// It defaults to "0700".  Existing directories are left alone.
+	DirMode string
+
+	// AllowUnsafeFilenames, if true, lets Filename templates render paths
+	// that gnorm would otherwise refuse to write: absolute paths, paths
+	// outside the OutputDir, and names that are invalid on Windows, like ones
+	// with a colon or named CON.
+	AllowUnsafeFilenames bool
+}
+
+// TargetOptions holds settings that apply to all the output targets of one type.
+type TargetOptions struct {
+	// Engine is the name of the built-in template engine used to render the
+	// templates for these targets.  It overrides the TemplateEngine Name, and
+	// the TemplateEngine CommandLine, if set.
+	Engine string
+
+	// TrimBlankLines, if true, removes blank lines from the start and end of
+	// the rendered output.  It has no effect on output rendered by an external
+	// TemplateEngine CommandLine.
+	TrimBlankLines bool
+
+	// CollapseBlankLines, if true, replaces each run of consecutive blank lines
+	// in the rendered output with a single blank line.  It has no effect on
+	// output rendered by an external TemplateEngine CommandLine.
+	CollapseBlankLines bool
+
+	// Format, if set, formats the rendered output as Go source, in process,
+	// which is faster and more reliable than running gofmt with PostRun.  It
+	// may be "gofmt", or "goimports", which also adds missing imports and
+	// removes unused ones.  Output that isn't valid Go is an error.  It has no
+	// effect on output rendered by an external TemplateEngine CommandLine.
+	Format string
+
+	// FileMode, if set, overrides the top level FileMode for these targets,
+	// e.g. "0755" for generated scripts that must be executable.
+	FileMode string
+
+	// Params are merged over the top-level Params for these targets, so values
+	// only some targets need don't have to be passed to all of them.  Nested
+	// tables are merged key by key; any other value replaces the one it
+	// overrides.
+	Params map[string]interface{}
+}
+
+// TemplateTarget is a single output target, made up of a contents template and
+// the filename template for the file it renders to.
+type TemplateTarget struct {
+	// Template is the path to the contents template.
+	Template string
+
+	// Filename is the template for the output path, which may reference the
+	// same values as the keys of the corresponding Paths map.  If it renders
+	// to "-", the output is written to stdout instead of to a file.
+	Filename string
+
+	// TargetOptions holds options for this target only.  A non-empty Engine,
+	// Format, or FileMode overrides the one in the options for its type,
+	// TrimBlankLines and CollapseBlankLines apply if set here or for its type,
+	// and Params are merged over the Params for its type.
+	TargetOptions
+}
+
+// StaticDir is a directory of static files to copy to the OutputDir.
+type StaticDir struct {
+	// Dir is the directory the files are copied from.
+	Dir string
+
+	// Dest is the directory under the OutputDir the files are copied to.  It
+	// defaults to the OutputDir itself.
+	Dest string
+
+	// Include, if set, is a list of globs
+	// (https://golang.org/pkg/path/filepath/#Match), and only files that match
+	// one of them are copied.  Files that match one of the Exclude globs are
+	// not copied.  Globs are matched against both the path of a file relative
+	// to Dir and its base name, so "*.md" matches markdown files in every
+	// directory, and "docs/*" only the files in docs.
+	Include []string
+	Exclude []string
+
+	// Templates, if true, renders the files in Dir as templates, the same as
+	// StaticTemplates does for StaticDir.
+	Templates bool
+}
+
+// SchemaConfig holds type maps scoped to a schema, and to tables in that
+// schema.
+type SchemaConfig struct {
+	TypeMap         map[string]string
+	NullableTypeMap map[string]string
+
+	// Table holds type maps scoped to a table, keyed by table name.
+	Table map[string]TableConfig
+}
+
+// TableConfig holds type maps scoped to a table.
+type TableConfig struct {
+	TypeMap         map[string]string
+	NullableTypeMap map[string]string
+}
+
+// DatabaseConfig describes one of several databases to read.
+type DatabaseConfig struct {
+	// Name identifies the database in templates and output paths.
+	Name string
+
+	// DBType is the type of the database, such as "postgres", "mysql", "ddl",
+	// "dbml", "prisma", or "plugin:./mydriver".
+	DBType string
+
+	// ConnStr is the connection string for the database, which is expanded
+	// like the top level ConnStr.
+	ConnStr string
+
+	// Schemas holds the names of schemas to generate code for.
+	Schemas []string
+}
+
+// SSHTunnel describes the SSH server to connect to the database through.
+type SSHTunnel struct {
+	// Host is the address of the SSH server, as host or host:port.
+	Host string
+
+	// User is the user to log into the SSH server as.
+	User string
+
+	// KeyFile is the path to an unencrypted private key to authenticate with.
+	KeyFile string
+
+	// Agent, if true, authenticates using the SSH agent at $SSH_AUTH_SOCK.
+	Agent bool
+
+	// KnownHostsFile is the path to the known_hosts file used to verify the
+	// server's host key.  It defaults to ~/.ssh/known_hosts.
+	KnownHostsFile string
+
+	// InsecureIgnoreHostKey, if true, skips verifying the server's host key.
+	InsecureIgnoreHostKey bool
+
+	// Remote is the host:port of the database as seen from the SSH server.
+	Remote string
+}
+
+// TLS holds the TLS settings used to connect to the database.
+type TLS struct {
+	// CAFile is the path to a PEM file of certificate authorities used to
+	// verify the server's certificate.
+	CAFile string
+
+	// CertFile and KeyFile are the paths to the PEM client certificate and key.
+	CertFile string
+	KeyFile  string
+
+	// ServerName is the name the server's certificate is verified against.
+	ServerName string
+
+	// InsecureSkipVerify, if true, doesn't verify the server's certificate.
+	InsecureSkipVerify bool
+}
+
+// Profile holds values that override the values in the config file when the
+// profile is selected.  Values that aren't set are left unchanged.
+type Profile struct {
+	// ConnStr is the connection string for the database.
+	ConnStr string
+
+	// Schemas holds the names of schemas to generate code for.
+	Schemas []string
+
+	// OutputDir is the directory relative to the project root (where the
+	// gnorm.toml file is located) in which all the generated files are written
+	// to.
+	OutputDir string
+}
+
+// apply sets the values of the profile on c.
+func (p Profile) apply(c *Config) {
+	if p.ConnStr != "" {
+		c.ConnStr = p.ConnStr
+		c.ConnStrFile = ""
+	}
+	if len(p.Schemas) > 0 {
+		c.Schemas = p.Schemas
+	}
+	if p.OutputDir != "" {
+		c.OutputDir = p.OutputDir
+	}
 }
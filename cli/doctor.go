@@ -0,0 +1,27 @@
+package cli
+
+import (
+	"context"
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run"
+)
+
+// doctor checks everything gen needs to work: that the config file can be
+// read, that its templates parse, that the database can be connected to and
+// its catalogs read, and that the output directory can be written to.  The
+// checks after a failed config or templates check are skipped, since they
+// need the parsed config.
+func doctor(ctx context.Context, env environ.Values, file, profile string) []run.Check {
+	c, err := readConfigFile(env, file, profile)
+	checks := []run.Check{{Name: "config file", Err: err}}
+	if err != nil {
+		return checks
+	}
+	cfg, err := parseConfig(env, c)
+	checks = append(checks, run.Check{Name: "templates", Err: err})
+	if err != nil {
+		return checks
+	}
+	checks = append(checks, run.CheckDatabase(ctx, env, cfg)...)
+	return append(checks, run.Check{Name: "output directory", Err: run.CheckOutputDir(cfg)})
+}
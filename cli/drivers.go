@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"bytes"
+
+	"github.com/olekukonko/tablewriter"
+
+	"gnorm.org/gnorm/database"
+	"gnorm.org/gnorm/database/drivers"
+)
+
+// driverMatrix returns a table of what each registered driver supports, with
+// a header row, and a row for each driver sorted by name.
+func driverMatrix() [][]string {
+	names := drivers.Names()
+	rows := [][]string{{"Driver", "Enums", "Views", "Comments", "Indexes", "Foreign Keys", "Sequences", "Queries", "TLS", "Catalog Checks"}}
+	for _, name := range names {
+		d, _ := drivers.Lookup(name)
+		var f database.Features
+		if r, ok := d.(database.FeatureReporter); ok {
+			f = r.Features()
+		}
+		_, tls := d.(database.TLSConfigurer)
+		_, checks := d.(database.CatalogChecker)
+		rows = append(rows, []string{name, yesNo(f.Enums), yesNo(f.Views), yesNo(f.Comments), yesNo(f.Indexes), yesNo(f.ForeignKeys), yesNo(f.Sequences), yesNo(f.Queries), yesNo(tls), yesNo(checks)})
+	}
+	return rows
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// renderMatrix renders rows as a text table, with the first row as the header.
+func renderMatrix(rows [][]string) string {
+	buf := &bytes.Buffer{}
+	table := tablewriter.NewWriter(buf)
+	table.SetAutoFormatHeaders(false)
+	table.SetHeader(rows[0])
+	table.AppendBulk(rows[1:])
+	table.Render()
+	return buf.String()
+}
@@ -0,0 +1,25 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+
+	"gnorm.org/gnorm/database/drivers"
+)
+
+func TestDriverMatrix(t *testing.T) {
+	rows := driverMatrix()
+	if len(rows) != len(drivers.Names())+1 {
+		t.Fatalf("expected a header and a row per driver, but got %q", rows)
+	}
+	expected := []string{"postgres", "yes", "yes", "yes", "yes", "yes", "no", "yes", "yes", "yes"}
+	for _, row := range rows[1:] {
+		if row[0] == "postgres" {
+			if !reflect.DeepEqual(row, expected) {
+				t.Fatalf("expected postgres row %q, but got %q", expected, row)
+			}
+			return
+		}
+	}
+	t.Fatalf("expected a postgres row, but got %q", rows)
+}
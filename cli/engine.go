@@ -0,0 +1,288 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/cbroglie/mustache"
+	"github.com/flosch/pongo2/v6"
+	"github.com/pkg/errors"
+
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run"
+)
+
+// The names of the built-in template engines.
+const (
+	engineText     = "text/template"
+	enginePongo2   = "pongo2"
+	engineMustache = "mustache"
+)
+
+// contentsParser parses contents templates with the built-in template engines.
+// The zero value parses templates with gnorm's default functions and without
+// any partials.
+type contentsParser struct {
+	// funcs holds the functions available to templates.
+	funcs template.FuncMap
+
+	// fsys, if set, is the filesystem templates and partials are read from,
+	// instead of the OS filesystem.
+	fsys fs.FS
+
+	// partialsDir is the directory that holds templates shared by all the
+	// contents templates.
+	partialsDir string
+
+	// partials holds the parsed go templates from partialsDir.
+	partials *template.Template
+}
+
+// newContentsParser returns a contentsParser that makes the templates in
+// partialsDir available to every contents template.  For text/template, each
+// .gotmpl file in the directory is parsed as a template named after the file
+// (without the extension), and any templates it defines are available as well.
+// Templates are read from fsys, or the OS filesystem if fsys is nil.
+func newContentsParser(partialsDir string, funcs template.FuncMap, fsys fs.FS) (*contentsParser, error) {
+	p := &contentsParser{partialsDir: partialsDir, funcs: funcs, fsys: fsys}
+	if partialsDir == "" {
+		return p, nil
+	}
+	var files []string
+	var err error
+	if fsys != nil {
+		files, err = fs.Glob(fsys, path.Join(run.FSPath(partialsDir), "*.gotmpl"))
+	} else {
+		files, err = filepath.Glob(filepath.Join(partialsDir, "*.gotmpl"))
+	}
+	if err != nil {
+		return nil, errors.WithMessage(err, "error finding partials")
+	}
+	p.partials = template.New("").Funcs(p.funcMap())
+	for _, f := range files {
+		b, err := p.readFile(f)
+		if err != nil {
+			return nil, errors.WithMessage(err, "error reading partial")
+		}
+		name := strings.TrimSuffix(filepath.Base(f), filepath.Ext(f))
+		if _, err := p.partials.New(name).Parse(string(b)); err != nil {
+			return nil, errors.WithMessage(err, "error parsing partial "+f)
+		}
+	}
+	return p, nil
+}
+
+// readFile reads the template file at path.
+func (p *contentsParser) readFile(path string) ([]byte, error) {
+	if p.fsys == nil {
+		return ioutil.ReadFile(path)
+	}
+	return fs.ReadFile(p.fsys, run.FSPath(path))
+}
+
+// funcMap returns the functions available to templates.
+func (p *contentsParser) funcMap() template.FuncMap {
+	if p.funcs == nil {
+		return environ.FuncMap
+	}
+	return p.funcs
+}
+
+// parse parses the contents of the template file at path with the named
+// template engine.
+func (p *contentsParser) parse(engine, path string, contents []byte) (run.Template, error) {
+	switch engine {
+	case "", engineText:
+		if p.partials == nil {
+			return template.New(path).Funcs(p.funcMap()).Parse(string(contents))
+		}
+		t, err := p.partials.Clone()
+		if err != nil {
+			return nil, err
+		}
+		return t.New(path).Parse(string(contents))
+	case enginePongo2:
+		set, err := p.pongoSet(path)
+		if err != nil {
+			return nil, err
+		}
+		set.Globals.Update(pongo2.Context(p.funcMap()))
+		t, err := set.FromBytes(pongoUnescaped(contents))
+		if err != nil {
+			return nil, err
+		}
+		return pongoTemplate{t: t, path: path}, nil
+	case engineMustache:
+		// partials are loaded from the same directory as the template, or from
+		// the partials directory.
+		paths := []string{filepath.Dir(path)}
+		if p.partialsDir != "" {
+			paths = append(paths, p.partialsDir)
+		}
+		var partials mustache.PartialProvider = &mustache.FileProvider{Paths: paths}
+		if p.fsys != nil {
+			partials = fsPartials{fsys: p.fsys, paths: paths}
+		}
+		t, err := mustache.ParseStringPartialsRaw(string(contents), partials, true)
+		if err != nil {
+			return nil, err
+		}
+		return mustacheTemplate{t}, nil
+	default:
+		return nil, errors.Errorf("unknown template engine %q", engine)
+	}
+}
+
+// pongoSet returns the pongo2 template set for the template at path, which
+// loads included templates relative to the working directory, or the root of
+// p.fsys, and from the partials directory.
+func (p *contentsParser) pongoSet(path string) (*pongo2.TemplateSet, error) {
+	if p.fsys != nil {
+		set := pongo2.NewSet(path, unescapedLoader{pongo2.NewFSLoader(p.fsys)})
+		if p.partialsDir != "" {
+			sub, err := fs.Sub(p.fsys, run.FSPath(p.partialsDir))
+			if err != nil {
+				return nil, errors.WithMessage(err, "error loading partials")
+			}
+			set.AddLoader(unescapedLoader{pongo2.NewFSLoader(sub)})
+		}
+		return set, nil
+	}
+	set := pongo2.NewSet(path, unescapedLoader{pongo2.MustNewLocalFileSystemLoader("")})
+	if p.partialsDir != "" {
+		loader, err := pongo2.NewLocalFileSystemLoader(p.partialsDir)
+		if err != nil {
+			return nil, errors.WithMessage(err, "error loading partials")
+		}
+		set.AddLoader(unescapedLoader{loader})
+	}
+	return set, nil
+}
+
+// pongoExtends matches the extends tag of a pongo2 template.
+var pongoExtends = regexp.MustCompile(`{%-?\s*extends\s`)
+
+// pongoUnescaped returns the pongo2 template contents with autoescaping turned
+// off, since gnorm generates code, not html, and escaping output would just
+// mangle it.  pongo2 only has a process-wide switch for autoescaping besides
+// the autoescape tag, so the contents are wrapped in the tag instead.
+// Templates that extend another are left alone, since extends must be at the
+// top level; their blocks are rendered by the template they extend, which is
+// wrapped when it's loaded.
+func pongoUnescaped(contents []byte) []byte {
+	if pongoExtends.Match(contents) {
+		return contents
+	}
+	b := make([]byte, 0, len(contents)+len(pongoNoEscape)+len(pongoEndNoEscape))
+	b = append(b, pongoNoEscape...)
+	b = append(b, contents...)
+	return append(b, pongoEndNoEscape...)
+}
+
+const (
+	pongoNoEscape    = "{% autoescape off %}"
+	pongoEndNoEscape = "{% endautoescape %}"
+)
+
+// unescapedLoader turns off autoescaping in the templates that pongo2 loads,
+// such as those that are included or extended.
+type unescapedLoader struct {
+	pongo2.TemplateLoader
+}
+
+func (l unescapedLoader) Get(path string) (io.Reader, error) {
+	r, err := l.TemplateLoader.Get(path)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(pongoUnescaped(b)), nil
+}
+
+// fsPartials loads mustache partials from an fs.FS, the same way
+// mustache.FileProvider loads them from the OS filesystem: from the first of
+// paths that has a file with the partial's name and no extension, .mustache,
+// or .stache.  Partials that aren't found are empty.
+type fsPartials struct {
+	fsys  fs.FS
+	paths []string
+}
+
+func (f fsPartials) Get(name string) (string, error) {
+	for _, p := range f.paths {
+		for _, ext := range []string{"", ".mustache", ".stache"} {
+			b, err := fs.ReadFile(f.fsys, path.Join(run.FSPath(p), name+ext))
+			if err == nil {
+				return string(b), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// pongoTemplate adapts a pongo2 template to the run.Template interface.
+type pongoTemplate struct {
+	t    *pongo2.Template
+	path string
+}
+
+// Execute implements run.Template.  Errors that have a location are reported in
+// the same form as text/template errors, so that gnorm can show the template
+// source around the location.
+func (p pongoTemplate) Execute(w io.Writer, data interface{}) error {
+	err := p.t.ExecuteWriter(pongoContext(data), w)
+	if e, ok := err.(*pongo2.Error); ok && e.Line > 0 {
+		name := p.path
+		if e.Filename != "" && e.Filename != "<string>" {
+			name = e.Filename
+		}
+		return fmt.Errorf("template: %s:%d:%d: %v", name, e.Line, e.Column, e.OrigError)
+	}
+	return err
+}
+
+// pongoContext converts the data passed to templates into a pongo2 context.
+// Each exported field of a struct becomes a top level value in the context, so
+// that templates may refer to e.g. Table.Name, just like in text/template.
+func pongoContext(data interface{}) pongo2.Context {
+	ctx := pongo2.Context{}
+	if m, ok := data.(map[string]interface{}); ok {
+		for k, v := range m {
+			ctx[k] = v
+		}
+		return ctx
+	}
+	v := reflect.Indirect(reflect.ValueOf(data))
+	if v.Kind() != reflect.Struct {
+		return ctx
+	}
+	t := v.Type()
+	for x := 0; x < t.NumField(); x++ {
+		if f := t.Field(x); f.PkgPath == "" {
+			ctx[f.Name] = v.Field(x).Interface()
+		}
+	}
+	return ctx
+}
+
+// mustacheTemplate adapts a mustache template to the run.Template interface.
+type mustacheTemplate struct {
+	t *mustache.Template
+}
+
+// Execute implements run.Template.
+func (m mustacheTemplate) Execute(w io.Writer, data interface{}) error {
+	return m.t.FRender(w, data)
+}
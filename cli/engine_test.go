@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/flosch/pongo2/v6"
+
+	"gnorm.org/gnorm/run/data"
+)
+
+func TestPongo2Engine(t *testing.T) {
+	tmpl, err := (&contentsParser{}).parse(enginePongo2, "table.tpl", []byte(`{{ pascal(Table.Name) }}{% for c in Table.Columns %} {{ c.DBName }}{% endfor %} <{{ Params.x }}>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := data.TableData{
+		Table: &data.Table{
+			Name: "user_data",
+			Columns: data.Columns{
+				{DBName: "id"},
+				{DBName: "name"},
+			},
+		},
+		Params: map[string]interface{}{"x": "y&z"},
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, contents); err != nil {
+		t.Fatal(err)
+	}
+	expected := "UserData id name <y&z>"
+	if buf.String() != expected {
+		t.Fatalf("expected %q, but got %q", expected, buf.String())
+	}
+}
+
+func TestMustacheEngine(t *testing.T) {
+	tmpl, err := (&contentsParser{}).parse(engineMustache, "table.tpl", []byte(`{{Table.Name}}{{#Table.Columns}} {{DBName}}{{/Table.Columns}}{{#Table.HasPrimaryKey}} pk{{/Table.HasPrimaryKey}} <{{Params.x}}>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := data.TableData{
+		Table: &data.Table{
+			Name: "user_data",
+			Columns: data.Columns{
+				{DBName: "id"},
+				{DBName: "name"},
+			},
+		},
+		Params: map[string]interface{}{"x": "y&z"},
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, contents); err != nil {
+		t.Fatal(err)
+	}
+	expected := "user_data id name <y&z>"
+	if buf.String() != expected {
+		t.Fatalf("expected %q, but got %q", expected, buf.String())
+	}
+}
+
+func TestUnknownEngine(t *testing.T) {
+	if _, err := (&contentsParser{}).parse("nope", "table.tpl", []byte("")); err == nil {
+		t.Fatal("expected an error for an unknown template engine, but got nil")
+	}
+}
+
+func TestPartialsDir(t *testing.T) {
+	p, err := newContentsParser("testdata/partials", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := p.parse(engineText, "table.tpl", []byte(`{{range .Table.Columns}}{{template "column_field" .}} {{template "bang" .}}{{end}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := data.TableData{
+		Table: &data.Table{
+			Columns: data.Columns{
+				{Name: "user_id", Type: "int"},
+			},
+		},
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, contents); err != nil {
+		t.Fatal(err)
+	}
+	expected := "UserID int user_id!"
+	if buf.String() != expected {
+		t.Fatalf("expected %q, but got %q", expected, buf.String())
+	}
+
+	tmpl, err = p.parse(engineMustache, "table.tpl", []byte(`{{#Table.Columns}}{{> bang}}{{/Table.Columns}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := tmpl.Execute(buf, contents); err != nil {
+		t.Fatal(err)
+	}
+	expected = "user_id!"
+	if buf.String() != expected {
+		t.Fatalf("expected %q, but got %q", expected, buf.String())
+	}
+}
+
+func TestPongo2EngineErrorLocation(t *testing.T) {
+	tmpl, err := (&contentsParser{}).parse(enginePongo2, "table.tpl", []byte("line one\n{{ makeMap(\"odd\") }}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tmpl.Execute(&bytes.Buffer{}, data.TableData{Table: &data.Table{}})
+	if err == nil {
+		t.Fatal("expected an error, but got nil")
+	}
+	if !strings.HasPrefix(err.Error(), "template: table.tpl:2:") {
+		t.Fatalf("expected error to start with the template location, but got %q", err)
+	}
+}
+
+func TestPongo2EngineNoEscape(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "base.tpl"), []byte(`[{% block body %}{% endblock %}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "inc.tpl"), []byte(`{{ Params.x }}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := newContentsParser(dir, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := data.TableData{
+		Table:  &data.Table{},
+		Params: map[string]interface{}{"x": "<a&b>"},
+	}
+	for _, src := range []string{
+		`{{ Params.x }} {% include "inc.tpl" %}`,
+		`{% extends "base.tpl" %}{% block body %}{{ Params.x }} {% include "inc.tpl" %}{% endblock %}`,
+	} {
+		tmpl, err := p.parse(enginePongo2, "table.tpl", []byte(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := &bytes.Buffer{}
+		if err := tmpl.Execute(buf, contents); err != nil {
+			t.Fatal(err)
+		}
+		expected := "<a&b> <a&b>"
+		if strings.HasPrefix(src, "{% extends") {
+			expected = "[" + expected + "]"
+		}
+		if buf.String() != expected {
+			t.Errorf("%s: expected %q, but got %q", src, expected, buf.String())
+		}
+	}
+
+	// other users of pongo2 in the same binary still get autoescaping.
+	out, err := pongo2.RenderTemplateString(`{{ x }}`, pongo2.Context{"x": "<a&b>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "&lt;a&amp;b&gt;"; out != expected {
+		t.Fatalf("expected the pongo2 default to still escape to %q, but got %q", expected, out)
+	}
+}
@@ -0,0 +1,261 @@
+package cli
+
+import (
+	"embed"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// driverSample holds the parts of the sample config that depend on the
+// database driver.
+type driverSample struct {
+	// ConnStr is an example connection string in the driver's format.
+	ConnStr string
+
+	// Schemas is the list of schemas read by default.
+	Schemas string
+
+	// Types maps the driver's common types to the kind of value they hold,
+	// which each language maps to its own type.
+	Types []dbType
+}
+
+// dbType is a database type and the kind of value it holds.
+type dbType struct {
+	Name, Kind string
+}
+
+// driverSamples holds the sample config values for each driver that gnorm init
+// supports, keyed by DBType.
+var driverSamples = map[string]driverSample{
+	"postgres": {
+		ConnStr: "dbname=mydb host=127.0.0.1 sslmode=disable user=admin",
+		Schemas: `["public"]`,
+		Types: []dbType{
+			{"bigint", "int64"},
+			{"boolean", "bool"},
+			{"bytea", "bytes"},
+			{"character varying", "string"},
+			{"date", "date"},
+			{"double precision", "float64"},
+			{"integer", "int"},
+			{"jsonb", "json"},
+			{"numeric", "float64"},
+			{"smallint", "int16"},
+			{"text", "string"},
+			{"timestamp with time zone", "time"},
+			{"timestamp without time zone", "time"},
+			{"uuid", "uuid"},
+		},
+	},
+	"mysql": {
+		ConnStr: "admin:password@tcp(127.0.0.1:3306)/mydb?parseTime=true",
+		Schemas: `["mydb"]`,
+		Types: []dbType{
+			{"bigint", "int64"},
+			{"blob", "bytes"},
+			{"char", "string"},
+			{"date", "date"},
+			{"datetime", "time"},
+			{"decimal", "float64"},
+			{"double", "float64"},
+			{"enum", "string"},
+			{"float", "float32"},
+			{"int", "int"},
+			{"json", "json"},
+			{"smallint", "int16"},
+			{"text", "string"},
+			{"timestamp", "time"},
+			{"tinyint", "int8"},
+			{"varchar", "string"},
+		},
+	},
+}
+
+// langSample holds the parts of the sample config, and the templates, that
+// depend on the language the code is generated in.
+type langSample struct {
+	// Types and NullableTypes map each kind of value to the language's type
+	// for it.
+	Types         map[string]string
+	NullableTypes map[string]string
+
+	// PostRun, if set, is the toml array used as the PostRun command.
+	PostRun string
+
+	// TablePath, SchemaPath, and EnumPath are the output paths of the
+	// templates.
+	TablePath  string
+	SchemaPath string
+	EnumPath   string
+}
+
+// langSamples holds the starter kits for each language that gnorm init
+// supports.  Their templates are in the starters directory.
+var langSamples = map[string]langSample{
+	"go": {
+		Types: map[string]string{
+			"bool": "bool", "bytes": "[]byte", "date": "time.Time",
+			"float32": "float32", "float64": "float64", "int": "int",
+			"int8": "int8", "int16": "int16", "int64": "int64",
+			"json": "json.RawMessage", "string": "string", "time": "time.Time",
+			"uuid": "uuid.UUID",
+		},
+		NullableTypes: map[string]string{
+			"bool": "sql.NullBool", "bytes": "[]byte", "date": "sql.NullTime",
+			"float32": "sql.NullFloat64", "float64": "sql.NullFloat64",
+			"int": "sql.NullInt64", "int8": "sql.NullInt16", "int16": "sql.NullInt16",
+			"int64": "sql.NullInt64", "json": "json.RawMessage",
+			"string": "sql.NullString", "time": "sql.NullTime", "uuid": "uuid.NullUUID",
+		},
+		PostRun:    `["gofmt", "-w", "$GNORMFILE"]`,
+		TablePath:  "{{toLower .Schema}}/{{snake .Table}}.go",
+		SchemaPath: "{{toLower .Schema}}/doc.go",
+		EnumPath:   "{{toLower .Schema}}/{{snake .Enum}}_enum.go",
+	},
+	"typescript": {
+		Types: map[string]string{
+			"bool": "boolean", "bytes": "Uint8Array", "date": "Date",
+			"float32": "number", "float64": "number", "int": "number",
+			"int8": "number", "int16": "number", "int64": "number",
+			"json": "unknown", "string": "string", "time": "Date",
+			"uuid": "string",
+		},
+		TablePath:  "{{kebab .Schema}}/{{kebab .Table}}.ts",
+		SchemaPath: "{{kebab .Schema}}/index.ts",
+		EnumPath:   "{{kebab .Schema}}/{{kebab .Enum}}.enum.ts",
+	},
+	"python": {
+		Types: map[string]string{
+			"bool": "bool", "bytes": "bytes", "date": "datetime.date",
+			"float32": "float", "float64": "float", "int": "int",
+			"int8": "int", "int16": "int", "int64": "int",
+			"json": "Any", "string": "str", "time": "datetime.datetime",
+			"uuid": "uuid.UUID",
+		},
+		TablePath:  "{{snake .Schema}}/{{snake .Table}}.py",
+		SchemaPath: "{{snake .Schema}}/__init__.py",
+		EnumPath:   "{{snake .Schema}}/{{snake .Enum}}_enum.py",
+	},
+}
+
+// nullableType returns the type of nullable columns of the given kind in the
+// language.  Languages without nullable types of their own wrap the type.
+func (l langSample) nullableType(lang, kind string) string {
+	if t, ok := l.NullableTypes[kind]; ok {
+		return t
+	}
+	switch lang {
+	case "python":
+		return "Optional[" + l.Types[kind] + "]"
+	default:
+		return l.Types[kind] + " | null"
+	}
+}
+
+//go:embed starters
+var starters embed.FS
+
+// initDrivers returns the names of the drivers gnorm init supports.
+func initDrivers() []string {
+	names := make([]string, 0, len(driverSamples))
+	for name := range driverSamples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// initLangs returns the names of the languages gnorm init has starter kits for.
+func initLangs() []string {
+	names := make([]string, 0, len(langSamples))
+	for name := range langSamples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var (
+	// connStrLines matches the ConnStr examples and value.
+	connStrLines = regexp.MustCompile(`(?m)^# MySQL example:\n# ConnStr = .*\n# Postgres example:\nConnStr = .*$`)
+	dbTypeLine   = regexp.MustCompile(`(?m)^DBType = .*$`)
+	schemasLine  = regexp.MustCompile(`(?m)^Schemas = .*$`)
+	exampleLine  = regexp.MustCompile(`(?m)^# Example for mapping postgres types to Go types:$`)
+	typeMap      = regexp.MustCompile(`(?m)^\[TypeMap\]\n(?:[^\n]+\n)*`)
+	nullableMap  = regexp.MustCompile(`(?m)^\[NullableTypeMap\]\n(?:[^\n]+\n)*`)
+	nameConvLine = regexp.MustCompile(`(?m)^NameConversion = .*$`)
+	postRunLine  = regexp.MustCompile(`(?m)^PostRun = .*$`)
+	tablePaths   = regexp.MustCompile(`(?m)^\[TablePaths\]\n(?:[^\n]+\n)*`)
+	schemaPaths  = regexp.MustCompile(`(?m)^\[SchemaPaths\]\n(?:[^\n]+\n)*`)
+	enumPaths    = regexp.MustCompile(`(?m)^\[EnumPaths\]\n(?:[^\n]+\n)*`)
+)
+
+// sampleConfig returns the sample gnorm.toml for the given driver and
+// language.  The connection string, schemas, and type maps are set to values
+// that fit the driver, with types from the language.  If a language is given,
+// the output paths point to its starter templates.  If neither is given, the
+// generic sample is returned.  Otherwise the driver defaults to postgres and
+// the language to go.
+func sampleConfig(driver, lang string) (string, error) {
+	if driver == "" && lang == "" {
+		return sample, nil
+	}
+	driver, lang = strings.ToLower(driver), strings.ToLower(lang)
+	if driver == "" {
+		driver = "postgres"
+	}
+	d, ok := driverSamples[driver]
+	if !ok {
+		return "", errors.Errorf("unknown driver %q, expected one of %s", driver, strings.Join(initDrivers(), ", "))
+	}
+	typesLang := lang
+	if typesLang == "" {
+		typesLang = "go"
+	}
+	l, ok := langSamples[typesLang]
+	if !ok {
+		return "", errors.Errorf("unknown language %q, expected one of %s", lang, strings.Join(initLangs(), ", "))
+	}
+	var types, nullable []string
+	for _, t := range d.Types {
+		types = append(types, quote(t.Name)+" = "+quote(l.Types[t.Kind]))
+		nullable = append(nullable, quote(t.Name)+" = "+quote(l.nullableType(typesLang, t.Kind)))
+	}
+	s := sample
+	s = connStrLines.ReplaceAllLiteralString(s, "# "+driver+" example:\nConnStr = "+quote(d.ConnStr))
+	s = dbTypeLine.ReplaceAllLiteralString(s, "DBType = "+quote(driver))
+	s = schemasLine.ReplaceAllLiteralString(s, "Schemas = "+d.Schemas)
+	s = exampleLine.ReplaceAllLiteralString(s, "# Mapping of "+driver+" types to "+typesLang+" types:")
+	s = typeMap.ReplaceAllLiteralString(s, "[TypeMap]\n"+strings.Join(types, "\n")+"\n")
+	s = nullableMap.ReplaceAllLiteralString(s, "[NullableTypeMap]\n"+strings.Join(nullable, "\n")+"\n")
+	if lang == "" {
+		return s, nil
+	}
+	s = nameConvLine.ReplaceAllLiteralString(s, `NameConversion = "{{pascal .}}"`)
+	if l.PostRun != "" {
+		s = postRunLine.ReplaceAllLiteralString(s, "PostRun = "+l.PostRun)
+	}
+	s = tablePaths.ReplaceAllLiteralString(s, "[TablePaths]\n"+quote(l.TablePath)+` = "templates/table.gotmpl"`+"\n")
+	s = schemaPaths.ReplaceAllLiteralString(s, "[SchemaPaths]\n"+quote(l.SchemaPath)+` = "templates/schema.gotmpl"`+"\n")
+	s = enumPaths.ReplaceAllLiteralString(s, "[EnumPaths]\n"+quote(l.EnumPath)+` = "templates/enum.gotmpl"`+"\n")
+	return s, nil
+}
+
+// starterTemplate returns the contents of the named template of the starter
+// kit for lang.
+func starterTemplate(lang, name string) (string, error) {
+	b, err := starters.ReadFile("starters/" + strings.ToLower(lang) + "/" + name)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return string(b), nil
+}
+
+// quote returns s as a toml basic string.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
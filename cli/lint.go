@@ -0,0 +1,266 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"text/template"
+	"text/template/parse"
+
+	"gnorm.org/gnorm/run"
+	"gnorm.org/gnorm/run/data"
+)
+
+// lint checks the parsed config for problems that would otherwise only show up
+// while generating code.  It checks that every field referenced in a go
+// contents template exists on the data that template will receive, and that
+// every template that is called is defined.  It returns a list of the problems
+// found, each prefixed with the location of the problem.
+func lint(cfg *run.Config) []string {
+	var problems []string
+	if cfg.StaticDir != "" {
+		if _, err := os.Stat(cfg.StaticDir); err != nil {
+			problems = append(problems, fmt.Sprintf("StaticDir: %v", err))
+		}
+	}
+	for _, d := range cfg.StaticDirs {
+		if _, err := os.Stat(d.Dir); err != nil {
+			problems = append(problems, fmt.Sprintf("StaticDirs: %v", err))
+		}
+	}
+	targets := []struct {
+		targets []run.OutputTarget
+		data    interface{}
+	}{
+		{cfg.SchemaPaths, data.SchemaData{}},
+		{cfg.TablePaths, data.TableData{}},
+		{cfg.EnumPaths, data.EnumData{}},
+		{cfg.DBPaths, data.DatabaseData{}},
+	}
+	funcs := cfg.FuncMap()
+	for _, t := range targets {
+		for _, target := range t.targets {
+			// only go templates can be checked, other engines are checked as much
+			// as they can be when they're parsed.
+			tmpl, ok := target.Contents.(*template.Template)
+			if !ok {
+				continue
+			}
+			c := &typeChecker{
+				tmpl:    tmpl,
+				funcs:   funcs,
+				visited: map[string]bool{},
+			}
+			typ := reflect.TypeOf(t.data)
+			c.walk(tmpl.Tree.Root, typ, map[string]reflect.Type{"$": typ})
+			problems = append(problems, c.problems...)
+		}
+	}
+	return problems
+}
+
+// typeChecker walks a go template, tracking the type of dot, and records any
+// references to fields that don't exist on that type.  Values whose type can't
+// be known until the template is executed (e.g. interface values) are not
+// checked.
+type typeChecker struct {
+	tmpl     *template.Template
+	funcs    template.FuncMap
+	visited  map[string]bool
+	problems []string
+}
+
+func (c *typeChecker) problem(node parse.Node, format string, args ...interface{}) {
+	loc, _ := c.tmpl.ErrorContext(node)
+	c.problems = append(c.problems, loc+": "+fmt.Sprintf(format, args...))
+}
+
+// walk checks node with the given type of dot and variables.
+func (c *typeChecker) walk(node parse.Node, dot reflect.Type, vars map[string]reflect.Type) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			c.walk(child, dot, vars)
+		}
+	case *parse.ActionNode:
+		c.pipe(n.Pipe, dot, vars)
+	case *parse.IfNode:
+		c.branch(&n.BranchNode, dot, vars, false)
+	case *parse.WithNode:
+		c.branch(&n.BranchNode, dot, vars, false)
+	case *parse.RangeNode:
+		c.branch(&n.BranchNode, dot, vars, true)
+	case *parse.TemplateNode:
+		var typ reflect.Type
+		if n.Pipe != nil {
+			typ = c.pipe(n.Pipe, dot, vars)
+		}
+		t := c.tmpl.Lookup(n.Name)
+		if t == nil || t.Tree == nil {
+			c.problem(n, "no such template %q", n.Name)
+			return
+		}
+		// check the template with each type it's called with, but only once, so
+		// recursive templates don't loop forever.
+		key := fmt.Sprintf("%s %v", n.Name, typ)
+		if c.visited[key] {
+			return
+		}
+		c.visited[key] = true
+		c.walk(t.Tree.Root, typ, map[string]reflect.Type{"$": typ})
+	}
+}
+
+// branch checks an if, with, or range node.
+func (c *typeChecker) branch(n *parse.BranchNode, dot reflect.Type, vars map[string]reflect.Type, isRange bool) {
+	scope := copyVars(vars)
+	typ := c.pipe(n.Pipe, dot, scope)
+	inner := dot
+	switch {
+	case isRange:
+		key, elem := rangeTypes(typ)
+		inner = elem
+		switch len(n.Pipe.Decl) {
+		case 1:
+			scope[n.Pipe.Decl[0].Ident[0]] = elem
+		case 2:
+			scope[n.Pipe.Decl[0].Ident[0]] = key
+			scope[n.Pipe.Decl[1].Ident[0]] = elem
+		}
+	case n.NodeType == parse.NodeWith:
+		inner = typ
+	}
+	c.walk(n.List, inner, scope)
+	c.walk(n.ElseList, dot, copyVars(vars))
+}
+
+// pipe checks a pipeline and returns the type it evaluates to, or nil if it
+// can't be known.
+func (c *typeChecker) pipe(p *parse.PipeNode, dot reflect.Type, vars map[string]reflect.Type) reflect.Type {
+	var typ reflect.Type
+	for _, cmd := range p.Cmds {
+		typ = c.command(cmd, dot, vars)
+	}
+	for _, v := range p.Decl {
+		vars[v.Ident[0]] = typ
+	}
+	return typ
+}
+
+// command checks a command and returns the type it evaluates to, or nil if it
+// can't be known.
+func (c *typeChecker) command(cmd *parse.CommandNode, dot reflect.Type, vars map[string]reflect.Type) reflect.Type {
+	for _, arg := range cmd.Args[1:] {
+		c.arg(arg, dot, vars)
+	}
+	if id, ok := cmd.Args[0].(*parse.IdentifierNode); ok {
+		if f, ok := c.funcs[id.Ident]; ok {
+			if t := reflect.TypeOf(f); t.Kind() == reflect.Func && t.NumOut() > 0 {
+				return t.Out(0)
+			}
+		}
+		return nil
+	}
+	return c.arg(cmd.Args[0], dot, vars)
+}
+
+// arg checks a single argument and returns its type, or nil if it can't be
+// known.
+func (c *typeChecker) arg(node parse.Node, dot reflect.Type, vars map[string]reflect.Type) reflect.Type {
+	switch n := node.(type) {
+	case *parse.DotNode:
+		return dot
+	case *parse.FieldNode:
+		return c.fields(n, dot, n.Ident)
+	case *parse.VariableNode:
+		return c.fields(n, vars[n.Ident[0]], n.Ident[1:])
+	case *parse.ChainNode:
+		return c.fields(n, c.arg(n.Node, dot, vars), n.Field)
+	case *parse.PipeNode:
+		return c.pipe(n, dot, copyVars(vars))
+	case *parse.StringNode:
+		return reflect.TypeOf("")
+	case *parse.BoolNode:
+		return reflect.TypeOf(true)
+	}
+	return nil
+}
+
+// fields resolves the chain of field names starting at typ, recording a problem
+// for any field that doesn't exist.
+func (c *typeChecker) fields(node parse.Node, typ reflect.Type, names []string) reflect.Type {
+	for _, name := range names {
+		if typ == nil || typ.Kind() == reflect.Interface {
+			return nil
+		}
+		if m, ok := typ.MethodByName(name); ok {
+			typ = methodResult(m)
+			continue
+		}
+		if typ.Kind() != reflect.Ptr {
+			if m, ok := reflect.PtrTo(typ).MethodByName(name); ok {
+				typ = methodResult(m)
+				continue
+			}
+		}
+		base := typ
+		if base.Kind() == reflect.Ptr {
+			base = base.Elem()
+		}
+		switch base.Kind() {
+		case reflect.Struct:
+			f, ok := base.FieldByName(name)
+			if !ok || f.PkgPath != "" {
+				c.problem(node, "can't evaluate field %s in type %v", name, typ)
+				return nil
+			}
+			typ = f.Type
+		case reflect.Map:
+			if base.Key().Kind() != reflect.String {
+				return nil
+			}
+			typ = base.Elem()
+		default:
+			c.problem(node, "can't evaluate field %s in type %v", name, typ)
+			return nil
+		}
+	}
+	return typ
+}
+
+// methodResult returns the type of the first value returned by the method, or
+// nil if it returns nothing.
+func methodResult(m reflect.Method) reflect.Type {
+	if m.Type.NumOut() == 0 {
+		return nil
+	}
+	return m.Type.Out(0)
+}
+
+// rangeTypes returns the key and element types of ranging over a value of type
+// typ, or nils if they can't be known.
+func rangeTypes(typ reflect.Type) (key, elem reflect.Type) {
+	if typ == nil {
+		return nil, nil
+	}
+	switch typ.Kind() {
+	case reflect.Slice, reflect.Array:
+		return reflect.TypeOf(0), typ.Elem()
+	case reflect.Map:
+		return typ.Key(), typ.Elem()
+	case reflect.Chan:
+		return typ.Elem(), typ.Elem()
+	}
+	return nil, nil
+}
+
+func copyVars(vars map[string]reflect.Type) map[string]reflect.Type {
+	m := make(map[string]reflect.Type, len(vars))
+	for k, v := range vars {
+		m[k] = v
+	}
+	return m
+}
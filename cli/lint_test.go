@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+	"text/template"
+
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run"
+)
+
+func TestLint(t *testing.T) {
+	parse := func(name, s string) run.Template {
+		return template.Must(template.New(name).Funcs(environ.FuncMap).Parse(s))
+	}
+	cfg := &run.Config{
+		TablePaths: []run.OutputTarget{{
+			Contents: parse("table.tpl", `{{.Table.Name}} {{.Table.Nope}}
+{{range $i, $c := .Table.Columns}}{{$c.DBName}}{{$c.Bad}}{{end}}
+{{range .Table.PrimaryKeys}}{{.Name}}{{end}}{{.Table.Columns.Names.Sorted}}
+{{with .Table.Schema}}{{.Name}}{{.Missing}}{{end}}
+{{(pascal .Table.Name).Length}}{{.Params.anything.goes}}{{.Table.ColumnsByName.id.Type}}
+{{define "sub"}}{{.Oops}}{{end}}{{template "sub" .Table}}{{template "missing"}}`),
+		}},
+		EnumPaths: []run.OutputTarget{{
+			Contents: parse("enum.tpl", `{{range .Enum.Values}}{{.Name}}{{end}}{{$.Table}}`),
+		}},
+	}
+	expected := []string{
+		"table.tpl:1:24: can't evaluate field Nope in type *data.Table",
+		"table.tpl:2:51: can't evaluate field Bad in type *data.Column",
+		"table.tpl:4:33: can't evaluate field Missing in type *data.Schema",
+		"table.tpl:5:22: can't evaluate field Length in type string",
+		"table.tpl:6:18: can't evaluate field Oops in type *data.Table",
+		"table.tpl:6:68: no such template \"missing\"",
+		"enum.tpl:1:41: can't evaluate field Table in type data.EnumData",
+	}
+	if problems := lint(cfg); !reflect.DeepEqual(problems, expected) {
+		t.Fatalf("expected problems:\n%q\ngot:\n%q", expected, problems)
+	}
+}
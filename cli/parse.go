@@ -1,52 +1,227 @@
 package cli // import "gnorm.org/gnorm/cli"
 
 import (
+	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
 
 	"gnorm.org/gnorm/database"
-	"gnorm.org/gnorm/database/drivers/mysql"
-	"gnorm.org/gnorm/database/drivers/postgres"
+	"gnorm.org/gnorm/database/drivers"
+	"gnorm.org/gnorm/database/drivers/plugin"
+	// the built-in drivers register themselves.
+	_ "gnorm.org/gnorm/database/drivers/dbml"
+	_ "gnorm.org/gnorm/database/drivers/ddl"
+	_ "gnorm.org/gnorm/database/drivers/mysql"
+	_ "gnorm.org/gnorm/database/drivers/postgres"
+	_ "gnorm.org/gnorm/database/drivers/prisma"
 	"gnorm.org/gnorm/environ"
 	"gnorm.org/gnorm/run"
 	"gnorm.org/gnorm/run/data"
 )
 
-func parseFile(env environ.Values, file string) (*run.Config, error) {
-	f, err := os.Open(file)
+// Config file formats.
+const (
+	formatTOML = "toml"
+	formatYAML = "yaml"
+	formatJSON = "json"
+)
+
+// parseFile reads the config file, which may be toml, yaml, or json.  If
+// profile is not empty, the values of that profile override the values in the
+// rest of the file.
+func parseFile(env environ.Values, file, profile string) (*run.Config, error) {
+	c, err := readConfigFile(env, file, profile)
 	if err != nil {
-		return nil, errors.WithMessage(err, "can't open config file")
+		return nil, err
 	}
-	defer f.Close()
-	return Parse(env, f)
+	return parseConfig(env, c)
 }
 
-// Parse reads the configuration file and returns a gnorm config value.
-func Parse(env environ.Values, r io.Reader) (*run.Config, error) {
+// readConfigFile decodes the config file and applies the profile, without
+// parsing any of the templates in it.
+func readConfigFile(env environ.Values, file, profile string) (Config, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return Config{}, errors.WithMessage(err, "can't open config file")
+	}
 	c := Config{}
-	m, err := toml.DecodeReader(r, &c)
+	seen := map[string]bool{filepath.Clean(file): true}
+	if err := decodeConfig(env, &c, b, configFormat(file), filepath.Dir(file), seen); err != nil {
+		return Config{}, err
+	}
+	if profile != "" {
+		p, ok := c.Profiles[profile]
+		if !ok {
+			return Config{}, errors.Errorf("no profile %q in config file", profile)
+		}
+		p.apply(&c)
+	}
+	return c, nil
+}
+
+// Parse reads the TOML configuration file and returns a gnorm config value.
+// The given options are applied to the config before any templates are parsed.
+func Parse(env environ.Values, r io.Reader, opts ...run.Option) (*run.Config, error) {
+	return parseReader(env, r, formatTOML, opts)
+}
+
+// ParseJSON is like Parse, but reads a JSON configuration file.  Keys are the
+// same as in the TOML file.
+func ParseJSON(env environ.Values, r io.Reader, opts ...run.Option) (*run.Config, error) {
+	return parseReader(env, r, formatJSON, opts)
+}
+
+// ParseYAML is like Parse, but reads a YAML configuration file.  Keys are the
+// same as in the TOML file.
+func ParseYAML(env environ.Values, r io.Reader, opts ...run.Option) (*run.Config, error) {
+	return parseReader(env, r, formatYAML, opts)
+}
+
+func parseReader(env environ.Values, r io.Reader, format string, opts []run.Option) (*run.Config, error) {
+	b, err := ioutil.ReadAll(r)
 	if err != nil {
-		return nil, errors.WithMessage(err, "error parsing config file")
+		return nil, errors.WithMessage(err, "error reading config file")
 	}
-	undec := m.Undecoded()
-	if len(undec) > 0 {
-		log.Println("Warning: unknown values present in config file:", undec)
+	c := Config{}
+	if err := decodeConfig(env, &c, b, format, ".", map[string]bool{}); err != nil {
+		return nil, err
+	}
+	return parseConfig(env, c, opts...)
+}
+
+// configFormat returns the format of the config file at path, based on its
+// extension.  Files that aren't yaml or json are assumed to be toml.
+func configFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return formatYAML
+	case ".json":
+		return formatJSON
+	}
+	return formatTOML
+}
+
+// decodeConfig decodes the config file contents b into c.  If the config
+// Extends another config file, that file is decoded into c first, so that the
+// values in b override the values in it.  A relative Extends path is relative
+// to dir, the directory of the config file.  seen holds the config files
+// already being decoded, to catch files that extend themselves.
+func decodeConfig(env environ.Values, c *Config, b []byte, format, dir string, seen map[string]bool) error {
+	var ext struct{ Extends string }
+	if err := decode(env, b, format, &ext); err != nil {
+		return err
 	}
+	if ext.Extends != "" {
+		path := ext.Extends
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		if seen[path] {
+			return errors.Errorf("config file %s extends itself", path)
+		}
+		seen[path] = true
+		base, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.WithMessage(err, "can't open config file "+path)
+		}
+		if err := decodeConfig(env, c, base, configFormat(path), filepath.Dir(path), seen); err != nil {
+			return errors.WithMessage(err, "error in config file "+path)
+		}
+	}
+	return decode(env, b, format, c)
+}
 
-	if len(c.Schemas) == 0 {
+// decode decodes the config file contents b in the given format into v.  Maps
+// in v are merged with the decoded values, other values are replaced.
+func decode(env environ.Values, b []byte, format string, v interface{}) error {
+	switch format {
+	case formatJSON:
+		if err := json.Unmarshal(b, v); err != nil {
+			return errors.WithMessage(err, "error parsing config file")
+		}
+	case formatYAML:
+		var y interface{}
+		if err := yaml.Unmarshal(b, &y); err != nil {
+			return errors.WithMessage(err, "error parsing config file")
+		}
+		// yaml decodes struct fields by their lowercased names, so go through
+		// json, which matches keys to fields regardless of case, like toml does.
+		j, err := json.Marshal(jsonValue(y))
+		if err != nil {
+			return errors.WithMessage(err, "error parsing config file")
+		}
+		if err := json.Unmarshal(j, v); err != nil {
+			return errors.WithMessage(err, "error parsing config file")
+		}
+	default:
+		m, err := toml.Decode(string(b), v)
+		if err != nil {
+			return errors.WithMessage(err, "error parsing config file")
+		}
+		if _, ok := v.(*Config); ok {
+			if undec := m.Undecoded(); len(undec) > 0 {
+				env.Logger().Warnf("unknown values present in config file: %v", undec)
+			}
+		}
+	}
+	return nil
+}
+
+// jsonValue converts the maps in a value decoded from yaml, which have
+// interface{} keys, into maps with string keys that can be encoded as json.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[fmt.Sprint(k)] = jsonValue(val)
+		}
+		return m
+	case []interface{}:
+		for x := range v {
+			v[x] = jsonValue(v[x])
+		}
+	}
+	return v
+}
+
+// parseConfig validates the decoded config file and converts it into a gnorm
+// config value.
+func parseConfig(env environ.Values, c Config, opts ...run.Option) (*run.Config, error) {
+	expandVars(env, &c)
+	schemas := c.Schemas
+	if len(c.Database) > 0 {
+		var err error
+		schemas, err = databaseSchemas(c)
+		if err != nil {
+			return nil, err
+		}
+	} else if len(c.Schemas) == 0 {
 		return nil, errors.New("no schemas specified in config")
 	}
 
-	if c.NameConversion == "" {
+	if c.NameConversion == "" && len(c.NameConversionCommand) == 0 {
 		return nil, errors.New("no NameConversion specified in config")
 	}
+	if c.NameConversion != "" && len(c.NameConversionCommand) > 0 {
+		return nil, errors.New("both NameConversion and NameConversionCommand specified in config")
+	}
+	switch c.NameConversionFormat {
+	case "", run.NameFormatLines, run.NameFormatJSON:
+	default:
+		return nil, errors.Errorf("unknown NameConversionFormat %q, expected %q or %q", c.NameConversionFormat, run.NameFormatLines, run.NameFormatJSON)
+	}
 	if len(c.ExcludeTables) > 0 && len(c.IncludeTables) > 0 {
 		return nil, errors.New("both include tables and exclude tables")
 	}
@@ -54,12 +229,12 @@ func Parse(env environ.Values, r io.Reader) (*run.Config, error) {
 		c.OutputDir = "."
 	}
 
-	include, err := parseTables(c.IncludeTables, c.Schemas)
+	include, err := parseTables(c.IncludeTables, schemas)
 	if err != nil {
 		return nil, err
 	}
 
-	exclude, err := parseTables(c.ExcludeTables, c.Schemas)
+	exclude, err := parseTables(c.ExcludeTables, schemas)
 	if err != nil {
 		return nil, err
 	}
@@ -79,25 +254,89 @@ func Parse(env environ.Values, r io.Reader) (*run.Config, error) {
 			PluginDirs:       c.PluginDirs,
 			NoOverwriteGlobs: c.NoOverwriteGlobs,
 		},
-		Params: c.Params,
+		Params:                c.Params,
+		NameConversionCommand: c.NameConversionCommand,
+		NameConversionFormat:  c.NameConversionFormat,
+		LuaScript:             c.LuaScript,
+		DataHook:              c.DataHook,
+		Queries:               c.Queries,
+		ExcludeColumns:        c.ExcludeColumns,
+		SchemaTypeMaps:        schemaTypeMaps(c.Schema),
+		ColumnTypes:           c.ColumnTypes,
+		HeaderTimestamp:       c.HeaderTimestamp,
+		Version:               version,
 	}
-	d, err := getDriver(strings.ToLower(c.DBType))
-	if err != nil {
-		return nil, err
+	if c.IncludeExpr != "" {
+		cfg.IncludeExpr, err = run.ParseTableExpr(c.IncludeExpr)
+		if err != nil {
+			return nil, errors.WithMessage(err, "error parsing IncludeExpr")
+		}
+	}
+	if c.ExcludeExpr != "" {
+		cfg.ExcludeExpr, err = run.ParseTableExpr(c.ExcludeExpr)
+		if err != nil {
+			return nil, errors.WithMessage(err, "error parsing ExcludeExpr")
+		}
+	}
+	if len(c.Database) == 0 {
+		d, err := getDriver(c.DBType)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Driver = d
+	}
+
+	// the wasm function goes in before the options, so that programs can
+	// replace it.
+	wasm := environ.Wasm(c.WasmPlugins)
+	run.WithFuncs(template.FuncMap{"wasm": wasm.Call})(cfg)
+	run.WithRunHook(wasm.Start)(cfg)
+
+	for _, opt := range opts {
+		opt(cfg)
 	}
-	cfg.Driver = d
 
 	environ.FuncMap["plugin"] = environ.Plugin(c.PluginDirs)
+	funcs := cfg.FuncMap()
 
-	t, err := template.New("NameConversion").Funcs(environ.FuncMap).Parse(c.NameConversion)
-	if err != nil {
-		return nil, errors.WithMessage(err, "error parsing NameConversion template")
+	if c.NameConversion != "" {
+		t, err := template.New("NameConversion").Funcs(funcs).Parse(c.NameConversion)
+		if err != nil {
+			return nil, errors.WithMessage(err, "error parsing NameConversion template")
+		}
+		cfg.NameConversion = t
+	}
+
+	if c.NullableWrapper != "" {
+		t, err := template.New("NullableWrapper").Funcs(funcs).Parse(c.NullableWrapper)
+		if err != nil {
+			return nil, errors.WithMessage(err, "error parsing NullableWrapper template")
+		}
+		cfg.NullableWrapper = t
+	}
+
+	if c.Header != "" {
+		t, err := template.New("Header").Funcs(funcs).Parse(c.Header)
+		if err != nil {
+			return nil, errors.WithMessage(err, "error parsing Header template")
+		}
+		cfg.Header = t
+	}
+	if c.LicenseHeaderFile != "" {
+		b, err := ioutil.ReadFile(c.LicenseHeaderFile)
+		if err != nil {
+			return nil, errors.WithMessage(err, "can't read LicenseHeaderFile")
+		}
+		cfg.License = string(b)
+	}
+
+	if c.TemplateEngine.Name != "" && len(c.TemplateEngine.CommandLine) != 0 {
+		return nil, errors.New("both TemplateEngine Name and TemplateEngine CommandLine specified in config")
 	}
-	cfg.NameConversion = t
 
 	if len(c.TemplateEngine.CommandLine) != 0 {
 		for _, s := range c.TemplateEngine.CommandLine {
-			t, err = template.New("EngineCLI").Funcs(environ.FuncMap).Parse(s)
+			t, err := template.New("EngineCLI").Funcs(funcs).Parse(s)
 			if err != nil {
 				return nil, errors.WithMessage(err, "error parsing TemplateEngine CLI template")
 			}
@@ -107,41 +346,336 @@ func Parse(env environ.Values, r io.Reader) (*run.Config, error) {
 		cfg.TemplateEngine.UseStdout = c.TemplateEngine.UseStdout
 	}
 
-	useEngine := len(c.TemplateEngine.CommandLine) != 0
-	cfg.SchemaPaths, err = parseOutputTargets(c.SchemaPaths, useEngine)
+	parser, err := newContentsParser(c.PartialsDir, funcs, cfg.FS)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.SchemaPaths, err = parseOutputTargets(c.SchemaPaths, c.Params, c.SchemaOptions, targetEngine(c, c.SchemaOptions), parser)
 	if err != nil {
 		return nil, errors.WithMessage(err, "error parsing SchemaPaths")
 	}
+	schemaTemplates, err := parseTemplateTargets(c.SchemaTemplates, c, c.SchemaOptions, parser)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error parsing SchemaTemplates")
+	}
+	cfg.SchemaPaths = append(cfg.SchemaPaths, schemaTemplates...)
 
-	cfg.TablePaths, err = parseOutputTargets(c.TablePaths, useEngine)
+	cfg.TablePaths, err = parseOutputTargets(c.TablePaths, c.Params, c.TableOptions, targetEngine(c, c.TableOptions), parser)
 	if err != nil {
 		return nil, errors.WithMessage(err, "error parsing TablePaths")
 	}
+	tableTemplates, err := parseTemplateTargets(c.TableTemplates, c, c.TableOptions, parser)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error parsing TableTemplates")
+	}
+	cfg.TablePaths = append(cfg.TablePaths, tableTemplates...)
 
-	cfg.EnumPaths, err = parseOutputTargets(c.EnumPaths, useEngine)
+	cfg.EnumPaths, err = parseOutputTargets(c.EnumPaths, c.Params, c.EnumOptions, targetEngine(c, c.EnumOptions), parser)
 	if err != nil {
 		return nil, errors.WithMessage(err, "error parsing EnumPaths")
 	}
+	enumTemplates, err := parseTemplateTargets(c.EnumTemplates, c, c.EnumOptions, parser)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error parsing EnumTemplates")
+	}
+	cfg.EnumPaths = append(cfg.EnumPaths, enumTemplates...)
+
+	cfg.DBPaths, err = parseOutputTargets(c.DBPaths, c.Params, c.DBOptions, targetEngine(c, c.DBOptions), parser)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error parsing DBPaths")
+	}
+	dbTemplates, err := parseTemplateTargets(c.DBTemplates, c, c.DBOptions, parser)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error parsing DBTemplates")
+	}
+	cfg.DBPaths = append(cfg.DBPaths, dbTemplates...)
+
+	cfg.StaticTemplates = map[string]run.Template{}
+	if c.StaticTemplates {
+		if c.StaticDir == "" {
+			return nil, errors.New("StaticTemplates is set, but there's no StaticDir")
+		}
+		if err := parseStaticTemplates(cfg.StaticTemplates, run.StaticDir{Dir: c.StaticDir}, targetEngine(c, TargetOptions{}), parser); err != nil {
+			return nil, errors.WithMessage(err, "error parsing StaticTemplates")
+		}
+	}
+	for x, d := range c.StaticDirs {
+		if d.Dir == "" {
+			return nil, errors.Errorf("StaticDirs entry %d has no Dir", x+1)
+		}
+		dest := filepath.Clean(d.Dest)
+		if dest == "." {
+			dest = ""
+		}
+		if filepath.IsAbs(dest) || dest == ".." || strings.HasPrefix(dest, ".."+string(filepath.Separator)) {
+			return nil, errors.Errorf("StaticDirs entry %d has Dest %q outside the OutputDir", x+1, d.Dest)
+		}
+		for _, glob := range append(append([]string{}, d.Include...), d.Exclude...) {
+			if _, err := filepath.Match(glob, ""); err != nil {
+				return nil, errors.Errorf("StaticDirs entry %d has invalid glob %q", x+1, glob)
+			}
+		}
+		dir := run.StaticDir{
+			Dir:     d.Dir,
+			Dest:    dest,
+			Include: d.Include,
+			Exclude: d.Exclude,
+		}
+		if d.Templates {
+			if err := parseStaticTemplates(cfg.StaticTemplates, dir, targetEngine(c, TargetOptions{}), parser); err != nil {
+				return nil, errors.WithMessage(err, "error parsing templates in "+d.Dir)
+			}
+		}
+		cfg.StaticDirs = append(cfg.StaticDirs, dir)
+	}
 
-	if len(cfg.EnumPaths) == 0 && len(cfg.TablePaths) == 0 && len(cfg.SchemaPaths) == 0 {
+	if len(cfg.EnumPaths) == 0 && len(cfg.TablePaths) == 0 && len(cfg.SchemaPaths) == 0 && len(cfg.DBPaths) == 0 {
 		return nil, errors.New("no output paths defined, so no output will be generated")
 	}
 
-	cfg.ConnStr = os.Expand(c.ConnStr, func(s string) string {
+	connStr := c.ConnStr
+	if c.ConnStrFile != "" {
+		if connStr != "" {
+			return nil, errors.New("both ConnStr and ConnStrFile specified in config")
+		}
+		b, err := ioutil.ReadFile(c.ConnStrFile)
+		if err != nil {
+			return nil, errors.WithMessage(err, "can't read ConnStrFile")
+		}
+		connStr = strings.TrimSpace(string(b))
+	}
+	var password string
+	if c.Password.isSet() {
+		password, err = c.Password.lookup()
+		if err != nil {
+			return nil, err
+		}
+	}
+	cfg.ConnStr, err = expandConnStr(env, c, connStr, password)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range c.Database {
+		db := run.Database{Name: d.Name, Schemas: d.Schemas}
+		db.Driver, err = getDriver(d.DBType)
+		if err != nil {
+			return nil, errors.WithMessage(err, "Database "+d.Name)
+		}
+		db.ConnStr, err = expandConnStr(env, c, d.ConnStr, password)
+		if err != nil {
+			return nil, errors.WithMessage(err, "Database "+d.Name)
+		}
+		cfg.Databases = append(cfg.Databases, db)
+	}
+	if c.SSHTunnel != nil {
+		t := run.SSHTunnel(*c.SSHTunnel)
+		cfg.SSHTunnel = &t
+	}
+	if c.ConnectTimeout != "" {
+		cfg.ConnectTimeout, err = time.ParseDuration(c.ConnectTimeout)
+		if err != nil {
+			return nil, errors.WithMessage(err, "invalid ConnectTimeout")
+		}
+	}
+	if c.QueryTimeout != "" {
+		cfg.QueryTimeout, err = time.ParseDuration(c.QueryTimeout)
+		if err != nil {
+			return nil, errors.WithMessage(err, "invalid QueryTimeout")
+		}
+	}
+	if c.Retries < 0 {
+		return nil, errors.New("Retries must not be negative")
+	}
+	cfg.Retries = c.Retries
+	cfg.ReadOnly = c.ReadOnly
+	cfg.Overlays = c.Overlays
+	if c.PostRunWorkers < 0 {
+		return nil, errors.New("PostRunWorkers must not be negative")
+	}
+	cfg.PostRunWorkers = c.PostRunWorkers
+	cfg.PostRunWarnOnly = c.PostRunWarnOnly
+	if cfg.FileMode, err = parseMode("FileMode", c.FileMode); err != nil {
+		return nil, err
+	}
+	if cfg.DirMode, err = parseMode("DirMode", c.DirMode); err != nil {
+		return nil, err
+	}
+	cfg.AllowUnsafeFilenames = c.AllowUnsafeFilenames
+	if c.TLS != nil {
+		t := database.TLS(*c.TLS)
+		cfg.TLS = &t
+	}
+	return cfg, nil
+}
+
+// expandConnStr expands the variables in connStr, and reads the connection
+// string from a secret store if connStr refers to a secret.
+func expandConnStr(env environ.Values, c Config, connStr, password string) (string, error) {
+	connStr = os.Expand(connStr, func(s string) string {
+		if s == passwordVar && c.Password.isSet() {
+			return password
+		}
+		if s == run.TunnelVar && c.SSHTunnel != nil {
+			// replaced with the tunnel's address when the tunnel is opened.
+			return "$" + run.TunnelVar
+		}
 		return env.Env[s]
 	})
-	return cfg, nil
+	if !isSecretRef(connStr) {
+		return connStr, nil
+	}
+	connStr, err := resolveSecret(env.Env, connStr)
+	if err != nil {
+		return "", errors.WithMessage(err, "error reading ConnStr secret")
+	}
+	return connStr, nil
 }
 
+// expandVars expands the environment variables in the paths in c and in the
+// string values of its Params, so one config file can be used on different
+// machines.  $$ is a literal $.  ConnStr is expanded separately, since it has
+// its own special variables, and PostRun is expanded when it's run, since it
+// may use $GNORMFILE.
+func expandVars(env environ.Values, c *Config) {
+	expand := func(s string) string {
+		return os.Expand(s, func(name string) string {
+			if name == "$" {
+				return "$"
+			}
+			return env.Env[name]
+		})
+	}
+	c.ConnStrFile = expand(c.ConnStrFile)
+	c.OutputDir = expand(c.OutputDir)
+	c.StaticDir = expand(c.StaticDir)
+	if c.StaticDirs != nil {
+		dirs := make([]StaticDir, len(c.StaticDirs))
+		for x, d := range c.StaticDirs {
+			d.Dir = expand(d.Dir)
+			d.Dest = expand(d.Dest)
+			dirs[x] = d
+		}
+		c.StaticDirs = dirs
+	}
+	c.PartialsDir = expand(c.PartialsDir)
+	c.LuaScript = expand(c.LuaScript)
+	c.Overlays = expandAll(c.Overlays, expand)
+	c.LicenseHeaderFile = expand(c.LicenseHeaderFile)
+	c.PluginDirs = expandAll(c.PluginDirs, expand)
+	for _, paths := range []*map[string]string{&c.TablePaths, &c.SchemaPaths, &c.EnumPaths, &c.DBPaths} {
+		if *paths == nil {
+			continue
+		}
+		m := make(map[string]string, len(*paths))
+		for k, v := range *paths {
+			m[k] = expand(v)
+		}
+		*paths = m
+	}
+	for _, targets := range []*[]TemplateTarget{&c.TableTemplates, &c.SchemaTemplates, &c.EnumTemplates, &c.DBTemplates} {
+		if *targets == nil {
+			continue
+		}
+		ts := make([]TemplateTarget, len(*targets))
+		for x, t := range *targets {
+			t.Template = expand(t.Template)
+			t.Params = expandMap(t.Params, expand)
+			ts[x] = t
+		}
+		*targets = ts
+	}
+	for _, opts := range []*TargetOptions{&c.TableOptions, &c.SchemaOptions, &c.EnumOptions, &c.DBOptions} {
+		opts.Params = expandMap(opts.Params, expand)
+	}
+	c.Params = expandMap(c.Params, expand)
+}
+
+// expandMap is expandParams for a Params map.
+func expandMap(m map[string]interface{}, expand func(string) string) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	return expandParams(m, expand).(map[string]interface{})
+}
+
+func expandAll(ss []string, expand func(string) string) []string {
+	if ss == nil {
+		return nil
+	}
+	out := make([]string, len(ss))
+	for x, s := range ss {
+		out[x] = expand(s)
+	}
+	return out
+}
+
+// expandParams returns a copy of v with expand applied to all the strings in
+// it, including those in nested tables and arrays.
+func expandParams(v interface{}, expand func(string) string) interface{} {
+	switch v := v.(type) {
+	case string:
+		return expand(v)
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[k] = expandParams(val, expand)
+		}
+		return m
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for x, val := range v {
+			out[x] = expandParams(val, expand)
+		}
+		return out
+	case []map[string]interface{}:
+		out := make([]map[string]interface{}, len(v))
+		for x, val := range v {
+			out[x] = expandParams(val, expand).(map[string]interface{})
+		}
+		return out
+	}
+	return v
+}
+
+// databaseSchemas validates the Database sections of the config, and returns
+// the schemas of all the databases.
+func databaseSchemas(c Config) ([]string, error) {
+	if c.DBType != "" || c.ConnStr != "" || c.ConnStrFile != "" || len(c.Schemas) > 0 {
+		return nil, errors.New("DBType, ConnStr, ConnStrFile, and Schemas can't be set with Database, set them in each Database instead")
+	}
+	if len(c.Queries) > 0 {
+		return nil, errors.New("Queries can't be used with Database")
+	}
+	var schemas []string
+	names := map[string]bool{}
+	for _, d := range c.Database {
+		if d.Name == "" {
+			return nil, errors.New("no Name specified for Database")
+		}
+		if names[d.Name] {
+			return nil, errors.Errorf("more than one Database named %q", d.Name)
+		}
+		names[d.Name] = true
+		if len(d.Schemas) == 0 {
+			return nil, errors.Errorf("no schemas specified for Database %q", d.Name)
+		}
+		schemas = append(schemas, d.Schemas...)
+	}
+	return schemas, nil
+}
+
+// getDriver returns the driver registered for the DBType name, or the plugin
+// driver it names.
 func getDriver(name string) (database.Driver, error) {
-	switch name {
-	case "postgres":
-		return postgres.PG{}, nil
-	case "mysql":
-		return mysql.MySQL{}, nil
-	default:
-		return nil, errors.Errorf("unknown database type: %v", name)
+	if p, ok := plugin.New(name); ok {
+		return p, nil
+	}
+	d, ok := drivers.Lookup(name)
+	if !ok {
+		return nil, errors.Errorf("unknown database type: %v (known types are %v)", name, strings.Join(drivers.Names(), ", "))
 	}
+	return d, nil
 }
 
 // parseTables takes a list of tablenames in "<schema.>table" format and spits
@@ -154,7 +688,19 @@ func parseTables(tables, schemas []string) (map[string][]string, error) {
 		out[s] = nil
 	}
 	for _, t := range tables {
-		vals := strings.Split(t, ".")
+		var vals []string
+		if strings.HasPrefix(t, "/") {
+			// a regular expression for the table name, which may contain periods
+			vals = []string{t}
+		} else {
+			vals = strings.SplitN(t, ".", 2)
+			if len(vals) == 2 && !strings.HasPrefix(vals[1], "/") && strings.Contains(vals[1], ".") {
+				vals = strings.Split(t, ".")
+			}
+		}
+		if _, err := run.ParseNamePattern(vals[len(vals)-1]); err != nil {
+			return nil, errors.WithMessage(err, "bad table "+t)
+		}
 		switch len(vals) {
 		case 1:
 			// just the table name, so it goes for all schemas
@@ -177,31 +723,190 @@ func parseTables(tables, schemas []string) (map[string][]string, error) {
 	return out, nil
 }
 
-func parseOutputTargets(vals map[string]string, usePath bool) ([]run.OutputTarget, error) {
+// schemaTypeMaps converts the Schema sections of the config file into the
+// scoped type maps used by gnorm.
+func schemaTypeMaps(schemas map[string]SchemaConfig) map[string]run.SchemaTypeMaps {
+	if len(schemas) == 0 {
+		return nil
+	}
+	out := make(map[string]run.SchemaTypeMaps, len(schemas))
+	for name, s := range schemas {
+		st := run.SchemaTypeMaps{
+			TypeMaps: run.TypeMaps{TypeMap: s.TypeMap, NullableTypeMap: s.NullableTypeMap},
+			Tables:   make(map[string]run.TypeMaps, len(s.Table)),
+		}
+		for table, t := range s.Table {
+			st.Tables[table] = run.TypeMaps{TypeMap: t.TypeMap, NullableTypeMap: t.NullableTypeMap}
+		}
+		out[name] = st
+	}
+	return out
+}
+
+// targetEngine returns the name of the built-in template engine used to render
+// targets with the given options, or "" if the TemplateEngine CommandLine should
+// be used.
+func targetEngine(c Config, opts TargetOptions) string {
+	switch {
+	case opts.Engine != "":
+		return opts.Engine
+	case c.TemplateEngine.Name != "":
+		return c.TemplateEngine.Name
+	case len(c.TemplateEngine.CommandLine) != 0:
+		return ""
+	default:
+		return engineText
+	}
+}
+
+func parseOutputTargets(vals map[string]string, params map[string]interface{}, opts TargetOptions, engine string, parser *contentsParser) ([]run.OutputTarget, error) {
 	out := make([]run.OutputTarget, 0, len(vals))
 	for fnTempl, contTempl := range vals {
-		fn, err := template.New("filename").Funcs(environ.FuncMap).Parse(fnTempl)
+		target, err := parseOutputTarget(fnTempl, contTempl, opts, engine, parser)
 		if err != nil {
-			return nil, errors.WithMessage(err, "error parsing filename template")
+			return nil, err
 		}
-		if usePath {
-			// use path means we're using an external template engine, so don't try to
-			// parse the template.
-			if _, err := os.Stat(contTempl); err != nil {
-				return nil, errors.WithMessage(err, "error checking contents template")
-			}
-			out = append(out, run.OutputTarget{Filename: fn, ContentsPath: contTempl})
+		target.Params = targetParams(params, opts)
+		out = append(out, target)
+	}
+	return out, nil
+}
+
+// parseTemplateTargets parses a list of template targets, applying each
+// target's options on top of the options for its type.
+func parseTemplateTargets(targets []TemplateTarget, c Config, opts TargetOptions, parser *contentsParser) ([]run.OutputTarget, error) {
+	out := make([]run.OutputTarget, 0, len(targets))
+	for x, t := range targets {
+		if t.Template == "" || t.Filename == "" {
+			return nil, errors.Errorf("target %d must have both a Template and a Filename", x+1)
+		}
+		o := opts
+		if t.Engine != "" {
+			o.Engine = t.Engine
+		}
+		if t.Format != "" {
+			o.Format = t.Format
+		}
+		if t.FileMode != "" {
+			o.FileMode = t.FileMode
+		}
+		o.TrimBlankLines = o.TrimBlankLines || t.TrimBlankLines
+		o.CollapseBlankLines = o.CollapseBlankLines || t.CollapseBlankLines
+		o.Params = mergeParams(opts.Params, t.Params)
+		target, err := parseOutputTarget(t.Filename, t.Template, o, targetEngine(c, o), parser)
+		if err != nil {
+			return nil, err
+		}
+		target.Params = targetParams(c.Params, o)
+		out = append(out, target)
+	}
+	return out, nil
+}
+
+// targetParams returns the Params for targets with the given options, or nil
+// if the options don't override the global Params.
+func targetParams(global map[string]interface{}, opts TargetOptions) map[string]interface{} {
+	if len(opts.Params) == 0 {
+		return nil
+	}
+	return mergeParams(global, opts.Params)
+}
+
+// mergeParams returns the values of base overridden by the values of over.
+// Values that are tables in both are merged recursively.  Neither map is
+// modified.
+func mergeParams(base, over map[string]interface{}) map[string]interface{} {
+	if len(over) == 0 {
+		return base
+	}
+	if len(base) == 0 {
+		return over
+	}
+	out := make(map[string]interface{}, len(base)+len(over))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range over {
+		bm, ok1 := out[k].(map[string]interface{})
+		om, ok2 := v.(map[string]interface{})
+		if ok1 && ok2 {
+			out[k] = mergeParams(bm, om)
 			continue
 		}
-		b, err := ioutil.ReadFile(contTempl)
+		out[k] = v
+	}
+	return out
+}
+
+func parseOutputTarget(fnTempl, contTempl string, opts TargetOptions, engine string, parser *contentsParser) (run.OutputTarget, error) {
+	fn, err := template.New("filename").Funcs(parser.funcMap()).Parse(fnTempl)
+	if err != nil {
+		return run.OutputTarget{}, errors.WithMessage(err, "error parsing filename template")
+	}
+	mode, err := parseMode("FileMode", opts.FileMode)
+	if err != nil {
+		return run.OutputTarget{}, err
+	}
+	switch opts.Format {
+	case "", run.FormatGofmt, run.FormatGoimports:
+	default:
+		return run.OutputTarget{}, errors.Errorf("unknown Format %q, expected %q or %q", opts.Format, run.FormatGofmt, run.FormatGoimports)
+	}
+	if engine == "" {
+		// use path means we're using an external template engine, so don't try to
+		// parse the template.
+		if _, err := os.Stat(contTempl); err != nil {
+			return run.OutputTarget{}, errors.WithMessage(err, "error checking contents template")
+		}
+		return run.OutputTarget{Filename: fn, ContentsPath: contTempl, FileMode: mode}, nil
+	}
+	b, err := parser.readFile(contTempl)
+	if err != nil {
+		return run.OutputTarget{}, errors.WithMessage(err, "error reading contents template")
+	}
+	cont, err := parser.parse(engine, contTempl, b)
+	if err != nil {
+		return run.OutputTarget{}, errors.WithMessage(err, "error parsing contents template")
+	}
+	return run.OutputTarget{
+		Filename:           fn,
+		Contents:           cont,
+		ContentsPath:       contTempl,
+		TrimBlankLines:     opts.TrimBlankLines,
+		CollapseBlankLines: opts.CollapseBlankLines,
+		Format:             opts.Format,
+		FileMode:           mode,
+	}, nil
+}
+
+// parseStaticTemplates parses each file copied from dir as a template with the
+// named built-in engine, or text/template if the TemplateEngine CommandLine is
+// used, and adds them to out by path.
+func parseStaticTemplates(out map[string]run.Template, dir run.StaticDir, engine string, parser *contentsParser) error {
+	return dir.WalkFiles(parser.fsys, func(path string, info os.FileInfo) error {
+		b, err := parser.readFile(path)
 		if err != nil {
-			return nil, errors.WithMessage(err, "error reading contents template")
+			return err
 		}
-		cont, err := template.New(contTempl).Funcs(environ.FuncMap).Parse(string(b))
+		t, err := parser.parse(engine, path, b)
 		if err != nil {
-			return nil, errors.WithMessage(err, "error parsing contents template")
+			return errors.WithMessage(err, path)
 		}
-		out = append(out, run.OutputTarget{Filename: fn, Contents: cont})
+		out[path] = t
+		return nil
+	})
+}
+
+// parseMode parses the octal file permissions in s, as given in the config
+// field with the given name.  An empty string means the mode isn't set, and
+// returns 0.
+func parseMode(name, s string) (os.FileMode, error) {
+	if s == "" {
+		return 0, nil
 	}
-	return out, nil
+	m, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || m == 0 || m > 0777 {
+		return 0, errors.Errorf("%s %q must be an octal permission like \"0644\"", name, s)
+	}
+	return os.FileMode(m), nil
 }
@@ -2,10 +2,19 @@ package cli
 
 import (
 	"bytes"
-	"log"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"text/template"
 
+	"gnorm.org/gnorm/database/drivers/plugin"
 	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run"
 	"gnorm.org/gnorm/run/data"
 
 	"github.com/BurntSushi/toml"
@@ -45,14 +54,30 @@ func TestParseTables(t *testing.T) {
 	}
 }
 
+func TestParseTablePatterns(t *testing.T) {
+	m, err := parseTables([]string{"audit_*", "/^tmp\\..*/", "schema2./^a.b$/"}, []string{"schema", "schema2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]string{
+		"schema":  {"audit_*", "/^tmp\\..*/"},
+		"schema2": {"audit_*", "/^tmp\\..*/", "/^a.b$/"},
+	}
+	if diff := cmp.Diff(expected, m); diff != "" {
+		t.Fatalf("unexpected tables (-want +got):\n%s", diff)
+	}
+	if _, err := parseTables([]string{"/(/"}, []string{"schema"}); err == nil {
+		t.Fatal("expected an error for a bad regular expression, but got nil")
+	}
+}
+
 func TestParseConfig(t *testing.T) {
 	var stderr, stdout bytes.Buffer
 	env := environ.Values{
 		Stderr: &stderr,
 		Stdout: &stdout,
-		Log:    log.New(&stderr, "", 0),
 	}
-	cfg, err := parseFile(env, "gnorm.toml")
+	cfg, err := parseFile(env, "gnorm.toml", "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -99,6 +124,81 @@ func TestParseConfig(t *testing.T) {
 
 }
 
+func TestParseWithFuncs(t *testing.T) {
+	var stderr bytes.Buffer
+	env := environ.Values{
+		Stderr: &stderr,
+	}
+	config := `
+DBType = "postgres"
+Schemas = ["public"]
+NameConversion = "{{shout .}}"
+[TablePaths]
+"{{shout .Table}}.go" = "testdata/table.tpl"
+`
+	cfg, err := Parse(env, strings.NewReader(config), run.WithFuncs(template.FuncMap{"shout": strings.ToUpper}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err := cfg.NameConversion.Execute(buf, "users"); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "USERS" {
+		t.Fatalf(`expected "USERS", but got %q`, buf.String())
+	}
+
+	if _, err := Parse(env, strings.NewReader(config)); err == nil {
+		t.Fatal("expected an error parsing templates with an unknown function, but got nil")
+	}
+}
+
+func TestParseTemplateTargets(t *testing.T) {
+	var stderr bytes.Buffer
+	env := environ.Values{
+		Stderr: &stderr,
+	}
+	config := `
+DBType = "postgres"
+Schemas = ["public"]
+NameConversion = "{{.}}"
+
+[TableOptions]
+CollapseBlankLines = true
+
+[[TableTemplates]]
+Template = "testdata/table.tpl"
+Filename = "{{.Table}}/model.go"
+
+[[TableTemplates]]
+Template = "testdata/table.tpl"
+Filename = "{{.Table}}/queries.go"
+TrimBlankLines = true
+`
+	cfg, err := Parse(env, strings.NewReader(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.TablePaths) != 2 {
+		t.Fatalf("expected 2 table targets, but got %d", len(cfg.TablePaths))
+	}
+	for x, name := range []string{"users/model.go", "users/queries.go"} {
+		buf := &bytes.Buffer{}
+		if err := cfg.TablePaths[x].Filename.Execute(buf, map[string]string{"Table": "users"}); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != name {
+			t.Errorf("expected target %d to be %q, but got %q", x, name, buf.String())
+		}
+		if !cfg.TablePaths[x].CollapseBlankLines {
+			t.Errorf("expected target %d to inherit CollapseBlankLines from TableOptions", x)
+		}
+	}
+	if cfg.TablePaths[0].TrimBlankLines || !cfg.TablePaths[1].TrimBlankLines {
+		t.Error("expected only the second target to have TrimBlankLines set")
+	}
+}
+
 func TestParseGnormToml(t *testing.T) {
 	c := Config{}
 	m, err := toml.DecodeFile("gnorm.toml", &c)
@@ -119,3 +219,715 @@ func contains(list []string, s string) bool {
 	}
 	return false
 }
+
+func TestParseYAMLAndJSON(t *testing.T) {
+	var stderr bytes.Buffer
+	env := environ.Values{
+		Stderr: &stderr,
+	}
+	configs := map[string]string{
+		"yaml": `
+DBType: postgres
+Schemas: [public]
+NameConversion: "{{pascal .}}"
+TablePaths:
+  "{{.Table}}.go": testdata/table.tpl
+TableTemplates:
+  - Template: testdata/table.tpl
+    Filename: "{{.Table}}_test.go"
+    TrimBlankLines: true
+TypeMap:
+  integer: int
+`,
+		"json": `{
+	"DBType": "postgres",
+	"Schemas": ["public"],
+	"NameConversion": "{{pascal .}}",
+	"TablePaths": {"{{.Table}}.go": "testdata/table.tpl"},
+	"TableTemplates": [
+		{"Template": "testdata/table.tpl", "Filename": "{{.Table}}_test.go", "TrimBlankLines": true}
+	],
+	"TypeMap": {"integer": "int"}
+}`,
+	}
+	for format, config := range configs {
+		t.Run(format, func(t *testing.T) {
+			parse := ParseJSON
+			if format == "yaml" {
+				parse = ParseYAML
+			}
+			cfg, err := parse(env, strings.NewReader(config))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cmp.Equal(cfg.Schemas, []string{"public"}) {
+				t.Errorf("expected Schemas [public], but got %v", cfg.Schemas)
+			}
+			if cfg.TypeMap["integer"] != "int" {
+				t.Errorf("expected TypeMap integer to be int, but got %q", cfg.TypeMap["integer"])
+			}
+			if len(cfg.TablePaths) != 2 {
+				t.Fatalf("expected 2 table targets, but got %d", len(cfg.TablePaths))
+			}
+			if !cfg.TablePaths[1].TrimBlankLines {
+				t.Error("expected TableTemplates target to have TrimBlankLines set")
+			}
+		})
+	}
+}
+
+func TestParseExtends(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	base := `
+DBType = "postgres"
+Schemas = ["public"]
+NameConversion = "{{pascal .}}"
+
+[TablePaths]
+"{{.Table}}.go" = "testdata/table.tpl"
+
+[TypeMap]
+integer = "int"
+text = "string"
+`
+	local := `
+Extends = "base.gnorm.toml"
+Schemas = ["billing"]
+
+[TypeMap]
+text = "sql.NullString"
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, "base.gnorm.toml"), []byte(base), 0600); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "gnorm.toml")
+	if err := ioutil.WriteFile(file, []byte(local), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var stderr bytes.Buffer
+	env := environ.Values{
+		Stderr: &stderr,
+	}
+	cfg, err := parseFile(env, file, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DBType != "postgres" {
+		t.Errorf("expected DBType from base config, but got %q", cfg.DBType)
+	}
+	if !cmp.Equal(cfg.Schemas, []string{"billing"}) {
+		t.Errorf("expected Schemas to be overridden to [billing], but got %v", cfg.Schemas)
+	}
+	expected := map[string]string{"integer": "int", "text": "sql.NullString"}
+	if diff := cmp.Diff(expected, cfg.TypeMap); diff != "" {
+		t.Errorf("unexpected TypeMap (-want +got):\n%s", diff)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "base.gnorm.toml"), []byte(`Extends = "gnorm.toml"`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseFile(env, file, ""); err == nil {
+		t.Fatal("expected an error for config files that extend each other, but got nil")
+	}
+}
+
+func TestParseProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := `
+ConnStr = "dbname=dev"
+DBType = "postgres"
+Schemas = ["public"]
+NameConversion = "{{pascal .}}"
+
+[TablePaths]
+"{{.Table}}.go" = "testdata/table.tpl"
+
+[Profiles.prod]
+ConnStr = "dbname=prod"
+OutputDir = "gen"
+`
+	file := filepath.Join(dir, "gnorm.toml")
+	if err := ioutil.WriteFile(file, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var stderr bytes.Buffer
+	env := environ.Values{
+		Stderr: &stderr,
+	}
+	cfg, err := parseFile(env, file, "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ConnStr != "dbname=prod" || cfg.OutputDir != "gen" {
+		t.Errorf("expected ConnStr and OutputDir from the prod profile, but got %q and %q", cfg.ConnStr, cfg.OutputDir)
+	}
+	if !cmp.Equal(cfg.Schemas, []string{"public"}) {
+		t.Errorf("expected Schemas not set by the profile to be unchanged, but got %v", cfg.Schemas)
+	}
+	if _, err := parseFile(env, file, "staging"); err == nil {
+		t.Fatal("expected an error for an unknown profile, but got nil")
+	}
+}
+
+func TestParseSchemaTypeMaps(t *testing.T) {
+	var stderr bytes.Buffer
+	env := environ.Values{
+		Stderr: &stderr,
+	}
+	config := `
+DBType = "postgres"
+Schemas = ["billing"]
+NameConversion = "{{.}}"
+
+[TablePaths]
+"{{.Table}}.go" = "testdata/table.tpl"
+
+[Schema.billing.TypeMap]
+numeric = "decimal.Decimal"
+
+[Schema.billing.Table.invoices.NullableTypeMap]
+numeric = "decimal.NullDecimal"
+`
+	cfg, err := Parse(env, strings.NewReader(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]run.SchemaTypeMaps{
+		"billing": {
+			TypeMaps: run.TypeMaps{TypeMap: map[string]string{"numeric": "decimal.Decimal"}},
+			Tables: map[string]run.TypeMaps{
+				"invoices": {NullableTypeMap: map[string]string{"numeric": "decimal.NullDecimal"}},
+			},
+		},
+	}
+	if diff := cmp.Diff(expected, cfg.SchemaTypeMaps); diff != "" {
+		t.Fatalf("unexpected SchemaTypeMaps (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseConnStrFileAndPassword(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	dsn := filepath.Join(dir, "dsn")
+	if err := ioutil.WriteFile(dsn, []byte("dbname=$DB password=$GNORM_PASSWORD\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	pw := filepath.Join(dir, "pw")
+	if err := ioutil.WriteFile(pw, []byte("s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config := `
+DBType = "postgres"
+Schemas = ["public"]
+NameConversion = "{{.}}"
+ConnStrFile = "` + filepath.ToSlash(dsn) + `"
+
+[Password]
+File = "` + filepath.ToSlash(pw) + `"
+
+[TablePaths]
+"{{.Table}}.go" = "testdata/table.tpl"
+`
+	var stderr bytes.Buffer
+	env := environ.Values{
+		Stderr: &stderr,
+		Env:    map[string]string{"DB": "mydb", "GNORM_PASSWORD": "from env"},
+	}
+	cfg, err := Parse(env, strings.NewReader(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "dbname=mydb password=s3cret"; cfg.ConnStr != expected {
+		t.Fatalf("expected ConnStr %q, but got %q", expected, cfg.ConnStr)
+	}
+}
+
+func TestParseSSHTunnel(t *testing.T) {
+	config := `
+DBType = "postgres"
+Schemas = ["public"]
+NameConversion = "{{.}}"
+ConnStr = "postgres://$USER@$GNORM_TUNNEL/mydb"
+
+[SSHTunnel]
+Host = "bastion.example.com"
+User = "deploy"
+Agent = true
+Remote = "db.internal:5432"
+
+[TablePaths]
+"{{.Table}}.go" = "testdata/table.tpl"
+`
+	var stderr bytes.Buffer
+	env := environ.Values{
+		Stderr: &stderr,
+		Env:    map[string]string{"USER": "admin"},
+	}
+	cfg, err := Parse(env, strings.NewReader(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "postgres://admin@$GNORM_TUNNEL/mydb"; cfg.ConnStr != expected {
+		t.Fatalf("expected ConnStr %q, but got %q", expected, cfg.ConnStr)
+	}
+	expected := run.SSHTunnel{Host: "bastion.example.com", User: "deploy", Agent: true, Remote: "db.internal:5432"}
+	if cfg.SSHTunnel == nil || *cfg.SSHTunnel != expected {
+		t.Fatalf("expected SSHTunnel %#v, but got %#v", expected, cfg.SSHTunnel)
+	}
+}
+
+func TestParseDatabases(t *testing.T) {
+	config := `
+NameConversion = "{{.}}"
+IncludeTables = ["accounts.invoices"]
+
+[[Database]]
+Name = "billing"
+DBType = "postgres"
+ConnStr = "dbname=$DB"
+Schemas = ["accounts"]
+
+[[Database]]
+Name = "users"
+DBType = "mysql"
+ConnStr = "root@tcp/"
+Schemas = ["users"]
+
+[TablePaths]
+"{{.Database}}/{{.Table}}.go" = "testdata/table.tpl"
+`
+	var stderr bytes.Buffer
+	env := environ.Values{
+		Stderr: &stderr,
+		Env:    map[string]string{"DB": "billing"},
+	}
+	cfg, err := Parse(env, strings.NewReader(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Databases) != 2 {
+		t.Fatalf("expected 2 databases, but got %v", len(cfg.Databases))
+	}
+	billing := cfg.Databases[0]
+	if billing.Name != "billing" || billing.ConnStr != "dbname=billing" || len(billing.Schemas) != 1 || billing.Driver == nil {
+		t.Fatalf("unexpected billing database: %#v", billing)
+	}
+	if _, ok := cfg.IncludeTables["accounts"]; !ok {
+		t.Fatalf("expected IncludeTables for the accounts schema, but got %v", cfg.IncludeTables)
+	}
+
+	_, err = Parse(env, strings.NewReader(config+`
+[Queries]
+count = "SELECT 1"
+`))
+	if err == nil {
+		t.Fatal("expected an error using Queries with Database, but got nil")
+	}
+}
+
+func TestParseTargetParams(t *testing.T) {
+	config := `
+DBType = "postgres"
+Schemas = ["public"]
+NameConversion = "{{.}}"
+ConnStr = "dbname=mydb"
+
+[Params]
+module = "example.com/app"
+db = { driver = "pgx", pool = 1 }
+
+[TablePaths]
+"{{.Table}}.go" = "testdata/table.tpl"
+
+[SchemaPaths]
+"{{.Schema}}.go" = "testdata/table.tpl"
+
+[TableOptions.Params]
+package = "models"
+db = { pool = 5 }
+
+[[TableTemplates]]
+Template = "testdata/table.tpl"
+Filename = "{{.Table}}_queries.go"
+[TableTemplates.Params]
+package = "queries"
+`
+	var stderr bytes.Buffer
+	env := environ.Values{
+		Stderr: &stderr,
+	}
+	cfg, err := Parse(env, strings.NewReader(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.TablePaths) != 2 || len(cfg.SchemaPaths) != 1 {
+		t.Fatalf("expected 2 table targets and 1 schema target, but got %v and %v", len(cfg.TablePaths), len(cfg.SchemaPaths))
+	}
+	expected := map[string]interface{}{
+		"module":  "example.com/app",
+		"package": "models",
+		"db":      map[string]interface{}{"driver": "pgx", "pool": int64(5)},
+	}
+	if diff := cmp.Diff(expected, cfg.TablePaths[0].Params); diff != "" {
+		t.Fatalf("unexpected TablePaths params (-want +got):\n%s", diff)
+	}
+	expected["package"] = "queries"
+	if diff := cmp.Diff(expected, cfg.TablePaths[1].Params); diff != "" {
+		t.Fatalf("unexpected TableTemplates params (-want +got):\n%s", diff)
+	}
+	if cfg.SchemaPaths[0].Params != nil {
+		t.Fatalf("expected schema target to use the global params, but got %v", cfg.SchemaPaths[0].Params)
+	}
+	if pool := cfg.Params["db"].(map[string]interface{})["pool"]; pool != int64(1) {
+		t.Fatalf("expected global params to be unchanged, but got pool %v", pool)
+	}
+}
+
+func TestParseExpandVars(t *testing.T) {
+	config := `
+DBType = "postgres"
+Schemas = ["public"]
+NameConversion = "{{.}}"
+ConnStr = "dbname=mydb"
+OutputDir = "$OUT/gen"
+
+[Params]
+module = "$MODULE"
+price = "$$5"
+nested = { paths = ["$OUT/a"] }
+
+[TablePaths]
+"{{.Table}}.go" = "$TEMPLATES/table.tpl"
+`
+	var stderr bytes.Buffer
+	env := environ.Values{
+		Stderr: &stderr,
+		Env:    map[string]string{"OUT": "build", "MODULE": "example.com/app", "TEMPLATES": "testdata"},
+	}
+	cfg, err := Parse(env, strings.NewReader(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "build/gen"; cfg.OutputDir != expected {
+		t.Fatalf("expected OutputDir %q, but got %q", expected, cfg.OutputDir)
+	}
+	if expected := "testdata/table.tpl"; cfg.TablePaths[0].ContentsPath != expected {
+		t.Fatalf("expected template path %q, but got %q", expected, cfg.TablePaths[0].ContentsPath)
+	}
+	expected := map[string]interface{}{
+		"module": "example.com/app",
+		"price":  "$5",
+		"nested": map[string]interface{}{"paths": []interface{}{"build/a"}},
+	}
+	if diff := cmp.Diff(expected, cfg.Params); diff != "" {
+		t.Fatalf("unexpected params (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseFileModes(t *testing.T) {
+	config := `
+DBType = "postgres"
+Schemas = ["public"]
+NameConversion = "{{.}}"
+FileMode = "0644"
+DirMode = "0755"
+
+[TableOptions]
+FileMode = "0600"
+
+[[SchemaTemplates]]
+Template = "testdata/table.tpl"
+Filename = "{{.Schema}}.go"
+
+[[SchemaTemplates]]
+Template = "testdata/table.tpl"
+Filename = "{{.Schema}}.sh"
+FileMode = "0755"
+
+[TablePaths]
+"{{.Table}}.go" = "testdata/table.tpl"
+`
+	var stderr bytes.Buffer
+	env := environ.Values{Stderr: &stderr}
+	cfg, err := Parse(env, strings.NewReader(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.FileMode != 0644 || cfg.DirMode != 0755 {
+		t.Errorf("expected FileMode 0644 and DirMode 0755, but got %o and %o", cfg.FileMode, cfg.DirMode)
+	}
+	if cfg.SchemaPaths[0].FileMode != 0 || cfg.SchemaPaths[1].FileMode != 0755 {
+		t.Errorf("expected schema targets to have FileMode 0 and 0755, but got %o and %o", cfg.SchemaPaths[0].FileMode, cfg.SchemaPaths[1].FileMode)
+	}
+	if cfg.TablePaths[0].FileMode != 0600 {
+		t.Errorf("expected table target to have FileMode 0600, but got %o", cfg.TablePaths[0].FileMode)
+	}
+
+	for _, mode := range []string{"644x", "0", "1777", "rw-r--r--"} {
+		_, err := Parse(env, strings.NewReader(strings.Replace(config, `FileMode = "0644"`, `FileMode = "`+mode+`"`, 1)))
+		if err == nil {
+			t.Errorf("expected an error for FileMode %q, but got nil", mode)
+		}
+	}
+}
+
+func TestParseStaticTemplates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gnorm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "Makefile")
+	if err := ioutil.WriteFile(path, []byte("NAME = {{.Params.name}}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config := `
+DBType = "postgres"
+Schemas = ["public"]
+NameConversion = "{{.}}"
+StaticTemplates = true
+
+[TablePaths]
+"{{.Table}}.go" = "testdata/table.tpl"
+`
+	var stderr bytes.Buffer
+	env := environ.Values{Stderr: &stderr}
+	if _, err := Parse(env, strings.NewReader(config)); err == nil {
+		t.Fatal("expected an error for StaticTemplates without a StaticDir, but got nil")
+	}
+	config = "StaticDir = " + strconv.Quote(dir) + "\n" + config
+	cfg, err := Parse(env, strings.NewReader(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl, ok := cfg.StaticTemplates[path]
+	if !ok {
+		t.Fatalf("expected a template for %s, but got %v", path, cfg.StaticTemplates)
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, data.StaticData{Params: map[string]interface{}{"name": "app"}}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "NAME = app\n" {
+		t.Fatalf("expected the static file to be rendered, but got %q", buf)
+	}
+}
+
+func TestParseStaticDirs(t *testing.T) {
+	config := `
+DBType = "postgres"
+Schemas = ["public"]
+NameConversion = "{{.}}"
+OutputDir = "gen"
+
+[TablePaths]
+"{{.Table}}.go" = "testdata/table.tpl"
+
+[[StaticDirs]]
+Dir = "$ASSETS"
+Dest = "web/assets/"
+Include = ["*.gotmpl"]
+Exclude = ["bang.*"]
+Templates = true
+`
+	var stderr bytes.Buffer
+	env := environ.Values{Stderr: &stderr, Env: map[string]string{"ASSETS": "testdata/partials"}}
+	cfg, err := Parse(env, strings.NewReader(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []run.StaticDir{{
+		Dir:     "testdata/partials",
+		Dest:    filepath.Join("web", "assets"),
+		Include: []string{"*.gotmpl"},
+		Exclude: []string{"bang.*"},
+	}}
+	if diff := cmp.Diff(expected, cfg.StaticDirs); diff != "" {
+		t.Fatalf("unexpected StaticDirs (-want +got):\n%s", diff)
+	}
+	// only the included files are parsed.
+	if _, ok := cfg.StaticTemplates[filepath.Join("testdata", "partials", "column.gotmpl")]; !ok || len(cfg.StaticTemplates) != 1 {
+		t.Fatalf("expected only column.gotmpl to be parsed as a template, but got %v", cfg.StaticTemplates)
+	}
+
+	for _, bad := range []string{`Dest = "../up"`, `Dest = "/abs"`, `Dir = ""`, `Include = ["[x"]`} {
+		field := strings.SplitN(bad, " ", 2)[0]
+		c := regexp.MustCompile(`(?m)^`+field+` = .*$`).ReplaceAllString(config, bad)
+		if _, err := Parse(env, strings.NewReader(c)); err == nil {
+			t.Errorf("expected an error for %s, but got nil", bad)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	config := `
+DBType = "postgres"
+Schemas = ["public"]
+NameConversion = "{{.}}"
+
+[TableOptions]
+Format = "gofmt"
+
+[[TableTemplates]]
+Template = "testdata/table.tpl"
+Filename = "{{.Table}}/model.go"
+
+[[TableTemplates]]
+Template = "testdata/table.tpl"
+Filename = "{{.Table}}/queries.go"
+Format = "goimports"
+`
+	var stderr bytes.Buffer
+	env := environ.Values{Stderr: &stderr}
+	cfg, err := Parse(env, strings.NewReader(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.TablePaths[0].Format != run.FormatGofmt || cfg.TablePaths[1].Format != run.FormatGoimports {
+		t.Fatalf("expected Formats gofmt and goimports, but got %q and %q", cfg.TablePaths[0].Format, cfg.TablePaths[1].Format)
+	}
+	config = strings.Replace(config, `Format = "gofmt"`, `Format = "prettier"`, 1)
+	if _, err := Parse(env, strings.NewReader(config)); err == nil {
+		t.Fatal("expected an error for an unknown Format, but got nil")
+	}
+}
+
+func TestParseLicenseHeaderFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gnorm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "LICENSE_HEADER")
+	if err := ioutil.WriteFile(path, []byte("Copyright Example\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config := `
+DBType = "postgres"
+Schemas = ["public"]
+NameConversion = "{{.}}"
+LicenseHeaderFile = "$DIR/LICENSE_HEADER"
+
+[TablePaths]
+"{{.Table}}.go" = "testdata/table.tpl"
+`
+	var stderr bytes.Buffer
+	env := environ.Values{Stderr: &stderr, Env: map[string]string{"DIR": dir}}
+	cfg, err := Parse(env, strings.NewReader(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.License != "Copyright Example\n" {
+		t.Fatalf("expected License to hold the file's contents, but got %q", cfg.License)
+	}
+	os.Remove(path)
+	if _, err := Parse(env, strings.NewReader(config)); err == nil {
+		t.Fatal("expected an error for a missing LicenseHeaderFile, but got nil")
+	}
+}
+
+func TestGetDriver(t *testing.T) {
+	if _, err := getDriver("Postgres"); err != nil {
+		t.Fatal(err)
+	}
+	d, err := getDriver("plugin:./Drivers/sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := d.(plugin.Driver); !ok || p.Path != "./Drivers/sqlite" {
+		t.Fatalf("expected the plugin driver ./Drivers/sqlite, but got %#v", d)
+	}
+	if _, err := getDriver("oracle"); err == nil || !strings.Contains(err.Error(), "mysql, postgres") {
+		t.Fatalf("expected an error listing the known types, but got %v", err)
+	}
+}
+
+func TestParseWithFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"templates/table.gotmpl":    {Data: []byte(`{{template "name" .}}`)},
+		"templates/schema.mustache": {Data: []byte(`{{> header}}{{Schema}}`)},
+		"partials/name.gotmpl":      {Data: []byte(`{{define "name"}}table {{.Table}}{{end}}`)},
+		"partials/header.mustache":  {Data: []byte(`schema `)},
+		"static/Makefile":           {Data: []byte("NAME = {{.Params.name}}\n")},
+	}
+	config := `
+DBType = "postgres"
+Schemas = ["public"]
+NameConversion = "{{.}}"
+PartialsDir = "partials"
+StaticDir = "./static"
+StaticTemplates = true
+
+[TablePaths]
+"{{.Table}}.go" = "templates/table.gotmpl"
+
+[SchemaPaths]
+"{{.Schema}}.go" = "templates/schema.mustache"
+
+[SchemaOptions]
+Engine = "mustache"
+`
+	env := environ.Values{Stderr: &bytes.Buffer{}}
+	cfg, err := Parse(env, strings.NewReader(config), run.WithFS(fsys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	render := func(tmpl run.Template, data interface{}) string {
+		t.Helper()
+		buf := &bytes.Buffer{}
+		if err := tmpl.Execute(buf, data); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+	if s := render(cfg.TablePaths[0].Contents, map[string]string{"Table": "users"}); s != "table users" {
+		t.Errorf("expected the table template to use the partial, but got %q", s)
+	}
+	if s := render(cfg.SchemaPaths[0].Contents, map[string]string{"Schema": "public"}); s != "schema public" {
+		t.Errorf("expected the schema template to use the partial, but got %q", s)
+	}
+	tmpl, ok := cfg.StaticTemplates[filepath.Join("static", "Makefile")]
+	if !ok {
+		t.Fatalf("expected a template for static/Makefile, but got %v", cfg.StaticTemplates)
+	}
+	if s := render(tmpl, data.StaticData{Params: map[string]interface{}{"name": "app"}}); s != "NAME = app\n" {
+		t.Errorf("expected the static file to be rendered, but got %q", s)
+	}
+}
+
+func TestParseDataHook(t *testing.T) {
+	config := `
+DBType = "postgres"
+Schemas = ["public"]
+NameConversion = "{{.}}"
+DataHook = ["./transform", "--owners", "owners.csv"]
+
+[[TableTemplates]]
+Template = "testdata/table.tpl"
+Filename = "{{.Table}}.go"
+`
+	var stderr bytes.Buffer
+	env := environ.Values{Stderr: &stderr}
+	cfg, err := Parse(env, strings.NewReader(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"./transform", "--owners", "owners.csv"}
+	if diff := cmp.Diff(expected, cfg.DataHook); diff != "" {
+		t.Fatalf("unexpected DataHook (-want +got):\n%s", diff)
+	}
+}
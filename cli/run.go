@@ -1,17 +1,23 @@
 package cli
 
 import (
+	"context"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 
 	"github.com/spf13/cobra"
 
 	"gnorm.org/gnorm/environ"
 )
 
-// Run captures the OS environment and passes it to ParseAndRun.  It returns
+// Run captures the OS environment and passes it to ParseAndRunContext, with a
+// context that's cancelled when gnorm is interrupted or terminated.  It returns
 // the code that the executable should exit with.
 func Run() int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 	env := environ.Values{
 		Args:   make([]string, len(os.Args)-1),
 		Stderr: os.Stderr,
@@ -20,7 +26,7 @@ func Run() int {
 		Env:    getenv(os.Environ()),
 	}
 	copy(env.Args, os.Args[1:])
-	return ParseAndRun(env)
+	return ParseAndRunContext(ctx, env)
 }
 
 func getenv(env []string) map[string]string {
@@ -37,6 +43,17 @@ func getenv(env []string) map[string]string {
 
 // ParseAndRun parses the environment and runs the command.
 func ParseAndRun(env environ.Values) int {
+	return ParseAndRunContext(context.Background(), env)
+}
+
+// ParseAndRunContext is like ParseAndRun, but database queries and PostRun
+// commands are cancelled once ctx is done.
+func ParseAndRunContext(ctx context.Context, env environ.Values) int {
+	if env.Log == nil {
+		env.Log = environ.NewLogger(env.Stderr, environ.LevelWarn, environ.LogText)
+	}
+	var logFormat string
+	var logLevel string
 	// the return code from the executed command
 	rootCmd := &cobra.Command{
 		Use:   "gnorm",
@@ -44,15 +61,50 @@ func ParseAndRun(env environ.Values) int {
 		Long: `
 A flexible code generator that turns your DB schema into
 runnable code.  See full docs at https://gnorm.org`[1:],
+		// the commands all share env.Log, so setting it up here sets it up
+		// for whichever command runs.
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			format, err := environ.ParseLogFormat(logFormat)
+			if err != nil {
+				return codeErr{err, 2}
+			}
+			level, err := environ.ParseLevel(logLevel)
+			if err != nil {
+				return codeErr{err, 2}
+			}
+			// programs that pass in their own logger configure it themselves.
+			if l, ok := env.Log.(*environ.WriterLogger); ok {
+				l.SetFormat(format)
+				l.SetLevel(level)
+			}
+			return nil
+		},
 	}
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "format of log messages: text or json")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "warn", "lowest level of log messages to show: debug, info, or warn (--verbose is the same as debug)")
 	rootCmd.SetArgs(env.Args)
 	rootCmd.SetOutput(env.Stderr)
 
-	rootCmd.AddCommand(previewCmd(env))
-	rootCmd.AddCommand(genCmd(env))
+	rootCmd.AddCommand(previewCmd(ctx, env))
+	rootCmd.AddCommand(genCmd(ctx, env))
+	rootCmd.AddCommand(cleanCmd(env))
+	rootCmd.AddCommand(lintCmd(env))
+	rootCmd.AddCommand(validateCmd(ctx, env))
+	rootCmd.AddCommand(doctorCmd(ctx, env))
+	rootCmd.AddCommand(dumpCmd(ctx, env))
+	rootCmd.AddCommand(graphCmd(ctx, env))
+	rootCmd.AddCommand(openapiCmd(ctx, env))
+	rootCmd.AddCommand(graphqlCmd(ctx, env))
+	rootCmd.AddCommand(protoCmd(ctx, env))
+	rootCmd.AddCommand(jsonschemaCmd(ctx, env))
+	rootCmd.AddCommand(avroCmd(ctx, env))
+	rootCmd.AddCommand(exportCmd(ctx, env))
+	rootCmd.AddCommand(diffCmd(ctx, env))
+	rootCmd.AddCommand(driversCmd(env))
+	rootCmd.AddCommand(configCmd(env))
 	rootCmd.AddCommand(versionCmd(env))
 	rootCmd.AddCommand(initCmd(env))
-	rootCmd.AddCommand(docCmd(env))
+	rootCmd.AddCommand(docCmd(ctx, env))
 	rootCmd.SilenceUsage = true
 	return code(rootCmd.Execute())
 }
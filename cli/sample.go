@@ -24,26 +24,92 @@ package cli
 // 	fmt.Println("`")
 // }
 // gocog]]]
-const sample = `# ConnStr is the connection string for the database.  Any environment variables
+const sample = `# Extends, if specified, is the path to another config file (which may be toml,
+# yaml, or json) that this config file is based on.  Values from that file are
+# used unless this file sets them.  Tables such as TypeMap are merged, with keys
+# in this file overriding the same keys in the base file.  A relative path is
+# relative to the directory of this config file.
+# Extends = "../base.gnorm.toml"
+
+# Profiles holds sets of values that override the values in the rest of this
+# file, so one config file can be used for different environments.  A profile
+# is selected with the --profile flag, e.g. gnorm gen --profile prod.  Profiles
+# may set ConnStr, Schemas, and OutputDir.
+# [Profiles.prod]
+# ConnStr = "dbname=mydb host=db.example.com sslmode=require user=gnorm"
+# Schemas = ["public", "billing"]
+# OutputDir = "gen"
+
+# ConnStr is the connection string for the database.  Any environment variables
 # in this string will be expanded, so for example dbname=$MY_DDB will do the
 # right thing.
+# ConnStr may instead refer to a secret holding the connection string, which is
+# read when gnorm runs: "vault://path#key" reads key from the secret at path from
+# Vault at $VAULT_ADDR using $VAULT_TOKEN, "awssm://secret-id" reads from AWS
+# Secrets Manager using the aws CLI, and "gcpsm://secret" reads from Google
+# Cloud Secret Manager using the gcloud CLI.  For the latter two, #key may be
+# added to read key from a secret that holds a json object.
+# Vault example:
+# ConnStr = "vault://secret/data/mydb#dsn"
 # MySQL example:
 # ConnStr = "root:admin@tcp/"
 # Postgres example:
 ConnStr = "dbname=mydb host=127.0.0.1 sslmode=disable user=admin"
 
-# DBType holds the type of db you're connecting to.  Possible values are
-# "postgres" or "mysql".
+# ConnStrFile, if specified, is the path to a file containing the connection
+# string, which is used instead of ConnStr, so that it can be kept out of this
+# file.  You cannot set both ConnStr and ConnStrFile.
+# ConnStrFile = "secrets/dsn.txt"
+
+# DBType holds the type of db you're connecting to.  The built-in types are
+# "postgres", "mysql", "ddl", which reads the schema from the .sql file or
+# directory of .sql files that ConnStr names, "dbml", which reads it from the
+# DBML file that ConnStr names, and "prisma", which reads it from the Prisma
+# schema file that ConnStr names.  Custom builds of gnorm may register others,
+# which "gnorm drivers" lists.  "plugin:" followed by the path to an executable
+# runs that executable as the driver, see the Driver Plugins docs.
 DBType = "postgres"
 
 # Schemas holds the names of schemas to generate code for.
 Schemas = ["public"]
 
+# ConnectTimeout limits how long gnorm waits to connect to the database, and
+# QueryTimeout limits how long each query made while reading the database may
+# take, so an unresponsive database can't hang gnorm forever.  They're durations
+# like "10s" or "2m", and by default there's no limit.  Note that the mysql
+# driver can't interrupt a query that's already running.  Retries is the number
+# of times to retry reading the database if it fails, waiting one second before
+# the first retry and twice as long before each retry after that.
+# ConnectTimeout = "10s"
+# QueryTimeout = "30s"
+# Retries = 3
+
+# ReadOnly, if true, reads the database (including running Queries) in a
+# read-only transaction on a single connection, so gnorm can't change the
+# database even if the user in ConnStr is allowed to.
+# ReadOnly = true
+
+# Overlays are snapshot files, in JSON or YAML like gnorm dump writes, that are
+# layered in order over the schema read from the database (or --from snapshot).
+# Tables, columns, indexes, and enums they name that don't exist are added, and
+# ones that do are changed, but only in the fields the overlay sets, so you can
+# generate code for a migration that's still in review, or add comments that
+# aren't in the database.  Overlays may leave out Version, and name the columns
+# of indexes with just their Name.
+# Overlays = ["overlay.yaml"]
+
 # PluginDirs a list of paths that will be used for finding plugins.  The list
 # will be traversed in order, looking for a specifically named plugin. The first
 # plugin that is found will be the one used.
 PluginDirs = ["plugins"]
 
+# WasmPlugins maps plugin names to the paths of WebAssembly modules that may be
+# called from templates with the wasm function, e.g. {{wasm "name" "function"
+# .}}.  WebAssembly plugins use the same protocol as regular plugins, but run in
+# a sandbox without access to the filesystem, network, or environment.
+# [WasmPlugins]
+# slug = "plugins/slug.wasm"
+
 # NameConversion defines how the DBName of tables, schemas, and enums are
 # converted into their Name value.  This is a template that may use all the
 # regular functions.  The "." value is the DB name of the item. Thus, to make an
@@ -51,34 +117,131 @@ PluginDirs = ["plugins"]
 # the Name the PascalCase version, you'd use "{{pascal .}}".
 NameConversion = "{{.}}"
 
+# NameConversionCommand, if specified, is a command with arguments that is used
+# instead of NameConversion to convert DBNames into Names.  GNORM writes all the
+# DBNames to the command's stdin, one per line, and the command must write the
+# converted names to stdout, one per line, in the same order.  Environment
+# variables will be expanded.  You cannot set both NameConversion and
+# NameConversionCommand.
+# NameConversionCommand = ["./mynamer"]
+
+# NameConversionFormat is how names are sent to the NameConversionCommand,
+# either "lines", the default, or "json".  With "json", GNORM writes the DBNames
+# as a JSON array of strings, and the command must write the converted names as
+# a JSON array of strings, in the same order.
+# NameConversionFormat = "json"
+
+# LuaScript, if specified, is the path to a lua script that is run over the
+# database data before any templates are rendered.  The script sees the data as
+# the global value db and the Params as the global value params.  It may modify
+# the data, e.g. renaming tables or changing column types, and may add its own
+# values to the Meta map of any schema, table, column, or enum, which templates
+# can then read as e.g. .Table.Meta.audited.  Note that lua lists start at 1.
+# LuaScript = "transform.lua"
+
+# DataHook, if specified, is a command with arguments that is run over the
+# database data after the LuaScript, before any templates are rendered, e.g. to
+# add ownership tags or ACLs from other systems to the Meta maps.  GNORM writes
+# the data to the command's stdin as JSON, in the same form as gnorm preview
+# -format json, and the command must write it back to stdout in the same form.
+# Changes to the Name, Comment, and Meta of schemas, tables, columns, and enums,
+# and to the Type of columns, are kept.  Environment variables will be expanded.
+# DataHook = ["./addmeta"]
+
+# Header, if specified, is a template that is rendered and written at the start
+# of every generated file, so templates don't each need their own boilerplate.
+# It may reference .Version (the version of gnorm), .Schema (the DBName of the
+# schema the file was generated from, empty for DBPaths), .Timestamp (the time
+# the file was generated, only set if HeaderTimestamp is true), and .DoNotEdit
+# (the text "Code generated by gnorm. DO NOT EDIT.", which go tools recognize).
+# Header = "// {{.DoNotEdit}}\n// gnorm {{.Version}}, schema {{.Schema}}\n\n"
+
+# HeaderTimestamp, if true, sets the .Timestamp value passed to Header.  Note
+# that this makes every generated file change each time gnorm is run.
+# HeaderTimestamp = false
+
+# LicenseHeaderFile, if specified, is the path to a file of license text that's
+# written at the start of every generated file, before the Header.  It's written
+# as a comment in the language of each file, picked from its extension, e.g. //
+# for .go files, # for .py files, and <!-- --> for .html files.  Files in
+# languages without comments, like JSON, are left without it.
+# LicenseHeaderFile = "LICENSE_HEADER"
+
+# Queries maps names to SQL queries that are run against the database when it
+# is read, on the same connection.  The rows each query returns are available
+# to all templates as .DB.Queries.name, where each row is a map of column names
+# to values, e.g. {{range .DB.Queries.stats}}{{.relname}}{{end}}.
+# [Queries]
+# stats = "SELECT relname, n_live_tup FROM pg_stat_user_tables"
+
 # IncludeTables is a whitelist of tables to generate data for. Tables not
 # in this list will not be included in data geenrated by gnorm. You cannot
 # set IncludeTables if ExcludeTables is set.  By default, tables will be
 # included in all schemas.  To specify tables for a specific schema only,
-# use the schema.tablenmae format.
+# use the schema.tablenmae format.  Table names may be glob patterns, like
+# "audit_*", or regular expressions surrounded by slashes, like "/^tmp_/".
 IncludeTables = []
 
 # ExcludeTables is a blacklist of tables to ignore while generating data.
 # All tables in a schema that are not in this list will be used for
 # generation. You cannot set ExcludeTables if IncludeTables is set.  By
 # default, tables will be excluded from all schemas.  To specify tables for
-# a specific schema only, use the schema.tablenmae format.
+# a specific schema only, use the schema.tablenmae format.  Patterns may be
+# used as in IncludeTables.
 ExcludeTables = ["xyzzx"]
 
+# ExcludeColumns maps "schema.table", or just "table" for tables of that name in
+# any schema, to lists of columns to leave out of the data generated by gnorm,
+# such as password hashes.  Column names may be patterns, as in IncludeTables.
+# [ExcludeColumns]
+# "public.users" = ["password_hash"]
+# "orders" = ["deleted_*"]
+
+# IncludeExpr is a CEL expression (https://github.com/google/cel-spec) that is
+# evaluated for each table.  Only tables for which it is true will be included
+# in the data generated by gnorm.  The table is available as the value table,
+# with the fields name, schema, type, comment, isView, isInsertable, columns,
+# primaryKeys (lists of column names), foreignKeys (a list of referenced table
+# names), and isJoinTable (true if the table has two foreign keys and no other
+# columns besides primary keys).
+# IncludeExpr = "table.name.startsWith('app_') && !table.isJoinTable"
+
+# ExcludeExpr is a CEL expression like IncludeExpr.  Tables for which it is true
+# will be left out of the data generated by gnorm.  Both expressions are applied
+# after IncludeTables and ExcludeTables.
+# ExcludeExpr = "table.isView"
+
 # PostRun is a command with arguments that is run after each file is generated
 # by GNORM.  It is generally used to reformat the file, but it can be for any
 # use. Environment variables will be expanded, and the special $GNORMFILE
 # environment variable may be used, which will expand to the name of the file
-# that was just generated.
+# that was just generated.  Files whose generated contents are the same as the
+# existing file are not rewritten, and PostRun is not run for them.
 # Example to run goimports on each output file:
 PostRun = ["echo", "$GNORMFILE"]
 
+# PostRunWarnOnly, if true, logs a warning when the PostRun command fails for a
+# file, instead of stopping gnorm with an error.
+# PostRunWarnOnly = true
+
+# PostRunWorkers is the number of PostRun commands run at the same time, which
+# speeds up slow formatters on large projects.  By default PostRun is run for
+# each file, one at a time, right after the file is written.  Output from
+# concurrent commands may be interleaved.
+# PostRunWorkers = 4
+
 # OutputDir is the directory relative to the project root (where the
 # gnorm.toml file is located) in which all the generated files are written
 # to.
 #
 # This defaults to the current working directory i.e the directory in which
-# gnorm.toml is found.
+# gnorm.toml is found.  It may be overridden with gnorm gen --output-dir.
+#
+# Environment variables are expanded in OutputDir, StaticDir, PartialsDir,
+# LuaScript, ConnStrFile, PluginDirs, the template paths of all the output
+# targets, and the string values of Params, so one config file can adapt to
+# different machines and CI, e.g. OutputDir = "$BUILD_DIR/gnorm".  Use $$ for a
+# literal $.
 OutputDir = "gnorm"
 
 # StaticDir is the directory relative to the project root (where the
@@ -89,11 +252,123 @@ OutputDir = "gnorm"
 # OutputDir
 StaticDir = "static"
 
+# StaticTemplates, if true, renders each file in the StaticDir as a template,
+# with the Config and Params, before it's written to the OutputDir.  This lets
+# mostly static files, like a Makefile or a go.mod stub, use a few values from
+# the config.
+# StaticTemplates = true
+
+# StaticDirs are more directories of static files, each copied to its own Dest
+# under the OutputDir.  Include and Exclude are globs
+# (https://golang.org/pkg/path/filepath/#Match) matched against the path of
+# each file relative to Dir, and against its base name.  If Include is set,
+# only the files that match it are copied, and files that match Exclude are
+# never copied.  Templates renders the files as templates, like
+# StaticTemplates.
+# [[StaticDirs]]
+# Dir = "assets"
+# Dest = "web/assets"
+# Include = ["*.css", "*.js"]
+# Exclude = ["*.min.js"]
+# Templates = false
+
 # NoOverwriteGlobs is a list of globs
 # (https://golang.org/pkg/path/filepath/#Match). If a filename matches a glob
 # *and* a file exists with that name, it will not be generated.
 NoOverwriteGlobs = ["*.perm.go"]
 
+# FileMode, if specified, is the octal permissions given to generated files,
+# whatever the umask, e.g. "0644".  It's applied to files that already exist,
+# too.  By default, new files are created with 0600 and existing files keep
+# their permissions.  TableOptions, SchemaOptions, EnumOptions, DBOptions, and
+# each target in TableTemplates etc. can override it with their own FileMode,
+# e.g. "0755" for generated scripts.
+# FileMode = "0644"
+
+# DirMode is the octal permissions given to directories created for generated
+# files, before the umask is applied.  It defaults to "0700".  Existing
+# directories are left alone.
+# DirMode = "0755"
+
+# AllowUnsafeFilenames, if true, lets Filename templates render paths that gnorm
+# would otherwise refuse to write, to protect you from template mistakes:
+# absolute paths, paths outside the OutputDir (using ..), and names that are
+# invalid on Windows, like ones with a colon or named CON.
+# AllowUnsafeFilenames = false
+
+# NullableWrapper, if specified, is a template that gives the type of nullable
+# columns whose database type isn't in NullableTypeMap, by wrapping the type it's
+# mapped to in TypeMap, e.g. "*{{.Type}}" or "sql.Null[{{.Type}}]".  The template
+# may also use .DBType, the column's database type.  Entries in NullableTypeMap
+# take precedence over it.
+# NullableWrapper = "sql.Null[{{.Type}}]"
+
+# PartialsDir is a directory of templates that are shared by all your contents
+# templates.  Each .gotmpl file in the directory is parsed as a template named
+# after the file without its extension, so column_field.gotmpl may be used in
+# any template with {{template "column_field" .}}.  Templates defined in those
+# files are available as well.  For pongo2 and mustache templates, the directory
+# is searched for included templates and partials.
+# PartialsDir = "templates/partials"
+
+# Password describes where to read the database password from, so it can be
+# kept out of this file and your shell history.  The password is used in
+# ConnStr as $GNORM_PASSWORD, e.g. "dbname=mydb user=admin
+# password=$GNORM_PASSWORD".  Set only one of File (a file whose first line is
+# the password), KeyringService and KeyringUser (an entry in the OS keyring), or
+# NetrcMachine (a machine in $NETRC or ~/.netrc).
+# [Password]
+# KeyringService = "gnorm"
+# KeyringUser = "admin"
+
+# Database, if specified, lists several databases to read in one run, so that
+# code for all of them can be generated with a single gnorm gen.  Each has a
+# Name, and its own DBType, ConnStr, and Schemas, which then must not be set at
+# the top level.  ConnStr is expanded the same way as the top level ConnStr.
+# The schemas of all the databases are passed to the templates together, and
+# each schema's .Database is the Name of its database.  Output paths may use
+# {{.Database}} to keep the files of each database apart.  Queries can't be
+# used with Database.
+# [[Database]]
+# Name = "billing"
+# DBType = "postgres"
+# ConnStr = "dbname=billing host=127.0.0.1 sslmode=disable user=admin"
+# Schemas = ["public"]
+#
+# [[Database]]
+# Name = "users"
+# DBType = "mysql"
+# ConnStr = "root:admin@tcp/"
+# Schemas = ["users"]
+
+# SSHTunnel, if specified, is an SSH server (such as a bastion host) that gnorm
+# connects to the database through, for databases that aren't directly
+# reachable.  Gnorm opens the tunnel itself, and $GNORM_TUNNEL in ConnStr is
+# replaced with the local address of the tunnel, e.g.
+# "postgres://admin@$GNORM_TUNNEL/mydb?sslmode=disable".  Host is the SSH
+# server (port 22 by default), and Remote is the host:port of the database as
+# seen from the SSH server.  Authenticate with an unencrypted private key in
+# KeyFile, or set Agent to use the SSH agent at $SSH_AUTH_SOCK.  The server's
+# host key is checked against KnownHostsFile, which defaults to
+# ~/.ssh/known_hosts.
+# [SSHTunnel]
+# Host = "bastion.example.com"
+# User = "deploy"
+# Agent = true
+# Remote = "db.internal:5432"
+
+# TLS, if specified, holds the TLS settings used to connect to the database,
+# which the driver adds to the connection string so you don't have to write
+# them in a driver-specific form.  CAFile holds the certificate authorities used
+# to verify the server (the system's roots are used if it's empty), CertFile and
+# KeyFile are the client certificate and key, ServerName overrides the name the
+# server's certificate is checked against (mysql only), and InsecureSkipVerify
+# disables verifying the server's certificate.  All files are PEM encoded.
+# [TLS]
+# CAFile = "certs/ca.pem"
+# CertFile = "certs/client.pem"
+# KeyFile = "certs/client-key.pem"
+
 # TablePaths is a map of output paths to template paths that tells Gnorm how to
 # render and output its table info and where to save that output.  Each template
 # will be rendered with each table in turn and written out to the given output
@@ -105,6 +380,13 @@ NoOverwriteGlobs = ["*.perm.go"]
 # rendered.  For example, "{{.Schema}}/{{.Table}}/{{.Table}}.go" =
 # "tables.gotmpl" would render tables.gotmpl template with data from the the
 # "public.users" table to ./public/users/users.go.
+#
+# Note that .Schema and .Table are the Names as strings, not the schema and
+# table themselves, e.g. "{{.Schema}}/{{snake .Table}}/model.go".  Output paths
+# of all kinds may also reference .Data, which is the same data the template is
+# rendered with, for anything else, e.g. "{{.Data.Table.Schema.DBName}}/{{snake
+# .Data.Table.DBName}}/model.go".  Any directories in the output path are
+# created as needed.
 [TablePaths]
 "{{.Schema}}/tables/{{.Table}}.go" = "testdata/table.tpl"
 
@@ -138,13 +420,76 @@ NoOverwriteGlobs = ["*.perm.go"]
 [EnumPaths]
 "{{.Schema}}/enums/{{.Enum}}.go" = "testdata/enum.tpl"
 
+# DBPaths is a map of output paths to template paths that tells Gnorm how to
+# render and output the data for the whole database.  Each template is rendered
+# once, with all the schemas, and written out to the given output path.  This is
+# useful for files that span schemas, like a registry of all tables.  If no
+# pairs are specified, no whole-database files will be rendered.
+# [DBPaths]
+# "registry.go" = "templates/registry.gotmpl"
+
+# TableOptions, SchemaOptions, EnumOptions, and DBOptions hold settings that
+# apply to all the TablePaths, SchemaPaths, EnumPaths, and DBPaths targets
+# respectively.
+#
+# Engine is the name of the built-in template engine used to render the
+# templates for those targets.  It overrides the TemplateEngine Name, and the
+# TemplateEngine CommandLine, if set.
+#
+# TrimBlankLines removes blank lines from the start and end of the rendered
+# output, and CollapseBlankLines replaces each run of consecutive blank lines
+# with a single blank line, so your templates don't have to juggle whitespace.
+# Neither has an effect on output rendered by an external TemplateEngine
+# CommandLine.
+#
+# Format formats the rendered output as Go source, in process, so you don't
+# need to run gofmt with PostRun.  It may be "gofmt", or "goimports", which also
+# adds missing imports and removes unused ones.  Like TrimBlankLines, it has no
+# effect on output rendered by an external TemplateEngine CommandLine.
+#
+# Params are merged over the top-level Params for those targets, so values only
+# some targets need don't have to be passed to all of them.  Nested tables are
+# merged key by key, and any other value replaces the one it overrides.
+# [TableOptions]
+# Engine = "pongo2"
+# TrimBlankLines = true
+# CollapseBlankLines = true
+# Format = "goimports"
+# [TableOptions.Params]
+# package = "models"
+
+# TableTemplates, SchemaTemplates, EnumTemplates, and DBTemplates are lists of
+# output targets that are rendered in addition to the TablePaths, SchemaPaths,
+# EnumPaths, and DBPaths targets respectively.  Each target has a Template (the
+# path to the contents template) and a Filename (the template for the output
+# path, which may reference the same values as the keys of the Paths maps).
+# Unlike the Paths maps, targets in these lists are rendered in order, and each
+# may set its own Engine, TrimBlankLines, CollapseBlankLines, Format, FileMode,
+# and Params options.  A target's Params are merged over the Params for its
+# type.
+# [[TableTemplates]]
+# Template = "templates/model.gotmpl"
+# Filename = "{{.Schema}}/{{.Table}}/model.go"
+#
+# [[TableTemplates]]
+# Template = "templates/queries.gotmpl"
+# Filename = "{{.Schema}}/{{.Table}}/queries.go"
+# TrimBlankLines = true
+# [TableTemplates.Params]
+# package = "queries"
+
 # TypeMap is a mapping of database type names to replacement type names
 # (generally types from your language for deserialization), specifically for
 # database columns that are nullable.  In the data sent to your template, this
 # is the mapping that translates Column.DBType into Column.Type.  If a DBType is
 # not in this mapping, Column.Type will be an empty string.  Note that because
 # of the way tables in TOML work, TypeMap and NullableTypeMap must be at the end
-# of your configuration file.
+# of your configuration file.  Keys surrounded by slashes are regular
+# expressions that must match the whole DBType, and their values may use the
+# expression's capture groups, e.g. "/varchar\\(\\d+\\)/" = "string" or
+# "/(\\w+)\\[\\]/" = "[]$1".  Exact keys are checked first.  When several
+# expressions match, the longest one wins, and expressions of the same length
+# are tried in sorted order.
 # Example for mapping postgres types to Go types:
 [TypeMap]
 "timestamp with time zone" = "time.Time"
@@ -172,11 +517,30 @@ NoOverwriteGlobs = ["*.perm.go"]
 "integer" = "sql.NullInt64"
 "numeric" = "sql.NullFloat64"
 
+# ColumnTypes maps "schema.table.column" to the type of that column, for one-off
+# columns that need a different type than their database type is mapped to,
+# such as a text column that holds json.  It takes precedence over TypeMap,
+# NullableTypeMap, and the type maps in Schema.  Like TypeMap, this must be at
+# the end of your configuration file.
+# [ColumnTypes]
+# "public.events.payload" = "json.RawMessage"
+
+# Schema holds TypeMaps and NullableTypeMaps that apply only to the tables of a
+# single schema, or to a single table in that schema.  Their entries override
+# the same types in TypeMap and NullableTypeMap, so the same database type may
+# map to different types in different parts of your database.  Like TypeMap,
+# these must be at the end of your configuration file.
+# [Schema.billing.TypeMap]
+# "numeric" = "decimal.Decimal"
+# [Schema.billing.Table.invoices.NullableTypeMap]
+# "numeric" = "decimal.NullDecimal"
 
 # Params contains any data you may want to pass to your templates.  This is a
 # good way to make templates reusable with different configuration values for
 # different situations.  The values in this field will be available in the
-# .Params value for all templates.
+# .Params value for all templates.  The Params of TableOptions etc. and of
+# TableTemplates etc. are merged over these, so shared values like a module path
+# only need to be set here.
 [Params]
 mySpecialValue = "some value"
 
@@ -184,6 +548,14 @@ mySpecialValue = "some value"
 # render your templates, allowing you to use your preferred templating
 # engine.  If not specified, go's text/template will be used to render.
 # [TemplateEngine]
+    # Name is the name of the built-in template engine used to render your
+    # templates.  The possible values are "text/template" (the default),
+    # "pongo2", which uses Jinja/Django-style templates, and "mustache", which
+    # uses logic-less mustache templates.  Handlebars helpers and blocks such
+    # as {{#each}} and {{#if}} are not supported.  You cannot set both Name and
+    # CommandLine.
+    # Name = "pongo2"
+
     # CommandLine is the command to run to render the template.  You may
     # pass the following variables to the command line -
     # {{.Data}} the name of a .json file containing the gnorm data serialized into json
@@ -0,0 +1,160 @@
+package cli
+
+import (
+	_ "embed"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+)
+
+// configSource is the source of config.go, which holds the documentation of
+// each config field used as its description in the schema.
+//
+//go:embed config.go
+var configSource string
+
+// configSchema returns a JSON Schema describing the config file, built from the
+// Config type, so that editors can offer completion for it and CI can check
+// it.
+func configSchema() map[string]interface{} {
+	docs := fieldDocs(configSource)
+	schema := typeSchema(reflect.TypeOf(Config{}), "Config", docs)
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = "gnorm configuration"
+	return schema
+}
+
+// typeSchema returns the schema for values of type t.  Structs are described
+// by their fields, documented with the docs of name + "." + the field name.
+func typeSchema(t reflect.Type, name string, docs map[string]string) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem(), name, docs)
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": typeSchema(t.Elem(), elemName(t.Elem(), name), docs),
+		}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": typeSchema(t.Elem(), elemName(t.Elem(), name), docs),
+		}
+	case reflect.Struct:
+		props := map[string]interface{}{}
+		addFields(props, t, name, docs)
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           props,
+			"additionalProperties": false,
+		}
+	}
+	// interface values may be anything.
+	return map[string]interface{}{}
+}
+
+// addFields adds the schemas of the exported fields of the struct type t to
+// props.  The fields of embedded structs are added as if they were fields of t.
+func addFields(props map[string]interface{}, t reflect.Type, name string, docs map[string]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		if f.Anonymous {
+			addFields(props, f.Type, f.Type.Name(), docs)
+			continue
+		}
+		fname := name + "." + f.Name
+		s := typeSchema(f.Type, elemName(f.Type, fname), docs)
+		if doc := docs[fname]; doc != "" {
+			s["description"] = doc
+		}
+		props[f.Name] = s
+	}
+}
+
+// elemName returns the name used to look up the docs of the fields of t, which
+// is the name of t if it's a named type, or the name of the field it's the type
+// of if it's not.
+func elemName(t reflect.Type, field string) string {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Name() != "" {
+		return t.Name()
+	}
+	return field
+}
+
+// fieldDocs parses the go source src and returns the doc comments of the
+// fields of the struct types declared in it, keyed by "Type.Field".  Fields of
+// anonymous struct types are keyed by "Type.Field.Field".  Fields without a doc
+// comment on the line right after another field share that field's doc, since
+// that's how related fields are documented together.
+func fieldDocs(src string) map[string]string {
+	docs := map[string]string{}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "config.go", src, parser.ParseComments)
+	if err != nil {
+		// the source is embedded from this package, so this can't happen.
+		panic(err)
+	}
+	var walk func(st *ast.StructType, name string)
+	walk = func(st *ast.StructType, name string) {
+		prevDoc, prevLine := "", 0
+		for _, field := range st.Fields.List {
+			doc := commentText(field.Doc)
+			if doc == "" && fset.Position(field.Pos()).Line == prevLine+1 {
+				doc = prevDoc
+			}
+			prevDoc, prevLine = doc, fset.Position(field.End()).Line
+			for _, n := range field.Names {
+				docs[name+"."+n.Name] = doc
+				if inner, ok := field.Type.(*ast.StructType); ok {
+					walk(inner, name+"."+n.Name)
+				}
+			}
+		}
+	}
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts, ok := spec.(*ast.TypeSpec)
+			if !ok {
+				continue
+			}
+			if st, ok := ts.Type.(*ast.StructType); ok {
+				walk(st, ts.Name.Name)
+			}
+		}
+	}
+	return docs
+}
+
+// commentText returns the text of the comment with the lines of each paragraph
+// joined.
+func commentText(c *ast.CommentGroup) string {
+	if c == nil {
+		return ""
+	}
+	paras := strings.Split(strings.TrimSpace(c.Text()), "\n\n")
+	for i, p := range paras {
+		paras[i] = strings.Replace(p, "\n", " ", -1)
+	}
+	return strings.Join(paras, "\n\n")
+}
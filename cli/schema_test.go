@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestConfigSchema(t *testing.T) {
+	// round trip through json to check the schema is what the command prints.
+	b, err := json.Marshal(configSchema())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema struct {
+		Properties map[string]struct {
+			Type        string
+			Description string
+			Properties  map[string]struct {
+				Type        string
+				Description string
+			}
+		}
+	}
+	if err := json.Unmarshal(b, &schema); err != nil {
+		t.Fatal(err)
+	}
+	typ := reflect.TypeOf(Config{})
+	for i := 0; i < typ.NumField(); i++ {
+		name := typ.Field(i).Name
+		p, ok := schema.Properties[name]
+		if !ok {
+			t.Fatalf("expected a property for %s, but there isn't one", name)
+		}
+		if p.Description == "" {
+			t.Errorf("expected a description for %s, but it's empty", name)
+		}
+	}
+	if typ := schema.Properties["Retries"].Type; typ != "integer" {
+		t.Fatalf("expected Retries to be an integer, but got %q", typ)
+	}
+	name := schema.Properties["TemplateEngine"].Properties["Name"]
+	if name.Type != "string" || name.Description == "" {
+		t.Fatalf("expected a documented string for TemplateEngine.Name, but got %#v", name)
+	}
+	opts := schema.Properties["TableOptions"].Properties["Engine"]
+	if opts.Type != "string" || opts.Description == "" {
+		t.Fatalf("expected a documented string for TableOptions.Engine, but got %#v", opts)
+	}
+}
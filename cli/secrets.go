@@ -0,0 +1,250 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	keyring "github.com/zalando/go-keyring"
+)
+
+// passwordVar is the name of the variable in ConnStr that is replaced with the
+// password read from the source given in the Password section of the config.
+const passwordVar = "GNORM_PASSWORD"
+
+// Password describes where to read the database password from, so that it
+// doesn't have to be written in the config file or set in the environment.
+// Only one of the sources may be set.
+type Password struct {
+	// File is the path to a file whose first line is the password.
+	File string
+
+	// KeyringService and KeyringUser identify the password in the OS keyring
+	// (the macOS Keychain, the Secret Service on Linux, or the Windows
+	// Credential Manager).
+	KeyringService string
+	KeyringUser    string
+
+	// NetrcMachine is the machine name whose password is read from the netrc
+	// file, which is $NETRC if set, or ~/.netrc otherwise.
+	NetrcMachine string
+}
+
+// isSet reports whether any password source is configured.
+func (p Password) isSet() bool {
+	return p.File != "" || p.KeyringService != "" || p.NetrcMachine != ""
+}
+
+// lookup reads the password from the configured source.
+func (p Password) lookup() (string, error) {
+	sources := 0
+	for _, s := range []string{p.File, p.KeyringService, p.NetrcMachine} {
+		if s != "" {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return "", errors.New("only one of File, KeyringService, and NetrcMachine may be set in Password")
+	}
+	switch {
+	case p.File != "":
+		b, err := ioutil.ReadFile(p.File)
+		if err != nil {
+			return "", errors.WithMessage(err, "can't read password file")
+		}
+		return strings.TrimRight(strings.SplitN(string(b), "\n", 2)[0], "\r"), nil
+	case p.KeyringService != "":
+		pw, err := keyring.Get(p.KeyringService, p.KeyringUser)
+		if err != nil {
+			return "", errors.WithMessage(err, "can't read password for "+p.KeyringService+" from keyring")
+		}
+		return pw, nil
+	case p.NetrcMachine != "":
+		return netrcPassword(p.NetrcMachine)
+	}
+	return "", nil
+}
+
+// netrcPassword returns the password for the given machine from the netrc
+// file.
+func netrcPassword(machine string) (string, error) {
+	path := os.Getenv("NETRC")
+	if path == "" {
+		u, err := user.Current()
+		if err != nil {
+			return "", errors.WithMessage(err, "can't find home directory for .netrc")
+		}
+		path = filepath.Join(u.HomeDir, ".netrc")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", errors.WithMessage(err, "can't open netrc file")
+	}
+	defer f.Close()
+
+	// netrc files are a series of whitespace separated tokens, where each
+	// machine entry is followed by its login, password, etc.
+	scanner := bufio.NewScanner(f)
+	scanner.Split(bufio.ScanWords)
+	var current string
+	for scanner.Scan() {
+		switch scanner.Text() {
+		case "machine":
+			if !scanner.Scan() {
+				break
+			}
+			current = scanner.Text()
+		case "default":
+			current = ""
+		case "password":
+			if !scanner.Scan() {
+				break
+			}
+			if current == machine {
+				return scanner.Text(), nil
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", errors.WithMessage(err, "error reading netrc file")
+	}
+	return "", errors.Errorf("no password for machine %q in %s", machine, path)
+}
+
+// secretSchemes are the prefixes of ConnStr values that refer to a secret in
+// a secrets manager rather than being the connection string itself.
+var secretSchemes = []string{"vault://", "awssm://", "gcpsm://"}
+
+// isSecretRef reports whether s refers to a secret in a secrets manager.
+func isSecretRef(s string) bool {
+	for _, scheme := range secretSchemes {
+		if strings.HasPrefix(s, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// runSecretCommand runs a command line tool that prints a secret, and returns
+// its output.  It's a variable so tests can replace it.
+var runSecretCommand = func(name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.WithMessage(err, fmt.Sprintf("error running %s: %s", name, stderr.String()))
+	}
+	return out, nil
+}
+
+// resolveSecret returns the secret that ref refers to.  ref has one of the
+// following forms, where the optional #key selects a value from a secret
+// that holds a json object:
+//
+//	vault://path#key       read path from Vault's HTTP API at $VAULT_ADDR,
+//	                       using $VAULT_TOKEN (KV version 1 and 2 are supported)
+//	awssm://secret-id#key  read the secret from AWS Secrets Manager with the aws CLI
+//	gcpsm://secret#key     read the latest version of the secret from Google
+//	                       Cloud Secret Manager with the gcloud CLI; secret may
+//	                       also be a full projects/p/secrets/s/versions/v name
+func resolveSecret(env map[string]string, ref string) (string, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "", errors.WithMessage(err, "bad secret reference")
+	}
+	name := u.Host + u.Path
+	key := u.Fragment
+	var secret string
+	switch u.Scheme {
+	case "vault":
+		if key == "" {
+			return "", errors.Errorf("vault secret %q needs a #key", name)
+		}
+		return vaultSecret(env, name, key)
+	case "awssm":
+		out, err := runSecretCommand("aws", "secretsmanager", "get-secret-value", "--secret-id", name, "--query", "SecretString", "--output", "text")
+		if err != nil {
+			return "", err
+		}
+		secret = strings.TrimSpace(string(out))
+	case "gcpsm":
+		args := []string{"secrets", "versions", "access", "latest", "--secret", name}
+		if strings.HasPrefix(name, "projects/") {
+			args = []string{"secrets", "versions", "access", name}
+		}
+		out, err := runSecretCommand("gcloud", args...)
+		if err != nil {
+			return "", err
+		}
+		secret = strings.TrimSpace(string(out))
+	default:
+		return "", errors.Errorf("unknown secret scheme %q", u.Scheme)
+	}
+	if key == "" {
+		return secret, nil
+	}
+	return secretKey(secret, key)
+}
+
+// secretKey returns the value of key in the json object secret.
+func secretKey(secret, key string) (string, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(secret), &m); err != nil {
+		return "", errors.WithMessage(err, "secret with a #key must be a json object")
+	}
+	v, ok := m[key]
+	if !ok {
+		return "", errors.Errorf("no key %q in secret", key)
+	}
+	return fmt.Sprint(v), nil
+}
+
+// vaultSecret reads the value of key from the secret at path in Vault.
+func vaultSecret(env map[string]string, path, key string) (string, error) {
+	addr := env["VAULT_ADDR"]
+	if addr == "" {
+		return "", errors.New("VAULT_ADDR must be set to read secrets from vault")
+	}
+	req, err := http.NewRequest("GET", strings.TrimSuffix(addr, "/")+"/v1/"+path, nil)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	req.Header.Set("X-Vault-Token", env["VAULT_TOKEN"])
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", errors.WithMessage(err, "error reading secret from vault")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("error reading secret %s from vault: %s", path, resp.Status)
+	}
+	var body struct {
+		Data map[string]interface{}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", errors.WithMessage(err, "error decoding secret from vault")
+	}
+	data := body.Data
+	// KV version 2 nests the secret's values in another data object.
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = inner
+		}
+	}
+	v, ok := data[key]
+	if !ok {
+		return "", errors.Errorf("no key %q in vault secret %s", key, path)
+	}
+	return fmt.Sprint(v), nil
+}
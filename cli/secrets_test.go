@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	keyring "github.com/zalando/go-keyring"
+)
+
+func TestPasswordLookup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "password")
+	if err := ioutil.WriteFile(file, []byte("s3cret\nignored\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	netrc := filepath.Join(dir, "netrc")
+	contents := "machine other login bob password nope\nmachine db.example.com\n\tlogin admin\n\tpassword n3trc\n"
+	if err := ioutil.WriteFile(netrc, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("NETRC", os.Getenv("NETRC"))
+	os.Setenv("NETRC", netrc)
+
+	keyring.MockInit()
+	if err := keyring.Set("gnorm", "admin", "k3yring"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		p        Password
+		expected string
+	}{
+		"file":    {Password{File: file}, "s3cret"},
+		"netrc":   {Password{NetrcMachine: "db.example.com"}, "n3trc"},
+		"keyring": {Password{KeyringService: "gnorm", KeyringUser: "admin"}, "k3yring"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := tt.p.lookup()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.expected {
+				t.Fatalf("expected %q, but got %q", tt.expected, got)
+			}
+		})
+	}
+
+	if _, err := (Password{NetrcMachine: "missing"}).lookup(); err == nil {
+		t.Error("expected an error for a machine not in the netrc file, but got nil")
+	}
+	if _, err := (Password{File: file, NetrcMachine: "db.example.com"}).lookup(); err == nil {
+		t.Error("expected an error for more than one password source, but got nil")
+	}
+}
+
+func TestResolveSecret(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "tok" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/db":
+			fmt.Fprint(w, `{"data": {"data": {"dsn": "dbname=v2"}, "metadata": {"version": 1}}}`)
+		case "/v1/kv/db":
+			fmt.Fprint(w, `{"data": {"dsn": "dbname=v1"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	defer func(f func(string, ...string) ([]byte, error)) { runSecretCommand = f }(runSecretCommand)
+	runSecretCommand = func(name string, args ...string) ([]byte, error) {
+		switch name {
+		case "aws":
+			return []byte(`{"dsn": "dbname=aws"}` + "\n"), nil
+		case "gcloud":
+			return []byte(strings.Join(args, " ") + "\n"), nil
+		}
+		return nil, fmt.Errorf("unexpected command %s", name)
+	}
+
+	env := map[string]string{"VAULT_ADDR": srv.URL, "VAULT_TOKEN": "tok"}
+	tests := map[string]string{
+		"vault://secret/data/db#dsn":               "dbname=v2",
+		"vault://kv/db#dsn":                        "dbname=v1",
+		"awssm://prod/db#dsn":                      "dbname=aws",
+		"gcpsm://db":                               "secrets versions access latest --secret db",
+		"gcpsm://projects/p/secrets/db/versions/3": "secrets versions access projects/p/secrets/db/versions/3",
+	}
+	for ref, expected := range tests {
+		got, err := resolveSecret(env, ref)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", ref, err)
+			continue
+		}
+		if got != expected {
+			t.Errorf("%s: expected %q, but got %q", ref, expected, got)
+		}
+	}
+
+	for _, ref := range []string{"vault://secret/data/missing#dsn", "vault://secret/data/db", "awssm://prod/db#nope"} {
+		if _, err := resolveSecret(env, ref); err == nil {
+			t.Errorf("%s: expected an error, but got nil", ref)
+		}
+	}
+}
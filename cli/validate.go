@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run"
+)
+
+// validate fully parses the config file and checks it for problems, the same
+// as lint does.  Unknown keys are reported as problems rather than warnings.
+// If ping is true, it also connects to and reads the database.  Problems in a
+// toml config file are prefixed with the line of the key they're about, if it
+// can be found.
+func validate(ctx context.Context, env environ.Values, file, profile string, ping bool) []string {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return []string{fmt.Sprintf("can't open config file: %v", err)}
+	}
+	var lines map[string]int
+	var problems []string
+	if configFormat(file) == formatTOML {
+		lines = tomlKeyLines(b)
+		md, err := toml.Decode(string(b), &Config{})
+		if err != nil {
+			// toml's errors already include the line.
+			return []string{fmt.Sprintf("%s: %v", file, err)}
+		}
+		for _, k := range md.Undecoded() {
+			problems = append(problems, fmt.Sprintf("%s: unknown key %s", position(file, lines, k.String()), k))
+		}
+	}
+	cfg, err := parseFile(env, file, profile)
+	if err != nil {
+		return append(problems, fmt.Sprintf("%s: %v", position(file, lines, errorKey(lines, err.Error())), err))
+	}
+	problems = append(problems, lint(cfg)...)
+	if ping {
+		if err := run.Ping(ctx, env, cfg); err != nil {
+			problems = append(problems, fmt.Sprintf("database: %v", err))
+		}
+	}
+	return problems
+}
+
+// position returns file:line for the key, or just the file if the line of the
+// key isn't known.
+func position(file string, lines map[string]int, key string) string {
+	// keys in inline tables and arrays of tables aren't found, so use the
+	// line of the closest table we know about.
+	for key != "" {
+		if line, ok := lines[key]; ok {
+			return fmt.Sprintf("%s:%d", file, line)
+		}
+		i := strings.LastIndex(key, ".")
+		if i < 0 {
+			break
+		}
+		key = key[:i]
+	}
+	return file
+}
+
+// errorKey returns the key that the error message msg is about, which is the
+// key whose name appears first in the message.  Keys with the same name in
+// different tables are told apart by preferring the shortest path.  It returns
+// "" if no key is mentioned.
+func errorKey(lines map[string]int, msg string) string {
+	best, bestIdx := "", -1
+	for key := range lines {
+		if !identPath.MatchString(key) {
+			// quoted keys like output paths aren't names the error would use.
+			continue
+		}
+		name := key[strings.LastIndex(key, ".")+1:]
+		loc := regexp.MustCompile(`\b` + name + `\b`).FindStringIndex(msg)
+		if loc == nil {
+			continue
+		}
+		if bestIdx < 0 || loc[0] < bestIdx ||
+			(loc[0] == bestIdx && (len(key) < len(best) || len(key) == len(best) && key < best)) {
+			best, bestIdx = key, loc[0]
+		}
+	}
+	return best
+}
+
+// identPath matches dotted paths made up of plain identifiers.
+var identPath = regexp.MustCompile(`^[A-Za-z_]\w*(\.[A-Za-z_]\w*)*$`)
+
+// tomlKeyLines returns the line each table and key in the toml file b is first
+// defined on, keyed by its dotted path, e.g. "TemplateEngine.Name".  It only
+// understands as much toml as a gnorm config file usually uses, so values that
+// span lines may confuse it, but it's only used to point at errors.
+func tomlKeyLines(b []byte) map[string]int {
+	lines := map[string]int{}
+	table := ""
+	inArray := false
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if inArray {
+			// skip the lines of a multiline array.
+			if strings.HasPrefix(line, "]") {
+				inArray = false
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			table = tomlKey(strings.Trim(line, "[] \t"))
+			if _, ok := lines[table]; !ok {
+				lines[table] = i + 1
+			}
+			continue
+		}
+		eq := strings.Index(line, "=")
+		if eq < 0 {
+			continue
+		}
+		key := tomlKey(line[:eq])
+		if table != "" {
+			key = table + "." + key
+		}
+		if _, ok := lines[key]; !ok {
+			lines[key] = i + 1
+		}
+		value := strings.TrimSpace(line[eq+1:])
+		inArray = strings.HasPrefix(value, "[") && !strings.HasSuffix(value, "]")
+	}
+	return lines
+}
+
+// tomlKey normalizes a (possibly dotted) toml key by removing the quotes and
+// whitespace around each part.
+func tomlKey(s string) string {
+	parts := strings.Split(s, ".")
+	for i, p := range parts {
+		parts[i] = strings.Trim(strings.TrimSpace(p), `"'`)
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	abs, err := filepath.Abs("testdata/table.tpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "gnorm.toml")
+	_, _, env := makeEnv()
+
+	config := `
+DBType = "postgres"
+Schemas = ["public"]
+NameConversion = "{{.}}"
+ConnStr = "dbname=mydb"
+Nonsense = true
+
+[TablePaths]
+"{{.Table}}.go" = "` + abs + `"
+`
+	if err := ioutil.WriteFile(file, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{file + ":6: unknown key Nonsense"}
+	if problems := validate(context.Background(), env, file, "", false); !reflect.DeepEqual(problems, expected) {
+		t.Fatalf("expected problems:\n%q\ngot:\n%q", expected, problems)
+	}
+
+	config = `
+DBType = "postgres"
+Schemas = ["public"]
+NameConversion = "{{.}}"
+ConnStr = "dbname=mydb"
+
+[TemplateEngine]
+Name = "pongo2"
+CommandLine = ["mytool", "{{.Data}}"]
+
+[TablePaths]
+"{{.Table}}.go" = "` + abs + `"
+`
+	if err := ioutil.WriteFile(file, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{file + ":7: both TemplateEngine Name and TemplateEngine CommandLine specified in config"}
+	if problems := validate(context.Background(), env, file, "", false); !reflect.DeepEqual(problems, expected) {
+		t.Fatalf("expected problems:\n%q\ngot:\n%q", expected, problems)
+	}
+}
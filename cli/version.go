@@ -0,0 +1,159 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"gnorm.org/gnorm/environ"
+)
+
+// latestReleaseURL is the GitHub API endpoint that describes the latest
+// release of gnorm.  It's a variable so tests can point it elsewhere.
+var latestReleaseURL = "https://api.github.com/repos/gnormal/gnorm/releases/latest"
+
+// releasesPage is where users can download the latest release.
+const releasesPage = "https://github.com/gnormal/gnorm/releases/latest"
+
+// versionInfo is the output of gnorm version --json.
+type versionInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"builddate"`
+
+	// Latest and UpdateAvailable are only set with --check.  UpdateAvailable
+	// is left out if this build's version can't be compared, such as for
+	// development builds.
+	Latest          string `json:"latest,omitempty"`
+	UpdateAvailable *bool  `json:"update_available,omitempty"`
+}
+
+func versionCmd(env environ.Values) *cobra.Command {
+	var asJSON bool
+	var check bool
+	cmd := &cobra.Command{
+		Use:   "version",
+		Short: "Displays the version of GNORM.",
+		Long: `
+Shows the build date and commit hash used to build this binary.  With --json,
+they're printed as a JSON object with version, commit, and builddate fields, for
+scripts to read.  With --check, gnorm asks GitHub for its latest release and
+reports whether it's newer than this one.  Nothing is sent over the network
+unless --check is given.
+`[1:],
+		RunE: func(cmd *cobra.Command, args []string) error {
+			info := versionInfo{Version: version, Commit: commitHash, BuildDate: timestamp}
+			if check {
+				latest, err := latestRelease(latestReleaseURL)
+				if err != nil {
+					return codeErr{err, 1}
+				}
+				info.Latest = latest
+				if newer, ok := newerVersion(version, latest); ok {
+					info.UpdateAvailable = &newer
+				}
+			}
+			if asJSON {
+				b, err := json.MarshalIndent(info, "", "  ")
+				if err != nil {
+					return codeErr{errors.WithMessage(err, "couldn't convert version to json"), 1}
+				}
+				fmt.Fprintln(env.Stdout, string(b))
+				return nil
+			}
+			fmt.Fprintf(env.Stdout, "version: %s\nbuilt at: %s\ncommit hash: %s", version, timestamp, commitHash)
+			if !check {
+				return nil
+			}
+			fmt.Fprintln(env.Stdout)
+			switch {
+			case info.UpdateAvailable == nil:
+				fmt.Fprintf(env.Stdout, "latest release: %s\n", info.Latest)
+			case *info.UpdateAvailable:
+				fmt.Fprintf(env.Stdout, "A newer version of gnorm is available: %s\nDownload it from %s\n", info.Latest, releasesPage)
+			default:
+				fmt.Fprintln(env.Stdout, "This is the latest version of gnorm.")
+			}
+			return nil
+		},
+		Args: cobra.ExactArgs(0),
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the version info as JSON")
+	cmd.Flags().BoolVar(&check, "check", false, "check whether a newer release of gnorm is available")
+	return cmd
+}
+
+// latestRelease returns the tag of the latest release from the GitHub API
+// endpoint at url.
+func latestRelease(url string) (string, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", errors.WithMessage(err, "can't check for the latest release")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("can't check for the latest release: %s", resp.Status)
+	}
+	var body struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", errors.WithMessage(err, "error decoding latest release")
+	}
+	if body.TagName == "" {
+		return "", errors.New("latest release has no tag")
+	}
+	return body.TagName, nil
+}
+
+// newerVersion reports whether latest is a newer version than current.  Both
+// are release tags like v1.2.3, and current may have the suffix git describe
+// adds to builds after a tag.  ok is false if either can't be parsed, which is
+// the case for development builds.
+func newerVersion(current, latest string) (newer, ok bool) {
+	c, ok := parseVersion(current)
+	if !ok {
+		return false, false
+	}
+	l, ok := parseVersion(latest)
+	if !ok {
+		return false, false
+	}
+	for i := range c {
+		if l[i] != c[i] {
+			return l[i] > c[i], true
+		}
+	}
+	return false, true
+}
+
+// parseVersion returns the major, minor, and patch numbers of a version like
+// v1.2.3, ignoring anything after a dash.
+func parseVersion(v string) ([3]int, bool) {
+	var nums [3]int
+	v = strings.TrimPrefix(strings.SplitN(v, "-", 2)[0], "v")
+	parts := strings.Split(v, ".")
+	if len(parts) > len(nums) {
+		return nums, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nums, false
+		}
+		nums[i] = n
+	}
+	return nums, true
+}
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewerVersion(t *testing.T) {
+	tests := []struct {
+		current, latest string
+		newer, ok       bool
+	}{
+		{"v1.0.0", "v1.0.1", true, true},
+		{"v1.2.0", "v1.10.0", true, true},
+		{"v1.1.0", "v1.1.0", false, true},
+		{"v1.1.0-3-gabcdef", "v1.1.0", false, true},
+		{"v2.0.0", "v1.9.9", false, true},
+		{"v1.1", "v1.1.1", true, true},
+		{"DEV", "v1.0.0", false, false},
+		{"dev", "v1.0.0", false, false},
+		{"v1.0.0", "nightly", false, false},
+	}
+	for _, tt := range tests {
+		newer, ok := newerVersion(tt.current, tt.latest)
+		if newer != tt.newer || ok != tt.ok {
+			t.Errorf("newerVersion(%q, %q) = %v, %v, expected %v, %v", tt.current, tt.latest, newer, ok, tt.newer, tt.ok)
+		}
+	}
+}
+
+func TestVersionCheck(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"tag_name": "v1.2.0", "name": "1.2.0"}`)
+	}))
+	defer srv.Close()
+	defer func(s string) { latestReleaseURL = s }(latestReleaseURL)
+	latestReleaseURL = srv.URL
+	defer func(s string) { version = s }(version)
+	version = "v1.1.0"
+
+	stderr, stdout, env := makeEnv()
+	env.Args = []string{"version", "--json", "--check"}
+	if code := ParseAndRun(env); code != 0 {
+		t.Fatalf("expected code 0, but got %v: %s", code, stderr)
+	}
+	var info versionInfo
+	if err := json.Unmarshal(stdout.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != "v1.1.0" || info.Latest != "v1.2.0" || info.UpdateAvailable == nil || !*info.UpdateAvailable {
+		t.Fatalf("unexpected version info: %s", stdout)
+	}
+
+	stderr, stdout, env = makeEnv()
+	env.Args = []string{"version", "--check"}
+	version = "v1.2.0"
+	if code := ParseAndRun(env); code != 0 {
+		t.Fatalf("expected code 0, but got %v: %s", code, stderr)
+	}
+	if !strings.HasSuffix(stdout.String(), "\nThis is the latest version of gnorm.\n") {
+		t.Fatalf("unexpected output: %s", stdout)
+	}
+
+	// without --check, there's nothing about the latest release.
+	stderr, stdout, env = makeEnv()
+	env.Args = []string{"version", "--json"}
+	if code := ParseAndRun(env); code != 0 {
+		t.Fatalf("expected code 0, but got %v: %s", code, stderr)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(stdout.Bytes(), &fields); err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 3 || fields["version"] != "v1.2.0" {
+		t.Fatalf("expected only version, commit, and builddate, but got %s", stdout)
+	}
+}
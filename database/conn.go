@@ -0,0 +1,192 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Timeouts limit how long drivers wait on the database.  Zero values mean no
+// limit.
+type Timeouts struct {
+	// Connect limits how long connecting to the database may take.
+	Connect time.Duration
+
+	// Query limits how long each query, including reading its results, may
+	// take.
+	Query time.Duration
+}
+
+type (
+	timeoutsKey struct{}
+	readOnlyKey struct{}
+	queriesKey  struct{}
+)
+
+// WithTimeouts returns a copy of ctx that carries t.  Connections opened with
+// Open using the returned context are subject to t.
+func WithTimeouts(ctx context.Context, t Timeouts) context.Context {
+	return context.WithValue(ctx, timeoutsKey{}, t)
+}
+
+// WithReadOnly returns a copy of ctx with which Open makes read-only
+// connections, so that gnorm can't change the database, even if its user is
+// allowed to.
+func WithReadOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readOnlyKey{}, true)
+}
+
+// WithQueries returns a copy of ctx that carries queries, which maps names to
+// SQL queries.  Drivers that support queries run them in Parse, on the same
+// connection they read the schema with, and return their results in
+// Info.Queries.
+func WithQueries(ctx context.Context, queries map[string]string) context.Context {
+	return context.WithValue(ctx, queriesKey{}, queries)
+}
+
+// Queries returns the queries carried by ctx, if any.
+func Queries(ctx context.Context) map[string]string {
+	q, _ := ctx.Value(queriesKey{}).(map[string]string)
+	return q
+}
+
+// queryer holds the methods shared by *sql.DB and *sql.Conn.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// DB wraps a *sql.DB so that every query is made with the context the DB was
+// opened with, limited by the query timeout.  It satisfies the DB interface of
+// the drivers' generated query packages.
+type DB struct {
+	db      *sql.DB
+	q       queryer
+	tx      *sql.Conn // the connection in a read-only transaction, if any
+	ctx     context.Context
+	timeout time.Duration
+
+	mu      sync.Mutex
+	cancels []context.CancelFunc
+}
+
+// Open opens a database using the named database/sql driver, and makes sure it
+// can connect within the connect timeout carried by ctx.  If ctx was made with
+// WithReadOnly, all queries are made on a single connection in a read-only
+// transaction, which is rolled back when the DB is closed.  Drivers use this
+// so that they honor the settings gnorm is configured with.  Note that timeouts
+// can only interrupt queries that are in progress if the database/sql driver
+// supports contexts.
+func Open(ctx context.Context, driver, conn string) (*DB, error) {
+	db, err := sql.Open(driver, conn)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	t, _ := ctx.Value(timeoutsKey{}).(Timeouts)
+	pingCtx := ctx
+	if t.Connect > 0 {
+		var cancel context.CancelFunc
+		pingCtx, cancel = context.WithTimeout(ctx, t.Connect)
+		defer cancel()
+	}
+	if err := db.PingContext(pingCtx); err != nil {
+		db.Close()
+		return nil, errors.WithMessage(err, "error connecting to database")
+	}
+	d := &DB{db: db, q: db, ctx: ctx, timeout: t.Query}
+	if ro, _ := ctx.Value(readOnlyKey{}).(bool); ro {
+		if err := d.beginReadOnly(); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// beginReadOnly starts a read-only transaction on a single connection, which
+// is used for all queries after.  The standard START TRANSACTION statement is
+// used rather than sql.TxOptions, since not all drivers support the latter.
+func (d *DB) beginReadOnly() error {
+	c, err := d.db.Conn(d.ctx)
+	if err != nil {
+		return errors.WithMessage(err, "error connecting to database")
+	}
+	if _, err := c.ExecContext(d.ctx, "START TRANSACTION READ ONLY"); err != nil {
+		c.Close()
+		return errors.WithMessage(err, "error starting read-only transaction")
+	}
+	d.q = c
+	d.tx = c
+	return nil
+}
+
+// queryContext returns the context for a single query.  Its deadline isn't
+// cancelled until the DB is closed, since the rows of a query must be read
+// after the query returns.
+func (d *DB) queryContext() context.Context {
+	if d.timeout <= 0 {
+		return d.ctx
+	}
+	ctx, cancel := context.WithTimeout(d.ctx, d.timeout)
+	d.mu.Lock()
+	d.cancels = append(d.cancels, cancel)
+	d.mu.Unlock()
+	return ctx
+}
+
+// Exec executes a query that doesn't return rows.
+func (d *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return d.q.ExecContext(d.queryContext(), query, args...)
+}
+
+// Query executes a query that returns rows.
+func (d *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return d.q.QueryContext(d.queryContext(), query, args...)
+}
+
+// QueryRow executes a query that returns at most one row.
+func (d *DB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return d.q.QueryRowContext(d.queryContext(), query, args...)
+}
+
+// Check runs f, which makes queries with d, and returns its error.  In a
+// read-only transaction, f is run in a savepoint that's rolled back if f fails,
+// since postgres aborts the whole transaction on an error, which would fail
+// every query made after it.
+func (d *DB) Check(f func() error) error {
+	if d.tx == nil {
+		return f()
+	}
+	if _, err := d.Exec("SAVEPOINT gnorm_check"); err != nil {
+		return errors.WithMessage(err, "error making savepoint")
+	}
+	if err := f(); err != nil {
+		// if this fails too, the next check reports it.
+		d.Exec("ROLLBACK TO SAVEPOINT gnorm_check")
+		return err
+	}
+	if _, err := d.Exec("RELEASE SAVEPOINT gnorm_check"); err != nil {
+		return errors.WithMessage(err, "error releasing savepoint")
+	}
+	return nil
+}
+
+// Close closes the database.
+func (d *DB) Close() error {
+	d.mu.Lock()
+	for _, cancel := range d.cancels {
+		cancel()
+	}
+	d.cancels = nil
+	d.mu.Unlock()
+	if d.tx != nil {
+		// nothing could have been changed, but end the transaction cleanly.
+		d.tx.ExecContext(context.Background(), "ROLLBACK")
+		d.tx.Close()
+	}
+	return d.db.Close()
+}
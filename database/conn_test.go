@@ -0,0 +1,136 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// recordDriver is a database/sql driver that records the statements it's sent.
+// Statements that contain fail, if set, fail, and like postgres, fail every
+// statement after them until the next ROLLBACK.
+type recordDriver struct {
+	stmts []string
+	fail  string
+}
+
+func (d *recordDriver) Open(name string) (driver.Conn, error) {
+	return &recordConn{d: d}, nil
+}
+
+type recordConn struct {
+	d       *recordDriver
+	aborted bool
+}
+
+func (c *recordConn) Prepare(query string) (driver.Stmt, error) { panic("not implemented") }
+func (c *recordConn) Close() error                              { return nil }
+func (c *recordConn) Begin() (driver.Tx, error)                 { panic("not implemented") }
+
+func (c *recordConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.run(query); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (c *recordConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.run(query); err != nil {
+		return nil, err
+	}
+	return &emptyRows{}, nil
+}
+
+func (c *recordConn) run(query string) error {
+	c.d.stmts = append(c.d.stmts, query)
+	switch {
+	case strings.HasPrefix(query, "ROLLBACK"):
+		c.aborted = false
+	case c.aborted:
+		return errors.New("current transaction is aborted")
+	case c.d.fail != "" && strings.Contains(query, c.d.fail):
+		c.aborted = true
+		return errors.New("permission denied")
+	}
+	return nil
+}
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string              { return []string{"x"} }
+func (emptyRows) Close() error                   { return nil }
+func (emptyRows) Next(dest []driver.Value) error { return io.EOF }
+
+var record = &recordDriver{}
+
+func init() {
+	sql.Register("record", record)
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	record.stmts = nil
+	db, err := Open(WithReadOnly(context.Background()), "record", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RunQueries(db, map[string]string{"q": "SELECT 1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"START TRANSACTION READ ONLY", "SELECT 1", "ROLLBACK"}
+	if !reflect.DeepEqual(record.stmts, expected) {
+		t.Fatalf("expected statements %q, but got %q", expected, record.stmts)
+	}
+
+	record.stmts = nil
+	db, err = Open(context.Background(), "record", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RunQueries(db, map[string]string{"q": "SELECT 1"}); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	expected = []string{"SELECT 1"}
+	if !reflect.DeepEqual(record.stmts, expected) {
+		t.Fatalf("expected statements %q, but got %q", expected, record.stmts)
+	}
+}
+
+func TestCheckQueriesReadOnly(t *testing.T) {
+	record.stmts = nil
+	record.fail = "secret"
+	defer func() { record.fail = "" }()
+	db, err := Open(WithReadOnly(context.Background()), "record", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	checks := CheckQueries(db, map[string]string{
+		"c": "SELECT 1 FROM public",
+		"a": "SELECT 1 FROM catalog",
+		"b": "SELECT 1 FROM secret",
+	})
+	if checks["a"] != nil || checks["c"] != nil {
+		t.Errorf("expected only b to fail, but got %v", checks)
+	}
+	if err := checks["b"]; err == nil || err.Error() != "permission denied" {
+		t.Errorf("expected b to fail with permission denied, but got %v", err)
+	}
+	expected := []string{
+		"START TRANSACTION READ ONLY",
+		"SAVEPOINT gnorm_check", "SELECT 1 FROM catalog", "RELEASE SAVEPOINT gnorm_check",
+		"SAVEPOINT gnorm_check", "SELECT 1 FROM secret", "ROLLBACK TO SAVEPOINT gnorm_check",
+		"SAVEPOINT gnorm_check", "SELECT 1 FROM public", "RELEASE SAVEPOINT gnorm_check",
+	}
+	if !reflect.DeepEqual(record.stmts, expected) {
+		t.Fatalf("expected statements %q, but got %q", expected, record.stmts)
+	}
+}
@@ -0,0 +1,127 @@
+package dbml
+
+import (
+	"strings"
+
+	"gnorm.org/gnorm/database/drivers/internal/schemafile"
+)
+
+// lex splits the DBML into tokens, skipping comments.  Newlines are kept,
+// since they end the definitions of columns and enum values.
+func lex(s string) []schemafile.Token {
+	var toks []schemafile.Token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\n':
+			if len(toks) > 0 && toks[len(toks)-1].Kind != schemafile.Newline {
+				toks = append(toks, schemafile.Token{Kind: schemafile.Newline, Text: "\n"})
+			}
+			i++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f':
+			i++
+		case strings.HasPrefix(s[i:], "//"):
+			if end := strings.IndexByte(s[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(s)
+			}
+		case strings.HasPrefix(s[i:], "/*"):
+			if end := strings.Index(s[i+2:], "*/"); end >= 0 {
+				i += end + 4
+			} else {
+				i = len(s)
+			}
+		case strings.HasPrefix(s[i:], "'''"):
+			end := strings.Index(s[i+3:], "'''")
+			if end < 0 {
+				return append(toks, schemafile.Token{Kind: schemafile.String, Text: dedent(s[i+3:])})
+			}
+			toks = append(toks, schemafile.Token{Kind: schemafile.String, Text: dedent(s[i+3 : i+3+end])})
+			i += end + 6
+		case c == '\'':
+			text, n := quoted(s[i:], c)
+			toks = append(toks, schemafile.Token{Kind: schemafile.String, Text: text})
+			i += n
+		case c == '"':
+			text, n := quoted(s[i:], c)
+			toks = append(toks, schemafile.Token{Kind: schemafile.Quoted, Text: text})
+			i += n
+		case c == '`':
+			text, n := quoted(s[i:], c)
+			toks = append(toks, schemafile.Token{Kind: schemafile.Expr, Text: text})
+			i += n
+		case strings.HasPrefix(s[i:], "<>"):
+			toks = append(toks, schemafile.Token{Kind: schemafile.Punct, Text: "<>"})
+			i += 2
+		case schemafile.IsDigit(c):
+			j := i
+			for j < len(s) && (schemafile.IsDigit(s[j]) || s[j] == '.') {
+				j++
+			}
+			toks = append(toks, schemafile.Token{Kind: schemafile.Number, Text: s[i:j]})
+			i = j
+		case schemafile.IsWordByte(c):
+			j := i
+			for j < len(s) && (schemafile.IsWordByte(s[j]) || schemafile.IsDigit(s[j])) {
+				j++
+			}
+			toks = append(toks, schemafile.Token{Kind: schemafile.Word, Text: s[i:j]})
+			i = j
+		default:
+			toks = append(toks, schemafile.Token{Kind: schemafile.Punct, Text: string(c)})
+			i++
+		}
+	}
+	return toks
+}
+
+// quoted returns the text of the quoted string at the start of s, which
+// starts with the quote q, and the length of the string with its quotes.
+// Backslashes escape the character after them.
+func quoted(s string, q byte) (string, int) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+			}
+		case q:
+			return b.String(), i + 1
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), len(s)
+}
+
+// dedent removes the blank lines at the start and end of a multi-line
+// string, and the indentation its lines have in common, as DBML does.
+func dedent(s string) string {
+	lines := strings.Split(s, "\n")
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	indent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	for x, line := range lines {
+		if len(line) >= indent && indent > 0 {
+			lines[x] = line[indent:]
+		}
+		lines[x] = strings.TrimRight(lines[x], " \t\r")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,738 @@
+// Package dbml is a gnorm driver that reads the schema from a DBML file, the
+// language of dbdiagram.io, instead of from a running database.
+package dbml // import "gnorm.org/gnorm/database/drivers/dbml"
+
+import (
+	"context"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"gnorm.org/gnorm/database"
+	"gnorm.org/gnorm/database/drivers"
+	"gnorm.org/gnorm/database/drivers/internal/schemafile"
+	"gnorm.org/gnorm/environ"
+)
+
+// DBML implements drivers.Driver by parsing the Tables, Enums, and Refs of a
+// DBML file.
+type DBML struct{}
+
+func init() {
+	drivers.Register("dbml", DBML{})
+}
+
+// Parse reads the schema from the DBML file that conn names.  Tables and enums
+// whose names aren't qualified by a schema are put in the first of
+// schemaNames, or public if there are none.  Refs become foreign keys on the
+// columns on the many side of the relationship, or on the left side of
+// one-to-one relationships, and many-to-many refs are skipped, since they
+// have no foreign key.  Projects, TableGroups, and sticky notes are ignored.
+func (DBML) Parse(ctx context.Context, log environ.Logger, conn string, schemaNames []string, filterTables func(schema, table string) bool) (*database.Info, error) {
+	if conn == "" {
+		return nil, errors.New("the dbml driver needs ConnStr to be the path of a .dbml file")
+	}
+	b, err := ioutil.ReadFile(conn)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	p := &parser{log: log, defaultSchema: "public", aliases: map[string]*database.Table{}}
+	if len(schemaNames) > 0 {
+		p.defaultSchema = schemaNames[0]
+	}
+	log.Debugf("parsing %s", conn)
+	if err := p.parse(lex(string(b))); err != nil {
+		return nil, errors.WithMessage(err, conn)
+	}
+	if err := p.resolve(); err != nil {
+		return nil, errors.WithMessage(err, conn)
+	}
+	return p.Info(schemaNames, filterTables), nil
+}
+
+// Features reports what Parse reads from the DBML.
+func (DBML) Features() database.Features {
+	return database.Features{
+		Enums:       true,
+		Comments:    true,
+		Indexes:     true,
+		ForeignKeys: true,
+	}
+}
+
+// ref is a relationship between the columns of two tables, which is resolved
+// into a foreign key once all the tables have been read.
+type ref struct {
+	name        string
+	left, right endpoint
+	op          string // >, <, -, or <>
+}
+
+// endpoint is the table and columns at one end of a ref.
+type endpoint struct {
+	schema, table string
+	columns       []string
+}
+
+// columnType is the type of a column as it's written, which is resolved once
+// all the enums have been read.
+type columnType struct {
+	col    *database.Column
+	schema string
+	quoted bool
+}
+
+// parser builds up the schema from the definitions in a DBML file.
+type parser struct {
+	schemafile.Schemas
+	log           environ.Logger
+	defaultSchema string
+	aliases       map[string]*database.Table
+	refs          []ref
+	types         []columnType
+}
+
+// table returns the table with the given name or alias, or nil if there isn't
+// one.
+func (p *parser) table(schema, name string) *database.Table {
+	if t := p.Table(schema, name); t != nil {
+		return t
+	}
+	if schema == p.defaultSchema {
+		return p.aliases[name]
+	}
+	return nil
+}
+
+// parse reads the top level definitions.
+func (p *parser) parse(toks []schemafile.Token) error {
+	c := &cursor{toks: toks}
+	for {
+		c.skipNewlines()
+		if c.done() {
+			return nil
+		}
+		t := c.next()
+		switch {
+		case t.Is("Table"):
+			if err := p.parseTable(c); err != nil {
+				return err
+			}
+		case t.Is("Enum"):
+			if err := p.parseEnum(c); err != nil {
+				return err
+			}
+		case t.Is("Ref"):
+			if err := p.parseRefs(c); err != nil {
+				return err
+			}
+		case t.Is("Project"), t.Is("TableGroup"), t.Is("TablePartial"), t.Is("Note"), t.Is("Records"):
+			p.log.Debugf("skipping %s", t.Text)
+			for !c.done() && !c.peek().IsPunct("{") {
+				c.next()
+			}
+			c.block()
+		default:
+			return errors.Errorf("expected Table, Enum, or Ref, but got %q", t.Text)
+		}
+	}
+}
+
+// name reads a name that may be qualified by a schema.
+func (p *parser) name(c *cursor) (schema, name string, err error) {
+	if !c.peek().IsName() {
+		return "", "", errors.Errorf("expected a name, but got %q", c.peek().Text)
+	}
+	name = c.next().Text
+	schema = p.defaultSchema
+	if c.peek().IsPunct(".") {
+		c.next()
+		if !c.peek().IsName() {
+			return "", "", errors.Errorf("expected a name after %q, but got %q", name, c.peek().Text)
+		}
+		schema, name = name, c.next().Text
+	}
+	return schema, name, nil
+}
+
+func (p *parser) parseTable(c *cursor) error {
+	schema, name, err := p.name(c)
+	if err != nil {
+		return err
+	}
+	t := &database.Table{Name: name, Type: "BASE TABLE", IsInsertable: true}
+	if c.accept("as") {
+		if !c.peek().IsName() {
+			return errors.Errorf("expected an alias for table %s, but got %q", name, c.peek().Text)
+		}
+		p.aliases[c.next().Text] = t
+	}
+	for _, s := range settings(c) {
+		if s.key == "note" {
+			t.Comment = s.text()
+		}
+	}
+	if !c.peek().IsPunct("{") {
+		return errors.Errorf("expected { after table %s, but got %q", name, c.peek().Text)
+	}
+	s := p.Schema(schema, true)
+	s.Tables = append(s.Tables, t)
+	body := &cursor{toks: c.block()}
+	for {
+		body.skipNewlines()
+		if body.done() {
+			return nil
+		}
+		switch {
+		case body.peek().Is("Note") && (body.peekAt(1).IsPunct(":") || body.peekAt(1).IsPunct("{")):
+			body.next()
+			if body.accept(":") {
+				t.Comment = body.next().Text
+			} else {
+				for _, tok := range body.block() {
+					if tok.Kind == schemafile.String {
+						t.Comment = tok.Text
+					}
+				}
+			}
+		case body.peek().Is("indexes") && body.peekAt(1).IsPunct("{"):
+			body.next()
+			if err := p.parseIndexes(s, t, body.block()); err != nil {
+				return errors.WithMessage(err, "table "+name)
+			}
+		case body.peek().IsPunct("~"):
+			p.log.Debugf("skipping the partial %s in table %s", body.peekAt(1).Text, name)
+			body.line()
+		default:
+			if err := p.parseColumn(s, t, &cursor{toks: body.line()}); err != nil {
+				return errors.WithMessage(err, "table "+name)
+			}
+		}
+	}
+}
+
+func (p *parser) parseColumn(s *database.Schema, t *database.Table, c *cursor) error {
+	if !c.peek().IsName() {
+		return errors.Errorf("expected a column name, but got %q", c.peek().Text)
+	}
+	col := &database.Column{Name: c.next().Text, Nullable: true, Ordinal: int64(len(t.Columns) + 1)}
+	quoted := c.peek().Kind == schemafile.Quoted
+	typeSchema, typ, err := p.name(c)
+	if err != nil {
+		return errors.WithMessage(err, "column "+col.Name)
+	}
+	col.Type = typ
+	if c.peek().IsPunct("(") {
+		var nums []int
+		for _, a := range c.parens() {
+			if a.Kind == schemafile.Number {
+				n, _ := strconv.Atoi(a.Text)
+				nums = append(nums, n)
+			}
+		}
+		lower := strings.ToLower(typ)
+		switch {
+		case len(nums) == 2:
+			col.Precision, col.Scale = nums[0], nums[1]
+		case len(nums) == 1 && (lower == "numeric" || lower == "decimal"):
+			col.Precision = nums[0]
+		case len(nums) == 1 && (strings.Contains(lower, "char") || strings.Contains(lower, "binary") || strings.Contains(lower, "bit")):
+			col.Length = nums[0]
+		}
+	}
+	if c.peek().IsPunct("[") && c.peekAt(1).IsPunct("]") {
+		c.next()
+		c.next()
+		col.IsArray = true
+	}
+	t.Columns = append(t.Columns, col)
+	p.types = append(p.types, columnType{col: col, schema: typeSchema, quoted: quoted})
+	for _, set := range settings(c) {
+		switch set.key {
+		case "pk", "primary key":
+			col.IsPrimaryKey = true
+			col.Nullable = false
+			p.index(t, t.Name+"_pkey", []*database.Column{col}, true)
+		case "not null":
+			col.Nullable = false
+		case "null":
+			col.Nullable = true
+		case "unique":
+			p.index(t, "", []*database.Column{col}, true)
+		case "increment":
+			col.HasDefault = true
+		case "note":
+			col.Comment = set.text()
+		case "default":
+			col.Default = set.expr()
+			col.HasDefault = col.Default != "" && !strings.EqualFold(col.Default, "null")
+		case "ref":
+			r, err := p.inlineRef(s, t, col, set.value)
+			if err != nil {
+				return errors.WithMessage(err, "column "+col.Name)
+			}
+			p.refs = append(p.refs, r)
+		}
+	}
+	return nil
+}
+
+// inlineRef reads the ref in the settings of a column, such as > users.id.
+func (p *parser) inlineRef(s *database.Schema, t *database.Table, col *database.Column, toks []schemafile.Token) (ref, error) {
+	c := &cursor{toks: toks}
+	op := c.next()
+	if op.Kind != schemafile.Punct {
+		return ref{}, errors.Errorf("expected <, >, -, or <> in ref, but got %q", op.Text)
+	}
+	right, err := p.endpoint(c)
+	if err != nil {
+		return ref{}, err
+	}
+	left := endpoint{schema: s.Name, table: t.Name, columns: []string{col.Name}}
+	return ref{left: left, right: right, op: op.Text}, nil
+}
+
+func (p *parser) parseIndexes(s *database.Schema, t *database.Table, toks []schemafile.Token) error {
+	c := &cursor{toks: toks}
+	for {
+		c.skipNewlines()
+		if c.done() {
+			return nil
+		}
+		line := &cursor{toks: c.line()}
+		var names []string
+		expr := false
+		switch {
+		case line.peek().IsPunct("("):
+			for _, part := range split(line.parens()) {
+				if len(part) == 1 && part[0].IsName() {
+					names = append(names, part[0].Text)
+				} else {
+					expr = true
+				}
+			}
+		case line.peek().Kind == schemafile.Expr:
+			line.next()
+			expr = true
+		default:
+			names = append(names, line.next().Text)
+		}
+		var name string
+		var unique, pk bool
+		for _, set := range settings(line) {
+			switch set.key {
+			case "name":
+				name = set.text()
+			case "unique":
+				unique = true
+			case "pk":
+				pk = true
+			}
+		}
+		if expr {
+			p.log.Debugf("skipping index %s on an expression in table %s", name, t.Name)
+			continue
+		}
+		var cols []*database.Column
+		for _, n := range names {
+			col := column(t, n)
+			if col == nil {
+				return errors.Errorf("index on column %s, which doesn't exist", n)
+			}
+			cols = append(cols, col)
+		}
+		if pk {
+			for _, col := range cols {
+				col.IsPrimaryKey = true
+				col.Nullable = false
+			}
+			if name == "" {
+				name = t.Name + "_pkey"
+			}
+			unique = true
+		}
+		p.index(t, name, cols, unique)
+	}
+}
+
+// index adds the index to the table, named the way postgres names indexes if
+// it has no name.
+func (p *parser) index(t *database.Table, name string, cols []*database.Column, unique bool) {
+	if name == "" {
+		var names []string
+		for _, col := range cols {
+			names = append(names, col.Name)
+		}
+		suffix := "_idx"
+		if unique {
+			suffix = "_key"
+		}
+		name = t.Name + "_" + strings.Join(names, "_") + suffix
+	}
+	t.Indexes = append(t.Indexes, &database.Index{Name: name, IsUnique: unique, Columns: cols})
+}
+
+func (p *parser) parseEnum(c *cursor) error {
+	schema, name, err := p.name(c)
+	if err != nil {
+		return err
+	}
+	if !c.peek().IsPunct("{") {
+		return errors.Errorf("expected { after enum %s, but got %q", name, c.peek().Text)
+	}
+	e := &database.Enum{Name: name}
+	body := &cursor{toks: c.block()}
+	for {
+		body.skipNewlines()
+		if body.done() {
+			break
+		}
+		line := body.line()
+		if len(line) > 0 && line[0].IsName() {
+			e.Values = append(e.Values, &database.EnumValue{Name: line[0].Text, Value: len(e.Values) + 1})
+		}
+	}
+	s := p.Schema(schema, true)
+	s.Enums = append(s.Enums, e)
+	return nil
+}
+
+// parseRefs reads a Ref, in its short form, Ref name: a.id < b.a_id, or its
+// long form, which has a ref on each line between braces.
+func (p *parser) parseRefs(c *cursor) error {
+	var name string
+	if c.peek().IsName() {
+		name = c.next().Text
+	}
+	if c.accept(":") {
+		return p.parseRef(name, &cursor{toks: c.line()})
+	}
+	if !c.peek().IsPunct("{") {
+		return errors.Errorf("expected : or { after Ref, but got %q", c.peek().Text)
+	}
+	body := &cursor{toks: c.block()}
+	for {
+		body.skipNewlines()
+		if body.done() {
+			return nil
+		}
+		if err := p.parseRef(name, &cursor{toks: body.line()}); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *parser) parseRef(name string, c *cursor) error {
+	left, err := p.endpoint(c)
+	if err != nil {
+		return err
+	}
+	op := c.next()
+	if op.Kind != schemafile.Punct {
+		return errors.Errorf("expected <, >, -, or <> in ref, but got %q", op.Text)
+	}
+	right, err := p.endpoint(c)
+	if err != nil {
+		return err
+	}
+	p.refs = append(p.refs, ref{name: name, left: left, right: right, op: op.Text})
+	return nil
+}
+
+// endpoint reads the table and columns at one end of a ref, written as
+// [schema.]table.column or [schema.]table.(column, column).
+func (p *parser) endpoint(c *cursor) (endpoint, error) {
+	var parts []string
+	var cols []string
+	for {
+		switch {
+		case c.peek().IsName():
+			parts = append(parts, c.next().Text)
+		case c.peek().IsPunct("("):
+			for _, tok := range c.parens() {
+				if tok.IsName() {
+					cols = append(cols, tok.Text)
+				}
+			}
+		default:
+			return endpoint{}, errors.Errorf("expected a column in ref, but got %q", c.peek().Text)
+		}
+		if !c.accept(".") {
+			break
+		}
+	}
+	if cols == nil && len(parts) > 0 {
+		cols = []string{parts[len(parts)-1]}
+		parts = parts[:len(parts)-1]
+	}
+	switch len(parts) {
+	case 1:
+		return endpoint{schema: p.defaultSchema, table: parts[0], columns: cols}, nil
+	case 2:
+		return endpoint{schema: parts[0], table: parts[1], columns: cols}, nil
+	}
+	return endpoint{}, errors.New("expected a ref to name a table and its columns")
+}
+
+// resolve sets the types of columns whose type is an enum, and turns the refs
+// into foreign keys, once all the tables and enums have been read.
+func (p *parser) resolve() error {
+	for _, ct := range p.types {
+		if p.Enum(ct.schema, ct.col.Type) != nil {
+			ct.col.UserDefined = true
+			continue
+		}
+		if !ct.quoted {
+			ct.col.Type = strings.ToLower(ct.col.Type)
+		}
+	}
+	for _, r := range p.refs {
+		fk, pk := r.left, r.right
+		switch r.op {
+		case ">", "-":
+		case "<":
+			fk, pk = pk, fk
+		case "<>":
+			p.log.Debugf("skipping many-to-many ref between %s and %s, which has no foreign key", r.left.table, r.right.table)
+			continue
+		default:
+			return errors.Errorf("unknown ref type %q between %s and %s", r.op, r.left.table, r.right.table)
+		}
+		t := p.table(fk.schema, fk.table)
+		if t == nil {
+			return errors.Errorf("ref from table %s.%s, which doesn't exist", fk.schema, fk.table)
+		}
+		ref := p.table(pk.schema, pk.table)
+		if ref == nil {
+			return errors.Errorf("ref to table %s.%s, which doesn't exist", pk.schema, pk.table)
+		}
+		if len(fk.columns) != len(pk.columns) {
+			return errors.Errorf("ref from %s to %s has different numbers of columns", t.Name, ref.Name)
+		}
+		name := r.name
+		if name == "" {
+			// the name postgres gives foreign keys.
+			name = t.Name + "_" + fk.columns[0] + "_fkey"
+		}
+		for x, n := range fk.columns {
+			col := column(t, n)
+			if col == nil {
+				return errors.Errorf("ref from column %s.%s, which doesn't exist", t.Name, n)
+			}
+			if column(ref, pk.columns[x]) == nil {
+				return errors.Errorf("ref to column %s.%s, which doesn't exist", ref.Name, pk.columns[x])
+			}
+			col.IsForeignKey = true
+			col.ForeignKey = &database.ForeignKey{
+				SchemaName:               fk.schema,
+				TableName:                t.Name,
+				ColumnName:               col.Name,
+				Name:                     name,
+				UniqueConstraintPosition: x + 1,
+				ForeignTableName:         ref.Name,
+				ForeignColumnName:        pk.columns[x],
+			}
+		}
+	}
+	return nil
+}
+
+// column returns the table's column with the given name, or nil if there
+// isn't one.
+func column(t *database.Table, name string) *database.Column {
+	for _, col := range t.Columns {
+		if col.Name == name {
+			return col
+		}
+	}
+	return nil
+}
+
+// setting is one of the settings in brackets after a table, column, or index,
+// such as pk, not null, or note: 'the id'.  The key is in lower case.
+type setting struct {
+	key   string
+	value []schemafile.Token
+}
+
+// text returns the value of the setting as a string.
+func (s setting) text() string {
+	if len(s.value) == 0 {
+		return ""
+	}
+	return s.value[0].Text
+}
+
+// expr returns the value of the setting as a SQL expression, the way the
+// default of a column is written in the database.
+func (s setting) expr() string {
+	var parts []string
+	for _, tok := range s.value {
+		switch tok.Kind {
+		case schemafile.String:
+			parts = append(parts, "'"+strings.Replace(tok.Text, "'", "''", -1)+"'")
+		default:
+			parts = append(parts, tok.Text)
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// settings reads the settings in brackets, if the next token starts them.
+func settings(c *cursor) []setting {
+	if !c.peek().IsPunct("[") {
+		return nil
+	}
+	c.next()
+	start := c.pos
+	for !c.done() && !c.peek().IsPunct("]") {
+		c.next()
+	}
+	toks := c.toks[start:c.pos]
+	c.accept("]")
+	var sets []setting
+	for _, part := range split(toks) {
+		var s setting
+		var words []string
+		x := 0
+		for ; x < len(part) && part[x].Kind == schemafile.Word; x++ {
+			words = append(words, strings.ToLower(part[x].Text))
+		}
+		s.key = strings.Join(words, " ")
+		if x < len(part) && part[x].IsPunct(":") {
+			for _, tok := range part[x+1:] {
+				if tok.Kind != schemafile.Newline {
+					s.value = append(s.value, tok)
+				}
+			}
+		}
+		sets = append(sets, s)
+	}
+	return sets
+}
+
+// cursor walks the tokens of a DBML file.
+type cursor struct {
+	toks []schemafile.Token
+	pos  int
+}
+
+func (c *cursor) done() bool {
+	return c.pos >= len(c.toks)
+}
+
+// peek returns the next token without consuming it, or an empty token at the
+// end.
+func (c *cursor) peek() schemafile.Token {
+	return c.peekAt(0)
+}
+
+// peekAt returns the token n tokens ahead, or an empty token past the end.
+func (c *cursor) peekAt(n int) schemafile.Token {
+	if c.pos+n >= len(c.toks) {
+		return schemafile.Token{Kind: schemafile.Punct}
+	}
+	return c.toks[c.pos+n]
+}
+
+func (c *cursor) next() schemafile.Token {
+	t := c.peek()
+	if !c.done() {
+		c.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it's the given keyword or punctuation.
+func (c *cursor) accept(s string) bool {
+	if t := c.peek(); t.Is(s) || t.IsPunct(s) {
+		c.pos++
+		return true
+	}
+	return false
+}
+
+func (c *cursor) skipNewlines() {
+	for c.peek().Kind == schemafile.Newline {
+		c.pos++
+	}
+}
+
+// line consumes and returns the tokens up to the end of the line, not counting
+// the newlines inside brackets and parentheses.
+func (c *cursor) line() []schemafile.Token {
+	start := c.pos
+	depth := 0
+	for !c.done() {
+		t := c.peek()
+		switch {
+		case t.Kind == schemafile.Newline && depth == 0:
+			toks := c.toks[start:c.pos]
+			c.pos++
+			return toks
+		case t.IsPunct("("), t.IsPunct("["), t.IsPunct("{"):
+			depth++
+		case t.IsPunct(")"), t.IsPunct("]"), t.IsPunct("}"):
+			depth--
+		}
+		c.pos++
+	}
+	return c.toks[start:]
+}
+
+// block consumes a block in braces, if the next token starts one, and returns
+// the tokens inside it.
+func (c *cursor) block() []schemafile.Token {
+	return c.enclosed("{", "}")
+}
+
+// parens consumes a list in parentheses, if the next token starts one, and
+// returns the tokens inside it.
+func (c *cursor) parens() []schemafile.Token {
+	return c.enclosed("(", ")")
+}
+
+func (c *cursor) enclosed(open, close string) []schemafile.Token {
+	if !c.peek().IsPunct(open) {
+		return nil
+	}
+	c.next()
+	start := c.pos
+	depth := 1
+	for !c.done() {
+		t := c.next()
+		switch {
+		case t.IsPunct(open):
+			depth++
+		case t.IsPunct(close):
+			depth--
+			if depth == 0 {
+				return c.toks[start : c.pos-1]
+			}
+		}
+	}
+	return c.toks[start:]
+}
+
+// split splits the tokens on the commas outside of parentheses.
+func split(toks []schemafile.Token) [][]schemafile.Token {
+	if len(toks) == 0 {
+		return nil
+	}
+	var parts [][]schemafile.Token
+	depth, start := 0, 0
+	for x, t := range toks {
+		switch {
+		case t.IsPunct("("):
+			depth++
+		case t.IsPunct(")"):
+			depth--
+		case t.IsPunct(",") && depth == 0:
+			parts = append(parts, toks[start:x])
+			start = x + 1
+		}
+	}
+	return append(parts, toks[start:])
+}
@@ -0,0 +1,232 @@
+package dbml
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gnorm.org/gnorm/database"
+	"gnorm.org/gnorm/environ"
+)
+
+const schema = `
+Project bookstore {
+  database_type: 'PostgreSQL'
+  Note: 'The bookstore.'
+}
+
+// authors write books.
+Table authors as A {
+  id uuid [pk, default: ` + "`uuid_generate_v4()`" + `]
+  name varchar(100) [not null, note: 'the name on the cover']
+  Note: '''
+    People who write
+    books.
+  '''
+}
+
+Table books {
+  id int [pk, increment]
+  author_id uuid [not null, ref: > A.id]
+  isbn "character varying"(32) [not null, unique]
+  booktype book_type [not null, default: 'fiction']
+  price decimal(10, 2)
+  tags text[]
+  /* edition and
+     printing */
+  edition int [default: 1]
+  printing int
+
+  indexes {
+    (edition, printing) [unique, name: 'books_edition']
+    ` + "`lower(isbn)`" + `
+  }
+}
+
+Table reviews {
+  book_id int
+  edition int
+  printing int
+  stars int [not null, note: "1 to 5"]
+}
+
+Enum book_type {
+  fiction
+  "non-fiction" [note: 'true stories']
+}
+
+Ref reviews_book: reviews.(edition, printing) > books.(edition, printing)
+Ref {
+  books.id < reviews.book_id [delete: cascade]
+}
+
+TableGroup store {
+  authors
+  books
+}
+`
+
+func parse(t *testing.T, contents string, schemaNames []string, filterTables func(schema, table string) bool) (*database.Info, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "schema.dbml")
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return DBML{}.Parse(context.Background(), environ.Values{}.Logger(), path, schemaNames, filterTables)
+}
+
+func TestParse(t *testing.T) {
+	info, err := parse(t, schema, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Schemas) != 1 || info.Schemas[0].Name != "public" {
+		t.Fatalf("expected the public schema, but got %#v", info.Schemas)
+	}
+	s := info.Schemas[0]
+	expectedEnums := []*database.Enum{{
+		Name: "book_type",
+		Values: []*database.EnumValue{
+			{Name: "fiction", Value: 1},
+			{Name: "non-fiction", Value: 2},
+		},
+	}}
+	if !reflect.DeepEqual(s.Enums, expectedEnums) {
+		t.Errorf("expected enums %#v, but got %#v", expectedEnums, s.Enums)
+	}
+	if len(s.Tables) != 3 {
+		t.Fatalf("expected 3 tables, but got %d", len(s.Tables))
+	}
+
+	authorsID := &database.Column{Name: "id", Type: "uuid", HasDefault: true, Default: "uuid_generate_v4()", IsPrimaryKey: true, Ordinal: 1}
+	expectedAuthors := &database.Table{
+		Name:         "authors",
+		Type:         "BASE TABLE",
+		Comment:      "People who write\nbooks.",
+		IsInsertable: true,
+		Columns: []*database.Column{
+			authorsID,
+			{Name: "name", Type: "varchar", Length: 100, Comment: "the name on the cover", Ordinal: 2},
+		},
+		Indexes: []*database.Index{
+			{Name: "authors_pkey", IsUnique: true, Columns: []*database.Column{authorsID}},
+		},
+	}
+	if !reflect.DeepEqual(s.Tables[0], expectedAuthors) {
+		t.Errorf("expected authors %#v, but got %#v", expectedAuthors, s.Tables[0])
+	}
+
+	books := s.Tables[1]
+	bookID := &database.Column{Name: "id", Type: "int", HasDefault: true, IsPrimaryKey: true, Ordinal: 1}
+	isbn := &database.Column{Name: "isbn", Type: "character varying", Length: 32, Ordinal: 3}
+	edition := &database.Column{Name: "edition", Type: "int", Nullable: true, HasDefault: true, Default: "1", Ordinal: 7}
+	printing := &database.Column{Name: "printing", Type: "int", Nullable: true, Ordinal: 8}
+	expectedColumns := []*database.Column{
+		bookID,
+		{Name: "author_id", Type: "uuid", Ordinal: 2, IsForeignKey: true, ForeignKey: &database.ForeignKey{
+			SchemaName:               "public",
+			TableName:                "books",
+			ColumnName:               "author_id",
+			Name:                     "books_author_id_fkey",
+			UniqueConstraintPosition: 1,
+			ForeignTableName:         "authors",
+			ForeignColumnName:        "id",
+		}},
+		isbn,
+		{Name: "booktype", Type: "book_type", UserDefined: true, HasDefault: true, Default: "'fiction'", Ordinal: 4},
+		{Name: "price", Type: "decimal", Precision: 10, Scale: 2, Nullable: true, Ordinal: 5},
+		{Name: "tags", Type: "text", IsArray: true, Nullable: true, Ordinal: 6},
+		edition,
+		printing,
+	}
+	if !reflect.DeepEqual(books.Columns, expectedColumns) {
+		for x, c := range books.Columns {
+			t.Logf("column %d: %#v", x, c)
+		}
+		t.Errorf("wrong columns for books")
+	}
+	expectedIndexes := []*database.Index{
+		{Name: "books_pkey", IsUnique: true, Columns: []*database.Column{bookID}},
+		{Name: "books_isbn_key", IsUnique: true, Columns: []*database.Column{isbn}},
+		{Name: "books_edition", IsUnique: true, Columns: []*database.Column{edition, printing}},
+	}
+	if !reflect.DeepEqual(books.Indexes, expectedIndexes) {
+		for x, i := range books.Indexes {
+			t.Logf("index %d: %#v", x, i)
+		}
+		t.Errorf("wrong indexes for books")
+	}
+
+	var fks []database.ForeignKey
+	for _, c := range s.Tables[2].Columns {
+		if c.ForeignKey != nil {
+			fks = append(fks, *c.ForeignKey)
+		}
+	}
+	expectedFKs := []database.ForeignKey{
+		{SchemaName: "public", TableName: "reviews", ColumnName: "book_id", Name: "reviews_book_id_fkey", UniqueConstraintPosition: 1, ForeignTableName: "books", ForeignColumnName: "id"},
+		{SchemaName: "public", TableName: "reviews", ColumnName: "edition", Name: "reviews_book", UniqueConstraintPosition: 1, ForeignTableName: "books", ForeignColumnName: "edition"},
+		{SchemaName: "public", TableName: "reviews", ColumnName: "printing", Name: "reviews_book", UniqueConstraintPosition: 2, ForeignTableName: "books", ForeignColumnName: "printing"},
+	}
+	if !reflect.DeepEqual(fks, expectedFKs) {
+		t.Errorf("expected foreign keys\n%#v\nbut got\n%#v", expectedFKs, fks)
+	}
+	if c := s.Tables[2].Columns[3]; c.Comment != "1 to 5" {
+		t.Errorf("wrong comment on stars: %q", c.Comment)
+	}
+}
+
+func TestParseSchemas(t *testing.T) {
+	info, err := parse(t, `
+Table audit.log {
+  id bigint [pk]
+  user_id bigint [ref: > users.id]
+}
+Table users {
+  id bigint [pk]
+}
+Table sessions {
+  id bigint [pk]
+}
+`, []string{"app", "audit"}, func(schema, table string) bool {
+		return table != "sessions"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range info.Schemas {
+		for _, tbl := range s.Tables {
+			names = append(names, s.Name+"."+tbl.Name)
+		}
+	}
+	// unqualified tables are in the first schema.
+	expected := []string{"app.users", "audit.log"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected tables %q, but got %q", expected, names)
+	}
+	fk := info.Schemas[1].Tables[0].Columns[1].ForeignKey
+	if fk == nil || fk.ForeignTableName != "users" || fk.SchemaName != "audit" {
+		t.Errorf("expected a foreign key to users, but got %#v", fk)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name, dbml string
+	}{
+		{"missing table", "Table a {\n  id int [ref: > b.id]\n}\n"},
+		{"missing column", "Table a {\n  id int [ref: > a.nope]\n}\n"},
+		{"unknown definition", "View a {\n}\n"},
+		{"index on missing column", "Table a {\n  id int\n  indexes {\n    nope\n  }\n}\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := parse(t, test.dbml, nil, nil); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
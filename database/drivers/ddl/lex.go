@@ -0,0 +1,165 @@
+package ddl
+
+import (
+	"strings"
+
+	"gnorm.org/gnorm/database/drivers/internal/schemafile"
+)
+
+// statements splits the SQL into statements, each of which is a list of
+// tokens, skipping comments.  Statements end with semicolons, which aren't
+// included.
+func statements(sql string) [][]schemafile.Token {
+	var stmts [][]schemafile.Token
+	var cur []schemafile.Token
+	for _, t := range lex(sql) {
+		if t.IsPunct(";") {
+			if len(cur) > 0 {
+				stmts = append(stmts, cur)
+			}
+			cur = nil
+			continue
+		}
+		cur = append(cur, t)
+	}
+	if len(cur) > 0 {
+		stmts = append(stmts, cur)
+	}
+	return stmts
+}
+
+// lex splits the SQL into tokens.  It's forgiving: anything it doesn't
+// recognize becomes a punctuation token of one character.
+func lex(s string) []schemafile.Token {
+	var toks []schemafile.Token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+		case strings.HasPrefix(s[i:], "--"), c == '#':
+			if end := strings.IndexByte(s[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(s)
+			}
+		case strings.HasPrefix(s[i:], "/*"):
+			if end := strings.Index(s[i+2:], "*/"); end >= 0 {
+				i += end + 4
+			} else {
+				i = len(s)
+			}
+		case c == '\'':
+			text, n := quoted(s[i:], c)
+			toks = append(toks, schemafile.Token{Kind: schemafile.String, Text: text})
+			i += n
+		case c == '"' || c == '`':
+			text, n := quoted(s[i:], c)
+			toks = append(toks, schemafile.Token{Kind: schemafile.Quoted, Text: text})
+			i += n
+		case strings.HasPrefix(s[i:], "[]"):
+			toks = append(toks, schemafile.Token{Kind: schemafile.Punct, Text: "[]"})
+			i += 2
+		case c == '$' && dollarTag(s[i:]) != "":
+			tag := dollarTag(s[i:])
+			rest := s[i+len(tag):]
+			end := strings.Index(rest, tag)
+			if end < 0 {
+				return append(toks, schemafile.Token{Kind: schemafile.String, Text: rest})
+			}
+			toks = append(toks, schemafile.Token{Kind: schemafile.String, Text: rest[:end]})
+			i += len(tag)*2 + end
+		case strings.HasPrefix(s[i:], "::"):
+			toks = append(toks, schemafile.Token{Kind: schemafile.Punct, Text: "::"})
+			i += 2
+		case schemafile.IsDigit(c):
+			j := i
+			for j < len(s) && (schemafile.IsDigit(s[j]) || s[j] == '.') {
+				j++
+			}
+			toks = append(toks, schemafile.Token{Kind: schemafile.Number, Text: s[i:j]})
+			i = j
+		case schemafile.IsWordByte(c):
+			j := i
+			for j < len(s) && (schemafile.IsWordByte(s[j]) || schemafile.IsDigit(s[j]) || s[j] == '$') {
+				j++
+			}
+			toks = append(toks, schemafile.Token{Kind: schemafile.Word, Text: s[i:j]})
+			i = j
+		default:
+			toks = append(toks, schemafile.Token{Kind: schemafile.Punct, Text: string(c)})
+			i++
+		}
+	}
+	return toks
+}
+
+// quoted returns the value of the string or identifier at the start of s,
+// which is quoted with q, and how many bytes it takes up.  The quote is
+// escaped by doubling it.
+func quoted(s string, q byte) (string, int) {
+	var b strings.Builder
+	i := 1
+	for i < len(s) {
+		if s[i] == q {
+			if i+1 < len(s) && s[i+1] == q {
+				b.WriteByte(q)
+				i += 2
+				continue
+			}
+			return b.String(), i + 1
+		}
+		b.WriteByte(s[i])
+		i++
+	}
+	return b.String(), i
+}
+
+// dollarTag returns the tag, such as $$ or $body$, of the dollar quoted string
+// at the start of s, or "" if there isn't one.
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		if s[i] == '$' {
+			return s[:i+1]
+		}
+		if !schemafile.IsWordByte(s[i]) && !schemafile.IsDigit(s[i]) {
+			return ""
+		}
+	}
+	return ""
+}
+
+// render writes the tokens back out as SQL, such as for the expression of a
+// column's default.
+func render(toks []schemafile.Token) string {
+	var b strings.Builder
+	for x, t := range toks {
+		if x > 0 && space(toks[x-1], t) {
+			b.WriteByte(' ')
+		}
+		switch t.Kind {
+		case schemafile.String:
+			b.WriteString("'" + strings.Replace(t.Text, "'", "''", -1) + "'")
+		case schemafile.Quoted:
+			b.WriteString(`"` + strings.Replace(t.Text, `"`, `""`, -1) + `"`)
+		default:
+			b.WriteString(t.Text)
+		}
+	}
+	return b.String()
+}
+
+// space reports whether there's a space between the tokens when they're
+// rendered.
+func space(prev, next schemafile.Token) bool {
+	switch {
+	case next.IsPunct(")"), next.IsPunct(","), next.IsPunct("."), next.IsPunct("::"), next.IsPunct("[]"):
+		return false
+	case prev.IsPunct("("), prev.IsPunct("."), prev.IsPunct("::"):
+		return false
+	case next.IsPunct("(") && prev.IsName():
+		// function calls
+		return false
+	}
+	return true
+}
@@ -0,0 +1,998 @@
+// Package ddl is a gnorm driver that reads the schema from files of SQL DDL,
+// such as a schema.sql or a directory of migrations, instead of from a running
+// database.
+package ddl // import "gnorm.org/gnorm/database/drivers/ddl"
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"gnorm.org/gnorm/database"
+	"gnorm.org/gnorm/database/drivers"
+	"gnorm.org/gnorm/database/drivers/internal/schemafile"
+	"gnorm.org/gnorm/environ"
+)
+
+// DDL implements drivers.Driver by parsing the CREATE TABLE, CREATE TYPE,
+// CREATE INDEX, ALTER TABLE, ALTER TYPE, COMMENT ON, and DROP statements in
+// .sql files.
+type DDL struct{}
+
+func init() {
+	drivers.Register("ddl", DDL{})
+}
+
+// Parse reads the schema from the .sql file that conn names, or from all the
+// .sql files in the directory that conn names, in the order of the version
+// numbers their names start with, which is the order migration tools run them
+// in.  Down migrations, in files
+// ending in .down.sql or after a "-- +goose Down" line, are skipped.  Tables
+// and types whose names aren't qualified by a schema are put in the first of
+// schemaNames.  Statements that don't change the shape of the schema, such as
+// INSERTs, are ignored.
+func (DDL) Parse(ctx context.Context, log environ.Logger, conn string, schemaNames []string, filterTables func(schema, table string) bool) (*database.Info, error) {
+	files, err := sqlFiles(conn)
+	if err != nil {
+		return nil, err
+	}
+	p := newParser(log, "public")
+	if len(schemaNames) > 0 {
+		p.defaultSchema = schemaNames[0]
+	}
+	for _, name := range files {
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		log.Debugf("parsing %s", name)
+		sql := string(b)
+		if x := strings.Index(sql, "-- +goose Down"); x >= 0 {
+			sql = sql[:x]
+		}
+		for _, stmt := range statements(sql) {
+			if err := p.statement(stmt); err != nil {
+				return nil, errors.WithMessage(err, name)
+			}
+		}
+	}
+	return p.Info(schemaNames, filterTables), nil
+}
+
+// Features reports what Parse reads from the DDL.  Views are read without
+// their columns, since that would take knowing the types of their queries.
+func (DDL) Features() database.Features {
+	return database.Features{
+		Enums:       true,
+		Views:       true,
+		Comments:    true,
+		Indexes:     true,
+		ForeignKeys: true,
+	}
+}
+
+// sqlFiles returns path if it's a file, or the .sql files in it, sorted by
+// version, if it's a directory.
+func sqlFiles(path string) ([]string, error) {
+	if path == "" {
+		return nil, errors.New("the ddl driver needs ConnStr to be the path of a .sql file or a directory of them")
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if !fi.IsDir() {
+		return []string{path}, nil
+	}
+	names, err := filepath.Glob(filepath.Join(path, "*.sql"))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var files []string
+	for _, name := range names {
+		if !strings.HasSuffix(name, ".down.sql") {
+			files = append(files, name)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return migrationLess(filepath.Base(files[i]), filepath.Base(files[j]))
+	})
+	return files, nil
+}
+
+// migrationLess reports whether the migration file named a runs before the one
+// named b.  Names that both start with a version number, such as 2_add.sql and
+// 10_drop.sql, are sorted by the number, so 2 runs before 10.  Otherwise, and
+// for equal versions, they're sorted by name.
+func migrationLess(a, b string) bool {
+	va, vb := migrationVersion(a), migrationVersion(b)
+	if va != "" && vb != "" && va != vb {
+		if len(va) != len(vb) {
+			return len(va) < len(vb)
+		}
+		return va < vb
+	}
+	return a < b
+}
+
+// migrationVersion returns the digits that name starts with, without leading
+// zeros, or "" if it doesn't start with a digit.  Versions are compared as
+// strings, since timestamps and the like can be too long for an int.
+func migrationVersion(name string) string {
+	end := 0
+	for end < len(name) && name[end] >= '0' && name[end] <= '9' {
+		end++
+	}
+	if end == 0 {
+		return ""
+	}
+	v := strings.TrimLeft(name[:end], "0")
+	if v == "" {
+		return "0"
+	}
+	return v
+}
+
+// parser builds up the schema from the statements it's given, in order.
+type parser struct {
+	schemafile.Schemas
+	log           environ.Logger
+	defaultSchema string
+}
+
+func newParser(log environ.Logger, defaultSchema string) *parser {
+	return &parser{log: log, defaultSchema: defaultSchema}
+}
+
+// statement applies the statement to the schema.
+func (p *parser) statement(toks []schemafile.Token) error {
+	c := &cursor{toks: toks}
+	switch {
+	case c.accept("CREATE"):
+		c.accept("OR", "REPLACE")
+		for c.acceptAny("TEMP", "TEMPORARY", "UNLOGGED", "GLOBAL", "LOCAL", "MATERIALIZED", "RECURSIVE") {
+		}
+		switch {
+		case c.accept("TABLE"):
+			return p.createTable(c)
+		case c.accept("TYPE"):
+			return p.createType(c)
+		case c.accept("VIEW"):
+			return p.createView(c)
+		case c.accept("SCHEMA"):
+			c.accept("IF", "NOT", "EXISTS")
+			if c.peek().IsName() {
+				p.Schema(c.next().Text, true)
+			}
+			return nil
+		case c.accept("UNIQUE", "INDEX"):
+			return p.createIndex(c, true)
+		case c.accept("INDEX"):
+			return p.createIndex(c, false)
+		}
+	case c.accept("ALTER", "TABLE"):
+		return p.alterTable(c)
+	case c.accept("ALTER", "TYPE"):
+		return p.alterType(c)
+	case c.accept("COMMENT", "ON"):
+		return p.comment(c)
+	case c.accept("DROP"):
+		return p.drop(c)
+	}
+	p.log.Debugf("skipping statement %s", render(toks))
+	return nil
+}
+
+// name reads a name that may be qualified by a schema.
+func (p *parser) name(c *cursor) (schema, name string, err error) {
+	if !c.peek().IsName() {
+		return "", "", errors.Errorf("expected a name, but got %q", c.peek().Text)
+	}
+	name = c.next().Text
+	schema = p.defaultSchema
+	for c.peek().IsPunct(".") {
+		c.next()
+		if !c.peek().IsName() {
+			return "", "", errors.Errorf("expected a name after %q, but got %q", name, c.peek().Text)
+		}
+		schema, name = name, c.next().Text
+	}
+	return schema, name, nil
+}
+
+func (p *parser) createTable(c *cursor) error {
+	ifNotExists := c.accept("IF", "NOT", "EXISTS")
+	schema, name, err := p.name(c)
+	if err != nil {
+		return err
+	}
+	if ifNotExists && p.Table(schema, name) != nil {
+		p.log.Debugf("skipping table %s.%s, which already exists", schema, name)
+		return nil
+	}
+	if !c.peek().IsPunct("(") {
+		// CREATE TABLE ... AS SELECT, PARTITION OF, and the like.
+		p.log.Debugf("skipping table %s.%s, which isn't defined by its columns", schema, name)
+		return nil
+	}
+	s := p.Schema(schema, true)
+	t := &database.Table{Name: name, Type: "BASE TABLE", IsInsertable: true}
+	s.Tables = append(s.Tables, t)
+	for _, elem := range split(c.parens()) {
+		if err := p.tableElement(s, t, elem); err != nil {
+			return errors.WithMessage(err, "table "+name)
+		}
+	}
+	for !c.done() {
+		// mysql table options, such as COMMENT='...'.
+		if c.accept("COMMENT") {
+			c.accept("=")
+			if c.peek().Kind == schemafile.String {
+				t.Comment = c.next().Text
+			}
+			continue
+		}
+		c.next()
+	}
+	return nil
+}
+
+// tableElement adds a column or constraint from the list in a CREATE TABLE or
+// an ALTER TABLE ADD.
+func (p *parser) tableElement(s *database.Schema, t *database.Table, elem []schemafile.Token) error {
+	c := &cursor{toks: elem}
+	var constraint string
+	if c.accept("CONSTRAINT") {
+		if c.peek().IsName() {
+			constraint = c.next().Text
+		}
+	}
+	switch {
+	case c.accept("PRIMARY", "KEY"):
+		toks := c.parens()
+		for _, col := range p.columns(t, toks) {
+			col.IsPrimaryKey = true
+			col.Nullable = false
+		}
+		p.primaryKeyIndex(t, constraint, toks)
+		return nil
+	case c.accept("FOREIGN", "KEY"):
+		cols := p.columns(t, c.parens())
+		if !c.accept("REFERENCES") {
+			return errors.New("expected REFERENCES after FOREIGN KEY")
+		}
+		return p.references(c, s, t, cols, constraint)
+	case c.acceptAny("UNIQUE"):
+		c.acceptAny("KEY", "INDEX")
+		if c.peek().IsName() {
+			constraint = c.next().Text
+		}
+		p.index(t, constraint, c.parens(), true)
+		return nil
+	case c.acceptAny("KEY", "INDEX"):
+		if c.peek().IsName() {
+			constraint = c.next().Text
+		}
+		p.index(t, constraint, c.parens(), false)
+		return nil
+	case c.acceptAny("CHECK", "EXCLUDE", "FULLTEXT", "SPATIAL", "LIKE"):
+		return nil
+	}
+	if constraint != "" {
+		return nil
+	}
+	return p.column(c, s, t)
+}
+
+// column adds the column defined by the rest of c to the table.
+func (p *parser) column(c *cursor, s *database.Schema, t *database.Table) error {
+	if !c.peek().IsName() {
+		return errors.Errorf("expected a column name, but got %q", c.peek().Text)
+	}
+	col := &database.Column{Name: c.next().Text, Nullable: true, Ordinal: int64(len(t.Columns) + 1)}
+	if err := p.columnType(c, s, t, col); err != nil {
+		return errors.WithMessage(err, "column "+col.Name)
+	}
+	t.Columns = append(t.Columns, col)
+	var constraint string
+	for !c.done() {
+		switch {
+		case c.accept("CONSTRAINT"):
+			if c.peek().IsName() {
+				constraint = c.next().Text
+			}
+			continue
+		case c.accept("NOT", "NULL"):
+			col.Nullable = false
+		case c.accept("NULL"):
+			col.Nullable = true
+		case c.accept("DEFAULT"):
+			col.Default = render(c.until(columnKeywords))
+			col.HasDefault = col.Default != "" && !strings.EqualFold(col.Default, "NULL")
+		case c.accept("PRIMARY", "KEY"):
+			col.IsPrimaryKey = true
+			col.Nullable = false
+			p.primaryKeyIndex(t, constraint, []schemafile.Token{{Kind: schemafile.Quoted, Text: col.Name}})
+		case c.accept("UNIQUE"):
+			c.accept("KEY")
+			p.index(t, constraint, []schemafile.Token{{Kind: schemafile.Quoted, Text: col.Name}}, true)
+		case c.accept("REFERENCES"):
+			if err := p.references(c, s, t, []*database.Column{col}, constraint); err != nil {
+				return err
+			}
+		case c.accept("COMMENT"):
+			if c.peek().Kind == schemafile.String {
+				col.Comment = c.next().Text
+			}
+		case c.accept("GENERATED"):
+			// identity and generated columns get their values from the
+			// database.
+			col.HasDefault = true
+			c.until(generatedEnd)
+		case c.accept("AUTO_INCREMENT"):
+			col.HasDefault = true
+		default:
+			c.next()
+			if c.peek().IsPunct("(") {
+				c.parens()
+			}
+		}
+		constraint = ""
+	}
+	return nil
+}
+
+// columnKeywords start the parts of a column definition after its type.
+var columnKeywords = []string{"GENERATED", "CONSTRAINT", "NOT", "NULL", "DEFAULT", "PRIMARY", "UNIQUE", "REFERENCES", "CHECK", "COLLATE", "COMMENT", "AUTO_INCREMENT", "ON"}
+
+// generatedEnd are the keywords that can follow GENERATED ... AS IDENTITY or
+// GENERATED ... AS (expression), which themselves can hold DEFAULT.
+var generatedEnd = []string{"CONSTRAINT", "NOT", "NULL", "PRIMARY", "UNIQUE", "REFERENCES", "CHECK", "COLLATE", "COMMENT"}
+
+// typeWords are the words that can follow the first word of a type, as in
+// double precision or timestamp with time zone.
+var typeWords = map[string]bool{"PRECISION": true, "VARYING": true, "WITH": true, "WITHOUT": true, "TIME": true, "ZONE": true, "UNSIGNED": true, "ZEROFILL": true}
+
+// aliases maps the postgres shorthand for types to the names postgres reports
+// for them, so that TypeMaps work the same as they do with the postgres driver.
+var aliases = map[string]string{
+	"int2":        "smallint",
+	"int4":        "integer",
+	"int8":        "bigint",
+	"float4":      "real",
+	"float8":      "double precision",
+	"bool":        "boolean",
+	"timestamptz": "timestamp with time zone",
+	"timetz":      "time with time zone",
+}
+
+// serials are the postgres types that are shorthand for an integer column with
+// a default from a sequence.
+var serials = map[string]string{"serial": "integer", "serial4": "integer", "bigserial": "bigint", "serial8": "bigint", "smallserial": "smallint", "serial2": "smallint"}
+
+// columnType reads the column's type, and any length, precision, or array
+// brackets it has.
+func (p *parser) columnType(c *cursor, s *database.Schema, t *database.Table, col *database.Column) error {
+	if !c.peek().IsName() {
+		return errors.Errorf("expected a type, but got %q", c.peek().Text)
+	}
+	quoted := c.peek().Kind == schemafile.Quoted
+	typeSchema, first, _ := p.name(c)
+	if !quoted {
+		first = strings.ToLower(first)
+	}
+	words := []string{first}
+	for {
+		switch {
+		case c.peek().IsPunct("("):
+			args := c.parens()
+			if first == "enum" {
+				// mysql enums belong to their column.
+				e := &database.Enum{Table: t.Name, Name: col.Name}
+				for _, a := range args {
+					if a.Kind == schemafile.String {
+						e.Values = append(e.Values, &database.EnumValue{Name: a.Text, Value: len(e.Values) + 1})
+					}
+				}
+				s.Enums = append(s.Enums, e)
+				continue
+			}
+			var nums []int
+			for _, a := range args {
+				if a.Kind == schemafile.Number {
+					n, _ := strconv.Atoi(a.Text)
+					nums = append(nums, n)
+				}
+			}
+			switch {
+			case len(nums) == 2:
+				col.Precision, col.Scale = nums[0], nums[1]
+			case len(nums) == 1 && (first == "numeric" || first == "decimal"):
+				col.Precision = nums[0]
+			case len(nums) == 1 && (strings.Contains(first, "char") || strings.Contains(first, "binary") || strings.Contains(first, "bit")):
+				col.Length = nums[0]
+			}
+			continue
+		case c.peek().IsPunct("[]"):
+			c.next()
+			col.IsArray = true
+			continue
+		case c.peek().IsPunct("["):
+			// a sized array, such as integer[3].
+			c.next()
+			c.until([]string{"]"})
+			c.accept("]")
+			col.IsArray = true
+			continue
+		case c.accept("ARRAY"):
+			col.IsArray = true
+			continue
+		case c.peek().Kind == schemafile.Word && typeWords[strings.ToUpper(c.peek().Text)]:
+			w := strings.ToLower(c.next().Text)
+			if w != "unsigned" && w != "zerofill" {
+				words = append(words, w)
+			}
+			continue
+		}
+		break
+	}
+	col.Type = strings.Join(words, " ")
+	if typ, ok := aliases[col.Type]; ok {
+		col.Type = typ
+	}
+	if typ, ok := serials[col.Type]; ok {
+		col.Type = typ
+		col.HasDefault = true
+		col.Default = fmt.Sprintf("nextval('%s_%s_seq'::regclass)", t.Name, col.Name)
+	}
+	if p.Enum(typeSchema, words[0]) != nil {
+		col.UserDefined = true
+	}
+	return nil
+}
+
+// references reads the table and columns that a foreign key references, and
+// sets the foreign key of cols.
+func (p *parser) references(c *cursor, s *database.Schema, t *database.Table, cols []*database.Column, name string) error {
+	refSchema, refTable, err := p.name(c)
+	if err != nil {
+		return err
+	}
+	var refCols []string
+	if c.peek().IsPunct("(") {
+		for _, tok := range c.parens() {
+			if tok.IsName() {
+				refCols = append(refCols, tok.Text)
+			}
+		}
+	} else if ref := p.Table(refSchema, refTable); ref != nil {
+		// the primary key is referenced when no columns are given.
+		for _, rc := range ref.Columns {
+			if rc.IsPrimaryKey {
+				refCols = append(refCols, rc.Name)
+			}
+		}
+	}
+	if name == "" {
+		// the name postgres gives foreign keys.
+		name = t.Name + "_" + cols[0].Name + "_fkey"
+	}
+	for x, col := range cols {
+		if x >= len(refCols) {
+			break
+		}
+		col.IsForeignKey = true
+		col.ForeignKey = &database.ForeignKey{
+			SchemaName:               s.Name,
+			TableName:                t.Name,
+			ColumnName:               col.Name,
+			Name:                     name,
+			UniqueConstraintPosition: x + 1,
+			ForeignTableName:         refTable,
+			ForeignColumnName:        refCols[x],
+		}
+	}
+	// skip ON DELETE and the like.
+	for !c.done() && !c.peek().Is("CONSTRAINT") {
+		c.next()
+	}
+	return nil
+}
+
+// primaryKeyIndex adds the unique index that backs the primary key on the
+// columns named in toks, named the way postgres names it if the constraint
+// has no name.
+func (p *parser) primaryKeyIndex(t *database.Table, name string, toks []schemafile.Token) {
+	if name == "" {
+		name = t.Name + "_pkey"
+	}
+	p.index(t, name, toks, true)
+}
+
+// columns returns the table's columns named in toks.
+func (p *parser) columns(t *database.Table, toks []schemafile.Token) []*database.Column {
+	var cols []*database.Column
+	for _, tok := range toks {
+		if !tok.IsName() {
+			continue
+		}
+		for _, col := range t.Columns {
+			if col.Name == tok.Text {
+				cols = append(cols, col)
+			}
+		}
+	}
+	return cols
+}
+
+// index adds an index on the columns named in toks to the table.  Indexes on
+// expressions are skipped, since they don't have columns.
+func (p *parser) index(t *database.Table, name string, toks []schemafile.Token, unique bool) {
+	var cols []*database.Column
+	for _, part := range split(toks) {
+		// skip ASC, DESC, operator classes, and the like.
+		if len(part) == 0 || !part[0].IsName() || (len(part) > 1 && part[1].IsPunct("(")) {
+			p.log.Debugf("skipping index %s on an expression", name)
+			return
+		}
+		cols = append(cols, p.columns(t, part[:1])...)
+	}
+	if len(cols) == 0 {
+		return
+	}
+	if name == "" {
+		// the names postgres gives indexes.
+		var names []string
+		for _, col := range cols {
+			names = append(names, col.Name)
+		}
+		suffix := "_idx"
+		if unique {
+			suffix = "_key"
+		}
+		name = t.Name + "_" + strings.Join(names, "_") + suffix
+	}
+	t.Indexes = append(t.Indexes, &database.Index{Name: name, IsUnique: unique, Columns: cols})
+}
+
+func (p *parser) createType(c *cursor) error {
+	schema, name, err := p.name(c)
+	if err != nil {
+		return err
+	}
+	if !c.accept("AS", "ENUM") {
+		p.log.Debugf("skipping type %s.%s, which isn't an enum", schema, name)
+		return nil
+	}
+	e := &database.Enum{Name: name}
+	for _, tok := range c.parens() {
+		if tok.Kind == schemafile.String {
+			e.Values = append(e.Values, &database.EnumValue{Name: tok.Text, Value: len(e.Values) + 1})
+		}
+	}
+	s := p.Schema(schema, true)
+	s.Enums = append(s.Enums, e)
+	return nil
+}
+
+func (p *parser) createView(c *cursor) error {
+	c.accept("IF", "NOT", "EXISTS")
+	schema, name, err := p.name(c)
+	if err != nil {
+		return err
+	}
+	s := p.Schema(schema, true)
+	s.Tables = append(s.Tables, &database.Table{Name: name, Type: "VIEW", IsView: true})
+	return nil
+}
+
+func (p *parser) createIndex(c *cursor, unique bool) error {
+	c.accept("CONCURRENTLY")
+	c.accept("IF", "NOT", "EXISTS")
+	var name string
+	if !c.peek().Is("ON") {
+		_, n, err := p.name(c)
+		if err != nil {
+			return err
+		}
+		name = n
+	}
+	if !c.accept("ON") {
+		return errors.Errorf("expected ON in index %s", name)
+	}
+	c.accept("ONLY")
+	schema, table, err := p.name(c)
+	if err != nil {
+		return err
+	}
+	if c.accept("USING") {
+		c.next()
+	}
+	t := p.Table(schema, table)
+	if t == nil {
+		return errors.Errorf("index %s is on table %s.%s, which doesn't exist", name, schema, table)
+	}
+	p.index(t, name, c.parens(), unique)
+	return nil
+}
+
+func (p *parser) alterTable(c *cursor) error {
+	c.accept("IF", "EXISTS")
+	c.accept("ONLY")
+	schema, name, err := p.name(c)
+	if err != nil {
+		return err
+	}
+	t := p.Table(schema, name)
+	if t == nil {
+		return errors.Errorf("can't alter table %s.%s, which doesn't exist", schema, name)
+	}
+	s := p.Schema(schema, false)
+	for _, action := range split(c.rest()) {
+		a := &cursor{toks: action}
+		switch {
+		case a.accept("ADD"):
+			a.accept("COLUMN")
+			a.accept("IF", "NOT", "EXISTS")
+			if err := p.tableElement(s, t, a.rest()); err != nil {
+				return errors.WithMessage(err, "table "+name)
+			}
+		case a.accept("DROP"):
+			if a.accept("CONSTRAINT") || a.accept("INDEX") || a.accept("PRIMARY") || a.accept("FOREIGN") {
+				continue
+			}
+			a.accept("COLUMN")
+			a.accept("IF", "EXISTS")
+			if a.peek().IsName() {
+				dropColumn(t, a.next().Text)
+			}
+		case a.accept("RENAME", "COLUMN"), a.accept("RENAME"):
+			if a.peek().Is("TO") {
+				// renaming the table.
+				a.next()
+				if a.peek().IsName() {
+					t.Name = a.next().Text
+				}
+				continue
+			}
+			old := a.next().Text
+			if a.accept("TO") && a.peek().IsName() {
+				for _, col := range t.Columns {
+					if col.Name == old {
+						col.Name = a.next().Text
+						break
+					}
+				}
+			}
+		default:
+			p.log.Debugf("skipping change to table %s.%s: %s", schema, name, render(action))
+		}
+	}
+	return nil
+}
+
+// alterType adds and renames the values of an enum.  Other changes to types,
+// such as to their owner, don't change the shape of the schema.
+func (p *parser) alterType(c *cursor) error {
+	schema, name, err := p.name(c)
+	if err != nil {
+		return err
+	}
+	e := p.Enum(schema, name)
+	if e == nil {
+		p.log.Debugf("skipping change to type %s.%s, which isn't an enum", schema, name)
+		return nil
+	}
+	switch {
+	case c.accept("ADD", "VALUE"):
+		ifNotExists := c.accept("IF", "NOT", "EXISTS")
+		if c.peek().Kind != schemafile.String {
+			return errors.Errorf("expected a value to add to type %s.%s, but got %q", schema, name, c.peek().Text)
+		}
+		value := c.next().Text
+		if enumValue(e, value) >= 0 {
+			if ifNotExists {
+				return nil
+			}
+			return errors.Errorf("type %s.%s already has the value %q", schema, name, value)
+		}
+		at := len(e.Values)
+		if before := c.accept("BEFORE"); before || c.accept("AFTER") {
+			if c.peek().Kind != schemafile.String {
+				return errors.Errorf("expected a value of type %s.%s, but got %q", schema, name, c.peek().Text)
+			}
+			neighbor := c.next().Text
+			at = enumValue(e, neighbor)
+			if at < 0 {
+				return errors.Errorf("type %s.%s has no value %q", schema, name, neighbor)
+			}
+			if !before {
+				at++
+			}
+		}
+		values := append([]*database.EnumValue{}, e.Values[:at]...)
+		values = append(values, &database.EnumValue{Name: value})
+		e.Values = append(values, e.Values[at:]...)
+		// values are numbered by their sort order.
+		for x, v := range e.Values {
+			v.Value = x + 1
+		}
+	case c.accept("RENAME", "VALUE"):
+		if c.peek().Kind != schemafile.String {
+			return errors.Errorf("expected a value of type %s.%s, but got %q", schema, name, c.peek().Text)
+		}
+		old := c.next().Text
+		if !c.accept("TO") || c.peek().Kind != schemafile.String {
+			return errors.Errorf("expected TO and the new name of value %q", old)
+		}
+		x := enumValue(e, old)
+		if x < 0 {
+			return errors.Errorf("type %s.%s has no value %q", schema, name, old)
+		}
+		e.Values[x].Name = c.next().Text
+	default:
+		p.log.Debugf("skipping change to type %s.%s: %s", schema, name, render(c.rest()))
+	}
+	return nil
+}
+
+// enumValue returns the index of the named value in the enum, or -1 if it
+// doesn't have one.
+func enumValue(e *database.Enum, name string) int {
+	for x, v := range e.Values {
+		if v.Name == name {
+			return x
+		}
+	}
+	return -1
+}
+
+// dropColumn removes the column from the table, and from its indexes.
+func dropColumn(t *database.Table, name string) {
+	var cols []*database.Column
+	for _, col := range t.Columns {
+		if col.Name != name {
+			col.Ordinal = int64(len(cols) + 1)
+			cols = append(cols, col)
+		}
+	}
+	t.Columns = cols
+	var indexes []*database.Index
+	for _, idx := range t.Indexes {
+		keep := true
+		for _, col := range idx.Columns {
+			if col.Name == name {
+				keep = false
+			}
+		}
+		if keep {
+			indexes = append(indexes, idx)
+		}
+	}
+	t.Indexes = indexes
+}
+
+func (p *parser) comment(c *cursor) error {
+	switch {
+	case c.accept("TABLE"), c.accept("VIEW"):
+		schema, name, err := p.name(c)
+		if err != nil {
+			return err
+		}
+		if c.accept("IS") && c.peek().Kind == schemafile.String {
+			if t := p.Table(schema, name); t != nil {
+				t.Comment = c.next().Text
+			}
+		}
+	case c.accept("COLUMN"):
+		// the column is qualified by its table, and maybe its schema.
+		var parts []string
+		for c.peek().IsName() {
+			parts = append(parts, c.next().Text)
+			if !c.peek().IsPunct(".") {
+				break
+			}
+			c.next()
+		}
+		if len(parts) < 2 {
+			return errors.New("expected COMMENT ON COLUMN table.column")
+		}
+		schema := p.defaultSchema
+		if len(parts) > 2 {
+			schema = parts[len(parts)-3]
+		}
+		t := p.Table(schema, parts[len(parts)-2])
+		if t != nil && c.accept("IS") && c.peek().Kind == schemafile.String {
+			comment := c.next().Text
+			for _, col := range t.Columns {
+				if col.Name == parts[len(parts)-1] {
+					col.Comment = comment
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (p *parser) drop(c *cursor) error {
+	kind := strings.ToUpper(c.next().Text)
+	c.accept("CONCURRENTLY")
+	c.accept("IF", "EXISTS")
+	for _, part := range split(c.rest()) {
+		pc := &cursor{toks: part}
+		schema, name, err := p.name(pc)
+		if err != nil {
+			return err
+		}
+		s := p.Schema(schema, false)
+		if s == nil {
+			continue
+		}
+		switch kind {
+		case "TABLE", "VIEW":
+			var tables []*database.Table
+			for _, t := range s.Tables {
+				if t.Name != name {
+					tables = append(tables, t)
+				}
+			}
+			s.Tables = tables
+		case "TYPE":
+			var enums []*database.Enum
+			for _, e := range s.Enums {
+				if e.Table != "" || e.Name != name {
+					enums = append(enums, e)
+				}
+			}
+			s.Enums = enums
+		case "INDEX":
+			for _, t := range s.Tables {
+				var indexes []*database.Index
+				for _, idx := range t.Indexes {
+					if idx.Name != name {
+						indexes = append(indexes, idx)
+					}
+				}
+				t.Indexes = indexes
+			}
+		}
+	}
+	return nil
+}
+
+// cursor walks the tokens of a statement.
+type cursor struct {
+	toks []schemafile.Token
+	pos  int
+}
+
+func (c *cursor) done() bool {
+	return c.pos >= len(c.toks)
+}
+
+// peek returns the next token without consuming it, or an empty token at the
+// end.
+func (c *cursor) peek() schemafile.Token {
+	if c.done() {
+		return schemafile.Token{Kind: schemafile.Punct}
+	}
+	return c.toks[c.pos]
+}
+
+func (c *cursor) next() schemafile.Token {
+	t := c.peek()
+	if !c.done() {
+		c.pos++
+	}
+	return t
+}
+
+// accept consumes the given keywords, or punctuation, if the next tokens are
+// them, and reports whether they were.
+func (c *cursor) accept(words ...string) bool {
+	for x, w := range words {
+		if c.pos+x >= len(c.toks) {
+			return false
+		}
+		t := c.toks[c.pos+x]
+		if !t.Is(w) && !t.IsPunct(w) {
+			return false
+		}
+	}
+	c.pos += len(words)
+	return true
+}
+
+// acceptAny consumes the next token if it's one of the keywords.
+func (c *cursor) acceptAny(words ...string) bool {
+	for _, w := range words {
+		if c.accept(w) {
+			return true
+		}
+	}
+	return false
+}
+
+// parens consumes a parenthesized list, if the next token starts one, and
+// returns the tokens inside it.
+func (c *cursor) parens() []schemafile.Token {
+	if !c.peek().IsPunct("(") {
+		return nil
+	}
+	c.next()
+	start := c.pos
+	depth := 1
+	for !c.done() {
+		t := c.next()
+		switch {
+		case t.IsPunct("("):
+			depth++
+		case t.IsPunct(")"):
+			depth--
+			if depth == 0 {
+				return c.toks[start : c.pos-1]
+			}
+		}
+	}
+	return c.toks[start:]
+}
+
+// until consumes tokens up to one of the keywords, or punctuation, outside of
+// parentheses, and returns them.
+func (c *cursor) until(words []string) []schemafile.Token {
+	start := c.pos
+	depth := 0
+	for !c.done() {
+		t := c.peek()
+		if depth == 0 {
+			for _, w := range words {
+				if t.Is(w) || t.IsPunct(w) {
+					return c.toks[start:c.pos]
+				}
+			}
+		}
+		switch {
+		case t.IsPunct("("):
+			depth++
+		case t.IsPunct(")"):
+			depth--
+		}
+		c.next()
+	}
+	return c.toks[start:]
+}
+
+// rest consumes and returns the rest of the tokens.
+func (c *cursor) rest() []schemafile.Token {
+	toks := c.toks[c.pos:]
+	c.pos = len(c.toks)
+	return toks
+}
+
+// split splits the tokens on the commas outside of parentheses.
+func split(toks []schemafile.Token) [][]schemafile.Token {
+	if len(toks) == 0 {
+		return nil
+	}
+	var parts [][]schemafile.Token
+	depth, start := 0, 0
+	for x, t := range toks {
+		switch {
+		case t.IsPunct("("):
+			depth++
+		case t.IsPunct(")"):
+			depth--
+		case t.IsPunct(",") && depth == 0:
+			parts = append(parts, toks[start:x])
+			start = x + 1
+		}
+	}
+	return append(parts, toks[start:])
+}
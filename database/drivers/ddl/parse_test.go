@@ -0,0 +1,354 @@
+package ddl
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"gnorm.org/gnorm/database"
+	"gnorm.org/gnorm/environ"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func parse(t *testing.T, conn string, schemaNames []string) *database.Info {
+	t.Helper()
+	info, err := DDL{}.Parse(context.Background(), environ.Values{}.Logger(), conn, schemaNames, func(string, string) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
+
+func TestParsePostgres(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"001_init.sql": `
+-- the authors and their books.
+CREATE TYPE book_type AS ENUM ('fiction', 'nonfiction');
+
+CREATE TABLE authors (
+	id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+	name text NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS public.books (
+	id serial,
+	author_id uuid NOT NULL REFERENCES authors,
+	isbn character varying(32) NOT NULL UNIQUE,
+	booktype book_type NOT NULL,
+	price numeric(10, 2),
+	tags varchar[],
+	published timestamptz DEFAULT now(),
+	CONSTRAINT books_pkey PRIMARY KEY (id)
+);
+CREATE INDEX books_author_idx ON books USING btree (author_id);
+COMMENT ON TABLE books IS 'Books, by their authors.';
+COMMENT ON COLUMN public.books.isbn IS 'It''s unique.';
+
+INSERT INTO authors (id, name) VALUES ('c0d5bb0e-0f8b-4cfa-9b1b-1a9b8c1e5c3c', 'Ann; of Green Gables');
+`,
+		"002_views.sql": `
+-- +goose Up
+ALTER TABLE books ADD COLUMN pages integer NOT NULL DEFAULT 0, DROP COLUMN tags;
+CREATE VIEW book_authors AS SELECT b.id, a.name FROM books b JOIN authors a ON a.id = b.author_id;
+CREATE TABLE scratch (id int);
+DROP TABLE scratch;
+
+-- +goose Down
+DROP VIEW book_authors;
+ALTER TABLE books DROP COLUMN pages;
+`,
+		"002_views.down.sql": `DROP TABLE books;`,
+	})
+	info := parse(t, dir, []string{"public"})
+	if len(info.Schemas) != 1 || info.Schemas[0].Name != "public" {
+		t.Fatalf("expected the public schema, but got %#v", info.Schemas)
+	}
+	s := info.Schemas[0]
+	expectedEnums := []*database.Enum{{
+		Name: "book_type",
+		Values: []*database.EnumValue{
+			{Name: "fiction", Value: 1},
+			{Name: "nonfiction", Value: 2},
+		},
+	}}
+	if !reflect.DeepEqual(s.Enums, expectedEnums) {
+		t.Errorf("expected enums %#v, but got %#v", expectedEnums, s.Enums)
+	}
+	var names []string
+	for _, tbl := range s.Tables {
+		names = append(names, tbl.Name+" "+tbl.Type)
+	}
+	expectedNames := []string{"authors BASE TABLE", "books BASE TABLE", "book_authors VIEW"}
+	if !reflect.DeepEqual(names, expectedNames) {
+		t.Fatalf("expected tables %q, but got %q", expectedNames, names)
+	}
+
+	authors := s.Tables[0]
+	authorsID := &database.Column{Name: "id", Type: "uuid", HasDefault: true, Default: "uuid_generate_v4()", IsPrimaryKey: true, Ordinal: 1}
+	expectedAuthors := &database.Table{
+		Name:         "authors",
+		Type:         "BASE TABLE",
+		IsInsertable: true,
+		Columns: []*database.Column{
+			authorsID,
+			{Name: "name", Type: "text", Ordinal: 2},
+		},
+		Indexes: []*database.Index{
+			{Name: "authors_pkey", IsUnique: true, Columns: []*database.Column{authorsID}},
+		},
+	}
+	if !reflect.DeepEqual(authors, expectedAuthors) {
+		t.Errorf("expected authors %#v, but got %#v", expectedAuthors, authors)
+	}
+
+	books := s.Tables[1]
+	if books.Comment != "Books, by their authors." {
+		t.Errorf("wrong comment on books: %q", books.Comment)
+	}
+	bookID := &database.Column{Name: "id", Type: "integer", HasDefault: true, Default: "nextval('books_id_seq'::regclass)", IsPrimaryKey: true, Ordinal: 1}
+	authorID := &database.Column{Name: "author_id", Type: "uuid", Ordinal: 2, IsForeignKey: true, ForeignKey: &database.ForeignKey{
+		SchemaName:               "public",
+		TableName:                "books",
+		ColumnName:               "author_id",
+		Name:                     "books_author_id_fkey",
+		UniqueConstraintPosition: 1,
+		ForeignTableName:         "authors",
+		ForeignColumnName:        "id",
+	}}
+	isbn := &database.Column{Name: "isbn", Type: "character varying", Length: 32, Comment: "It's unique.", Ordinal: 3}
+	expectedColumns := []*database.Column{
+		bookID,
+		authorID,
+		isbn,
+		{Name: "booktype", Type: "book_type", UserDefined: true, Ordinal: 4},
+		{Name: "price", Type: "numeric", Precision: 10, Scale: 2, Nullable: true, Ordinal: 5},
+		{Name: "published", Type: "timestamp with time zone", Nullable: true, HasDefault: true, Default: "now()", Ordinal: 6},
+		{Name: "pages", Type: "integer", HasDefault: true, Default: "0", Ordinal: 7},
+	}
+	if !reflect.DeepEqual(books.Columns, expectedColumns) {
+		for x, c := range books.Columns {
+			t.Logf("column %d: %#v", x, c)
+		}
+		t.Errorf("wrong columns for books")
+	}
+	expectedIndexes := []*database.Index{
+		{Name: "books_isbn_key", IsUnique: true, Columns: []*database.Column{isbn}},
+		{Name: "books_pkey", IsUnique: true, Columns: []*database.Column{bookID}},
+		{Name: "books_author_idx", Columns: []*database.Column{authorID}},
+	}
+	if !reflect.DeepEqual(books.Indexes, expectedIndexes) {
+		for x, i := range books.Indexes {
+			t.Logf("index %d: %#v", x, i)
+		}
+		t.Errorf("wrong indexes for books")
+	}
+
+	view := s.Tables[2]
+	if !view.IsView || view.IsInsertable || len(view.Columns) != 0 {
+		t.Errorf("expected a view without columns, but got %#v", view)
+	}
+}
+
+func TestParseMySQL(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"schema.sql": "" +
+			"CREATE TABLE `users` (\n" +
+			"  `id` int(11) unsigned NOT NULL AUTO_INCREMENT,\n" +
+			"  `email` varchar(255) NOT NULL COMMENT 'where to write',\n" +
+			"  `status` enum('active','banned') DEFAULT 'active',\n" +
+			"  `updated` datetime DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,\n" +
+			"  PRIMARY KEY (`id`),\n" +
+			"  UNIQUE KEY `email` (`email`),\n" +
+			"  KEY `updated_idx` (`updated`)\n" +
+			") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='people';\n",
+	})
+	info := parse(t, filepath.Join(dir, "schema.sql"), []string{"app"})
+	if len(info.Schemas) != 1 || len(info.Schemas[0].Tables) != 1 {
+		t.Fatalf("expected one table in schema app, but got %#v", info.Schemas)
+	}
+	s := info.Schemas[0]
+	users := s.Tables[0]
+	if users.Comment != "people" {
+		t.Errorf("wrong comment on users: %q", users.Comment)
+	}
+	id := &database.Column{Name: "id", Type: "int", HasDefault: true, IsPrimaryKey: true, Ordinal: 1}
+	email := &database.Column{Name: "email", Type: "varchar", Length: 255, Comment: "where to write", Ordinal: 2}
+	updated := &database.Column{Name: "updated", Type: "datetime", Nullable: true, HasDefault: true, Default: "CURRENT_TIMESTAMP", Ordinal: 4}
+	expectedColumns := []*database.Column{
+		id,
+		email,
+		{Name: "status", Type: "enum", Nullable: true, HasDefault: true, Default: "'active'", Ordinal: 3},
+		updated,
+	}
+	if !reflect.DeepEqual(users.Columns, expectedColumns) {
+		for x, c := range users.Columns {
+			t.Logf("column %d: %#v", x, c)
+		}
+		t.Errorf("wrong columns for users")
+	}
+	expectedIndexes := []*database.Index{
+		{Name: "users_pkey", IsUnique: true, Columns: []*database.Column{id}},
+		{Name: "email", IsUnique: true, Columns: []*database.Column{email}},
+		{Name: "updated_idx", Columns: []*database.Column{updated}},
+	}
+	if !reflect.DeepEqual(users.Indexes, expectedIndexes) {
+		for x, i := range users.Indexes {
+			t.Logf("index %d: %#v", x, i)
+		}
+		t.Errorf("wrong indexes for users")
+	}
+	expectedEnums := []*database.Enum{{
+		Table: "users",
+		Name:  "status",
+		Values: []*database.EnumValue{
+			{Name: "active", Value: 1},
+			{Name: "banned", Value: 2},
+		},
+	}}
+	if !reflect.DeepEqual(s.Enums, expectedEnums) {
+		t.Errorf("expected enums %#v, but got %#v", expectedEnums, s.Enums)
+	}
+}
+
+func TestParseFilters(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"schema.sql": `
+CREATE SCHEMA audit;
+CREATE TABLE audit.log (id bigint);
+CREATE TABLE users (id bigint);
+CREATE TABLE sessions (id bigint);
+`,
+	})
+	info, err := DDL{}.Parse(context.Background(), environ.Values{}.Logger(), dir, []string{"public", "audit"}, func(schema, table string) bool {
+		return table != "sessions"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range info.Schemas {
+		for _, tbl := range s.Tables {
+			names = append(names, s.Name+"."+tbl.Name)
+		}
+	}
+	// unqualified tables are in the first schema.
+	expected := []string{"public.users", "audit.log"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected tables %q, but got %q", expected, names)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"schema.sql": `CREATE INDEX users_idx ON users (id);`,
+	})
+	_, err := DDL{}.Parse(context.Background(), environ.Values{}.Logger(), dir, nil, nil)
+	if err == nil {
+		t.Fatal("expected an error for an index on a table that doesn't exist")
+	}
+	if _, err := (DDL{}).Parse(context.Background(), environ.Values{}.Logger(), "", nil, nil); err == nil {
+		t.Fatal("expected an error for an empty ConnStr")
+	}
+}
+
+func TestParseMigrationOrder(t *testing.T) {
+	// 10_b.sql sorts before 2_a.sql by name, but runs after it.
+	dir := writeFiles(t, map[string]string{
+		"1_init.sql": `CREATE TABLE users (id bigint);`,
+		"2_a.sql":    `ALTER TABLE users ADD COLUMN name text;`,
+		"10_b.sql":   `ALTER TABLE users RENAME COLUMN name TO full_name;`,
+	})
+	files, err := sqlFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, filepath.Base(f))
+	}
+	expected := []string{"1_init.sql", "2_a.sql", "10_b.sql"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected files in the order %q, but got %q", expected, names)
+	}
+	info := parse(t, dir, nil)
+	cols := info.Schemas[0].Tables[0].Columns
+	if len(cols) != 2 || cols[1].Name != "full_name" {
+		t.Errorf("expected the renamed full_name column, but got %#v", cols)
+	}
+}
+
+func TestMigrationLess(t *testing.T) {
+	names := []string{"schema.sql", "20230102_b.sql", "010_c.sql", "9_d.sql", "20230101_a.sql", "10_a.sql"}
+	sort.Slice(names, func(i, j int) bool { return migrationLess(names[i], names[j]) })
+	// equal versions, and names without one, are sorted by name.
+	expected := []string{"9_d.sql", "010_c.sql", "10_a.sql", "20230101_a.sql", "20230102_b.sql", "schema.sql"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %q, but got %q", expected, names)
+	}
+}
+
+func TestParseCreateTableIfNotExists(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"schema.sql": `
+CREATE TABLE users (id bigint, name text);
+CREATE TABLE IF NOT EXISTS users (id bigint);
+`,
+	})
+	info := parse(t, dir, nil)
+	tables := info.Schemas[0].Tables
+	if len(tables) != 1 {
+		t.Fatalf("expected 1 table, but got %d", len(tables))
+	}
+	if len(tables[0].Columns) != 2 {
+		t.Errorf("expected the first definition of users to be kept, but got %#v", tables[0].Columns)
+	}
+}
+
+func TestParseAlterType(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"schema.sql": `
+CREATE TYPE status AS ENUM ('new', 'done');
+ALTER TYPE status ADD VALUE 'archived';
+ALTER TYPE status ADD VALUE IF NOT EXISTS 'done';
+ALTER TYPE public.status ADD VALUE 'started' BEFORE 'done';
+ALTER TYPE status ADD VALUE 'reviewed' AFTER 'started';
+ALTER TYPE status RENAME VALUE 'new' TO 'created';
+ALTER TYPE status OWNER TO admin;
+`,
+	})
+	info := parse(t, dir, nil)
+	var values []string
+	for x, v := range info.Schemas[0].Enums[0].Values {
+		if v.Value != x+1 {
+			t.Errorf("expected %s to be value %d, but got %d", v.Name, x+1, v.Value)
+		}
+		values = append(values, v.Name)
+	}
+	expected := []string{"created", "started", "reviewed", "done", "archived"}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("expected values %q, but got %q", expected, values)
+	}
+
+	dir = writeFiles(t, map[string]string{
+		"schema.sql": `
+CREATE TYPE status AS ENUM ('new');
+ALTER TYPE status ADD VALUE 'new';
+`,
+	})
+	if _, err := (DDL{}).Parse(context.Background(), environ.Values{}.Logger(), dir, nil, nil); err == nil {
+		t.Error("expected an error adding a value that already exists")
+	}
+}
@@ -0,0 +1,60 @@
+// Package drivers holds the registry of the database drivers gnorm can read
+// schemas with, by the name used for DBType in gnorm.toml.  Drivers register
+// themselves when they're imported, the same way database/sql drivers do, so a
+// program that wraps gnorm can add a driver by importing its package:
+//
+//	import _ "example.com/gnorm-sqlite"
+package drivers // import "gnorm.org/gnorm/database/drivers"
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"gnorm.org/gnorm/database"
+)
+
+// Driver is the interface database drivers implement.
+type Driver = database.Driver
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]Driver{}
+)
+
+// Register makes a driver available by name, which is what DBType is set to
+// in order to use it.  Names are case insensitive.  Register is meant to be
+// called from the init function of the driver's package, and panics if d is
+// nil or a driver is already registered with the same name.
+func Register(name string, d Driver) {
+	if d == nil {
+		panic("drivers: Register driver is nil")
+	}
+	name = strings.ToLower(name)
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := registry[name]; ok {
+		panic("drivers: Register called twice for driver " + name)
+	}
+	registry[name] = d
+}
+
+// Lookup returns the driver registered with the given name, if there is one.
+func Lookup(name string) (Driver, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	d, ok := registry[strings.ToLower(name)]
+	return d, ok
+}
+
+// Names returns the names of the registered drivers, sorted.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
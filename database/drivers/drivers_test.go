@@ -0,0 +1,40 @@
+package drivers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"gnorm.org/gnorm/database"
+	"gnorm.org/gnorm/environ"
+)
+
+type testDriver struct{}
+
+func (testDriver) Parse(ctx context.Context, log environ.Logger, conn string, schemaNames []string, filterTables func(schema, table string) bool) (*database.Info, error) {
+	return &database.Info{}, nil
+}
+
+func TestRegister(t *testing.T) {
+	defer func(r map[string]Driver) { registry = r }(registry)
+	registry = map[string]Driver{}
+
+	Register("Test", testDriver{})
+	Register("other", testDriver{})
+	if d, ok := Lookup("TEST"); !ok || d != (testDriver{}) {
+		t.Fatalf("expected to look up the test driver, but got %v, %v", d, ok)
+	}
+	if _, ok := Lookup("missing"); ok {
+		t.Fatal("expected no driver called missing")
+	}
+	if names := Names(); !reflect.DeepEqual(names, []string{"other", "test"}) {
+		t.Fatalf("expected names other and test, but got %q", names)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected registering the same name twice to panic")
+		}
+	}()
+	Register("test", testDriver{})
+}
@@ -0,0 +1,142 @@
+// Package schemafile holds the parts the drivers that read schemas from files,
+// rather than from a database, have in common.
+package schemafile
+
+import (
+	"strings"
+
+	"gnorm.org/gnorm/database"
+)
+
+// Kind is the kind of a token.  Each language uses only some of them.
+type Kind int
+
+const (
+	Word    Kind = iota // a keyword or unquoted name
+	Quoted              // a quoted name
+	String              // a string, without its quotes
+	Expr                // an expression, such as one in backticks in DBML
+	Number              // a number
+	Doc                 // a documentation comment, without its markers
+	Newline             // the end of one or more lines
+	Punct               // punctuation, such as { or ::
+)
+
+// Token is a token of a schema file.  The text of strings, quoted names, and
+// expressions is their value, without the quotes.
+type Token struct {
+	Kind Kind
+	Text string
+}
+
+// Is reports whether the token is the given keyword, ignoring case.
+func (t Token) Is(word string) bool {
+	return t.Kind == Word && strings.EqualFold(t.Text, word)
+}
+
+// IsExact reports whether the token is the given word, for languages whose
+// keywords are case sensitive.
+func (t Token) IsExact(word string) bool {
+	return t.Kind == Word && t.Text == word
+}
+
+// IsPunct reports whether the token is the given punctuation.
+func (t Token) IsPunct(p string) bool {
+	return t.Kind == Punct && t.Text == p
+}
+
+// IsName reports whether the token can be the name of something.
+func (t Token) IsName() bool {
+	return t.Kind == Word || t.Kind == Quoted
+}
+
+// Schemas holds the schemas read from schema files, in the order they were
+// first seen.
+type Schemas struct {
+	schemas []*database.Schema
+}
+
+// Info returns the schemas that were read, in the order of schemaNames, with
+// only the tables that filterTables accepts.  If schemaNames is empty, all the
+// schemas are returned.
+func (s *Schemas) Info(schemaNames []string, filterTables func(schema, table string) bool) *database.Info {
+	info := &database.Info{}
+	schemas := s.schemas
+	if len(schemaNames) > 0 {
+		schemas = nil
+		for _, name := range schemaNames {
+			if sch := s.Schema(name, false); sch != nil {
+				schemas = append(schemas, sch)
+			}
+		}
+	}
+	for _, sch := range schemas {
+		if filterTables != nil {
+			var tables []*database.Table
+			for _, t := range sch.Tables {
+				if filterTables(sch.Name, t.Name) {
+					tables = append(tables, t)
+				}
+			}
+			sch.Tables = tables
+		}
+		info.Schemas = append(info.Schemas, sch)
+	}
+	return info
+}
+
+// Schema returns the schema with the given name, creating it if create is
+// true.
+func (s *Schemas) Schema(name string, create bool) *database.Schema {
+	for _, sch := range s.schemas {
+		if sch.Name == name {
+			return sch
+		}
+	}
+	if !create {
+		return nil
+	}
+	sch := &database.Schema{Name: name}
+	s.schemas = append(s.schemas, sch)
+	return sch
+}
+
+// Table returns the table with the given name, or nil if there isn't one.
+func (s *Schemas) Table(schema, name string) *database.Table {
+	sch := s.Schema(schema, false)
+	if sch == nil {
+		return nil
+	}
+	for _, t := range sch.Tables {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// Enum returns the enum with the given name that doesn't belong to a table,
+// or nil if there isn't one.
+func (s *Schemas) Enum(schema, name string) *database.Enum {
+	sch := s.Schema(schema, false)
+	if sch == nil {
+		return nil
+	}
+	for _, e := range sch.Enums {
+		if e.Table == "" && e.Name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// IsDigit reports whether c is an ASCII digit.
+func IsDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// IsWordByte reports whether c can start a word.  Bytes of UTF-8 encoded
+// runes are counted as letters.
+func IsWordByte(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
@@ -1,9 +1,9 @@
 package mysql // import "gnorm.org/gnorm/database/drivers/mysql"
 
 import (
-	"database/sql"
+	"context"
 	"fmt"
-	"log"
+	"sort"
 	"strings"
 
 	// mysql driver
@@ -11,27 +11,86 @@ import (
 	"github.com/pkg/errors"
 
 	"gnorm.org/gnorm/database"
+	"gnorm.org/gnorm/database/drivers"
 	"gnorm.org/gnorm/database/drivers/mysql/gnorm/columns"
 	"gnorm.org/gnorm/database/drivers/mysql/gnorm/statistics"
 	"gnorm.org/gnorm/database/drivers/mysql/gnorm/tables"
+	"gnorm.org/gnorm/environ"
 )
 
 // MySQL implements drivers.Driver interface for MySQL database.
 type MySQL struct{}
 
+func init() {
+	drivers.Register("mysql", MySQL{})
+}
+
 // Parse reads the mysql schemas for the given schemas and converts them into
 // database.Info structs.
-func (MySQL) Parse(log *log.Logger, conn string, schemaNames []string, filterTables func(schema, table string) bool) (*database.Info, error) {
-	return parse(log, conn, schemaNames, filterTables)
+func (MySQL) Parse(ctx context.Context, log environ.Logger, conn string, schemaNames []string, filterTables func(schema, table string) bool) (*database.Info, error) {
+	return parse(ctx, log, conn, schemaNames, filterTables)
+}
+
+// Features reports what Parse reads from the database.  Sequences aren't
+// read.
+func (MySQL) Features() database.Features {
+	return database.Features{
+		Enums:       true,
+		Views:       true,
+		Comments:    true,
+		Indexes:     true,
+		ForeignKeys: true,
+		Queries:     true,
+	}
+}
+
+// catalogs are the catalog tables that parse reads.
+var catalogs = []string{
+	"information_schema.TABLES",
+	"information_schema.COLUMNS",
+	"information_schema.STATISTICS",
+	"information_schema.REFERENTIAL_CONSTRAINTS",
+	"information_schema.KEY_COLUMN_USAGE",
+}
+
+// CheckCatalogs checks that the catalog tables that Parse reads can be read,
+// and that each of the given schemas can be seen.  MySQL only shows the
+// schemas the user has some privilege on.
+func (MySQL) CheckCatalogs(ctx context.Context, log environ.Logger, conn string, schemaNames []string) (map[string]error, error) {
+	log.Debugf("connecting to mysql with DSN %v", conn)
+	db, err := database.Open(ctx, "mysql", conn)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	queries := make(map[string]string, len(catalogs))
+	for _, c := range catalogs {
+		queries[c] = "SELECT 1 FROM " + c + " LIMIT 1"
+	}
+	checks := database.CheckQueries(db, queries)
+	for _, s := range schemaNames {
+		checks["schema "+s] = db.Check(func() error {
+			var n int
+			if err := db.QueryRow("SELECT COUNT(*) FROM information_schema.SCHEMATA WHERE SCHEMA_NAME = ?", s).Scan(&n); err != nil {
+				return err
+			}
+			if n == 0 {
+				return errors.New("schema " + s + " doesn't exist, or the user has no privileges on it")
+			}
+			return nil
+		})
+	}
+	return checks, nil
 }
 
-func parse(log *log.Logger, conn string, schemaNames []string, filterTables func(schema, table string) bool) (*database.Info, error) {
-	log.Println("connecting to mysql with DSN", conn)
-	db, err := sql.Open("mysql", conn)
+func parse(ctx context.Context, log environ.Logger, conn string, schemaNames []string, filterTables func(schema, table string) bool) (*database.Info, error) {
+	log.Debugf("connecting to mysql with DSN %v", conn)
+	db, err := database.Open(ctx, "mysql", conn)
 	if err != nil {
-		return nil, errors.WithStack(err)
+		return nil, err
 	}
-	log.Println("querying table schemas for", schemaNames)
+	defer db.Close()
+	log.Debugf("querying table schemas for %v", schemaNames)
 	tables, err := tables.Query(db, tables.TableSchemaCol.In(schemaNames))
 	if err != nil {
 		return nil, err
@@ -64,7 +123,7 @@ func parse(log *log.Logger, conn string, schemaNames []string, filterTables func
 		}
 		tables, ok := schemas[c.TableSchema]
 		if !ok {
-			log.Printf("Should be impossible: column %q references unknown schema %q", c.ColumnName, c.TableSchema)
+			log.Warnf("Should be impossible: column %q references unknown schema %q", c.ColumnName, c.TableSchema)
 			continue
 		}
 
@@ -76,7 +135,7 @@ func parse(log *log.Logger, conn string, schemaNames []string, filterTables func
 			}
 		}
 		if table == nil {
-			log.Printf("Should be impossible: column %q references unknown table %q in schema %q", c.ColumnName, c.TableName, c.TableSchema)
+			log.Warnf("Should be impossible: column %q references unknown table %q in schema %q", c.ColumnName, c.TableName, c.TableSchema)
 			continue
 		}
 
@@ -98,6 +157,10 @@ func parse(log *log.Logger, conn string, schemaNames []string, filterTables func
 	if err != nil {
 		return nil, err
 	}
+	// the columns of an index are added in the order they're read.
+	sort.SliceStable(statistics, func(i, j int) bool {
+		return statistics[i].SeqInIndex < statistics[j].SeqInIndex
+	})
 
 	for _, s := range statistics {
 		if !filterTables(s.TableSchema, s.TableName) {
@@ -106,7 +169,7 @@ func parse(log *log.Logger, conn string, schemaNames []string, filterTables func
 
 		tables, ok := schemas[s.TableSchema]
 		if !ok {
-			log.Printf("Should be impossible: index %q references unknown schema %q", s.IndexName, s.TableSchema)
+			log.Warnf("Should be impossible: index %q references unknown schema %q", s.IndexName, s.TableSchema)
 			continue
 		}
 
@@ -118,7 +181,7 @@ func parse(log *log.Logger, conn string, schemaNames []string, filterTables func
 			}
 		}
 		if table == nil {
-			log.Printf("Should be impossible: index %q references unknown table %q", s.IndexName, s.TableName)
+			log.Warnf("Should be impossible: index %q references unknown table %q", s.IndexName, s.TableName)
 			continue
 		}
 
@@ -130,7 +193,7 @@ func parse(log *log.Logger, conn string, schemaNames []string, filterTables func
 			}
 		}
 		if column == nil {
-			log.Printf("Should be impossible: index %q references unknown column %q", s.IndexName, s.ColumnName)
+			log.Warnf("Should be impossible: index %q references unknown column %q", s.IndexName, s.ColumnName)
 			continue
 		}
 
@@ -161,13 +224,13 @@ func parse(log *log.Logger, conn string, schemaNames []string, filterTables func
 	}
 	for _, fk := range foreignKeys {
 		if !filterTables(fk.SchemaName, fk.TableName) {
-			log.Printf("skipping constraint %q because it is for filtered-out table %v.%v", fk.Name, fk.SchemaName, fk.TableName)
+			log.Debugf("skipping constraint %q because it is for filtered-out table %v.%v", fk.Name, fk.SchemaName, fk.TableName)
 			continue
 		}
 
 		tables, ok := schemas[fk.SchemaName]
 		if !ok {
-			log.Printf("Should be impossible: constraint %q references unknown schema %q", fk.Name, fk.SchemaName)
+			log.Warnf("Should be impossible: constraint %q references unknown schema %q", fk.Name, fk.SchemaName)
 			continue
 		}
 
@@ -179,7 +242,7 @@ func parse(log *log.Logger, conn string, schemaNames []string, filterTables func
 			}
 		}
 		if table == nil {
-			log.Printf("Should be impossible: constraint %q references unknown table %q in schema %q", fk.Name, fk.TableName, fk.SchemaName)
+			log.Warnf("Should be impossible: constraint %q references unknown table %q in schema %q", fk.Name, fk.TableName, fk.SchemaName)
 			continue
 		}
 
@@ -203,6 +266,7 @@ func parse(log *log.Logger, conn string, schemaNames []string, filterTables func
 
 		dbtables := make(map[string]*database.Table, len(tables))
 		for _, t := range tables {
+			database.SortColumns(t.Columns)
 			dbtables[t.Name] = t
 		}
 		for tname, index := range indexes[schema] {
@@ -212,14 +276,22 @@ func parse(log *log.Logger, conn string, schemaNames []string, filterTables func
 		res.Schemas = append(res.Schemas, s)
 	}
 
+	if queries := database.Queries(ctx); len(queries) > 0 {
+		log.Debugf("running %v queries", len(queries))
+		res.Queries, err = database.RunQueries(db, queries)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return res, nil
 }
 
-func toDBColumn(c *columns.Row, log *log.Logger) (*database.Column, *database.Enum, error) {
+func toDBColumn(c *columns.Row, log environ.Logger) (*database.Column, *database.Enum, error) {
 	col := &database.Column{
 		Name:         c.ColumnName,
 		Nullable:     c.IsNullable == "YES",
 		HasDefault:   c.ColumnDefault.String != "",
+		Default:      c.ColumnDefault.String,
 		Type:         c.DataType,
 		Comment:      c.ColumnComment,
 		Ordinal:      c.OrdinalPosition,
@@ -234,6 +306,11 @@ func toDBColumn(c *columns.Row, log *log.Logger) (*database.Column, *database.En
 		col.Length = int(c.CharacterMaximumLength.Int64)
 	}
 
+	if col.Type == "decimal" && c.NumericPrecision.Valid {
+		col.Precision = int(c.NumericPrecision.Int64)
+		col.Scale = int(c.NumericScale.Int64)
+	}
+
 	if col.Type != "enum" {
 		return col, nil, nil
 	}
@@ -266,7 +343,7 @@ func toDBColumn(c *columns.Row, log *log.Logger) (*database.Column, *database.En
 	return col, enum, nil
 }
 
-func queryForeignKeys(log *log.Logger, db *sql.DB, schemas []string) ([]*database.ForeignKey, error) {
+func queryForeignKeys(log environ.Logger, db *database.DB, schemas []string) ([]*database.ForeignKey, error) {
 	// TODO: make this work with Gnorm generated types
 	const q = `SELECT lkc.TABLE_SCHEMA, lkc.TABLE_NAME, lkc.COLUMN_NAME, lkc.CONSTRAINT_NAME, lkc.POSITION_IN_UNIQUE_CONSTRAINT, lkc.REFERENCED_TABLE_NAME, lkc.REFERENCED_COLUMN_NAME
 	  FROM information_schema.REFERENTIAL_CONSTRAINTS as rc
@@ -0,0 +1,31 @@
+package mysql
+
+import (
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
+
+	"gnorm.org/gnorm/database"
+)
+
+// tlsConfigName is the name the TLS settings are registered under with the
+// mysql driver.
+const tlsConfigName = "gnorm"
+
+// ConfigureTLS registers the TLS settings with the mysql driver and adds them
+// to the DSN.
+func (MySQL) ConfigureTLS(conn string, t *database.TLS) (string, error) {
+	cfg, err := t.Config()
+	if err != nil {
+		return "", err
+	}
+	if err := mysql.RegisterTLSConfig(tlsConfigName, cfg); err != nil {
+		return "", errors.WithStack(err)
+	}
+	sep := "?"
+	if strings.Contains(conn, "?") {
+		sep = "&"
+	}
+	return conn + sep + "tls=" + tlsConfigName, nil
+}
@@ -0,0 +1,109 @@
+// Package plugin implements a database driver that runs in a separate
+// executable, so that drivers with heavyweight or cgo dependencies don't have
+// to be compiled into gnorm.  It's used by setting DBType to "plugin:" followed
+// by the path to the executable.
+//
+// gnorm runs the executable with the name of a method as its only argument,
+// writes a JSON request to its stdin, and reads a JSON response from its
+// stdout.  The methods are:
+//
+//	parse: the request is {"ConnStr": "...", "Schemas": ["..."]}, and the
+//	response is the schema info, in the same format as the Info of a
+//	snapshot written by gnorm dump.  If Queries are configured, the request
+//	also has {"Queries": {"name": "..."}}, and the response's Queries must
+//	map each query name to its rows, each row an object of column names to
+//	values.
+//
+// If the executable exits with a non-zero status, the method failed, and
+// whatever it wrote to stderr is included in the error.  Drivers that can't run
+// queries should leave Queries out of the response.
+package plugin // import "gnorm.org/gnorm/database/drivers/plugin"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"gnorm.org/gnorm/database"
+	"gnorm.org/gnorm/environ"
+)
+
+// Prefix is the prefix of DBType values that name a plugin driver.
+const Prefix = "plugin:"
+
+// Driver implements drivers.Driver by running the executable at Path.
+type Driver struct {
+	Path string
+}
+
+// New returns the plugin driver for the DBType dbType, and whether dbType
+// names a plugin driver at all.
+func New(dbType string) (Driver, bool) {
+	if len(dbType) < len(Prefix) || !strings.EqualFold(dbType[:len(Prefix)], Prefix) {
+		return Driver{}, false
+	}
+	return Driver{Path: dbType[len(Prefix):]}, true
+}
+
+// parseRequest is the request for the parse method.
+type parseRequest struct {
+	ConnStr string
+	Schemas []string
+	Queries map[string]string `json:",omitempty"`
+}
+
+// Parse runs the plugin's parse method, and removes the tables that
+// filterTables excludes from the schema info it returns.  The queries carried
+// by ctx are sent along, so the plugin can run them on its connection.
+func (d Driver) Parse(ctx context.Context, log environ.Logger, conn string, schemaNames []string, filterTables func(schema, table string) bool) (*database.Info, error) {
+	info := &database.Info{}
+	req := parseRequest{ConnStr: conn, Schemas: schemaNames, Queries: database.Queries(ctx)}
+	if err := d.call(ctx, log, "parse", req, info); err != nil {
+		return nil, err
+	}
+	for _, s := range info.Schemas {
+		if s == nil {
+			return nil, errors.Errorf("driver plugin %s returned a null schema", d.Path)
+		}
+		tables := s.Tables[:0]
+		for _, t := range s.Tables {
+			if t != nil && filterTables(s.Name, t.Name) {
+				tables = append(tables, t)
+			}
+		}
+		s.Tables = tables
+	}
+	return info, nil
+}
+
+// call runs the method of the plugin with req as its input, and decodes its
+// output into resp.
+func (d Driver) call(ctx context.Context, log environ.Logger, method string, req, resp interface{}) error {
+	if d.Path == "" {
+		return errors.New("no path given for driver plugin, DBType should be " + Prefix + "<path>")
+	}
+	b, err := json.Marshal(req)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	log.Debugf("running driver plugin %s %s", d.Path, method)
+	cmd := exec.CommandContext(ctx, d.Path, method)
+	cmd.Stdin = bytes.NewReader(b)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if stderr.Len() > 0 {
+			return errors.Errorf("driver plugin %s %s failed: %v\n%s", d.Path, method, err, strings.TrimSpace(stderr.String()))
+		}
+		return errors.Wrapf(err, "driver plugin %s %s failed", d.Path, method)
+	}
+	if err := json.Unmarshal(stdout.Bytes(), resp); err != nil {
+		return errors.Wrapf(err, "can't parse the output of driver plugin %s %s", d.Path, method)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gnorm.org/gnorm/database"
+	"gnorm.org/gnorm/environ"
+)
+
+// TestMain runs the test binary as a driver plugin when GNORM_TEST_PLUGIN is
+// set, so the tests can run it.
+func TestMain(m *testing.M) {
+	if os.Getenv("GNORM_TEST_PLUGIN") != "" {
+		os.Exit(fakePlugin(os.Args[len(os.Args)-1]))
+	}
+	os.Exit(m.Run())
+}
+
+func fakePlugin(method string) int {
+	switch method {
+	case "parse":
+		var req parseRequest
+		if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if req.ConnStr == "bad" {
+			fmt.Fprintln(os.Stderr, "can't connect")
+			return 1
+		}
+		info := &database.Info{}
+		for name, q := range req.Queries {
+			if info.Queries == nil {
+				info.Queries = map[string][]map[string]interface{}{}
+			}
+			info.Queries[name] = []map[string]interface{}{{"query": q}}
+		}
+		for _, s := range req.Schemas {
+			info.Schemas = append(info.Schemas, &database.Schema{
+				Name: s,
+				Tables: []*database.Table{
+					{Name: "users", Columns: []*database.Column{{Name: "id", Type: "integer"}}},
+					{Name: "secrets"},
+				},
+			})
+		}
+		json.NewEncoder(os.Stdout).Encode(info)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unsupported method %s\n", method)
+		return 1
+	}
+}
+
+func TestNew(t *testing.T) {
+	if d, ok := New("Plugin:./mydriver"); !ok || d.Path != "./mydriver" {
+		t.Fatalf("expected plugin driver ./mydriver, but got %#v, %v", d, ok)
+	}
+	if _, ok := New("postgres"); ok {
+		t.Fatal("expected postgres not to be a plugin driver")
+	}
+}
+
+func TestDriver(t *testing.T) {
+	os.Setenv("GNORM_TEST_PLUGIN", "1")
+	defer os.Unsetenv("GNORM_TEST_PLUGIN")
+	d := Driver{Path: os.Args[0]}
+
+	info, err := d.Parse(context.Background(), environ.Discard, "conn", []string{"public"}, func(schema, table string) bool {
+		return table != "secrets"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &database.Info{Schemas: []*database.Schema{{
+		Name:   "public",
+		Tables: []*database.Table{{Name: "users", Columns: []*database.Column{{Name: "id", Type: "integer"}}}},
+	}}}
+	if !reflect.DeepEqual(info, expected) {
+		t.Fatalf("expected %#v, but got %#v", expected, info)
+	}
+
+	ctx := database.WithQueries(context.Background(), map[string]string{"q": "select 1"})
+	info, err = d.Parse(ctx, environ.Discard, "conn", nil, func(schema, table string) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows := info.Queries["q"]; len(rows) != 1 || rows[0]["query"] != "select 1" {
+		t.Fatalf("expected the plugin to run the queries, but got %v", info.Queries)
+	}
+
+	_, err = d.Parse(context.Background(), environ.Discard, "bad", nil, func(schema, table string) bool { return true })
+	if err == nil || !strings.Contains(err.Error(), "can't connect") {
+		t.Fatalf("expected the plugin's stderr in the error, but got %v", err)
+	}
+}
@@ -2,10 +2,10 @@ package postgres
 
 import (
 	"database/sql"
-	"log"
 	"testing"
 
 	"gnorm.org/gnorm/database/drivers/postgres/gnorm/columns"
+	"gnorm.org/gnorm/environ"
 )
 
 // These values are actual values created by reading postgres 9.6.3.
@@ -144,13 +144,12 @@ type testLog struct {
 	t *testing.T
 }
 
-func (l testLog) Write(b []byte) (n int, err error) {
-	l.t.Log(string(b))
-	return len(b), nil
-}
+func (l testLog) Debugf(format string, v ...interface{}) { l.t.Logf(format, v...) }
+func (l testLog) Infof(format string, v ...interface{})  { l.t.Logf(format, v...) }
+func (l testLog) Warnf(format string, v ...interface{})  { l.t.Logf(format, v...) }
 
-func tLog(t *testing.T) *log.Logger {
-	return log.New(testLog{t}, "", 0)
+func tLog(t *testing.T) environ.Logger {
+	return testLog{t}
 }
 
 func TestLength(t *testing.T) {
@@ -1,9 +1,9 @@
 package postgres // import "gnorm.org/gnorm/database/drivers/postgres"
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
 	"strings"
 
 	// register postgres driver
@@ -11,42 +11,109 @@ import (
 	"github.com/pkg/errors"
 
 	"gnorm.org/gnorm/database"
+	"gnorm.org/gnorm/database/drivers"
 	"gnorm.org/gnorm/database/drivers/postgres/gnorm/columns"
 	"gnorm.org/gnorm/database/drivers/postgres/gnorm/tables"
+	"gnorm.org/gnorm/environ"
 )
 
 // PG implements drivers.Driver interface for interacting with postgresql
 // database.
 type PG struct{}
 
+func init() {
+	drivers.Register("postgres", PG{})
+}
+
 // Parse reads the postgres schemas for the given schemas and converts them into
 // database.Info structs.
-func (PG) Parse(log *log.Logger, conn string, schemaNames []string, filterTables func(schema, table string) bool) (*database.Info, error) {
-	return parse(log, conn, schemaNames, filterTables)
+func (PG) Parse(ctx context.Context, log environ.Logger, conn string, schemaNames []string, filterTables func(schema, table string) bool) (*database.Info, error) {
+	return parse(ctx, log, conn, schemaNames, filterTables)
+}
+
+// Features reports what Parse reads from the database.  Sequences aren't
+// read.
+func (PG) Features() database.Features {
+	return database.Features{
+		Enums:       true,
+		Views:       true,
+		Comments:    true,
+		Indexes:     true,
+		ForeignKeys: true,
+		Queries:     true,
+	}
+}
+
+// catalogs are the catalog tables that parse reads.
+var catalogs = []string{
+	"information_schema.tables",
+	"information_schema.columns",
+	"information_schema.table_constraints",
+	"information_schema.key_column_usage",
+	"information_schema.referential_constraints",
+	"pg_catalog.pg_attribute",
+	"pg_catalog.pg_class",
+	"pg_catalog.pg_description",
+	"pg_catalog.pg_index",
+	"pg_catalog.pg_namespace",
+	"pg_catalog.pg_type",
+	"pg_catalog.pg_enum",
 }
 
-func parse(log *log.Logger, conn string, schemaNames []string, filterTables func(schema, table string) bool) (*database.Info, error) {
-	log.Println("connecting to postgres with DSN", conn)
-	db, err := sql.Open("postgres", conn)
+// CheckCatalogs checks that the catalog tables that Parse reads can be read,
+// and that the user may use each of the given schemas.  Note that postgres
+// only shows the objects in information_schema that the user has some
+// privilege on, so a schema the user can't see any tables in may still pass.
+func (PG) CheckCatalogs(ctx context.Context, log environ.Logger, conn string, schemaNames []string) (map[string]error, error) {
+	log.Debugf("connecting to postgres with DSN %v", conn)
+	db, err := database.Open(ctx, "postgres", conn)
 	if err != nil {
-		return nil, errors.WithStack(err)
+		return nil, err
+	}
+	defer db.Close()
+	queries := make(map[string]string, len(catalogs))
+	for _, c := range catalogs {
+		queries[c] = "SELECT 1 FROM " + c + " LIMIT 1"
+	}
+	checks := database.CheckQueries(db, queries)
+	for _, s := range schemaNames {
+		checks["schema "+s] = db.Check(func() error {
+			var ok bool
+			if err := db.QueryRow("SELECT has_schema_privilege($1, 'USAGE')", s).Scan(&ok); err != nil {
+				return err
+			}
+			if !ok {
+				return errors.New("permission denied for schema " + s)
+			}
+			return nil
+		})
 	}
+	return checks, nil
+}
+
+func parse(ctx context.Context, log environ.Logger, conn string, schemaNames []string, filterTables func(schema, table string) bool) (*database.Info, error) {
+	log.Debugf("connecting to postgres with DSN %v", conn)
+	db, err := database.Open(ctx, "postgres", conn)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
 	sch := make([]sql.NullString, len(schemaNames))
 	for x := range schemaNames {
 		sch[x] = sql.NullString{String: schemaNames[x], Valid: true}
 	}
 
-	log.Println("querying table schemas for", schemaNames)
+	log.Debugf("querying table schemas for %v", schemaNames)
 	tables, err := tables.Query(db, tables.TableSchemaCol.In(sch))
 	if err != nil {
 		return nil, err
 	}
 
-	log.Printf("found %v tables", len(tables))
+	log.Debugf("found %v tables", len(tables))
 	schemas := make(map[string][]*database.Table, len(schemaNames))
 	for _, t := range tables {
 		if !filterTables(t.TableSchema.String, t.TableName.String) {
-			log.Printf("skipping filtered-out table %v.%v", t.TableSchema.String, t.TableName.String)
+			log.Debugf("skipping filtered-out table %v.%v", t.TableSchema.String, t.TableName.String)
 			continue
 		}
 
@@ -62,16 +129,16 @@ func parse(log *log.Logger, conn string, schemaNames []string, filterTables func
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("found %v columns for all tables in all specified schemas", len(columns))
+	log.Debugf("found %v columns for all tables in all specified schemas", len(columns))
 	for _, c := range columns {
 		if !filterTables(c.TableSchema.String, c.TableName.String) {
-			log.Printf("skipping column %q because it is for filtered-out table %v.%v", c.ColumnName.String, c.TableSchema.String, c.TableName.String)
+			log.Debugf("skipping column %q because it is for filtered-out table %v.%v", c.ColumnName.String, c.TableSchema.String, c.TableName.String)
 			continue
 		}
 
 		tables, ok := schemas[c.TableSchema.String]
 		if !ok {
-			log.Printf("Should be impossible: column %q references unknown schema %q", c.ColumnName.String, c.TableSchema.String)
+			log.Warnf("Should be impossible: column %q references unknown schema %q", c.ColumnName.String, c.TableSchema.String)
 			continue
 		}
 		var table *database.Table
@@ -82,7 +149,7 @@ func parse(log *log.Logger, conn string, schemaNames []string, filterTables func
 			}
 		}
 		if table == nil {
-			log.Printf("Should be impossible: column %q references unknown table %q in schema %q", c.ColumnName.String, c.TableName.String, c.TableSchema.String)
+			log.Warnf("Should be impossible: column %q references unknown table %q in schema %q", c.ColumnName.String, c.TableName.String, c.TableSchema.String)
 			continue
 		}
 
@@ -90,20 +157,20 @@ func parse(log *log.Logger, conn string, schemaNames []string, filterTables func
 		table.Columns = append(table.Columns, col)
 	}
 
-	primaryKeys, err := queryPrimaryKeys(log, db, schemaNames)
+	primaryKeys, foreignKeys, err := queryKeys(log, db, schemaNames)
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("found %v primary keys", len(primaryKeys))
+	log.Debugf("found %v primary key columns and %v foreign key columns", len(primaryKeys), len(foreignKeys))
 	for _, pk := range primaryKeys {
 		if !filterTables(pk.SchemaName, pk.TableName) {
-			log.Printf("skipping constraint %q because it is for filtered-out table %v.%v", pk.Name, pk.SchemaName, pk.TableName)
+			log.Debugf("skipping constraint %q because it is for filtered-out table %v.%v", pk.Name, pk.SchemaName, pk.TableName)
 			continue
 		}
 
 		tables, ok := schemas[pk.SchemaName]
 		if !ok {
-			log.Printf("Should be impossible: constraint %q references unknown schema %q", pk.Name, pk.SchemaName)
+			log.Warnf("Should be impossible: constraint %q references unknown schema %q", pk.Name, pk.SchemaName)
 			continue
 		}
 		var table *database.Table
@@ -114,7 +181,7 @@ func parse(log *log.Logger, conn string, schemaNames []string, filterTables func
 			}
 		}
 		if table == nil {
-			log.Printf("Should be impossible: constraint %q references unknown table %q in schema %q", pk.Name, pk.TableName, pk.SchemaName)
+			log.Warnf("Should be impossible: constraint %q references unknown table %q in schema %q", pk.Name, pk.TableName, pk.SchemaName)
 			continue
 		}
 
@@ -126,19 +193,15 @@ func parse(log *log.Logger, conn string, schemaNames []string, filterTables func
 		}
 	}
 
-	foreignKeys, err := queryForeignKeys(log, db, schemaNames)
-	if err != nil {
-		return nil, err
-	}
 	for _, fk := range foreignKeys {
 		if !filterTables(fk.SchemaName, fk.TableName) {
-			log.Printf("skipping constraint %q because it is for filtered-out table %v.%v", fk.Name, fk.SchemaName, fk.TableName)
+			log.Debugf("skipping constraint %q because it is for filtered-out table %v.%v", fk.Name, fk.SchemaName, fk.TableName)
 			continue
 		}
 
 		tables, ok := schemas[fk.SchemaName]
 		if !ok {
-			log.Printf("Should be impossible: constraint %q references unknown schema %q", fk.Name, fk.SchemaName)
+			log.Warnf("Should be impossible: constraint %q references unknown schema %q", fk.Name, fk.SchemaName)
 			continue
 		}
 		var table *database.Table
@@ -149,7 +212,7 @@ func parse(log *log.Logger, conn string, schemaNames []string, filterTables func
 			}
 		}
 		if table == nil {
-			log.Printf("Should be impossible: constraint %q references unknown table %q in schema %q", fk.Name, fk.TableName, fk.SchemaName)
+			log.Warnf("Should be impossible: constraint %q references unknown table %q in schema %q", fk.Name, fk.TableName, fk.SchemaName)
 			continue
 		}
 
@@ -166,13 +229,13 @@ func parse(log *log.Logger, conn string, schemaNames []string, filterTables func
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("found %v enums for all schemas", len(enums))
+	log.Debugf("found %v enums for all schemas", len(enums))
 
 	indexResults, err := queryIndexes(log, db, schemaNames)
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("found %d indexes for all tables in all schemas", len(indexResults))
+	log.Debugf("found %d indexes for all tables in all schemas", len(indexResults))
 
 	indexes := make(map[string]map[string][]*database.Index)
 outer:
@@ -183,7 +246,7 @@ outer:
 
 		tables, ok := schemas[r.SchemaName]
 		if !ok {
-			log.Printf("Should be impossible: index %q references unknown schema %q", r.IndexName, r.SchemaName)
+			log.Warnf("Should be impossible: index %q references unknown schema %q", r.IndexName, r.SchemaName)
 			continue
 		}
 
@@ -195,7 +258,7 @@ outer:
 			}
 		}
 		if table == nil {
-			log.Printf("Should be impossible: index %q references unknown table %q", r.IndexName, r.TableName)
+			log.Warnf("Should be impossible: index %q references unknown table %q", r.IndexName, r.TableName)
 			continue
 		}
 
@@ -208,7 +271,7 @@ outer:
 		for _, c := range r.Columns {
 			column, cok := columnMap[c]
 			if !cok {
-				log.Printf("Should be impossible: index %q references unknown column %q", r.IndexName, c)
+				log.Warnf("Should be impossible: index %q references unknown column %q", r.IndexName, c)
 				continue outer
 			}
 			columns = append(columns, column)
@@ -235,11 +298,11 @@ outer:
 		index.Columns = columns
 	}
 
-	columnCommentResults, err := queryColumnComments(log, db, schemaNames)
+	tableCommentResults, columnCommentResults, err := queryComments(log, db, schemaNames)
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("found %d comments for all columns in all tables in all specified schemas", len(columnCommentResults))
+	log.Debugf("found %d comments for tables and %d comments for columns in all specified schemas", len(tableCommentResults), len(columnCommentResults))
 
 	for _, r := range columnCommentResults {
 		if !filterTables(r.SchemaName, r.TableName) {
@@ -248,7 +311,7 @@ outer:
 
 		tables, ok := schemas[r.SchemaName]
 		if !ok {
-			log.Printf("Should be impossible: comment for %q.%q.%q references unknown schema %q",
+			log.Warnf("Should be impossible: comment for %q.%q.%q references unknown schema %q",
 				r.SchemaName, r.TableName, r.ColumnName, r.SchemaName)
 			continue
 		}
@@ -261,7 +324,7 @@ outer:
 			}
 		}
 		if table == nil {
-			log.Printf("Should be impossible: comment for %q.%q.%q references unknown table %q",
+			log.Warnf("Should be impossible: comment for %q.%q.%q references unknown table %q",
 				r.SchemaName, r.TableName, r.ColumnName, r.TableName)
 			continue
 		}
@@ -274,12 +337,6 @@ outer:
 		}
 	}
 
-	tableCommentResults, err := queryTableComments(log, db, schemaNames)
-	if err != nil {
-		return nil, err
-	}
-	log.Printf("found %d comments for all tables in all specified schemas", len(tableCommentResults))
-
 	for _, r := range tableCommentResults {
 		if !filterTables(r.SchemaName, r.TableName) {
 			continue
@@ -287,7 +344,7 @@ outer:
 
 		tables, ok := schemas[r.SchemaName]
 		if !ok {
-			log.Printf("Should be impossible: comment for %q.%q references unknown schema %q",
+			log.Warnf("Should be impossible: comment for %q.%q references unknown schema %q",
 				r.SchemaName, r.TableName, r.SchemaName)
 			continue
 		}
@@ -300,7 +357,7 @@ outer:
 			}
 		}
 		if table == nil {
-			log.Printf("Should be impossible: comment for %q.%q references unknown table %s",
+			log.Warnf("Should be impossible: comment for %q.%q references unknown table %s",
 				r.SchemaName, r.TableName, r.TableName)
 			continue
 		}
@@ -319,6 +376,7 @@ outer:
 
 		dbtables := make(map[string]*database.Table, len(tables))
 		for _, t := range tables {
+			database.SortColumns(t.Columns)
 			dbtables[t.Name] = t
 		}
 		for tname, index := range indexes[schema] {
@@ -328,14 +386,22 @@ outer:
 		res.Schemas = append(res.Schemas, s)
 	}
 
+	if queries := database.Queries(ctx); len(queries) > 0 {
+		log.Debugf("running %v queries", len(queries))
+		res.Queries, err = database.RunQueries(db, queries)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return res, nil
 }
 
-func toDBColumn(c *columns.Row, log *log.Logger) *database.Column {
+func toDBColumn(c *columns.Row, log environ.Logger) *database.Column {
 	col := &database.Column{
 		Name:       c.ColumnName.String,
 		Nullable:   c.IsNullable.String == "YES",
 		HasDefault: c.ColumnDefault.String != "",
+		Default:    c.ColumnDefault.String,
 		Length:     int(c.CharacterMaximumLength.Int64),
 		Ordinal:    c.OrdinalPosition.Int64,
 		Orig:       *c,
@@ -352,6 +418,14 @@ func toDBColumn(c *columns.Row, log *log.Logger) *database.Column {
 	case "USER-DEFINED":
 		col.UserDefined = true
 		typ = c.UdtName.String
+
+	case "numeric":
+		// numeric columns declared without a precision can hold any number
+		// of digits, and have no precision here.
+		if c.NumericPrecision.Valid {
+			col.Precision = int(c.NumericPrecision.Int64)
+			col.Scale = int(c.NumericScale.Int64)
+		}
 	}
 
 	col.Type = typ
@@ -359,77 +433,59 @@ func toDBColumn(c *columns.Row, log *log.Logger) *database.Column {
 	return col
 }
 
-func queryPrimaryKeys(log *log.Logger, db *sql.DB, schemas []string) ([]*database.PrimaryKey, error) {
+// queryKeys reads the columns of the primary keys and foreign keys in the
+// schemas with a single query.
+func queryKeys(log environ.Logger, db *database.DB, schemas []string) ([]*database.PrimaryKey, []*database.ForeignKey, error) {
 	// TODO: make this work with Gnorm generated types
 	const q = `
-	SELECT k.table_schema, k.table_name, k.column_name, k.constraint_name
+	SELECT k.table_schema, k.table_name, k.column_name, k.constraint_name, c.constraint_type,
+		k.position_in_unique_constraint, f.table_name, f.column_name
 	FROM information_schema.key_column_usage k
-	LEFT JOIN information_schema.table_constraints c
-    	ON k.table_schema = c.table_schema
-    	AND k.table_name = c.table_name
-    	AND k.constraint_name = c.constraint_name
-	WHERE c.constraint_type='PRIMARY KEY' AND k.table_schema IN (%s)`
-	spots := make([]string, len(schemas))
-	vals := make([]interface{}, len(schemas))
-	for x := range schemas {
-		spots[x] = fmt.Sprintf("$%v", x+1)
-		vals[x] = schemas[x]
-	}
-	query := fmt.Sprintf(q, strings.Join(spots, ", "))
-	rows, err := db.Query(query, vals...)
+	JOIN information_schema.table_constraints c
+		ON k.table_schema = c.table_schema
+		AND k.table_name = c.table_name
+		AND k.constraint_name = c.constraint_name
+	LEFT JOIN information_schema.referential_constraints rc
+		ON rc.constraint_schema = k.table_schema
+		AND rc.constraint_name = k.constraint_name
+	LEFT JOIN information_schema.key_column_usage f
+		ON f.table_schema = rc.constraint_schema
+		AND f.ordinal_position = k.position_in_unique_constraint
+		AND f.constraint_name = rc.unique_constraint_name
+	WHERE c.constraint_type IN ('PRIMARY KEY', 'FOREIGN KEY') AND k.table_schema IN (%s)`
+	spots, vals := placeholders(schemas)
+	rows, err := db.Query(fmt.Sprintf(q, spots), vals...)
 	if err != nil {
-		return nil, errors.WithMessage(err, "error querying keys")
+		return nil, nil, errors.WithMessage(err, "error querying keys")
 	}
 	defer rows.Close()
-	var ret []*database.PrimaryKey
-
+	var pks []*database.PrimaryKey
+	var fks []*database.ForeignKey
 	for rows.Next() {
-		kc := &database.PrimaryKey{}
-		if err := rows.Scan(&kc.SchemaName, &kc.TableName, &kc.ColumnName, &kc.Name); err != nil {
-			return nil, errors.WithMessage(err, "error scanning key constraint")
-		}
-		ret = append(ret, kc)
-	}
-	return ret, nil
-}
-
-func queryForeignKeys(log *log.Logger, db *sql.DB, schemas []string) ([]*database.ForeignKey, error) {
-	// TODO: make this work with Gnorm generated types
-	const q = `SELECT rc.constraint_schema, lkc.table_name, lkc.column_name, lkc.constraint_name, lkc.position_in_unique_constraint, fkc.table_name, fkc.column_name
-	  FROM information_schema.referential_constraints rc
-  		LEFT JOIN information_schema.key_column_usage lkc
-    	  ON lkc.table_schema = rc.constraint_schema
-      		AND lkc.constraint_name = rc.constraint_name
-  		LEFT JOIN information_schema.key_column_usage fkc
-    	  ON fkc.table_schema = rc.constraint_schema
-      	    AND fkc.ordinal_position = lkc.position_in_unique_constraint
-      		AND fkc.constraint_name = rc.unique_constraint_name
-	  WHERE rc.constraint_schema IN (%s)`
-	spots := make([]string, len(schemas))
-	vals := make([]interface{}, len(schemas))
-	for x := range schemas {
-		spots[x] = fmt.Sprintf("$%v", x+1)
-		vals[x] = schemas[x]
-	}
-	query := fmt.Sprintf(q, strings.Join(spots, ", "))
-	rows, err := db.Query(query, vals...)
-	if err != nil {
-		return nil, errors.WithMessage(err, "error querying foreign keys")
-	}
-	defer rows.Close()
-	var ret []*database.ForeignKey
-
-	for rows.Next() {
-		fk := &database.ForeignKey{}
-		if err := rows.Scan(&fk.SchemaName, &fk.TableName, &fk.ColumnName, &fk.Name, &fk.UniqueConstraintPosition, &fk.ForeignTableName, &fk.ForeignColumnName); err != nil {
-			return nil, errors.WithMessage(err, "error scanning foreign key constraint")
+		var schema, table, column, name, typ string
+		var pos sql.NullInt64
+		var refTable, refColumn sql.NullString
+		if err := rows.Scan(&schema, &table, &column, &name, &typ, &pos, &refTable, &refColumn); err != nil {
+			return nil, nil, errors.WithMessage(err, "error scanning key constraint")
+		}
+		if typ == "PRIMARY KEY" {
+			pks = append(pks, &database.PrimaryKey{SchemaName: schema, TableName: table, ColumnName: column, Name: name})
+			continue
 		}
-		ret = append(ret, fk)
+		fks = append(fks, &database.ForeignKey{
+			SchemaName:               schema,
+			TableName:                table,
+			ColumnName:               column,
+			Name:                     name,
+			UniqueConstraintPosition: int(pos.Int64),
+			ForeignTableName:         refTable.String,
+			ForeignColumnName:        refColumn.String,
+		})
 	}
 	if rows.Err() != nil {
-		return nil, errors.WithMessage(rows.Err(), "error reading foreign keys")
+		return nil, nil, errors.WithMessage(rows.Err(), "error reading keys")
 	}
-	return ret, nil
+	return pks, fks, nil
 }
 
 type indexResult struct {
@@ -440,7 +496,7 @@ type indexResult struct {
 	Columns    []string
 }
 
-func queryIndexes(log *log.Logger, db *sql.DB, schemaNames []string) ([]indexResult, error) {
+func queryIndexes(log environ.Logger, db *database.DB, schemaNames []string) ([]indexResult, error) {
 	const q = `
 	SELECT
 		n.nspname as schema,
@@ -459,15 +515,8 @@ func queryIndexes(log *log.Logger, db *sql.DB, schemaNames []string) ([]indexRes
 		ON n.oid = c.relnamespace
 	WHERE n.nspname IN (%s)`
 
-	spots := make([]string, len(schemaNames))
-	vals := make([]interface{}, len(schemaNames))
-	for i := range schemaNames {
-		spots[i] = fmt.Sprintf("$%v", i+1)
-		vals[i] = schemaNames[i]
-	}
-
-	query := fmt.Sprintf(q, strings.Join(spots, ", "))
-	rows, err := db.Query(query, vals...)
+	spots, vals := placeholders(schemaNames)
+	rows, err := db.Query(fmt.Sprintf(q, spots), vals...)
 	if err != nil {
 		return nil, errors.WithMessage(err, "error querying indexes")
 	}
@@ -500,168 +549,126 @@ type columnCommentResult struct {
 	Comment    string
 }
 
-func queryColumnComments(log *log.Logger, db *sql.DB, schemaNames []string) ([]columnCommentResult, error) {
-	const q = `
-	SELECT
-		cols.table_schema,
-		cols.table_name,
-		cols.column_name,
-			(
-					SELECT pg_catalog.col_description(c.oid, cols.ordinal_position::int)
-					FROM pg_catalog.pg_class c
-					WHERE c.relname = cols.table_name
-			) AS column_comment
-	FROM information_schema.columns cols
-	WHERE cols.table_schema IN (%s)`
-
-	spots := make([]string, len(schemaNames))
-	vals := make([]interface{}, len(schemaNames))
-	for i := range schemaNames {
-		spots[i] = fmt.Sprintf("$%v", i+1)
-		vals[i] = schemaNames[i]
-	}
-
-	query := fmt.Sprintf(q, strings.Join(spots, ", "))
-	rows, err := db.Query(query, vals...)
-	if err != nil {
-		return nil, errors.WithMessage(err, "error querying column comments")
-	}
-	defer rows.Close()
-
-	var results []columnCommentResult
-	for rows.Next() {
-		var r columnCommentResult
-		var c sql.NullString
-		if err := rows.Scan(&r.SchemaName, &r.TableName, &r.ColumnName, &c); err != nil {
-			return nil, errors.WithMessage(err, "error scanning column comment")
-		}
-
-		if c.Valid {
-			r.Comment = c.String
-			results = append(results, r)
-		}
-	}
-
-	return results, nil
-}
-
 type tableCommentResult struct {
 	SchemaName string
 	TableName  string
 	Comment    string
 }
 
-func queryTableComments(log *log.Logger, db *sql.DB, schemaNames []string) ([]tableCommentResult, error) {
+// queryComments reads the comments on the tables and views in the schemas, and
+// on their columns, with a single query.  Only the objects that have comments
+// are returned.
+func queryComments(log environ.Logger, db *database.DB, schemaNames []string) ([]tableCommentResult, []columnCommentResult, error) {
 	const q = `
-	SELECT
-		tabs.table_schema,
-		tabs.table_name,
-			(
-					SELECT obj_description(c.oid)
-					FROM pg_class c
-					WHERE c.relname = tabs.table_name
-			) AS column_comment
-	FROM information_schema.tables tabs
-	WHERE tabs.table_schema IN (%s)`
-
-	spots := make([]string, len(schemaNames))
-	vals := make([]interface{}, len(schemaNames))
-	for i := range schemaNames {
-		spots[i] = fmt.Sprintf("$%v", i+1)
-		vals[i] = schemaNames[i]
-	}
-
-	query := fmt.Sprintf(q, strings.Join(spots, ", "))
-	rows, err := db.Query(query, vals...)
+	SELECT n.nspname, c.relname, a.attname, d.description
+	FROM pg_catalog.pg_description d
+	JOIN pg_catalog.pg_class c
+		ON c.oid = d.objoid
+	JOIN pg_catalog.pg_namespace n
+		ON n.oid = c.relnamespace
+	LEFT JOIN pg_catalog.pg_attribute a
+		ON a.attrelid = c.oid
+		AND a.attnum = d.objsubid
+		AND NOT a.attisdropped
+	WHERE d.classoid = 'pg_catalog.pg_class'::regclass
+	AND c.relkind IN ('r', 'v', 'f', 'p')
+	AND (d.objsubid = 0 OR a.attname IS NOT NULL)
+	AND n.nspname IN (%s)`
+
+	spots, vals := placeholders(schemaNames)
+	rows, err := db.Query(fmt.Sprintf(q, spots), vals...)
 	if err != nil {
-		return nil, errors.WithMessage(err, "error querying table comments")
+		return nil, nil, errors.WithMessage(err, "error querying comments")
 	}
 	defer rows.Close()
 
-	var results []tableCommentResult
+	var tables []tableCommentResult
+	var cols []columnCommentResult
 	for rows.Next() {
-		var r tableCommentResult
-		var c sql.NullString
-		if err := rows.Scan(&r.SchemaName, &r.TableName, &c); err != nil {
-			return nil, errors.WithMessage(err, "error scanning table comment")
+		var schema, table, comment string
+		var column sql.NullString
+		if err := rows.Scan(&schema, &table, &column, &comment); err != nil {
+			return nil, nil, errors.WithMessage(err, "error scanning comment")
 		}
-
-		if c.Valid {
-			r.Comment = c.String
-			results = append(results, r)
+		if column.Valid {
+			cols = append(cols, columnCommentResult{SchemaName: schema, TableName: table, ColumnName: column.String, Comment: comment})
+		} else {
+			tables = append(tables, tableCommentResult{SchemaName: schema, TableName: table, Comment: comment})
 		}
 	}
+	if rows.Err() != nil {
+		return nil, nil, errors.WithMessage(rows.Err(), "error reading comments")
+	}
+	return tables, cols, nil
+}
 
-	return results, nil
+// placeholders returns the placeholders for a list of the given values, such
+// as "$1, $2", and the values as arguments for the query.
+func placeholders(values []string) (string, []interface{}) {
+	spots := make([]string, len(values))
+	vals := make([]interface{}, len(values))
+	for x := range values {
+		spots[x] = fmt.Sprintf("$%v", x+1)
+		vals[x] = values[x]
+	}
+	return strings.Join(spots, ", "), vals
 }
 
-func queryEnums(log *log.Logger, db *sql.DB, schemas []string) (map[string][]*database.Enum, error) {
+// enumValueResult is a value of an enum, as queryEnums reads it.
+type enumValueResult struct {
+	SchemaName string
+	EnumName   string
+	Label      string
+	SortOrder  float64
+}
+
+// queryEnums reads the enums in the schemas and their values with a single
+// query, and returns them by schema.
+func queryEnums(log environ.Logger, db *database.DB, schemas []string) (map[string][]*database.Enum, error) {
 	// TODO: make this work with Gnorm generated types
 	const q = `
-	SELECT      n.nspname, t.typname as type
+	SELECT      n.nspname, t.typname, e.enumlabel, e.enumsortorder
 	FROM        pg_type t
-	LEFT JOIN   pg_catalog.pg_namespace n ON n.oid = t.typnamespace
+	JOIN        pg_catalog.pg_namespace n ON n.oid = t.typnamespace
 	JOIN        pg_enum e ON t.oid = e.enumtypid
 	WHERE       (t.typrelid = 0 OR (SELECT c.relkind = 'c' FROM pg_catalog.pg_class c WHERE c.oid = t.typrelid))
 	AND     NOT EXISTS(SELECT 1 FROM pg_catalog.pg_type el WHERE el.oid = t.typelem AND el.typarray = t.oid)
-	AND     n.nspname IN (%s)`
-	spots := make([]string, len(schemas))
-	vals := make([]interface{}, len(schemas))
-	for x := range schemas {
-		spots[x] = fmt.Sprintf("$%v", x+1)
-		vals[x] = schemas[x]
-	}
-	query := fmt.Sprintf(q, strings.Join(spots, ", "))
-	rows, err := db.Query(query, vals...)
+	AND     n.nspname IN (%s)
+	ORDER BY    n.nspname, t.typname, e.enumsortorder`
+	spots, vals := placeholders(schemas)
+	rows, err := db.Query(fmt.Sprintf(q, spots), vals...)
 	if err != nil {
-		return nil, errors.WithMessage(err, "error querying enum names")
+		return nil, errors.WithMessage(err, "error querying enums")
 	}
 	defer rows.Close()
-	ret := map[string][]*database.Enum{}
+	var results []enumValueResult
 	for rows.Next() {
-		var name, schema string
-		if err := rows.Scan(&schema, &name); err != nil {
-			return nil, errors.WithMessage(err, "error scanning enum name into string")
-		}
-		vals, err := queryValues(log, db, schema, name)
-		if err != nil {
-			return nil, err
-		}
-		enum := &database.Enum{
-			Name:   name,
-			Values: vals,
+		var r enumValueResult
+		if err := rows.Scan(&r.SchemaName, &r.EnumName, &r.Label, &r.SortOrder); err != nil {
+			return nil, errors.WithMessage(err, "error scanning enum value")
 		}
-		ret[schema] = append(ret[schema], enum)
+		results = append(results, r)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, errors.WithMessage(err, "error reading enum names")
+		return nil, errors.WithMessage(err, "error reading enums")
 	}
-	return ret, nil
+	log.Debugf("found %d values for all enums in all specified schemas", len(results))
+	return groupEnums(results), nil
 }
 
-func queryValues(log *log.Logger, db *sql.DB, schema, enum string) ([]*database.EnumValue, error) {
-	// TODO: make this work with Gnorm generated types
-	rows, err := db.Query(`
-	SELECT
-	e.enumlabel,
-	e.enumsortorder
-	FROM pg_type t
-	JOIN ONLY pg_namespace n ON n.oid = t.typnamespace
-	LEFT JOIN pg_enum e ON t.oid = e.enumtypid
-	WHERE n.nspname = $1 AND t.typname = $2`, schema, enum)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to query enum values for %s.%s", schema, enum)
-	}
-	defer rows.Close()
-	var vals []*database.EnumValue
-	for rows.Next() {
-		var name sql.NullString
-		var val sql.NullInt64
-		if err := rows.Scan(&name, &val); err != nil {
-			return nil, errors.Wrapf(err, "failed reading enum values for %s.%s", schema, enum)
+// groupEnums groups the values, which must be sorted by schema, enum, and sort
+// order, into their enums.
+func groupEnums(results []enumValueResult) map[string][]*database.Enum {
+	ret := map[string][]*database.Enum{}
+	var enum *database.Enum
+	var schema string
+	for _, r := range results {
+		if enum == nil || schema != r.SchemaName || enum.Name != r.EnumName {
+			schema = r.SchemaName
+			enum = &database.Enum{Name: r.EnumName}
+			ret[schema] = append(ret[schema], enum)
 		}
-		vals = append(vals, &database.EnumValue{Name: name.String, Value: int(val.Int64)})
+		enum.Values = append(enum.Values, &database.EnumValue{Name: r.Label, Value: int(r.SortOrder)})
 	}
-	log.Printf("found %d values for enum %v.%v", len(vals), schema, enum)
-	return vals, nil
+	return ret
 }
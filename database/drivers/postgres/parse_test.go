@@ -0,0 +1,245 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gnorm.org/gnorm/database"
+)
+
+// fakeResult is the canned result of the queries that contain match.
+type fakeResult struct {
+	match string
+	cols  []string
+	rows  [][]driver.Value
+}
+
+// fakePG is a database/sql driver that answers queries with the first of
+// results that matches them, and records the queries it's sent.  Like lib/pq,
+// it fails a query made on a connection while the rows of another query on it
+// are still open.
+type fakePG struct {
+	results []fakeResult
+	queries []string
+}
+
+var fake = &fakePG{}
+
+func init() {
+	sql.Register("fakepg", fake)
+}
+
+// openFake opens a read-only DB, so that all queries are made on a single
+// connection, that answers queries with results.
+func openFake(t *testing.T, results ...fakeResult) *database.DB {
+	fake.results = results
+	fake.queries = nil
+	db, err := database.Open(database.WithReadOnly(context.Background()), "fakepg", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func (d *fakePG) Open(name string) (driver.Conn, error) {
+	return &fakeConn{d: d}, nil
+}
+
+type fakeConn struct {
+	d    *fakePG
+	open int
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) { panic("not implemented") }
+func (c *fakeConn) Close() error                              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)                 { panic("not implemented") }
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(0), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if c.open > 0 {
+		return nil, errors.New("pq: unexpected message while the rows of another query are open")
+	}
+	c.d.queries = append(c.d.queries, query)
+	for _, r := range c.d.results {
+		if strings.Contains(query, r.match) {
+			c.open++
+			return &fakeRows{c: c, cols: r.cols, rows: r.rows}, nil
+		}
+	}
+	return nil, errors.New("unexpected query: " + query)
+}
+
+type fakeRows struct {
+	c    *fakeConn
+	cols []string
+	rows [][]driver.Value
+	done bool
+}
+
+func (r *fakeRows) Columns() []string { return r.cols }
+
+func (r *fakeRows) Close() error {
+	if !r.done {
+		r.done = true
+		r.c.open--
+	}
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestGroupEnums(t *testing.T) {
+	results := []enumValueResult{
+		{SchemaName: "public", EnumName: "book_type", Label: "fiction", SortOrder: 1},
+		{SchemaName: "public", EnumName: "book_type", Label: "nonfiction", SortOrder: 2},
+		{SchemaName: "public", EnumName: "mood", Label: "happy", SortOrder: 1},
+		{SchemaName: "other", EnumName: "book_type", Label: "hardcover", SortOrder: 1},
+	}
+	expected := map[string][]*database.Enum{
+		"public": {
+			{Name: "book_type", Values: []*database.EnumValue{{Name: "fiction", Value: 1}, {Name: "nonfiction", Value: 2}}},
+			{Name: "mood", Values: []*database.EnumValue{{Name: "happy", Value: 1}}},
+		},
+		"other": {
+			{Name: "book_type", Values: []*database.EnumValue{{Name: "hardcover", Value: 1}}},
+		},
+	}
+	actual := groupEnums(results)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %v enums, got %v", expected, actual)
+	}
+	if len(groupEnums(nil)) != 0 {
+		t.Fatal("expected no enums for no values")
+	}
+}
+
+func TestQueryEnumsReadOnly(t *testing.T) {
+	db := openFake(t, fakeResult{
+		match: "pg_enum",
+		cols:  []string{"nspname", "typname", "enumlabel", "enumsortorder"},
+		rows: [][]driver.Value{
+			{"public", "book_type", "fiction", float64(1)},
+			{"public", "book_type", "nonfiction", float64(2)},
+			{"public", "mood", "happy", float64(1)},
+		},
+	})
+	enums, err := queryEnums(tLog(t), db, []string{"public"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]*database.Enum{
+		"public": {
+			{Name: "book_type", Values: []*database.EnumValue{{Name: "fiction", Value: 1}, {Name: "nonfiction", Value: 2}}},
+			{Name: "mood", Values: []*database.EnumValue{{Name: "happy", Value: 1}}},
+		},
+	}
+	if !reflect.DeepEqual(enums, expected) {
+		t.Fatalf("expected %v enums, got %v", expected, enums)
+	}
+
+	// the fake fails nested queries, the same way lib/pq does on a single
+	// connection.
+	rows, err := db.Query("SELECT FROM pg_enum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	if _, err := db.Query("SELECT FROM pg_enum"); err == nil {
+		t.Fatal("expected an error for a query made while rows are open, but got nil")
+	}
+}
+
+func TestQueryKeys(t *testing.T) {
+	db := openFake(t, fakeResult{
+		match: "key_column_usage",
+		cols:  []string{"table_schema", "table_name", "column_name", "constraint_name", "constraint_type", "position_in_unique_constraint", "table_name", "column_name"},
+		rows: [][]driver.Value{
+			// same named tables in two schemas.
+			{"public", "users", "id", "users_pkey", "PRIMARY KEY", nil, nil, nil},
+			{"audit", "users", "event_id", "users_pkey", "PRIMARY KEY", nil, nil, nil},
+			// a composite primary key, and a composite foreign key to it.
+			{"public", "books", "id", "books_pkey", "PRIMARY KEY", nil, nil, nil},
+			{"public", "books", "lang", "books_pkey", "PRIMARY KEY", nil, nil, nil},
+			{"public", "editions", "book_id", "editions_book_fkey", "FOREIGN KEY", int64(1), "books", "id"},
+			{"public", "editions", "book_lang", "editions_book_fkey", "FOREIGN KEY", int64(2), "books", "lang"},
+			{"audit", "users", "user_id", "users_user_fkey", "FOREIGN KEY", int64(1), "users", "id"},
+		},
+	})
+	pks, fks, err := queryKeys(tLog(t), db, []string{"public", "audit"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedPKs := []*database.PrimaryKey{
+		{SchemaName: "public", TableName: "users", ColumnName: "id", Name: "users_pkey"},
+		{SchemaName: "audit", TableName: "users", ColumnName: "event_id", Name: "users_pkey"},
+		{SchemaName: "public", TableName: "books", ColumnName: "id", Name: "books_pkey"},
+		{SchemaName: "public", TableName: "books", ColumnName: "lang", Name: "books_pkey"},
+	}
+	if !reflect.DeepEqual(pks, expectedPKs) {
+		t.Errorf("expected primary keys %v, but got %v", expectedPKs, pks)
+	}
+	expectedFKs := []*database.ForeignKey{
+		{SchemaName: "public", TableName: "editions", ColumnName: "book_id", Name: "editions_book_fkey", UniqueConstraintPosition: 1, ForeignTableName: "books", ForeignColumnName: "id"},
+		{SchemaName: "public", TableName: "editions", ColumnName: "book_lang", Name: "editions_book_fkey", UniqueConstraintPosition: 2, ForeignTableName: "books", ForeignColumnName: "lang"},
+		{SchemaName: "audit", TableName: "users", ColumnName: "user_id", Name: "users_user_fkey", UniqueConstraintPosition: 1, ForeignTableName: "users", ForeignColumnName: "id"},
+	}
+	if !reflect.DeepEqual(fks, expectedFKs) {
+		t.Errorf("expected foreign keys %v, but got %v", expectedFKs, fks)
+	}
+	if q := fake.queries[len(fake.queries)-1]; !strings.Contains(q, "k.table_schema IN ($1, $2)") {
+		t.Errorf("expected the keys to be read for both schemas, but got query %s", q)
+	}
+}
+
+func TestQueryComments(t *testing.T) {
+	db := openFake(t, fakeResult{
+		match: "pg_description",
+		cols:  []string{"nspname", "relname", "attname", "description"},
+		rows: [][]driver.Value{
+			{"public", "users", nil, "people who log in"},
+			{"public", "users", "id", "the user's id"},
+			{"audit", "users", nil, "changes to users"},
+			{"audit", "users", "id", "the change's id"},
+		},
+	})
+	tables, cols, err := queryComments(tLog(t), db, []string{"public", "audit"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedTables := []tableCommentResult{
+		{SchemaName: "public", TableName: "users", Comment: "people who log in"},
+		{SchemaName: "audit", TableName: "users", Comment: "changes to users"},
+	}
+	if !reflect.DeepEqual(tables, expectedTables) {
+		t.Errorf("expected table comments %v, but got %v", expectedTables, tables)
+	}
+	expectedCols := []columnCommentResult{
+		{SchemaName: "public", TableName: "users", ColumnName: "id", Comment: "the user's id"},
+		{SchemaName: "audit", TableName: "users", ColumnName: "id", Comment: "the change's id"},
+	}
+	if !reflect.DeepEqual(cols, expectedCols) {
+		t.Errorf("expected column comments %v, but got %v", expectedCols, cols)
+	}
+	// comments are matched to tables by their namespace, not just their name.
+	q := fake.queries[len(fake.queries)-1]
+	if !strings.Contains(q, "n.oid = c.relnamespace") || !strings.Contains(q, "n.nspname IN ($1, $2)") {
+		t.Errorf("expected the comments to be joined by namespace, but got query %s", q)
+	}
+}
@@ -0,0 +1,58 @@
+package postgres
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"gnorm.org/gnorm/database"
+)
+
+// ConfigureTLS adds the ssl settings for t to the connection string, which may
+// be a URL or a list of key=value pairs.
+func (PG) ConfigureTLS(conn string, t *database.TLS) (string, error) {
+	if t.ServerName != "" {
+		return "", errors.New("TLS ServerName is not supported by the postgres driver, which verifies the certificate against the host")
+	}
+	mode := "verify-full"
+	if t.InsecureSkipVerify {
+		mode = "require"
+	}
+	params := [][2]string{{"sslmode", mode}}
+	if t.CAFile != "" {
+		params = append(params, [2]string{"sslrootcert", t.CAFile})
+	}
+	if t.CertFile != "" {
+		params = append(params, [2]string{"sslcert", t.CertFile})
+	}
+	if t.KeyFile != "" {
+		params = append(params, [2]string{"sslkey", t.KeyFile})
+	}
+
+	if strings.HasPrefix(conn, "postgres://") || strings.HasPrefix(conn, "postgresql://") {
+		u, err := url.Parse(conn)
+		if err != nil {
+			return "", errors.WithMessage(err, "can't parse ConnStr as a url")
+		}
+		q := u.Query()
+		for _, p := range params {
+			q.Set(p[0], p[1])
+		}
+		u.RawQuery = q.Encode()
+		return u.String(), nil
+	}
+	// later values override earlier ones, so these win over any ssl settings
+	// already in the connection string.
+	for _, p := range params {
+		conn += " " + p[0] + "=" + quoteValue(p[1])
+	}
+	return strings.TrimSpace(conn), nil
+}
+
+// quoteValue quotes v for use as a value in a key=value connection string.
+func quoteValue(v string) string {
+	v = strings.Replace(v, `\`, `\\`, -1)
+	v = strings.Replace(v, `'`, `\'`, -1)
+	return "'" + v + "'"
+}
@@ -0,0 +1,39 @@
+package postgres
+
+import (
+	"testing"
+
+	"gnorm.org/gnorm/database"
+)
+
+func TestConfigureTLS(t *testing.T) {
+	tests := []struct {
+		conn     string
+		tls      database.TLS
+		expected string
+	}{
+		{
+			conn:     "dbname=mydb host=db sslmode=disable",
+			tls:      database.TLS{CAFile: "certs/ca.pem", CertFile: "certs/client.pem", KeyFile: "certs/it's.pem"},
+			expected: `dbname=mydb host=db sslmode=disable sslmode='verify-full' sslrootcert='certs/ca.pem' sslcert='certs/client.pem' sslkey='certs/it\'s.pem'`,
+		},
+		{
+			conn:     "postgres://admin@db/mydb?sslmode=disable",
+			tls:      database.TLS{InsecureSkipVerify: true},
+			expected: "postgres://admin@db/mydb?sslmode=require",
+		},
+	}
+	for _, test := range tests {
+		conn, err := PG{}.ConfigureTLS(test.conn, &test.tls)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if conn != test.expected {
+			t.Errorf("expected %q, but got %q", test.expected, conn)
+		}
+	}
+
+	if _, err := (PG{}).ConfigureTLS("host=db", &database.TLS{ServerName: "db.example.com"}); err == nil {
+		t.Fatal("expected an error for ServerName, but got nil")
+	}
+}
@@ -0,0 +1,81 @@
+package prisma
+
+import (
+	"strings"
+
+	"gnorm.org/gnorm/database/drivers/internal/schemafile"
+)
+
+// lex splits the schema into tokens, skipping comments other than
+// documentation comments.  Newlines are kept, since they end the definitions
+// of fields and enum values.
+func lex(s string) []schemafile.Token {
+	var toks []schemafile.Token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\n':
+			if len(toks) > 0 && toks[len(toks)-1].Kind != schemafile.Newline {
+				toks = append(toks, schemafile.Token{Kind: schemafile.Newline, Text: "\n"})
+			}
+			i++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f':
+			i++
+		case strings.HasPrefix(s[i:], "//"):
+			end := strings.IndexByte(s[i:], '\n')
+			if end < 0 {
+				end = len(s) - i
+			}
+			if strings.HasPrefix(s[i:], "///") {
+				toks = append(toks, schemafile.Token{Kind: schemafile.Doc, Text: strings.TrimSpace(s[i+3 : i+end])})
+			}
+			i += end
+		case c == '"':
+			text, n := quoted(s[i:])
+			toks = append(toks, schemafile.Token{Kind: schemafile.String, Text: text})
+			i += n
+		case strings.HasPrefix(s[i:], "@@"):
+			toks = append(toks, schemafile.Token{Kind: schemafile.Punct, Text: "@@"})
+			i += 2
+		case schemafile.IsDigit(c) || (c == '-' && i+1 < len(s) && schemafile.IsDigit(s[i+1])):
+			j := i + 1
+			for j < len(s) && (schemafile.IsDigit(s[j]) || s[j] == '.') {
+				j++
+			}
+			toks = append(toks, schemafile.Token{Kind: schemafile.Number, Text: s[i:j]})
+			i = j
+		case schemafile.IsWordByte(c):
+			j := i
+			for j < len(s) && (schemafile.IsWordByte(s[j]) || schemafile.IsDigit(s[j])) {
+				j++
+			}
+			toks = append(toks, schemafile.Token{Kind: schemafile.Word, Text: s[i:j]})
+			i = j
+		default:
+			toks = append(toks, schemafile.Token{Kind: schemafile.Punct, Text: string(c)})
+			i++
+		}
+	}
+	return toks
+}
+
+// quoted returns the text of the double quoted string at the start of s, and
+// the length of the string with its quotes.  Backslashes escape the character
+// after them.
+func quoted(s string) (string, int) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+			}
+		case '"':
+			return b.String(), i + 1
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), len(s)
+}
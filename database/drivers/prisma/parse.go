@@ -0,0 +1,799 @@
+// Package prisma is a gnorm driver that reads the schema from a Prisma schema
+// file, so that projects moving off of Prisma can generate code from the
+// schema.prisma they already have.
+package prisma // import "gnorm.org/gnorm/database/drivers/prisma"
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"gnorm.org/gnorm/database"
+	"gnorm.org/gnorm/database/drivers"
+	"gnorm.org/gnorm/database/drivers/internal/schemafile"
+	"gnorm.org/gnorm/environ"
+)
+
+// Prisma implements drivers.Driver by reading the models, views, and enums of
+// a Prisma schema.
+type Prisma struct{}
+
+func init() {
+	drivers.Register("prisma", Prisma{})
+}
+
+// Parse reads the schema from the Prisma schema file that conn names.  Models
+// become tables and their scalar fields columns, named by their @@map and
+// @map attributes, if they have them.  The types of columns are the ones
+// Prisma migrate creates for the provider of the datasource, which is
+// postgres unless the provider is mysql, and @db attributes override them.
+// Relation fields become foreign keys on the fields of their @relation.
+// Models without a @@schema are put in the first of schemaNames, or public
+// if there are none.
+func (Prisma) Parse(ctx context.Context, log environ.Logger, conn string, schemaNames []string, filterTables func(schema, table string) bool) (*database.Info, error) {
+	if conn == "" {
+		return nil, errors.New("the prisma driver needs ConnStr to be the path of a schema.prisma file")
+	}
+	b, err := ioutil.ReadFile(conn)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	p := &parser{log: log, defaultSchema: "public"}
+	if len(schemaNames) > 0 {
+		p.defaultSchema = schemaNames[0]
+	}
+	log.Debugf("parsing %s", conn)
+	if err := p.parse(lex(string(b))); err != nil {
+		return nil, errors.WithMessage(err, conn)
+	}
+	if err := p.build(); err != nil {
+		return nil, errors.WithMessage(err, conn)
+	}
+	return p.Info(schemaNames, filterTables), nil
+}
+
+// Features reports what Parse reads from the Prisma schema.
+func (Prisma) Features() database.Features {
+	return database.Features{
+		Enums:       true,
+		Views:       true,
+		Comments:    true,
+		Indexes:     true,
+		ForeignKeys: true,
+	}
+}
+
+// model is a model or view of the Prisma schema.
+type model struct {
+	name, dbName, schema, doc string
+	view                      bool
+	fields                    []*field
+	attrs                     []attribute
+	table                     *database.Table
+}
+
+// field returns the model's field with the given name, or nil if there isn't
+// one.
+func (m *model) field(name string) *field {
+	for _, f := range m.fields {
+		if f.name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// field is a field of a model.  Relation fields, whose type is another model,
+// have no column.
+type field struct {
+	name, dbName, typ, doc string
+	optional, list         bool
+	attrs                  []attribute
+	col                    *database.Column
+}
+
+// enum is an enum of the Prisma schema.  The names of its values are the
+// ones in the schema, and their values the ones in the database, which are
+// different for values with a @map.
+type enum struct {
+	name, dbName, schema string
+	names, values        []string
+}
+
+// attribute is an attribute of a field, such as @id or @db.VarChar(255), or of
+// a model, such as @@index([a, b]).  Its name doesn't include the @ or @@.
+type attribute struct {
+	name string
+	args []arg
+}
+
+// arg is an argument of an attribute, which is named unless it's the first.
+type arg struct {
+	name  string
+	value []schemafile.Token
+}
+
+// arg returns the value of the argument with the given name, or of the first
+// argument if it has no name and pos is true.
+func (a attribute) arg(name string, pos bool) []schemafile.Token {
+	for x, ar := range a.args {
+		if ar.name == name || (pos && x == 0 && ar.name == "") {
+			return ar.value
+		}
+	}
+	return nil
+}
+
+// str returns the value of the string argument with the given name.
+func (a attribute) str(name string, pos bool) string {
+	v := a.arg(name, pos)
+	if len(v) == 1 && v[0].Kind == schemafile.String {
+		return v[0].Text
+	}
+	return ""
+}
+
+// attr returns the attribute with the given name, or false if there isn't one.
+func attr(attrs []attribute, name string) (attribute, bool) {
+	for _, a := range attrs {
+		if a.name == name {
+			return a, true
+		}
+	}
+	return attribute{}, false
+}
+
+// parser builds up the schema from the definitions in a Prisma schema.
+type parser struct {
+	schemafile.Schemas
+	log           environ.Logger
+	defaultSchema string
+	provider      string
+	models        []*model
+	enums         []*enum
+}
+
+func (p *parser) model(name string) *model {
+	for _, m := range p.models {
+		if m.name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+func (p *parser) enum(name string) *enum {
+	for _, e := range p.enums {
+		if e.name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// parse reads the blocks of the schema.
+func (p *parser) parse(toks []schemafile.Token) error {
+	c := &cursor{toks: toks}
+	var doc []string
+	for {
+		c.skipNewlines()
+		if c.done() {
+			return nil
+		}
+		t := c.next()
+		if t.Kind == schemafile.Doc {
+			doc = append(doc, t.Text)
+			continue
+		}
+		if t.Kind != schemafile.Word || !c.peek().IsName() {
+			return errors.Errorf("expected a model, view, or enum, but got %q", t.Text)
+		}
+		name := c.next().Text
+		if !c.peek().IsPunct("{") {
+			return errors.Errorf("expected { after %s %s, but got %q", t.Text, name, c.peek().Text)
+		}
+		body := &cursor{toks: c.block()}
+		var err error
+		switch t.Text {
+		case "model", "view":
+			err = p.parseModel(t.Text == "view", name, strings.Join(doc, "\n"), body)
+		case "enum":
+			err = p.parseEnum(name, body)
+		case "datasource":
+			p.parseDatasource(body)
+		default:
+			p.log.Debugf("skipping %s %s", t.Text, name)
+		}
+		if err != nil {
+			return errors.WithMessage(err, t.Text+" "+name)
+		}
+		doc = nil
+	}
+}
+
+// parseDatasource reads the provider of the datasource.
+func (p *parser) parseDatasource(c *cursor) {
+	for !c.done() {
+		line := &cursor{toks: c.line()}
+		if line.accept("provider") && line.accept("=") && line.peek().Kind == schemafile.String {
+			p.provider = line.next().Text
+		}
+	}
+}
+
+func (p *parser) parseModel(view bool, name, doc string, c *cursor) error {
+	m := &model{name: name, dbName: name, schema: p.defaultSchema, doc: doc, view: view}
+	var fieldDoc []string
+	for {
+		c.skipNewlines()
+		if c.done() {
+			break
+		}
+		line := &cursor{toks: c.line()}
+		switch {
+		case line.peek().Kind == schemafile.Doc:
+			fieldDoc = append(fieldDoc, line.next().Text)
+		case line.accept("@@"):
+			a, err := parseAttribute(line)
+			if err != nil {
+				return err
+			}
+			m.attrs = append(m.attrs, a)
+		case line.peek().IsName():
+			f := &field{name: line.next().Text, doc: strings.Join(fieldDoc, "\n")}
+			fieldDoc = nil
+			f.dbName = f.name
+			if err := parseFieldType(f, line); err != nil {
+				return errors.WithMessage(err, "field "+f.name)
+			}
+			for line.accept("@") {
+				a, err := parseAttribute(line)
+				if err != nil {
+					return errors.WithMessage(err, "field "+f.name)
+				}
+				f.attrs = append(f.attrs, a)
+			}
+			if a, ok := attr(f.attrs, "map"); ok {
+				f.dbName = a.str("name", true)
+			}
+			m.fields = append(m.fields, f)
+		default:
+			return errors.Errorf("expected a field, but got %q", line.peek().Text)
+		}
+	}
+	if a, ok := attr(m.attrs, "map"); ok {
+		m.dbName = a.str("name", true)
+	}
+	if a, ok := attr(m.attrs, "schema"); ok {
+		m.schema = a.str("", true)
+	}
+	p.models = append(p.models, m)
+	return nil
+}
+
+// parseFieldType reads the type of the field, and whether it's optional or a
+// list.
+func parseFieldType(f *field, c *cursor) error {
+	if !c.peek().IsName() {
+		return errors.Errorf("expected a type, but got %q", c.peek().Text)
+	}
+	f.typ = c.next().Text
+	if f.typ == "Unsupported" {
+		// Unsupported("circle") is a database type that Prisma doesn't know.
+		for _, t := range c.parens() {
+			if t.Kind == schemafile.String {
+				f.typ = t.Text
+			}
+		}
+	}
+	switch {
+	case c.accept("?"):
+		f.optional = true
+	case c.peek().IsPunct("[") && c.peekAt(1).IsPunct("]"):
+		c.next()
+		c.next()
+		f.list = true
+	}
+	return nil
+}
+
+// parseAttribute reads an attribute, after its @ or @@.
+func parseAttribute(c *cursor) (attribute, error) {
+	if !c.peek().IsName() {
+		return attribute{}, errors.Errorf("expected an attribute, but got %q", c.peek().Text)
+	}
+	a := attribute{name: c.next().Text}
+	for c.peek().IsPunct(".") && c.peekAt(1).IsName() {
+		c.next()
+		a.name += "." + c.next().Text
+	}
+	if !c.peek().IsPunct("(") {
+		return a, nil
+	}
+	for _, part := range split(c.parens()) {
+		if len(part) >= 2 && part[0].Kind == schemafile.Word && part[1].IsPunct(":") {
+			a.args = append(a.args, arg{name: part[0].Text, value: part[2:]})
+		} else {
+			a.args = append(a.args, arg{value: part})
+		}
+	}
+	return a, nil
+}
+
+func (p *parser) parseEnum(name string, c *cursor) error {
+	e := &enum{name: name, dbName: name, schema: p.defaultSchema}
+	for {
+		c.skipNewlines()
+		if c.done() {
+			break
+		}
+		line := &cursor{toks: c.line()}
+		switch {
+		case line.peek().Kind == schemafile.Doc:
+		case line.accept("@@"):
+			a, err := parseAttribute(line)
+			if err != nil {
+				return err
+			}
+			switch a.name {
+			case "map":
+				e.dbName = a.str("name", true)
+			case "schema":
+				e.schema = a.str("", true)
+			}
+		case line.peek().IsName():
+			name := line.next().Text
+			value := name
+			for line.accept("@") {
+				a, err := parseAttribute(line)
+				if err != nil {
+					return err
+				}
+				if a.name == "map" {
+					value = a.str("name", true)
+				}
+			}
+			e.names = append(e.names, name)
+			e.values = append(e.values, value)
+		}
+	}
+	p.enums = append(p.enums, e)
+	return nil
+}
+
+// mysql reports whether the datasource is mysql, whose enums belong to their
+// columns, and whose types are named differently.
+func (p *parser) mysql() bool {
+	return p.provider == "mysql"
+}
+
+// build turns the models and enums into tables and enums, once they've all
+// been read, since fields may refer to models and enums defined after them.
+func (p *parser) build() error {
+	if !p.mysql() {
+		for _, e := range p.enums {
+			s := p.Schema(e.schema, true)
+			s.Enums = append(s.Enums, &database.Enum{Name: e.dbName, Values: enumValues(e)})
+		}
+	}
+	for _, m := range p.models {
+		s := p.Schema(m.schema, true)
+		t := &database.Table{Name: m.dbName, Type: "BASE TABLE", Comment: m.doc, IsInsertable: true}
+		if m.view {
+			t.Type, t.IsView, t.IsInsertable = "VIEW", true, false
+		}
+		m.table = t
+		s.Tables = append(s.Tables, t)
+		for _, f := range m.fields {
+			if p.model(f.typ) != nil {
+				continue
+			}
+			col := &database.Column{
+				Name:     f.dbName,
+				Nullable: f.optional,
+				IsArray:  f.list,
+				Comment:  f.doc,
+				Ordinal:  int64(len(t.Columns) + 1),
+			}
+			f.col = col
+			t.Columns = append(t.Columns, col)
+			p.columnType(s, t, f)
+			p.columnDefault(t, f)
+			if a, ok := attr(f.attrs, "id"); ok {
+				col.IsPrimaryKey = true
+				index(t, a.str("map", false), []*database.Column{col}, "_pkey")
+			}
+			if a, ok := attr(f.attrs, "unique"); ok {
+				index(t, a.str("map", false), []*database.Column{col}, "_key")
+			}
+		}
+		for _, a := range m.attrs {
+			suffix := map[string]string{"id": "_pkey", "unique": "_key", "index": "_idx"}[a.name]
+			if suffix == "" {
+				continue
+			}
+			cols, err := columns(m, a.arg("fields", true))
+			if err != nil {
+				return errors.WithMessage(err, fmt.Sprintf("@@%s of model %s", a.name, m.name))
+			}
+			if a.name == "id" {
+				for _, col := range cols {
+					col.IsPrimaryKey = true
+				}
+			}
+			index(t, a.str("map", false), cols, suffix)
+		}
+	}
+	for _, m := range p.models {
+		for _, f := range m.fields {
+			a, ok := attr(f.attrs, "relation")
+			if !ok || a.arg("fields", false) == nil {
+				// the other side of the relation has the foreign key.
+				continue
+			}
+			if err := p.relation(m, f, a); err != nil {
+				return errors.WithMessage(err, fmt.Sprintf("relation %s of model %s", f.name, m.name))
+			}
+		}
+	}
+	return nil
+}
+
+// relation sets the foreign key of the columns in the fields of the relation
+// attribute a of field f.
+func (p *parser) relation(m *model, f *field, a attribute) error {
+	ref := p.model(f.typ)
+	if ref == nil {
+		return errors.Errorf("%s isn't a model", f.typ)
+	}
+	cols, err := columns(m, a.arg("fields", false))
+	if err != nil {
+		return err
+	}
+	refCols, err := columns(ref, a.arg("references", false))
+	if err != nil {
+		return err
+	}
+	if len(cols) != len(refCols) || len(cols) == 0 {
+		return errors.New("fields and references must have the same number of fields")
+	}
+	name := a.str("map", false)
+	if name == "" {
+		// the name Prisma gives foreign keys.
+		name = m.dbName + "_" + cols[0].Name + "_fkey"
+	}
+	for x, col := range cols {
+		col.IsForeignKey = true
+		col.ForeignKey = &database.ForeignKey{
+			SchemaName:               m.schema,
+			TableName:                m.dbName,
+			ColumnName:               col.Name,
+			Name:                     name,
+			UniqueConstraintPosition: x + 1,
+			ForeignTableName:         ref.dbName,
+			ForeignColumnName:        refCols[x].Name,
+		}
+	}
+	return nil
+}
+
+// columns returns the columns of the model's fields named in the list toks,
+// such as [a, b(sort: Desc)].
+func columns(m *model, toks []schemafile.Token) ([]*database.Column, error) {
+	c := &cursor{toks: toks}
+	var cols []*database.Column
+	for _, part := range split(c.enclosed("[", "]")) {
+		if len(part) == 0 {
+			continue
+		}
+		f := m.field(part[0].Text)
+		if f == nil || f.col == nil {
+			return nil, errors.Errorf("model %s has no scalar field %s", m.name, part[0].Text)
+		}
+		cols = append(cols, f.col)
+	}
+	return cols, nil
+}
+
+// index adds the index to the table, named the way Prisma names indexes if it
+// has no name.
+func index(t *database.Table, name string, cols []*database.Column, suffix string) {
+	if name == "" {
+		name = t.Name
+		if suffix != "_pkey" {
+			for _, col := range cols {
+				name += "_" + col.Name
+			}
+		}
+		name += suffix
+	}
+	t.Indexes = append(t.Indexes, &database.Index{Name: name, IsUnique: suffix != "_idx", Columns: cols})
+}
+
+func enumValues(e *enum) []*database.EnumValue {
+	vals := make([]*database.EnumValue, len(e.values))
+	for x, v := range e.values {
+		vals[x] = &database.EnumValue{Name: v, Value: x + 1}
+	}
+	return vals
+}
+
+// pgTypes and mysqlTypes map Prisma's scalar types to the types that Prisma
+// migrate creates for them.
+var (
+	pgTypes = map[string]string{
+		"String":   "text",
+		"Boolean":  "boolean",
+		"Int":      "integer",
+		"BigInt":   "bigint",
+		"Float":    "double precision",
+		"Decimal":  "numeric",
+		"DateTime": "timestamp without time zone",
+		"Json":     "jsonb",
+		"Bytes":    "bytea",
+	}
+	mysqlTypes = map[string]string{
+		"String":   "varchar",
+		"Boolean":  "tinyint",
+		"Int":      "int",
+		"BigInt":   "bigint",
+		"Float":    "double",
+		"Decimal":  "decimal",
+		"DateTime": "datetime",
+		"Json":     "json",
+		"Bytes":    "longblob",
+	}
+)
+
+// pgNative maps the names of @db attributes for postgres to the names
+// postgres reports for their types, where they're not just the name in lower
+// case.
+var pgNative = map[string]string{
+	"VarChar":         "character varying",
+	"Char":            "character",
+	"DoublePrecision": "double precision",
+	"Decimal":         "numeric",
+	"Timestamp":       "timestamp without time zone",
+	"Timestamptz":     "timestamp with time zone",
+	"Time":            "time without time zone",
+	"Timetz":          "time with time zone",
+	"VarBit":          "bit varying",
+}
+
+// columnType sets the type of the field's column, from its @db attribute if it
+// has one.
+func (p *parser) columnType(s *database.Schema, t *database.Table, f *field) {
+	col := f.col
+	if e := p.enum(f.typ); e != nil {
+		if p.mysql() {
+			col.Type = "enum"
+			s.Enums = append(s.Enums, &database.Enum{Table: t.Name, Name: col.Name, Values: enumValues(e)})
+		} else {
+			col.Type = e.dbName
+			col.UserDefined = true
+		}
+		return
+	}
+	types := pgTypes
+	if p.mysql() {
+		types = mysqlTypes
+	}
+	col.Type = f.typ
+	if typ, ok := types[f.typ]; ok {
+		col.Type = typ
+		switch {
+		case f.typ == "Decimal":
+			col.Precision, col.Scale = 65, 30
+		case f.typ == "String" && p.mysql():
+			col.Length = 191
+		}
+	}
+	for _, a := range f.attrs {
+		if !strings.HasPrefix(a.name, "db.") {
+			continue
+		}
+		native := strings.TrimPrefix(a.name, "db.")
+		col.Type = strings.ToLower(native)
+		if p.mysql() {
+			col.Type = strings.TrimPrefix(col.Type, "unsigned")
+		} else if typ, ok := pgNative[native]; ok {
+			col.Type = typ
+		}
+		var nums []int
+		for _, ar := range a.args {
+			if len(ar.value) == 1 && ar.value[0].Kind == schemafile.Number {
+				n, _ := strconv.Atoi(ar.value[0].Text)
+				nums = append(nums, n)
+			}
+		}
+		col.Length, col.Precision, col.Scale = 0, 0, 0
+		switch {
+		case len(nums) == 2:
+			col.Precision, col.Scale = nums[0], nums[1]
+		case len(nums) == 1 && native == "Decimal":
+			col.Precision = nums[0]
+		case len(nums) == 1 && (strings.Contains(col.Type, "char") || strings.Contains(col.Type, "binary") || strings.Contains(col.Type, "bit")):
+			col.Length = nums[0]
+		}
+	}
+}
+
+// columnDefault sets the default of the field's column from its @default
+// attribute, as the database would report it.  Defaults that Prisma Client
+// generates, such as uuid() and cuid(), aren't defaults in the database.
+func (p *parser) columnDefault(t *database.Table, f *field) {
+	a, ok := attr(f.attrs, "default")
+	if !ok {
+		return
+	}
+	col := f.col
+	v := a.arg("value", true)
+	if len(v) == 0 {
+		return
+	}
+	switch {
+	case v[0].IsExact("autoincrement"), v[0].IsExact("sequence"):
+		col.HasDefault = true
+		if !p.mysql() {
+			col.Default = fmt.Sprintf("nextval('%s_%s_seq'::regclass)", t.Name, col.Name)
+		}
+	case v[0].IsExact("now"):
+		col.HasDefault = true
+		col.Default = "CURRENT_TIMESTAMP"
+	case v[0].IsExact("dbgenerated"):
+		col.HasDefault = true
+		for _, tok := range v[1:] {
+			if tok.Kind == schemafile.String {
+				col.Default = tok.Text
+			}
+		}
+	case len(v) > 1 && v[1].IsPunct("("):
+		// uuid(), cuid(), and the like.
+	case v[0].Kind == schemafile.String:
+		col.HasDefault = true
+		col.Default = "'" + strings.Replace(v[0].Text, "'", "''", -1) + "'"
+	case v[0].Kind == schemafile.Number, v[0].IsExact("true"), v[0].IsExact("false"):
+		col.HasDefault = true
+		col.Default = v[0].Text
+	case v[0].Kind == schemafile.Word:
+		// the value of an enum.
+		col.HasDefault = true
+		value := v[0].Text
+		if e := p.enum(f.typ); e != nil {
+			for x, name := range e.names {
+				if name == value {
+					value = e.values[x]
+				}
+			}
+		}
+		col.Default = "'" + value + "'"
+	}
+}
+
+// cursor walks the tokens of a Prisma schema.
+type cursor struct {
+	toks []schemafile.Token
+	pos  int
+}
+
+func (c *cursor) done() bool {
+	return c.pos >= len(c.toks)
+}
+
+// peek returns the next token without consuming it, or an empty token at the
+// end.
+func (c *cursor) peek() schemafile.Token {
+	return c.peekAt(0)
+}
+
+// peekAt returns the token n tokens ahead, or an empty token past the end.
+func (c *cursor) peekAt(n int) schemafile.Token {
+	if c.pos+n >= len(c.toks) {
+		return schemafile.Token{Kind: schemafile.Punct}
+	}
+	return c.toks[c.pos+n]
+}
+
+func (c *cursor) next() schemafile.Token {
+	t := c.peek()
+	if !c.done() {
+		c.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it's the given word or punctuation.
+func (c *cursor) accept(s string) bool {
+	if t := c.peek(); t.IsExact(s) || t.IsPunct(s) {
+		c.pos++
+		return true
+	}
+	return false
+}
+
+func (c *cursor) skipNewlines() {
+	for c.peek().Kind == schemafile.Newline {
+		c.pos++
+	}
+}
+
+// line consumes and returns the tokens up to the end of the line, not counting
+// the newlines inside brackets and parentheses.
+func (c *cursor) line() []schemafile.Token {
+	start := c.pos
+	depth := 0
+	for !c.done() {
+		t := c.peek()
+		switch {
+		case t.Kind == schemafile.Newline && depth == 0:
+			toks := c.toks[start:c.pos]
+			c.pos++
+			return toks
+		case t.IsPunct("("), t.IsPunct("["), t.IsPunct("{"):
+			depth++
+		case t.IsPunct(")"), t.IsPunct("]"), t.IsPunct("}"):
+			depth--
+		}
+		c.pos++
+	}
+	return c.toks[start:]
+}
+
+// block consumes a block in braces, if the next token starts one, and returns
+// the tokens inside it.
+func (c *cursor) block() []schemafile.Token {
+	return c.enclosed("{", "}")
+}
+
+// parens consumes a list in parentheses, if the next token starts one, and
+// returns the tokens inside it.
+func (c *cursor) parens() []schemafile.Token {
+	return c.enclosed("(", ")")
+}
+
+func (c *cursor) enclosed(open, close string) []schemafile.Token {
+	if !c.peek().IsPunct(open) {
+		return nil
+	}
+	c.next()
+	start := c.pos
+	depth := 1
+	for !c.done() {
+		t := c.next()
+		switch {
+		case t.IsPunct(open):
+			depth++
+		case t.IsPunct(close):
+			depth--
+			if depth == 0 {
+				return c.toks[start : c.pos-1]
+			}
+		}
+	}
+	return c.toks[start:]
+}
+
+// split splits the tokens on the commas outside of parentheses and brackets.
+func split(toks []schemafile.Token) [][]schemafile.Token {
+	if len(toks) == 0 {
+		return nil
+	}
+	var parts [][]schemafile.Token
+	depth, start := 0, 0
+	for x, t := range toks {
+		switch {
+		case t.IsPunct("("), t.IsPunct("["):
+			depth++
+		case t.IsPunct(")"), t.IsPunct("]"):
+			depth--
+		case t.IsPunct(",") && depth == 0:
+			parts = append(parts, toks[start:x])
+			start = x + 1
+		}
+	}
+	return append(parts, toks[start:])
+}
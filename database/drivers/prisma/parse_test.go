@@ -0,0 +1,278 @@
+package prisma
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gnorm.org/gnorm/database"
+	"gnorm.org/gnorm/environ"
+)
+
+const schema = `
+datasource db {
+  provider = "postgresql"
+  url      = env("DATABASE_URL")
+}
+
+generator client {
+  provider = "prisma-client-js"
+}
+
+/// People who write books.
+model Author {
+  id    String @id @default(dbgenerated("gen_random_uuid()")) @db.Uuid
+  /// the name on the cover
+  name  String @db.VarChar(100)
+  books Book[]
+
+  @@map("authors")
+}
+
+model Book {
+  id        Int      @id @default(autoincrement())
+  authorId  String   @map("author_id") @db.Uuid
+  author    Author   @relation(fields: [authorId], references: [id], onDelete: Cascade)
+  isbn      String   @unique
+  kind      BookKind @default(NonFiction)
+  price     Decimal? @db.Decimal(10, 2)
+  tags      String[]
+  published DateTime @default(now()) @db.Timestamptz(6)
+  slug      String   @default(cuid())
+  edition   Int      @default(1)
+  printing  Int
+
+  @@unique([edition, printing], map: "books_edition")
+  @@index([authorId(sort: Desc)])
+  @@map("books")
+}
+
+// reviews aren't documented.
+model Review {
+  bookId Int  @map("book_id")
+  stars  Int
+  book   Book @relation(fields: [bookId], references: [id], map: "reviews_book")
+
+  @@id([bookId, stars])
+}
+
+enum BookKind {
+  Fiction    @map("fiction")
+  NonFiction @map("non-fiction")
+
+  @@map("book_kind")
+}
+`
+
+func parse(t *testing.T, contents string, schemaNames []string, filterTables func(schema, table string) bool) (*database.Info, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "schema.prisma")
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return Prisma{}.Parse(context.Background(), environ.Values{}.Logger(), path, schemaNames, filterTables)
+}
+
+func TestParse(t *testing.T) {
+	info, err := parse(t, schema, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Schemas) != 1 || info.Schemas[0].Name != "public" {
+		t.Fatalf("expected the public schema, but got %#v", info.Schemas)
+	}
+	s := info.Schemas[0]
+	expectedEnums := []*database.Enum{{
+		Name: "book_kind",
+		Values: []*database.EnumValue{
+			{Name: "fiction", Value: 1},
+			{Name: "non-fiction", Value: 2},
+		},
+	}}
+	if !reflect.DeepEqual(s.Enums, expectedEnums) {
+		t.Errorf("expected enums %#v, but got %#v", expectedEnums, s.Enums)
+	}
+	if len(s.Tables) != 3 {
+		t.Fatalf("expected 3 tables, but got %d", len(s.Tables))
+	}
+
+	authorsID := &database.Column{Name: "id", Type: "uuid", HasDefault: true, Default: "gen_random_uuid()", IsPrimaryKey: true, Ordinal: 1}
+	expectedAuthors := &database.Table{
+		Name:         "authors",
+		Type:         "BASE TABLE",
+		Comment:      "People who write books.",
+		IsInsertable: true,
+		Columns: []*database.Column{
+			authorsID,
+			{Name: "name", Type: "character varying", Length: 100, Comment: "the name on the cover", Ordinal: 2},
+		},
+		Indexes: []*database.Index{
+			{Name: "authors_pkey", IsUnique: true, Columns: []*database.Column{authorsID}},
+		},
+	}
+	if !reflect.DeepEqual(s.Tables[0], expectedAuthors) {
+		t.Errorf("expected authors %#v, but got %#v", expectedAuthors, s.Tables[0])
+	}
+
+	books := s.Tables[1]
+	bookID := &database.Column{Name: "id", Type: "integer", HasDefault: true, Default: "nextval('books_id_seq'::regclass)", IsPrimaryKey: true, Ordinal: 1}
+	authorID := &database.Column{Name: "author_id", Type: "uuid", Ordinal: 2, IsForeignKey: true, ForeignKey: &database.ForeignKey{
+		SchemaName:               "public",
+		TableName:                "books",
+		ColumnName:               "author_id",
+		Name:                     "books_author_id_fkey",
+		UniqueConstraintPosition: 1,
+		ForeignTableName:         "authors",
+		ForeignColumnName:        "id",
+	}}
+	isbn := &database.Column{Name: "isbn", Type: "text", Ordinal: 3}
+	edition := &database.Column{Name: "edition", Type: "integer", HasDefault: true, Default: "1", Ordinal: 9}
+	printing := &database.Column{Name: "printing", Type: "integer", Ordinal: 10}
+	expectedColumns := []*database.Column{
+		bookID,
+		authorID,
+		isbn,
+		{Name: "kind", Type: "book_kind", UserDefined: true, HasDefault: true, Default: "'non-fiction'", Ordinal: 4},
+		{Name: "price", Type: "numeric", Precision: 10, Scale: 2, Nullable: true, Ordinal: 5},
+		{Name: "tags", Type: "text", IsArray: true, Ordinal: 6},
+		{Name: "published", Type: "timestamp with time zone", HasDefault: true, Default: "CURRENT_TIMESTAMP", Ordinal: 7},
+		{Name: "slug", Type: "text", Ordinal: 8},
+		edition,
+		printing,
+	}
+	if !reflect.DeepEqual(books.Columns, expectedColumns) {
+		for x, c := range books.Columns {
+			t.Logf("column %d: %#v", x, c)
+		}
+		t.Errorf("wrong columns for books")
+	}
+	expectedIndexes := []*database.Index{
+		{Name: "books_pkey", IsUnique: true, Columns: []*database.Column{bookID}},
+		{Name: "books_isbn_key", IsUnique: true, Columns: []*database.Column{isbn}},
+		{Name: "books_edition", IsUnique: true, Columns: []*database.Column{edition, printing}},
+		{Name: "books_author_id_idx", Columns: []*database.Column{authorID}},
+	}
+	if !reflect.DeepEqual(books.Indexes, expectedIndexes) {
+		for x, i := range books.Indexes {
+			t.Logf("index %d: %#v", x, i)
+		}
+		t.Errorf("wrong indexes for books")
+	}
+
+	reviews := s.Tables[2]
+	if reviews.Name != "Review" || reviews.Comment != "" {
+		t.Errorf("expected the undocumented Review table, but got %#v", reviews)
+	}
+	expectedFK := &database.ForeignKey{SchemaName: "public", TableName: "Review", ColumnName: "book_id", Name: "reviews_book", UniqueConstraintPosition: 1, ForeignTableName: "books", ForeignColumnName: "id"}
+	if fk := reviews.Columns[0].ForeignKey; !reflect.DeepEqual(fk, expectedFK) {
+		t.Errorf("expected foreign key %#v, but got %#v", expectedFK, fk)
+	}
+	if !reviews.Columns[0].IsPrimaryKey || !reviews.Columns[1].IsPrimaryKey {
+		t.Errorf("expected the columns of @@id to be the primary key")
+	}
+	if len(reviews.Indexes) != 1 || reviews.Indexes[0].Name != "Review_pkey" || len(reviews.Indexes[0].Columns) != 2 {
+		t.Errorf("expected the index Review_pkey on both columns, but got %#v", reviews.Indexes)
+	}
+}
+
+func TestParseMySQL(t *testing.T) {
+	info, err := parse(t, `
+datasource db {
+  provider = "mysql"
+  url      = env("DATABASE_URL")
+}
+
+model User {
+  id     Int    @id @default(autoincrement()) @db.UnsignedInt
+  email  String @unique
+  role   Role   @default(USER)
+  active Boolean
+
+  @@map("users")
+}
+
+enum Role {
+  USER
+  ADMIN
+}
+`, []string{"app"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := info.Schemas[0]
+	if s.Name != "app" {
+		t.Errorf("expected the tables to be in the first schema, but got %q", s.Name)
+	}
+	expectedColumns := []*database.Column{
+		{Name: "id", Type: "int", HasDefault: true, IsPrimaryKey: true, Ordinal: 1},
+		{Name: "email", Type: "varchar", Length: 191, Ordinal: 2},
+		{Name: "role", Type: "enum", HasDefault: true, Default: "'USER'", Ordinal: 3},
+		{Name: "active", Type: "tinyint", Ordinal: 4},
+	}
+	if !reflect.DeepEqual(s.Tables[0].Columns, expectedColumns) {
+		for x, c := range s.Tables[0].Columns {
+			t.Logf("column %d: %#v", x, c)
+		}
+		t.Errorf("wrong columns for users")
+	}
+	expectedEnums := []*database.Enum{{
+		Table: "users",
+		Name:  "role",
+		Values: []*database.EnumValue{
+			{Name: "USER", Value: 1},
+			{Name: "ADMIN", Value: 2},
+		},
+	}}
+	if !reflect.DeepEqual(s.Enums, expectedEnums) {
+		t.Errorf("expected enums %#v, but got %#v", expectedEnums, s.Enums)
+	}
+}
+
+func TestParseSchemas(t *testing.T) {
+	info, err := parse(t, `
+model Log {
+  id Int @id
+  @@schema("audit")
+}
+model User {
+  id Int @id
+}
+model Session {
+  id Int @id
+}
+`, []string{"app", "audit"}, func(schema, table string) bool {
+		return table != "Session"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range info.Schemas {
+		for _, tbl := range s.Tables {
+			names = append(names, s.Name+"."+tbl.Name)
+		}
+	}
+	expected := []string{"app.User", "audit.Log"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected tables %q, but got %q", expected, names)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name, prisma string
+	}{
+		{"missing field", "model A {\n  id Int @id\n  @@index([nope])\n}\n"},
+		{"relation to missing field", "model A {\n  id Int @id\n  b B @relation(fields: [bId], references: [id])\n}\nmodel B {\n  id Int @id\n}\n"},
+		{"not a block", "model A\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := parse(t, test.prisma, nil, nil); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
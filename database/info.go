@@ -1,18 +1,22 @@
 package database // import "gnorm.org/gnorm/database"
 import (
-	"log"
+	"context"
+
+	"gnorm.org/gnorm/environ"
 )
 
 // Info is the collection of schema info from a database.
 type Info struct {
-	Schemas []*Schema // the list of schema info
+	Schemas []*Schema                           // the list of schema info
+	Queries map[string][]map[string]interface{} // the rows returned by each of the configured queries
 }
 
 // Schema is the information on a single named schema in the database.
 type Schema struct {
-	Name   string   // the original name of the schema in the DB
-	Tables []*Table // the list of tables in this schema
-	Enums  []*Enum  // the list of enums in this schema
+	Name     string   // the original name of the schema in the DB
+	Database string   // the name of the database the schema is from, when reading several
+	Tables   []*Table // the list of tables in this schema
+	Enums    []*Enum  // the list of enums in this schema
 }
 
 // Enum represents a type that has a set of allowed values.
@@ -71,9 +75,12 @@ type Column struct {
 	Type         string      // the original type of the column in the DB
 	IsArray      bool        // true if the column type is an array
 	Length       int         // non-zero if the type has a length (e.g. varchar[16])
+	Precision    int         // non-zero if the type has a precision (e.g. numeric(10,2))
+	Scale        int         // the number of digits after the decimal point, for types with a precision
 	UserDefined  bool        // true if the type is user-defined
 	Nullable     bool        // true if the column is not NON NULL
 	HasDefault   bool        // true if the column has a default
+	Default      string      // the expression of the column's default, if it has one
 	Comment      string      // the comment attached to the column
 	IsPrimaryKey bool        // true if the column is a primary key
 	Ordinal      int64       // the column's ordinal position
@@ -82,7 +89,44 @@ type Column struct {
 	Orig         interface{} // the raw database column data
 }
 
-// Driver defines the base interface for databases that are supported by gnorm
+// Driver defines the base interface for databases that are supported by gnorm.
+// Drivers should connect with Open, so that they honor the timeouts carried by
+// ctx, and log what they do, such as the queries they run, as debug messages to
+// log.
 type Driver interface {
-	Parse(log *log.Logger, conn string, schemaNames []string, filterTables func(schema, table string) bool) (*Info, error)
+	Parse(ctx context.Context, log environ.Logger, conn string, schemaNames []string, filterTables func(schema, table string) bool) (*Info, error)
+}
+
+// TLSConfigurer is implemented by drivers that can connect using TLS.
+// ConfigureTLS returns the connection string changed so that the driver
+// connects with the given TLS settings.
+type TLSConfigurer interface {
+	ConfigureTLS(conn string, t *TLS) (string, error)
+}
+
+// CatalogChecker is implemented by drivers that can check that the database
+// user is allowed to read what the driver reads the schema from.
+// CheckCatalogs returns an error if it can't connect.  Otherwise it returns
+// the result of each check by name, such as the name of a catalog table, with
+// a nil error for checks that pass.
+type CatalogChecker interface {
+	CheckCatalogs(ctx context.Context, log environ.Logger, conn string, schemaNames []string) (map[string]error, error)
+}
+
+// Features describes what a driver reads from the database, so that users know
+// what data their templates can rely on.
+type Features struct {
+	Enums       bool // reads enum types
+	Views       bool // reads views, marked with IsView
+	Comments    bool // reads the comments on tables and columns
+	Indexes     bool // reads indexes
+	ForeignKeys bool // reads foreign keys
+	Sequences   bool // reads sequences
+	Queries     bool // runs the queries carried by the context, see WithQueries
+}
+
+// FeatureReporter is implemented by drivers that can describe what they read
+// from the database.
+type FeatureReporter interface {
+	Features() Features
 }
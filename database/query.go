@@ -0,0 +1,76 @@
+package database
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// RunQueries runs each of the queries against db, and returns the rows of
+// results for each query by name.  Each row maps column names to values.
+// Values the database returns as bytes are converted to strings.  Drivers may
+// use this to run the queries carried by the context given to Parse.
+func RunQueries(db *DB, queries map[string]string) (map[string][]map[string]interface{}, error) {
+	out := make(map[string][]map[string]interface{}, len(queries))
+	for name, query := range queries {
+		rows, err := queryRows(db, query)
+		if err != nil {
+			return nil, errors.WithMessage(err, "error running query "+name)
+		}
+		out[name] = rows
+	}
+	return out, nil
+}
+
+// CheckQueries runs each of the queries against db in order of their names,
+// and returns the error each query got by name, or nil if it succeeded.  The
+// results are thrown away.  Each query is run with db.Check, so one failing
+// doesn't fail the rest.  Drivers may use this to implement CatalogChecker.
+func CheckQueries(db *DB, queries map[string]string) map[string]error {
+	names := make([]string, 0, len(queries))
+	for name := range queries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	out := make(map[string]error, len(queries))
+	for _, name := range names {
+		out[name] = db.Check(func() error {
+			_, err := queryRows(db, queries[name])
+			return err
+		})
+	}
+	return out
+}
+
+func queryRows(db *DB, query string) ([]map[string]interface{}, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	out := []map[string]interface{}{}
+	for rows.Next() {
+		vals := make([]interface{}, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for x := range vals {
+			ptrs[x] = &vals[x]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]interface{}, len(cols))
+		for x, col := range cols {
+			if b, ok := vals[x].([]byte); ok {
+				row[col] = string(b)
+			} else {
+				row[col] = vals[x]
+			}
+		}
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
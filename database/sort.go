@@ -0,0 +1,38 @@
+package database
+
+import "sort"
+
+// Sort puts the schema info in a stable order, so that reading the same
+// database twice gives the same result, whatever order the database returned
+// its rows in.  Tables, enums, and indexes are sorted by name.  Schemas stay in
+// the order they were configured in, and the columns of tables and indexes,
+// and the values of enums, stay in the order the driver read them in, since
+// that order is part of their definition.
+func (info *Info) Sort() {
+	for _, s := range info.Schemas {
+		sort.SliceStable(s.Tables, func(i, j int) bool {
+			return s.Tables[i].Name < s.Tables[j].Name
+		})
+		sort.SliceStable(s.Enums, func(i, j int) bool {
+			// (mysql) enums are named after their column, so they're only
+			// unique within their table.
+			if s.Enums[i].Table != s.Enums[j].Table {
+				return s.Enums[i].Table < s.Enums[j].Table
+			}
+			return s.Enums[i].Name < s.Enums[j].Name
+		})
+		for _, t := range s.Tables {
+			sort.SliceStable(t.Indexes, func(i, j int) bool {
+				return t.Indexes[i].Name < t.Indexes[j].Name
+			})
+		}
+	}
+}
+
+// SortColumns sorts the columns by their ordinal position.  Drivers use it to
+// return columns in the order they're defined in their table.
+func SortColumns(cols []*Column) {
+	sort.SliceStable(cols, func(i, j int) bool {
+		return cols[i].Ordinal < cols[j].Ordinal
+	})
+}
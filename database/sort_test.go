@@ -0,0 +1,77 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInfoSort(t *testing.T) {
+	info := &Info{Schemas: []*Schema{{
+		Name: "b",
+		Tables: []*Table{{
+			Name: "users",
+			Columns: []*Column{
+				{Name: "name", Ordinal: 2},
+				{Name: "id", Ordinal: 1},
+			},
+			Indexes: []*Index{
+				{Name: "users_pkey", Columns: []*Column{{Name: "id"}}},
+				{Name: "users_name_id", Columns: []*Column{{Name: "name"}, {Name: "id"}}},
+			},
+		}, {
+			Name: "accounts",
+		}},
+		Enums: []*Enum{
+			{Name: "status", Table: "users"},
+			{Name: "kind", Table: "users"},
+			{Name: "status", Table: "accounts"},
+		},
+	}, {
+		Name: "a",
+	}}}
+	info.Sort()
+
+	var names []string
+	for _, s := range info.Schemas {
+		names = append(names, s.Name)
+		for _, t := range s.Tables {
+			names = append(names, s.Name+"."+t.Name)
+			for _, c := range t.Columns {
+				names = append(names, s.Name+"."+t.Name+"."+c.Name)
+			}
+			for _, i := range t.Indexes {
+				for _, c := range i.Columns {
+					names = append(names, i.Name+"."+c.Name)
+				}
+			}
+		}
+		for _, e := range s.Enums {
+			names = append(names, e.Table+"."+e.Name)
+		}
+	}
+	expected := []string{
+		"b",
+		"b.accounts",
+		"b.users",
+		// columns and index columns keep their order.
+		"b.users.name",
+		"b.users.id",
+		"users_name_id.name",
+		"users_name_id.id",
+		"users_pkey.id",
+		"accounts.status",
+		"users.kind",
+		"users.status",
+		// schemas keep their order.
+		"a",
+	}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected order:\n%q\nbut got:\n%q", expected, names)
+	}
+
+	cols := info.Schemas[0].Tables[1].Columns
+	SortColumns(cols)
+	if cols[0].Name != "id" || cols[1].Name != "name" {
+		t.Fatalf("expected columns to be sorted by ordinal, but got %v, %v", cols[0].Name, cols[1].Name)
+	}
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+// TLS holds the TLS settings used to connect to the database.  Drivers that
+// implement TLSConfigurer apply them to the connection string, so they don't
+// have to be written in a driver-specific form.
+type TLS struct {
+	// CAFile is the path to a PEM file of the certificate authorities used to
+	// verify the server's certificate.  If empty, the system's roots are used.
+	CAFile string
+
+	// CertFile and KeyFile are the paths to the PEM client certificate and
+	// key used to authenticate with the server.
+	CertFile string
+	KeyFile  string
+
+	// ServerName is the name the server's certificate is verified against, if
+	// it differs from the host being connected to.
+	ServerName string
+
+	// InsecureSkipVerify, if true, doesn't verify the server's certificate.
+	InsecureSkipVerify bool
+}
+
+// Config returns a tls.Config with the settings of t.
+func (t *TLS) Config() (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+	if t.CAFile != "" {
+		b, err := ioutil.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, errors.WithMessage(err, "can't read TLS CAFile")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, errors.Errorf("no certificates found in TLS CAFile %v", t.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return nil, errors.New("TLS CertFile and KeyFile must be set together")
+	}
+	if t.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, errors.WithMessage(err, "can't load TLS client certificate")
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
@@ -2,8 +2,6 @@ package environ // import "gnorm.org/gnorm/environ"
 
 import (
 	"io"
-	"io/ioutil"
-	"log"
 )
 
 // Values encapsulates the environment of the OS.
@@ -13,15 +11,25 @@ type Values struct {
 	Stdout io.Writer
 	Stdin  io.Reader
 	Env    map[string]string
-	Log    *log.Logger
+	Log    Logger
 }
 
-// InitLog sets up env.Log to print to stderr if verbose is true, otherwise log
-// messages will be discarded.
+// Logger returns env.Log, or Discard if env.Log isn't set.
+func (env Values) Logger() Logger {
+	if env.Log == nil {
+		return Discard
+	}
+	return env.Log
+}
+
+// InitLog sets up env.Log to print warnings to stderr, if it isn't set up
+// already.  If verbose is true and env.Log is a *WriterLogger, it prints debug
+// messages too.
 func (env *Values) InitLog(verbose bool) {
-	if verbose {
-		env.Log = log.New(env.Stderr, "", 0)
-	} else {
-		env.Log = log.New(ioutil.Discard, "", 0)
+	if env.Log == nil {
+		env.Log = NewLogger(env.Stderr, LevelWarn, LogText)
+	}
+	if l, ok := env.Log.(*WriterLogger); ok && verbose {
+		l.SetLevel(LevelDebug)
 	}
 }
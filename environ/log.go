@@ -0,0 +1,175 @@
+package environ
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Level is the severity of a log message.  Loggers only write messages at or
+// above their level.
+type Level int
+
+// The log levels, from least to most severe.
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+)
+
+// String returns the name of the level, as used by ParseLevel.
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	}
+	return fmt.Sprintf("Level(%d)", int(l))
+}
+
+// ParseLevel returns the level with the given name: debug, info, or warn.
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	}
+	return 0, errors.Errorf("unknown log level %q, expected debug, info, or warn", s)
+}
+
+// LogFormat defines the formats a Logger can write messages in.
+type LogFormat int
+
+const (
+	// LogText writes each message on its own line, with warnings prefixed
+	// with "Warning: ".
+	LogText LogFormat = iota
+	// LogJSON writes each message as a JSON object on its own line, with the
+	// time, level, and message, for log tooling to parse.
+	LogJSON
+)
+
+// ParseLogFormat returns the log format with the given name: text or json.
+func ParseLogFormat(s string) (LogFormat, error) {
+	switch strings.ToLower(s) {
+	case "text":
+		return LogText, nil
+	case "json":
+		return LogJSON, nil
+	}
+	return 0, errors.Errorf("unknown log format %q, expected text or json", s)
+}
+
+// Logger is the interface gnorm and its database drivers log through, so that
+// programs that embed gnorm can send its messages to their own logging.  Debug
+// messages describe what gnorm is doing, such as each query a driver runs, info
+// messages the progress of a run, and warnings problems that don't stop it.
+//
+// *logrus.Logger, *logrus.Entry, and zap's *SugaredLogger implement Logger as
+// they are, and FromSlog adapts a *slog.Logger.  Implementations must be safe
+// to use from multiple goroutines.
+type Logger interface {
+	Debugf(format string, v ...interface{})
+	Infof(format string, v ...interface{})
+	Warnf(format string, v ...interface{})
+}
+
+// Discard is a Logger that discards all messages.
+var Discard Logger = discard{}
+
+type discard struct{}
+
+func (discard) Debugf(format string, v ...interface{}) {}
+func (discard) Infof(format string, v ...interface{})  {}
+func (discard) Warnf(format string, v ...interface{})  {}
+
+// WriterLogger is the Logger gnorm uses on the command line, which writes
+// messages at or above its level to an io.Writer.  It is safe to use from
+// multiple goroutines.  A nil *WriterLogger discards all messages.
+type WriterLogger struct {
+	mu     sync.Mutex
+	w      io.Writer
+	level  Level
+	format LogFormat
+	now    func() time.Time
+}
+
+// NewLogger returns a WriterLogger that writes messages at or above level to
+// w, in the given format.
+func NewLogger(w io.Writer, level Level, format LogFormat) *WriterLogger {
+	return &WriterLogger{w: w, level: level, format: format, now: time.Now}
+}
+
+// SetLevel changes the lowest level of messages the logger writes.
+func (l *WriterLogger) SetLevel(level Level) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.level = level
+}
+
+// SetFormat changes the format the logger writes messages in.
+func (l *WriterLogger) SetFormat(format LogFormat) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.format = format
+}
+
+// Debugf logs a message about what gnorm is doing, for debugging problems.
+func (l *WriterLogger) Debugf(format string, v ...interface{}) {
+	l.output(LevelDebug, fmt.Sprintf(format, v...))
+}
+
+// Infof logs a message about the progress of a run.
+func (l *WriterLogger) Infof(format string, v ...interface{}) {
+	l.output(LevelInfo, fmt.Sprintf(format, v...))
+}
+
+// Warnf logs a message about a problem that doesn't stop the run.
+func (l *WriterLogger) Warnf(format string, v ...interface{}) {
+	l.output(LevelWarn, fmt.Sprintf(format, v...))
+}
+
+func (l *WriterLogger) output(level Level, msg string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if level < l.level || l.w == nil {
+		return
+	}
+	msg = strings.TrimSuffix(msg, "\n")
+	if l.format == LogJSON {
+		b, err := json.Marshal(struct {
+			Time  string `json:"time"`
+			Level string `json:"level"`
+			Msg   string `json:"msg"`
+		}{l.now().UTC().Format(time.RFC3339Nano), level.String(), msg})
+		if err != nil {
+			return
+		}
+		l.w.Write(append(b, '\n'))
+		return
+	}
+	if level == LevelWarn {
+		msg = "Warning: " + msg
+	}
+	io.WriteString(l.w, msg+"\n")
+}
@@ -0,0 +1,58 @@
+package environ
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := NewLogger(buf, LevelInfo, LogText)
+	l.Debugf("hidden %d", 1)
+	l.Infof("shown %d", 2)
+	l.Warnf("careful")
+	expected := "shown 2\nWarning: careful\n"
+	if buf.String() != expected {
+		t.Fatalf("expected %q, but got %q", expected, buf.String())
+	}
+
+	buf.Reset()
+	l.SetFormat(LogJSON)
+	l.SetLevel(LevelDebug)
+	l.now = func() time.Time { return time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC) }
+	l.Debugf(`a "quoted" message`)
+	expected = `{"time":"2020-01-02T03:04:05Z","level":"debug","msg":"a \"quoted\" message"}` + "\n"
+	if buf.String() != expected {
+		t.Fatalf("expected %q, but got %q", expected, buf.String())
+	}
+
+	// a nil logger discards everything.
+	var nl *WriterLogger
+	nl.Warnf("nothing")
+}
+
+func TestValuesLogger(t *testing.T) {
+	if l := (Values{}).Logger(); l != Discard {
+		t.Fatalf("expected Discard when Log isn't set, but got %#v", l)
+	}
+	l := NewLogger(nil, LevelWarn, LogText)
+	if got := (Values{Log: l}).Logger(); got != l {
+		t.Fatalf("expected the Log that was set, but got %#v", got)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for s, expected := range map[string]Level{"debug": LevelDebug, "INFO": LevelInfo, "warn": LevelWarn} {
+		l, err := ParseLevel(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l != expected {
+			t.Errorf("expected %q to be %v, but got %v", s, expected, l)
+		}
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Fatal("expected an error for an unknown level")
+	}
+}
@@ -0,0 +1,41 @@
+//go:build go1.21
+
+package environ
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// FromSlog returns a Logger that logs to l, with gnorm's levels mapped to the
+// slog levels of the same names.
+func FromSlog(l *slog.Logger) Logger {
+	return slogLogger{l: l}
+}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (s slogLogger) Debugf(format string, v ...interface{}) {
+	s.log(slog.LevelDebug, format, v)
+}
+
+func (s slogLogger) Infof(format string, v ...interface{}) {
+	s.log(slog.LevelInfo, format, v)
+}
+
+func (s slogLogger) Warnf(format string, v ...interface{}) {
+	s.log(slog.LevelWarn, format, v)
+}
+
+// log formats the message only if l logs messages at level, since drivers log
+// many debug messages that are usually thrown away.
+func (s slogLogger) log(level slog.Level, format string, v []interface{}) {
+	ctx := context.Background()
+	if !s.l.Enabled(ctx, level) {
+		return
+	}
+	s.l.Log(ctx, level, fmt.Sprintf(format, v...))
+}
@@ -0,0 +1,30 @@
+//go:build go1.21
+
+package environ
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+)
+
+func TestFromSlog(t *testing.T) {
+	buf := &bytes.Buffer{}
+	h := slog.NewTextHandler(buf, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	l := FromSlog(slog.New(h))
+	l.Debugf("hidden %d", 1)
+	l.Infof("shown %d", 2)
+	l.Warnf("careful")
+	expected := "level=INFO msg=\"shown 2\"\nlevel=WARN msg=careful\n"
+	if buf.String() != expected {
+		t.Fatalf("expected %q, but got %q", expected, buf.String())
+	}
+}
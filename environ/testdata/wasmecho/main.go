@@ -0,0 +1,26 @@
+// Command wasmecho is a WebAssembly plugin used by the tests.  Build it with
+// GOOS=wasip1 GOARCH=wasm.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+func main() {
+	var in map[string]interface{}
+	if err := json.NewDecoder(os.Stdin).Decode(&in); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	switch os.Args[1] {
+	case "echo":
+		json.NewEncoder(os.Stdout).Encode(in)
+	case "shout":
+		json.NewEncoder(os.Stdout).Encode(map[string]interface{}{"data": fmt.Sprint(in["data"], "!")})
+	default:
+		fmt.Fprintln(os.Stderr, "unknown function", os.Args[1])
+		os.Exit(2)
+	}
+}
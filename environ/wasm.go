@@ -0,0 +1,153 @@
+package environ
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// wasmMemoryPages is the most memory a WebAssembly plugin may use, in 64KiB
+// pages, which makes 256MiB.
+const wasmMemoryPages = 4096
+
+// WasmPlugins runs WebAssembly plugins for templates.  Call is the function
+// templates use, and Start must be called with the context of each run that
+// uses it.
+//
+// WebAssembly plugins follow the same protocol as regular plugins, but run in a
+// sandbox as WASI command modules.  The function name is passed as the first
+// argument, the context is written to stdin as json, and the output is read
+// from stdout as json.  Plugins have no access to the filesystem, network, or
+// environment, and are limited to 256MiB of memory.
+type WasmPlugins struct {
+	paths map[string]string
+
+	mu       sync.RWMutex
+	runs     int
+	ctx      context.Context
+	runtime  wazero.Runtime
+	compiled map[string]wazero.CompiledModule
+}
+
+// Wasm returns the WebAssembly plugins at the given paths.  plugins maps plugin
+// names to the paths of their .wasm files.
+func Wasm(plugins map[string]string) *WasmPlugins {
+	return &WasmPlugins{paths: plugins}
+}
+
+// Start starts a run that uses the plugins.  Plugins are stopped once ctx is
+// done.  The returned function ends the run, and once every run has ended, the
+// runtime and compiled plugins are closed.  If runs overlap, plugins run with
+// the context of the latest one.
+func (w *WasmPlugins) Start(ctx context.Context) func() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.runs++
+	w.ctx = ctx
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		w.runs--
+		if w.runs > 0 {
+			return
+		}
+		w.ctx = nil
+		if w.runtime != nil {
+			w.runtime.Close(context.Background())
+			w.runtime = nil
+			w.compiled = nil
+		}
+	}
+}
+
+// Call calls function in the named plugin with ctx, and returns its output.
+func (w *WasmPlugins) Call(name, function string, ctx interface{}) (interface{}, error) {
+	runCtx, mod, err := w.module(name)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(map[string]interface{}{"data": ctx})
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	cfg := wazero.NewModuleConfig().
+		WithName("").
+		WithArgs(name, function).
+		WithStdin(bytes.NewReader(b)).
+		WithStdout(&stdout).
+		WithStderr(&stderr)
+	m, err := w.instantiate(runCtx, mod, cfg)
+	if m != nil {
+		defer m.Close(context.Background())
+	}
+	if exit, ok := err.(*sys.ExitError); ok && exit.ExitCode() == 0 {
+		err = nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "error running wasm plugin %q: %s", name, stderr.String())
+	}
+	o := make(map[string]interface{})
+	if err := json.Unmarshal(stdout.Bytes(), &o); err != nil {
+		return nil, errors.Wrapf(err, "error decoding output of wasm plugin %q", name)
+	}
+	return convert(o["data"]), nil
+}
+
+// instantiate runs mod to completion.  It holds a read lock, so plugins run
+// concurrently, but the runtime isn't closed while any of them are running.
+func (w *WasmPlugins) instantiate(ctx context.Context, mod wazero.CompiledModule, cfg wazero.ModuleConfig) (api.Module, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.runtime == nil {
+		return nil, errors.New("wasm plugins were closed while running")
+	}
+	return w.runtime.InstantiateModule(ctx, mod, cfg)
+}
+
+// module returns the context of the current run, and the compiled module for
+// the named plugin.
+func (w *WasmPlugins) module(name string) (context.Context, wazero.CompiledModule, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	ctx := w.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, errors.WithMessage(err, "wasm plugin "+name+" not run")
+	}
+	if mod, ok := w.compiled[name]; ok {
+		return ctx, mod, nil
+	}
+	path, ok := w.paths[name]
+	if !ok {
+		return nil, nil, errors.Errorf("unknown wasm plugin %q", name)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "error reading wasm plugin")
+	}
+	if w.runtime == nil {
+		rcfg := wazero.NewRuntimeConfig().
+			WithCloseOnContextDone(true).
+			WithMemoryLimitPages(wasmMemoryPages)
+		w.runtime = wazero.NewRuntimeWithConfig(ctx, rcfg)
+		wasi_snapshot_preview1.MustInstantiate(ctx, w.runtime)
+		w.compiled = map[string]wazero.CompiledModule{}
+	}
+	mod, err := w.runtime.CompileModule(ctx, b)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "error compiling wasm plugin "+path)
+	}
+	w.compiled[name] = mod
+	return ctx, mod, nil
+}
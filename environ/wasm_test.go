@@ -0,0 +1,85 @@
+package environ
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// buildWasmEcho compiles the wasmecho test plugin, skipping the test if the
+// installed go can't build wasip1 binaries.
+func buildWasmEcho(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "echo.wasm")
+	cmd := exec.Command("go", "build", "-o", out, "./testdata/wasmecho")
+	cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+	if b, err := cmd.CombinedOutput(); err != nil {
+		os.RemoveAll(dir)
+		t.Skipf("can't build wasm plugin: %v\n%s", err, b)
+	}
+	return out
+}
+
+func TestWasm(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping slow wasm compilation in short mode")
+	}
+	path := buildWasmEcho(t)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	w := Wasm(map[string]string{"echo": path})
+	end := w.Start(context.Background())
+	defer end()
+	wasm := w.Call
+	out, err := wasm("echo", "echo", []string{"Hello", "World"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, []string{"Hello", "World"}) {
+		t.Errorf("expected [Hello World], but got %#v", out)
+	}
+	// call again to use the cached module.
+	out, err = wasm("echo", "shout", "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "hi!" {
+		t.Errorf(`expected "hi!", but got %#v`, out)
+	}
+	if _, err := wasm("echo", "nope", "hi"); err == nil {
+		t.Error("expected error calling unknown function, but got nil")
+	}
+	if _, err := wasm("nope", "echo", "hi"); err == nil {
+		t.Error("expected error calling unknown plugin, but got nil")
+	}
+}
+
+func TestWasmCancelled(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping slow wasm compilation in short mode")
+	}
+	path := buildWasmEcho(t)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	w := Wasm(map[string]string{"echo": path})
+	ctx, cancel := context.WithCancel(context.Background())
+	end := w.Start(ctx)
+	if _, err := w.Call("echo", "echo", "hi"); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, err := w.Call("echo", "echo", "hi"); err == nil {
+		t.Error("expected error calling plugin after the run was cancelled, but got nil")
+	}
+	end()
+	if w.runtime != nil {
+		t.Error("expected the runtime to be closed once the run ended")
+	}
+}
@@ -1,28 +1,53 @@
 module gnorm.org/gnorm
 
-go 1.13
+go 1.18
 
 require (
 	github.com/BurntSushi/toml v0.3.0
 	github.com/andreyvit/diff v0.0.0-20170406064948-c7f18ee00883
+	github.com/cbroglie/mustache v1.0.1
 	github.com/codemodus/kace v0.5.0
+	github.com/flosch/pongo2/v6 v6.1.0
 	github.com/go-sql-driver/mysql v1.3.0
-	github.com/google/go-cmp v0.1.0
-	github.com/inconshreveable/mousetrap v1.0.0 // indirect
-	github.com/jessevdk/go-flags v1.4.0 // indirect
+	github.com/google/cel-go v0.17.8
+	github.com/google/go-cmp v0.5.9
 	github.com/jinzhu/inflection v1.0.0
-	github.com/kballard/go-shellquote v0.0.0-20180428030007-95032a82bc51 // indirect
 	github.com/lib/pq v0.0.0-20170810061220-e42267488fe3
 	github.com/magefile/mage v1.10.0
-	github.com/mattn/go-runewidth v0.0.2 // indirect
-	github.com/natefinch/gocog v0.0.0-20170818170132-3af1fb832aae // indirect
 	github.com/olekukonko/tablewriter v0.0.0-20170719101040-be5337e7b39e
 	github.com/pkg/browser v0.0.0-20170505125900-c90ca0c84f15
 	github.com/pkg/errors v0.8.0
+	github.com/pmezard/go-difflib v1.0.0
 	github.com/rakyll/statik v0.1.1
-	github.com/sergi/go-diff v1.1.0 // indirect
 	github.com/spf13/cobra v0.0.0-20170905172051-b78744579491
+	github.com/tetratelabs/wazero v1.0.0
+	github.com/yuin/gopher-lua v1.1.1
+	github.com/zalando/go-keyring v0.2.3
+	golang.org/x/crypto v0.23.0
+	golang.org/x/tools v0.6.0
+	gopkg.in/yaml.v2 v2.2.4
+	layeh.com/gopher-luar v1.0.11
+)
+
+require (
+	github.com/alessio/shellescape v1.4.1 // indirect
+	github.com/antlr/antlr4/runtime/Go/antlr/v4 v4.0.0-20230305170008-8188dc5388df // indirect
+	github.com/danieljoos/wincred v1.2.0 // indirect
+	github.com/godbus/dbus/v5 v5.1.0 // indirect
+	github.com/inconshreveable/mousetrap v1.0.0 // indirect
+	github.com/jessevdk/go-flags v1.4.0 // indirect
+	github.com/kballard/go-shellquote v0.0.0-20180428030007-95032a82bc51 // indirect
+	github.com/mattn/go-runewidth v0.0.2 // indirect
+	github.com/natefinch/gocog v0.0.0-20170818170132-3af1fb832aae // indirect
+	github.com/sergi/go-diff v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.0 // indirect
+	github.com/stoewer/go-strcase v1.2.0 // indirect
+	golang.org/x/exp v0.0.0-20220722155223-a9213eeb770e // indirect
+	golang.org/x/mod v0.8.0 // indirect
+	golang.org/x/sys v0.20.0 // indirect
+	golang.org/x/text v0.15.0 // indirect
+	google.golang.org/genproto/googleapis/api v0.0.0-20230525234035-dd9d682886f9 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20230525234030-28d5490b6b19 // indirect
+	google.golang.org/protobuf v1.30.0 // indirect
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
-	gopkg.in/yaml.v2 v2.2.4
 )
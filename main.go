@@ -16,7 +16,22 @@ import (
 //go:generate gocog ./site/content/cli/commands/version.md --startmark={{{ --endmark=}}}
 //go:generate gocog ./site/content/cli/commands/init.md --startmark={{{ --endmark=}}}
 //go:generate gocog ./site/content/cli/commands/gen.md --startmark={{{ --endmark=}}}
+//go:generate gocog ./site/content/cli/commands/clean.md --startmark={{{ --endmark=}}}
+//go:generate gocog ./site/content/cli/commands/lint.md --startmark={{{ --endmark=}}}
+//go:generate gocog ./site/content/cli/commands/validate.md --startmark={{{ --endmark=}}}
+//go:generate gocog ./site/content/cli/commands/doctor.md --startmark={{{ --endmark=}}}
+//go:generate gocog ./site/content/cli/commands/dump.md --startmark={{{ --endmark=}}}
+//go:generate gocog ./site/content/cli/commands/diff.md --startmark={{{ --endmark=}}}
+//go:generate gocog ./site/content/cli/commands/graph.md --startmark={{{ --endmark=}}}
+//go:generate gocog ./site/content/cli/commands/drivers.md --startmark={{{ --endmark=}}}
+//go:generate gocog ./site/content/cli/commands/config.md --startmark={{{ --endmark=}}}
 //go:generate gocog ./site/content/cli/commands/docs.md --startmark={{{ --endmark=}}}
+//go:generate gocog ./site/content/cli/commands/openapi.md --startmark={{{ --endmark=}}}
+//go:generate gocog ./site/content/cli/commands/graphql.md --startmark={{{ --endmark=}}}
+//go:generate gocog ./site/content/cli/commands/proto.md --startmark={{{ --endmark=}}}
+//go:generate gocog ./site/content/cli/commands/jsonschema.md --startmark={{{ --endmark=}}}
+//go:generate gocog ./site/content/cli/commands/avro.md --startmark={{{ --endmark=}}}
+//go:generate gocog ./site/content/cli/commands/export.md --startmark={{{ --endmark=}}}
 //go:generate gocog ./site/content/templates/functions.md --startmark={{{ --endmark=}}}
 //go:generate gocog ./site/content/cli/configuration.md --startmark={{{ --endmark=}}}
 
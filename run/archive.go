@@ -0,0 +1,153 @@
+package run
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"gnorm.org/gnorm/environ"
+)
+
+// archive formats, picked by the extension of the archive's filename.
+const (
+	archiveTarGz = "tar.gz"
+	archiveTar   = "tar"
+	archiveZip   = "zip"
+)
+
+// archiveFormat returns the format of the archive at path, from its extension.
+func archiveFormat(path string) (string, error) {
+	switch lower := strings.ToLower(path); {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return archiveTarGz, nil
+	case strings.HasSuffix(lower, ".tar"):
+		return archiveTar, nil
+	case strings.HasSuffix(lower, ".zip"):
+		return archiveZip, nil
+	default:
+		return "", errors.Errorf("can't tell the archive format of %q, it should end in .tar.gz, .tgz, .tar, or .zip", path)
+	}
+}
+
+// generateArchive calls generate to generate the files into an empty temporary
+// directory, and then writes them to cfg.Archive.  generate is given a copy of
+// cfg with that directory as its OutputDir, so cfg itself isn't changed.  Since
+// the directory starts out empty, every file is written, and PostRun is run on
+// each of them.  The manifest isn't included in the archive.
+func generateArchive(env environ.Values, cfg *Config, generate func(*Config) error) error {
+	format, err := archiveFormat(cfg.Archive)
+	if err != nil {
+		return err
+	}
+	dir, err := ioutil.TempDir("", "gnorm-archive")
+	if err != nil {
+		return errors.WithMessage(err, "error creating directory to generate the archive in")
+	}
+	defer os.RemoveAll(dir)
+
+	out := *cfg
+	out.Archive, out.OutputDir = "", dir
+	if err := generate(&out); err != nil {
+		return err
+	}
+	if err := writeArchive(env, dir, cfg.Archive, format); err != nil {
+		// don't leave a partial archive behind.
+		os.Remove(cfg.Archive)
+		return err
+	}
+	return nil
+}
+
+// writeArchive writes the files in dir, other than the manifest, to an archive
+// at path with the given format.  Paths in the archive are relative to dir.
+func writeArchive(env environ.Values, dir, path, format string) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.WithMessage(err, "error creating archive")
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil && cerr != nil {
+			err = errors.WithMessage(cerr, "error writing archive")
+		}
+	}()
+	var add func(name string, info os.FileInfo, r io.Reader) error
+	var closeArchive func() error
+	switch format {
+	case archiveZip:
+		zw := zip.NewWriter(f)
+		add = func(name string, info os.FileInfo, r io.Reader) error {
+			hdr, err := zip.FileInfoHeader(info)
+			if err != nil {
+				return err
+			}
+			hdr.Name = name
+			hdr.Method = zip.Deflate
+			w, err := zw.CreateHeader(hdr)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(w, r)
+			return err
+		}
+		closeArchive = zw.Close
+	default:
+		var w io.Writer = f
+		var gz *gzip.Writer
+		if format == archiveTarGz {
+			gz = gzip.NewWriter(f)
+			w = gz
+		}
+		tw := tar.NewWriter(w)
+		add = func(name string, info os.FileInfo, r io.Reader) error {
+			hdr, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			hdr.Name = name
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			_, err = io.Copy(tw, r)
+			return err
+		}
+		closeArchive = func() error {
+			if err := tw.Close(); err != nil {
+				return err
+			}
+			if gz != nil {
+				return gz.Close()
+			}
+			return nil
+		}
+	}
+	err = filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if rel == ManifestFile {
+			return nil
+		}
+		r, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		env.Logger().Debugf("adding %s to archive", rel)
+		return errors.Wrapf(add(filepath.ToSlash(rel), info, r), "error adding %s to archive", rel)
+	})
+	if err != nil {
+		return err
+	}
+	return errors.WithMessage(closeArchive(), "error writing archive")
+}
@@ -0,0 +1,129 @@
+package run
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"text/template"
+
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run/data"
+)
+
+func TestGenerateArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gnorm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	static := filepath.Join(dir, "static")
+	if err := os.MkdirAll(static, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(static, "README"), []byte("readme"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out")
+	env := environ.Values{Stdout: &bytes.Buffer{}}
+	cfg := &Config{
+		ConfigData:     data.ConfigData{OutputDir: out, StaticDir: static},
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver:         dummyDriver{},
+		TablePaths: []OutputTarget{{
+			Filename: template.Must(template.New("").Parse(`tables/{{.Table}}.txt`)),
+			Contents: template.Must(template.New("").Parse(`{{.Table.Name}}`)),
+		}},
+	}
+	expected := map[string]string{
+		"README":           "readme",
+		"tables/table.txt": "table",
+		"tables/tb2.txt":   "tb2",
+	}
+
+	cfg.Archive = filepath.Join(dir, "gen.tar.gz")
+	// the config isn't changed, even while the archive is being generated.
+	var during string
+	cfg.DataHookFuncs = []DataHookFunc{func(*data.DBData) error {
+		during = cfg.OutputDir
+		return nil
+	}}
+	if err := Generate(context.Background(), env, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if during != out {
+		t.Errorf("expected OutputDir to stay %q during the run, but got %q", out, during)
+	}
+	if cfg.OutputDir != out || cfg.Archive != filepath.Join(dir, "gen.tar.gz") {
+		t.Fatalf("expected config to be unchanged, but got OutputDir %q and Archive %q", cfg.OutputDir, cfg.Archive)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Fatalf("expected the output directory not to be created, but got %v", err)
+	}
+	f, err := os.Open(cfg.Archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[hdr.Name] = string(b)
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Fatalf("expected tar.gz to hold %v, but got %v", expected, files)
+	}
+
+	cfg.Archive = filepath.Join(dir, "gen.zip")
+	if err := Generate(context.Background(), env, cfg); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.OpenReader(cfg.Archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	files = map[string]string{}
+	for _, zf := range zr.File {
+		r, err := zf.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[zf.Name] = string(b)
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Fatalf("expected zip to hold %v, but got %v", expected, files)
+	}
+
+	cfg.Archive = filepath.Join(dir, "gen.rar")
+	if err := Generate(context.Background(), env, cfg); err == nil {
+		t.Fatal("expected an error for an unknown archive format, but got nil")
+	}
+}
@@ -0,0 +1,53 @@
+package run
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"gnorm.org/gnorm/environ"
+)
+
+// stagingPrefix starts the name of the staging directory made in the output
+// directory by atomic runs.
+const stagingPrefix = ".gnorm-staging"
+
+// stageOutput creates the staging directory for an atomic run.  It's made in
+// the output directory, so that files can be renamed into place, which is
+// only atomic within a filesystem.
+func stageOutput(cfg *Config) (string, error) {
+	if err := os.MkdirAll(outputDir(cfg), dirMode(cfg)); err != nil {
+		return "", errors.WithMessage(err, "error creating output directory")
+	}
+	dir, err := ioutil.TempDir(outputDir(cfg), stagingPrefix)
+	if err != nil {
+		return "", errors.WithMessage(err, "error creating staging directory")
+	}
+	return dir, nil
+}
+
+// commitStaged moves each file in the staging directory to the same path in
+// dest, replacing the file that's there.  Directories are created with
+// dirMode.
+func commitStaged(env environ.Values, staging, dest string, dirMode os.FileMode) error {
+	return filepath.Walk(staging, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(staging, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		if err := os.MkdirAll(filepath.Dir(target), dirMode); err != nil {
+			return errors.WithMessage(err, "error creating template output directory")
+		}
+		env.Logger().Debugf("moving %s into place", rel)
+		if err := os.Rename(path, target); err != nil {
+			return errors.Wrapf(err, "error moving generated file %s into place", rel)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,82 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run/data"
+)
+
+func TestGenerateAtomic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gnorm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "out")
+	env := environ.Values{Stdout: &bytes.Buffer{}}
+	cfg := &Config{
+		ConfigData:     data.ConfigData{OutputDir: out},
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver:         dummyDriver{},
+		TablePaths: []OutputTarget{{
+			Filename: template.Must(template.New("").Parse(`{{.Table}}.txt`)),
+			Contents: template.Must(template.New("").Parse(`{{.Table.Name}} {{.Params.run}}`)),
+		}},
+		Params: map[string]interface{}{"run": "first"},
+	}
+	if err := Generate(context.Background(), env, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// the template fails for the second table, after the first one has been
+	// rendered.
+	cfg.Atomic = true
+	cfg.Params["run"] = "second"
+	cfg.TablePaths[0].Contents = template.Must(template.New("").Parse(`{{.Table.Name}} {{.Params.run}}{{if eq .Table.Name "tb2"}}{{.Nope}}{{end}}`))
+	if err := Generate(context.Background(), env, cfg); err == nil {
+		t.Fatal("expected an error, but got nil")
+	}
+	checkFiles := func(expected map[string]string) {
+		t.Helper()
+		fis, err := ioutil.ReadDir(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, fi := range fis {
+			if strings.HasPrefix(fi.Name(), stagingPrefix) {
+				t.Errorf("expected staging directory to be removed, but found %s", fi.Name())
+			}
+		}
+		for file, contents := range expected {
+			b, err := ioutil.ReadFile(filepath.Join(out, file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != contents {
+				t.Errorf("expected %s to contain %q, but got %q", file, contents, b)
+			}
+		}
+	}
+	checkFiles(map[string]string{"table.txt": "table first", "tb2.txt": "tb2 first"})
+
+	cfg.TablePaths[0].Contents = template.Must(template.New("").Parse(`{{.Table.Name}} {{.Params.run}}`))
+	if err := Generate(context.Background(), env, cfg); err != nil {
+		t.Fatal(err)
+	}
+	checkFiles(map[string]string{"table.txt": "table second", "tb2.txt": "tb2 second"})
+	m, err := ReadManifest(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Outputs) != 2 || m.Outputs[0].SHA256 != sha256Hex("table second") {
+		t.Fatalf("expected manifest to describe the moved files, but got %#v", m.Outputs)
+	}
+}
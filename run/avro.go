@@ -0,0 +1,143 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run/data"
+)
+
+// Avro reads the database the same way Generate does, with the same filters,
+// and writes an Avro schema for each table to sink, named after the table with
+// a .avsc extension.  Each table is a record in the given namespace, if any,
+// with a field for each column.  Nullable columns are unions with null that
+// default to null.  Timestamps, dates, and uuids use Avro's logical types, as
+// do decimals with a precision.
+func Avro(ctx context.Context, env environ.Values, cfg *Config, sink OutputSink, namespace string) error {
+	info, err := parseDB(ctx, env, cfg)
+	if err != nil {
+		return err
+	}
+	db, err := makeData(ctx, env, info, cfg)
+	if err != nil {
+		return err
+	}
+	for _, s := range db.Schemas {
+		for _, t := range s.Tables {
+			name := t.Name
+			if len(db.Schemas) > 1 {
+				name = s.Name + name
+			}
+			name = graphqlName(name)
+			b, err := json.MarshalIndent(avroRecord(t, name, namespace), "", "  ")
+			if err != nil {
+				return errors.WithMessage(err, "can't encode Avro schema for table "+t.DBName)
+			}
+			if err := sink.WriteFile(name+".avsc", append(b, '\n'), 0644); err != nil {
+				return err
+			}
+			env.Logger().Infof("wrote %s.avsc", name)
+		}
+	}
+	return nil
+}
+
+// avroSchema is an Avro record, enum, or array schema, or a primitive type
+// with a logical type.
+type avroSchema struct {
+	Type        string       `json:"type"`
+	Name        string       `json:"name,omitempty"`
+	Namespace   string       `json:"namespace,omitempty"`
+	Doc         string       `json:"doc,omitempty"`
+	LogicalType string       `json:"logicalType,omitempty"`
+	Precision   int          `json:"precision,omitempty"`
+	Scale       int          `json:"scale,omitempty"`
+	Symbols     []string     `json:"symbols,omitempty"`
+	Items       interface{}  `json:"items,omitempty"`
+	Fields      []*avroField `json:"fields,omitempty"`
+}
+
+// avroField is a field of an Avro record.  Its Type is the name of a type, a
+// schema, or a union of them.
+type avroField struct {
+	Name    string           `json:"name"`
+	Doc     string           `json:"doc,omitempty"`
+	Type    interface{}      `json:"type"`
+	Default *json.RawMessage `json:"default,omitempty"`
+}
+
+// avroNull is the default of nullable fields.
+var avroNull = json.RawMessage("null")
+
+// avroRecord returns the record schema for rows of the table.  Enums are
+// defined where they're first used, and referred to by name after that, as
+// Avro requires.
+func avroRecord(t *data.Table, name, namespace string) *avroSchema {
+	rec := &avroSchema{Type: "record", Name: name, Namespace: namespace, Doc: t.Comment, Fields: []*avroField{}}
+	defined := map[*data.Enum]bool{}
+	for _, c := range t.Columns {
+		var typ interface{}
+		if e := columnEnum(c); e != nil {
+			ename := e.Name
+			if e.Table != nil && e.Table.DBName != "" {
+				ename = e.Table.Name + ename
+			}
+			ename = graphqlName(ename)
+			if defined[e] {
+				typ = ename
+			} else {
+				defined[e] = true
+				symbols := make([]string, len(e.Values))
+				for x, v := range e.Values {
+					symbols[x] = graphqlName(v.DBName)
+				}
+				typ = &avroSchema{Type: "enum", Name: ename, Symbols: symbols}
+			}
+		} else {
+			typ = avroType(c)
+		}
+		if c.IsArray {
+			typ = &avroSchema{Type: "array", Items: typ}
+		}
+		f := &avroField{Name: graphqlName(c.DBName), Doc: c.Comment, Type: typ}
+		if c.Nullable {
+			f.Type = []interface{}{"null", typ}
+			f.Default = &avroNull
+		}
+		rec.Fields = append(rec.Fields, f)
+	}
+	return rec
+}
+
+// avroType returns the Avro type for values of the column's database type,
+// not counting arrays.  Decimals without a precision, and types gnorm doesn't
+// know, are strings.
+func avroType(c *data.Column) interface{} {
+	s := openAPIType(c)
+	switch {
+	case s.Format == "int32":
+		return "int"
+	case s.Format == "int64":
+		return "long"
+	case s.Format == "float":
+		return "float"
+	case s.Format == "double":
+		return "double"
+	case s.Type == "number" && c.Precision > 0:
+		return &avroSchema{Type: "bytes", LogicalType: "decimal", Precision: c.Precision, Scale: c.Scale}
+	case s.Type == "boolean":
+		return "boolean"
+	case s.Format == "uuid":
+		return &avroSchema{Type: "string", LogicalType: "uuid"}
+	case s.Format == "date":
+		return &avroSchema{Type: "int", LogicalType: "date"}
+	case s.Format == "date-time":
+		return &avroSchema{Type: "long", LogicalType: "timestamp-micros"}
+	case s.Format == "byte":
+		return "bytes"
+	}
+	return "string"
+}
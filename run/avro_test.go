@@ -0,0 +1,132 @@
+package run
+
+import (
+	"context"
+	"testing"
+	"text/template"
+
+	"github.com/andreyvit/diff"
+
+	"gnorm.org/gnorm/database"
+	"gnorm.org/gnorm/environ"
+)
+
+func TestAvro(t *testing.T) {
+	cfg := &Config{
+		NameConversion: template.Must(template.New("").Funcs(environ.FuncMap).Parse(`{{pascal .}}`)),
+		Driver: infoDriver{&database.Info{Schemas: []*database.Schema{{
+			Name:  "public",
+			Enums: []*database.Enum{{Name: "book_type", Values: []*database.EnumValue{{Name: "fiction", Value: 1}, {Name: "non-fiction", Value: 2}}}},
+			Tables: []*database.Table{{
+				Name:    "books",
+				Comment: "books for sale",
+				Columns: []*database.Column{
+					{Name: "id", Type: "bigint", Comment: "the book's id"},
+					{Name: "title", Type: "varchar", Length: 200},
+					{Name: "type", Type: "book_type", UserDefined: true, Nullable: true},
+					{Name: "old_type", Type: "book_type", UserDefined: true},
+					{Name: "price", Type: "numeric", Precision: 10, Scale: 2},
+					{Name: "weight", Type: "numeric", Nullable: true},
+					{Name: "published", Type: "date"},
+					{Name: "updated_at", Type: "timestamp with time zone", Nullable: true},
+					{Name: "tags", Type: "text", IsArray: true},
+					{Name: "uid", Type: "uuid"},
+				},
+			}},
+		}}}},
+	}
+	sink := &MemorySink{}
+	if err := Avro(context.Background(), environ.Values{}, cfg, sink, "com.example"); err != nil {
+		t.Fatal(err)
+	}
+	expected := `
+{
+  "type": "record",
+  "name": "Books",
+  "namespace": "com.example",
+  "doc": "books for sale",
+  "fields": [
+    {
+      "name": "id",
+      "doc": "the book's id",
+      "type": "long"
+    },
+    {
+      "name": "title",
+      "type": "string"
+    },
+    {
+      "name": "type",
+      "type": [
+        "null",
+        {
+          "type": "enum",
+          "name": "BookType",
+          "symbols": [
+            "fiction",
+            "non_fiction"
+          ]
+        }
+      ],
+      "default": null
+    },
+    {
+      "name": "old_type",
+      "type": "BookType"
+    },
+    {
+      "name": "price",
+      "type": {
+        "type": "bytes",
+        "logicalType": "decimal",
+        "precision": 10,
+        "scale": 2
+      }
+    },
+    {
+      "name": "weight",
+      "type": [
+        "null",
+        "string"
+      ],
+      "default": null
+    },
+    {
+      "name": "published",
+      "type": {
+        "type": "int",
+        "logicalType": "date"
+      }
+    },
+    {
+      "name": "updated_at",
+      "type": [
+        "null",
+        {
+          "type": "long",
+          "logicalType": "timestamp-micros"
+        }
+      ],
+      "default": null
+    },
+    {
+      "name": "tags",
+      "type": {
+        "type": "array",
+        "items": "string"
+      }
+    },
+    {
+      "name": "uid",
+      "type": {
+        "type": "string",
+        "logicalType": "uuid"
+      }
+    }
+  ]
+}
+`[1:]
+	if got := string(sink.Files()["Books.avsc"]); got != expected {
+		t.Fatalf("unexpected schema:\n%s", diff.LineDiff(expected, got))
+	}
+}
@@ -0,0 +1,65 @@
+package run
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// renderedPaths records what each file rendered by a run was rendered from,
+// so that targets that render to the same path are caught, rather than the
+// last one silently overwriting the others.  It's safe to use from multiple
+// goroutines.  A nil *renderedPaths doesn't check anything.
+type renderedPaths struct {
+	mu      sync.Mutex
+	sources map[string]OutputFile
+}
+
+func newRenderedPaths() *renderedPaths {
+	return &renderedPaths{sources: map[string]OutputFile{}}
+}
+
+// claim records that the file at path, relative to the output directory, is
+// rendered from src.  It returns an error describing both sources if the path
+// was already rendered from something else.
+func (r *renderedPaths) claim(path string, src OutputFile) error {
+	if r == nil {
+		return nil
+	}
+	path = filepath.ToSlash(filepath.Clean(path))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if prev, ok := r.sources[path]; ok {
+		return errors.Errorf("%s and %s both render to %q, so one would overwrite the other; change the Filename templates, or the NameConversion, so they render to different paths", describeSource(prev), describeSource(src), path)
+	}
+	r.sources[path] = src
+	return nil
+}
+
+// describeSource describes what a file is rendered from, e.g. "table
+// public.users (templates/model.gotmpl)".
+func describeSource(f OutputFile) string {
+	var what string
+	switch f.Kind {
+	case OutputSchema:
+		what = "schema " + f.Schema
+	case OutputEnum:
+		what = "enum " + f.Schema + "." + f.Enum
+		if f.Table != "" {
+			what = "enum " + f.Schema + "." + f.Table + "." + f.Enum
+		}
+	case OutputTable:
+		what = "table " + f.Schema + "." + f.Table
+	default:
+		what = "the database"
+	}
+	if f.Database != "" {
+		what += " in database " + f.Database
+	}
+	if f.Template == "" {
+		return what
+	}
+	return fmt.Sprintf("%s (%s)", what, f.Template)
+}
@@ -0,0 +1,82 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"text/template"
+
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run/data"
+)
+
+func TestFilenameCollision(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gnorm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	env := environ.Values{Stdout: &bytes.Buffer{}}
+	cfg := &Config{
+		ConfigData:     data.ConfigData{OutputDir: dir},
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver:         dummyDriver{},
+		TablePaths: []OutputTarget{{
+			// both tables render to the same file.
+			Filename:     template.Must(template.New("").Parse(`{{.Schema}}/./models.go`)),
+			Contents:     template.Must(template.New("").Parse(`{{.Table.Name}}`)),
+			ContentsPath: "model.gotmpl",
+		}},
+	}
+	err = Generate(context.Background(), env, cfg)
+	if err == nil {
+		t.Fatal("expected an error, but got nil")
+	}
+	for _, s := range []string{`"schema/models.go"`, "table schema.table (model.gotmpl)", "table schema.tb2 (model.gotmpl)"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("expected error to contain %s, but got: %v", s, err)
+		}
+	}
+
+	// the same goes for different targets, even with --stdout.
+	cfg.TablePaths[0].Filename = template.Must(template.New("").Parse(`{{.Table}}.go`))
+	cfg.DBPaths = []OutputTarget{{
+		Filename:     template.Must(template.New("").Parse(`tb2.go`)),
+		Contents:     template.Must(template.New("").Parse(`db`)),
+		ContentsPath: "db.gotmpl",
+	}}
+	cfg.Stdout = true
+	err = Generate(context.Background(), env, cfg)
+	if err == nil || !strings.Contains(err.Error(), "the database (db.gotmpl)") {
+		t.Fatalf("expected an error naming the database target, but got %v", err)
+	}
+
+	// files written to stdout with "-" don't collide.
+	cfg.Stdout = false
+	cfg.TablePaths[0].Filename = template.Must(template.New("").Parse(`-`))
+	cfg.DBPaths[0].Filename = template.Must(template.New("").Parse(`-`))
+	if err := Generate(context.Background(), env, cfg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDescribeSource(t *testing.T) {
+	tests := []struct {
+		src      OutputFile
+		expected string
+	}{
+		{OutputFile{Kind: OutputSchema, Schema: "public", Template: "s.tpl"}, "schema public (s.tpl)"},
+		{OutputFile{Kind: OutputEnum, Schema: "public", Enum: "color"}, "enum public.color"},
+		{OutputFile{Kind: OutputEnum, Schema: "app", Table: "users", Enum: "status"}, "enum app.users.status"},
+		{OutputFile{Kind: OutputTable, Database: "billing", Schema: "public", Table: "users"}, "table public.users in database billing"},
+		{OutputFile{Kind: OutputDatabase, Template: "db.tpl"}, "the database (db.tpl)"},
+	}
+	for _, tt := range tests {
+		if s := describeSource(tt.src); s != tt.expected {
+			t.Errorf("expected %q, but got %q", tt.expected, s)
+		}
+	}
+}
@@ -1,12 +1,32 @@
 package run
 
 import (
+	"io"
+	"io/fs"
+	"os"
 	"text/template"
+	"time"
 
 	"gnorm.org/gnorm/database"
+	"gnorm.org/gnorm/environ"
 	"gnorm.org/gnorm/run/data"
 )
 
+// Database is one of several databases read in a single run.
+type Database struct {
+	// Name identifies the database in templates and output paths.
+	Name string
+
+	// Driver is the driver used to read the database.
+	Driver database.Driver
+
+	// ConnStr is the connection string for the database.
+	ConnStr string
+
+	// Schemas holds the names of the schemas to read.
+	Schemas []string
+}
+
 // Config holds the schema that is expected to exist in the gnorm.toml file.
 type Config struct {
 	data.ConfigData
@@ -35,6 +55,32 @@ type Config struct {
 	// "public.book_type" enum to ./gnorm/public/enums/users.go.
 	EnumPaths []OutputTarget
 
+	// DBPaths is a list of output targets used to render the data for the whole
+	// database.  Each target is rendered once, with all the schemas.
+	DBPaths []OutputTarget
+
+	// StaticDirs are more directories of static files to copy to the output
+	// directory, after the ones in StaticDir.
+	StaticDirs []StaticDir
+
+	// StaticTemplates holds the templates for the static files that are
+	// rendered with StaticData, by their path, rather than copied as they
+	// are.
+	StaticTemplates map[string]Template
+
+	// FS, if set, is the filesystem static files are read from, instead of
+	// the OS filesystem.  Paths to them are relative to its root.
+	FS fs.FS
+
+	// Sink, if set, receives the generated and static files instead of the
+	// OutputDir.
+	Sink OutputSink
+
+	// Logger, if set, receives the messages logged by Introspect and Render,
+	// and by the database driver they use, instead of warnings being written
+	// to stderr.  Generate logs to the Log of the environment it's given.
+	Logger environ.Logger
+
 	// NameConversion defines how the DBName of tables, schemas, and enums are
 	// converted into their Name value.  This is a template that may use all the
 	// regular functions.  The "." value is the DB name of the item. Thus, to
@@ -43,16 +89,220 @@ type Config struct {
 	// "{{pascal .}}".
 	NameConversion *template.Template
 
+	// NameConversionCommand, if specified, is a command with arguments that is
+	// used instead of NameConversion to convert DBNames into Names.  The DBNames
+	// are written to the command's stdin, one per line, and the command must
+	// write the converted names to stdout, one per line, in the same order.
+	NameConversionCommand []string
+
+	// NameConversionFormat is how the names are sent to and read back from the
+	// NameConversionCommand, either NameFormatLines, the default, or
+	// NameFormatJSON.
+	NameConversionFormat string
+
+	// IncludeExpr, if not nil, is evaluated for each table, and only tables
+	// for which it is true are included in the data passed to templates.
+	IncludeExpr *TableExpr
+
+	// ExcludeExpr, if not nil, is evaluated for each table, and tables for
+	// which it is true are left out of the data passed to templates.
+	ExcludeExpr *TableExpr
+
+	// ExcludeColumns maps "schema.table", or just "table" for tables of that
+	// name in any schema, to the names of columns to leave out of the data
+	// passed to templates.  Column names may be patterns, as described in
+	// ParseNamePattern.
+	ExcludeColumns map[string][]string
+
+	// OnlySchemas, if set, limits the schemas that files are generated for to
+	// the ones named in it, which must be schemas the config reads.  All the
+	// schemas are still read, so that foreign keys between them are kept.
+	OnlySchemas []string
+
+	// OnlyTables, if set, limits the tables that files are generated for to the
+	// ones that match one of its patterns.  Each pattern is "schema.table", or
+	// just "table" for tables of that name in any schema, where table may be a
+	// pattern as described in ParseNamePattern.  All the tables the other
+	// filters include are still read, so that foreign keys to tables that
+	// aren't picked are kept.
+	OnlyTables []string
+
+	// SchemaTypeMaps holds type maps that override the TypeMap and
+	// NullableTypeMap for the tables in a schema, keyed by schema name.
+	SchemaTypeMaps map[string]SchemaTypeMaps
+
+	// NullableWrapper, if not nil, is used to get the type of nullable columns
+	// whose type isn't in any NullableTypeMap.  It is run with the values .Type,
+	// the type the column's database type is mapped to for columns that aren't
+	// nullable, and .DBType, the column's database type.
+	NullableWrapper *template.Template
+
+	// ColumnTypes maps "schema.table.column" to the type of that column,
+	// overriding the type maps.
+	ColumnTypes map[string]string
+
+	// TypeMapper, if set, maps the types of columns instead of ColumnTypes,
+	// the type maps, and NullableWrapper.
+	TypeMapper TypeMapper
+
+	// LuaScript, if specified, is the path to a lua script that is run over the
+	// database data before any templates are rendered.  The script sees the
+	// data as the global value db, and may modify it or add values to the Meta
+	// maps of schemas, tables, columns, and enums.
+	LuaScript string
+
+	// DataHook, if specified, is a command with arguments that is run over the
+	// database data after the LuaScript.  The data is written to its stdin as
+	// JSON, and the command must write it back to stdout in the same form.
+	// Changes to the names, types, comments, and Meta maps of schemas, tables,
+	// columns, and enums are kept.  It's killed once the run is cancelled.
+	DataHook []string
+
+	// DataHookFuncs are run over the database data after the DataHook, in
+	// order, before any templates are rendered.  Use WithDataHookFunc to add
+	// them when embedding gnorm.
+	DataHookFuncs []DataHookFunc
+
 	// Driver holds a reference to the current database driver that was
 	// registered for the DBType and can connect using ConnStr.
 	Driver database.Driver
 
+	// Databases, if set, are read instead of the database given by Driver,
+	// ConnStr, and Schemas, and their schemas are all passed to the templates
+	// together.
+	Databases []Database
+
+	// SSHTunnel, if set, is the SSH server gnorm connects to the database
+	// through.  $GNORM_TUNNEL in ConnStr is replaced with the local address of
+	// the tunnel.
+	SSHTunnel *SSHTunnel
+
+	// TLS, if set, holds the TLS settings the driver uses to connect to the
+	// database.
+	TLS *database.TLS
+
+	// ConnectTimeout limits how long connecting to the database, and to the
+	// SSHTunnel if there is one, may take, and QueryTimeout limits how long
+	// each query made while reading the database may take.  Zero means no
+	// limit.
+	ConnectTimeout time.Duration
+	QueryTimeout   time.Duration
+
+	// Retries is the number of times reading the database is retried if it
+	// fails, waiting longer between each attempt.
+	Retries int
+
+	// ReadOnly, if true, reads the database in a read-only transaction, so that
+	// gnorm can't change the database even if its user is allowed to.
+	ReadOnly bool
+
+	// Snapshot, if set, is the path of a snapshot file made by gnorm dump that
+	// the schema info is read from, instead of connecting to the database.
+	Snapshot string
+
+	// Overlays, if set, are the paths of snapshot files that are layered, in
+	// order, over the schema info read from the database or Snapshot, to add
+	// or change tables, columns, comments, and enums that aren't in the
+	// database yet.
+	Overlays []string
+
+	// PostRunWarnOnly, if true, logs a warning when the PostRun command fails
+	// for a file, instead of aborting the run.
+	PostRunWarnOnly bool
+
+	// PostRunWorkers is the number of PostRun commands run at the same time.
+	// Values less than 2 run them one at a time, right after each file is
+	// written.
+	PostRunWorkers int
+
+	// Parallel is the number of files rendered and written at the same time.
+	// Values less than 2 generate them one at a time.  Files are always
+	// generated one at a time when writing to stdout or doing a dry run, so
+	// that the output is in the same order every time.
+	Parallel int
+
 	// Params contains any data you may want to pass to your templates.  This is
 	// a good way to make templates reusable with different configuration values
 	// for different situations.  The values in this field will be available in
 	// the .Params value for all templates.
 	Params map[string]interface{}
 
+	// Stdout, if true, writes all generated output to stdout, each file preceded
+	// by a separator line with its path, instead of writing files to disk.
+	// Output targets whose filename renders to "-" are always written to
+	// stdout, without a separator line.
+	Stdout bool
+
+	// DryRun, if true, writes a unified diff of the changes generating would
+	// make to the output directory to stdout, followed by a list of the new,
+	// changed, and removed files, instead of writing anything.
+	DryRun bool
+
+	// Atomic, if true, writes the generated files to a staging directory in
+	// the output directory, and only moves them into place once every
+	// template and PostRun command has succeeded, so that a failed run leaves
+	// the output directory as it was.
+	Atomic bool
+
+	// Archive, if set, is the path of a .tar.gz, .tgz, .tar, or .zip file
+	// that the generated files are written to, instead of the output
+	// directory.
+	Archive string
+
+	// FileMode, if not zero, is the permissions generated files are given,
+	// including existing ones.  Otherwise new files are created with 0600, and
+	// existing files keep their permissions.
+	FileMode os.FileMode
+
+	// DirMode, if not zero, is the permissions directories created for
+	// generated files are given, before the umask.  It defaults to 0700.
+	DirMode os.FileMode
+
+	// AllowUnsafeFilenames, if true, turns off the checks that stop rendered
+	// filenames from being absolute, outside the output directory, or invalid
+	// on Windows.
+	AllowUnsafeFilenames bool
+
+	// Report, if true, prints a summary of what the run did to stdout when it
+	// finishes, and ReportFile, if set, is a file the summary is written to as
+	// JSON.
+	Report     bool
+	ReportFile string
+
+	// Prune, if true, deletes the orphaned files listed in the manifest after
+	// generating, the same as Clean with orphans set.
+	Prune bool
+
+	// Queries maps names to SQL queries that are run against the database
+	// when it is read, on the same connection.  The rows each query returns
+	// are available to templates as .DB.Queries.name.
+	Queries map[string]string
+
+	// Header, if not nil, is rendered with a HeaderData value and written at
+	// the start of every generated file.
+	Header *template.Template
+
+	// License, if set, is written as a comment at the start of every generated
+	// file in a language whose comments gnorm knows, before the Header.
+	License string
+
+	// HeaderTimestamp, if true, sets the Timestamp value passed to Header.
+	// Note that files with a timestamp change every time they're generated.
+	HeaderTimestamp bool
+
+	// Version is the version of gnorm, passed to the Header template.
+	Version string
+
+	// Funcs holds functions made available to templates in addition to
+	// gnorm's default functions.  It is not set from gnorm.toml, use
+	// WithFuncs to add functions when embedding gnorm.
+	Funcs template.FuncMap
+
+	// RunHooks are started with the context of each run of Generate or
+	// Render, and ended when the run ends.  It is not set from gnorm.toml, use
+	// WithRunHook to add hooks when embedding gnorm.
+	RunHooks []RunHook
+
 	// TemplateEngine, if specified, describes a command line tool to run to
 	// render your templates, allowing you to use your preferred templating
 	// engine.  If not specified, go's text/template will be used to render.
@@ -73,12 +323,79 @@ type Config struct {
 	}
 }
 
+// TypeMaps holds a TypeMap and NullableTypeMap that override the entries in
+// the config's TypeMap and NullableTypeMap for some of the tables.
+type TypeMaps struct {
+	TypeMap         map[string]string
+	NullableTypeMap map[string]string
+}
+
+// SchemaTypeMaps holds the type maps for a schema, and the type maps for
+// tables in that schema, keyed by table name.  Table type maps take
+// precedence over the schema type maps.
+type SchemaTypeMaps struct {
+	TypeMaps
+	Tables map[string]TypeMaps
+}
+
 // OutputTarget contains a template that generates a filename to write to, and a
 // template that generates the contents for that file.  If an external template
 // engine is used, Contents will be nil, and the template at ContentsPath should
-// be used.
+// be used.  Otherwise ContentsPath is the file Contents was parsed from, and is
+// used to show the failing template source when Contents fails to execute.
 type OutputTarget struct {
 	Filename     *template.Template
-	Contents     *template.Template
+	Contents     Template
 	ContentsPath string
+
+	// TrimBlankLines removes blank lines from the start and end of the
+	// rendered contents.
+	TrimBlankLines bool
+
+	// CollapseBlankLines replaces each run of consecutive blank lines in the
+	// rendered contents with a single blank line.
+	CollapseBlankLines bool
+
+	// Format, if set, is the formatter run on the rendered contents, either
+	// FormatGofmt or FormatGoimports.
+	Format string
+
+	// Params, if not nil, is passed to the templates of this target instead of
+	// Config.Params.
+	Params map[string]interface{}
+
+	// FileMode, if not zero, is the permissions of the files generated for
+	// this target, instead of Config.FileMode.
+	FileMode os.FileMode
+}
+
+// params returns the Params passed to the templates of the target.
+func (t OutputTarget) params(cfg *Config) map[string]interface{} {
+	if t.Params != nil {
+		return t.Params
+	}
+	return cfg.Params
+}
+
+// StaticDir is a directory of static files to copy to the output directory.
+type StaticDir struct {
+	// Dir is the directory the files are copied from.
+	Dir string
+
+	// Dest is the directory under the output directory the files are copied
+	// to, or "" for the output directory itself.
+	Dest string
+
+	// Include, if not empty, are globs that files must match to be copied, and
+	// Exclude are globs for files that aren't copied.  Globs are matched
+	// against the path of the file relative to Dir, and its base name.
+	Include []string
+	Exclude []string
+}
+
+// Template is a parsed template that writes its output to w using the given
+// data.  A *text/template.Template is a Template, as are the templates
+// produced by the other built-in template engines.
+type Template interface {
+	Execute(w io.Writer, data interface{}) error
 }
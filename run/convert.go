@@ -2,36 +2,51 @@ package run
 
 import (
 	"bytes"
-	"log"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
 
 	"github.com/pkg/errors"
 	"gnorm.org/gnorm/database"
+	"gnorm.org/gnorm/environ"
 	"gnorm.org/gnorm/run/data"
 )
 
 type nameConverter func(s string) (string, error)
 
-func makeData(log *log.Logger, info *database.Info, cfg *Config) (*data.DBData, error) {
-	convert := func(s string) (string, error) {
-		buf := &bytes.Buffer{}
-		err := cfg.NameConversion.Execute(buf, s)
-		if err != nil {
-			return "", errors.WithMessage(err, "name conversion failed for "+s)
+func makeData(ctx context.Context, env environ.Values, info *database.Info, cfg *Config) (*data.DBData, error) {
+	log := env.Logger()
+	convert, err := makeConverter(env, info, cfg)
+	if err != nil {
+		return nil, err
+	}
+	types := cfg.TypeMapper
+	if types == nil {
+		if types, err = newTypeMapper(cfg); err != nil {
+			return nil, err
 		}
-		return buf.String(), nil
 	}
 
 	db := &data.DBData{
 		SchemasByName: make(map[string]*data.Schema, len(info.Schemas)),
+		Queries:       info.Queries,
 	}
-	var err error
 	for _, s := range info.Schemas {
 		sch := &data.Schema{
 			DBName:       s.Name,
+			Database:     s.Database,
 			TablesByName: make(map[string]*data.Table, len(s.Tables)),
 		}
 		db.Schemas = append(db.Schemas, sch)
-		db.SchemasByName[sch.DBName] = sch
+		if s.Database != "" {
+			db.SchemasByName[s.Database+"."+sch.DBName] = sch
+		} else {
+			db.SchemasByName[sch.DBName] = sch
+		}
 
 		sch.Name, err = convert(s.Name)
 		if err != nil {
@@ -88,9 +103,12 @@ func makeData(log *log.Logger, info *database.Info, cfg *Config) (*data.DBData,
 					DBType:             c.Type,
 					IsArray:            c.IsArray,
 					Length:             c.Length,
+					Precision:          c.Precision,
+					Scale:              c.Scale,
 					UserDefined:        c.UserDefined,
 					Nullable:           c.Nullable,
 					HasDefault:         c.HasDefault,
+					Default:            c.Default,
 					Comment:            c.Comment,
 					IsPrimaryKey:       c.IsPrimaryKey,
 					Ordinal:            c.Ordinal,
@@ -105,15 +123,15 @@ func makeData(log *log.Logger, info *database.Info, cfg *Config) (*data.DBData,
 					return nil, errors.WithMessage(err, "column")
 				}
 				var ok bool
-				if c.Nullable {
-					col.Type, ok = cfg.NullableTypeMap[c.Type]
-					if !ok {
-						log.Println("Unmapped nullable type:", c.Type)
-					}
-				} else {
-					col.Type, ok = cfg.TypeMap[c.Type]
-					if !ok {
-						log.Println("Unmapped type:", c.Type)
+				col.Type, ok, err = types.MapType(col)
+				if err != nil {
+					return nil, errors.WithMessage(err, "column "+t.Name+"."+c.Name)
+				}
+				if !ok {
+					if c.Nullable {
+						log.Infof("Unmapped nullable type: %v", c.Type)
+					} else {
+						log.Infof("Unmapped type: %v", c.Type)
 					}
 				}
 			}
@@ -137,10 +155,18 @@ func makeData(log *log.Logger, info *database.Info, cfg *Config) (*data.DBData,
 				table.IndexesByName[index.DBName] = index
 			}
 		}
-		if err = mapSchemaForeignKeyReferences(s, sch, convert); err != nil {
+		if err = mapSchemaForeignKeyReferences(log, s, sch, convert); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.LuaScript != "" {
+		if err := runLuaScript(cfg.LuaScript, db, cfg.Params); err != nil {
 			return nil, err
 		}
 	}
+	if err := runDataHooks(ctx, env, cfg, db); err != nil {
+		return nil, err
+	}
 	return db, nil
 }
 
@@ -155,11 +181,11 @@ func filterPrimaryKeyColumns(columns data.Columns) data.Columns {
 	return pkColumns
 }
 
-func mapSchemaForeignKeyReferences(isch *database.Schema, sch *data.Schema, convert nameConverter) error {
+func mapSchemaForeignKeyReferences(log environ.Logger, isch *database.Schema, sch *data.Schema, convert nameConverter) error {
 	for _, t := range isch.Tables {
 		table, ok := sch.TablesByName[t.Name]
 		if !ok {
-			log.Printf("Unmapped table %v in %v", t.Name, isch.Name)
+			log.Debugf("Unmapped table %v in %v", t.Name, isch.Name)
 			continue
 		}
 
@@ -168,19 +194,19 @@ func mapSchemaForeignKeyReferences(isch *database.Schema, sch *data.Schema, conv
 		for _, c := range t.Columns {
 			column, ok := table.ColumnsByName[c.Name]
 			if !ok {
-				log.Printf("Unmapped column %v in %v.%v", c.Name, isch.Name, t.Name)
+				log.Debugf("Unmapped column %v in %v.%v", c.Name, isch.Name, t.Name)
 				continue
 			}
 
 			if column.IsFK {
 				refTable, ok := sch.TablesByName[c.ForeignKey.ForeignTableName]
 				if !ok {
-					log.Printf("Unmapped foreign table %v in %v", c.ForeignKey.ForeignTableName, isch.Name)
+					log.Debugf("Unmapped foreign table %v in %v", c.ForeignKey.ForeignTableName, isch.Name)
 					continue
 				}
 				refColumn, ok := refTable.ColumnsByName[c.ForeignKey.ForeignColumnName]
 				if !ok {
-					log.Printf("Unmapped foreign column %v in %v.%v", c.ForeignKey.ForeignColumnName, isch.Name, c.ForeignKey.ForeignTableName)
+					log.Debugf("Unmapped foreign column %v in %v.%v", c.ForeignKey.ForeignColumnName, isch.Name, c.ForeignKey.ForeignTableName)
 					continue
 				}
 
@@ -205,8 +231,14 @@ func mapSchemaForeignKeyReferences(isch *database.Schema, sch *data.Schema, conv
 			}
 		}
 
-		for _, fkc := range fkColumnsByFKNames {
-			err := mapForeignTable(fkc, convert)
+		// foreign keys are added in name order, so they're the same each run.
+		fkNames := make([]string, 0, len(fkColumnsByFKNames))
+		for name := range fkColumnsByFKNames {
+			fkNames = append(fkNames, name)
+		}
+		sort.Strings(fkNames)
+		for _, name := range fkNames {
+			err := mapForeignTable(fkColumnsByFKNames[name], convert)
 			if err != nil {
 				return err
 			}
@@ -246,3 +278,166 @@ func mapForeignTable(fkc data.ForeignKeyColumns, convert nameConverter) error {
 
 	return nil
 }
+
+// The formats that names may be sent to the NameConversionCommand in.
+const (
+	// NameFormatLines sends the names one per line, and reads the converted
+	// names back one per line.
+	NameFormatLines = "lines"
+	// NameFormatJSON sends the names as a JSON array of strings, and reads the
+	// converted names back as a JSON array of strings.
+	NameFormatJSON = "json"
+)
+
+// makeConverter returns the function used to convert the DBNames of items in
+// the database into their Name.  If a NameConversionCommand is configured, all
+// the names are converted up front with a single run of the command, otherwise
+// the NameConversion template is executed for each name.
+func makeConverter(env environ.Values, info *database.Info, cfg *Config) (nameConverter, error) {
+	if len(cfg.NameConversionCommand) == 0 {
+		return func(s string) (string, error) {
+			buf := &bytes.Buffer{}
+			err := cfg.NameConversion.Execute(buf, s)
+			if err != nil {
+				return "", errors.WithMessage(err, "name conversion failed for "+s)
+			}
+			return buf.String(), nil
+		}, nil
+	}
+	names := dbNames(info)
+	converted, err := runNameCommand(env, cfg.NameConversionCommand, cfg.NameConversionFormat, names)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]string, len(names))
+	for x := range names {
+		m[names[x]] = converted[x]
+	}
+	return func(s string) (string, error) {
+		name, ok := m[s]
+		if !ok {
+			return "", errors.Errorf("name conversion command produced no name for %q", s)
+		}
+		return name, nil
+	}, nil
+}
+
+// dbNames returns the unique list of all the DBNames in info that will be
+// converted into Names, in the order they are first found.
+func dbNames(info *database.Info) []string {
+	var names []string
+	seen := map[string]bool{}
+	add := func(s string) {
+		if !seen[s] {
+			seen[s] = true
+			names = append(names, s)
+		}
+	}
+	for _, s := range info.Schemas {
+		add(s.Name)
+		for _, e := range s.Enums {
+			add(e.Name)
+			for _, v := range e.Values {
+				add(v.Name)
+			}
+		}
+		for _, t := range s.Tables {
+			add(t.Name)
+			for _, c := range t.Columns {
+				add(c.Name)
+				if c.ForeignKey != nil {
+					add(c.ForeignKey.Name)
+				}
+			}
+			for _, i := range t.Indexes {
+				add(i.Name)
+			}
+		}
+	}
+	return names
+}
+
+// runNameCommand runs the given command line, writing the names to its stdin
+// in the given format.  The command is expected to write the converted names
+// to stdout in the same format, in the same order.
+func runNameCommand(env environ.Values, command []string, format string, names []string) ([]string, error) {
+	conv := func(s string) string { return env.Env[s] }
+	args := make([]string, len(command))
+	for x, s := range command {
+		args[x] = os.Expand(s, conv)
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	envvars := make([]string, 0, len(env.Env))
+	for k, v := range env.Env {
+		envvars = append(envvars, k+"="+v)
+	}
+	cmd.Env = envvars
+	input, err := encodeNames(format, names)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	env.Logger().Debugf("converting %d names with %q", len(names), args)
+	if err := cmd.Run(); err != nil {
+		cl := strings.Join(args, " ")
+		if stderr.Len() > 0 {
+			return nil, errors.WithMessage(err, fmt.Sprintf("failed to run name conversion command %q\n%s", cl, stderr.String()))
+		}
+		return nil, errors.WithMessage(err, "failed to run name conversion command: "+cl)
+	}
+	converted, err := decodeNames(format, stdout.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if len(converted) != len(names) {
+		return nil, errors.Errorf("name conversion command returned %d names, but was sent %d", len(converted), len(names))
+	}
+	return converted, nil
+}
+
+// encodeNames returns the names as they're sent to a name conversion command
+// in the given format.
+func encodeNames(format string, names []string) ([]byte, error) {
+	switch format {
+	case "", NameFormatLines:
+		input := strings.Join(names, "\n")
+		if len(names) > 0 {
+			input += "\n"
+		}
+		return []byte(input), nil
+	case NameFormatJSON:
+		if names == nil {
+			names = []string{}
+		}
+		b, err := json.Marshal(names)
+		if err != nil {
+			return nil, errors.WithMessage(err, "can't convert names to json")
+		}
+		return b, nil
+	}
+	return nil, errors.Errorf("unknown NameConversionFormat %q, expected %q or %q", format, NameFormatLines, NameFormatJSON)
+}
+
+// decodeNames parses the output of a name conversion command in the given
+// format.
+func decodeNames(format string, b []byte) ([]string, error) {
+	if format == NameFormatJSON {
+		var converted []string
+		if err := json.Unmarshal(b, &converted); err != nil {
+			return nil, errors.WithMessage(err, "can't parse the output of the name conversion command as a json array of strings")
+		}
+		return converted, nil
+	}
+	out := strings.TrimSuffix(string(b), "\n")
+	if out == "" {
+		return nil, nil
+	}
+	converted := strings.Split(out, "\n")
+	for x := range converted {
+		converted[x] = strings.TrimSuffix(converted[x], "\r")
+	}
+	return converted, nil
+}
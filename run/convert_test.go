@@ -1,8 +1,12 @@
 package run
 
 import (
-	"bytes"
-	"log"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
 	"testing"
 	"text/template"
 
@@ -54,9 +58,7 @@ func TestMakeData(t *testing.T) {
 		}},
 	}
 
-	buf := &bytes.Buffer{}
-	l := log.New(buf, "", 0)
-	data, err := makeData(l, info, c)
+	data, err := makeData(context.Background(), environ.Values{}, info, c)
 	if err != nil {
 		t.Fatal("unexpected error from convertNames", err)
 	}
@@ -205,8 +207,7 @@ func TestForeignKeyRefs(t *testing.T) {
 		}},
 	}
 
-	log := log.New(&bytes.Buffer{}, "", 0)
-	data, err := makeData(log, info, c)
+	data, err := makeData(context.Background(), environ.Values{}, info, c)
 	if err != nil {
 		t.Fatalf("unexpected err: %s", err)
 	}
@@ -286,3 +287,247 @@ func TestForeignKeyRefs(t *testing.T) {
 		t.Fatalf("incorrect foreign key ref column; expected %s, got %s", "col_3", name)
 	}
 }
+
+func TestForeignKeyOrder(t *testing.T) {
+	t.Parallel()
+
+	c := &Config{
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+	}
+	table := &database.Table{Name: "tbl_1"}
+	names := []string{"fk_c", "fk_a", "fk_e", "fk_b", "fk_d"}
+	for _, name := range names {
+		table.Columns = append(table.Columns, &database.Column{
+			Name:         "col_" + name,
+			Type:         "int",
+			IsForeignKey: true,
+			ForeignKey: &database.ForeignKey{
+				Name:              name,
+				SchemaName:        "public",
+				TableName:         "tbl_1",
+				ColumnName:        "col_" + name,
+				ForeignTableName:  "tbl_2",
+				ForeignColumnName: "col_1",
+			},
+		})
+	}
+	info := &database.Info{
+		Schemas: []*database.Schema{{
+			Name: "public",
+			Tables: []*database.Table{table, {
+				Name:    "tbl_2",
+				Columns: []*database.Column{{Name: "col_1", Type: "int"}},
+			}},
+		}},
+	}
+
+	data, err := makeData(context.Background(), environ.Values{}, info, c)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	expected := []string{"fk_a", "fk_b", "fk_c", "fk_d", "fk_e"}
+	if fks := data.Schemas[0].TablesByName["tbl_1"].ForeignKeys.DBNames(); !reflect.DeepEqual([]string(fks), expected) {
+		t.Fatalf("expected foreign keys %q, but got %q", expected, fks)
+	}
+	if refs := data.Schemas[0].TablesByName["tbl_2"].ForeignKeyRefs.DBNames(); !reflect.DeepEqual([]string(refs), expected) {
+		t.Fatalf("expected foreign key refs %q, but got %q", expected, refs)
+	}
+}
+
+// testNamer is run by TestMain when the test binary is used as a name
+// conversion command.
+func testNamer() {
+	if os.Getenv("GNORM_NAMEHELPER") == NameFormatJSON {
+		var names []string
+		if err := json.NewDecoder(os.Stdin).Decode(&names); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		for x := range names {
+			names[x] = "j_" + names[x]
+		}
+		json.NewEncoder(os.Stdout).Encode(names)
+		return
+	}
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		fmt.Println("x_" + scanner.Text())
+	}
+}
+
+func TestNameConversionCommand(t *testing.T) {
+	c := &Config{
+		NameConversionCommand: []string{os.Args[0]},
+	}
+	info := &database.Info{
+		Schemas: []*database.Schema{{
+			Name: "schema",
+			Tables: []*database.Table{{
+				Name: "table",
+				Columns: []*database.Column{{
+					Name: "col1",
+					Type: "int",
+				}, {
+					Name: "table",
+					Type: "int",
+				}},
+			}},
+		}},
+	}
+	env := environ.Values{
+		Env: map[string]string{"GNORM_NAMEHELPER": "1"},
+	}
+	data, err := makeData(context.Background(), env, info, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := data.Schemas[0].Name; name != "x_schema" {
+		t.Errorf("schema name expected %q but got %q", "x_schema", name)
+	}
+	if name := data.Schemas[0].Tables[0].Name; name != "x_table" {
+		t.Errorf("table name expected %q but got %q", "x_table", name)
+	}
+	if names := data.Schemas[0].Tables[0].Columns.Names(); names[0] != "x_col1" || names[1] != "x_table" {
+		t.Errorf("column names expected [x_col1 x_table] but got %q", names)
+	}
+}
+
+func TestMapType(t *testing.T) {
+	cfg := &Config{
+		ConfigData: data.ConfigData{
+			TypeMap:         map[string]string{"numeric": "float64", "text": "string"},
+			NullableTypeMap: map[string]string{"numeric": "sql.NullFloat64"},
+		},
+		SchemaTypeMaps: map[string]SchemaTypeMaps{
+			"billing": {
+				TypeMaps: TypeMaps{TypeMap: map[string]string{"numeric": "decimal.Decimal"}},
+				Tables: map[string]TypeMaps{
+					"invoices": {NullableTypeMap: map[string]string{"numeric": "decimal.NullDecimal"}},
+				},
+			},
+		},
+	}
+	tests := []struct {
+		schema, table, dbType string
+		nullable              bool
+		expected              string
+	}{
+		{"public", "users", "numeric", false, "float64"},
+		{"billing", "users", "numeric", false, "decimal.Decimal"},
+		{"billing", "users", "text", false, "string"},
+		{"billing", "users", "numeric", true, "sql.NullFloat64"},
+		{"billing", "invoices", "numeric", true, "decimal.NullDecimal"},
+		{"billing", "invoices", "numeric", false, "decimal.Decimal"},
+	}
+	types, err := newTypeMapper(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		typ, ok := types.mapType(tt.schema, tt.table, tt.dbType, tt.nullable)
+		if !ok || typ != tt.expected {
+			t.Errorf("%s.%s %s (nullable %v): expected %q, but got %q", tt.schema, tt.table, tt.dbType, tt.nullable, tt.expected, typ)
+		}
+	}
+	if _, ok := types.mapType("billing", "invoices", "uuid", false); ok {
+		t.Error("expected unmapped type to not be found")
+	}
+}
+
+func TestColumnTypes(t *testing.T) {
+	c := &Config{
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		ConfigData: data.ConfigData{
+			TypeMap: map[string]string{"text": "string"},
+		},
+		ColumnTypes: map[string]string{"public.events.payload": "json.RawMessage"},
+	}
+	info := &database.Info{
+		Schemas: []*database.Schema{{
+			Name: "public",
+			Tables: []*database.Table{{
+				Name: "events",
+				Columns: []*database.Column{
+					{Name: "name", Type: "text"},
+					{Name: "payload", Type: "text"},
+				},
+			}},
+		}},
+	}
+	db, err := makeData(context.Background(), environ.Values{}, info, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cols := db.Schemas[0].Tables[0].Columns
+	if cols[0].Type != "string" {
+		t.Errorf("expected name column to use the TypeMap type string, but got %q", cols[0].Type)
+	}
+	if cols[1].Type != "json.RawMessage" {
+		t.Errorf("expected payload column type json.RawMessage, but got %q", cols[1].Type)
+	}
+}
+
+func TestNullableWrapper(t *testing.T) {
+	c := &Config{
+		NameConversion:  template.Must(template.New("").Parse(`{{.}}`)),
+		NullableWrapper: template.Must(template.New("").Parse(`sql.Null[{{.Type}}]`)),
+		ConfigData: data.ConfigData{
+			TypeMap:         map[string]string{"text": "string", "integer": "int"},
+			NullableTypeMap: map[string]string{"integer": "sql.NullInt64"},
+		},
+	}
+	info := &database.Info{
+		Schemas: []*database.Schema{{
+			Name: "public",
+			Tables: []*database.Table{{
+				Name: "users",
+				Columns: []*database.Column{
+					{Name: "name", Type: "text", Nullable: true},
+					{Name: "age", Type: "integer", Nullable: true},
+					{Name: "id", Type: "uuid", Nullable: true},
+				},
+			}},
+		}},
+	}
+	db, err := makeData(context.Background(), environ.Values{}, info, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x, expected := range []string{"sql.Null[string]", "sql.NullInt64", ""} {
+		if got := db.Schemas[0].Tables[0].Columns[x].Type; got != expected {
+			t.Errorf("column %d: expected type %q, but got %q", x, expected, got)
+		}
+	}
+}
+
+func TestNameConversionCommandJSON(t *testing.T) {
+	c := &Config{
+		NameConversionCommand: []string{os.Args[0]},
+		NameConversionFormat:  NameFormatJSON,
+	}
+	info := &database.Info{
+		Schemas: []*database.Schema{{
+			Name: "schema",
+			Tables: []*database.Table{{
+				Name: "table",
+				Columns: []*database.Column{{
+					Name: "line\nbreak",
+					Type: "int",
+				}},
+			}},
+		}},
+	}
+	env := environ.Values{
+		Env: map[string]string{"GNORM_NAMEHELPER": NameFormatJSON},
+	}
+	data, err := makeData(context.Background(), env, info, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := data.Schemas[0].Name; name != "j_schema" {
+		t.Errorf("schema name expected %q but got %q", "j_schema", name)
+	}
+	if name := data.Schemas[0].Tables[0].Columns[0].Name; name != "j_line\nbreak" {
+		t.Errorf("column name expected %q but got %q", "j_line\nbreak", name)
+	}
+}
@@ -11,7 +11,8 @@ import (
 // DBData is all the data about a database that we know.
 type DBData struct {
 	Schemas       []*Schema
-	SchemasByName map[string]*Schema `yaml:"-" json:"-"` // dbname to schema
+	SchemasByName map[string]*Schema                  `yaml:"-" json:"-"`                   // dbname to schema, or database.dbname when reading several databases
+	Queries       map[string][]map[string]interface{} `yaml:",omitempty" json:",omitempty"` // the rows returned by each of the configured queries
 }
 
 // SchemaData is the data passed to schema templates.
@@ -38,13 +39,29 @@ type EnumData struct {
 	Params map[string]interface{}
 }
 
+// DatabaseData is the data passed to whole-database templates.
+type DatabaseData struct {
+	DB     *DBData
+	Config ConfigData
+	Params map[string]interface{}
+}
+
+// StaticData is the data passed to static files that are rendered as
+// templates.
+type StaticData struct {
+	Config ConfigData
+	Params map[string]interface{}
+}
+
 // Schema is the data about a DB schema.
 type Schema struct {
-	Name         string            // the converted name of the schema
-	DBName       string            // the original name of the schema in the DB
-	Tables       Tables            // the list of tables in this schema
-	Enums        Enums             // the list of enums in this schema
-	TablesByName map[string]*Table `yaml:"-" json:"-"` // dbnames to tables
+	Name         string                 // the converted name of the schema
+	DBName       string                 // the original name of the schema in the DB
+	Database     string                 `yaml:",omitempty" json:",omitempty"` // the name of the database the schema is from, when reading several
+	Tables       Tables                 // the list of tables in this schema
+	Enums        Enums                  // the list of enums in this schema
+	TablesByName map[string]*Table      `yaml:"-" json:"-"`                   // dbnames to tables
+	Meta         map[string]interface{} `yaml:",omitempty" json:",omitempty"` // values added by the LuaScript or data hooks
 }
 
 // Table is the data about a DB Table.
@@ -63,8 +80,9 @@ type Table struct {
 	IndexesByName  map[string]*Index      `yaml:"-" json:"-"` // indexname to index
 	ForeignKeys    ForeignKeys            // Foreign Keys
 	ForeignKeyRefs ForeignKeys            // Foreign Keys referencing this table
-	FKByName       map[string]*ForeignKey `yaml:"-" json:"-"` // Foreign Keys by foreign key name
-	FKRefsByName   map[string]*ForeignKey `yaml:"-" json:"-"` // Foreign Keys referencing this table by foreign key name
+	FKByName       map[string]*ForeignKey `yaml:"-" json:"-"`                   // Foreign Keys by foreign key name
+	FKRefsByName   map[string]*ForeignKey `yaml:"-" json:"-"`                   // Foreign Keys referencing this table by foreign key name
+	Meta           map[string]interface{} `yaml:",omitempty" json:",omitempty"` // values added by the LuaScript or data hooks
 }
 
 // HasPrimaryKey returns true if Table has one or more primary keys.
@@ -91,9 +109,12 @@ type Column struct {
 	DBType             string                       // the original type of the column in the DB
 	IsArray            bool                         // true if the column type is an array
 	Length             int                          // non-zero if the type has a length (e.g. varchar[16])
+	Precision          int                          // non-zero if the type has a precision (e.g. numeric(10,2))
+	Scale              int                          // the number of digits after the decimal point, for types with a precision
 	UserDefined        bool                         // true if the type is user-defined
 	Nullable           bool                         // true if the column is not NON NULL
 	HasDefault         bool                         // true if the column has a default
+	Default            string                       // the expression of the column's default, if it has one
 	Comment            string                       // the comment attached to the column
 	IsPrimaryKey       bool                         // true if the column is a primary key
 	Ordinal            int64                        // the column's ordinal position
@@ -101,8 +122,9 @@ type Column struct {
 	HasFKRef           bool                         // true if the column is referenced by a foreign key
 	FKColumn           *ForeignKeyColumn            // foreign key column definition
 	FKColumnRefs       ForeignKeyColumns            // all foreign key columns referencing this column
-	FKColumnRefsByName map[string]*ForeignKeyColumn `yaml:"-" json:"-"` // all foreign key columns referencing this column by foreign key name
-	Orig               interface{}                  `yaml:"-" json:"-"` // the raw database column data
+	FKColumnRefsByName map[string]*ForeignKeyColumn `yaml:"-" json:"-"`                   // all foreign key columns referencing this column by foreign key name
+	Orig               interface{}                  `yaml:"-" json:"-"`                   // the raw database column data
+	Meta               map[string]interface{}       `yaml:",omitempty" json:",omitempty"` // values added by the LuaScript or data hooks
 }
 
 // ForeignKey contains the
@@ -135,11 +157,12 @@ type Index struct {
 
 // Enum represents a type that has a set of allowed values.
 type Enum struct {
-	Name   string       // the converted name of the enum
-	DBName string       // the original name of the enum in the DB
-	Schema *Schema      `yaml:"-" json:"-"` // the schema the enum is in
-	Table  *Table       `yaml:"-" json:"-"` // (mysql) the table this enum is part of
-	Values []*EnumValue // the list of possible values for this enum
+	Name   string                 // the converted name of the enum
+	DBName string                 // the original name of the enum in the DB
+	Schema *Schema                `yaml:"-" json:"-"` // the schema the enum is in
+	Table  *Table                 `yaml:"-" json:"-"` // (mysql) the table this enum is part of
+	Values []*EnumValue           // the list of possible values for this enum
+	Meta   map[string]interface{} `yaml:",omitempty" json:",omitempty"` // values added by the LuaScript or data hooks
 }
 
 // EnumValue is one of the named values for an enum.
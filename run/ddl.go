@@ -0,0 +1,238 @@
+package run
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gnorm.org/gnorm/run/data"
+)
+
+// DDLDialect defines the flavors of SQL that DDL can be written in.
+type DDLDialect int
+
+const (
+	// DDLStandard writes driver-neutral SQL, with identifiers quoted with
+	// double quotes and comments written as SQL comments.
+	DDLStandard DDLDialect = iota
+	// DDLPostgres writes SQL for postgres, with enums created as types and
+	// comments set with COMMENT ON.
+	DDLPostgres
+	// DDLMySQL writes SQL for mysql, with identifiers quoted with backticks
+	// and enums and comments written inline.
+	DDLMySQL
+)
+
+// writeDDL writes the CREATE statements that would make the schema in db, in
+// the given dialect.  It's used by both Export and Preview.  The output is
+// canonical: tables, indexes, and foreign keys are sorted by name, and foreign
+// keys are added after all the tables are created.  It's rebuilt from what
+// gnorm read from the database, so check constraints and the queries behind
+// views are left out, and views are written as comments.
+func writeDDL(buf *bytes.Buffer, db *data.DBData, dialect DDLDialect) {
+	var fks data.ForeignKeys
+	for _, s := range db.Schemas {
+		if dialect != DDLMySQL {
+			for _, e := range ddlEnums(s) {
+				fmt.Fprintf(buf, "CREATE TYPE %s AS ENUM (%s);\n\n", ddlQualified(dialect, s, e.DBName), ddlValues(e))
+			}
+		}
+		for _, t := range ddlTables(s) {
+			if t.IsView {
+				// gnorm doesn't read the definitions of views.
+				var cols []string
+				for _, c := range t.Columns {
+					cols = append(cols, ddlIdent(dialect, c.DBName)+" "+ddlType(dialect, c))
+				}
+				fmt.Fprintf(buf, "-- VIEW %s (%s)\n\n", ddlTableName(dialect, t), strings.Join(cols, ", "))
+				continue
+			}
+			writeDDLTable(buf, t, dialect)
+			fks = append(fks, graphFKs(t)...)
+		}
+	}
+	for _, fk := range fks {
+		var cols, refs []string
+		for _, c := range fk.FKColumns {
+			cols = append(cols, ddlIdent(dialect, c.ColumnDBName))
+			refs = append(refs, ddlIdent(dialect, c.RefColumnDBName))
+		}
+		fmt.Fprintf(buf, "ALTER TABLE %s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s);\n",
+			ddlTableName(dialect, fk.Table), ddlIdent(dialect, fk.DBName), strings.Join(cols, ", "), ddlTableName(dialect, fk.RefTable), strings.Join(refs, ", "))
+	}
+	// drop the blank line after the last statement.
+	if fks == nil && buf.Len() > 0 {
+		buf.Truncate(buf.Len() - 1)
+	}
+}
+
+func writeDDLTable(buf *bytes.Buffer, t *data.Table, dialect DDLDialect) {
+	name := ddlTableName(dialect, t)
+	if dialect == DDLStandard {
+		writeSQLComment(buf, t.Comment)
+	}
+	fmt.Fprintf(buf, "CREATE TABLE %s (\n", name)
+	var lines []string
+	for _, c := range t.Columns {
+		line := ddlIdent(dialect, c.DBName) + " " + ddlType(dialect, c)
+		if !c.Nullable {
+			line += " NOT NULL"
+		}
+		if c.Default != "" {
+			line += " DEFAULT " + c.Default
+		}
+		if dialect == DDLMySQL && c.Comment != "" {
+			line += " COMMENT " + ddlString(c.Comment)
+		}
+		if dialect == DDLStandard && c.Comment != "" {
+			// the comment goes on its own line, so it can't swallow the comma.
+			lines = append(lines, "  -- "+strings.Replace(c.Comment, "\n", " ", -1)+"\n  "+line)
+			continue
+		}
+		lines = append(lines, "  "+line)
+	}
+	if len(t.PrimaryKeys) > 0 {
+		var cols []string
+		for _, c := range t.PrimaryKeys {
+			cols = append(cols, ddlIdent(dialect, c.DBName))
+		}
+		lines = append(lines, "  PRIMARY KEY ("+strings.Join(cols, ", ")+")")
+	}
+	buf.WriteString(strings.Join(lines, ",\n"))
+	buf.WriteString("\n)")
+	if dialect == DDLMySQL && t.Comment != "" {
+		buf.WriteString(" COMMENT " + ddlString(t.Comment))
+	}
+	buf.WriteString(";\n")
+	if dialect == DDLPostgres {
+		if t.Comment != "" {
+			fmt.Fprintf(buf, "COMMENT ON TABLE %s IS %s;\n", name, ddlString(t.Comment))
+		}
+		for _, c := range t.Columns {
+			if c.Comment != "" {
+				fmt.Fprintf(buf, "COMMENT ON COLUMN %s.%s IS %s;\n", name, ddlIdent(dialect, c.DBName), ddlString(c.Comment))
+			}
+		}
+	}
+	for _, idx := range ddlIndexes(t) {
+		unique := ""
+		if idx.IsUnique {
+			unique = "UNIQUE "
+		}
+		var cols []string
+		for _, c := range idx.Columns {
+			cols = append(cols, ddlIdent(dialect, c.DBName))
+		}
+		fmt.Fprintf(buf, "CREATE %sINDEX %s ON %s (%s);\n", unique, ddlIdent(dialect, idx.DBName), name, strings.Join(cols, ", "))
+	}
+	buf.WriteString("\n")
+}
+
+// ddlType returns the column's type as it's written in a CREATE TABLE.  Enums
+// that belong to a table, as they do in mysql, and all enums in mysql, are
+// written inline.
+func ddlType(dialect DDLDialect, c *data.Column) string {
+	if e := columnEnum(c); e != nil {
+		if dialect == DDLMySQL || (e.Table != nil && e.Table.DBName != "") {
+			return "ENUM(" + ddlValues(e) + ")"
+		}
+		t := ddlQualified(dialect, e.Schema, e.DBName)
+		if c.IsArray {
+			t += "[]"
+		}
+		return t
+	}
+	return graphType(c)
+}
+
+// ddlTables returns the schema's tables, sorted by name.
+func ddlTables(s *data.Schema) data.Tables {
+	tables := append(data.Tables(nil), s.Tables...)
+	sort.Slice(tables, func(i, j int) bool { return tables[i].DBName < tables[j].DBName })
+	return tables
+}
+
+// ddlEnums returns the schema's enums that don't belong to a table, sorted by
+// name.
+func ddlEnums(s *data.Schema) data.Enums {
+	var enums data.Enums
+	for _, e := range s.Enums {
+		if e.Table == nil || e.Table.DBName == "" {
+			enums = append(enums, e)
+		}
+	}
+	sort.Slice(enums, func(i, j int) bool { return enums[i].DBName < enums[j].DBName })
+	return enums
+}
+
+// ddlIndexes returns the table's indexes, sorted by name, without the index
+// that backs its primary key, which the PRIMARY KEY creates.
+func ddlIndexes(t *data.Table) data.Indexes {
+	var indexes data.Indexes
+	for _, idx := range t.Indexes {
+		if idx.DBName == "PRIMARY" || (idx.IsUnique && sameColumns(idx.Columns, t.PrimaryKeys)) {
+			continue
+		}
+		indexes = append(indexes, idx)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i].DBName < indexes[j].DBName })
+	return indexes
+}
+
+// sameColumns reports whether a and b have the same columns, in the same
+// order.
+func sameColumns(a, b data.Columns) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for x := range a {
+		if a[x].DBName != b[x].DBName {
+			return false
+		}
+	}
+	return true
+}
+
+// ddlValues returns the enum's values as a list of SQL strings.
+func ddlValues(e *data.Enum) string {
+	vals := make([]string, len(e.Values))
+	for x, v := range e.Values {
+		vals[x] = ddlString(v.DBName)
+	}
+	return strings.Join(vals, ", ")
+}
+
+// ddlTableName returns the name of the table qualified by its schema.
+func ddlTableName(dialect DDLDialect, t *data.Table) string {
+	return ddlQualified(dialect, t.Schema, t.DBName)
+}
+
+// ddlQualified returns name qualified by the schema.
+func ddlQualified(dialect DDLDialect, s *data.Schema, name string) string {
+	return ddlIdent(dialect, s.DBName) + "." + ddlIdent(dialect, name)
+}
+
+// ddlIdent quotes the identifier for the dialect.
+func ddlIdent(dialect DDLDialect, s string) string {
+	if dialect == DDLMySQL {
+		return "`" + strings.Replace(s, "`", "``", -1) + "`"
+	}
+	return `"` + strings.Replace(s, `"`, `""`, -1) + `"`
+}
+
+// ddlString returns s as a SQL string.
+func ddlString(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// writeSQLComment writes the comment, if there is one, as SQL line comments
+// before what it describes.
+func writeSQLComment(buf *bytes.Buffer, comment string) {
+	if comment == "" {
+		return
+	}
+	for _, line := range strings.Split(comment, "\n") {
+		fmt.Fprintf(buf, "-- %s\n", strings.TrimRight(line, " \t\r"))
+	}
+}
@@ -0,0 +1,184 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run/data"
+)
+
+// Dictionary reads the database the same way Generate does, with the same
+// filters, and writes a markdown data dictionary to sink, with a page for each
+// schema named after it with a .md extension.  Each page lists the schema's
+// tables, with the type, nullability, default, and comment of each of their
+// columns, and its enums, with their values.  Foreign keys link to the tables
+// they reference, and to the tables that reference them, across pages.
+func Dictionary(ctx context.Context, env environ.Values, cfg *Config, sink OutputSink) error {
+	info, err := parseDB(ctx, env, cfg)
+	if err != nil {
+		return err
+	}
+	db, err := makeData(ctx, env, info, cfg)
+	if err != nil {
+		return err
+	}
+	for _, s := range db.Schemas {
+		buf := &bytes.Buffer{}
+		writeDictionary(buf, s)
+		name := dictionaryPage(s)
+		if err := sink.WriteFile(name, buf.Bytes(), 0644); err != nil {
+			return err
+		}
+		env.Logger().Infof("wrote %s", name)
+	}
+	return nil
+}
+
+// DictionaryCSV reads the database the same way Generate does, with the same
+// filters, and writes the data dictionary to env.Stdout as CSV, with a header
+// and then a row for each column of each table, giving its schema, table,
+// name, type, nullability, default, and comment.
+func DictionaryCSV(ctx context.Context, env environ.Values, cfg *Config) error {
+	info, err := parseDB(ctx, env, cfg)
+	if err != nil {
+		return err
+	}
+	db, err := makeData(ctx, env, info, cfg)
+	if err != nil {
+		return err
+	}
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	w.Write([]string{"schema", "table", "column", "type", "nullable", "default", "comment"})
+	for _, s := range db.Schemas {
+		for _, t := range s.Tables {
+			for _, c := range t.Columns {
+				nullable := "NO"
+				if c.Nullable {
+					nullable = "YES"
+				}
+				w.Write([]string{graphSchemaName(s), t.DBName, c.DBName, graphType(c), nullable, c.Default, c.Comment})
+			}
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	_, err = env.Stdout.Write(buf.Bytes())
+	return err
+}
+
+func writeDictionary(buf *bytes.Buffer, s *data.Schema) {
+	fmt.Fprintf(buf, "# %s\n", graphSchemaName(s))
+	if len(s.Tables) > 0 {
+		buf.WriteString("\n## Tables\n\n")
+		for _, t := range s.Tables {
+			fmt.Fprintf(buf, "- [%s](#%s)\n", t.DBName, dictionaryAnchor(t.DBName))
+		}
+	}
+	if len(s.Enums) > 0 {
+		buf.WriteString("\n## Enums\n\n")
+		for _, e := range s.Enums {
+			fmt.Fprintf(buf, "- [%s](#%s)\n", dictionaryEnumName(e), dictionaryAnchor(dictionaryEnumName(e)))
+		}
+	}
+	for _, t := range s.Tables {
+		fmt.Fprintf(buf, "\n## %s\n\n", t.DBName)
+		if t.IsView {
+			buf.WriteString("A view.\n\n")
+		}
+		if t.Comment != "" {
+			fmt.Fprintf(buf, "%s\n\n", t.Comment)
+		}
+		buf.WriteString("| Column | Type | Nullable | Default | Keys | Comment |\n")
+		buf.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+		for _, c := range t.Columns {
+			nullable := "no"
+			if c.Nullable {
+				nullable = "yes"
+			}
+			var def string
+			if c.Default != "" {
+				def = "`" + c.Default + "`"
+			}
+			keys := graphKeys(c)
+			if c.IsFK && c.FKColumn != nil && c.FKColumn.RefColumn != nil {
+				ref := c.FKColumn.RefColumn.Table
+				keys += fmt.Sprintf(" → [%s.%s](%s)", ref.DBName, c.FKColumn.RefColumnDBName, dictionaryLink(s, ref))
+			}
+			fmt.Fprintf(buf, "| %s | %s | %s | %s | %s | %s |\n",
+				dictionaryCell(c.DBName), dictionaryCell(graphType(c)), nullable, dictionaryCell(def), keys, dictionaryCell(c.Comment))
+		}
+		if refs := dictionaryRefs(t); len(refs) > 0 {
+			buf.WriteString("\nReferenced by:\n\n")
+			for _, fk := range refs {
+				fmt.Fprintf(buf, "- [%s](%s) (%s)\n", fk.Table.DBName, dictionaryLink(s, fk.Table), fk.DBName)
+			}
+		}
+	}
+	for _, e := range s.Enums {
+		fmt.Fprintf(buf, "\n## %s\n\n", dictionaryEnumName(e))
+		buf.WriteString("| Value | Number |\n")
+		buf.WriteString("| --- | --- |\n")
+		for _, v := range e.Values {
+			fmt.Fprintf(buf, "| %s | %d |\n", dictionaryCell(v.DBName), v.Value)
+		}
+	}
+}
+
+// dictionaryPage returns the name of the page for the schema.
+func dictionaryPage(s *data.Schema) string {
+	return graphSchemaName(s) + ".md"
+}
+
+// dictionaryEnumName returns the name of the enum, prefixed by its table for
+// enums that belong to a table, as they do in mysql.
+func dictionaryEnumName(e *data.Enum) string {
+	if e.Table != nil && e.Table.DBName != "" {
+		return e.Table.DBName + "." + e.DBName
+	}
+	return e.DBName
+}
+
+// dictionaryLink returns the link to the table from the page for the schema
+// from.
+func dictionaryLink(from *data.Schema, t *data.Table) string {
+	link := "#" + dictionaryAnchor(t.DBName)
+	if t.Schema != from {
+		link = dictionaryPage(t.Schema) + link
+	}
+	return link
+}
+
+// dictionaryRefs returns the foreign keys that reference the table, sorted by
+// name so that the page is the same every time.
+func dictionaryRefs(t *data.Table) data.ForeignKeys {
+	var refs data.ForeignKeys
+	for _, fk := range t.ForeignKeyRefs {
+		if fk.Table != nil {
+			refs = append(refs, fk)
+		}
+	}
+	return graphFKs(&data.Table{ForeignKeys: refs})
+}
+
+// nonAnchor matches the characters that GitHub and GitLab drop from headings
+// when they make anchors for them.
+var nonAnchor = regexp.MustCompile(`[^a-z0-9 _-]`)
+
+// dictionaryAnchor returns the anchor that markdown renderers give a heading.
+func dictionaryAnchor(heading string) string {
+	return strings.Replace(nonAnchor.ReplaceAllString(strings.ToLower(heading), ""), " ", "-", -1)
+}
+
+// dictionaryCell escapes s so that it fits in a cell of a markdown table.
+func dictionaryCell(s string) string {
+	s = strings.Replace(s, "|", `\|`, -1)
+	return strings.Replace(strings.TrimSpace(s), "\n", "<br>", -1)
+}
@@ -0,0 +1,138 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"text/template"
+
+	"github.com/andreyvit/diff"
+
+	"gnorm.org/gnorm/database"
+	"gnorm.org/gnorm/environ"
+)
+
+func TestDictionary(t *testing.T) {
+	cfg := &Config{
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver:         dummyDriver{},
+	}
+	sink := &MemorySink{}
+	if err := Dictionary(context.Background(), environ.Values{}, cfg, sink); err != nil {
+		t.Fatal(err)
+	}
+	expected := `
+# schema
+
+## Tables
+
+- [table](#table)
+- [tb2](#tb2)
+
+## Enums
+
+- [enum](#enum)
+
+## table
+
+a table
+
+| Column | Type | Nullable | Default | Keys | Comment |
+| --- | --- | --- | --- | --- | --- |
+| col1 | int | no |  | PK | first column |
+| col2 | *int | yes |  |  |  |
+| col3 | string | no |  |  |  |
+| col4 | *string | yes |  |  |  |
+
+Referenced by:
+
+- [tb2](#tb2) (tb2_col2_fkey)
+
+## tb2
+
+A view.
+
+| Column | Type | Nullable | Default | Keys | Comment |
+| --- | --- | --- | --- | --- | --- |
+| col1 | int | no |  | PK |  |
+| col2 | int | no |  | FK → [table.col1](#table) |  |
+
+## enum
+
+| Value | Number |
+| --- | --- |
+| enumvalue | 0 |
+`[1:]
+	if got := string(sink.Files()["schema.md"]); got != expected {
+		t.Fatalf("unexpected page:\n%s", diff.LineDiff(expected, got))
+	}
+}
+
+func TestDictionaryCells(t *testing.T) {
+	cfg := &Config{
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver: infoDriver{&database.Info{Schemas: []*database.Schema{{
+			Name: "public",
+			Tables: []*database.Table{{
+				Name:    "Order Items",
+				Comment: "what was ordered\nand how many",
+				Columns: []*database.Column{
+					{Name: "price", Type: "numeric", Precision: 10, Scale: 2, Comment: "in dollars | cents"},
+					{Name: "quantity", Type: "integer", HasDefault: true, Default: "1"},
+				},
+			}},
+		}}}},
+	}
+	sink := &MemorySink{}
+	if err := Dictionary(context.Background(), environ.Values{}, cfg, sink); err != nil {
+		t.Fatal(err)
+	}
+	expected := `
+# public
+
+## Tables
+
+- [Order Items](#order-items)
+
+## Order Items
+
+what was ordered
+and how many
+
+| Column | Type | Nullable | Default | Keys | Comment |
+| --- | --- | --- | --- | --- | --- |
+| price | numeric(10,2) | no |  |  | in dollars \| cents |
+| quantity | integer | no | `[1:] + "`1`" + ` |  |  |
+`
+	if got := string(sink.Files()["public.md"]); got != expected {
+		t.Fatalf("unexpected page:\n%s", diff.LineDiff(expected, got))
+	}
+}
+
+func TestDictionaryCSV(t *testing.T) {
+	cfg := &Config{
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver: infoDriver{&database.Info{Schemas: []*database.Schema{{
+			Name: "public",
+			Tables: []*database.Table{{
+				Name: "orders",
+				Columns: []*database.Column{
+					{Name: "id", Type: "integer", HasDefault: true, Default: "nextval('orders_id_seq'::regclass)"},
+					{Name: "note", Type: "varchar", Length: 100, Nullable: true, Comment: "what the customer said, \"quoted\""},
+				},
+			}},
+		}}}},
+	}
+	out := &bytes.Buffer{}
+	if err := DictionaryCSV(context.Background(), environ.Values{Stdout: out}, cfg); err != nil {
+		t.Fatal(err)
+	}
+	expected := `
+schema,table,column,type,nullable,default,comment
+public,orders,id,integer,NO,nextval('orders_id_seq'::regclass),
+public,orders,note,varchar(100),YES,,"what the customer said, ""quoted"""
+`[1:]
+	if got := out.String(); got != expected {
+		t.Fatalf("unexpected csv:\n%s", diff.LineDiff(expected, got))
+	}
+}
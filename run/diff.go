@@ -0,0 +1,236 @@
+package run
+
+import (
+	"fmt"
+	"strings"
+
+	"gnorm.org/gnorm/database"
+)
+
+// DiffSchemas compares the schema info in old against the schema info in
+// new, and returns a line for each schema, table, column, index, or enum that
+// was added (prefixed with "+"), removed (prefixed with "-"), or changed
+// (prefixed with "~").  Comments are not compared.  It returns nil if the
+// schemas are the same.
+func DiffSchemas(old, new *database.Info) []string {
+	var d []string
+	oldSchemas := map[string]*database.Schema{}
+	for _, s := range old.Schemas {
+		oldSchemas[schemaName(s)] = s
+	}
+	newSchemas := map[string]*database.Schema{}
+	for _, s := range new.Schemas {
+		newSchemas[schemaName(s)] = s
+	}
+	for _, s := range old.Schemas {
+		if _, ok := newSchemas[schemaName(s)]; !ok {
+			d = append(d, "- schema "+schemaName(s))
+		}
+	}
+	for _, s := range new.Schemas {
+		o, ok := oldSchemas[schemaName(s)]
+		if !ok {
+			d = append(d, "+ schema "+schemaName(s))
+			continue
+		}
+		d = append(d, diffTables(schemaName(s), o.Tables, s.Tables)...)
+		d = append(d, diffEnums(schemaName(s), o.Enums, s.Enums)...)
+	}
+	return d
+}
+
+// schemaName returns the name of the schema, qualified by the name of its
+// database if it has one.
+func schemaName(s *database.Schema) string {
+	if s.Database != "" {
+		return s.Database + "." + s.Name
+	}
+	return s.Name
+}
+
+func diffTables(schema string, old, new []*database.Table) []string {
+	var d []string
+	oldTables := map[string]*database.Table{}
+	for _, t := range old {
+		oldTables[t.Name] = t
+	}
+	newTables := map[string]*database.Table{}
+	for _, t := range new {
+		newTables[t.Name] = t
+	}
+	for _, t := range old {
+		if _, ok := newTables[t.Name]; !ok {
+			d = append(d, fmt.Sprintf("- %s %s.%s", tableKind(t), schema, t.Name))
+		}
+	}
+	for _, t := range new {
+		name := schema + "." + t.Name
+		o, ok := oldTables[t.Name]
+		if !ok {
+			d = append(d, fmt.Sprintf("+ %s %s", tableKind(t), name))
+			continue
+		}
+		if o.IsView != t.IsView {
+			d = append(d, fmt.Sprintf("~ %s %s: was a %s", tableKind(t), name, tableKind(o)))
+		}
+		d = append(d, diffColumns(name, o.Columns, t.Columns)...)
+		d = append(d, diffIndexes(name, o.Indexes, t.Indexes)...)
+	}
+	return d
+}
+
+func tableKind(t *database.Table) string {
+	if t.IsView {
+		return "view"
+	}
+	return "table"
+}
+
+func diffColumns(table string, old, new []*database.Column) []string {
+	var d []string
+	oldCols := map[string]*database.Column{}
+	for _, c := range old {
+		oldCols[c.Name] = c
+	}
+	newCols := map[string]*database.Column{}
+	for _, c := range new {
+		newCols[c.Name] = c
+	}
+	for _, c := range old {
+		if _, ok := newCols[c.Name]; !ok {
+			d = append(d, fmt.Sprintf("- column %s.%s %s", table, c.Name, columnType(c)))
+		}
+	}
+	for _, c := range new {
+		name := table + "." + c.Name
+		o, ok := oldCols[c.Name]
+		if !ok {
+			d = append(d, fmt.Sprintf("+ column %s %s", name, columnType(c)))
+			continue
+		}
+		if columnType(o) != columnType(c) {
+			d = append(d, fmt.Sprintf("~ column %s: type %s -> %s", name, columnType(o), columnType(c)))
+		}
+		if o.Nullable != c.Nullable {
+			d = append(d, fmt.Sprintf("~ column %s: nullable %t -> %t", name, o.Nullable, c.Nullable))
+		}
+		if o.HasDefault != c.HasDefault {
+			d = append(d, fmt.Sprintf("~ column %s: default %t -> %t", name, o.HasDefault, c.HasDefault))
+		}
+		if o.IsPrimaryKey != c.IsPrimaryKey {
+			d = append(d, fmt.Sprintf("~ column %s: primary key %t -> %t", name, o.IsPrimaryKey, c.IsPrimaryKey))
+		}
+		if foreignKey(o) != foreignKey(c) {
+			d = append(d, fmt.Sprintf("~ column %s: foreign key %s -> %s", name, foreignKey(o), foreignKey(c)))
+		}
+	}
+	return d
+}
+
+// columnType returns the column's type as it would be written in the database,
+// e.g. varchar(16) or integer[].
+func columnType(c *database.Column) string {
+	t := c.Type
+	if c.Length > 0 {
+		t = fmt.Sprintf("%s(%d)", t, c.Length)
+	}
+	if c.IsArray {
+		t += "[]"
+	}
+	return t
+}
+
+// foreignKey describes the column's foreign key, or returns "none" if it
+// doesn't have one.
+func foreignKey(c *database.Column) string {
+	if c.ForeignKey == nil {
+		return "none"
+	}
+	return fmt.Sprintf("%s (%s.%s)", c.ForeignKey.Name, c.ForeignKey.ForeignTableName, c.ForeignKey.ForeignColumnName)
+}
+
+func diffIndexes(table string, old, new []*database.Index) []string {
+	var d []string
+	oldIdx := map[string]*database.Index{}
+	for _, i := range old {
+		oldIdx[i.Name] = i
+	}
+	newIdx := map[string]*database.Index{}
+	for _, i := range new {
+		newIdx[i.Name] = i
+	}
+	for _, i := range old {
+		if _, ok := newIdx[i.Name]; !ok {
+			d = append(d, fmt.Sprintf("- index %s.%s", table, i.Name))
+		}
+	}
+	for _, i := range new {
+		name := table + "." + i.Name
+		o, ok := oldIdx[i.Name]
+		if !ok {
+			d = append(d, fmt.Sprintf("+ index %s (%s)", name, indexColumns(i)))
+			continue
+		}
+		if o.IsUnique != i.IsUnique {
+			d = append(d, fmt.Sprintf("~ index %s: unique %t -> %t", name, o.IsUnique, i.IsUnique))
+		}
+		if indexColumns(o) != indexColumns(i) {
+			d = append(d, fmt.Sprintf("~ index %s: columns (%s) -> (%s)", name, indexColumns(o), indexColumns(i)))
+		}
+	}
+	return d
+}
+
+func indexColumns(i *database.Index) string {
+	names := make([]string, len(i.Columns))
+	for x, c := range i.Columns {
+		names[x] = c.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+func diffEnums(schema string, old, new []*database.Enum) []string {
+	var d []string
+	oldEnums := map[string]*database.Enum{}
+	for _, e := range old {
+		oldEnums[enumName(e)] = e
+	}
+	newEnums := map[string]*database.Enum{}
+	for _, e := range new {
+		newEnums[enumName(e)] = e
+	}
+	for _, e := range old {
+		if _, ok := newEnums[enumName(e)]; !ok {
+			d = append(d, fmt.Sprintf("- enum %s.%s", schema, enumName(e)))
+		}
+	}
+	for _, e := range new {
+		name := schema + "." + enumName(e)
+		o, ok := oldEnums[enumName(e)]
+		if !ok {
+			d = append(d, fmt.Sprintf("+ enum %s (%s)", name, enumValues(e)))
+			continue
+		}
+		if enumValues(o) != enumValues(e) {
+			d = append(d, fmt.Sprintf("~ enum %s: values (%s) -> (%s)", name, enumValues(o), enumValues(e)))
+		}
+	}
+	return d
+}
+
+// enumName returns the name of the enum, qualified by its table for mysql
+// enums, which belong to a table.
+func enumName(e *database.Enum) string {
+	if e.Table != "" {
+		return e.Table + "." + e.Name
+	}
+	return e.Name
+}
+
+func enumValues(e *database.Enum) string {
+	names := make([]string, len(e.Values))
+	for x, v := range e.Values {
+		names[x] = v.Name
+	}
+	return strings.Join(names, ", ")
+}
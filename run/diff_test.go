@@ -0,0 +1,90 @@
+package run
+
+import (
+	"reflect"
+	"testing"
+
+	"gnorm.org/gnorm/database"
+)
+
+func diffInfo() *database.Info {
+	id := &database.Column{Name: "id", Type: "integer", IsPrimaryKey: true}
+	name := &database.Column{Name: "name", Type: "varchar", Length: 16}
+	return &database.Info{
+		Schemas: []*database.Schema{{
+			Name: "public",
+			Tables: []*database.Table{
+				{
+					Name:    "users",
+					Columns: []*database.Column{id, name},
+					Indexes: []*database.Index{{Name: "users_name", Columns: []*database.Column{name}}},
+				},
+				{
+					Name: "posts",
+					Columns: []*database.Column{
+						{Name: "id", Type: "integer", IsPrimaryKey: true},
+						{Name: "tags", Type: "text", IsArray: true},
+					},
+				},
+			},
+			Enums: []*database.Enum{{
+				Name:   "color",
+				Values: []*database.EnumValue{{Name: "red", Value: 1}, {Name: "blue", Value: 2}},
+			}},
+		}},
+	}
+}
+
+func TestDiffSchemas(t *testing.T) {
+	if d := DiffSchemas(diffInfo(), diffInfo()); d != nil {
+		t.Fatalf("expected no differences, but got %q", d)
+	}
+
+	old := diffInfo()
+	new := diffInfo()
+	users := new.Schemas[0].Tables[0]
+	users.Columns[1].Length = 32
+	users.Columns[1].Nullable = true
+	users.Columns = append(users.Columns, &database.Column{Name: "email", Type: "text"})
+	users.Indexes[0].IsUnique = true
+	posts := new.Schemas[0].Tables[1]
+	posts.Columns = posts.Columns[:1]
+	posts.Columns = append(posts.Columns, &database.Column{
+		Name:       "user_id",
+		Type:       "integer",
+		ForeignKey: &database.ForeignKey{Name: "posts_user", ForeignTableName: "users", ForeignColumnName: "id"},
+	})
+	new.Schemas[0].Tables = append(new.Schemas[0].Tables, &database.Table{Name: "active_users", IsView: true})
+	new.Schemas[0].Enums[0].Values = append(new.Schemas[0].Enums[0].Values, &database.EnumValue{Name: "green", Value: 3})
+	new.Schemas = append(new.Schemas, &database.Schema{Name: "audit"})
+
+	expected := []string{
+		"~ column public.users.name: type varchar(16) -> varchar(32)",
+		"~ column public.users.name: nullable false -> true",
+		"+ column public.users.email text",
+		"~ index public.users.users_name: unique false -> true",
+		"- column public.posts.tags text[]",
+		"+ column public.posts.user_id integer",
+		"+ view public.active_users",
+		"~ enum public.color: values (red, blue) -> (red, blue, green)",
+		"+ schema audit",
+	}
+	if d := DiffSchemas(old, new); !reflect.DeepEqual(d, expected) {
+		t.Fatalf("expected differences:\n%q\nbut got:\n%q", expected, d)
+	}
+
+	expected = []string{
+		"- schema audit",
+		"- view public.active_users",
+		"- column public.users.email text",
+		"~ column public.users.name: type varchar(32) -> varchar(16)",
+		"~ column public.users.name: nullable true -> false",
+		"~ index public.users.users_name: unique true -> false",
+		"- column public.posts.user_id integer",
+		"+ column public.posts.tags text[]",
+		"~ enum public.color: values (red, blue, green) -> (red, blue)",
+	}
+	if d := DiffSchemas(new, old); !reflect.DeepEqual(d, expected) {
+		t.Fatalf("expected differences:\n%q\nbut got:\n%q", expected, d)
+	}
+}
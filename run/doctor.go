@@ -0,0 +1,89 @@
+package run
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"gnorm.org/gnorm/database"
+	"gnorm.org/gnorm/environ"
+)
+
+// Check is the result of one of the checks gnorm doctor makes.  Err is nil if
+// the check passed.
+type Check struct {
+	Name string
+	Err  error
+}
+
+// CheckDatabase connects to each database in cfg the same way Generate does,
+// and checks that the catalogs its driver reads the schema from can be read.
+// Drivers that can't check their catalogs are checked by reading the schema.
+func CheckDatabase(ctx context.Context, env environ.Values, cfg *Config) []Check {
+	if len(cfg.Databases) == 0 {
+		return checkDatabase(ctx, env, cfg, "database", cfg.Driver, cfg.ConnStr, cfg.Schemas)
+	}
+	var checks []Check
+	for _, d := range cfg.Databases {
+		checks = append(checks, checkDatabase(ctx, env, cfg, "database "+d.Name, d.Driver, d.ConnStr, d.Schemas)...)
+	}
+	return checks
+}
+
+func checkDatabase(ctx context.Context, env environ.Values, cfg *Config, name string, driver database.Driver, connStr string, schemas []string) []Check {
+	c, ok := driver.(database.CatalogChecker)
+	if !ok {
+		_, err := parseDatabase(ctx, env, cfg, driver, connStr, schemas)
+		return []Check{{Name: name + ": read schema", Err: err}}
+	}
+	ctx, connStr, done, err := connect(ctx, env, cfg, driver, connStr)
+	if err != nil {
+		return []Check{{Name: name + ": connect", Err: err}}
+	}
+	defer done()
+	results, err := c.CheckCatalogs(ctx, env.Logger(), connStr, schemas)
+	checks := []Check{{Name: name + ": connect", Err: err}}
+	if err != nil {
+		return checks
+	}
+	names := make([]string, 0, len(results))
+	for n := range results {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		checks = append(checks, Check{Name: name + ": " + n, Err: results[n]})
+	}
+	return checks
+}
+
+// CheckOutputDir checks that files can be written to the output directory, or,
+// if it doesn't exist yet, that it can be created.
+func CheckOutputDir(cfg *Config) error {
+	dir := outputDir(cfg)
+	for {
+		fi, err := os.Stat(dir)
+		if err == nil {
+			if !fi.IsDir() {
+				return errors.Errorf("%s is not a directory", dir)
+			}
+			break
+		}
+		parent := filepath.Dir(dir)
+		if !os.IsNotExist(err) || parent == dir {
+			return errors.WithStack(err)
+		}
+		// gen creates the directory, so it only needs to be creatable.
+		dir = parent
+	}
+	f, err := ioutil.TempFile(dir, ".gnorm")
+	if err != nil {
+		return errors.WithMessage(err, "can't write to "+dir)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
@@ -0,0 +1,80 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gnorm.org/gnorm/environ"
+)
+
+// checkerDriver is a dummyDriver that can check its catalogs.
+type checkerDriver struct {
+	dummyDriver
+	connErr error
+}
+
+var errDenied = errors.New("permission denied")
+
+func (d checkerDriver) CheckCatalogs(ctx context.Context, log environ.Logger, conn string, schemaNames []string) (map[string]error, error) {
+	if d.connErr != nil {
+		return nil, d.connErr
+	}
+	checks := map[string]error{"catalog": nil}
+	for _, s := range schemaNames {
+		checks["schema "+s] = errDenied
+	}
+	return checks, nil
+}
+
+func TestCheckDatabase(t *testing.T) {
+	env := environ.Values{}
+	cfg := &Config{Driver: checkerDriver{}}
+	cfg.Schemas = []string{"public"}
+	expected := []Check{
+		{Name: "database: connect"},
+		{Name: "database: catalog"},
+		{Name: "database: schema public", Err: errDenied},
+	}
+	if checks := CheckDatabase(context.Background(), env, cfg); !reflect.DeepEqual(checks, expected) {
+		t.Fatalf("expected checks %v, but got %v", expected, checks)
+	}
+
+	connErr := errors.New("connection refused")
+	cfg.Driver = checkerDriver{connErr: connErr}
+	expected = []Check{{Name: "database: connect", Err: connErr}}
+	if checks := CheckDatabase(context.Background(), env, cfg); !reflect.DeepEqual(checks, expected) {
+		t.Fatalf("expected checks %v, but got %v", expected, checks)
+	}
+
+	// drivers that can't check their catalogs are checked by reading them.
+	cfg.Driver = dummyDriver{}
+	expected = []Check{{Name: "database: read schema"}}
+	if checks := CheckDatabase(context.Background(), env, cfg); !reflect.DeepEqual(checks, expected) {
+		t.Fatalf("expected checks %v, but got %v", expected, checks)
+	}
+}
+
+func TestCheckOutputDir(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{}
+	cfg.OutputDir = filepath.Join(dir, "does", "not", "exist")
+	if err := CheckOutputDir(cfg); err != nil {
+		t.Fatalf("expected an output dir that can be created to pass, but got %v", err)
+	}
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg.OutputDir = file
+	if err := CheckOutputDir(cfg); err == nil {
+		t.Fatal("expected an output dir that's a file to fail")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "does")); !os.IsNotExist(err) {
+		t.Fatalf("expected the output dir not to be created, but got %v", err)
+	}
+}
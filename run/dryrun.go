@@ -0,0 +1,181 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+
+	"gnorm.org/gnorm/environ"
+)
+
+// dryRunReport records the files, relative to the output directory, that a
+// dry run would add or change.
+type dryRunReport struct {
+	added   []string
+	changed []string
+}
+
+// dryRunFile renders the target into a temporary directory, runs PostRun on
+// it, and writes a diff of the result against old, the current contents of the
+// file if it exists, to env.Stdout.
+func dryRunFile(ctx context.Context, env environ.Values, r *genRun, name string, old []byte, exists bool, contents interface{}, target OutputTarget) error {
+	dir, err := ioutil.TempDir("", "gnorm")
+	if err != nil {
+		return errors.WithMessage(err, "can't create directory for dry run")
+	}
+	defer os.RemoveAll(dir)
+	// keep the file's extension, since PostRun commands often depend on it.
+	path := filepath.Join(dir, filepath.Base(name))
+	changed, err := writeFile(ctx, env, r.Config, path, old, exists, contents, target)
+	if err != nil || !changed {
+		return err
+	}
+	if len(r.PostRun) > 0 {
+		// the command's output would get mixed up with the diff.
+		penv := env
+		penv.Stdout = env.Stderr
+		if err := doPostRun(ctx, penv, path, r.PostRun); err != nil {
+			if !r.PostRunWarnOnly {
+				return err
+			}
+			env.Logger().Warnf("%v", err)
+		}
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "error reading generated file %q", path)
+	}
+	return writeDiff(env, r, name, old, exists, b)
+}
+
+// dryRunStatic writes a diff of the files in the static directories against
+// their copies in the output directory to env.Stdout.
+func dryRunStatic(env environ.Values, r *genRun) error {
+	if r.OutputDir == "" {
+		return nil
+	}
+	for _, dir := range staticDirs(r.Config) {
+		err := dir.WalkFiles(r.FS, func(path string, info os.FileInfo) error {
+			rel, err := filepath.Rel(dir.Dir, path)
+			if err != nil {
+				return err
+			}
+			rel = filepath.Join(dir.Dest, rel)
+			r.generated.add(rel, OutputFile{Kind: OutputStatic, Template: path})
+			b, err := renderStatic(r.Config, path)
+			if err != nil {
+				return err
+			}
+			old, err := ioutil.ReadFile(filepath.Join(r.OutputDir, rel))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			return writeDiff(env, r, rel, old, err == nil, b)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeDiff writes a unified diff from old to new for the file name to
+// env.Stdout, and records the file as added or changed.  Nothing is written if
+// the file exists and is unchanged.
+func writeDiff(env environ.Values, r *genRun, name string, old []byte, exists bool, new []byte) error {
+	if exists && bytes.Equal(old, new) {
+		return nil
+	}
+	name = filepath.ToSlash(name)
+	from := "a/" + name
+	if exists {
+		r.dryRun.changed = append(r.dryRun.changed, name)
+	} else {
+		from = "/dev/null"
+		r.dryRun.added = append(r.dryRun.added, name)
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        diffLines(old),
+		B:        diffLines(new),
+		FromFile: from,
+		ToFile:   "b/" + name,
+		Context:  3,
+	})
+	if err != nil {
+		return errors.WithMessage(err, "can't diff "+name)
+	}
+	_, err = io.WriteString(env.Stdout, diff)
+	return err
+}
+
+// diffLines splits b into lines, keeping their line endings.  Unlike
+// difflib.SplitLines, a trailing newline doesn't add an empty last line.
+func diffLines(b []byte) []string {
+	var lines []string
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, '\n') + 1
+		if i == 0 {
+			// diffs need every line to end with a newline.
+			lines = append(lines, string(b)+"\n")
+			break
+		}
+		lines = append(lines, string(b[:i]))
+		b = b[i:]
+	}
+	return lines
+}
+
+// writeDryRunSummary writes the lists of files the dry run would add, change,
+// and remove to env.Stdout.  Files are removed when they were generated by
+// the last run, according to the manifest, and covered by this one, but not
+// generated by it.  gnorm gen leaves them in place unless Prune is set, gnorm
+// clean --orphans removes them.
+func writeDryRunSummary(env environ.Values, r *genRun) error {
+	m, err := ReadManifest(outputDir(r.Config))
+	if err != nil {
+		return err
+	}
+	covered, err := coveredFiles(r.Config)
+	if err != nil {
+		return err
+	}
+	outputs := make(map[string]OutputFile, len(m.Outputs))
+	for _, out := range m.Outputs {
+		outputs[out.Path] = out
+	}
+	var removed []string
+	for _, f := range m.Files {
+		if r.generated.has(f) || !covered(outputs[f]) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(outputDir(r.Config), filepath.FromSlash(f))); err == nil {
+			removed = append(removed, f)
+		}
+	}
+	if len(r.dryRun.added)+len(r.dryRun.changed)+len(removed) == 0 {
+		fmt.Fprintln(env.Stdout, "No changes.")
+		return nil
+	}
+	for _, l := range []struct {
+		kind  string
+		files []string
+	}{
+		{"new", r.dryRun.added},
+		{"changed", r.dryRun.changed},
+		{"removed", removed},
+	} {
+		sort.Strings(l.files)
+		for _, f := range l.files {
+			fmt.Fprintf(env.Stdout, "%s file: %s\n", l.kind, f)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"text/template"
+
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run/data"
+)
+
+func TestDryRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gnorm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "out")
+	static := filepath.Join(dir, "static")
+	if err := os.MkdirAll(static, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(static, "README"), []byte("static"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	stdout := &bytes.Buffer{}
+	env := environ.Values{Stdout: stdout}
+	cfg := &Config{
+		ConfigData:     data.ConfigData{OutputDir: out, StaticDir: static},
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver:         droppedTableDriver{},
+		TablePaths: []OutputTarget{{
+			Filename: template.Must(template.New("").Parse(`{{.Table}}.txt`)),
+			Contents: template.Must(template.New("").Parse("{{.Table.Name}}\n{{.Params.version}}\n")),
+		}},
+		Params: map[string]interface{}{"version": "one"},
+	}
+	if err := Generate(context.Background(), env, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.DryRun = true
+	cfg.Driver = dummyDriver{}
+	cfg.Params["version"] = "two"
+	if err := Generate(context.Background(), env, cfg); err != nil {
+		t.Fatal(err)
+	}
+	expected := `--- a/table.txt
++++ b/table.txt
+@@ -1,2 +1,2 @@
+ table
+-one
++two
+--- /dev/null
++++ b/tb2.txt
+@@ -0,0 +1,2 @@
++tb2
++two
+new file: tb2.txt
+changed file: table.txt
+`
+	if s := stdout.String(); s != expected {
+		t.Fatalf("expected output:\n%s\nbut got:\n%s", expected, s)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(out, "table.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "table\none\n" {
+		t.Fatalf("expected table.txt to be unchanged, but got %q", b)
+	}
+	if _, err := os.Stat(filepath.Join(out, "tb2.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected tb2.txt not to be written, but got %v", err)
+	}
+
+	// write everything, then check that a table that's gone shows up as
+	// removed.
+	cfg.DryRun = false
+	if err := Generate(context.Background(), env, cfg); err != nil {
+		t.Fatal(err)
+	}
+	stdout.Reset()
+	cfg.DryRun = true
+	cfg.Driver = droppedTableDriver{}
+	if err := Generate(context.Background(), env, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if s, expected := stdout.String(), "removed file: tb2.txt\n"; s != expected {
+		t.Fatalf("expected output %q, but got %q", expected, s)
+	}
+
+	stdout.Reset()
+	cfg.Driver = dummyDriver{}
+	if err := Generate(context.Background(), env, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if s, expected := stdout.String(), "No changes.\n"; s != expected {
+		t.Fatalf("expected output %q, but got %q", expected, s)
+	}
+}
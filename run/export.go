@@ -0,0 +1,66 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+
+	"gnorm.org/gnorm/environ"
+)
+
+// ExportFormat defines the formats that Export can write the schema in.
+type ExportFormat int
+
+const (
+	// ExportDDL writes the schema as the CREATE statements that would make it.
+	ExportDDL ExportFormat = iota
+	// ExportLiquibaseXML writes a Liquibase changelog, in XML, that creates
+	// the schema.
+	ExportLiquibaseXML
+	// ExportLiquibaseYAML writes a Liquibase changelog, in YAML, that creates
+	// the schema.
+	ExportLiquibaseYAML
+)
+
+// Export reads the database the same way Generate does, with the same filters,
+// and writes its schema to env.Stdout in the given format.  The output is
+// canonical: tables, indexes, and foreign keys are sorted by name, and foreign
+// keys are added after all the tables are created, so the output only changes
+// when the shape of the schema does, which makes it useful for snapshot tests.
+// The dialect is only used by ExportDDL.
+func Export(ctx context.Context, env environ.Values, cfg *Config, format ExportFormat, dialect DDLDialect) error {
+	info, err := parseDB(ctx, env, cfg)
+	if err != nil {
+		return err
+	}
+	db, err := makeData(ctx, env, info, cfg)
+	if err != nil {
+		return err
+	}
+	buf := &bytes.Buffer{}
+	switch format {
+	case ExportDDL:
+		writeDDL(buf, db, dialect)
+	case ExportLiquibaseXML:
+		buf.WriteString(xml.Header)
+		enc := xml.NewEncoder(buf)
+		enc.Indent("", "  ")
+		if err := enc.Encode(liquibaseChangeLog(db)); err != nil {
+			return errors.WithMessage(err, "can't encode Liquibase changelog")
+		}
+		buf.WriteString("\n")
+	case ExportLiquibaseYAML:
+		b, err := yaml.Marshal(liquibaseChangeLog(db))
+		if err != nil {
+			return errors.WithMessage(err, "can't encode Liquibase changelog")
+		}
+		buf.Write(b)
+	default:
+		return errors.Errorf("Unsupported format: %v", format)
+	}
+	_, err = env.Stdout.Write(buf.Bytes())
+	return err
+}
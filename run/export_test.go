@@ -0,0 +1,270 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/andreyvit/diff"
+
+	"gnorm.org/gnorm/database"
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run/data"
+)
+
+func TestExportDDL(t *testing.T) {
+	cfg := &Config{
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver:         dummyDriver{},
+	}
+	out := &bytes.Buffer{}
+	if err := Export(context.Background(), environ.Values{Stdout: out}, cfg, ExportDDL, DDLStandard); err != nil {
+		t.Fatal(err)
+	}
+	expected := `
+CREATE TYPE "schema"."enum" AS ENUM ('enumvalue');
+
+-- a table
+CREATE TABLE "schema"."table" (
+  -- first column
+  "col1" int NOT NULL,
+  "col2" *int,
+  "col3" string NOT NULL,
+  "col4" *string,
+  PRIMARY KEY ("col1")
+);
+
+-- VIEW "schema"."tb2" ("col1" int, "col2" int)
+`[1:]
+	if got := out.String(); got != expected {
+		t.Fatalf("unexpected ddl:\n%s", diff.LineDiff(expected, got))
+	}
+}
+
+func TestExportDDLDialects(t *testing.T) {
+	info := &database.Info{Schemas: []*database.Schema{{
+		Name:  "shop",
+		Enums: []*database.Enum{{Name: "status", Values: []*database.EnumValue{{Name: "open", Value: 1}, {Name: "shipped", Value: 2}}}},
+		Tables: []*database.Table{{
+			Name:    "orders",
+			Comment: "what's been ordered",
+			Columns: []*database.Column{
+				{Name: "id", Type: "integer", IsPrimaryKey: true, HasDefault: true, Default: "1"},
+				{Name: "status", Type: "status", UserDefined: true, Comment: "where it's at"},
+				{Name: "note", Type: "varchar", Length: 100, Nullable: true},
+			},
+			Indexes: []*database.Index{
+				{Name: "orders_pkey", IsUnique: true, Columns: []*database.Column{{Name: "id"}}},
+				{Name: "orders_status_idx", Columns: []*database.Column{{Name: "status"}}},
+			},
+		}},
+	}}}
+	tests := []struct {
+		dialect  DDLDialect
+		expected string
+	}{
+		{DDLPostgres, `
+CREATE TYPE "shop"."status" AS ENUM ('open', 'shipped');
+
+CREATE TABLE "shop"."orders" (
+  "id" integer NOT NULL DEFAULT 1,
+  "status" "shop"."status" NOT NULL,
+  "note" varchar(100),
+  PRIMARY KEY ("id")
+);
+COMMENT ON TABLE "shop"."orders" IS 'what''s been ordered';
+COMMENT ON COLUMN "shop"."orders"."status" IS 'where it''s at';
+CREATE INDEX "orders_status_idx" ON "shop"."orders" ("status");
+`[1:]},
+		// mysql quotes with backticks, which can't be in a raw string.
+		{DDLMySQL, strings.Replace(`
+CREATE TABLE ~shop~.~orders~ (
+  ~id~ integer NOT NULL DEFAULT 1,
+  ~status~ ENUM('open', 'shipped') NOT NULL COMMENT 'where it''s at',
+  ~note~ varchar(100),
+  PRIMARY KEY (~id~)
+) COMMENT 'what''s been ordered';
+CREATE INDEX ~orders_status_idx~ ON ~shop~.~orders~ (~status~);
+`[1:], "~", "`", -1)},
+	}
+	for _, test := range tests {
+		cfg := &Config{
+			NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+			Driver:         infoDriver{info},
+		}
+		out := &bytes.Buffer{}
+		if err := Export(context.Background(), environ.Values{Stdout: out}, cfg, ExportDDL, test.dialect); err != nil {
+			t.Fatal(err)
+		}
+		if got := out.String(); got != test.expected {
+			t.Errorf("dialect %v:\n%s", test.dialect, diff.LineDiff(test.expected, got))
+		}
+	}
+}
+
+func TestExportLiquibase(t *testing.T) {
+	cfg := &Config{
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver: infoDriver{&database.Info{Schemas: []*database.Schema{{
+			Name:  "shop",
+			Enums: []*database.Enum{{Name: "status", Values: []*database.EnumValue{{Name: "open", Value: 1}, {Name: "shipped", Value: 2}}}},
+			Tables: []*database.Table{{
+				Name:    "orders",
+				Comment: "what's been ordered",
+				Columns: []*database.Column{
+					{Name: "id", Type: "integer", IsPrimaryKey: true, HasDefault: true, Default: "nextval('orders_id_seq'::regclass)"},
+					{Name: "status", Type: "status", UserDefined: true},
+					{Name: "customer_id", Type: "integer", Nullable: true, IsForeignKey: true, ForeignKey: &database.ForeignKey{
+						SchemaName:        "shop",
+						TableName:         "orders",
+						ColumnName:        "customer_id",
+						Name:              "orders_customer_fkey",
+						ForeignTableName:  "customers",
+						ForeignColumnName: "id",
+					}},
+				},
+				Indexes: []*database.Index{
+					{Name: "orders_pkey", IsUnique: true, Columns: []*database.Column{{Name: "id"}}},
+					{Name: "orders_status_idx", Columns: []*database.Column{{Name: "status"}}},
+				},
+			}, {
+				Name:    "customers",
+				Columns: []*database.Column{{Name: "id", Type: "integer", IsPrimaryKey: true}},
+			}},
+		}}}},
+	}
+	tests := []struct {
+		format   ExportFormat
+		expected string
+	}{
+		{ExportLiquibaseYAML, `
+databaseChangeLog:
+- changeSet:
+    id: create-type:shop:status
+    author: gnorm
+    changes:
+    - sql: CREATE TYPE "shop"."status" AS ENUM ('open', 'shipped')
+- changeSet:
+    id: create-table:shop:customers
+    author: gnorm
+    changes:
+    - createTable:
+        schemaName: shop
+        tableName: customers
+        columns:
+        - column:
+            name: id
+            type: integer
+            constraints:
+              nullable: false
+              primaryKey: true
+- changeSet:
+    id: create-table:shop:orders
+    author: gnorm
+    changes:
+    - createTable:
+        schemaName: shop
+        tableName: orders
+        remarks: what's been ordered
+        columns:
+        - column:
+            name: id
+            type: integer
+            defaultValueComputed: nextval('orders_id_seq'::regclass)
+            constraints:
+              nullable: false
+              primaryKey: true
+        - column:
+            name: status
+            type: status
+            constraints:
+              nullable: false
+        - column:
+            name: customer_id
+            type: integer
+    - createIndex:
+        schemaName: shop
+        tableName: orders
+        indexName: orders_status_idx
+        columns:
+        - column:
+            name: status
+- changeSet:
+    id: add-foreign-keys
+    author: gnorm
+    changes:
+    - addForeignKeyConstraint:
+        constraintName: orders_customer_fkey
+        baseTableSchemaName: shop
+        baseTableName: orders
+        baseColumnNames: customer_id
+        referencedTableSchemaName: shop
+        referencedTableName: customers
+        referencedColumnNames: id
+`[1:]},
+		{ExportLiquibaseXML, `
+<?xml version="1.0" encoding="UTF-8"?>
+<databaseChangeLog xmlns="http://www.liquibase.org/xml/ns/dbchangelog" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://www.liquibase.org/xml/ns/dbchangelog http://www.liquibase.org/xml/ns/dbchangelog/dbchangelog-latest.xsd">
+  <changeSet id="create-type:shop:status" author="gnorm">
+    <sql>CREATE TYPE &#34;shop&#34;.&#34;status&#34; AS ENUM (&#39;open&#39;, &#39;shipped&#39;)</sql>
+  </changeSet>
+  <changeSet id="create-table:shop:customers" author="gnorm">
+    <createTable schemaName="shop" tableName="customers">
+      <column name="id" type="integer">
+        <constraints nullable="false" primaryKey="true"></constraints>
+      </column>
+    </createTable>
+  </changeSet>
+  <changeSet id="create-table:shop:orders" author="gnorm">
+    <createTable schemaName="shop" tableName="orders" remarks="what&#39;s been ordered">
+      <column name="id" type="integer" defaultValueComputed="nextval(&#39;orders_id_seq&#39;::regclass)">
+        <constraints nullable="false" primaryKey="true"></constraints>
+      </column>
+      <column name="status" type="status">
+        <constraints nullable="false"></constraints>
+      </column>
+      <column name="customer_id" type="integer"></column>
+    </createTable>
+    <createIndex schemaName="shop" tableName="orders" indexName="orders_status_idx">
+      <column name="status"></column>
+    </createIndex>
+  </changeSet>
+  <changeSet id="add-foreign-keys" author="gnorm">
+    <addForeignKeyConstraint constraintName="orders_customer_fkey" baseTableSchemaName="shop" baseTableName="orders" baseColumnNames="customer_id" referencedTableSchemaName="shop" referencedTableName="customers" referencedColumnNames="id"></addForeignKeyConstraint>
+  </changeSet>
+</databaseChangeLog>
+`[1:]},
+	}
+	for _, test := range tests {
+		out := &bytes.Buffer{}
+		if err := Export(context.Background(), environ.Values{Stdout: out}, cfg, test.format, DDLStandard); err != nil {
+			t.Fatal(err)
+		}
+		if got := out.String(); got != test.expected {
+			t.Errorf("format %v:\n%s", test.format, diff.LineDiff(test.expected, got))
+		}
+	}
+}
+
+func TestLiquibaseID(t *testing.T) {
+	ids := map[string]bool{}
+	for _, test := range []struct {
+		database, schema, table string
+	}{
+		{"", "a-b", "c"},
+		{"", "a", "b-c"},
+		{"one", "public", "users"},
+		{"two", "public", "users"},
+	} {
+		id := liquibaseID("create-table", &data.Schema{Database: test.database, DBName: test.schema}, test.table)
+		if ids[id] {
+			t.Errorf("changeset ID %q for %v is used twice", id, test)
+		}
+		ids[id] = true
+	}
+	if id := liquibaseID("create-table", &data.Schema{DBName: "public"}, "users"); id != "create-table:public:users" {
+		t.Errorf("expected create-table:public:users, but got %q", id)
+	}
+}
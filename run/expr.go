@@ -0,0 +1,137 @@
+package run
+
+import (
+	"github.com/google/cel-go/cel"
+	"github.com/pkg/errors"
+
+	"gnorm.org/gnorm/database"
+)
+
+// TableExpr is a compiled CEL expression that decides whether a table should
+// be included in the data passed to templates.  The expression sees the table
+// as the value table, which has the following fields:
+//
+//	name          string        the original name of the table in the DB
+//	schema        string        the original name of the table's schema
+//	type          string        the table type (e.g. VIEW or BASE TABLE)
+//	comment       string        the comment attached to the table
+//	isView        bool          true if the table is a view
+//	isInsertable  bool          true if the table accepts inserts
+//	columns       list(string)  the names of the table's columns
+//	primaryKeys   list(string)  the names of the primary key columns
+//	foreignKeys   list(string)  the names of the tables referenced by foreign keys
+//	isJoinTable   bool          true if the table has two foreign keys and no
+//	                            columns other than foreign and primary keys
+//
+// For example, "table.name.startsWith('app_') && !table.isJoinTable".
+type TableExpr struct {
+	expr string
+	prg  cel.Program
+}
+
+// ParseTableExpr compiles the given CEL expression, which must evaluate to a
+// bool.
+func ParseTableExpr(expr string) (*TableExpr, error) {
+	env, err := cel.NewEnv(cel.Variable("table", cel.MapType(cel.StringType, cel.DynType)))
+	if err != nil {
+		return nil, err
+	}
+	ast, iss := env.Compile(expr)
+	if iss.Err() != nil {
+		return nil, iss.Err()
+	}
+	// fields of table are dynamically typed, so they can only be checked when
+	// the expression is evaluated.
+	if t := ast.OutputType(); t != cel.BoolType && t != cel.DynType {
+		return nil, errors.Errorf("expression %q must return a bool, but returns %v", expr, ast.OutputType())
+	}
+	prg, err := env.Program(ast)
+	if err != nil {
+		return nil, err
+	}
+	return &TableExpr{expr: expr, prg: prg}, nil
+}
+
+// Match reports whether the expression is true for the given table.
+func (e *TableExpr) Match(schema string, t *database.Table) (bool, error) {
+	out, _, err := e.prg.Eval(map[string]interface{}{"table": tableVars(schema, t)})
+	if err != nil {
+		return false, errors.WithMessage(err, "error evaluating "+e.expr+" for table "+schema+"."+t.Name)
+	}
+	b, ok := out.Value().(bool)
+	if !ok {
+		return false, errors.Errorf("expression %q returned %v instead of a bool", e.expr, out.Value())
+	}
+	return b, nil
+}
+
+// tableVars returns the values of the table variable used in a TableExpr.
+func tableVars(schema string, t *database.Table) map[string]interface{} {
+	columns := []string{}
+	pks := []string{}
+	fks := []string{}
+	fkNames := map[string]bool{}
+	onlyKeys := len(t.Columns) > 0
+	for _, c := range t.Columns {
+		columns = append(columns, c.Name)
+		if c.IsPrimaryKey {
+			pks = append(pks, c.Name)
+		}
+		if !c.IsForeignKey || c.ForeignKey == nil {
+			if !c.IsPrimaryKey {
+				onlyKeys = false
+			}
+			continue
+		}
+		if !fkNames[c.ForeignKey.Name] {
+			fkNames[c.ForeignKey.Name] = true
+			fks = append(fks, c.ForeignKey.ForeignTableName)
+		}
+	}
+	return map[string]interface{}{
+		"name":         t.Name,
+		"schema":       schema,
+		"type":         t.Type,
+		"comment":      t.Comment,
+		"isView":       t.IsView,
+		"isInsertable": t.IsInsertable,
+		"columns":      columns,
+		"primaryKeys":  pks,
+		"foreignKeys":  fks,
+		"isJoinTable":  onlyKeys && len(fkNames) == 2,
+	}
+}
+
+// filterInfo removes the tables from info that aren't included by
+// cfg.IncludeExpr or are excluded by cfg.ExcludeExpr.
+func filterInfo(info *database.Info, cfg *Config) error {
+	if cfg.IncludeExpr == nil && cfg.ExcludeExpr == nil {
+		return nil
+	}
+	for _, s := range info.Schemas {
+		tables := s.Tables[:0]
+		for _, t := range s.Tables {
+			if cfg.IncludeExpr != nil {
+				ok, err := cfg.IncludeExpr.Match(s.Name, t)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					continue
+				}
+			}
+			if cfg.ExcludeExpr != nil {
+				ok, err := cfg.ExcludeExpr.Match(s.Name, t)
+				if err != nil {
+					return err
+				}
+				if ok {
+					continue
+				}
+			}
+			tables = append(tables, t)
+		}
+		s.Tables = tables
+	}
+	return nil
+}
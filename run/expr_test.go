@@ -0,0 +1,77 @@
+package run
+
+import (
+	"testing"
+
+	"gnorm.org/gnorm/database"
+)
+
+func TestTableExpr(t *testing.T) {
+	fk := func(name, table string) *database.ForeignKey {
+		return &database.ForeignKey{Name: name, ForeignTableName: table}
+	}
+	users := &database.Table{
+		Name: "app_users",
+		Columns: []*database.Column{
+			{Name: "id", IsPrimaryKey: true},
+			{Name: "name"},
+		},
+	}
+	groups := &database.Table{
+		Name: "app_groups",
+		Columns: []*database.Column{
+			{Name: "id", IsPrimaryKey: true},
+		},
+	}
+	members := &database.Table{
+		Name: "app_members",
+		Columns: []*database.Column{
+			{Name: "id", IsPrimaryKey: true},
+			{Name: "user_id", IsForeignKey: true, ForeignKey: fk("members_user", "app_users")},
+			{Name: "group_id", IsForeignKey: true, ForeignKey: fk("members_group", "app_groups")},
+		},
+	}
+	view := &database.Table{Name: "user_view", IsView: true}
+	info := &database.Info{
+		Schemas: []*database.Schema{{
+			Name:   "public",
+			Tables: []*database.Table{users, groups, members, view},
+		}},
+	}
+
+	include, err := ParseTableExpr("table.name.startsWith('app_') && !table.isJoinTable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exclude, err := ParseTableExpr("'name' in table.columns || table.isView")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := filterInfo(info, &Config{IncludeExpr: include, ExcludeExpr: exclude}); err != nil {
+		t.Fatal(err)
+	}
+	tables := info.Schemas[0].Tables
+	if len(tables) != 1 || tables[0] != groups {
+		var names []string
+		for _, t := range tables {
+			names = append(names, t.Name)
+		}
+		t.Fatalf("expected only app_groups to be included, but got %v", names)
+	}
+}
+
+func TestParseTableExprErrors(t *testing.T) {
+	if _, err := ParseTableExpr("table.name +"); err == nil {
+		t.Error("expected error parsing invalid expression, but got nil")
+	}
+	if _, err := ParseTableExpr("size(table.columns)"); err == nil {
+		t.Error("expected error parsing non-bool expression, but got nil")
+	}
+	e, err := ParseTableExpr("table.name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Match("public", &database.Table{Name: "users"}); err == nil {
+		t.Error("expected error evaluating non-bool expression, but got nil")
+	}
+}
@@ -0,0 +1,68 @@
+package run
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// windowsReserved are the names Windows reserves for devices, which can't be
+// used as filenames, with or without an extension.
+var windowsReserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// checkFilename returns the rendered filename name, cleaned, or an error if
+// it isn't safe to write to: if it's empty or absolute, if it's outside the
+// output directory, or if it couldn't be written on Windows.  Generated code
+// is often shared between systems, so the Windows rules apply everywhere.
+// With cfg.AllowUnsafeFilenames, name is returned as it is.
+func checkFilename(cfg *Config, name string) (string, error) {
+	if cfg.AllowUnsafeFilenames {
+		return name, nil
+	}
+	unsafe := func(reason string) error {
+		return errors.Errorf("Filename template rendered %q, which %s; fix the template, or set AllowUnsafeFilenames to write it anyway", name, reason)
+	}
+	if strings.TrimSpace(name) == "" {
+		return "", errors.New("Filename template rendered an empty filename")
+	}
+	if filepath.IsAbs(name) || filepath.VolumeName(name) != "" || strings.HasPrefix(name, "/") {
+		return "", unsafe("is an absolute path")
+	}
+	clean := filepath.Clean(name)
+	if clean == "." {
+		return "", unsafe("doesn't name a file")
+	}
+	if clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", unsafe("is outside the output directory")
+	}
+	for _, part := range strings.Split(filepath.ToSlash(clean), "/") {
+		if i := strings.IndexFunc(part, invalidFilenameRune); i >= 0 {
+			r, _ := utf8.DecodeRuneInString(part[i:])
+			return "", unsafe("has the character " + strconv.QuoteRune(r) + ", which is invalid in filenames on Windows")
+		}
+		if strings.HasSuffix(part, ".") || strings.HasSuffix(part, " ") {
+			return "", unsafe("has a name ending in a dot or space, which Windows drops")
+		}
+		base := strings.ToUpper(strings.SplitN(part, ".", 2)[0])
+		if windowsReserved[base] {
+			return "", unsafe("uses the name " + base + ", which Windows reserves")
+		}
+	}
+	return clean, nil
+}
+
+// invalidFilenameRune reports whether r can't be used in a filename on
+// Windows.  Backslashes are included, since they separate directories on
+// Windows, but not elsewhere.
+func invalidFilenameRune(r rune) bool {
+	return r < 0x20 || strings.ContainsRune(`<>:"|?*\`, r)
+}
@@ -0,0 +1,51 @@
+package run
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckFilename(t *testing.T) {
+	tests := []struct {
+		name, expected, err string
+	}{
+		{"models/user.go", filepath.Join("models", "user.go"), ""},
+		{"models//./user.go", filepath.Join("models", "user.go"), ""},
+		{"a/../b.go", "b.go", ""},
+		{".hidden", ".hidden", ""},
+		{"", "", "empty filename"},
+		{"/etc/passwd", "", "absolute path"},
+		{"../outside.go", "", "outside the output directory"},
+		{"a/../../outside.go", "", "outside the output directory"},
+		{"models/", "models", ""},
+		{".", "", "doesn't name a file"},
+		{"user:name.go", "", `':'`},
+		{"what?.go", "", `'?'`},
+		{`back\slash.go`, "", `'\\'`},
+		{"tab\t.go", "", `'\t'`},
+		{"models./user.go", "", "ending in a dot"},
+		{"con.go", "", "CON"},
+		{"lpt1/x.go", "", "LPT1"},
+		{"console.go", "console.go", ""},
+	}
+	for _, tt := range tests {
+		out, err := checkFilename(&Config{}, tt.name)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%q: unexpected error: %v", tt.name, err)
+			} else if out != tt.expected {
+				t.Errorf("%q: expected %q, but got %q", tt.name, tt.expected, out)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%q: expected an error containing %s, but got %v", tt.name, tt.err, err)
+		}
+	}
+
+	out, err := checkFilename(&Config{AllowUnsafeFilenames: true}, "../outside.go")
+	if err != nil || out != "../outside.go" {
+		t.Errorf("expected AllowUnsafeFilenames to allow any name, but got %q, %v", out, err)
+	}
+}
@@ -0,0 +1,38 @@
+package run
+
+import (
+	"go/format"
+
+	"github.com/pkg/errors"
+	"golang.org/x/tools/imports"
+)
+
+// The formatters that may be run on rendered Go output.
+const (
+	// FormatGofmt formats the output like gofmt does.
+	FormatGofmt = "gofmt"
+
+	// FormatGoimports formats the output like gofmt, and also adds missing
+	// imports and removes unused ones, like goimports does.
+	FormatGoimports = "goimports"
+)
+
+// formatSource formats the rendered output b with the named formatter.
+func formatSource(formatter string, b []byte) ([]byte, error) {
+	var out []byte
+	var err error
+	switch formatter {
+	case "":
+		return b, nil
+	case FormatGofmt:
+		out, err = format.Source(b)
+	case FormatGoimports:
+		out, err = imports.Process("", b, &imports.Options{Comments: true, TabIndent: true, TabWidth: 8})
+	default:
+		return nil, errors.Errorf("unknown formatter %q", formatter)
+	}
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to "+formatter+" rendered output")
+	}
+	return out, nil
+}
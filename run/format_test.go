@@ -0,0 +1,47 @@
+package run
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestFormatSource(t *testing.T) {
+	src := "package p\nimport \"os\"\nfunc  F( ) {\nfmt.Println(\"hi\")\n}\n"
+	tests := []struct {
+		formatter, expected string
+	}{
+		{"", src},
+		{FormatGofmt, "package p\n\nimport \"os\"\n\nfunc F() {\n\tfmt.Println(\"hi\")\n}\n"},
+		{FormatGoimports, "package p\n\nimport \"fmt\"\n\nfunc F() {\n\tfmt.Println(\"hi\")\n}\n"},
+	}
+	for _, tt := range tests {
+		out, err := formatSource(tt.formatter, []byte(src))
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.formatter, err)
+			continue
+		}
+		if string(out) != tt.expected {
+			t.Errorf("%q: expected:\n%s\nbut got:\n%s", tt.formatter, tt.expected, out)
+		}
+	}
+	if _, err := formatSource(FormatGofmt, []byte("package p\nfunc {")); err == nil || !strings.Contains(err.Error(), "gofmt") {
+		t.Errorf("expected an error formatting invalid Go, but got %v", err)
+	}
+}
+
+func TestRenderFormat(t *testing.T) {
+	target := OutputTarget{
+		Contents:       template.Must(template.New("").Parse("\n\npackage {{.}}\nvar  x = 1\n")),
+		TrimBlankLines: true,
+		Format:         FormatGofmt,
+	}
+	out, err := render(target, "p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []byte("package p\n\nvar x = 1\n"); !bytes.Equal(out, expected) {
+		t.Fatalf("expected %q, but got %q", expected, out)
+	}
+}
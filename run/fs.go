@@ -0,0 +1,55 @@
+package run
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FSPath converts a path from the config, which is relative to the root of an
+// fs.FS, to the slash separated form that fs.FS expects.
+func FSPath(name string) string {
+	return path.Clean(filepath.ToSlash(name))
+}
+
+// readSource reads a file that templates or static files are read from, from
+// cfg.FS if it's set, or the OS filesystem otherwise.
+func readSource(cfg *Config, name string) ([]byte, error) {
+	if cfg.FS == nil {
+		return ioutil.ReadFile(name)
+	}
+	return fs.ReadFile(cfg.FS, FSPath(name))
+}
+
+// WalkFiles calls fn for each of the files in the directory that it Includes,
+// with the file's path, which is under d.Dir, and its info.  The directory is
+// read from fsys, or the OS filesystem if fsys is nil.
+func (d StaticDir) WalkFiles(fsys fs.FS, fn func(path string, info fs.FileInfo) error) error {
+	root := "."
+	if fsys == nil {
+		fsys = os.DirFS(d.Dir)
+	} else {
+		root = FSPath(d.Dir)
+	}
+	return fs.WalkDir(fsys, root, func(p string, e fs.DirEntry, err error) error {
+		if err != nil || e.IsDir() {
+			return err
+		}
+		rel := p
+		if root != "." {
+			rel = strings.TrimPrefix(p, root+"/")
+		}
+		name := filepath.Join(d.Dir, filepath.FromSlash(rel))
+		if ok, err := d.Includes(name); err != nil || !ok {
+			return err
+		}
+		info, err := e.Info()
+		if err != nil {
+			return err
+		}
+		return fn(name, info)
+	})
+}
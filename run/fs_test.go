@@ -0,0 +1,95 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"text/template"
+
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run/data"
+)
+
+func TestStaticFilesFromFS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gnorm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	env := environ.Values{Stdout: &bytes.Buffer{}}
+	cfg := &Config{
+		ConfigData:     data.ConfigData{OutputDir: dir, StaticDir: "./bundle/static"},
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver:         dummyDriver{},
+		StaticTemplates: map[string]Template{
+			filepath.Join("bundle", "static", "version.txt"): template.Must(template.New("").Parse(`{{.Config.OutputDir}}`)),
+		},
+		// embedded files are read only.
+		FS: fstest.MapFS{
+			"bundle/static/go.mod":       {Data: []byte("module example\n"), Mode: 0444},
+			"bundle/static/sub/a.txt":    {Data: []byte("a"), Mode: 0444},
+			"bundle/static/version.txt":  {Data: []byte("ignored"), Mode: 0444},
+			"bundle/templates/table.tpl": {Data: []byte("not static")},
+		},
+	}
+	// the second run replaces the copies from the first.
+	for i := 0; i < 2; i++ {
+		if err := Generate(context.Background(), env, cfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, expected := range map[string]string{
+		"go.mod":      "module example\n",
+		"sub/a.txt":   "a",
+		"version.txt": dir,
+	} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != expected {
+			t.Errorf("expected %s to be %q, but got %q", name, expected, b)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "table.tpl")); !os.IsNotExist(err) {
+		t.Fatalf("expected only the static directory to be copied, but got %v", err)
+	}
+}
+
+func TestStaticFilesFromFSNewOutputDir(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	env := environ.Values{Stdout: &bytes.Buffer{}}
+	cfg := &Config{
+		ConfigData:     data.ConfigData{OutputDir: out, StaticDir: "static"},
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver:         dummyDriver{},
+		// the directories of an embed.FS are read only too.
+		FS: fstest.MapFS{
+			"static/sub/a.txt": {Data: []byte("a"), Mode: 0444},
+		},
+	}
+	if err := Generate(context.Background(), env, cfg); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"", "sub"} {
+		stat, err := os.Stat(filepath.Join(out, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stat.Mode().Perm()&0700 != 0700 {
+			t.Errorf("expected %q to be writable, but its mode is %v", filepath.Join(out, name), stat.Mode())
+		}
+	}
+	b, err := ioutil.ReadFile(filepath.Join(out, "sub", "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "a" {
+		t.Errorf("expected %q, but got %q", "a", b)
+	}
+}
@@ -6,10 +6,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/fs"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
@@ -21,53 +24,373 @@ import (
 )
 
 // Generate reads your database, gets the schema for it, and then generates
-// files based on your templates and your configuration.
-func Generate(env environ.Values, cfg *Config) error {
-	info, err := cfg.Driver.Parse(env.Log, cfg.ConnStr, cfg.Schemas, makeFilter(cfg.IncludeTables, cfg.ExcludeTables))
+// files based on your templates and your configuration.  Once ctx is done,
+// queries and PostRun commands are cancelled, and no more files are generated.
+func Generate(ctx context.Context, env environ.Values, cfg *Config) error {
+	if err := checkSink(cfg); err != nil {
+		return err
+	}
+	if cfg.Archive != "" && !cfg.Stdout && !cfg.DryRun {
+		return generateArchive(env, cfg, func(cfg *Config) error { return Generate(ctx, env, cfg) })
+	}
+	r, end := startRun(ctx, cfg)
+	defer end()
+	p := startProgress(env, "reading database", 0)
+	info, err := parseDB(ctx, env, cfg)
+	p.finish()
 	if err != nil {
 		return err
 	}
-	db, err := makeData(env.Log, info, cfg)
+	env.Logger().Infof("read %d tables in %d schemas", countTables(info), len(info.Schemas))
+	db, err := makeData(ctx, env, info, cfg)
 	if err != nil {
 		return err
 	}
-	if len(cfg.SchemaPaths) == 0 {
-		env.Log.Println("No SchemaPaths specified, skipping schemas.")
+	return generateData(ctx, env, r, db)
+}
+
+// genRun is a single run of Generate or Render.  It holds the state of the run
+// alongside its config, so that runs with the same config don't share any.
+type genRun struct {
+	*Config
+
+	// report, if set, records what the run did.
+	report *reporter
+
+	// only, if set, reports whether files are generated for a table, as picked
+	// by OnlySchemas and OnlyTables.
+	only func(schema, table string) bool
+
+	// progress, if set, counts the files generated so far.
+	progress *progress
+
+	// rendered, if set, records what each file was rendered from, to catch
+	// targets that render to the same path.
+	rendered *renderedPaths
+
+	// generated, if set, records the files written by the run, for the
+	// manifest.
+	generated *generatedFiles
+
+	// dryRun, if set, records the files a dry run would change.
+	dryRun *dryRunReport
+
+	// staging, if set, is the directory files are written to before they're
+	// moved into the output directory.
+	staging string
+
+	// postRuns, if set, queues PostRun commands to be run by PostRunWorkers
+	// workers.
+	postRuns *postRunQueue
+
+	// renders, if set, queues files to be generated by Parallel workers.
+	renders *renderQueue
+}
+
+// startRun starts a run with cfg, starting its RunHooks with ctx, and returns
+// the run and a function that ends the hooks once the run is done.
+func startRun(ctx context.Context, cfg *Config) (*genRun, func()) {
+	r := &genRun{Config: cfg}
+	if (cfg.Report || cfg.ReportFile != "") && !cfg.Stdout && !cfg.DryRun {
+		r.report = newReporter()
+	}
+	ends := make([]func(), len(cfg.RunHooks))
+	for i, hook := range cfg.RunHooks {
+		ends[i] = hook(ctx)
+	}
+	return r, func() {
+		for i := len(ends) - 1; i >= 0; i-- {
+			ends[i]()
+		}
+	}
+}
+
+// generateData generates the files for db, and copies the static files.
+func generateData(ctx context.Context, env environ.Values, r *genRun, db *data.DBData) error {
+	only, err := onlyFilter(r.Config)
+	if err != nil {
+		return err
+	}
+	r.only = only
+	r.progress = startProgress(env, "generating files", countTargets(r, db))
+	defer r.progress.finish()
+	r.rendered = newRenderedPaths()
+	if r.Sink != nil && !r.Stdout {
+		return generateSink(ctx, env, r, db)
+	}
+	if !r.Stdout {
+		r.generated = newGeneratedFiles()
+	}
+	if r.DryRun && !r.Stdout {
+		r.dryRun = &dryRunReport{}
+		if err := generateFiles(ctx, env, r, db); err != nil {
+			return err
+		}
+		if err := dryRunStatic(env, r); err != nil {
+			return err
+		}
+		return writeDryRunSummary(env, r)
+	}
+	if r.Atomic && !r.Stdout {
+		staging, err := stageOutput(r.Config)
+		if err != nil {
+			return err
+		}
+		r.staging = staging
+		defer os.RemoveAll(staging)
+	}
+	if r.PostRunWorkers > 1 && len(r.PostRun) > 0 && !r.Stdout {
+		r.postRuns = newPostRunQueue(ctx, env, r)
+		err := generateFiles(ctx, env, r, db)
+		// wait for the queued commands even if generating failed, so none
+		// are left running.
+		if werr := r.postRuns.wait(); err == nil {
+			err = werr
+		}
+		r.postRuns = nil
+		if err != nil {
+			return err
+		}
 	} else {
-		if err := generateSchemas(env, cfg, db); err != nil {
+		if err := generateFiles(ctx, env, r, db); err != nil {
+			return err
+		}
+	}
+	if r.Stdout {
+		return nil
+	}
+	staticDest := r.OutputDir
+	if r.staging != "" && staticDest != "" {
+		staticDest = r.staging
+	}
+	if err := copyStaticFiles(env, r, staticDest); err != nil {
+		return err
+	}
+	if r.staging != "" {
+		if err := commitStaged(env, r.staging, outputDir(r.Config), dirMode(r.Config)); err != nil {
 			return err
 		}
 	}
-	if len(cfg.EnumPaths) == 0 {
-		env.Log.Println("No EnumPath specified, skipping enums.")
+	covered, err := coveredFiles(r.Config)
+	if err != nil {
+		return err
+	}
+	if err := writeManifest(outputDir(r.Config), r.generated, covered); err != nil {
+		return err
+	}
+	if r.Prune {
+		if err := clean(env, r.Config, r.report, true, false); err != nil {
+			return err
+		}
+	}
+	return finishReport(env, r)
+}
+
+// finishReport prints the report of the run, and writes it to the ReportFile,
+// if they were asked for.
+func finishReport(env environ.Values, r *genRun) error {
+	if r.report == nil {
+		return nil
+	}
+	rep := r.report.finish()
+	if r.Report {
+		writeReport(env.Stdout, rep)
+	}
+	if r.ReportFile != "" {
+		return saveReport(r.ReportFile, rep)
+	}
+	return nil
+}
+
+// partial reports whether only some of the schemas or tables are generated.
+func partial(cfg *Config) bool {
+	return len(cfg.OnlySchemas) > 0 || len(cfg.OnlyTables) > 0
+}
+
+// pickedSchema reports whether files are generated for the schema.
+func pickedSchema(cfg *Config, schema *data.Schema) bool {
+	return len(onlySchemas(cfg, []string{schema.DBName})) > 0
+}
+
+// pickedTable reports whether files are generated for the table.
+func pickedTable(r *genRun, schema *data.Schema, table *data.Table) bool {
+	return r.only == nil || r.only(schema.DBName, table.DBName)
+}
+
+// pickData removes the schemas and tables that OnlySchemas and OnlyTables don't
+// pick from db, for showing just those.  It's done once the data is made, so
+// the foreign keys of the tables that are left still point to the ones that
+// were removed.
+func pickData(cfg *Config, db *data.DBData) error {
+	if !partial(cfg) {
+		return nil
+	}
+	only, err := onlyFilter(cfg)
+	if err != nil {
+		return err
+	}
+	schemas := db.Schemas[:0]
+	for _, s := range db.Schemas {
+		if !pickedSchema(cfg, s) {
+			continue
+		}
+		tables := s.Tables[:0]
+		for _, t := range s.Tables {
+			if only(s.DBName, t.DBName) {
+				tables = append(tables, t)
+			}
+		}
+		s.Tables = tables
+		schemas = append(schemas, s)
+	}
+	db.Schemas = schemas
+	return nil
+}
+
+// outputDir returns the directory generated files are written to.
+func outputDir(cfg *Config) string {
+	if cfg.OutputDir == "" {
+		return "."
+	}
+	return cfg.OutputDir
+}
+
+// generateFiles renders all the output targets, on cfg.Parallel workers if it
+// is set.  If only some schemas or tables are picked, only their targets are
+// rendered, and the targets that cover more than them are skipped: schemas and
+// enums when OnlyTables is set, and the database when either OnlySchemas or
+// OnlyTables is set.  The data they're rendered with is still the whole
+// database.
+func generateFiles(ctx context.Context, env environ.Values, r *genRun, db *data.DBData) error {
+	if r.Parallel < 2 || r.Stdout || r.dryRun != nil {
+		return generateTargets(ctx, env, r, db)
+	}
+	r.renders = newRenderQueue(r.Parallel)
+	err := generateTargets(ctx, lockOutput(env), r, db)
+	// wait for the queued files even if queueing failed, so none are left
+	// being written.
+	if werr := r.renders.wait(); err == nil {
+		err = werr
+	}
+	r.renders = nil
+	return err
+}
+
+// generateTargets renders the output targets for generateFiles.
+func generateTargets(ctx context.Context, env environ.Values, r *genRun, db *data.DBData) error {
+	if len(r.OnlyTables) > 0 {
+		env.Logger().Infof("Only generating some tables, skipping schemas and enums.")
+	} else if len(r.SchemaPaths) == 0 {
+		env.Logger().Infof("No SchemaPaths specified, skipping schemas.")
 	} else {
-		if err := generateEnums(env, cfg, db); err != nil {
+		if err := generateSchemas(ctx, env, r, db); err != nil {
 			return err
 		}
 	}
-	if len(cfg.TablePaths) == 0 {
-		env.Log.Println("No table path specified, skipping tables.")
+	if len(r.OnlyTables) > 0 {
+		// already logged above.
+	} else if len(r.EnumPaths) == 0 {
+		env.Logger().Infof("No EnumPath specified, skipping enums.")
 	} else {
-		if err := generateTables(env, cfg, db); err != nil {
+		if err := generateEnums(ctx, env, r, db); err != nil {
 			return err
 		}
 	}
-	return copyStaticFiles(env, cfg.StaticDir, cfg.OutputDir)
+	if len(r.TablePaths) == 0 {
+		env.Logger().Infof("No table path specified, skipping tables.")
+	} else {
+		if err := generateTables(ctx, env, r, db); err != nil {
+			return err
+		}
+	}
+	if len(r.DBPaths) > 0 {
+		if partial(r.Config) {
+			env.Logger().Infof("Only generating some schemas or tables, skipping DBPaths.")
+			return nil
+		}
+		return generateDB(ctx, env, r, db)
+	}
+	return nil
 }
 
-func generateSchemas(env environ.Values, cfg *Config, db *data.DBData) error {
+// These are the data passed to the Filename templates of output targets.
+// Besides the Names of what's being rendered, as strings, e.g.
+// {{.Schema}}/{{snake .Table}}/model.go, each has the same Data the contents
+// template is rendered with, so paths can be built from anything in it, e.g.
+// {{.Data.Table.Schema.DBName}}/{{snake .Data.Table.DBName}}/model.go.
+type (
+	schemaFile struct {
+		Database string
+		Schema   string
+		Data     data.SchemaData
+	}
+	enumFile struct {
+		Database string
+		Schema   string
+		Enum     string
+		Table    string
+		Data     data.EnumData
+	}
+	tableFile struct {
+		Database string
+		Schema   string
+		Table    string
+		Data     data.TableData
+	}
+	dbFile struct {
+		Data data.DatabaseData
+	}
+)
+
+// outputSource returns what the file rendered with the given filename data
+// and target was generated from, for the manifest.
+func outputSource(filedata interface{}, target OutputTarget) OutputFile {
+	out := OutputFile{Template: target.ContentsPath}
+	switch f := filedata.(type) {
+	case schemaFile:
+		out.Kind = OutputSchema
+		out.Database = f.Database
+		out.Schema = f.Data.Schema.DBName
+	case enumFile:
+		out.Kind = OutputEnum
+		out.Database = f.Database
+		out.Schema = f.Data.Enum.Schema.DBName
+		out.Table = f.Table
+		out.Enum = f.Data.Enum.DBName
+	case tableFile:
+		out.Kind = OutputTable
+		out.Database = f.Database
+		out.Schema = f.Data.Table.Schema.DBName
+		out.Table = f.Data.Table.DBName
+	case dbFile:
+		out.Kind = OutputDatabase
+	}
+	return out
+}
+
+func generateSchemas(ctx context.Context, env environ.Values, r *genRun, db *data.DBData) error {
 	for _, schema := range db.Schemas {
-		fileData := struct{ Schema string }{Schema: schema.Name}
+		if !pickedSchema(r.Config, schema) {
+			continue
+		}
 		contents := data.SchemaData{
 			Schema: schema,
 			DB:     db,
-			Config: cfg.ConfigData,
-			Params: cfg.Params,
+			Config: r.ConfigData,
 		}
-		for _, target := range cfg.SchemaPaths {
-			env.Log.Printf("Generating output for schema %v", schema.Name)
-			if err := genFile(env, fileData, contents, target, cfg.NoOverwriteGlobs, cfg.PostRun, cfg.OutputDir, cfg.TemplateEngine); err != nil {
-				return errors.WithMessage(err, "generating file for schema "+schema.Name)
+		for _, target := range r.SchemaPaths {
+			contents.Params = target.params(r.Config)
+			fileData := schemaFile{Database: schema.Database, Schema: schema.Name, Data: contents}
+			env.Logger().Debugf("Generating output for schema %v", schema.Name)
+			schema, contents, target := schema, contents, target
+			err := queueFile(r, func() error {
+				if err := genFile(ctx, env, r, fileData, contents, target); err != nil {
+					return errors.WithMessage(err, "generating file for schema "+schema.Name)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
 			}
 		}
 	}
@@ -80,20 +403,30 @@ type templateEngine struct {
 	UseStdout   bool
 }
 
-func generateEnums(env environ.Values, cfg *Config, db *data.DBData) error {
+func generateEnums(ctx context.Context, env environ.Values, r *genRun, db *data.DBData) error {
 	for _, schema := range db.Schemas {
+		if !pickedSchema(r.Config, schema) {
+			continue
+		}
 		for _, enum := range schema.Enums {
-			fileData := struct{ Schema, Enum, Table string }{Schema: schema.Name, Enum: enum.Name, Table: enum.Table.DBName}
 			contents := data.EnumData{
 				Enum:   enum,
 				DB:     db,
-				Config: cfg.ConfigData,
-				Params: cfg.Params,
+				Config: r.ConfigData,
 			}
-			for _, target := range cfg.EnumPaths {
-				if err := genFile(env, fileData, contents, target, cfg.NoOverwriteGlobs, cfg.PostRun, cfg.OutputDir, cfg.TemplateEngine); err != nil {
-					env.Log.Printf("Generating output for enum %v", enum.Name)
-					return errors.WithMessage(err, "generating file for enum "+enum.Name)
+			for _, target := range r.EnumPaths {
+				contents.Params = target.params(r.Config)
+				fileData := enumFile{Database: schema.Database, Schema: schema.Name, Enum: enum.Name, Table: enum.Table.DBName, Data: contents}
+				enum, contents, target := enum, contents, target
+				err := queueFile(r, func() error {
+					if err := genFile(ctx, env, r, fileData, contents, target); err != nil {
+						env.Logger().Debugf("Generating output for enum %v", enum.Name)
+						return errors.WithMessage(err, "generating file for enum "+enum.Name)
+					}
+					return nil
+				})
+				if err != nil {
+					return err
 				}
 			}
 		}
@@ -101,20 +434,30 @@ func generateEnums(env environ.Values, cfg *Config, db *data.DBData) error {
 	return nil
 }
 
-func generateTables(env environ.Values, cfg *Config, db *data.DBData) error {
+func generateTables(ctx context.Context, env environ.Values, r *genRun, db *data.DBData) error {
 	for _, schema := range db.Schemas {
 		for _, table := range schema.Tables {
+			if !pickedTable(r, schema, table) {
+				continue
+			}
 			contents := data.TableData{
 				Table:  table,
 				DB:     db,
-				Config: cfg.ConfigData,
-				Params: cfg.Params,
+				Config: r.ConfigData,
 			}
-			fileData := struct{ Schema, Table string }{Schema: schema.Name, Table: table.Name}
-			for _, target := range cfg.TablePaths {
-				if err := genFile(env, fileData, contents, target, cfg.NoOverwriteGlobs, cfg.PostRun, cfg.OutputDir, cfg.TemplateEngine); err != nil {
-					env.Log.Printf("Generating output for table %v", table.Name)
-					return errors.WithMessage(err, "generating file for table "+table.Name)
+			for _, target := range r.TablePaths {
+				contents.Params = target.params(r.Config)
+				fileData := tableFile{Database: schema.Database, Schema: schema.Name, Table: table.Name, Data: contents}
+				table, contents, target := table, contents, target
+				err := queueFile(r, func() error {
+					if err := genFile(ctx, env, r, fileData, contents, target); err != nil {
+						env.Logger().Debugf("Generating output for table %v", table.Name)
+						return errors.WithMessage(err, "generating file for table "+table.Name)
+					}
+					return nil
+				})
+				if err != nil {
+					return err
 				}
 			}
 		}
@@ -122,52 +465,361 @@ func generateTables(env environ.Values, cfg *Config, db *data.DBData) error {
 	return nil
 }
 
-func genFile(env environ.Values, filedata, contents interface{}, target OutputTarget, noOverwriteGlobs, postrun []string, outputDir string, engine templateEngine) error {
+func generateDB(ctx context.Context, env environ.Values, r *genRun, db *data.DBData) error {
+	contents := data.DatabaseData{
+		DB:     db,
+		Config: r.ConfigData,
+	}
+	for _, target := range r.DBPaths {
+		contents.Params = target.params(r.Config)
+		env.Logger().Debugf("Generating output for database")
+		contents, target := contents, target
+		err := queueFile(r, func() error {
+			if err := genFile(ctx, env, r, dbFile{Data: contents}, contents, target); err != nil {
+				return errors.WithMessage(err, "generating file for database")
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func genFile(ctx context.Context, env environ.Values, r *genRun, filedata, contents interface{}, target OutputTarget) error {
+	if err := ctx.Err(); err != nil {
+		return errors.WithMessage(err, "generation stopped")
+	}
+	defer r.progress.add(1)
 	buf := &bytes.Buffer{}
 	err := target.Filename.Execute(buf, filedata)
 	if err != nil {
 		return errors.WithMessage(err, "failed to run Filename template")
 	}
-	outputPath := filepath.Join(outputDir, buf.String())
+	name := buf.String()
+	if name != "-" {
+		name, err = checkFilename(r.Config, name)
+		if err != nil {
+			return err
+		}
+		if err := r.rendered.claim(name, outputSource(filedata, target)); err != nil {
+			return err
+		}
+	}
+	if r.Stdout || name == "-" {
+		return genStdout(ctx, env, r.Config, name, contents, target)
+	}
+	if r.Sink != nil {
+		return genSink(ctx, env, r, name, contents, target)
+	}
+	outputPath := filepath.Join(r.OutputDir, name)
+
+	noOverwrite := false
+	for _, glob := range r.NoOverwriteGlobs {
+		m, err := filepath.Match(glob, name)
+		if err != nil {
+			return errors.WithMessage(err, "error checking glob")
+		}
+		if m {
+			noOverwrite = true
+			break
+		}
+	}
 
 	// if file exists and filename matches glob, abort
-	if _, err := os.Stat(outputPath); err == nil {
-		for _, glob := range noOverwriteGlobs {
-			m, err := filepath.Match(glob, buf.String())
-			if err != nil {
-				return errors.WithMessage(err, "error checking glob")
-			}
-			if m {
-				env.Log.Printf("Skipping generation for file %s", buf.String())
-				return nil
-			}
+	stat, err := os.Stat(outputPath)
+	if err == nil && noOverwrite {
+		env.Logger().Debugf("Skipping generation for file %s", name)
+		r.report.skipped(name)
+		return nil
+	}
+
+	// files that aren't overwritten belong to the user once they exist, so
+	// clean shouldn't remove them.
+	if !noOverwrite {
+		r.generated.add(name, outputSource(filedata, target))
+	}
+
+	// keep the old contents around so we can tell if anything changed.
+	var old []byte
+	if stat != nil {
+		old, err = ioutil.ReadFile(outputPath)
+		if err != nil {
+			return errors.Wrapf(err, "error reading existing file %q", outputPath)
 		}
 	}
+	if r.dryRun != nil {
+		return dryRunFile(ctx, env, r, name, old, stat != nil, contents, target)
+	}
 
-	if err := os.MkdirAll(filepath.Dir(outputPath), 0700); err != nil {
+	// with a staging directory, the file is written there, and only moved to
+	// outputPath once the whole run succeeds.
+	writePath := outputPath
+	if r.staging != "" {
+		writePath = filepath.Join(r.staging, name)
+	}
+	if err := os.MkdirAll(filepath.Dir(writePath), dirMode(r.Config)); err != nil {
 		return errors.WithMessage(err, "error creating template output directory")
 	}
+	changed, err := writeFile(ctx, env, r.Config, writePath, old, stat != nil, contents, target)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		env.Logger().Debugf("Skipping unchanged file %s", name)
+		r.report.unchanged(name)
+		// the file is left alone, but its permissions may have been
+		// configured since it was written.
+		return setFileMode(r.Config, target, outputPath)
+	}
+	r.report.written(name)
+	job := postRunJob{path: writePath, name: name, old: old, stat: stat}
+	if len(r.PostRun) == 0 {
+		if stat != nil {
+			return keepModTime(writePath, old, stat.ModTime())
+		}
+		return nil
+	}
+	if r.postRuns != nil {
+		r.postRuns.add(job)
+		return nil
+	}
+	return finishFile(ctx, env, r, job)
+}
+
+// writeFile renders the target to path, with the header and the regions kept
+// from old, the previous contents of the file if it existed.  It returns false
+// without writing the file if the rendered contents are the same as old.
+func writeFile(ctx context.Context, env environ.Values, cfg *Config, path string, old []byte, exists bool, contents interface{}, target OutputTarget) (bool, error) {
+	if target.Contents == nil {
+		if err := runExternalEngine(ctx, env.Env, path, target.ContentsPath, contents, cfg.TemplateEngine); err != nil {
+			return false, err
+		}
+		if err := addFileHeader(cfg, contents, path); err != nil {
+			return false, err
+		}
+		if exists {
+			if err := keepFileRegions(path, old); err != nil {
+				return false, err
+			}
+		}
+		return true, setFileMode(cfg, target, path)
+	}
+	out, err := render(target, contents)
+	if err != nil {
+		return false, err
+	}
+	out, err = addHeader(cfg, contents, path, out)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		out, err = keepRegions(out, old)
+		if err != nil {
+			return false, errors.WithMessage(err, path)
+		}
+	}
+	if exists && bytes.Equal(out, old) {
+		return false, nil
+	}
+	if err := ioutil.WriteFile(path, out, 0600); err != nil {
+		return false, errors.Wrapf(err, "error writing generated file %q", path)
+	}
+	return true, setFileMode(cfg, target, path)
+}
+
+// setFileMode sets the permissions of the file generated for target at path,
+// if they're configured.
+func setFileMode(cfg *Config, target OutputTarget, path string) error {
+	mode := fileMode(cfg, target)
+	if mode == 0 {
+		return nil
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		return errors.Wrapf(err, "error setting permissions of generated file %q", path)
+	}
+	return nil
+}
+
+// fileMode returns the configured permissions of the files generated for
+// target, or 0 if they aren't configured.
+func fileMode(cfg *Config, target OutputTarget) os.FileMode {
+	if target.FileMode != 0 {
+		return target.FileMode
+	}
+	return cfg.FileMode
+}
+
+// dirMode returns the permissions of the directories created for generated
+// files.
+func dirMode(cfg *Config) os.FileMode {
+	if cfg.DirMode == 0 {
+		return 0700
+	}
+	return cfg.DirMode
+}
 
-	if len(engine.CommandLine) != 0 {
-		if err := runExternalEngine(env.Env, outputPath, target.ContentsPath, contents, engine); err != nil {
+// keepModTime resets the modification time of the file at path to modTime if
+// its contents are the same as old.  This keeps files whose output is only
+// unchanged after PostRun (or an external engine) from looking modified to
+// incremental build tools.
+func keepModTime(path string, old []byte, modTime time.Time) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "error reading generated file %q", path)
+	}
+	if !bytes.Equal(b, old) {
+		return nil
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		return errors.Wrapf(err, "error resetting modification time of %q", path)
+	}
+	return nil
+}
+
+// genStdout writes the rendered contents of the target to env.Stdout instead
+// of to a file.  Unless the filename is "-", the contents are preceded by a
+// separator line with the path of the file they would have been written to.
+func genStdout(ctx context.Context, env environ.Values, cfg *Config, filename string, contents interface{}, target OutputTarget) error {
+	out, err := renderOutput(ctx, env, cfg, filename, contents, target)
+	if err != nil {
+		return err
+	}
+	if filename != "-" {
+		if _, err := fmt.Fprintf(env.Stdout, "==> %s <==\n", filepath.Join(cfg.OutputDir, filename)); err != nil {
 			return err
 		}
+		if len(out) > 0 && out[len(out)-1] != '\n' {
+			out = append(out, '\n')
+		}
+	}
+	_, err = env.Stdout.Write(out)
+	return err
+}
+
+// renderOutput returns the contents of the file generated for the target,
+// with its header, without writing it anywhere.
+func renderOutput(ctx context.Context, env environ.Values, cfg *Config, filename string, contents interface{}, target OutputTarget) ([]byte, error) {
+	var out []byte
+	if target.Contents == nil {
+		// external engines write their output to a file, so give them a
+		// temporary one to write to.
+		f, err := ioutil.TempFile("", "gnorm")
+		if err != nil {
+			return nil, errors.WithMessage(err, "can't create temp file for output")
+		}
+		f.Close()
+		defer os.Remove(f.Name())
+		if err := runExternalEngine(ctx, env.Env, f.Name(), target.ContentsPath, contents, cfg.TemplateEngine); err != nil {
+			return nil, err
+		}
+		out, err = ioutil.ReadFile(f.Name())
+		if err != nil {
+			return nil, errors.WithMessage(err, "can't read template engine output")
+		}
 	} else {
-		outbuf := &bytes.Buffer{}
-		if err := target.Contents.Execute(outbuf, contents); err != nil {
-			return errors.WithMessage(err, "failed to run contents template")
+		var err error
+		out, err = render(target, contents)
+		if err != nil {
+			return nil, err
 		}
-		if err := ioutil.WriteFile(outputPath, outbuf.Bytes(), 0600); err != nil {
-			return errors.Wrapf(err, "error writing generated file %q", outputPath)
+	}
+	return addHeader(cfg, contents, filename, out)
+}
+
+// render executes the contents template of the target with the given data.
+func render(target OutputTarget, contents interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := target.Contents.Execute(buf, contents); err != nil {
+		return nil, errors.WithMessage(withSnippet(err, target.ContentsPath), "failed to run contents template")
+	}
+	out := tidyBlankLines(buf.Bytes(), target.TrimBlankLines, target.CollapseBlankLines)
+	return formatSource(target.Format, out)
+}
+
+// errLocation matches the location of an error in a template, as reported by
+// text/template, e.g. "template: table.gotmpl:12:5: ".
+var errLocation = regexp.MustCompile(`template: (.+?):(\d+):(?:\d+:)? `)
+
+// snippetLines is the number of lines shown before and after the line of the
+// template that failed.
+const snippetLines = 2
+
+// templateError is an error executing a template, with a snippet of the
+// template source around the line that failed.
+type templateError struct {
+	err     error
+	snippet string
+}
+
+func (e templateError) Error() string {
+	return e.err.Error() + "\n" + e.snippet
+}
+
+// withSnippet adds the lines of the template at path surrounding the location
+// of err to the error, if err refers to a line in that template.
+func withSnippet(err error, path string) error {
+	m := errLocation.FindStringSubmatch(err.Error())
+	if m == nil || path == "" || m[1] != path {
+		return err
+	}
+	line, _ := strconv.Atoi(m[2])
+	b, rerr := ioutil.ReadFile(path)
+	if rerr != nil || line < 1 {
+		return err
+	}
+	lines := strings.Split(string(b), "\n")
+	if line > len(lines) {
+		return err
+	}
+	start, end := line-snippetLines, line+snippetLines
+	if start < 1 {
+		start = 1
+	}
+	if end > len(lines) {
+		end = len(lines)
+	}
+	buf := &bytes.Buffer{}
+	width := len(strconv.Itoa(end))
+	for x := start; x <= end; x++ {
+		marker := " "
+		if x == line {
+			marker = ">"
 		}
+		fmt.Fprintf(buf, "%s %*d | %s\n", marker, width, x, lines[x-1])
 	}
-	if len(postrun) > 0 {
-		return doPostRun(env, outputPath, postrun)
+	return templateError{err: err, snippet: strings.TrimSuffix(buf.String(), "\n")}
+}
+
+// tidyBlankLines removes leading and trailing blank lines from b if trim is
+// true, and replaces runs of blank lines with a single blank line if collapse
+// is true.  A line containing only whitespace is considered blank.
+func tidyBlankLines(b []byte, trim, collapse bool) []byte {
+	if !trim && !collapse {
+		return b
 	}
-	return nil
+	lines := bytes.SplitAfter(b, []byte("\n"))
+	blank := func(line []byte) bool { return len(bytes.TrimSpace(line)) == 0 }
+	if trim {
+		for len(lines) > 0 && blank(lines[0]) {
+			lines = lines[1:]
+		}
+		for len(lines) > 0 && blank(lines[len(lines)-1]) {
+			lines = lines[:len(lines)-1]
+		}
+	}
+	out := make([]byte, 0, len(b))
+	for x, line := range lines {
+		if collapse && x > 0 && blank(line) && blank(lines[x-1]) {
+			continue
+		}
+		out = append(out, line...)
+	}
+	return out
 }
 
-func runExternalEngine(env map[string]string, outputPath, templatePath string, contents interface{}, engine templateEngine) error {
+func runExternalEngine(ctx context.Context, env map[string]string, outputPath, templatePath string, contents interface{}, engine templateEngine) error {
 	b, err := json.Marshal(contents)
 	if err != nil {
 		return errors.WithMessage(err, "can't render data for template to json")
@@ -199,7 +851,7 @@ func runExternalEngine(env map[string]string, outputPath, templatePath string, c
 		}
 		args = append(args, buf.String())
 	}
-	cmd := exec.Command(args[0], args[1:]...)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
 	if engine.UseStdin {
 		cmd.Stdin = bytes.NewReader(b)
 	}
@@ -229,7 +881,7 @@ func runExternalEngine(env map[string]string, outputPath, templatePath string, c
 	return nil
 }
 
-func doPostRun(env environ.Values, file string, postrun []string) error {
+func doPostRun(ctx context.Context, env environ.Values, file string, postrun []string) error {
 	newenv := make(map[string]string, len(env.Env)+1)
 	for k := range env.Env {
 		newenv[k] = env.Env[k]
@@ -241,7 +893,7 @@ func doPostRun(env environ.Values, file string, postrun []string) error {
 		run[x] = os.Expand(s, conv)
 	}
 	var cmd *exec.Cmd
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
 	defer cancel()
 	if len(run) > 1 {
 		cmd = exec.CommandContext(ctx, run[0], run[1:]...)
@@ -256,24 +908,57 @@ func doPostRun(env environ.Values, file string, postrun []string) error {
 	return nil
 }
 
-// copyStaticFiles copies files recursively from src directory to dest directory
-// while preserving the directory structure
-func copyStaticFiles(env environ.Values, src string, dest string) error {
+// copyStaticFiles copies the files in each of the static directories to dest,
+// while preserving the directory structure, and records the copied files in
+// r.generated.
+func copyStaticFiles(env environ.Values, r *genRun, dest string) error {
+	for _, dir := range staticDirs(r.Config) {
+		if err := copyStaticDir(env, r, dir, dest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// staticDirs returns the directories of static files to copy, starting with
+// StaticDir, if it's set.
+func staticDirs(cfg *Config) []StaticDir {
+	if cfg.StaticDir == "" {
+		return cfg.StaticDirs
+	}
+	return append([]StaticDir{{Dir: cfg.StaticDir}}, cfg.StaticDirs...)
+}
+
+// copyStaticDir copies files recursively from the static directory to its
+// destination under dest.  Files with a template in cfg.StaticTemplates are
+// rendered instead of copied.
+func copyStaticDir(env environ.Values, r *genRun, dir StaticDir, dest string) error {
+	src := dir.Dir
 	if src == "" || dest == "" {
 		return nil
 	}
-	stat, err := os.Stat(src)
+	dest = filepath.Join(dest, dir.Dest)
+	var stat os.FileInfo
+	var err error
+	if r.FS != nil {
+		stat, err = fs.Stat(r.FS, FSPath(src))
+	} else {
+		stat, err = os.Stat(src)
+	}
 	if err != nil {
 		return err
 	}
 	if !stat.IsDir() {
 		return fmt.Errorf("Outputdir specifies a directory path that already exists as file %s", dest)
 	}
+	// files in an fs.FS, such as an embed.FS, may be read only, but the copies
+	// have to be writable so the next run can replace them.
+	dirPerm := stat.Mode().Perm() | 0700
 	var dstat os.FileInfo
 	dstat, err = os.Stat(dest)
 	if err != nil {
 		if os.IsNotExist(err) {
-			err = os.MkdirAll(dest, stat.Mode())
+			err = os.MkdirAll(dest, dirPerm)
 			if err != nil {
 				return err
 			}
@@ -285,30 +970,39 @@ func copyStaticFiles(env environ.Values, src string, dest string) error {
 	if !dstat.IsDir() {
 		return fmt.Errorf("%s is not a directory", dest)
 	}
-	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if info.IsDir() {
-			return nil
-		}
+	return dir.WalkFiles(r.FS, func(path string, info os.FileInfo) error {
+		mode := info.Mode().Perm() | 0200
 		base := filepath.Dir(path)
 		rel, err := filepath.Rel(src, base)
 		if err != nil {
 			return err
 		}
 		o := filepath.Join(dest, rel)
-		err = os.MkdirAll(o, stat.Mode())
+		err = os.MkdirAll(o, dirPerm)
 		if err != nil {
 			return err
 		}
-		f, err := os.Open(path)
+		r.generated.add(filepath.Join(dir.Dest, rel, filepath.Base(path)), OutputFile{Kind: OutputStatic, Template: path})
+		r.report.static(filepath.Join(dir.Dest, rel, filepath.Base(path)))
+		if _, ok := r.StaticTemplates[path]; ok {
+			b, err := renderStatic(r.Config, path)
+			if err != nil {
+				return err
+			}
+			return ioutil.WriteFile(filepath.Join(o, filepath.Base(path)), b, mode)
+		}
+		var f io.ReadCloser
+		if r.FS != nil {
+			f, err = r.FS.Open(FSPath(path))
+		} else {
+			f, err = os.Open(path)
+		}
 		if err != nil {
 			return err
 		}
 		defer f.Close()
 
-		t, err := os.OpenFile(filepath.Join(o, filepath.Base(path)), os.O_RDWR|os.O_TRUNC|os.O_CREATE, info.Mode())
+		t, err := os.OpenFile(filepath.Join(o, filepath.Base(path)), os.O_RDWR|os.O_TRUNC|os.O_CREATE, mode)
 		if err != nil {
 			return err
 		}
@@ -317,3 +1011,49 @@ func copyStaticFiles(env environ.Values, src string, dest string) error {
 		return err
 	})
 }
+
+// Includes reports whether the file at path, in the directory, should be
+// copied, according to the Include and Exclude globs of the directory.
+func (d StaticDir) Includes(path string) (bool, error) {
+	rel, err := filepath.Rel(d.Dir, path)
+	if err != nil {
+		return false, err
+	}
+	match := func(globs []string) (bool, error) {
+		for _, glob := range globs {
+			for _, name := range []string{rel, filepath.Base(rel)} {
+				m, err := filepath.Match(glob, name)
+				if err != nil {
+					return false, errors.WithMessage(err, "error checking glob")
+				}
+				if m {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	}
+	if len(d.Include) > 0 {
+		ok, err := match(d.Include)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	excluded, err := match(d.Exclude)
+	return !excluded, err
+}
+
+// renderStatic returns the contents of the static file at path.  If it has a
+// template in cfg.StaticTemplates, that's rendered with the config and params,
+// otherwise the file is read as it is.
+func renderStatic(cfg *Config, path string) ([]byte, error) {
+	t, ok := cfg.StaticTemplates[path]
+	if !ok {
+		return readSource(cfg, path)
+	}
+	buf := &bytes.Buffer{}
+	if err := t.Execute(buf, data.StaticData{Config: cfg.ConfigData, Params: cfg.Params}); err != nil {
+		return nil, errors.WithMessage(withSnippet(err, path), "failed to render static file "+path)
+	}
+	return buf.Bytes(), nil
+}
@@ -2,10 +2,10 @@ package run
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"io/ioutil"
-	"log"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -13,15 +13,27 @@ import (
 	"strings"
 	"testing"
 	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
 
 	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run/data"
 )
 
 func TestMain(m *testing.M) {
-	if os.Getenv("GNORM_RUNHELPER") == "" {
+	switch {
+	case os.Getenv("GNORM_NAMEHELPER") != "":
+		testNamer()
+	case os.Getenv("GNORM_RUNHELPER") != "":
+		testEngine()
+	case os.Getenv("GNORM_POSTRUNHELPER") != "":
+		testPostRun()
+	case os.Getenv("GNORM_DATAHOOKHELPER") != "":
+		testDataHook()
+	default:
 		os.Exit(m.Run())
 	}
-	testEngine()
 }
 
 func TestAtomicGenerate(t *testing.T) {
@@ -31,9 +43,7 @@ func TestAtomicGenerate(t *testing.T) {
 		// not have a .Name field.
 		Contents: template.Must(template.New("").Parse("{{.Name}}")),
 	}
-	env := environ.Values{
-		Log: log.New(ioutil.Discard, "", 0),
-	}
+	env := environ.Values{}
 	filename := "testfile.out"
 	original := []byte("goodbye world")
 	err := ioutil.WriteFile(filename, original, 0600)
@@ -42,7 +52,7 @@ func TestAtomicGenerate(t *testing.T) {
 	}
 	defer os.Remove(filename)
 	contents := "hello world"
-	err = genFile(env, filename, contents, target, nil, nil, ".", templateEngine{})
+	err = genFile(context.Background(), env, &genRun{Config: &Config{ConfigData: data.ConfigData{OutputDir: "."}}}, filename, contents, target)
 	if err == nil {
 		t.Fatal("Unexpected nil error generating contents. Should have failed.")
 	}
@@ -64,7 +74,7 @@ func TestCopyStaticFiles(t *testing.T) {
 	source := "testdata"
 	dest := "static_asset"
 
-	err := copyStaticFiles(environ.Values{}, source, dest)
+	err := copyStaticFiles(environ.Values{}, &genRun{Config: &Config{ConfigData: data.ConfigData{StaticDir: source}}}, dest)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -100,14 +110,18 @@ func TestCopyStaticFiles(t *testing.T) {
 	}
 }
 
+// globCfg returns a config that outputs to the current directory with the
+// given NoOverwriteGlobs.
+func globCfg(globs ...string) *Config {
+	return &Config{ConfigData: data.ConfigData{OutputDir: ".", NoOverwriteGlobs: globs}}
+}
+
 func TestNoOverwriteGlobs(t *testing.T) {
 	target := OutputTarget{
 		Filename: template.Must(template.New("").Parse("{{.}}")),
 		Contents: template.Must(template.New("").Parse("{{.}}")),
 	}
-	env := environ.Values{
-		Log: log.New(ioutil.Discard, "", 0),
-	}
+	env := environ.Values{}
 
 	filename := "testfile.out"
 
@@ -119,7 +133,7 @@ func TestNoOverwriteGlobs(t *testing.T) {
 		}
 		defer os.Remove(filename)
 
-		err = genFile(env, filename, "hello world", target, []string{"*.out"}, nil, ".", templateEngine{})
+		err = genFile(context.Background(), env, &genRun{Config: globCfg("*.out")}, filename, "hello world", target)
 		if err != nil {
 			t.Fatalf("Unexpected error generating contents: %s", err)
 		}
@@ -135,7 +149,7 @@ func TestNoOverwriteGlobs(t *testing.T) {
 
 		t.Run("does not match glob", func(t *testing.T) {
 			content := "hello world"
-			err = genFile(env, filename, content, target, []string{"bob"}, nil, ".", templateEngine{})
+			err = genFile(context.Background(), env, &genRun{Config: globCfg("bob")}, filename, content, target)
 			if err != nil {
 				t.Fatalf("Unexpected error generating contents: %s", err)
 			}
@@ -157,7 +171,7 @@ func TestNoOverwriteGlobs(t *testing.T) {
 		}
 
 		content := "hello world"
-		err := genFile(env, filename, content, target, []string{"*.out"}, nil, ".", templateEngine{})
+		err := genFile(context.Background(), env, &genRun{Config: globCfg("*.out")}, filename, content, target)
 		if err != nil {
 			t.Fatalf("Unexpected error generating contents: %s", err)
 		}
@@ -235,7 +249,7 @@ func TestTemplateEngine(t *testing.T) {
 		templates = append(templates, tp)
 	}
 
-	err = runExternalEngine(env, "outputpath", "templatepath", data, templateEngine{CommandLine: templates})
+	err = runExternalEngine(context.Background(), env, "outputpath", "templatepath", data, templateEngine{CommandLine: templates})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -298,7 +312,7 @@ func TestTemplateEngineStdinStdout(t *testing.T) {
 		templates = append(templates, tp)
 	}
 
-	err = runExternalEngine(env, outputfile, "templatepath", data, templateEngine{CommandLine: templates, UseStdin: true, UseStdout: true})
+	err = runExternalEngine(context.Background(), env, outputfile, "templatepath", data, templateEngine{CommandLine: templates, UseStdin: true, UseStdout: true})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -337,3 +351,507 @@ func TestTemplateEngineStdinStdout(t *testing.T) {
 		t.Errorf("expected to have written stdout to file %q, but got %q", output, b)
 	}
 }
+
+func TestGenerateDB(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := &Config{
+		ConfigData: data.ConfigData{OutputDir: dir},
+		DBPaths: []OutputTarget{{
+			Filename: template.Must(template.New("").Parse("registry.txt")),
+			Contents: template.Must(template.New("").Parse(`{{range .DB.Schemas}}{{range .Tables}}{{.Name}} {{end}}{{end}}{{.Params.x}}`)),
+		}},
+		Params: map[string]interface{}{"x": "y"},
+	}
+	db := &data.DBData{
+		Schemas: []*data.Schema{
+			{Tables: data.Tables{{Name: "a"}, {Name: "b"}}},
+			{Tables: data.Tables{{Name: "c"}}},
+		},
+	}
+	env := environ.Values{}
+	if err := generateDB(context.Background(), env, &genRun{Config: cfg}, db); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "registry.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "a b c y"; string(b) != expected {
+		t.Fatalf("expected %q, but got %q", expected, b)
+	}
+}
+
+func TestSkipUnchangedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	target := OutputTarget{
+		Filename: template.Must(template.New("").Parse("{{.}}")),
+		Contents: template.Must(template.New("").Parse("hello {{.}}")),
+	}
+	env := environ.Values{}
+	// PostRun would fail if it were run.
+	cfg := &Config{ConfigData: data.ConfigData{
+		OutputDir: dir,
+		PostRun:   []string{filepath.Join(dir, "does-not-exist")},
+	}}
+	filename := filepath.Join(dir, "a.txt")
+	if err := ioutil.WriteFile(filename, []byte("hello world"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(filename, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	if err := genFile(context.Background(), env, &genRun{Config: cfg}, "a.txt", "world", target); err != nil {
+		t.Fatalf("expected unchanged file to be skipped, but got error: %v", err)
+	}
+	stat, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stat.ModTime().Equal(modTime) {
+		t.Fatalf("expected modification time %v, but got %v", modTime, stat.ModTime())
+	}
+
+	cfg.PostRun = nil
+	if err := genFile(context.Background(), env, &genRun{Config: cfg}, "a.txt", "there", target); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "hello there"; string(b) != expected {
+		t.Fatalf("expected %q, but got %q", expected, b)
+	}
+}
+
+// testPostRun appends " done" to the file named by its argument, or fails if
+// the file's name contains "fail".
+func testPostRun() {
+	file := os.Args[1]
+	if strings.Contains(filepath.Base(file), "fail") {
+		os.Exit(1)
+	}
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(" done"); err != nil {
+		panic(err)
+	}
+}
+
+func TestPostRunWorkers(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GNORM_POSTRUNHELPER", "1")
+	target := OutputTarget{
+		Filename: template.Must(template.New("").Parse("{{.}}")),
+		Contents: template.Must(template.New("").Parse("hello {{.}}")),
+	}
+	env := environ.Values{}
+	cfg := &Config{
+		ConfigData: data.ConfigData{
+			OutputDir: dir,
+			PostRun:   []string{os.Args[0], "$GNORMFILE"},
+		},
+		PostRunWorkers: 3,
+	}
+	ctx := context.Background()
+	r := &genRun{Config: cfg}
+	names := []string{"a", "b", "c", "d", "e"}
+	r.postRuns = newPostRunQueue(ctx, env, r)
+	for _, name := range names {
+		if err := genFile(ctx, env, r, name, name, target); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.postRuns.wait(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := "hello " + name + " done"; string(b) != expected {
+			t.Fatalf("expected %q, but got %q", expected, b)
+		}
+	}
+
+	r.postRuns = newPostRunQueue(ctx, env, r)
+	if err := genFile(ctx, env, r, "fail", "x", target); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.postRuns.wait(); err == nil {
+		t.Fatal("expected an error from a failed PostRun, but got nil")
+	}
+
+	cfg.PostRunWarnOnly = true
+	r.postRuns = newPostRunQueue(ctx, env, r)
+	if err := genFile(ctx, env, r, "fail", "y", target); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.postRuns.wait(); err != nil {
+		t.Fatalf("expected a failed PostRun to only warn, but got error: %v", err)
+	}
+}
+
+func TestGenStdout(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	env := environ.Values{
+		Stdout: stdout,
+	}
+	target := OutputTarget{
+		Filename: template.Must(template.New("").Parse("{{.}}")),
+		Contents: template.Must(template.New("").Parse("hello {{.}}")),
+	}
+	cfg := &Config{ConfigData: data.ConfigData{OutputDir: "out"}, Stdout: true}
+	if err := genFile(context.Background(), env, &genRun{Config: cfg}, "a.txt", "world", target); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Stdout = false
+	if err := genFile(context.Background(), env, &genRun{Config: cfg}, "-", "stdout", target); err != nil {
+		t.Fatal(err)
+	}
+	expected := "==> " + filepath.Join("out", "a.txt") + " <==\nhello world\nhello stdout"
+	if stdout.String() != expected {
+		t.Fatalf("expected %q, but got %q", expected, stdout.String())
+	}
+	if _, err := os.Stat("out"); err == nil {
+		t.Fatal("expected no output directory to be created, but it was")
+	}
+}
+
+func TestTemplateErrorSnippet(t *testing.T) {
+	f, err := ioutil.TempFile("", "*.gotmpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	src := "one\ntwo\nthree {{.Nope}}\nfour\nfive\nsix\n"
+	if _, err := f.WriteString(src); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	target := OutputTarget{
+		Contents:     template.Must(template.New(f.Name()).Parse(src)),
+		ContentsPath: f.Name(),
+	}
+	_, err = render(target, "a string has no fields")
+	if err == nil {
+		t.Fatal("expected an error rendering the template, but got nil")
+	}
+	expected := `
+  1 | one
+  2 | two
+> 3 | three {{.Nope}}
+  4 | four
+  5 | five`
+	if !strings.HasSuffix(err.Error(), expected) {
+		t.Fatalf("expected error to end with:%s\n\nbut got:\n%s", expected, err)
+	}
+	if !strings.Contains(err.Error(), f.Name()+":3:") {
+		t.Fatalf("expected error to contain the template location, but got:\n%s", err)
+	}
+}
+
+func TestTidyBlankLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		trim     bool
+		collapse bool
+		expected string
+	}{
+		{name: "none", in: "\n\na\n\n\nb\n\n", expected: "\n\na\n\n\nb\n\n"},
+		{name: "trim", in: "\n \na\n\n\nb\n\n\t\n", trim: true, expected: "a\n\n\nb\n"},
+		{name: "collapse", in: "\n\na\n\n \n\nb\n\n", collapse: true, expected: "\na\n\nb\n\n"},
+		{name: "both", in: "\n\na\n\n\nb\n\n", trim: true, collapse: true, expected: "a\n\nb\n"},
+		{name: "no final newline", in: "a\n\n\nb", trim: true, collapse: true, expected: "a\n\nb"},
+		{name: "all blank", in: "\n\n\n", trim: true, collapse: true, expected: ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := string(tidyBlankLines([]byte(test.in), test.trim, test.collapse))
+			if out != test.expected {
+				t.Fatalf("expected %q, but got %q", test.expected, out)
+			}
+		})
+	}
+}
+
+func TestFilenameData(t *testing.T) {
+	// the examples given in the docs for TablePaths.
+	for filename, expected := range map[string]string{
+		"{{.Schema}}/{{snake .Table}}/model.go":                               "Public/user_data/model.go",
+		"{{.Data.Table.Schema.DBName}}/{{snake .Data.Table.DBName}}/model.go": "public/user_data/model.go",
+	} {
+		dir, err := ioutil.TempDir("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		cfg := &Config{
+			ConfigData: data.ConfigData{OutputDir: dir},
+			TablePaths: []OutputTarget{{
+				Filename: template.Must(template.New("").Funcs(environ.FuncMap).Parse(filename)),
+				Contents: template.Must(template.New("").Parse("{{.Table.Name}}")),
+			}},
+		}
+		schema := &data.Schema{Name: "Public", DBName: "public"}
+		schema.Tables = data.Tables{{Name: "UserData", DBName: "user_data", Schema: schema}}
+		db := &data.DBData{Schemas: []*data.Schema{schema}}
+		env := environ.Values{}
+		if err := generateTables(context.Background(), env, &genRun{Config: cfg}, db); err != nil {
+			t.Fatalf("%s: %v", filename, err)
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(expected)))
+		if err != nil {
+			t.Fatalf("%s: %v", filename, err)
+		}
+		if string(b) != "UserData" {
+			t.Fatalf("%s: expected %q, but got %q", filename, "UserData", b)
+		}
+	}
+}
+
+func TestFileModes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gnorm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "out")
+	env := environ.Values{Stdout: &bytes.Buffer{}}
+	cfg := &Config{
+		ConfigData:     data.ConfigData{OutputDir: out},
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver:         dummyDriver{},
+		TablePaths: []OutputTarget{{
+			Filename: template.Must(template.New("").Parse(`tables/{{.Table}}.txt`)),
+			Contents: template.Must(template.New("").Parse(`{{.Table.Name}}`)),
+		}},
+		SchemaPaths: []OutputTarget{{
+			Filename: template.Must(template.New("").Parse(`{{.Schema}}.sh`)),
+			Contents: template.Must(template.New("").Parse(`{{.Schema.Name}}`)),
+			FileMode: 0750,
+		}},
+		FileMode: 0640,
+		DirMode:  0750,
+	}
+	if err := Generate(context.Background(), env, cfg); err != nil {
+		t.Fatal(err)
+	}
+	checkMode := func(file string, expected os.FileMode) {
+		t.Helper()
+		fi, err := os.Stat(filepath.Join(out, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != expected {
+			t.Errorf("expected %s to have mode %v, but got %v", file, expected, fi.Mode().Perm())
+		}
+	}
+	checkMode("tables/table.txt", 0640)
+	checkMode("schema.sh", 0750)
+	// the umask may remove permissions from new directories, but the owner
+	// keeps the ones it was given.
+	fi, err := os.Stat(filepath.Join(out, "tables"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm()&0700 != 0700 || fi.Mode().Perm()&0022 != 0 {
+		t.Errorf("expected tables dir to have mode 0750 less the umask, but got %v", fi.Mode().Perm())
+	}
+
+	// files that are unchanged still get the configured mode.
+	cfg.FileMode = 0600
+	if err := Generate(context.Background(), env, cfg); err != nil {
+		t.Fatal(err)
+	}
+	checkMode("tables/table.txt", 0600)
+	checkMode("schema.sh", 0750)
+}
+
+func TestStaticTemplates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gnorm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	static := filepath.Join(dir, "static")
+	if err := os.MkdirAll(filepath.Join(static, "sub"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	mod := filepath.Join(static, "go.mod")
+	plain := filepath.Join(static, "sub", "plain.txt")
+	if err := ioutil.WriteFile(mod, []byte("module {{.Params.module}}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(plain, []byte("{{not a template}}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out")
+	env := environ.Values{Stdout: &bytes.Buffer{}}
+	cfg := &Config{
+		ConfigData:     data.ConfigData{OutputDir: out, StaticDir: static},
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver:         dummyDriver{},
+		DBPaths: []OutputTarget{{
+			Filename: template.Must(template.New("").Parse(`db.txt`)),
+			Contents: template.Must(template.New("").Parse(`db`)),
+		}},
+		Params: map[string]interface{}{"module": "example.com/app"},
+		StaticTemplates: map[string]Template{
+			mod: template.Must(template.New(mod).Parse("module {{.Params.module}}\n")),
+		},
+	}
+	if err := Generate(context.Background(), env, cfg); err != nil {
+		t.Fatal(err)
+	}
+	for file, expected := range map[string]string{
+		"go.mod":        "module example.com/app\n",
+		"sub/plain.txt": "{{not a template}}\n",
+	} {
+		b, err := ioutil.ReadFile(filepath.Join(out, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != expected {
+			t.Errorf("expected %s to contain %q, but got %q", file, expected, b)
+		}
+	}
+
+	// a dry run diffs the rendered file, too.
+	cfg.Params["module"] = "example.com/other"
+	cfg.DryRun = true
+	buf := &bytes.Buffer{}
+	env.Stdout = buf
+	if err := Generate(context.Background(), env, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "+module example.com/other") || strings.Contains(buf.String(), "plain.txt") {
+		t.Errorf("expected only a diff of go.mod, but got:\n%s", buf)
+	}
+}
+
+func TestStaticDirs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gnorm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"static/README.md":        "readme",
+		"assets/app.js":           "app",
+		"assets/app.min.js":       "min",
+		"assets/css/site.css":     "css",
+		"assets/notes.txt":        "notes",
+		"assets/docs/ignored.css": "ignored",
+	}
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out := filepath.Join(dir, "out")
+	env := environ.Values{Stdout: &bytes.Buffer{}}
+	cfg := &Config{
+		ConfigData:     data.ConfigData{OutputDir: out, StaticDir: filepath.Join(dir, "static")},
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver:         dummyDriver{},
+		DBPaths: []OutputTarget{{
+			Filename: template.Must(template.New("").Parse(`db.txt`)),
+			Contents: template.Must(template.New("").Parse(`db`)),
+		}},
+		StaticDirs: []StaticDir{{
+			Dir:     filepath.Join(dir, "assets"),
+			Dest:    filepath.Join("web", "assets"),
+			Include: []string{"*.js", "*.css"},
+			Exclude: []string{"*.min.js", "docs/*"},
+		}},
+	}
+	if err := Generate(context.Background(), env, cfg); err != nil {
+		t.Fatal(err)
+	}
+	var copied []string
+	err = filepath.Walk(out, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || info.Name() == ManifestFile {
+			return err
+		}
+		rel, err := filepath.Rel(out, path)
+		copied = append(copied, filepath.ToSlash(rel))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(copied)
+	expected := []string{"README.md", "db.txt", "web/assets/app.js", "web/assets/css/site.css"}
+	if !reflect.DeepEqual(copied, expected) {
+		t.Fatalf("expected files %q, but got %q", expected, copied)
+	}
+
+	m, err := ReadManifest(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m.Files, expected) {
+		t.Fatalf("expected manifest files %q, but got %q", expected, m.Files)
+	}
+}
+
+func TestGenerateCancelled(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gnorm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	env := environ.Values{Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}}
+	cfg := &Config{
+		ConfigData:     data.ConfigData{OutputDir: dir},
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver:         dummyDriver{},
+		TablePaths: []OutputTarget{{
+			Filename: template.Must(template.New("").Parse(`{{.Table}}.txt`)),
+			Contents: template.Must(template.New("").Parse(`{{.Table.Name}}`)),
+		}},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = Generate(ctx, env, cfg)
+	if errors.Cause(err) != context.Canceled {
+		t.Fatalf("expected context.Canceled, but got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "table.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected no files to be generated, but got %v", err)
+	}
+
+	// PostRun commands are killed when the run is cancelled.
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := doPostRun(ctx, env, "file", []string{"sleep", "5"}); err == nil {
+		t.Fatal("expected an error from a killed PostRun command, but got nil")
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Fatalf("expected PostRun to be killed promptly, but it took %v", time.Since(start))
+	}
+}
@@ -0,0 +1,260 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run/data"
+)
+
+// GraphFormat defines the diagram languages that Graph can write.
+type GraphFormat int
+
+const (
+	// GraphDOT writes the diagram in graphviz's DOT language.
+	GraphDOT GraphFormat = iota
+	// GraphMermaid writes a mermaid erDiagram.
+	GraphMermaid
+	// GraphPlantUML writes a PlantUML entity diagram.
+	GraphPlantUML
+)
+
+// GraphOptions configures the diagram that Graph draws.
+type GraphOptions struct {
+	// Group is the kind of PlantUML container, one of PlantUMLGroups, that
+	// each schema's tables are drawn in, labelled with the schema's name.  If
+	// empty, the tables aren't grouped.  The other formats ignore it.
+	Group string
+
+	// Markdown wraps the diagram in a fenced code block tagged with its
+	// format, so that it can be pasted into markdown that renders diagrams,
+	// as GitHub and GitLab do for mermaid.
+	Markdown bool
+}
+
+// PlantUMLGroups are the kinds of container that PlantUML diagrams can group
+// the tables of each schema in.
+var PlantUMLGroups = []string{"package", "namespace", "frame", "folder", "rectangle", "database", "cloud", "node"}
+
+// Graph reads the database the same way Generate does, with the same filters,
+// and writes an entity-relationship diagram of its tables, columns, and
+// foreign keys to env.Stdout.
+func Graph(ctx context.Context, env environ.Values, cfg *Config, format GraphFormat, opts GraphOptions) error {
+	if opts.Group != "" && !isPlantUMLGroup(opts.Group) {
+		return errors.Errorf("unsupported group %q, should be one of %s", opts.Group, strings.Join(PlantUMLGroups, ", "))
+	}
+	info, err := parseDB(ctx, env, cfg)
+	if err != nil {
+		return err
+	}
+	db, err := makeData(ctx, env, info, cfg)
+	if err != nil {
+		return err
+	}
+	buf := &bytes.Buffer{}
+	var lang string
+	switch format {
+	case GraphDOT:
+		lang = "dot"
+		writeDOT(buf, db)
+	case GraphMermaid:
+		lang = "mermaid"
+		writeMermaid(buf, db)
+	case GraphPlantUML:
+		lang = "plantuml"
+		writePlantUML(buf, db, opts.Group)
+	default:
+		return errors.Errorf("Unsupported format: %v", format)
+	}
+	if opts.Markdown {
+		b := append([]byte("```"+lang+"\n"), buf.Bytes()...)
+		buf = bytes.NewBuffer(append(b, "```\n"...))
+	}
+	_, err = env.Stdout.Write(buf.Bytes())
+	return err
+}
+
+func writeDOT(buf *bytes.Buffer, db *data.DBData) {
+	buf.WriteString("digraph schema {\n\trankdir=LR;\n\tnode [shape=plaintext];\n")
+	for _, s := range db.Schemas {
+		for _, t := range s.Tables {
+			fmt.Fprintf(buf, "\t%q [label=<<table border=\"0\" cellborder=\"1\" cellspacing=\"0\">", graphName(t))
+			fmt.Fprintf(buf, "<tr><td bgcolor=\"lightgrey\"><b>%s</b></td></tr>", html.EscapeString(graphName(t)))
+			for _, c := range t.Columns {
+				fmt.Fprintf(buf, "<tr><td align=\"left\">%s</td></tr>", html.EscapeString(strings.TrimSpace(c.DBName+" "+graphType(c)+" "+graphKeys(c))))
+			}
+			buf.WriteString("</table>>];\n")
+		}
+	}
+	for _, s := range db.Schemas {
+		for _, t := range s.Tables {
+			for _, fk := range graphFKs(t) {
+				fmt.Fprintf(buf, "\t%q -> %q [label=%q];\n", graphName(t), graphName(fk.RefTable), fk.DBName)
+			}
+		}
+	}
+	buf.WriteString("}\n")
+}
+
+func writeMermaid(buf *bytes.Buffer, db *data.DBData) {
+	buf.WriteString("erDiagram\n")
+	for _, s := range db.Schemas {
+		for _, t := range s.Tables {
+			fmt.Fprintf(buf, "    %s {\n", graphID(graphName(t)))
+			for _, c := range t.Columns {
+				// mermaid types can't have brackets, so spell out arrays.
+				typ := graphID(strings.Replace(graphType(c), "[]", " array", -1))
+				fmt.Fprintf(buf, "        %s %s", typ, graphID(c.DBName))
+				if keys := graphKeys(c); keys != "" {
+					fmt.Fprintf(buf, " %s", keys)
+				}
+				buf.WriteString("\n")
+			}
+			buf.WriteString("    }\n")
+		}
+	}
+	for _, s := range db.Schemas {
+		for _, t := range s.Tables {
+			for _, fk := range graphFKs(t) {
+				fmt.Fprintf(buf, "    %s %s %s : %q\n", graphID(graphName(t)), graphCardinality(fk), graphID(graphName(fk.RefTable)), fk.DBName)
+			}
+		}
+	}
+}
+
+func writePlantUML(buf *bytes.Buffer, db *data.DBData, group string) {
+	buf.WriteString("@startuml\nhide circle\nskinparam linetype ortho\n")
+	for _, s := range db.Schemas {
+		indent := ""
+		if group != "" {
+			fmt.Fprintf(buf, "%s %q {\n", group, graphSchemaName(s))
+			indent = "  "
+		}
+		for _, t := range s.Tables {
+			fmt.Fprintf(buf, "%sentity %q as %s {\n", indent, graphName(t), graphID(graphName(t)))
+			// primary keys go above the separator.
+			for _, c := range t.PrimaryKeys {
+				fmt.Fprintf(buf, "%s  * %s : %s <<PK>>\n", indent, c.DBName, graphType(c))
+			}
+			fmt.Fprintf(buf, "%s  --\n", indent)
+			for _, c := range t.Columns {
+				if c.IsPrimaryKey {
+					continue
+				}
+				mandatory := "  "
+				if !c.Nullable {
+					mandatory = "* "
+				}
+				fmt.Fprintf(buf, "%s  %s%s : %s", indent, mandatory, c.DBName, graphType(c))
+				if c.IsFK {
+					buf.WriteString(" <<FK>>")
+				}
+				buf.WriteString("\n")
+			}
+			fmt.Fprintf(buf, "%s}\n", indent)
+		}
+		if group != "" {
+			buf.WriteString("}\n")
+		}
+	}
+	for _, s := range db.Schemas {
+		for _, t := range s.Tables {
+			for _, fk := range graphFKs(t) {
+				fmt.Fprintf(buf, "%s %s %s : %s\n", graphID(graphName(t)), graphCardinality(fk), graphID(graphName(fk.RefTable)), fk.DBName)
+			}
+		}
+	}
+	buf.WriteString("@enduml\n")
+}
+
+// graphName returns the name of the table qualified by its schema, and its
+// database when reading several.
+func graphName(t *data.Table) string {
+	return graphSchemaName(t.Schema) + "." + t.DBName
+}
+
+// graphSchemaName returns the name of the schema, qualified by its database
+// when reading several.
+func graphSchemaName(s *data.Schema) string {
+	if s.Database != "" {
+		return s.Database + "." + s.DBName
+	}
+	return s.DBName
+}
+
+// isPlantUMLGroup reports whether group is one of PlantUMLGroups.
+func isPlantUMLGroup(group string) bool {
+	for _, g := range PlantUMLGroups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// graphType returns the column's type as it would be written in the database.
+func graphType(c *data.Column) string {
+	t := c.DBType
+	switch {
+	case c.Length > 0:
+		t = fmt.Sprintf("%s(%d)", t, c.Length)
+	case c.Precision > 0:
+		t = fmt.Sprintf("%s(%d,%d)", t, c.Precision, c.Scale)
+	}
+	if c.IsArray {
+		t += "[]"
+	}
+	return t
+}
+
+// graphKeys returns the kinds of keys the column is part of, in the form
+// mermaid uses.
+func graphKeys(c *data.Column) string {
+	var keys []string
+	if c.IsPrimaryKey {
+		keys = append(keys, "PK")
+	}
+	if c.IsFK {
+		keys = append(keys, "FK")
+	}
+	return strings.Join(keys, ", ")
+}
+
+// graphFKs returns the table's foreign keys, sorted by name so that the
+// diagram is the same every time.
+func graphFKs(t *data.Table) data.ForeignKeys {
+	fks := append(data.ForeignKeys(nil), t.ForeignKeys...)
+	sort.Slice(fks, func(i, j int) bool { return fks[i].DBName < fks[j].DBName })
+	return fks
+}
+
+// graphCardinality returns the crow's foot notation, used by both mermaid and
+// PlantUML, for the relationship from a table to the table its foreign key
+// references.  The reference is optional if any of the key's columns are
+// nullable.
+func graphCardinality(fk *data.ForeignKey) string {
+	for _, c := range fk.FKColumns {
+		if c.Column.Nullable {
+			return "}o--o|"
+		}
+	}
+	return "}o--||"
+}
+
+// nonIdent matches runs of characters that can't be in a mermaid or PlantUML
+// identifier.
+var nonIdent = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// graphID returns s with the characters that can't be in an identifier
+// replaced by underscores.
+func graphID(s string) string {
+	return strings.Trim(nonIdent.ReplaceAllString(s, "_"), "_")
+}
@@ -0,0 +1,145 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"text/template"
+
+	"github.com/andreyvit/diff"
+
+	"gnorm.org/gnorm/environ"
+)
+
+func TestGraph(t *testing.T) {
+	cfg := &Config{
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver:         dummyDriver{},
+	}
+	tests := []struct {
+		format   GraphFormat
+		expected string
+	}{
+		{GraphDOT, `
+digraph schema {
+	rankdir=LR;
+	node [shape=plaintext];
+	"schema.table" [label=<<table border="0" cellborder="1" cellspacing="0"><tr><td bgcolor="lightgrey"><b>schema.table</b></td></tr><tr><td align="left">col1 int PK</td></tr><tr><td align="left">col2 *int</td></tr><tr><td align="left">col3 string</td></tr><tr><td align="left">col4 *string</td></tr></table>>];
+	"schema.tb2" [label=<<table border="0" cellborder="1" cellspacing="0"><tr><td bgcolor="lightgrey"><b>schema.tb2</b></td></tr><tr><td align="left">col1 int PK</td></tr><tr><td align="left">col2 int FK</td></tr></table>>];
+	"schema.tb2" -> "schema.table" [label="tb2_col2_fkey"];
+}
+`[1:]},
+		{GraphMermaid, `
+erDiagram
+    schema_table {
+        int col1 PK
+        int col2
+        string col3
+        string col4
+    }
+    schema_tb2 {
+        int col1 PK
+        int col2 FK
+    }
+    schema_tb2 }o--|| schema_table : "tb2_col2_fkey"
+`[1:]},
+		{GraphPlantUML, `
+@startuml
+hide circle
+skinparam linetype ortho
+entity "schema.table" as schema_table {
+  * col1 : int <<PK>>
+  --
+    col2 : *int
+  * col3 : string
+    col4 : *string
+}
+entity "schema.tb2" as schema_tb2 {
+  * col1 : int <<PK>>
+  --
+  * col2 : int <<FK>>
+}
+schema_tb2 }o--|| schema_table : tb2_col2_fkey
+@enduml
+`[1:]},
+	}
+	for _, test := range tests {
+		out := &bytes.Buffer{}
+		env := environ.Values{Stdout: out}
+		if err := Graph(context.Background(), env, cfg, test.format, GraphOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != test.expected {
+			t.Errorf("format %v:\n%s", test.format, diff.LineDiff(test.expected, out.String()))
+		}
+	}
+}
+
+func TestGraphPlantUMLGroup(t *testing.T) {
+	cfg := &Config{
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver:         dummyDriver{},
+	}
+	out := &bytes.Buffer{}
+	env := environ.Values{Stdout: out}
+	if err := Graph(context.Background(), env, cfg, GraphPlantUML, GraphOptions{Group: "package"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := `
+@startuml
+hide circle
+skinparam linetype ortho
+package "schema" {
+  entity "schema.table" as schema_table {
+    * col1 : int <<PK>>
+    --
+      col2 : *int
+    * col3 : string
+      col4 : *string
+  }
+  entity "schema.tb2" as schema_tb2 {
+    * col1 : int <<PK>>
+    --
+    * col2 : int <<FK>>
+  }
+}
+schema_tb2 }o--|| schema_table : tb2_col2_fkey
+@enduml
+`[1:]
+	if out.String() != expected {
+		t.Errorf("unexpected diagram:\n%s", diff.LineDiff(expected, out.String()))
+	}
+	err := Graph(context.Background(), env, cfg, GraphPlantUML, GraphOptions{Group: "box"})
+	if err == nil {
+		t.Fatal("expected an error for an unsupported group, but got nil")
+	}
+}
+
+func TestGraphMarkdown(t *testing.T) {
+	cfg := &Config{
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver:         dummyDriver{},
+	}
+	out := &bytes.Buffer{}
+	env := environ.Values{Stdout: out}
+	if err := Graph(context.Background(), env, cfg, GraphMermaid, GraphOptions{Markdown: true}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "```mermaid\n" + `
+erDiagram
+    schema_table {
+        int col1 PK
+        int col2
+        string col3
+        string col4
+    }
+    schema_tb2 {
+        int col1 PK
+        int col2 FK
+    }
+    schema_tb2 }o--|| schema_table : "tb2_col2_fkey"
+`[1:] + "```\n"
+	if out.String() != expected {
+		t.Errorf("unexpected diagram:\n%s", diff.LineDiff(expected, out.String()))
+	}
+}
@@ -0,0 +1,215 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/codemodus/kace"
+
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run/data"
+)
+
+// GraphQL reads the database the same way Generate does, with the same
+// filters, and writes a GraphQL schema to env.Stdout, in the schema definition
+// language.  Each table gets an object type and each enum an enum type.
+// Columns become fields named by their DBName in camel case, and each foreign
+// key adds a field for the row it references, and a list field to the table
+// it references for the rows that reference it.
+func GraphQL(ctx context.Context, env environ.Values, cfg *Config) error {
+	info, err := parseDB(ctx, env, cfg)
+	if err != nil {
+		return err
+	}
+	db, err := makeData(ctx, env, info, cfg)
+	if err != nil {
+		return err
+	}
+	buf := &bytes.Buffer{}
+	writeGraphQL(buf, db)
+	_, err = env.Stdout.Write(buf.Bytes())
+	return err
+}
+
+// graphqlField is a field of a GraphQL object type.
+type graphqlField struct {
+	name, typ, description string
+}
+
+func writeGraphQL(buf *bytes.Buffer, db *data.DBData) {
+	prefix := func(s *data.Schema) string {
+		if len(db.Schemas) > 1 {
+			return s.Name
+		}
+		return ""
+	}
+	tableName := func(t *data.Table) string {
+		return graphqlName(prefix(t.Schema) + t.Name)
+	}
+	enumName := func(e *data.Enum) string {
+		if e.Table != nil && e.Table.DBName != "" {
+			return graphqlName(prefix(e.Schema) + e.Table.Name + e.Name)
+		}
+		return graphqlName(prefix(e.Schema) + e.Name)
+	}
+
+	types := map[*data.Table][]graphqlField{}
+	scalars := map[string]bool{}
+	for _, s := range db.Schemas {
+		for _, t := range s.Tables {
+			for _, c := range t.Columns {
+				var typ string
+				if e := columnEnum(c); e != nil {
+					typ = enumName(e)
+				} else {
+					typ = graphqlScalar(c)
+					if !graphqlBuiltin[typ] {
+						scalars[typ] = true
+					}
+				}
+				if c.IsArray {
+					typ = "[" + typ + "]"
+				}
+				if !c.Nullable {
+					typ += "!"
+				}
+				types[t] = append(types[t], graphqlField{name: kace.Camel(c.DBName), typ: typ, description: c.Comment})
+			}
+		}
+	}
+	// relationship fields are added after all the column fields, so that
+	// they can be named so they don't collide with them.
+	for _, s := range db.Schemas {
+		for _, t := range s.Tables {
+			for _, fk := range graphFKs(t) {
+				if fk.RefTable == nil {
+					continue
+				}
+				name := fk.RefTable.DBName
+				if len(fk.FKColumns) == 1 && strings.HasSuffix(fk.FKColumns[0].ColumnDBName, "_id") {
+					name = strings.TrimSuffix(fk.FKColumns[0].ColumnDBName, "_id")
+				}
+				typ := tableName(fk.RefTable)
+				if !graphqlNullableFK(fk) {
+					typ += "!"
+				}
+				types[t] = appendGraphQLField(types[t], graphqlField{name: kace.Camel(name), typ: typ}, fk)
+
+				typ = "[" + tableName(t) + "!]!"
+				types[fk.RefTable] = appendGraphQLField(types[fk.RefTable], graphqlField{name: kace.Camel(t.DBName), typ: typ}, fk)
+			}
+		}
+	}
+
+	var names []string
+	for name := range scalars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(buf, "scalar %s\n\n", name)
+	}
+	for _, s := range db.Schemas {
+		for _, e := range s.Enums {
+			fmt.Fprintf(buf, "enum %s {\n", enumName(e))
+			for _, v := range e.Values {
+				fmt.Fprintf(buf, "  %s\n", strings.ToUpper(graphqlName(v.DBName)))
+			}
+			buf.WriteString("}\n\n")
+		}
+	}
+	for _, s := range db.Schemas {
+		for _, t := range s.Tables {
+			writeGraphQLDescription(buf, "", t.Comment)
+			fmt.Fprintf(buf, "type %s {\n", tableName(t))
+			for _, f := range types[t] {
+				writeGraphQLDescription(buf, "  ", f.description)
+				fmt.Fprintf(buf, "  %s: %s\n", f.name, f.typ)
+			}
+			buf.WriteString("}\n\n")
+		}
+	}
+	// drop the blank line after the last definition.
+	if buf.Len() > 0 {
+		buf.Truncate(buf.Len() - 1)
+	}
+}
+
+// appendGraphQLField adds the field for the foreign key to fields, named by
+// the foreign key's DBName instead if there's already a field by its name.
+func appendGraphQLField(fields []graphqlField, f graphqlField, fk *data.ForeignKey) []graphqlField {
+	for _, existing := range fields {
+		if existing.name == f.name {
+			f.name = kace.Camel(fk.DBName)
+			break
+		}
+	}
+	return append(fields, f)
+}
+
+// graphqlNullableFK reports whether any of the foreign key's columns are
+// nullable, in which case the row it references is optional.
+func graphqlNullableFK(fk *data.ForeignKey) bool {
+	for _, c := range fk.FKColumns {
+		if c.Column != nil && c.Column.Nullable {
+			return true
+		}
+	}
+	return false
+}
+
+// graphqlBuiltin holds the scalar types every GraphQL schema has.
+var graphqlBuiltin = map[string]bool{"Int": true, "Float": true, "String": true, "Boolean": true, "ID": true}
+
+// graphqlScalar returns the scalar type for values of the column's database
+// type, not counting arrays.  Types that the built-in scalars can't hold, such
+// as 64 bit integers and timestamps, get custom scalars, and types gnorm
+// doesn't know are JSON.
+func graphqlScalar(c *data.Column) string {
+	s := openAPIType(c)
+	switch {
+	case s.Format == "int32":
+		return "Int"
+	case s.Format == "int64":
+		return "BigInt"
+	case s.Type == "number":
+		return "Float"
+	case s.Type == "boolean":
+		return "Boolean"
+	case s.Format == "uuid":
+		return "ID"
+	case s.Format == "date":
+		return "Date"
+	case s.Format == "date-time":
+		return "DateTime"
+	case s.Type == "string":
+		return "String"
+	}
+	return "JSON"
+}
+
+// graphqlName returns s with the characters that can't be in a GraphQL name
+// replaced by underscores.  Names can't start with a digit, so those get an
+// underscore in front.  Protobuf identifiers follow the same rules.
+func graphqlName(s string) string {
+	s = nonIdent.ReplaceAllString(s, "_")
+	if s == "" || (s[0] >= '0' && s[0] <= '9') {
+		s = "_" + s
+	}
+	return s
+}
+
+// writeGraphQLDescription writes the description, if there is one, as a
+// string on the line before what it describes.
+func writeGraphQLDescription(buf *bytes.Buffer, indent, description string) {
+	if description == "" {
+		return
+	}
+	// JSON strings are valid GraphQL strings.
+	b, _ := json.Marshal(description)
+	fmt.Fprintf(buf, "%s%s\n", indent, b)
+}
@@ -0,0 +1,122 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"text/template"
+
+	"github.com/andreyvit/diff"
+
+	"gnorm.org/gnorm/database"
+	"gnorm.org/gnorm/environ"
+)
+
+func TestGraphQL(t *testing.T) {
+	cfg := &Config{
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver:         dummyDriver{},
+	}
+	stdout := &bytes.Buffer{}
+	env := environ.Values{Stdout: stdout}
+	if err := GraphQL(context.Background(), env, cfg); err != nil {
+		t.Fatal(err)
+	}
+	expected := `
+scalar JSON
+
+enum enum {
+  ENUMVALUE
+}
+
+"a table"
+type table {
+  "first column"
+  col1: Int!
+  col2: JSON
+  col3: JSON!
+  col4: JSON
+  tb2: [tb2!]!
+}
+
+type tb2 {
+  col1: Int!
+  col2: Int!
+  table: table!
+}
+`[1:]
+	if stdout.String() != expected {
+		t.Fatalf("unexpected schema:\n%s", diff.LineDiff(expected, stdout.String()))
+	}
+}
+
+func TestGraphQLRelationships(t *testing.T) {
+	cfg := &Config{NameConversion: template.Must(template.New("").Funcs(environ.FuncMap).Parse(`{{pascal .}}`))}
+	info := &database.Info{Schemas: []*database.Schema{{
+		Name:  "public",
+		Enums: []*database.Enum{{Name: "book_type", Values: []*database.EnumValue{{Name: "fiction", Value: 1}, {Name: "non-fiction", Value: 2}}}},
+		Tables: []*database.Table{{
+			Name: "authors",
+			Columns: []*database.Column{
+				{Name: "id", Type: "bigint", IsPrimaryKey: true},
+				{Name: "name", Type: "text"},
+			},
+		}, {
+			Name:    "books",
+			Comment: "books in the library",
+			Columns: []*database.Column{
+				{Name: "id", Type: "uuid", IsPrimaryKey: true},
+				{Name: "author_id", Type: "bigint", IsForeignKey: true, ForeignKey: &database.ForeignKey{
+					SchemaName: "public", TableName: "books", ColumnName: "author_id", Name: "books_author_id_fkey",
+					ForeignTableName: "authors", ForeignColumnName: "id",
+				}},
+				{Name: "editor_id", Type: "bigint", Nullable: true, IsForeignKey: true, ForeignKey: &database.ForeignKey{
+					SchemaName: "public", TableName: "books", ColumnName: "editor_id", Name: "books_editor_id_fkey",
+					ForeignTableName: "authors", ForeignColumnName: "id",
+				}},
+				{Name: "type", Type: "book_type", UserDefined: true, Comment: `the "kind" of book`},
+				{Name: "tags", Type: "text", IsArray: true, Nullable: true},
+				{Name: "published", Type: "timestamptz"},
+			},
+		}},
+	}}}
+	db, err := makeData(context.Background(), environ.Values{}, info, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	writeGraphQL(buf, db)
+	expected := `
+scalar BigInt
+
+scalar DateTime
+
+enum BookType {
+  FICTION
+  NON_FICTION
+}
+
+type Authors {
+  id: BigInt!
+  name: String!
+  books: [Books!]!
+  booksEditorIDFkey: [Books!]!
+}
+
+"books in the library"
+type Books {
+  id: ID!
+  authorID: BigInt!
+  editorID: BigInt
+  "the \"kind\" of book"
+  type: BookType!
+  tags: [String]
+  published: DateTime!
+  author: Authors!
+  editor: Authors
+}
+`[1:]
+	if buf.String() != expected {
+		t.Fatalf("unexpected schema:\n%s", diff.LineDiff(expected, buf.String()))
+	}
+}
@@ -0,0 +1,88 @@
+package run
+
+import (
+	"bytes"
+	"io/ioutil"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"gnorm.org/gnorm/run/data"
+)
+
+// doNotEdit is the conventional comment text that marks a file as generated,
+// which go tools recognize when it's written as a // comment.
+const doNotEdit = "Code generated by gnorm. DO NOT EDIT."
+
+// HeaderData is the data passed to the Header template.
+type HeaderData struct {
+	Version   string // the version of gnorm generating the file
+	Schema    string // the DBName of the schema the file is generated from, if any
+	Timestamp string // the time the file was generated, if HeaderTimestamp is set
+	DoNotEdit string // "Code generated by gnorm. DO NOT EDIT."
+}
+
+// addHeader renders cfg.Header for the given template contents and puts it at
+// the start of out, which is the output for the file at path, preceded by the
+// license.  If there is no Header or License, out is returned unchanged.
+func addHeader(cfg *Config, contents interface{}, path string, out []byte) ([]byte, error) {
+	if cfg.Header == nil {
+		return addLicense(cfg.License, path, out), nil
+	}
+	hd := HeaderData{
+		Version:   cfg.Version,
+		Schema:    headerSchema(contents),
+		DoNotEdit: doNotEdit,
+	}
+	if cfg.HeaderTimestamp {
+		hd.Timestamp = time.Now().Format(time.RFC3339)
+	}
+	buf := &bytes.Buffer{}
+	if err := cfg.Header.Execute(buf, hd); err != nil {
+		return nil, errors.WithMessage(err, "failed to run Header template")
+	}
+	buf.Write(out)
+	return addLicense(cfg.License, path, buf.Bytes()), nil
+}
+
+// headerSchema returns the DBName of the schema the contents belong to, or an
+// empty string for whole-database contents.
+func headerSchema(contents interface{}) string {
+	var s *data.Schema
+	switch c := contents.(type) {
+	case data.SchemaData:
+		s = c.Schema
+	case data.TableData:
+		if c.Table != nil {
+			s = c.Table.Schema
+		}
+	case data.EnumData:
+		if c.Enum != nil {
+			s = c.Enum.Schema
+		}
+	}
+	if s == nil {
+		return ""
+	}
+	return s.DBName
+}
+
+// addFileHeader adds the header to the file at path, which was written by an
+// external template engine.
+func addFileHeader(cfg *Config, contents interface{}, path string) error {
+	if cfg.Header == nil && cfg.License == "" {
+		return nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "error reading generated file %q", path)
+	}
+	b, err = addHeader(cfg, contents, path, b)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, b, 0600); err != nil {
+		return errors.Wrapf(err, "error writing generated file %q", path)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package run
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"text/template"
+
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run/data"
+)
+
+func TestHeader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := &Config{
+		ConfigData: data.ConfigData{OutputDir: dir},
+		Header:     template.Must(template.New("").Parse("// {{.DoNotEdit}}\n// gnorm {{.Version}} {{.Schema}}{{.Timestamp}}\n\n")),
+		Version:    "1.2.3",
+	}
+	target := OutputTarget{
+		Filename: template.Must(template.New("").Parse("{{.}}")),
+		Contents: template.Must(template.New("").Parse("package {{.Table.Name}}")),
+	}
+	env := environ.Values{}
+	schema := &data.Schema{DBName: "public"}
+	contents := data.TableData{Table: &data.Table{Name: "users", Schema: schema}}
+	if err := genFile(context.Background(), env, &genRun{Config: cfg}, "users.go", contents, target); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "users.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "// Code generated by gnorm. DO NOT EDIT.\n// gnorm 1.2.3 public\n\npackage users"
+	if string(b) != expected {
+		t.Fatalf("expected %q, but got %q", expected, b)
+	}
+}
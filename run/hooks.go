@@ -0,0 +1,121 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run/data"
+)
+
+// DataHookFunc is a function that's run over the data before any templates are
+// rendered, after the LuaScript and the DataHook command.  It may change the
+// data, such as by adding values to the Meta maps of schemas, tables, columns,
+// and enums, and the templates see the changes.  Returning an error stops the
+// run.
+type DataHookFunc func(db *data.DBData) error
+
+// runDataHooks runs the DataHook command and then the DataHookFuncs over db.
+// Once ctx is done, no more hooks are run, and the command is killed.
+func runDataHooks(ctx context.Context, env environ.Values, cfg *Config, db *data.DBData) error {
+	if len(cfg.DataHook) > 0 {
+		if err := runDataCommand(ctx, env, cfg.DataHook, db); err != nil {
+			return err
+		}
+	}
+	for _, hook := range cfg.DataHookFuncs {
+		if err := ctx.Err(); err != nil {
+			return errors.WithMessage(err, "data hooks stopped")
+		}
+		if err := hook(db); err != nil {
+			return errors.WithMessage(err, "error running data hook")
+		}
+	}
+	return nil
+}
+
+// runDataCommand runs the given command line, writing db to its stdin as JSON.
+// The command is expected to write the data back to stdout as JSON, in the
+// same form, and the changes it made are copied into db by mergeData.
+func runDataCommand(ctx context.Context, env environ.Values, command []string, db *data.DBData) error {
+	conv := func(s string) string { return env.Env[s] }
+	args := make([]string, len(command))
+	for x, s := range command {
+		args[x] = os.Expand(s, conv)
+	}
+	b, err := json.Marshal(db)
+	if err != nil {
+		return errors.WithMessage(err, "can't convert data for DataHook to json")
+	}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	envvars := make([]string, 0, len(env.Env))
+	for k, v := range env.Env {
+		envvars = append(envvars, k+"="+v)
+	}
+	cmd.Env = envvars
+	cmd.Stdin = bytes.NewReader(b)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	env.Logger().Debugf("running data hook command %q", args)
+	if err := cmd.Run(); err != nil {
+		cl := strings.Join(args, " ")
+		if stderr.Len() > 0 {
+			return errors.WithMessage(err, fmt.Sprintf("failed to run data hook command %q\n%s", cl, stderr.String()))
+		}
+		return errors.WithMessage(err, "failed to run data hook command: "+cl)
+	}
+	out := &data.DBData{}
+	if err := json.Unmarshal(stdout.Bytes(), out); err != nil {
+		return errors.WithMessage(err, "can't parse the output of the data hook command")
+	}
+	mergeData(db, out)
+	return nil
+}
+
+// mergeData copies the Names, Meta maps, and comments of the schemas, tables,
+// columns, and enums in out, and the Types of the columns, into the matching
+// ones in db.  Schemas, tables, and columns are matched by their DBName, and
+// enums by their position and DBName.  Anything in out without a match in db
+// is ignored, so hooks can't add or remove items.
+func mergeData(db, out *data.DBData) {
+	for _, o := range out.Schemas {
+		var s *data.Schema
+		for _, sch := range db.Schemas {
+			if sch.DBName == o.DBName && sch.Database == o.Database {
+				s = sch
+				break
+			}
+		}
+		if s == nil {
+			continue
+		}
+		s.Name, s.Meta = o.Name, o.Meta
+		for _, ot := range o.Tables {
+			t := s.TablesByName[ot.DBName]
+			if t == nil {
+				continue
+			}
+			t.Name, t.Comment, t.Meta = ot.Name, ot.Comment, ot.Meta
+			for _, oc := range ot.Columns {
+				c := t.ColumnsByName[oc.DBName]
+				if c == nil {
+					continue
+				}
+				c.Name, c.Type, c.Comment, c.Meta = oc.Name, oc.Type, oc.Comment, oc.Meta
+			}
+		}
+		for x, oe := range o.Enums {
+			if x < len(s.Enums) && s.Enums[x].DBName == oe.DBName {
+				s.Enums[x].Name, s.Enums[x].Meta = oe.Name, oe.Meta
+			}
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/pkg/errors"
+
+	"gnorm.org/gnorm/database"
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run/data"
+)
+
+// testDataHook is run by TestMain when the test binary is used as a DataHook
+// command.
+func testDataHook() {
+	db := &data.DBData{}
+	if err := json.NewDecoder(os.Stdin).Decode(db); err != nil {
+		os.Stderr.WriteString(err.Error())
+		os.Exit(1)
+	}
+	s := db.Schemas[0]
+	s.Meta = map[string]interface{}{"owner": "billing"}
+	s.Tables[0].Name = "Renamed"
+	s.Tables[0].Columns[0].Type = "int64"
+	s.Tables = append(s.Tables, &data.Table{DBName: "added", Name: "Added"})
+	if err := json.NewEncoder(os.Stdout).Encode(db); err != nil {
+		os.Exit(1)
+	}
+}
+
+func hookInfo() *database.Info {
+	return &database.Info{
+		Schemas: []*database.Schema{{
+			Name: "schema",
+			Tables: []*database.Table{{
+				Name:    "table",
+				Columns: []*database.Column{{Name: "col1", Type: "int"}},
+			}},
+		}},
+	}
+}
+
+func TestDataHookCommand(t *testing.T) {
+	cfg := &Config{
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		DataHook:       []string{os.Args[0]},
+	}
+	env := environ.Values{
+		Env: map[string]string{"GNORM_DATAHOOKHELPER": "1"},
+	}
+	db, err := makeData(context.Background(), env, hookInfo(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := db.Schemas[0]
+	if s.Meta["owner"] != "billing" {
+		t.Errorf("expected schema meta owner to be billing, but got %v", s.Meta)
+	}
+	if len(s.Tables) != 1 {
+		t.Fatalf("expected the hook not to add tables, but got %d tables", len(s.Tables))
+	}
+	if name := s.Tables[0].Name; name != "Renamed" {
+		t.Errorf("expected table name %q, but got %q", "Renamed", name)
+	}
+	if typ := s.Tables[0].Columns[0].Type; typ != "int64" {
+		t.Errorf("expected column type %q, but got %q", "int64", typ)
+	}
+	if s.Tables[0].Schema != s || s.Tables[0].Columns[0].Table != s.Tables[0] {
+		t.Error("expected the data hook to keep the links between schemas, tables, and columns")
+	}
+}
+
+func TestDataHookCommandFails(t *testing.T) {
+	// without the helper env var, the test binary runs no tests and doesn't
+	// write json.
+	cfg := &Config{
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		DataHook:       []string{os.Args[0], "-test.run=^$"},
+	}
+	_, err := makeData(context.Background(), environ.Values{}, hookInfo(), cfg)
+	if err == nil || !strings.Contains(err.Error(), "data hook command") {
+		t.Fatalf("expected an error about the data hook command, but got %v", err)
+	}
+}
+
+func TestDataHookCommandCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cfg := &Config{
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		DataHook:       []string{os.Args[0]},
+	}
+	env := environ.Values{
+		Env: map[string]string{"GNORM_DATAHOOKHELPER": "1"},
+	}
+	cancel()
+	if _, err := makeData(ctx, env, hookInfo(), cfg); err == nil {
+		t.Fatal("expected an error for a cancelled run, but got nil")
+	}
+}
+
+func TestDataHookFuncs(t *testing.T) {
+	var order []string
+	cfg := &Config{NameConversion: template.Must(template.New("").Parse(`{{.}}`))}
+	for _, opt := range []Option{
+		WithDataHookFunc(func(db *data.DBData) error {
+			order = append(order, "first")
+			db.Schemas[0].Tables[0].Meta = map[string]interface{}{"audited": true}
+			return nil
+		}),
+		WithDataHookFunc(func(db *data.DBData) error {
+			order = append(order, "second")
+			return errors.New("boom")
+		}),
+	} {
+		opt(cfg)
+	}
+	db, err := makeData(context.Background(), environ.Values{}, hookInfo(), cfg)
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected the hook's error, but got %v", err)
+	}
+	if db != nil {
+		t.Error("expected no data when a hook fails")
+	}
+	if strings.Join(order, ",") != "first,second" {
+		t.Errorf("expected hooks to run in order, but got %v", order)
+	}
+}
+
+func TestDataHookFuncsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := false
+	cfg := &Config{NameConversion: template.Must(template.New("").Parse(`{{.}}`))}
+	WithDataHookFunc(func(db *data.DBData) error {
+		ran = true
+		return nil
+	})(cfg)
+	cancel()
+	if _, err := makeData(ctx, environ.Values{}, hookInfo(), cfg); errors.Cause(err) != context.Canceled {
+		t.Fatalf("expected the run's context error, but got %v", err)
+	}
+	if ran {
+		t.Error("expected no hooks to run once the run is cancelled")
+	}
+}
@@ -0,0 +1,124 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run/data"
+)
+
+// jsonSchemaDialect is the JSON Schema draft that JSONSchema writes.
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema reads the database the same way Generate does, with the same
+// filters, and writes a JSON Schema document for each table to sink, named
+// after the table with a .schema.json extension.  Columns become properties
+// named by their DBName, columns that aren't nullable are required, and
+// columns of an enum type list the enum's values.
+func JSONSchema(ctx context.Context, env environ.Values, cfg *Config, sink OutputSink) error {
+	info, err := parseDB(ctx, env, cfg)
+	if err != nil {
+		return err
+	}
+	db, err := makeData(ctx, env, info, cfg)
+	if err != nil {
+		return err
+	}
+	for _, s := range db.Schemas {
+		for _, t := range s.Tables {
+			name := t.Name
+			if len(db.Schemas) > 1 {
+				name = s.Name + name
+			}
+			name = graphqlName(name) + ".schema.json"
+			b, err := json.MarshalIndent(tableJSONSchema(t, name), "", "  ")
+			if err != nil {
+				return errors.WithMessage(err, "can't encode JSON Schema for table "+t.DBName)
+			}
+			if err := sink.WriteFile(name, append(b, '\n'), 0644); err != nil {
+				return err
+			}
+			env.Logger().Infof("wrote %s", name)
+		}
+	}
+	return nil
+}
+
+// jsonSchema is the subset of a JSON Schema that describes tables and their
+// columns.  Type is a string, or a list of strings for nullable columns.
+type jsonSchema struct {
+	Schema          string                 `json:"$schema,omitempty"`
+	ID              string                 `json:"$id,omitempty"`
+	Title           string                 `json:"title,omitempty"`
+	Description     string                 `json:"description,omitempty"`
+	Type            interface{}            `json:"type,omitempty"`
+	Format          string                 `json:"format,omitempty"`
+	ContentEncoding string                 `json:"contentEncoding,omitempty"`
+	MaxLength       int                    `json:"maxLength,omitempty"`
+	Enum            []interface{}          `json:"enum,omitempty"`
+	Items           *jsonSchema            `json:"items,omitempty"`
+	Properties      map[string]*jsonSchema `json:"properties,omitempty"`
+	Required        []string               `json:"required,omitempty"`
+}
+
+// tableJSONSchema returns the JSON Schema document for rows of the table.
+func tableJSONSchema(t *data.Table, id string) *jsonSchema {
+	doc := &jsonSchema{
+		Schema:      jsonSchemaDialect,
+		ID:          id,
+		Title:       t.Name,
+		Description: t.Comment,
+		Type:        "object",
+		Properties:  map[string]*jsonSchema{},
+	}
+	for _, c := range t.Columns {
+		doc.Properties[c.DBName] = columnJSONSchema(c)
+		if !c.Nullable {
+			doc.Required = append(doc.Required, c.DBName)
+		}
+	}
+	return doc
+}
+
+// columnJSONSchema returns the schema for values of the column.  The types and
+// formats are the same as gnorm openapi uses, except that only the formats
+// JSON Schema defines are kept.
+func columnJSONSchema(c *data.Column) *jsonSchema {
+	prop := &jsonSchema{Description: c.Comment}
+	if e := columnEnum(c); e != nil {
+		for _, v := range e.Values {
+			prop.Enum = append(prop.Enum, v.DBName)
+		}
+		prop.Type = "string"
+	} else {
+		s := openAPIType(c)
+		prop.Type = s.Type
+		prop.MaxLength = s.MaxLength
+		switch s.Format {
+		case "uuid", "date", "date-time":
+			prop.Format = s.Format
+		case "byte":
+			prop.ContentEncoding = "base64"
+		}
+	}
+	if prop.Type == "" {
+		// types gnorm doesn't know may hold any value.
+		prop.Type = nil
+	}
+	if c.IsArray {
+		prop = &jsonSchema{Type: "array", Items: prop, Description: prop.Description}
+		prop.Items.Description = ""
+	}
+	if c.Nullable {
+		if typ, ok := prop.Type.(string); ok {
+			prop.Type = []string{typ, "null"}
+		}
+		if prop.Enum != nil {
+			prop.Enum = append(prop.Enum, nil)
+		}
+	}
+	return prop
+}
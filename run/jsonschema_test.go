@@ -0,0 +1,138 @@
+package run
+
+import (
+	"context"
+	"testing"
+	"text/template"
+
+	"github.com/andreyvit/diff"
+
+	"gnorm.org/gnorm/database"
+	"gnorm.org/gnorm/environ"
+)
+
+// infoDriver is a driver that reads the schema info it holds.
+type infoDriver struct {
+	info *database.Info
+}
+
+func (d infoDriver) Parse(ctx context.Context, log environ.Logger, conn string, schemaNames []string, filterTables func(schema, table string) bool) (*database.Info, error) {
+	return d.info, nil
+}
+
+func TestJSONSchema(t *testing.T) {
+	cfg := &Config{
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver:         dummyDriver{},
+	}
+	sink := &MemorySink{}
+	if err := JSONSchema(context.Background(), environ.Values{}, cfg, sink); err != nil {
+		t.Fatal(err)
+	}
+	files := sink.Files()
+	if len(files) != 2 || files["tb2.schema.json"] == nil {
+		t.Fatalf("expected table.schema.json and tb2.schema.json, but got %v", files)
+	}
+	expected := `
+{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "$id": "table.schema.json",
+  "title": "table",
+  "description": "a table",
+  "type": "object",
+  "properties": {
+    "col1": {
+      "description": "first column",
+      "type": "integer"
+    },
+    "col2": {},
+    "col3": {},
+    "col4": {}
+  },
+  "required": [
+    "col1",
+    "col3"
+  ]
+}
+`[1:]
+	if got := string(files["table.schema.json"]); got != expected {
+		t.Fatalf("unexpected schema:\n%s", diff.LineDiff(expected, got))
+	}
+}
+
+func TestJSONSchemaTypes(t *testing.T) {
+	cfg := &Config{
+		NameConversion: template.Must(template.New("").Funcs(environ.FuncMap).Parse(`{{pascal .}}`)),
+		Driver: infoDriver{&database.Info{Schemas: []*database.Schema{{
+			Name:  "public",
+			Enums: []*database.Enum{{Name: "book_type", Values: []*database.EnumValue{{Name: "fiction", Value: 1}, {Name: "non-fiction", Value: 2}}}},
+			Tables: []*database.Table{{
+				Name: "books",
+				Columns: []*database.Column{
+					{Name: "id", Type: "uuid"},
+					{Name: "title", Type: "varchar", Length: 200},
+					{Name: "type", Type: "book_type", UserDefined: true, Nullable: true},
+					{Name: "tags", Type: "text", IsArray: true, Comment: "labels"},
+					{Name: "cover", Type: "bytea", Nullable: true},
+					{Name: "extra", Type: "jsonb", Nullable: true},
+				},
+			}},
+		}}}},
+	}
+	sink := &MemorySink{}
+	if err := JSONSchema(context.Background(), environ.Values{}, cfg, sink); err != nil {
+		t.Fatal(err)
+	}
+	expected := `
+{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "$id": "Books.schema.json",
+  "title": "Books",
+  "type": "object",
+  "properties": {
+    "cover": {
+      "type": [
+        "string",
+        "null"
+      ],
+      "contentEncoding": "base64"
+    },
+    "extra": {},
+    "id": {
+      "type": "string",
+      "format": "uuid"
+    },
+    "tags": {
+      "description": "labels",
+      "type": "array",
+      "items": {
+        "type": "string"
+      }
+    },
+    "title": {
+      "type": "string",
+      "maxLength": 200
+    },
+    "type": {
+      "type": [
+        "string",
+        "null"
+      ],
+      "enum": [
+        "fiction",
+        "non-fiction",
+        null
+      ]
+    }
+  },
+  "required": [
+    "id",
+    "title",
+    "tags"
+  ]
+}
+`[1:]
+	if got := string(sink.Files()["Books.schema.json"]); got != expected {
+		t.Fatalf("unexpected schema:\n%s", diff.LineDiff(expected, got))
+	}
+}
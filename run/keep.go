@@ -0,0 +1,121 @@
+package run
+
+import (
+	"bytes"
+	"io/ioutil"
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+// keepStart and keepEnd match the markers that surround protected regions in
+// generated files.  They may appear inside any kind of comment, e.g.
+// "// gnorm:keep-start imports" or "-- gnorm:keep-end".
+var (
+	keepStart = regexp.MustCompile(`gnorm:keep-start\s+(\S+)`)
+	keepEnd   = regexp.MustCompile(`gnorm:keep-end\b`)
+)
+
+// region is a protected region in a file.
+type region struct {
+	name string
+	// start and end are the indexes of the marker lines.
+	start, end int
+}
+
+// findRegions returns the protected regions in the given lines.
+func findRegions(lines [][]byte) ([]region, error) {
+	var regions []region
+	seen := map[string]bool{}
+	open := -1
+	var name string
+	for x, line := range lines {
+		if m := keepStart.FindSubmatch(line); m != nil {
+			if open >= 0 {
+				return nil, errors.Errorf("line %d: keep region %q starts inside keep region %q", x+1, m[1], name)
+			}
+			name = string(m[1])
+			if seen[name] {
+				return nil, errors.Errorf("line %d: duplicate keep region %q", x+1, name)
+			}
+			seen[name] = true
+			open = x
+			continue
+		}
+		if keepEnd.Match(line) {
+			if open < 0 {
+				return nil, errors.Errorf("line %d: keep-end without keep-start", x+1)
+			}
+			regions = append(regions, region{name: name, start: open, end: x})
+			open = -1
+		}
+	}
+	if open >= 0 {
+		return nil, errors.Errorf("line %d: keep region %q is never ended", open+1, name)
+	}
+	return regions, nil
+}
+
+// keepRegions returns generated with the contents of each protected region
+// replaced by the contents of the region with the same name in existing.
+// Regions that only exist in generated keep their generated contents, and
+// regions that only exist in existing are dropped.
+func keepRegions(generated, existing []byte) ([]byte, error) {
+	oldLines := bytes.SplitAfter(existing, []byte("\n"))
+	oldRegions, err := findRegions(oldLines)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error reading keep regions from existing file")
+	}
+	if len(oldRegions) == 0 {
+		return generated, nil
+	}
+	kept := make(map[string][][]byte, len(oldRegions))
+	for _, r := range oldRegions {
+		kept[r.name] = oldLines[r.start+1 : r.end]
+	}
+
+	lines := bytes.SplitAfter(generated, []byte("\n"))
+	regions, err := findRegions(lines)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error reading keep regions from generated output")
+	}
+	out := make([]byte, 0, len(generated))
+	prev := 0
+	for _, r := range regions {
+		content, ok := kept[r.name]
+		if !ok {
+			continue
+		}
+		for _, line := range lines[prev : r.start+1] {
+			out = append(out, line...)
+		}
+		for _, line := range content {
+			out = append(out, line...)
+		}
+		prev = r.end
+	}
+	for _, line := range lines[prev:] {
+		out = append(out, line...)
+	}
+	return out, nil
+}
+
+// keepFileRegions carries the protected regions in existing forward into the
+// file at path, which was written by an external template engine.
+func keepFileRegions(path string, existing []byte) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "error reading generated file %q", path)
+	}
+	out, err := keepRegions(b, existing)
+	if err != nil {
+		return errors.WithMessage(err, path)
+	}
+	if bytes.Equal(out, b) {
+		return nil
+	}
+	if err := ioutil.WriteFile(path, out, 0600); err != nil {
+		return errors.Wrapf(err, "error writing generated file %q", path)
+	}
+	return nil
+}
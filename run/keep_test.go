@@ -0,0 +1,73 @@
+package run
+
+import "testing"
+
+func TestKeepRegions(t *testing.T) {
+	generated := `package foo
+
+// gnorm:keep-start imports
+import "fmt"
+// gnorm:keep-end
+
+func A() {}
+
+// gnorm:keep-start extra
+// gnorm:keep-end
+`
+	existing := `package foo
+
+// gnorm:keep-start imports
+import (
+	"fmt"
+	"os"
+)
+// gnorm:keep-end
+
+func Old() {}
+
+// gnorm:keep-start extra
+func Mine() { os.Exit(1) }
+// gnorm:keep-end
+
+// gnorm:keep-start gone
+// gnorm:keep-end
+`
+	expected := `package foo
+
+// gnorm:keep-start imports
+import (
+	"fmt"
+	"os"
+)
+// gnorm:keep-end
+
+func A() {}
+
+// gnorm:keep-start extra
+func Mine() { os.Exit(1) }
+// gnorm:keep-end
+`
+	out, err := keepRegions([]byte(generated), []byte(existing))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+}
+
+func TestKeepRegionsErrors(t *testing.T) {
+	tests := map[string]string{
+		"unended":    "// gnorm:keep-start a\n",
+		"unstarted":  "// gnorm:keep-end\n",
+		"nested":     "// gnorm:keep-start a\n// gnorm:keep-start b\n// gnorm:keep-end\n",
+		"duplicated": "// gnorm:keep-start a\n// gnorm:keep-end\n// gnorm:keep-start a\n// gnorm:keep-end\n",
+	}
+	for name, existing := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := keepRegions([]byte("generated"), []byte(existing)); err == nil {
+				t.Fatal("expected an error, but got nil")
+			}
+		})
+	}
+}
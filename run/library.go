@@ -0,0 +1,65 @@
+package run
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run/data"
+)
+
+// Introspect reads the database described by cfg the same way Generate does,
+// with the same filters and name conversions, and returns the data that would
+// be passed to templates.  It lets Go programs that embed gnorm use the schema
+// directly, without rendering any templates.  Warnings are logged to stderr,
+// unless cfg has a Logger.
+func Introspect(ctx context.Context, cfg *Config) (*data.DBData, error) {
+	env := libraryEnv(cfg)
+	info, err := parseDB(ctx, env, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return makeData(ctx, env, info, cfg)
+}
+
+// Render generates the files for db, as returned by Introspect, based on the
+// templates and the rest of cfg, the same way Generate does after it reads the
+// database.  Programs may change db before rendering it.  Output that would go
+// to stdout, such as with cfg.Stdout set, is written to os.Stdout, and PostRun
+// commands expand the environment variables of this process.
+func Render(ctx context.Context, cfg *Config, db *data.DBData) error {
+	env := libraryEnv(cfg)
+	if err := checkSink(cfg); err != nil {
+		return err
+	}
+	if cfg.Archive != "" && !cfg.Stdout && !cfg.DryRun {
+		return generateArchive(env, cfg, func(cfg *Config) error { return Render(ctx, cfg, db) })
+	}
+	r, end := startRun(ctx, cfg)
+	defer end()
+	return generateData(ctx, env, r, db)
+}
+
+// libraryEnv returns the environment of this process, for runs that aren't
+// started from the command line.  It logs to cfg.Logger if that's set.
+func libraryEnv(cfg *Config) environ.Values {
+	vars := os.Environ()
+	env := make(map[string]string, len(vars))
+	for _, v := range vars {
+		if parts := strings.SplitN(v, "=", 2); len(parts) == 2 {
+			env[parts[0]] = parts[1]
+		}
+	}
+	var log environ.Logger = environ.NewLogger(os.Stderr, environ.LevelWarn, environ.LogText)
+	if cfg.Logger != nil {
+		log = cfg.Logger
+	}
+	return environ.Values{
+		Stderr: os.Stderr,
+		Stdout: os.Stdout,
+		Stdin:  os.Stdin,
+		Env:    env,
+		Log:    log,
+	}
+}
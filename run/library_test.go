@@ -0,0 +1,129 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"text/template"
+
+	"gnorm.org/gnorm/database"
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run/data"
+)
+
+func TestIntrospectAndRender(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gnorm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfg := &Config{
+		ConfigData:     data.ConfigData{OutputDir: dir},
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver:         dummyDriver{},
+		TablePaths: []OutputTarget{{
+			Filename: template.Must(template.New("").Parse(`{{.Table}}.txt`)),
+			Contents: template.Must(template.New("").Parse(`{{.Table.Name}} in {{.Table.Schema.Name}}`)),
+		}},
+	}
+	db, err := Introspect(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(db.Schemas) != 1 || len(db.Schemas[0].Tables) != 2 {
+		t.Fatalf("expected 1 schema with 2 tables, but got %#v", db.Schemas)
+	}
+
+	// programs can change the data before rendering it.
+	db.Schemas[0].Tables[0].Name = "Renamed"
+	if err := Render(context.Background(), cfg, db); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "Renamed.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "Renamed in schema" {
+		t.Fatalf("expected %q, but got %q", "Renamed in schema", b)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "tb2.txt")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRenderConcurrent(t *testing.T) {
+	sink := &MemorySink{}
+	cfg := &Config{
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver:         dummyDriver{},
+		Sink:           sink,
+		TablePaths: []OutputTarget{{
+			Filename: template.Must(template.New("").Parse(`{{.Table}}.txt`)),
+			Contents: template.Must(template.New("").Parse(`{{.Table.Name}}`)),
+		}},
+	}
+	db, err := Introspect(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// runs with the same config don't share their state, so each may render
+	// the same files.
+	errs := make(chan error)
+	for x := 0; x < 4; x++ {
+		go func() { errs <- Render(context.Background(), cfg, db) }()
+	}
+	for x := 0; x < 4; x++ {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+	if files := sink.Files(); len(files) != 2 {
+		t.Errorf("expected 2 files, but got %v", files)
+	}
+}
+
+// loggingDriver logs a debug message when it parses the database.
+type loggingDriver struct {
+	dummyDriver
+}
+
+func (d loggingDriver) Parse(ctx context.Context, log environ.Logger, conn string, schemaNames []string, filterTables func(schema, table string) bool) (*database.Info, error) {
+	log.Debugf("parsing %v", schemaNames)
+	return d.dummyDriver.Parse(ctx, log, conn, schemaNames, filterTables)
+}
+
+// recordLogger records the messages logged to it.
+type recordLogger struct {
+	msgs []string
+}
+
+func (r *recordLogger) Debugf(format string, v ...interface{}) {
+	r.msgs = append(r.msgs, "debug: "+fmt.Sprintf(format, v...))
+}
+
+func (r *recordLogger) Infof(format string, v ...interface{}) {
+	r.msgs = append(r.msgs, "info: "+fmt.Sprintf(format, v...))
+}
+
+func (r *recordLogger) Warnf(format string, v ...interface{}) {
+	r.msgs = append(r.msgs, "warn: "+fmt.Sprintf(format, v...))
+}
+
+func TestIntrospectLogger(t *testing.T) {
+	l := &recordLogger{}
+	cfg := &Config{
+		ConfigData:     data.ConfigData{Schemas: []string{"schema"}},
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver:         loggingDriver{},
+	}
+	WithLogger(l)(cfg)
+	if _, err := Introspect(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(l.msgs) == 0 || l.msgs[0] != "debug: parsing [schema]" {
+		t.Fatalf("expected the driver to log to the configured logger, but got %q", l.msgs)
+	}
+}
@@ -0,0 +1,74 @@
+package run
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+)
+
+// lineComments maps file extensions, and the names of files that don't have
+// one, to the prefix of a line comment in their language.
+var lineComments = map[string]string{
+	".go": "//", ".js": "//", ".jsx": "//", ".ts": "//", ".tsx": "//",
+	".java": "//", ".kt": "//", ".scala": "//", ".swift": "//", ".c": "//",
+	".h": "//", ".cc": "//", ".cpp": "//", ".hpp": "//", ".cs": "//",
+	".rs": "//", ".dart": "//", ".proto": "//",
+
+	".py": "#", ".rb": "#", ".sh": "#", ".bash": "#", ".pl": "#", ".r": "#",
+	".yaml": "#", ".yml": "#", ".toml": "#", ".tf": "#", ".mk": "#",
+	".graphql": "#", "Makefile": "#", "Dockerfile": "#",
+
+	".sql": "--", ".lua": "--", ".hs": "--", ".elm": "--",
+}
+
+// blockComments maps file extensions to the start and end of a block comment
+// in their language, for languages without line comments.
+var blockComments = map[string][2]string{
+	".css": {"/*", "*/"}, ".scss": {"/*", "*/"}, ".less": {"/*", "*/"},
+
+	".html": {"<!--", "-->"}, ".htm": {"<!--", "-->"}, ".xml": {"<!--", "-->"},
+	".svg": {"<!--", "-->"}, ".md": {"<!--", "-->"}, ".vue": {"<!--", "-->"},
+}
+
+// addLicense puts the license text, written as a comment in the language of
+// the file at path, at the start of out, after a #! line if out has one.  If
+// there's no license, or the language of the file isn't known, out is
+// returned unchanged.
+func addLicense(license, path string, out []byte) []byte {
+	if license == "" {
+		return out
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	prefix, ok := lineComments[ext]
+	if !ok {
+		prefix, ok = lineComments[filepath.Base(path)]
+	}
+	block, isBlock := blockComments[ext]
+	if !ok && !isBlock {
+		return out
+	}
+	buf := &bytes.Buffer{}
+	if bytes.HasPrefix(out, []byte("#!")) {
+		end := bytes.IndexByte(out, '\n') + 1
+		if end == 0 {
+			end = len(out)
+		}
+		buf.Write(out[:end])
+		out = out[end:]
+	}
+	lines := strings.Split(strings.TrimRight(license, "\n"), "\n")
+	if ok {
+		for _, l := range lines {
+			buf.WriteString(strings.TrimRight(prefix+" "+l, " ") + "\n")
+		}
+	} else {
+		buf.WriteString(block[0] + "\n")
+		for _, l := range lines {
+			buf.WriteString(strings.TrimRight(l, " ") + "\n")
+		}
+		buf.WriteString(block[1] + "\n")
+	}
+	buf.WriteString("\n")
+	buf.Write(out)
+	return buf.Bytes()
+}
@@ -0,0 +1,68 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"text/template"
+
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run/data"
+)
+
+func TestAddLicense(t *testing.T) {
+	license := "Copyright 2026 Example\n\nLicensed under MIT.\n"
+	tests := []struct {
+		path, out, expected string
+	}{
+		{"models/user.go", "package models\n", "// Copyright 2026 Example\n//\n// Licensed under MIT.\n\npackage models\n"},
+		{"schema.sql", "CREATE TABLE x();\n", "-- Copyright 2026 Example\n--\n-- Licensed under MIT.\n\nCREATE TABLE x();\n"},
+		{"run.sh", "#!/bin/sh\necho hi\n", "#!/bin/sh\n# Copyright 2026 Example\n#\n# Licensed under MIT.\n\necho hi\n"},
+		{"Makefile", "all:\n", "# Copyright 2026 Example\n#\n# Licensed under MIT.\n\nall:\n"},
+		{"index.HTML", "<p>\n", "<!--\nCopyright 2026 Example\n\nLicensed under MIT.\n-->\n\n<p>\n"},
+		{"data.json", "{}\n", "{}\n"},
+	}
+	for _, tt := range tests {
+		out := addLicense(license, tt.path, []byte(tt.out))
+		if string(out) != tt.expected {
+			t.Errorf("%s: expected:\n%s\nbut got:\n%s", tt.path, tt.expected, out)
+		}
+	}
+	if out := addLicense("", "x.go", []byte("package x\n")); string(out) != "package x\n" {
+		t.Errorf("expected no change without a license, but got %q", out)
+	}
+}
+
+func TestGenerateLicense(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gnorm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	env := environ.Values{Stdout: &bytes.Buffer{}}
+	cfg := &Config{
+		ConfigData:     data.ConfigData{OutputDir: dir},
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver:         dummyDriver{},
+		DBPaths: []OutputTarget{{
+			Filename: template.Must(template.New("").Parse(`db.go`)),
+			Contents: template.Must(template.New("").Parse("package db\n")),
+		}},
+		Header:  template.Must(template.New("").Parse("// {{.DoNotEdit}}\n\n")),
+		License: "Copyright Example",
+	}
+	if err := Generate(context.Background(), env, cfg); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "db.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "// Copyright Example\n\n// Code generated by gnorm. DO NOT EDIT.\n\npackage db\n"
+	if string(b) != expected {
+		t.Fatalf("expected:\n%s\nbut got:\n%s", expected, b)
+	}
+}
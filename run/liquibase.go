@@ -0,0 +1,207 @@
+package run
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"gnorm.org/gnorm/run/data"
+)
+
+// liquibaseChangeLog returns a changelog that creates the schema in db, with a
+// changeset for each enum, one for each table and its indexes, and one for all
+// the foreign keys, so that they're added after the tables they reference.
+// Liquibase has no change for enums, so enums that aren't part of their
+// column, as they are in mysql, are created with SQL.  Views are left out,
+// since gnorm doesn't read their definitions.
+func liquibaseChangeLog(db *data.DBData) *lbChangeLog {
+	log := &lbChangeLog{
+		Xmlns:          "http://www.liquibase.org/xml/ns/dbchangelog",
+		XmlnsXSI:       "http://www.w3.org/2001/XMLSchema-instance",
+		SchemaLocation: "http://www.liquibase.org/xml/ns/dbchangelog http://www.liquibase.org/xml/ns/dbchangelog/dbchangelog-latest.xsd",
+	}
+	var fks []*lbChange
+	for _, s := range db.Schemas {
+		for _, e := range ddlEnums(s) {
+			log.ChangeSets = append(log.ChangeSets, &lbChangeSet{
+				ID:      liquibaseID("create-type", s, e.DBName),
+				Author:  "gnorm",
+				Changes: []*lbChange{{SQL: fmt.Sprintf("CREATE TYPE %s AS ENUM (%s)", ddlQualified(DDLPostgres, s, e.DBName), ddlValues(e))}},
+			})
+		}
+		for _, t := range ddlTables(s) {
+			if t.IsView {
+				continue
+			}
+			create := &lbCreateTable{SchemaName: s.DBName, TableName: t.DBName, Remarks: t.Comment}
+			for _, c := range t.Columns {
+				col := &lbColumn{Name: c.DBName, Type: liquibaseType(c), DefaultValueComputed: c.Default, Remarks: c.Comment}
+				if !c.Nullable || c.IsPrimaryKey {
+					col.Constraints = &lbConstraints{}
+					if !c.Nullable {
+						col.Constraints.Nullable = new(bool)
+					}
+					if c.IsPrimaryKey {
+						pk := true
+						col.Constraints.PrimaryKey = &pk
+					}
+				}
+				create.Columns = append(create.Columns, col)
+			}
+			set := &lbChangeSet{ID: liquibaseID("create-table", s, t.DBName), Author: "gnorm", Changes: []*lbChange{{CreateTable: create}}}
+			for _, idx := range ddlIndexes(t) {
+				ci := &lbCreateIndex{SchemaName: s.DBName, TableName: t.DBName, IndexName: idx.DBName}
+				if idx.IsUnique {
+					unique := true
+					ci.Unique = &unique
+				}
+				for _, c := range idx.Columns {
+					ci.Columns = append(ci.Columns, &lbColumn{Name: c.DBName})
+				}
+				set.Changes = append(set.Changes, &lbChange{CreateIndex: ci})
+			}
+			log.ChangeSets = append(log.ChangeSets, set)
+			for _, fk := range graphFKs(t) {
+				fks = append(fks, &lbChange{AddForeignKeyConstraint: &lbForeignKey{
+					ConstraintName:            fk.DBName,
+					BaseTableSchemaName:       s.DBName,
+					BaseTableName:             t.DBName,
+					BaseColumnNames:           strings.Join(fk.FKColumns.ColumnDBNames(), ", "),
+					ReferencedTableSchemaName: fk.RefTable.Schema.DBName,
+					ReferencedTableName:       fk.RefTable.DBName,
+					ReferencedColumnNames:     strings.Join(fk.FKColumns.RefColumnDBNames(), ", "),
+				}})
+			}
+		}
+	}
+	if len(fks) > 0 {
+		log.ChangeSets = append(log.ChangeSets, &lbChangeSet{ID: "add-foreign-keys", Author: "gnorm", Changes: fks})
+	}
+	return log
+}
+
+// liquibaseID returns the ID of the changeset that creates the named enum or
+// table in schema s, e.g. "create-table:public:users".  The names are separated
+// with colons, since names with dashes would make IDs like
+// "create-table-a-b-c" ambiguous, and the database is included when the
+// schema is from one of several Databases, so that tables with the same name
+// in different databases get different IDs.
+func liquibaseID(kind string, s *data.Schema, name string) string {
+	parts := []string{kind}
+	if s.Database != "" {
+		parts = append(parts, s.Database)
+	}
+	return strings.Join(append(parts, s.DBName, name), ":")
+}
+
+// liquibaseType returns the column's type as it's written in a changelog.
+func liquibaseType(c *data.Column) string {
+	if e := columnEnum(c); e != nil {
+		if e.Table != nil && e.Table.DBName != "" {
+			return "ENUM(" + ddlValues(e) + ")"
+		}
+		t := e.DBName
+		if c.IsArray {
+			t += "[]"
+		}
+		return t
+	}
+	return graphType(c)
+}
+
+// The lb types are the parts of a Liquibase changelog that gnorm writes.  They
+// marshal to both of the forms Liquibase reads, XML and YAML.  Lists are
+// written as repeated elements in XML, and as lists of single key maps in YAML.
+
+type lbChangeLog struct {
+	XMLName        xml.Name     `xml:"databaseChangeLog" yaml:"-"`
+	Xmlns          string       `xml:"xmlns,attr" yaml:"-"`
+	XmlnsXSI       string       `xml:"xmlns:xsi,attr" yaml:"-"`
+	SchemaLocation string       `xml:"xsi:schemaLocation,attr" yaml:"-"`
+	ChangeSets     lbChangeSets `xml:"changeSet" yaml:"databaseChangeLog"`
+}
+
+type lbChangeSets []*lbChangeSet
+
+func (sets lbChangeSets) MarshalYAML() (interface{}, error) {
+	items := make([]map[string]*lbChangeSet, len(sets))
+	for x, s := range sets {
+		items[x] = map[string]*lbChangeSet{"changeSet": s}
+	}
+	return items, nil
+}
+
+type lbChangeSet struct {
+	ID      string      `xml:"id,attr" yaml:"id"`
+	Author  string      `xml:"author,attr" yaml:"author"`
+	Changes []*lbChange `yaml:"changes"`
+}
+
+// lbChange is one change of a changeset.  Only one of its fields is set.
+type lbChange struct {
+	CreateTable             *lbCreateTable `yaml:"createTable,omitempty"`
+	CreateIndex             *lbCreateIndex `yaml:"createIndex,omitempty"`
+	AddForeignKeyConstraint *lbForeignKey  `yaml:"addForeignKeyConstraint,omitempty"`
+	SQL                     string         `yaml:"sql,omitempty"`
+}
+
+// MarshalXML writes the change as the element for the field that's set.
+func (c *lbChange) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	switch {
+	case c.CreateTable != nil:
+		return e.EncodeElement(c.CreateTable, xml.StartElement{Name: xml.Name{Local: "createTable"}})
+	case c.CreateIndex != nil:
+		return e.EncodeElement(c.CreateIndex, xml.StartElement{Name: xml.Name{Local: "createIndex"}})
+	case c.AddForeignKeyConstraint != nil:
+		return e.EncodeElement(c.AddForeignKeyConstraint, xml.StartElement{Name: xml.Name{Local: "addForeignKeyConstraint"}})
+	}
+	return e.EncodeElement(c.SQL, xml.StartElement{Name: xml.Name{Local: "sql"}})
+}
+
+type lbCreateTable struct {
+	SchemaName string    `xml:"schemaName,attr" yaml:"schemaName"`
+	TableName  string    `xml:"tableName,attr" yaml:"tableName"`
+	Remarks    string    `xml:"remarks,attr,omitempty" yaml:"remarks,omitempty"`
+	Columns    lbColumns `xml:"column" yaml:"columns"`
+}
+
+type lbCreateIndex struct {
+	SchemaName string    `xml:"schemaName,attr" yaml:"schemaName"`
+	TableName  string    `xml:"tableName,attr" yaml:"tableName"`
+	IndexName  string    `xml:"indexName,attr" yaml:"indexName"`
+	Unique     *bool     `xml:"unique,attr,omitempty" yaml:"unique,omitempty"`
+	Columns    lbColumns `xml:"column" yaml:"columns"`
+}
+
+type lbColumns []*lbColumn
+
+func (cols lbColumns) MarshalYAML() (interface{}, error) {
+	items := make([]map[string]*lbColumn, len(cols))
+	for x, c := range cols {
+		items[x] = map[string]*lbColumn{"column": c}
+	}
+	return items, nil
+}
+
+type lbColumn struct {
+	Name                 string         `xml:"name,attr" yaml:"name"`
+	Type                 string         `xml:"type,attr,omitempty" yaml:"type,omitempty"`
+	DefaultValueComputed string         `xml:"defaultValueComputed,attr,omitempty" yaml:"defaultValueComputed,omitempty"`
+	Remarks              string         `xml:"remarks,attr,omitempty" yaml:"remarks,omitempty"`
+	Constraints          *lbConstraints `xml:"constraints,omitempty" yaml:"constraints,omitempty"`
+}
+
+type lbConstraints struct {
+	Nullable   *bool `xml:"nullable,attr,omitempty" yaml:"nullable,omitempty"`
+	PrimaryKey *bool `xml:"primaryKey,attr,omitempty" yaml:"primaryKey,omitempty"`
+}
+
+type lbForeignKey struct {
+	ConstraintName            string `xml:"constraintName,attr" yaml:"constraintName"`
+	BaseTableSchemaName       string `xml:"baseTableSchemaName,attr" yaml:"baseTableSchemaName"`
+	BaseTableName             string `xml:"baseTableName,attr" yaml:"baseTableName"`
+	BaseColumnNames           string `xml:"baseColumnNames,attr" yaml:"baseColumnNames"`
+	ReferencedTableSchemaName string `xml:"referencedTableSchemaName,attr" yaml:"referencedTableSchemaName"`
+	ReferencedTableName       string `xml:"referencedTableName,attr" yaml:"referencedTableName"`
+	ReferencedColumnNames     string `xml:"referencedColumnNames,attr" yaml:"referencedColumnNames"`
+}
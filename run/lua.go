@@ -0,0 +1,53 @@
+package run
+
+import (
+	"github.com/pkg/errors"
+	lua "github.com/yuin/gopher-lua"
+	luar "layeh.com/gopher-luar"
+
+	"gnorm.org/gnorm/run/data"
+)
+
+// runLuaScript runs the lua script at path over the given data.  The script
+// sees the data as the global value db, and the config's params as the global
+// value params.  Changes the script makes to db are seen by the templates.
+// Scripts may store their own values in the Meta map of schemas, tables,
+// columns, and enums, e.g. db.Schemas[1].Tables[1].Meta.audited = true.
+// Note that lua indexes lists starting at 1.
+func runLuaScript(path string, db *data.DBData, params map[string]interface{}) error {
+	initMeta(db)
+
+	L := lua.NewState()
+	defer L.Close()
+	L.SetGlobal("db", luar.New(L, db))
+	L.SetGlobal("params", luar.New(L, params))
+	if err := L.DoFile(path); err != nil {
+		return errors.WithMessage(err, "error running LuaScript "+path)
+	}
+	return nil
+}
+
+// initMeta makes sure all the Meta maps in db are non-nil, so that lua
+// scripts can write to them.
+func initMeta(db *data.DBData) {
+	for _, s := range db.Schemas {
+		if s.Meta == nil {
+			s.Meta = map[string]interface{}{}
+		}
+		for _, e := range s.Enums {
+			if e.Meta == nil {
+				e.Meta = map[string]interface{}{}
+			}
+		}
+		for _, t := range s.Tables {
+			if t.Meta == nil {
+				t.Meta = map[string]interface{}{}
+			}
+			for _, c := range t.Columns {
+				if c.Meta == nil {
+					c.Meta = map[string]interface{}{}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package run
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"gnorm.org/gnorm/run/data"
+)
+
+const transformScript = `
+for _, schema in db.Schemas() do
+	for _, table in schema.Tables() do
+		table.Name = string.upper(table.Name)
+		table.Meta.audited = params.audit
+		for _, col in table.Columns() do
+			if col.DBName == "created_at" then
+				col.Type = "time.Time"
+				col.Meta.autoSet = true
+			end
+		end
+	end
+end
+`
+
+func TestLuaScript(t *testing.T) {
+	f, err := ioutil.TempFile("", "*.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(transformScript); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	col := &data.Column{DBName: "created_at", Type: "string"}
+	other := &data.Column{DBName: "id", Type: "int"}
+	table := &data.Table{Name: "users", Columns: data.Columns{other, col}}
+	db := &data.DBData{
+		Schemas: []*data.Schema{{Tables: data.Tables{table}}},
+	}
+	params := map[string]interface{}{"audit": "yes"}
+	if err := runLuaScript(f.Name(), db, params); err != nil {
+		t.Fatal(err)
+	}
+	if table.Name != "USERS" {
+		t.Errorf(`expected table name "USERS", but got %q`, table.Name)
+	}
+	if table.Meta["audited"] != "yes" {
+		t.Errorf(`expected table Meta audited to be "yes", but got %v`, table.Meta["audited"])
+	}
+	if col.Type != "time.Time" {
+		t.Errorf(`expected column type "time.Time", but got %q`, col.Type)
+	}
+	if col.Meta["autoSet"] != true {
+		t.Errorf("expected column Meta autoSet to be true, but got %v", col.Meta["autoSet"])
+	}
+	if other.Type != "int" || len(other.Meta) != 0 {
+		t.Errorf("expected column id to be unchanged, but got %#v", other)
+	}
+}
+
+func TestLuaScriptError(t *testing.T) {
+	if err := runLuaScript("nope.lua", &data.DBData{}, nil); err == nil {
+		t.Fatal("expected an error running a missing script, but got nil")
+	}
+}
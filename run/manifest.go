@@ -0,0 +1,306 @@
+package run
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"gnorm.org/gnorm/environ"
+)
+
+// ManifestFile is the name of the file in the output directory that lists the
+// files gnorm generated there.
+const ManifestFile = ".gnorm-manifest.json"
+
+// ManifestVersion is the version of the manifest file format that this version
+// of gnorm writes.
+const ManifestVersion = 1
+
+// Manifest lists the files gnorm generated in an output directory, with paths
+// relative to it.
+type Manifest struct {
+	// Version is the version of the manifest file format.
+	Version int
+
+	// Files are the files written by the last run.
+	Files []string
+
+	// Orphans are the files written by earlier runs that the last run didn't
+	// write, such as the files for tables that no longer exist.
+	Orphans []string `json:",omitempty"`
+
+	// Outputs describe each of the Files: what it was generated from, and a
+	// hash of its contents, so that build tools can track gnorm's outputs.
+	Outputs []OutputFile `json:",omitempty"`
+}
+
+// The kinds of OutputFile.
+const (
+	OutputSchema   = "schema"
+	OutputEnum     = "enum"
+	OutputTable    = "table"
+	OutputDatabase = "database"
+	OutputStatic   = "static"
+)
+
+// OutputFile describes a file in the manifest.  The names of the database,
+// schema, table, and enum it was generated from are their names in the
+// database, and are only set for the kinds of output they apply to.
+type OutputFile struct {
+	// Path is the path of the file, relative to the output directory.
+	Path string
+
+	// Kind is what the file was generated for: schema, enum, table, database,
+	// or static, for files copied from the StaticDir.
+	Kind string
+
+	// Template is the path of the template the file was rendered from, or of
+	// the file it was copied from for static files.
+	Template string `json:",omitempty"`
+
+	Database string `json:",omitempty"`
+	Schema   string `json:",omitempty"`
+	Table    string `json:",omitempty"` // also set for (mysql) enums
+	Enum     string `json:",omitempty"`
+
+	// SHA256 is the hex encoded SHA-256 hash of the file's contents, after
+	// PostRun was run on it.
+	SHA256 string
+}
+
+// generatedFiles records the files written by a run, relative to the output
+// directory, and what they were generated from.  Files may be added from
+// multiple goroutines.  Adding to a nil *generatedFiles does nothing.
+type generatedFiles struct {
+	mu    sync.Mutex
+	files map[string]OutputFile
+}
+
+func newGeneratedFiles() *generatedFiles {
+	return &generatedFiles{files: map[string]OutputFile{}}
+}
+
+// add records the file at path, generated from src.  The path in src is
+// ignored.
+func (g *generatedFiles) add(path string, src OutputFile) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	src.Path = filepath.ToSlash(filepath.Clean(path))
+	g.files[src.Path] = src
+}
+
+// has reports whether the file at the slash separated path was generated.
+func (g *generatedFiles) has(path string) bool {
+	if g == nil {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	_, ok := g.files[path]
+	return ok
+}
+
+// ReadManifest reads the manifest in the output directory dir.  If there is no
+// manifest, it returns an empty one.
+func ReadManifest(dir string) (*Manifest, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, ManifestFile))
+	if os.IsNotExist(err) {
+		return &Manifest{Version: ManifestVersion}, nil
+	}
+	if err != nil {
+		return nil, errors.WithMessage(err, "can't read manifest")
+	}
+	m := &Manifest{}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, errors.Wrapf(err, "can't parse manifest %s", filepath.Join(dir, ManifestFile))
+	}
+	if m.Version > ManifestVersion {
+		return nil, errors.Errorf("manifest %s has version %d, but this version of gnorm only reads versions up to %d", filepath.Join(dir, ManifestFile), m.Version, ManifestVersion)
+	}
+	return m, nil
+}
+
+// writeManifest writes the manifest of the files generated in dir.  Files in
+// the old manifest that weren't generated this time are kept as orphans, as
+// long as they still exist, so that gnorm clean can remove them.  If the run
+// was partial, and only generated some of the tables, files that the run
+// doesn't cover are kept as they were instead.  covered reports whether the
+// run would have generated a file, going by what the old manifest says it was
+// generated from, if its source still existed.
+func writeManifest(dir string, generated *generatedFiles, covered func(OutputFile) bool) error {
+	old, err := ReadManifest(dir)
+	if err != nil {
+		return err
+	}
+	m := &Manifest{Version: ManifestVersion}
+	for f, out := range generated.files {
+		m.Files = append(m.Files, f)
+		sum, err := hashFile(filepath.Join(dir, filepath.FromSlash(f)))
+		if err != nil {
+			return err
+		}
+		out.SHA256 = sum
+		m.Outputs = append(m.Outputs, out)
+	}
+	oldOutputs := make(map[string]OutputFile, len(old.Outputs))
+	for _, out := range old.Outputs {
+		oldOutputs[out.Path] = out
+	}
+	kept := func(f string) bool {
+		if generated.has(f) {
+			return false
+		}
+		_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(f)))
+		return err == nil
+	}
+	for _, f := range old.Files {
+		if !kept(f) {
+			continue
+		}
+		if out, ok := oldOutputs[f]; !covered(out) {
+			m.Files = append(m.Files, f)
+			if ok {
+				m.Outputs = append(m.Outputs, out)
+			}
+		} else {
+			m.Orphans = append(m.Orphans, f)
+		}
+	}
+	for _, f := range old.Orphans {
+		if kept(f) {
+			m.Orphans = append(m.Orphans, f)
+		}
+	}
+	sort.Strings(m.Files)
+	sort.Strings(m.Orphans)
+	sort.Slice(m.Outputs, func(i, j int) bool { return m.Outputs[i].Path < m.Outputs[j].Path })
+	return saveManifest(dir, m)
+}
+
+// coveredFiles returns a function that reports whether a run with cfg would
+// generate the file described by out, if what it was generated from still
+// exists.  All files are covered by full runs.  Runs limited to some schemas
+// or tables only cover the files for those tables, and for those schemas and
+// their enums if no tables were picked, and the static files.  Files that the
+// manifest doesn't describe, such as those listed by older versions of gnorm,
+// are only covered by full runs.
+func coveredFiles(cfg *Config) (func(OutputFile) bool, error) {
+	if !partial(cfg) {
+		return func(OutputFile) bool { return true }, nil
+	}
+	only, err := onlyFilter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return func(out OutputFile) bool {
+		switch out.Kind {
+		case OutputStatic:
+			return true
+		case OutputTable:
+			return only(out.Schema, out.Table)
+		case OutputSchema, OutputEnum:
+			return len(cfg.OnlyTables) == 0 && len(onlySchemas(cfg, []string{out.Schema})) > 0
+		}
+		return false
+	}, nil
+}
+
+// hashFile returns the hex encoded SHA-256 hash of the contents of the file at
+// path.
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", errors.WithMessage(err, "can't hash generated file")
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", errors.Wrapf(err, "can't hash generated file %s", path)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func saveManifest(dir string, m *Manifest) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return errors.WithMessage(err, "couldn't convert manifest to json")
+	}
+	b = append(b, '\n')
+	if err := ioutil.WriteFile(filepath.Join(dir, ManifestFile), b, 0600); err != nil {
+		return errors.WithMessage(err, "can't write manifest")
+	}
+	return nil
+}
+
+// Clean removes the files listed in the manifest in cfg.OutputDir, and then
+// the manifest itself.  If orphans is true, only the orphaned files are
+// removed, and the manifest is kept.  Directories left empty are removed too.
+// The path of each file removed is printed to env.Stdout.  If dryRun is true,
+// the files are only printed, and nothing is removed.
+func Clean(env environ.Values, cfg *Config, orphans, dryRun bool) error {
+	return clean(env, cfg, nil, orphans, dryRun)
+}
+
+// clean is Clean, recording the files removed in rep.
+func clean(env environ.Values, cfg *Config, rep *reporter, orphans, dryRun bool) error {
+	dir := outputDir(cfg)
+	m, err := ReadManifest(dir)
+	if err != nil {
+		return err
+	}
+	files := m.Orphans
+	if !orphans {
+		files = append(m.Files, m.Orphans...)
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, filepath.FromSlash(f))
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+		fmt.Fprintln(env.Stdout, path)
+		if dryRun {
+			continue
+		}
+		env.Logger().Infof("removing %v", path)
+		if err := os.Remove(path); err != nil {
+			return errors.WithMessage(err, "can't remove generated file")
+		}
+		rep.deleted(f)
+		removeEmptyDirs(dir, filepath.Dir(path))
+	}
+	if dryRun {
+		return nil
+	}
+	if orphans {
+		m.Orphans = nil
+		return saveManifest(dir, m)
+	}
+	if err := os.Remove(filepath.Join(dir, ManifestFile)); err != nil && !os.IsNotExist(err) {
+		return errors.WithMessage(err, "can't remove manifest")
+	}
+	return nil
+}
+
+// removeEmptyDirs removes path and its parents, up to but not including root,
+// for as long as they're empty.
+func removeEmptyDirs(root, path string) {
+	root = filepath.Clean(root)
+	for path = filepath.Clean(path); path != root && path != "."; path = filepath.Dir(path) {
+		// Remove fails on directories that aren't empty.
+		if os.Remove(path) != nil {
+			return
+		}
+	}
+}
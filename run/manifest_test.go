@@ -0,0 +1,371 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"text/template"
+
+	"gnorm.org/gnorm/database"
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run/data"
+)
+
+// droppedTableDriver returns the same schema as dummyDriver, without its last
+// table.
+type droppedTableDriver struct {
+	dummyDriver
+}
+
+func (d droppedTableDriver) Parse(ctx context.Context, log environ.Logger, conn string, schemaNames []string, filterTables func(schema, table string) bool) (*database.Info, error) {
+	info, err := d.dummyDriver.Parse(ctx, log, conn, schemaNames, filterTables)
+	if err != nil {
+		return nil, err
+	}
+	tables := info.Schemas[0].Tables
+	info.Schemas[0].Tables = tables[:len(tables)-1]
+	return info, nil
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestManifestAndClean(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gnorm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "out")
+	static := filepath.Join(dir, "static")
+	if err := os.MkdirAll(static, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(static, "README"), []byte("static"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	env := environ.Values{Stdout: &bytes.Buffer{}}
+	cfg := &Config{
+		ConfigData: data.ConfigData{
+			OutputDir:        out,
+			StaticDir:        static,
+			NoOverwriteGlobs: []string{"*.keep"},
+		},
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver:         dummyDriver{},
+		TablePaths: []OutputTarget{{
+			Filename: template.Must(template.New("").Parse(`{{.Schema}}/{{.Table}}.txt`)),
+			Contents: template.Must(template.New("").Parse(`{{.Table.Name}}`)),
+		}},
+		SchemaPaths: []OutputTarget{{
+			Filename: template.Must(template.New("").Parse(`{{.Schema}}.keep`)),
+			Contents: template.Must(template.New("").Parse(`{{.Schema.Name}}`)),
+		}},
+	}
+	if err := Generate(context.Background(), env, cfg); err != nil {
+		t.Fatal(err)
+	}
+	m, err := ReadManifest(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readme := OutputFile{Path: "README", Kind: OutputStatic, Template: filepath.Join(static, "README"), SHA256: sha256Hex("static")}
+	table := OutputFile{Path: "schema/table.txt", Kind: OutputTable, Schema: "schema", Table: "table", SHA256: sha256Hex("table")}
+	expected := &Manifest{
+		Version: ManifestVersion,
+		Files:   []string{"README", "schema/table.txt", "schema/tb2.txt"},
+		Outputs: []OutputFile{
+			readme,
+			table,
+			{Path: "schema/tb2.txt", Kind: OutputTable, Schema: "schema", Table: "tb2", SHA256: sha256Hex("tb2")},
+		},
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Fatalf("expected manifest %#v, but got %#v", expected, m)
+	}
+
+	// tb2 no longer exists, so its file is an orphan.
+	cfg.Driver = droppedTableDriver{}
+	if err := Generate(context.Background(), env, cfg); err != nil {
+		t.Fatal(err)
+	}
+	m, err = ReadManifest(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = &Manifest{
+		Version: ManifestVersion,
+		Files:   []string{"README", "schema/table.txt"},
+		Orphans: []string{"schema/tb2.txt"},
+		Outputs: []OutputFile{readme, table},
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Fatalf("expected manifest %#v, but got %#v", expected, m)
+	}
+
+	if err := Clean(env, cfg, true, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "schema", "tb2.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected orphaned file to be removed, but got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "schema", "table.txt")); err != nil {
+		t.Fatalf("expected generated file to be kept, but got %v", err)
+	}
+
+	if err := Clean(env, cfg, false, false); err != nil {
+		t.Fatal(err)
+	}
+	fis, err := ioutil.ReadDir(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var left []string
+	for _, fi := range fis {
+		left = append(left, fi.Name())
+	}
+	// files that aren't overwritten aren't removed.
+	if expected := []string{"schema.keep"}; !reflect.DeepEqual(left, expected) {
+		t.Fatalf("expected only %q to be left, but got %q", expected, left)
+	}
+}
+
+// filterDriver returns the same schema as dummyDriver, without the tables
+// filtered out, the way real drivers do.
+type filterDriver struct {
+	dummyDriver
+}
+
+func (d filterDriver) Parse(ctx context.Context, log environ.Logger, conn string, schemaNames []string, filterTables func(schema, table string) bool) (*database.Info, error) {
+	info, err := d.dummyDriver.Parse(ctx, log, conn, schemaNames, filterTables)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range info.Schemas {
+		tables := s.Tables[:0]
+		for _, t := range s.Tables {
+			if filterTables(s.Name, t.Name) {
+				tables = append(tables, t)
+			}
+		}
+		s.Tables = tables
+	}
+	return info, nil
+}
+
+func TestGenerateOnlyTables(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gnorm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	env := environ.Values{Stdout: &bytes.Buffer{}}
+	cfg := &Config{
+		ConfigData:     data.ConfigData{OutputDir: dir},
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver:         filterDriver{},
+		TablePaths: []OutputTarget{{
+			Filename: template.Must(template.New("").Parse(`{{.Table}}.txt`)),
+			Contents: template.Must(template.New("").Parse(`{{.Table.Name}} {{.Params.run}}`)),
+		}},
+		SchemaPaths: []OutputTarget{{
+			Filename: template.Must(template.New("").Parse(`{{.Schema}}.txt`)),
+			Contents: template.Must(template.New("").Parse(`{{len .Schema.Tables}} tables`)),
+		}},
+		Params: map[string]interface{}{"run": "first"},
+	}
+	if err := Generate(context.Background(), env, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.OnlyTables = []string{"schema.tb*"}
+	cfg.Params["run"] = "second"
+	if err := Generate(context.Background(), env, cfg); err != nil {
+		t.Fatal(err)
+	}
+	for file, expected := range map[string]string{
+		"table.txt":  "table first",
+		"tb2.txt":    "tb2 second",
+		"schema.txt": "2 tables",
+	} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != expected {
+			t.Errorf("expected %s to contain %q, but got %q", file, expected, b)
+		}
+	}
+	m, err := ReadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Manifest{
+		Version: ManifestVersion,
+		Files:   []string{"schema.txt", "table.txt", "tb2.txt"},
+		Outputs: []OutputFile{
+			{Path: "schema.txt", Kind: OutputSchema, Schema: "schema", SHA256: sha256Hex("2 tables")},
+			// kept from the first run.
+			{Path: "table.txt", Kind: OutputTable, Schema: "schema", Table: "table", SHA256: sha256Hex("table first")},
+			{Path: "tb2.txt", Kind: OutputTable, Schema: "schema", Table: "tb2", SHA256: sha256Hex("tb2 second")},
+		},
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Fatalf("expected manifest %#v, but got %#v", expected, m)
+	}
+}
+
+// fkDriver returns a posts table with a foreign key to a users table.
+type fkDriver struct {
+	dummyDriver
+}
+
+func (fkDriver) Parse(ctx context.Context, log environ.Logger, conn string, schemaNames []string, filterTables func(schema, table string) bool) (*database.Info, error) {
+	fk := &database.ForeignKey{
+		SchemaName:        "schema",
+		TableName:         "posts",
+		ColumnName:        "user_id",
+		Name:              "posts_user_id_fkey",
+		ForeignTableName:  "users",
+		ForeignColumnName: "id",
+	}
+	schema := &database.Schema{Name: "schema"}
+	for _, t := range []*database.Table{{
+		Name:    "users",
+		Columns: []*database.Column{{Name: "id", Type: "int", IsPrimaryKey: true}},
+	}, {
+		Name:    "posts",
+		Columns: []*database.Column{{Name: "user_id", Type: "int", IsForeignKey: true, ForeignKey: fk}},
+	}} {
+		if filterTables(schema.Name, t.Name) {
+			schema.Tables = append(schema.Tables, t)
+		}
+	}
+	return &database.Info{Schemas: []*database.Schema{schema}}, nil
+}
+
+func TestGenerateOnlyTablesForeignKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gnorm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	env := environ.Values{Stdout: &bytes.Buffer{}}
+	cfg := &Config{
+		ConfigData:     data.ConfigData{OutputDir: dir},
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver:         fkDriver{},
+		TablePaths: []OutputTarget{{
+			Filename: template.Must(template.New("").Parse(`{{.Table}}.txt`)),
+			Contents: template.Must(template.New("").Parse(`{{range .Table.ForeignKeys}}{{.Name}} -> {{.RefTable.Name}}{{end}}`)),
+		}},
+		OnlyTables: []string{"posts"},
+	}
+	if err := Generate(context.Background(), env, cfg); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "posts.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "posts_user_id_fkey -> users"; string(b) != expected {
+		t.Errorf("expected posts.txt to contain %q, but got %q", expected, b)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "users.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected users.txt not to be generated, but got %v", err)
+	}
+}
+
+func TestOutputSource(t *testing.T) {
+	schema := &data.Schema{DBName: "public"}
+	enum := &data.Enum{DBName: "status", Schema: schema}
+	target := OutputTarget{ContentsPath: "templates/enum.gotmpl"}
+	out := outputSource(enumFile{Database: "main", Table: "users", Data: data.EnumData{Enum: enum}}, target)
+	expected := OutputFile{Kind: OutputEnum, Template: "templates/enum.gotmpl", Database: "main", Schema: "public", Table: "users", Enum: "status"}
+	if out != expected {
+		t.Fatalf("expected %#v, but got %#v", expected, out)
+	}
+	out = outputSource(dbFile{}, OutputTarget{ContentsPath: "templates/db.gotmpl"})
+	expected = OutputFile{Kind: OutputDatabase, Template: "templates/db.gotmpl"}
+	if out != expected {
+		t.Fatalf("expected %#v, but got %#v", expected, out)
+	}
+}
+
+// droppedFilterDriver returns the same tables as filterDriver, without tb2.
+type droppedFilterDriver struct {
+	filterDriver
+}
+
+func (d droppedFilterDriver) Parse(ctx context.Context, log environ.Logger, conn string, schemaNames []string, filterTables func(schema, table string) bool) (*database.Info, error) {
+	info, err := d.filterDriver.Parse(ctx, log, conn, schemaNames, filterTables)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range info.Schemas {
+		tables := s.Tables[:0]
+		for _, t := range s.Tables {
+			if t.Name != "tb2" {
+				tables = append(tables, t)
+			}
+		}
+		s.Tables = tables
+	}
+	return info, nil
+}
+
+func TestGeneratePrune(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gnorm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	stdout := &bytes.Buffer{}
+	env := environ.Values{Stdout: stdout}
+	cfg := &Config{
+		ConfigData:     data.ConfigData{OutputDir: dir},
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver:         filterDriver{},
+		TablePaths: []OutputTarget{{
+			Filename: template.Must(template.New("").Parse(`{{.Table}}.txt`)),
+			Contents: template.Must(template.New("").Parse(`{{.Table.Name}}`)),
+		}},
+		SchemaPaths: []OutputTarget{{
+			Filename: template.Must(template.New("").Parse(`{{.Schema}}.txt`)),
+			Contents: template.Must(template.New("").Parse(`{{len .Schema.Tables}} tables`)),
+		}},
+	}
+	if err := Generate(context.Background(), env, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// tb2 was dropped, and only the tables it matched are generated, so its
+	// file is an orphan, but the other files are kept.
+	cfg.Driver = droppedFilterDriver{}
+	cfg.OnlyTables = []string{"tb*"}
+	cfg.Prune = true
+	if err := Generate(context.Background(), env, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if s, expected := stdout.String(), filepath.Join(dir, "tb2.txt")+"\n"; s != expected {
+		t.Fatalf("expected output %q, but got %q", expected, s)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "tb2.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected tb2.txt to be pruned, but got %v", err)
+	}
+	m, err := ReadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"schema.txt", "table.txt"}; !reflect.DeepEqual(m.Files, expected) || len(m.Orphans) > 0 {
+		t.Fatalf("expected files %q and no orphans, but got %q and %q", expected, m.Files, m.Orphans)
+	}
+}
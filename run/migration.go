@@ -0,0 +1,396 @@
+package run
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"gnorm.org/gnorm/database"
+)
+
+// MigrationLayout defines the layouts of migration files that WriteMigration
+// can write.
+type MigrationLayout int
+
+const (
+	// MigrationGoose writes one file, version_name.sql, with the up and down
+	// migrations in the sections that goose expects.
+	MigrationGoose MigrationLayout = iota
+	// MigrationGolangMigrate writes version_name.up.sql and
+	// version_name.down.sql, as golang-migrate expects.
+	MigrationGolangMigrate
+)
+
+// migrationStep is a change to the schema, and the statement that undoes it.
+type migrationStep struct {
+	up, down string
+}
+
+// MigrationSQL returns the statements that change the schema in old into the
+// schema in new (up), and the statements that change it back (down), in the
+// given dialect.  Tables, columns, indexes, and enums that were added or
+// removed get the statements that add or remove them, as do the foreign keys
+// of tables that were added or removed.  Changes that can't be
+// made the same way everywhere, such as to a column's type, and views, whose
+// definitions gnorm doesn't read, get a TODO comment describing the change, so
+// the migration is a skeleton to start from rather than one to run as is.  It
+// returns nil if the schemas are the same.
+func MigrationSQL(old, new *database.Info, dialect DDLDialect) (up, down []string) {
+	var steps []migrationStep
+	oldSchemas := map[string]*database.Schema{}
+	for _, s := range old.Schemas {
+		oldSchemas[schemaName(s)] = s
+	}
+	newSchemas := map[string]*database.Schema{}
+	for _, s := range new.Schemas {
+		newSchemas[schemaName(s)] = s
+	}
+	for _, s := range new.Schemas {
+		o := oldSchemas[schemaName(s)]
+		if o == nil {
+			schema := ddlIdent(dialect, s.Name)
+			steps = append(steps, migrationStep{"CREATE SCHEMA " + schema + ";", "DROP SCHEMA " + schema + ";"})
+			o = &database.Schema{Name: s.Name}
+		}
+		steps = append(steps, migrateSchema(o, s, dialect)...)
+	}
+	for _, s := range old.Schemas {
+		if newSchemas[schemaName(s)] == nil {
+			steps = append(steps, migrateSchema(s, &database.Schema{Name: s.Name}, dialect)...)
+			schema := ddlIdent(dialect, s.Name)
+			steps = append(steps, migrationStep{"DROP SCHEMA " + schema + ";", "CREATE SCHEMA " + schema + ";"})
+		}
+	}
+	for _, step := range steps {
+		up = append(up, step.up)
+	}
+	// changes are undone in the reverse order they were made.
+	for x := len(steps) - 1; x >= 0; x-- {
+		down = append(down, steps[x].down)
+	}
+	return up, down
+}
+
+// migrateSchema returns the steps that change the schema old into new.  Added
+// enums are created before the tables that might use them, and removed enums
+// are dropped after the tables that used them.  The foreign keys of added
+// tables are added once all the tables are created, and those of removed
+// tables are dropped before any of the tables are, so that tables may
+// reference each other, as in writeDDL.
+func migrateSchema(old, new *database.Schema, dialect DDLDialect) []migrationStep {
+	var steps []migrationStep
+	oldEnums := map[string]*database.Enum{}
+	for _, e := range old.Enums {
+		oldEnums[enumName(e)] = e
+	}
+	newEnums := map[string]*database.Enum{}
+	for _, e := range new.Enums {
+		newEnums[enumName(e)] = e
+	}
+	for _, e := range new.Enums {
+		if e.Table != "" {
+			// mysql enums are part of their column.
+			continue
+		}
+		if o := oldEnums[enumName(e)]; o != nil {
+			if enumValues(o) != enumValues(e) {
+				steps = append(steps, migrationTODO(diffEnums(schemaName(new), []*database.Enum{o}, []*database.Enum{e})))
+			}
+			continue
+		}
+		if dialect != DDLMySQL {
+			steps = append(steps, migrationStep{createEnum(new, e, dialect), "DROP TYPE " + ddlIdent(dialect, new.Name) + "." + ddlIdent(dialect, e.Name) + ";"})
+		}
+	}
+
+	oldTables := map[string]*database.Table{}
+	for _, t := range old.Tables {
+		oldTables[t.Name] = t
+	}
+	newTables := map[string]*database.Table{}
+	for _, t := range new.Tables {
+		newTables[t.Name] = t
+	}
+	var added, dropped []*database.Table
+	for _, t := range new.Tables {
+		o := oldTables[t.Name]
+		switch {
+		case o == nil:
+			steps = append(steps, migrationStep{createTable(new, t, dialect), dropTable(new, t, dialect)})
+			added = append(added, t)
+		case o.IsView != t.IsView:
+			steps = append(steps, migrationTODO(diffTables(schemaName(new), []*database.Table{o}, []*database.Table{t})))
+		case !t.IsView:
+			steps = append(steps, migrateTable(old, new, o, t, dialect)...)
+		}
+	}
+	for _, t := range added {
+		for _, fk := range tableForeignKeys(t) {
+			steps = append(steps, migrationStep{addForeignKey(new, t, fk, dialect), dropForeignKey(new, t, fk, dialect)})
+		}
+	}
+	for _, t := range old.Tables {
+		if newTables[t.Name] == nil {
+			dropped = append(dropped, t)
+			for _, fk := range tableForeignKeys(t) {
+				steps = append(steps, migrationStep{dropForeignKey(old, t, fk, dialect), addForeignKey(old, t, fk, dialect)})
+			}
+		}
+	}
+	for _, t := range dropped {
+		steps = append(steps, migrationStep{dropTable(old, t, dialect), createTable(old, t, dialect)})
+	}
+
+	for _, e := range old.Enums {
+		if e.Table == "" && newEnums[enumName(e)] == nil && dialect != DDLMySQL {
+			steps = append(steps, migrationStep{"DROP TYPE " + ddlIdent(dialect, old.Name) + "." + ddlIdent(dialect, e.Name) + ";", createEnum(old, e, dialect)})
+		}
+	}
+	return steps
+}
+
+// migrateTable returns the steps that change the table old into new.
+func migrateTable(oldSchema, newSchema *database.Schema, old, new *database.Table, dialect DDLDialect) []migrationStep {
+	var steps []migrationStep
+	table := ddlIdent(dialect, newSchema.Name) + "." + ddlIdent(dialect, new.Name)
+	oldCols := map[string]*database.Column{}
+	for _, c := range old.Columns {
+		oldCols[c.Name] = c
+	}
+	newCols := map[string]*database.Column{}
+	for _, c := range new.Columns {
+		newCols[c.Name] = c
+	}
+	for _, c := range new.Columns {
+		o := oldCols[c.Name]
+		if o == nil {
+			steps = append(steps, migrationStep{
+				fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", table, columnDefinition(newSchema, new, c, dialect)),
+				fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", table, ddlIdent(dialect, c.Name)),
+			})
+			continue
+		}
+		if d := diffColumns(schemaName(newSchema)+"."+new.Name, []*database.Column{o}, []*database.Column{c}); len(d) > 0 {
+			steps = append(steps, migrationTODO(d))
+		}
+	}
+	for _, c := range old.Columns {
+		if newCols[c.Name] == nil {
+			steps = append(steps, migrationStep{
+				fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", table, ddlIdent(dialect, c.Name)),
+				fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", table, columnDefinition(oldSchema, old, c, dialect)),
+			})
+		}
+	}
+
+	oldIdx := map[string]*database.Index{}
+	for _, i := range old.Indexes {
+		oldIdx[i.Name] = i
+	}
+	newIdx := map[string]*database.Index{}
+	for _, i := range new.Indexes {
+		newIdx[i.Name] = i
+	}
+	for _, i := range old.Indexes {
+		n := newIdx[i.Name]
+		if n == nil || n.IsUnique != i.IsUnique || indexColumns(n) != indexColumns(i) {
+			steps = append(steps, migrationStep{dropIndex(newSchema, table, i, dialect), createIndex(table, i, dialect)})
+		}
+	}
+	for _, i := range new.Indexes {
+		o := oldIdx[i.Name]
+		if o == nil || o.IsUnique != i.IsUnique || indexColumns(o) != indexColumns(i) {
+			steps = append(steps, migrationStep{createIndex(table, i, dialect), dropIndex(newSchema, table, i, dialect)})
+		}
+	}
+	return steps
+}
+
+// migrationTODO returns a step that describes changes, as reported by
+// DiffSchemas, that have to be written by hand.
+func migrationTODO(diffs []string) migrationStep {
+	var up, down []string
+	for _, d := range diffs {
+		up = append(up, "-- TODO: "+d)
+		down = append(down, "-- TODO: undo "+d)
+	}
+	return migrationStep{strings.Join(up, "\n"), strings.Join(down, "\n")}
+}
+
+func createEnum(s *database.Schema, e *database.Enum, dialect DDLDialect) string {
+	vals := make([]string, len(e.Values))
+	for x, v := range e.Values {
+		vals[x] = ddlString(v.Name)
+	}
+	return fmt.Sprintf("CREATE TYPE %s.%s AS ENUM (%s);", ddlIdent(dialect, s.Name), ddlIdent(dialect, e.Name), strings.Join(vals, ", "))
+}
+
+func createTable(s *database.Schema, t *database.Table, dialect DDLDialect) string {
+	name := ddlIdent(dialect, s.Name) + "." + ddlIdent(dialect, t.Name)
+	if t.IsView {
+		return "-- TODO: create view " + name
+	}
+	var lines, pks []string
+	for _, c := range t.Columns {
+		lines = append(lines, "  "+columnDefinition(s, t, c, dialect))
+		if c.IsPrimaryKey {
+			pks = append(pks, ddlIdent(dialect, c.Name))
+		}
+	}
+	if len(pks) > 0 {
+		lines = append(lines, "  PRIMARY KEY ("+strings.Join(pks, ", ")+")")
+	}
+	stmts := []string{"CREATE TABLE " + name + " (\n" + strings.Join(lines, ",\n") + "\n);"}
+	for _, i := range t.Indexes {
+		if !isPrimaryKeyIndex(t, i) {
+			stmts = append(stmts, createIndex(name, i, dialect))
+		}
+	}
+	return strings.Join(stmts, "\n")
+}
+
+func dropTable(s *database.Schema, t *database.Table, dialect DDLDialect) string {
+	kind := "TABLE"
+	if t.IsView {
+		kind = "VIEW"
+	}
+	return fmt.Sprintf("DROP %s %s.%s;", kind, ddlIdent(dialect, s.Name), ddlIdent(dialect, t.Name))
+}
+
+// migrationFK is a foreign key constraint, with all of its columns.
+type migrationFK struct {
+	name, refTable string
+	cols, refs     []string
+}
+
+// tableForeignKeys returns the table's foreign keys, in the order of their
+// first columns.  Each column of a composite key has the key, so they're
+// grouped by the key's name.
+func tableForeignKeys(t *database.Table) []*migrationFK {
+	var fks []*migrationFK
+	byName := map[string]*migrationFK{}
+	for _, c := range t.Columns {
+		fk := c.ForeignKey
+		if fk == nil {
+			continue
+		}
+		m := byName[fk.Name]
+		if m == nil {
+			m = &migrationFK{name: fk.Name, refTable: fk.ForeignTableName}
+			byName[fk.Name] = m
+			fks = append(fks, m)
+		}
+		m.cols = append(m.cols, c.Name)
+		m.refs = append(m.refs, fk.ForeignColumnName)
+	}
+	return fks
+}
+
+func addForeignKey(s *database.Schema, t *database.Table, fk *migrationFK, dialect DDLDialect) string {
+	cols := make([]string, len(fk.cols))
+	refs := make([]string, len(fk.refs))
+	for x := range fk.cols {
+		cols[x] = ddlIdent(dialect, fk.cols[x])
+		refs[x] = ddlIdent(dialect, fk.refs[x])
+	}
+	return fmt.Sprintf("ALTER TABLE %s.%s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s.%s (%s);",
+		ddlIdent(dialect, s.Name), ddlIdent(dialect, t.Name), ddlIdent(dialect, fk.name), strings.Join(cols, ", "),
+		ddlIdent(dialect, s.Name), ddlIdent(dialect, fk.refTable), strings.Join(refs, ", "))
+}
+
+// dropForeignKey returns the statement that drops the foreign key, which
+// mysql calls a FOREIGN KEY rather than a CONSTRAINT.
+func dropForeignKey(s *database.Schema, t *database.Table, fk *migrationFK, dialect DDLDialect) string {
+	kind := "CONSTRAINT"
+	if dialect == DDLMySQL {
+		kind = "FOREIGN KEY"
+	}
+	return fmt.Sprintf("ALTER TABLE %s.%s DROP %s %s;", ddlIdent(dialect, s.Name), ddlIdent(dialect, t.Name), kind, ddlIdent(dialect, fk.name))
+}
+
+// columnDefinition returns the column as it's written in a CREATE TABLE or
+// ADD COLUMN.
+func columnDefinition(s *database.Schema, t *database.Table, c *database.Column, dialect DDLDialect) string {
+	typ := columnType(c)
+	for _, e := range s.Enums {
+		if e.Table == t.Name && e.Name == c.Name {
+			// mysql enums are written with their values.
+			vals := make([]string, len(e.Values))
+			for x, v := range e.Values {
+				vals[x] = ddlString(v.Name)
+			}
+			typ = "ENUM(" + strings.Join(vals, ", ") + ")"
+		}
+	}
+	def := ddlIdent(dialect, c.Name) + " " + typ
+	if !c.Nullable {
+		def += " NOT NULL"
+	}
+	if c.Default != "" {
+		def += " DEFAULT " + c.Default
+	}
+	return def
+}
+
+// isPrimaryKeyIndex reports whether the index is the one that backs the
+// table's primary key, which the PRIMARY KEY creates.
+func isPrimaryKeyIndex(t *database.Table, i *database.Index) bool {
+	if i.Name == "PRIMARY" {
+		return true
+	}
+	var pks []string
+	for _, c := range t.Columns {
+		if c.IsPrimaryKey {
+			pks = append(pks, c.Name)
+		}
+	}
+	return i.IsUnique && len(pks) > 0 && indexColumns(i) == strings.Join(pks, ", ")
+}
+
+func createIndex(table string, i *database.Index, dialect DDLDialect) string {
+	unique := ""
+	if i.IsUnique {
+		unique = "UNIQUE "
+	}
+	cols := make([]string, len(i.Columns))
+	for x, c := range i.Columns {
+		cols[x] = ddlIdent(dialect, c.Name)
+	}
+	return fmt.Sprintf("CREATE %sINDEX %s ON %s (%s);", unique, ddlIdent(dialect, i.Name), table, strings.Join(cols, ", "))
+}
+
+// dropIndex returns the statement that drops the index.  Indexes belong to
+// their table in mysql, and to their schema elsewhere.
+func dropIndex(s *database.Schema, table string, i *database.Index, dialect DDLDialect) string {
+	if dialect == DDLMySQL {
+		return fmt.Sprintf("DROP INDEX %s ON %s;", ddlIdent(dialect, i.Name), table)
+	}
+	return "DROP INDEX " + ddlIdent(dialect, s.Name) + "." + ddlIdent(dialect, i.Name) + ";"
+}
+
+// WriteMigration writes the up and down statements to sink as a migration in
+// the given layout, named with the version and name, such as
+// 20240102150405_add_users.sql for goose.
+func WriteMigration(sink OutputSink, layout MigrationLayout, version, name string, up, down []string) error {
+	base := version + "_" + name
+	switch layout {
+	case MigrationGoose:
+		content := "-- +goose Up\n" + migrationBody(up) + "\n-- +goose Down\n" + migrationBody(down)
+		return sink.WriteFile(base+".sql", []byte(content), 0644)
+	case MigrationGolangMigrate:
+		if err := sink.WriteFile(base+".up.sql", []byte(migrationBody(up)), 0644); err != nil {
+			return err
+		}
+		return sink.WriteFile(base+".down.sql", []byte(migrationBody(down)), 0644)
+	}
+	return errors.Errorf("Unsupported migration layout: %v", layout)
+}
+
+// migrationBody returns the statements, separated by blank lines.
+func migrationBody(stmts []string) string {
+	if len(stmts) == 0 {
+		return ""
+	}
+	return strings.Join(stmts, "\n\n") + "\n"
+}
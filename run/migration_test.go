@@ -0,0 +1,173 @@
+package run
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/andreyvit/diff"
+	"github.com/google/go-cmp/cmp"
+
+	"gnorm.org/gnorm/database"
+)
+
+func TestMigration(t *testing.T) {
+	if up, down := MigrationSQL(diffInfo(), diffInfo(), DDLPostgres); up != nil || down != nil {
+		t.Fatalf("expected no statements, but got %q and %q", up, down)
+	}
+
+	old := diffInfo()
+	new := diffInfo()
+	users := new.Schemas[0].Tables[0]
+	users.Columns[1].Length = 32
+	users.Columns = append(users.Columns, &database.Column{Name: "email", Type: "text", HasDefault: true, Default: "''::text"})
+	users.Indexes[0].IsUnique = true
+	posts := new.Schemas[0].Tables[1]
+	posts.Columns = posts.Columns[:1]
+	new.Schemas[0].Tables = append(new.Schemas[0].Tables, &database.Table{
+		Name: "comments",
+		Columns: []*database.Column{
+			{Name: "id", Type: "integer", IsPrimaryKey: true},
+			{Name: "post_id", Type: "integer", ForeignKey: &database.ForeignKey{Name: "comments_post", ForeignTableName: "posts", ForeignColumnName: "id"}},
+		},
+	})
+	new.Schemas[0].Enums = nil
+
+	up, down := MigrationSQL(old, new, DDLPostgres)
+	sink := &MemorySink{}
+	if err := WriteMigration(sink, MigrationGoose, "20240102150405", "changes", up, down); err != nil {
+		t.Fatal(err)
+	}
+	expected := `
+-- +goose Up
+-- TODO: ~ column public.users.name: type varchar(16) -> varchar(32)
+
+ALTER TABLE "public"."users" ADD COLUMN "email" text NOT NULL DEFAULT ''::text;
+
+DROP INDEX "public"."users_name";
+
+CREATE UNIQUE INDEX "users_name" ON "public"."users" ("name");
+
+ALTER TABLE "public"."posts" DROP COLUMN "tags";
+
+CREATE TABLE "public"."comments" (
+  "id" integer NOT NULL,
+  "post_id" integer NOT NULL,
+  PRIMARY KEY ("id")
+);
+
+ALTER TABLE "public"."comments" ADD CONSTRAINT "comments_post" FOREIGN KEY ("post_id") REFERENCES "public"."posts" ("id");
+
+DROP TYPE "public"."color";
+
+-- +goose Down
+CREATE TYPE "public"."color" AS ENUM ('red', 'blue');
+
+ALTER TABLE "public"."comments" DROP CONSTRAINT "comments_post";
+
+DROP TABLE "public"."comments";
+
+ALTER TABLE "public"."posts" ADD COLUMN "tags" text[] NOT NULL;
+
+DROP INDEX "public"."users_name";
+
+CREATE INDEX "users_name" ON "public"."users" ("name");
+
+ALTER TABLE "public"."users" DROP COLUMN "email";
+
+-- TODO: undo ~ column public.users.name: type varchar(16) -> varchar(32)
+`[1:]
+	if got := string(sink.Files()["20240102150405_changes.sql"]); got != expected {
+		t.Fatalf("unexpected migration:\n%s", diff.LineDiff(expected, got))
+	}
+
+	sink = &MemorySink{}
+	if err := WriteMigration(sink, MigrationGolangMigrate, "1", "changes", up, down); err != nil {
+		t.Fatal(err)
+	}
+	files := sink.Files()
+	if len(files) != 2 || files["1_changes.up.sql"] == nil || files["1_changes.down.sql"] == nil {
+		t.Fatalf("expected 1_changes.up.sql and 1_changes.down.sql, but got %v", files)
+	}
+}
+
+func TestMigrationForeignKeys(t *testing.T) {
+	// authors and books reference each other, and editions has a composite
+	// key to books.
+	tables := []*database.Table{{
+		Name: "authors",
+		Columns: []*database.Column{
+			{Name: "id", Type: "integer", IsPrimaryKey: true},
+			{Name: "latest_book", Type: "integer", Nullable: true, ForeignKey: &database.ForeignKey{Name: "authors_latest", ForeignTableName: "books", ForeignColumnName: "id"}},
+		},
+	}, {
+		Name: "books",
+		Columns: []*database.Column{
+			{Name: "id", Type: "integer", IsPrimaryKey: true},
+			{Name: "lang", Type: "text", IsPrimaryKey: true},
+			{Name: "author_id", Type: "integer", ForeignKey: &database.ForeignKey{Name: "books_author", ForeignTableName: "authors", ForeignColumnName: "id"}},
+		},
+	}, {
+		Name: "editions",
+		Columns: []*database.Column{
+			{Name: "book_id", Type: "integer", ForeignKey: &database.ForeignKey{Name: "editions_book", ForeignTableName: "books", ForeignColumnName: "id"}},
+			{Name: "book_lang", Type: "text", ForeignKey: &database.ForeignKey{Name: "editions_book", ForeignTableName: "books", ForeignColumnName: "lang"}},
+		},
+	}}
+	old := &database.Info{Schemas: []*database.Schema{{Name: "public"}}}
+	new := &database.Info{Schemas: []*database.Schema{{Name: "public", Tables: tables}}}
+
+	up, down := MigrationSQL(old, new, DDLMySQL)
+	sink := &MemorySink{}
+	if err := WriteMigration(sink, MigrationGoose, "1", "books", up, down); err != nil {
+		t.Fatal(err)
+	}
+	expected := "" +
+		"-- +goose Up\n" +
+		"CREATE TABLE `public`.`authors` (\n" +
+		"  `id` integer NOT NULL,\n" +
+		"  `latest_book` integer,\n" +
+		"  PRIMARY KEY (`id`)\n" +
+		");\n\n" +
+		"CREATE TABLE `public`.`books` (\n" +
+		"  `id` integer NOT NULL,\n" +
+		"  `lang` text NOT NULL,\n" +
+		"  `author_id` integer NOT NULL,\n" +
+		"  PRIMARY KEY (`id`, `lang`)\n" +
+		");\n\n" +
+		"CREATE TABLE `public`.`editions` (\n" +
+		"  `book_id` integer NOT NULL,\n" +
+		"  `book_lang` text NOT NULL\n" +
+		");\n\n" +
+		"ALTER TABLE `public`.`authors` ADD CONSTRAINT `authors_latest` FOREIGN KEY (`latest_book`) REFERENCES `public`.`books` (`id`);\n\n" +
+		"ALTER TABLE `public`.`books` ADD CONSTRAINT `books_author` FOREIGN KEY (`author_id`) REFERENCES `public`.`authors` (`id`);\n\n" +
+		"ALTER TABLE `public`.`editions` ADD CONSTRAINT `editions_book` FOREIGN KEY (`book_id`, `book_lang`) REFERENCES `public`.`books` (`id`, `lang`);\n" +
+		"\n-- +goose Down\n" +
+		"ALTER TABLE `public`.`editions` DROP FOREIGN KEY `editions_book`;\n\n" +
+		"ALTER TABLE `public`.`books` DROP FOREIGN KEY `books_author`;\n\n" +
+		"ALTER TABLE `public`.`authors` DROP FOREIGN KEY `authors_latest`;\n\n" +
+		"DROP TABLE `public`.`editions`;\n\n" +
+		"DROP TABLE `public`.`books`;\n\n" +
+		"DROP TABLE `public`.`authors`;\n"
+	if got := string(sink.Files()["1_books.sql"]); got != expected {
+		t.Fatalf("unexpected migration:\n%s", diff.LineDiff(expected, got))
+	}
+
+	// dropping the tables drops the keys first, and undoing it adds them after
+	// the tables are created again.
+	up, down = MigrationSQL(new, old, DDLPostgres)
+	expectedUp := []string{
+		`ALTER TABLE "public"."authors" DROP CONSTRAINT "authors_latest";`,
+		`ALTER TABLE "public"."books" DROP CONSTRAINT "books_author";`,
+		`ALTER TABLE "public"."editions" DROP CONSTRAINT "editions_book";`,
+		`DROP TABLE "public"."authors";`,
+		`DROP TABLE "public"."books";`,
+		`DROP TABLE "public"."editions";`,
+	}
+	if diff := cmp.Diff(expectedUp, up); diff != "" {
+		t.Errorf("unexpected up statements (-want +got):\n%s", diff)
+	}
+	if len(down) != 6 || !strings.HasPrefix(down[0], `CREATE TABLE "public"."editions"`) ||
+		down[5] != `ALTER TABLE "public"."authors" ADD CONSTRAINT "authors_latest" FOREIGN KEY ("latest_book") REFERENCES "public"."books" ("id");` {
+		t.Errorf("expected the tables to be created before their keys are added, but got %q", down)
+	}
+}
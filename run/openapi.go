@@ -0,0 +1,213 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run/data"
+)
+
+// OpenAPIFormat defines the formats that OpenAPI can write the document in.
+type OpenAPIFormat int
+
+const (
+	// OpenAPIJSON writes the document as JSON.
+	OpenAPIJSON OpenAPIFormat = iota
+	// OpenAPIYAML writes the document as YAML.
+	OpenAPIYAML
+)
+
+// OpenAPI reads the database the same way Generate does, with the same
+// filters, and writes an OpenAPI 3 document to env.Stdout with a component
+// schema for each table and enum.  Columns become properties named by their
+// DBName, with their type and format mapped from their database type, and
+// columns that aren't nullable are required.
+func OpenAPI(ctx context.Context, env environ.Values, cfg *Config, format OpenAPIFormat) error {
+	info, err := parseDB(ctx, env, cfg)
+	if err != nil {
+		return err
+	}
+	db, err := makeData(ctx, env, info, cfg)
+	if err != nil {
+		return err
+	}
+	doc := openAPIDocument(db)
+	var b []byte
+	switch format {
+	case OpenAPIJSON:
+		buf := &bytes.Buffer{}
+		enc := json.NewEncoder(buf)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(doc)
+		b = buf.Bytes()
+	case OpenAPIYAML:
+		b, err = yaml.Marshal(doc)
+	default:
+		return errors.Errorf("Unsupported format: %v", format)
+	}
+	if err != nil {
+		return errors.WithMessage(err, "can't encode OpenAPI document")
+	}
+	_, err = env.Stdout.Write(b)
+	return err
+}
+
+// openAPIDoc is an OpenAPI 3.0 document that only has components.
+type openAPIDoc struct {
+	OpenAPI    string                 `json:"openapi" yaml:"openapi"`
+	Info       openAPIInfo            `json:"info" yaml:"info"`
+	Paths      map[string]interface{} `json:"paths" yaml:"paths"`
+	Components openAPIComponents      `json:"components" yaml:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title" yaml:"title"`
+	Version string `json:"version" yaml:"version"`
+}
+
+type openAPIComponents struct {
+	Schemas map[string]*openAPISchema `json:"schemas" yaml:"schemas"`
+}
+
+// openAPISchema is the subset of the OpenAPI schema object that describes
+// tables, columns, and enums.
+type openAPISchema struct {
+	Ref         string                    `json:"$ref,omitempty" yaml:"$ref,omitempty"`
+	AllOf       []*openAPISchema          `json:"allOf,omitempty" yaml:"allOf,omitempty"`
+	Type        string                    `json:"type,omitempty" yaml:"type,omitempty"`
+	Format      string                    `json:"format,omitempty" yaml:"format,omitempty"`
+	Description string                    `json:"description,omitempty" yaml:"description,omitempty"`
+	Nullable    bool                      `json:"nullable,omitempty" yaml:"nullable,omitempty"`
+	MaxLength   int                       `json:"maxLength,omitempty" yaml:"maxLength,omitempty"`
+	Enum        []string                  `json:"enum,omitempty" yaml:"enum,omitempty"`
+	Items       *openAPISchema            `json:"items,omitempty" yaml:"items,omitempty"`
+	Properties  map[string]*openAPISchema `json:"properties,omitempty" yaml:"properties,omitempty"`
+	Required    []string                  `json:"required,omitempty" yaml:"required,omitempty"`
+}
+
+// openAPIDocument returns the document for db.  Components are named by the
+// Name of their table or enum, prefixed by the Name of their schema when
+// there's more than one schema.  Enums that belong to a table, as they do in
+// mysql, are also prefixed by the Name of their table.
+func openAPIDocument(db *data.DBData) *openAPIDoc {
+	doc := &openAPIDoc{
+		OpenAPI:    "3.0.3",
+		Paths:      map[string]interface{}{},
+		Components: openAPIComponents{Schemas: map[string]*openAPISchema{}},
+	}
+	var titles []string
+	for _, s := range db.Schemas {
+		titles = append(titles, s.DBName)
+	}
+	doc.Info = openAPIInfo{Title: strings.Join(titles, ", "), Version: "1.0.0"}
+	prefix := func(s *data.Schema) string {
+		if len(db.Schemas) > 1 {
+			return s.Name
+		}
+		return ""
+	}
+	enumName := func(e *data.Enum) string {
+		name := prefix(e.Schema) + e.Name
+		if e.Table != nil && e.Table.DBName != "" {
+			name = prefix(e.Schema) + e.Table.Name + e.Name
+		}
+		return name
+	}
+	for _, s := range db.Schemas {
+		for _, e := range s.Enums {
+			vals := make([]string, len(e.Values))
+			for x, v := range e.Values {
+				vals[x] = v.DBName
+			}
+			doc.Components.Schemas[enumName(e)] = &openAPISchema{Type: "string", Enum: vals}
+		}
+		for _, t := range s.Tables {
+			obj := &openAPISchema{
+				Type:        "object",
+				Description: t.Comment,
+				Properties:  map[string]*openAPISchema{},
+			}
+			for _, c := range t.Columns {
+				var prop *openAPISchema
+				if e := columnEnum(c); e != nil {
+					prop = &openAPISchema{Ref: "#/components/schemas/" + enumName(e)}
+				} else {
+					prop = openAPIType(c)
+				}
+				if c.IsArray {
+					prop = &openAPISchema{Type: "array", Items: prop}
+				}
+				if c.Nullable || c.Comment != "" {
+					if prop.Ref != "" {
+						// properties next to a $ref are ignored, so wrap it.
+						prop = &openAPISchema{AllOf: []*openAPISchema{prop}}
+					}
+					prop.Nullable = c.Nullable
+					prop.Description = c.Comment
+				}
+				obj.Properties[c.DBName] = prop
+				if !c.Nullable {
+					obj.Required = append(obj.Required, c.DBName)
+				}
+			}
+			doc.Components.Schemas[prefix(s)+t.Name] = obj
+		}
+	}
+	return doc
+}
+
+// columnEnum returns the enum that is the type of the column, or nil if its
+// type isn't an enum.
+func columnEnum(c *data.Column) *data.Enum {
+	for _, e := range c.Table.Schema.Enums {
+		if e.Table != nil && e.Table.DBName != "" {
+			// mysql enums are named after the column that uses them.
+			if c.DBType == "enum" && e.Table.DBName == c.Table.DBName && e.DBName == c.DBName {
+				return e
+			}
+			continue
+		}
+		if c.UserDefined && e.DBName == c.DBType {
+			return e
+		}
+	}
+	return nil
+}
+
+// openAPIType returns the schema for values of the column's database type, not
+// counting arrays.  Types gnorm doesn't know get an empty schema, which allows
+// any value.
+func openAPIType(c *data.Column) *openAPISchema {
+	switch strings.ToLower(c.DBType) {
+	case "smallint", "int2", "smallserial", "serial2", "integer", "int", "int4", "serial", "serial4", "mediumint", "tinyint", "year":
+		return &openAPISchema{Type: "integer", Format: "int32"}
+	case "bigint", "int8", "bigserial", "serial8":
+		return &openAPISchema{Type: "integer", Format: "int64"}
+	case "real", "float4", "float":
+		return &openAPISchema{Type: "number", Format: "float"}
+	case "double precision", "float8", "double":
+		return &openAPISchema{Type: "number", Format: "double"}
+	case "numeric", "decimal", "money":
+		return &openAPISchema{Type: "number"}
+	case "boolean", "bool":
+		return &openAPISchema{Type: "boolean"}
+	case "uuid":
+		return &openAPISchema{Type: "string", Format: "uuid"}
+	case "date":
+		return &openAPISchema{Type: "string", Format: "date"}
+	case "timestamp", "timestamp without time zone", "timestamp with time zone", "timestamptz", "datetime":
+		return &openAPISchema{Type: "string", Format: "date-time"}
+	case "bytea", "blob", "tinyblob", "mediumblob", "longblob", "binary", "varbinary":
+		return &openAPISchema{Type: "string", Format: "byte"}
+	case "text", "tinytext", "mediumtext", "longtext", "citext", "name", "varchar", "character varying", "char", "character", "bpchar",
+		"time", "time without time zone", "time with time zone", "timetz", "interval", "inet", "cidr", "macaddr", "xml":
+		return &openAPISchema{Type: "string", MaxLength: c.Length}
+	}
+	return &openAPISchema{}
+}
@@ -0,0 +1,175 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"text/template"
+
+	"github.com/andreyvit/diff"
+
+	"gnorm.org/gnorm/database"
+	"gnorm.org/gnorm/environ"
+)
+
+func TestOpenAPI(t *testing.T) {
+	cfg := &Config{
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver:         dummyDriver{},
+	}
+	stdout := &bytes.Buffer{}
+	env := environ.Values{Stdout: stdout}
+	if err := OpenAPI(context.Background(), env, cfg, OpenAPIYAML); err != nil {
+		t.Fatal(err)
+	}
+	expected := `
+openapi: 3.0.3
+info:
+  title: schema
+  version: 1.0.0
+paths: {}
+components:
+  schemas:
+    enum:
+      type: string
+      enum:
+      - enumvalue
+    table:
+      type: object
+      description: a table
+      properties:
+        col1:
+          type: integer
+          format: int32
+          description: first column
+        col2:
+          nullable: true
+        col3: {}
+        col4:
+          nullable: true
+      required:
+      - col1
+      - col3
+    tb2:
+      type: object
+      properties:
+        col1:
+          type: integer
+          format: int32
+        col2:
+          type: integer
+          format: int32
+      required:
+      - col1
+      - col2
+`[1:]
+	if stdout.String() != expected {
+		t.Fatalf("unexpected document:\n%s", diff.LineDiff(expected, stdout.String()))
+	}
+}
+
+func TestOpenAPITypes(t *testing.T) {
+	cfg := &Config{NameConversion: template.Must(template.New("").Funcs(environ.FuncMap).Parse(`{{pascal .}}`))}
+	info := &database.Info{Schemas: []*database.Schema{{
+		Name:  "public",
+		Enums: []*database.Enum{{Name: "book_type", Values: []*database.EnumValue{{Name: "fiction", Value: 1}, {Name: "non_fiction", Value: 2}}}},
+		Tables: []*database.Table{{
+			Name:    "books",
+			Comment: "books in the library",
+			Columns: []*database.Column{
+				{Name: "id", Type: "uuid"},
+				{Name: "pages", Type: "int8", Nullable: true},
+				{Name: "title", Type: "character varying", Length: 200, Comment: "the full title"},
+				{Name: "type", Type: "book_type", UserDefined: true},
+				{Name: "alt_type", Type: "book_type", UserDefined: true, Nullable: true},
+				{Name: "tags", Type: "text", IsArray: true},
+				{Name: "published", Type: "timestamp with time zone"},
+				{Name: "extra", Type: "jsonb", Nullable: true},
+			},
+		}},
+	}}}
+	db, err := makeData(context.Background(), environ.Values{}, info, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.MarshalIndent(openAPIDocument(db), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `
+{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "public",
+    "version": "1.0.0"
+  },
+  "paths": {},
+  "components": {
+    "schemas": {
+      "BookType": {
+        "type": "string",
+        "enum": [
+          "fiction",
+          "non_fiction"
+        ]
+      },
+      "Books": {
+        "type": "object",
+        "description": "books in the library",
+        "properties": {
+          "alt_type": {
+            "allOf": [
+              {
+                "$ref": "#/components/schemas/BookType"
+              }
+            ],
+            "nullable": true
+          },
+          "extra": {
+            "nullable": true
+          },
+          "id": {
+            "type": "string",
+            "format": "uuid"
+          },
+          "pages": {
+            "type": "integer",
+            "format": "int64",
+            "nullable": true
+          },
+          "published": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "tags": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "title": {
+            "type": "string",
+            "description": "the full title",
+            "maxLength": 200
+          },
+          "type": {
+            "$ref": "#/components/schemas/BookType"
+          }
+        },
+        "required": [
+          "id",
+          "title",
+          "type",
+          "tags",
+          "published"
+        ]
+      }
+    }
+  }
+}
+`[1:]
+	if got := string(b) + "\n"; got != expected {
+		t.Fatalf("unexpected document:\n%s", diff.LineDiff(expected, got))
+	}
+}
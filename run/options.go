@@ -0,0 +1,98 @@
+package run
+
+import (
+	"context"
+	"io/fs"
+	"text/template"
+
+	"gnorm.org/gnorm/environ"
+)
+
+// Option is a functional option that customizes a Config.  Options let
+// programs that embed gnorm configure it in ways that can't be expressed in
+// gnorm.toml.
+type Option func(*Config)
+
+// WithFuncs adds the given functions to those available to templates.  The
+// functions are merged with gnorm's default functions, replacing any defaults
+// with the same name.  WithFuncs may be used more than once, in which case
+// later functions replace earlier ones with the same name.
+func WithFuncs(funcs template.FuncMap) Option {
+	return func(cfg *Config) {
+		if cfg.Funcs == nil {
+			cfg.Funcs = template.FuncMap{}
+		}
+		for name, f := range funcs {
+			cfg.Funcs[name] = f
+		}
+	}
+}
+
+// WithFS reads templates, partials, and static files from fsys instead of the
+// OS filesystem, so that programs can ship them inside their binary with
+// go:embed.  Paths to them in the config are relative to the root of fsys.
+// Templates for an external TemplateEngine are still read from disk.
+func WithFS(fsys fs.FS) Option {
+	return func(cfg *Config) {
+		cfg.FS = fsys
+	}
+}
+
+// WithSink sends the generated and static files to sink instead of writing
+// them to the OutputDir.  Options that work on the files in the OutputDir, such
+// as PostRun, Prune, and Atomic, can't be used with a sink.
+func WithSink(sink OutputSink) Option {
+	return func(cfg *Config) {
+		cfg.Sink = sink
+	}
+}
+
+// WithLogger sends the messages logged by Introspect, Render, and the database
+// driver to l, so that programs can log them the same way as their own.
+func WithLogger(l environ.Logger) Option {
+	return func(cfg *Config) {
+		cfg.Logger = l
+	}
+}
+
+// WithTypeMapper maps the types of columns with m instead of the type maps in
+// the config.
+func WithTypeMapper(m TypeMapper) Option {
+	return func(cfg *Config) {
+		cfg.TypeMapper = m
+	}
+}
+
+// WithDataHookFunc adds hook to the DataHookFuncs that are run over the data
+// before any templates are rendered.
+func WithDataHookFunc(hook DataHookFunc) Option {
+	return func(cfg *Config) {
+		cfg.DataHookFuncs = append(cfg.DataHookFuncs, hook)
+	}
+}
+
+// RunHook is started with the context of a run when Generate or Render begins
+// it, and the function it returns is called when the run ends.  Run hooks let
+// template functions that hold resources, such as WebAssembly plugins, tie
+// them to the run.
+type RunHook func(ctx context.Context) func()
+
+// WithRunHook adds hook to the RunHooks that are started with each run.
+func WithRunHook(hook RunHook) Option {
+	return func(cfg *Config) {
+		cfg.RunHooks = append(cfg.RunHooks, hook)
+	}
+}
+
+// FuncMap returns the functions available to templates, which are gnorm's
+// default functions merged with the functions in Funcs.
+func (cfg *Config) FuncMap() template.FuncMap {
+	m := make(template.FuncMap, len(environ.FuncMap)+len(cfg.Funcs))
+	for name, f := range environ.FuncMap {
+		m[name] = f
+	}
+	for name, f := range cfg.Funcs {
+		m[name] = f
+	}
+	return m
+}
@@ -0,0 +1,29 @@
+package run
+
+import (
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestWithFuncs(t *testing.T) {
+	cfg := &Config{}
+	WithFuncs(template.FuncMap{"shout": strings.ToUpper, "pascal": strings.ToLower})(cfg)
+	WithFuncs(template.FuncMap{"shout": strings.TrimSpace})(cfg)
+
+	funcs := cfg.FuncMap()
+	if _, ok := funcs["camel"]; !ok {
+		t.Error("expected default function camel to be present, but it was not")
+	}
+	tmpl, err := template.New("").Funcs(funcs).Parse(`{{shout " a "}}{{pascal "B"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &strings.Builder{}
+	if err := tmpl.Execute(buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "ab" {
+		t.Fatalf(`expected "ab", but got %q`, buf.String())
+	}
+}
@@ -0,0 +1,263 @@
+package run
+
+import (
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+
+	"gnorm.org/gnorm/database"
+	"gnorm.org/gnorm/environ"
+)
+
+// overlay is the schema info in an overlay file, which has the same form as a
+// snapshot's, except that only the fields that are set change the schema info
+// it's layered over.  Schemas, tables, columns, and indexes are matched by
+// name, and enums by their table and name.
+type overlay struct {
+	Schemas []*overlaySchema
+}
+
+type overlaySchema struct {
+	Name     string
+	Database string
+	Tables   []*overlayTable
+	Enums    []*database.Enum
+}
+
+type overlayTable struct {
+	Name         string
+	Type         *string
+	Comment      *string
+	IsView       *bool
+	IsInsertable *bool
+	Columns      []*overlayColumn
+	Indexes      []*overlayIndex
+}
+
+type overlayColumn struct {
+	Name         string
+	Type         *string
+	IsArray      *bool
+	Length       *int
+	Precision    *int
+	Scale        *int
+	UserDefined  *bool
+	Nullable     *bool
+	HasDefault   *bool
+	Default      *string
+	Comment      *string
+	IsPrimaryKey *bool
+	Ordinal      *int64
+	IsForeignKey *bool
+	ForeignKey   *database.ForeignKey
+}
+
+// overlayIndex is an index whose columns are named, so that indexes copied
+// from a snapshot, whose columns are whole columns, can be read as well.
+type overlayIndex struct {
+	Name     string
+	IsUnique bool
+	Columns  []struct{ Name string }
+}
+
+// readOverlay reads the overlay file, which is decoded as YAML or JSON based
+// on its extension, like a snapshot.  Unlike a snapshot, it may leave out the
+// Version, since overlays are usually written by hand.
+func readOverlay(file string) (*overlay, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.WithMessage(err, "can't read overlay")
+	}
+	var s struct {
+		Version int
+		Info    *overlay
+	}
+	if SnapshotFormatOf(file) == SnapshotYAML {
+		err = yaml.Unmarshal(b, &s)
+	} else {
+		err = json.Unmarshal(b, &s)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't parse overlay %s", file)
+	}
+	if s.Version > SnapshotVersion {
+		return nil, errors.Errorf("overlay %s has version %d, but this version of gnorm only reads versions up to %d", file, s.Version, SnapshotVersion)
+	}
+	if s.Info == nil {
+		return nil, errors.Errorf("overlay %s has no schema info", file)
+	}
+	return s.Info, nil
+}
+
+// applyOverlays layers each of the files in cfg.Overlays, in order, over info,
+// and then filters info again, so that the tables and columns they add are
+// filtered the same way as the ones read from the database.
+func applyOverlays(env environ.Values, cfg *Config, info *database.Info) error {
+	for _, file := range cfg.Overlays {
+		env.Logger().Infof("applying overlay %v", file)
+		o, err := readOverlay(file)
+		if err != nil {
+			return err
+		}
+		if err := o.apply(info); err != nil {
+			return errors.WithMessage(err, "error applying overlay "+file)
+		}
+	}
+	return filterSchemaInfo(cfg, info)
+}
+
+// apply changes info to add or override what's set in the overlay.
+func (ov *overlay) apply(info *database.Info) error {
+	for _, o := range ov.Schemas {
+		var s *database.Schema
+		for _, sch := range info.Schemas {
+			if sch.Name == o.Name && sch.Database == o.Database {
+				s = sch
+				break
+			}
+		}
+		if s == nil {
+			s = &database.Schema{Name: o.Name, Database: o.Database}
+			info.Schemas = append(info.Schemas, s)
+		}
+		for _, ot := range o.Tables {
+			if err := ot.apply(s); err != nil {
+				return errors.WithMessage(err, "table "+ot.Name)
+			}
+		}
+		for _, oe := range o.Enums {
+			replaced := false
+			for x, e := range s.Enums {
+				if e.Table == oe.Table && e.Name == oe.Name {
+					s.Enums[x] = oe
+					replaced = true
+					break
+				}
+			}
+			if !replaced {
+				s.Enums = append(s.Enums, oe)
+			}
+		}
+	}
+	return nil
+}
+
+// apply adds the table to the schema, or changes the table of the same name.
+// New tables are base tables that accept inserts, unless the overlay says
+// otherwise.
+func (ot *overlayTable) apply(s *database.Schema) error {
+	var t *database.Table
+	for _, tbl := range s.Tables {
+		if tbl.Name == ot.Name {
+			t = tbl
+			break
+		}
+	}
+	if t == nil {
+		t = &database.Table{Name: ot.Name, Type: "BASE TABLE", IsInsertable: true}
+		s.Tables = append(s.Tables, t)
+	}
+	setString(&t.Type, ot.Type)
+	setString(&t.Comment, ot.Comment)
+	setBool(&t.IsView, ot.IsView)
+	setBool(&t.IsInsertable, ot.IsInsertable)
+	for _, oc := range ot.Columns {
+		oc.apply(s, t)
+	}
+	for _, oi := range ot.Indexes {
+		idx := &database.Index{Name: oi.Name, IsUnique: oi.IsUnique}
+		for _, c := range oi.Columns {
+			col := tableColumn(t, c.Name)
+			if col == nil {
+				return errors.Errorf("index %s is on column %s, which doesn't exist", oi.Name, c.Name)
+			}
+			idx.Columns = append(idx.Columns, col)
+		}
+		replaced := false
+		for x, i := range t.Indexes {
+			if i.Name == oi.Name {
+				t.Indexes[x] = idx
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			t.Indexes = append(t.Indexes, idx)
+		}
+	}
+	return nil
+}
+
+// apply adds the column to the table, or changes the column of the same name.
+// New columns come after the table's other columns, unless the overlay gives
+// their Ordinal.
+func (oc *overlayColumn) apply(s *database.Schema, t *database.Table) {
+	c := tableColumn(t, oc.Name)
+	if c == nil {
+		c = &database.Column{Name: oc.Name, Ordinal: int64(len(t.Columns) + 1)}
+		t.Columns = append(t.Columns, c)
+	}
+	setString(&c.Type, oc.Type)
+	setBool(&c.IsArray, oc.IsArray)
+	setInt(&c.Length, oc.Length)
+	setInt(&c.Precision, oc.Precision)
+	setInt(&c.Scale, oc.Scale)
+	setBool(&c.UserDefined, oc.UserDefined)
+	setBool(&c.Nullable, oc.Nullable)
+	setBool(&c.HasDefault, oc.HasDefault)
+	setString(&c.Default, oc.Default)
+	setString(&c.Comment, oc.Comment)
+	setBool(&c.IsPrimaryKey, oc.IsPrimaryKey)
+	if oc.Ordinal != nil {
+		c.Ordinal = *oc.Ordinal
+	}
+	if oc.ForeignKey != nil {
+		fk := *oc.ForeignKey
+		if fk.SchemaName == "" {
+			fk.SchemaName = s.Name
+		}
+		if fk.TableName == "" {
+			fk.TableName = t.Name
+		}
+		if fk.ColumnName == "" {
+			fk.ColumnName = c.Name
+		}
+		c.ForeignKey = &fk
+		c.IsForeignKey = true
+	}
+	setBool(&c.IsForeignKey, oc.IsForeignKey)
+	if !c.IsForeignKey {
+		c.ForeignKey = nil
+	}
+}
+
+// tableColumn returns the table's column with the given name, or nil if there
+// isn't one.
+func tableColumn(t *database.Table, name string) *database.Column {
+	for _, c := range t.Columns {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func setString(dst *string, v *string) {
+	if v != nil {
+		*dst = *v
+	}
+}
+
+func setBool(dst *bool, v *bool) {
+	if v != nil {
+		*dst = *v
+	}
+}
+
+func setInt(dst *int, v *int) {
+	if v != nil {
+		*dst = *v
+	}
+}
@@ -0,0 +1,129 @@
+package run
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run/data"
+)
+
+func TestOverlays(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gnorm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	first := filepath.Join(dir, "first.yaml")
+	second := filepath.Join(dir, "second.json")
+	if err := ioutil.WriteFile(first, []byte(`
+info:
+  schemas:
+  - name: schema
+    tables:
+    - name: table
+      comment: the table
+      columns:
+      - name: col2
+        nullable: false
+      - name: col5
+        type: uuid
+        comment: added by the migration
+      indexes:
+      - name: col5_idx
+        columns:
+        - name: col5
+    - name: pending
+      columns:
+      - name: id
+        type: int
+        isprimarykey: true
+    - name: scratch
+      columns:
+      - name: id
+        type: int
+    enums:
+    - name: enum
+      values:
+      - name: one
+        value: 1
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(second, []byte(`{"Version": 1, "Info": {"Schemas": [
+		{"Name": "schema", "Tables": [{"Name": "table", "Columns": [{"Name": "col5", "Comment": "the new column"}]}]}
+	]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &Config{
+		ConfigData: data.ConfigData{
+			ExcludeTables: map[string][]string{"schema": {"scratch"}},
+		},
+		Driver:   dummyDriver{},
+		Overlays: []string{first, second},
+	}
+	info, err := ReadSchema(context.Background(), environ.Values{}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := info.Schemas[0]
+	var names []string
+	for _, tbl := range s.Tables {
+		names = append(names, tbl.Name)
+	}
+	if len(names) != 3 || names[0] != "pending" || names[1] != "table" || names[2] != "tb2" {
+		t.Fatalf("expected the pending table to be added and scratch to be excluded, but got %q", names)
+	}
+	pending := s.Tables[0]
+	if pending.Type != "BASE TABLE" || !pending.IsInsertable || !pending.Columns[0].IsPrimaryKey || pending.Columns[0].Ordinal != 1 {
+		t.Errorf("expected pending to be a base table with a primary key, but got %#v", pending)
+	}
+
+	tbl := s.Tables[1]
+	if tbl.Comment != "the table" {
+		t.Errorf("expected the table's comment to be overridden, but got %q", tbl.Comment)
+	}
+	col2 := tableColumn(tbl, "col2")
+	if col2.Nullable || col2.Type != "*int" {
+		t.Errorf("expected only Nullable to change on col2, but got %#v", col2)
+	}
+	col5 := tableColumn(tbl, "col5")
+	if col5 == nil || col5.Type != "uuid" || col5.Comment != "the new column" || col5.Ordinal != 5 {
+		t.Fatalf("expected col5 to be added with the second overlay's comment, but got %#v", col5)
+	}
+	if len(tbl.Indexes) != 2 || tbl.Indexes[1].Name != "col5_idx" || tbl.Indexes[1].Columns[0] != col5 {
+		t.Errorf("expected an index on col5, but got %#v", tbl.Indexes)
+	}
+	if len(s.Enums) != 1 || len(s.Enums[0].Values) != 1 {
+		t.Errorf("expected the enum to be replaced, but got %#v", s.Enums)
+	}
+}
+
+func TestOverlayErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gnorm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tests := map[string]string{
+		"newer version":  `{"Version": 99, "Info": {}}`,
+		"no info":        `{"Version": 1}`,
+		"missing column": `{"Info": {"Schemas": [{"Name": "schema", "Tables": [{"Name": "table", "Indexes": [{"Name": "x", "Columns": [{"Name": "nope"}]}]}]}]}}`,
+	}
+	for name, contents := range tests {
+		t.Run(name, func(t *testing.T) {
+			file := filepath.Join(dir, "overlay.json")
+			if err := ioutil.WriteFile(file, []byte(contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+			cfg := &Config{Driver: dummyDriver{}, Overlays: []string{file}}
+			if _, err := ReadSchema(context.Background(), environ.Values{}, cfg); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
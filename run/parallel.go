@@ -0,0 +1,86 @@
+package run
+
+import (
+	"sync"
+
+	"gnorm.org/gnorm/environ"
+)
+
+// renderQueue generates files on a pool of workers, since each file can be
+// rendered and written independently of the others.
+type renderQueue struct {
+	jobs chan func() error
+	wg   sync.WaitGroup
+
+	mu  sync.Mutex
+	err error
+}
+
+// newRenderQueue starts workers that run the jobs added to the queue.
+func newRenderQueue(workers int) *renderQueue {
+	q := &renderQueue{jobs: make(chan func() error)}
+	for x := 0; x < workers; x++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+	return q
+}
+
+func (q *renderQueue) work() {
+	defer q.wg.Done()
+	for job := range q.jobs {
+		if q.failed() != nil {
+			// don't bother rendering the rest, the run has already failed.
+			continue
+		}
+		if err := job(); err != nil {
+			q.mu.Lock()
+			if q.err == nil {
+				q.err = err
+			}
+			q.mu.Unlock()
+		}
+	}
+}
+
+// failed returns the first error a job returned, if any.
+func (q *renderQueue) failed() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.err
+}
+
+// wait waits for all the queued jobs to finish, and returns the first error
+// any of them returned.
+func (q *renderQueue) wait() error {
+	close(q.jobs)
+	q.wg.Wait()
+	return q.err
+}
+
+// queueFile runs gen, which generates a file, on the run's render queue if it
+// has one, or right away if not.  Once a queued file fails, it returns the
+// error so that no more files are queued.
+func queueFile(r *genRun, gen func() error) error {
+	if r.renders == nil {
+		return gen()
+	}
+	if err := r.renders.failed(); err != nil {
+		return err
+	}
+	r.renders.jobs <- gen
+	return nil
+}
+
+// lockOutput returns env with its stdout and stderr changed so that writes to
+// them from multiple goroutines don't get mixed up.
+func lockOutput(env environ.Values) environ.Values {
+	mu := &sync.Mutex{}
+	if env.Stdout != nil {
+		env.Stdout = &lockedWriter{mu: mu, w: env.Stdout}
+	}
+	if env.Stderr != nil {
+		env.Stderr = &lockedWriter{mu: mu, w: env.Stderr}
+	}
+	return env
+}
@@ -0,0 +1,66 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run/data"
+)
+
+func TestGenerateParallel(t *testing.T) {
+	dir := t.TempDir()
+	env := environ.Values{Stdout: &bytes.Buffer{}}
+	var targets []OutputTarget
+	for _, name := range []string{"a", "b", "c"} {
+		targets = append(targets, OutputTarget{
+			Filename: template.Must(template.New("").Parse(`{{.Table}}.` + name)),
+			Contents: template.Must(template.New("").Parse(`{{.Table.Name}} ` + name)),
+		})
+	}
+	cfg := &Config{
+		ConfigData:     data.ConfigData{OutputDir: dir},
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver:         dummyDriver{},
+		TablePaths:     targets,
+		EnumPaths: []OutputTarget{{
+			Filename: template.Must(template.New("").Parse(`{{.Enum}}.enum`)),
+			Contents: template.Must(template.New("").Parse(`{{.Enum.Name}}`)),
+		}},
+		Parallel: 4,
+	}
+	if err := Generate(context.Background(), env, cfg); err != nil {
+		t.Fatal(err)
+	}
+	m, err := ReadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"enum.enum", "table.a", "table.b", "table.c", "tb2.a", "tb2.b", "tb2.c"}
+	if !reflect.DeepEqual(m.Files, expected) {
+		t.Fatalf("expected files %q, but got %q", expected, m.Files)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "tb2.c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "tb2 c" {
+		t.Fatalf("expected tb2.c to contain %q, but got %q", "tb2 c", b)
+	}
+
+	// an error from any of the files fails the run.
+	cfg.TablePaths = append(cfg.TablePaths, OutputTarget{
+		Filename: template.Must(template.New("").Parse(`{{.Table}}.bad`)),
+		Contents: template.Must(template.New("").Parse(`{{.Table.Name.Nope}}`)),
+	})
+	err = Generate(context.Background(), env, cfg)
+	if err == nil || !strings.Contains(err.Error(), "generating file for table") {
+		t.Fatalf("expected an error generating a table, but got %v", err)
+	}
+}
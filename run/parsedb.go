@@ -1,22 +1,357 @@
 package run
 
-func makeFilter(include, exclude map[string][]string) func(schema, table string) bool {
+import (
+	"context"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"gnorm.org/gnorm/database"
+	"gnorm.org/gnorm/environ"
+)
+
+// parseDB reads the schema info from the database, or each of the databases
+// in cfg.Databases, or the snapshot in cfg.Snapshot, layers cfg.Overlays over
+// it, and filters out the tables that shouldn't be included.  The info is
+// sorted, so that generated files and preview output are the same each time
+// the same schema is read.
+func parseDB(ctx context.Context, env environ.Values, cfg *Config) (*database.Info, error) {
+	info, err := readDB(ctx, env, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Overlays) > 0 {
+		if err := applyOverlays(env, cfg, info); err != nil {
+			return nil, err
+		}
+	}
+	info.Sort()
+	return info, nil
+}
+
+// readDB reads the schema info from the database or snapshot, in whatever
+// order it comes in.
+func readDB(ctx context.Context, env environ.Values, cfg *Config) (*database.Info, error) {
+	if cfg.Snapshot != "" {
+		return parseSnapshot(env, cfg)
+	}
+	if err := checkOnlySchemas(cfg); err != nil {
+		return nil, err
+	}
+	// the picked tables are only used once the files are generated, but bad
+	// patterns should fail before the database is read.
+	if _, err := onlyFilter(cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.Databases) == 0 {
+		return parseDatabase(ctx, env, cfg, cfg.Driver, cfg.ConnStr, cfg.Schemas)
+	}
+	if len(cfg.Queries) > 0 {
+		return nil, errors.New("Queries can't be used with Databases")
+	}
+	info := &database.Info{}
+	for _, d := range cfg.Databases {
+		env.Logger().Infof("reading database %v", d.Name)
+		i, err := parseDatabase(ctx, env, cfg, d.Driver, d.ConnStr, d.Schemas)
+		if err != nil {
+			return nil, errors.WithMessage(err, "error reading database "+d.Name)
+		}
+		for _, s := range i.Schemas {
+			s.Database = d.Name
+		}
+		info.Schemas = append(info.Schemas, i.Schemas...)
+	}
+	return info, nil
+}
+
+// Ping connects to and reads the database the same way Generate does, but
+// doesn't convert the data or render any templates.  It's used to check that
+// the connection settings work.
+func Ping(ctx context.Context, env environ.Values, cfg *Config) error {
+	_, err := parseDB(ctx, env, cfg)
+	return err
+}
+
+// ReadSchema connects to and reads the database the same way Generate does, and
+// returns the schema info without converting it.
+func ReadSchema(ctx context.Context, env environ.Values, cfg *Config) (*database.Info, error) {
+	return parseDB(ctx, env, cfg)
+}
+
+// parseDatabase reads the given schemas from a single database.  All of them
+// are read even if OnlySchemas or OnlyTables pick some of them, so that foreign
+// keys to tables that weren't picked are kept.
+func parseDatabase(ctx context.Context, env environ.Values, cfg *Config, driver database.Driver, connStr string, schemas []string) (*database.Info, error) {
+	filter, err := makeFilter(cfg.IncludeTables, cfg.ExcludeTables)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.OnlySchemas) > 0 && len(onlySchemas(cfg, schemas)) == 0 {
+		// none of this database's schemas were asked for, and foreign keys
+		// don't cross databases.
+		return &database.Info{}, nil
+	}
+	ctx, connStr, done, err := connect(ctx, env, cfg, driver, connStr)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	if len(cfg.Queries) > 0 {
+		ctx = database.WithQueries(ctx, cfg.Queries)
+	}
+	var info *database.Info
+	err = withRetries(ctx, env, cfg.Retries, func() error {
+		var err error
+		info, err = driver.Parse(ctx, env.Logger(), connStr, schemas, filter)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := excludeColumns(info, cfg.ExcludeColumns); err != nil {
+		return nil, err
+	}
+	if err := filterInfo(info, cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.Queries) > 0 && info.Queries == nil {
+		return nil, errors.New("Queries are not supported by this database driver")
+	}
+	return info, nil
+}
+
+// connect gets ready to connect to a database with connStr, opening the SSH
+// tunnel and setting up TLS if cfg asks for them.  It returns the context and
+// connection string to pass to the driver, and a function that closes the
+// tunnel, which must be called once the driver is done.  The returned context
+// is derived from ctx, so the driver's queries are cancelled along with it.
+func connect(ctx context.Context, env environ.Values, cfg *Config, driver database.Driver, connStr string) (context.Context, string, func(), error) {
+	done := func() {}
+	if cfg.SSHTunnel != nil {
+		if !strings.Contains(connStr, "$"+TunnelVar) {
+			return nil, "", nil, errors.New("SSHTunnel is set, but ConnStr doesn't use $" + TunnelVar)
+		}
+		tun, err := openTunnel(ctx, env, cfg.SSHTunnel, cfg.ConnectTimeout)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		done = func() { tun.Close() }
+		connStr = strings.Replace(connStr, "$"+TunnelVar, tun.Addr(), -1)
+	}
+	if cfg.TLS != nil {
+		c, ok := driver.(database.TLSConfigurer)
+		if !ok {
+			done()
+			return nil, "", nil, errors.New("TLS is not supported by this database driver")
+		}
+		var err error
+		connStr, err = c.ConfigureTLS(connStr, cfg.TLS)
+		if err != nil {
+			done()
+			return nil, "", nil, err
+		}
+	}
+	ctx = database.WithTimeouts(ctx, database.Timeouts{
+		Connect: cfg.ConnectTimeout,
+		Query:   cfg.QueryTimeout,
+	})
+	if cfg.ReadOnly {
+		ctx = database.WithReadOnly(ctx)
+	}
+	return ctx, connStr, done, nil
+}
+
+// makeFilter returns a function that reports whether a table should be
+// included, given maps of schema names to patterns of tables to include and
+// exclude.  See ParseNamePattern for the format of the patterns.
+func makeFilter(include, exclude map[string][]string) (func(schema, table string) bool, error) {
 	if sumLens(include) == 0 && sumLens(exclude) == 0 {
-		return func(_, _ string) bool { return true }
+		return func(_, _ string) bool { return true }, nil
 	}
 	if sumLens(include) == 0 {
+		patterns, err := parsePatterns(exclude)
+		if err != nil {
+			return nil, errors.WithMessage(err, "error parsing ExcludeTables")
+		}
 		return func(schema, table string) bool {
-			return !contains(exclude[schema], table)
+			return !matchAny(patterns[schema], table)
+		}, nil
+	}
+	patterns, err := parsePatterns(include)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error parsing IncludeTables")
+	}
+	return func(schema, table string) bool {
+		return matchAny(patterns[schema], table)
+	}, nil
+}
+
+// onlySchemas returns the schemas that are in cfg.OnlySchemas, or all of them
+// if it's not set.
+func onlySchemas(cfg *Config, schemas []string) []string {
+	if len(cfg.OnlySchemas) == 0 {
+		return schemas
+	}
+	var out []string
+	for _, s := range schemas {
+		for _, only := range cfg.OnlySchemas {
+			if s == only {
+				out = append(out, s)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// checkOnlySchemas returns an error if cfg.OnlySchemas names a schema that the
+// config doesn't read.
+func checkOnlySchemas(cfg *Config) error {
+	schemas := map[string]bool{}
+	for _, s := range cfg.Schemas {
+		schemas[s] = true
+	}
+	for _, d := range cfg.Databases {
+		for _, s := range d.Schemas {
+			schemas[s] = true
 		}
 	}
+	for _, s := range cfg.OnlySchemas {
+		if !schemas[s] {
+			return errors.Errorf("schema %q is not one of the schemas in the config", s)
+		}
+	}
+	return nil
+}
+
+// onlyFilter returns a function that reports whether a table is picked by
+// cfg.OnlySchemas and cfg.OnlyTables.  It doesn't filter what's read, only
+// which tables files are generated for.
+func onlyFilter(cfg *Config) (func(schema, table string) bool, error) {
+	if len(cfg.OnlySchemas) == 0 && len(cfg.OnlyTables) == 0 {
+		return func(_, _ string) bool { return true }, nil
+	}
+	tables := map[string][]func(string) bool{}
+	for _, p := range cfg.OnlyTables {
+		schema := ""
+		// regexp patterns may have dots of their own.
+		if i := strings.Index(p, "."); i >= 0 && !strings.HasPrefix(p, "/") {
+			schema, p = p[:i], p[i+1:]
+		}
+		match, err := ParseNamePattern(p)
+		if err != nil {
+			return nil, errors.WithMessage(err, "error parsing tables")
+		}
+		tables[schema] = append(tables[schema], match)
+	}
 	return func(schema, table string) bool {
-		return contains(include[schema], table)
+		if len(onlySchemas(cfg, []string{schema})) == 0 {
+			return false
+		}
+		if len(cfg.OnlyTables) == 0 {
+			return true
+		}
+		return matchAny(tables[""], table) || matchAny(tables[schema], table)
+	}, nil
+}
+
+// excludeColumns removes columns from the tables in info.  exclude maps
+// "schema.table" or just "table" (for tables of that name in any schema) to
+// patterns of column names to remove.  Indexes that include a removed column
+// are removed as well.
+func excludeColumns(info *database.Info, exclude map[string][]string) error {
+	if len(exclude) == 0 {
+		return nil
+	}
+	patterns, err := parsePatterns(exclude)
+	if err != nil {
+		return errors.WithMessage(err, "error parsing ExcludeColumns")
+	}
+	for _, s := range info.Schemas {
+		for _, t := range s.Tables {
+			var p []func(string) bool
+			p = append(p, patterns[t.Name]...)
+			p = append(p, patterns[s.Name+"."+t.Name]...)
+			if len(p) == 0 {
+				continue
+			}
+			removed := map[string]bool{}
+			columns := t.Columns[:0]
+			for _, c := range t.Columns {
+				if matchAny(p, c.Name) {
+					removed[c.Name] = true
+					continue
+				}
+				columns = append(columns, c)
+			}
+			t.Columns = columns
+			if len(removed) == 0 {
+				continue
+			}
+			indexes := t.Indexes[:0]
+			for _, i := range t.Indexes {
+				if !indexHasColumn(i, removed) {
+					indexes = append(indexes, i)
+				}
+			}
+			t.Indexes = indexes
+		}
+	}
+	return nil
+}
+
+// indexHasColumn reports whether any of the index's columns are in names.
+func indexHasColumn(i *database.Index, names map[string]bool) bool {
+	for _, c := range i.Columns {
+		if names[c.Name] {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseNamePattern parses a pattern for matching table or column names.  A
+// pattern surrounded by slashes, e.g. "/^tmp_/", is a regular expression.  Any
+// other pattern is a glob, e.g. "audit_*", as used by path.Match.  A pattern
+// without any wildcards only matches that exact name.
+func ParseNamePattern(pattern string) (func(name string) bool, error) {
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return nil, errors.WithMessage(err, "bad pattern "+pattern)
+		}
+		return re.MatchString, nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, errors.WithMessage(err, "bad pattern "+pattern)
+	}
+	return func(name string) bool {
+		ok, _ := path.Match(pattern, name)
+		return ok
+	}, nil
+}
+
+// parsePatterns parses the patterns in each of the lists in m.
+func parsePatterns(m map[string][]string) (map[string][]func(string) bool, error) {
+	out := make(map[string][]func(string) bool, len(m))
+	for k, patterns := range m {
+		for _, p := range patterns {
+			match, err := ParseNamePattern(p)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = append(out[k], match)
+		}
 	}
+	return out, nil
 }
 
-func contains(vals []string, s string) bool {
-	for x := range vals {
-		if vals[x] == s {
+// matchAny reports whether any of the patterns match name.
+func matchAny(patterns []func(string) bool, name string) bool {
+	for _, match := range patterns {
+		if match(name) {
 			return true
 		}
 	}
@@ -1,17 +1,295 @@
 package run
 
-import "testing"
+import (
+	"context"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"gnorm.org/gnorm/database"
+	"gnorm.org/gnorm/environ"
+)
 
 func TestMakeFilter(t *testing.T) {
 	var include, exclude map[string][]string
-	f := makeFilter(include, exclude)
+	f, err := makeFilter(include, exclude)
+	if err != nil {
+		t.Fatal(err)
+	}
 	if !f("anything", "any other thing") {
 		t.Fatalf("nil maps should return a filter that doesn't filter")
 	}
 	include = map[string][]string{}
 	exclude = map[string][]string{}
-	f = makeFilter(include, exclude)
+	f, err = makeFilter(include, exclude)
+	if err != nil {
+		t.Fatal(err)
+	}
 	if !f("anything", "any other thing") {
 		t.Fatalf("empty maps should return a filter that doesn't filter")
 	}
 }
+
+func TestMakeFilterPatterns(t *testing.T) {
+	exclude := map[string][]string{"public": {"audit_*", "/^tmp_.+_old$/", "users"}}
+	f, err := makeFilter(nil, exclude)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for table, expected := range map[string]bool{
+		"audit_log":     false,
+		"tmp_users_old": false,
+		"users":         false,
+		"tmp_users":     true,
+		"user_audit":    true,
+		"users2":        true,
+	} {
+		if f("public", table) != expected {
+			t.Errorf("expected filter of %q to be %v, but got %v", table, expected, !expected)
+		}
+	}
+	if !f("other", "audit_log") {
+		t.Error("expected patterns to only apply to their own schema")
+	}
+
+	if _, err := makeFilter(map[string][]string{"public": {"/(/"}}, nil); err == nil {
+		t.Error("expected an error for a bad regular expression, but got nil")
+	}
+}
+
+func TestOnlyFilter(t *testing.T) {
+	cfg := &Config{
+		OnlySchemas: []string{"public", "app"},
+		OnlyTables:  []string{"public.audit_*", "users", "/^a.b$/"},
+	}
+	f, err := onlyFilter(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for table, expected := range map[string]bool{
+		"public.audit_users": true,
+		"public.audit_log":   true,
+		"public.users":       true,
+		"public.posts":       false,
+		"public.a.b":         true,
+		"app.users":          true,
+		"app.audit_users":    false,
+		"other.users":        false,
+	} {
+		i := strings.Index(table, ".")
+		if f(table[:i], table[i+1:]) != expected {
+			t.Errorf("expected filter of %q to be %v, but got %v", table, expected, !expected)
+		}
+	}
+
+	cfg.Schemas = []string{"public"}
+	if err := checkOnlySchemas(cfg); err == nil {
+		t.Error("expected an error for a schema that isn't in the config, but got nil")
+	}
+}
+
+type queryDriver struct {
+	dummyDriver
+}
+
+func (d queryDriver) Parse(ctx context.Context, log environ.Logger, conn string, schemaNames []string, filterTables func(schema, table string) bool) (*database.Info, error) {
+	info, err := d.dummyDriver.Parse(ctx, log, conn, schemaNames, filterTables)
+	if err != nil {
+		return nil, err
+	}
+	info.Queries = map[string][]map[string]interface{}{}
+	for name, q := range database.Queries(ctx) {
+		info.Queries[name] = []map[string]interface{}{{"query": q}}
+	}
+	return info, nil
+}
+
+func TestParseDBQueries(t *testing.T) {
+	env := environ.Values{}
+	cfg := &Config{
+		Driver:  queryDriver{},
+		Queries: map[string]string{"stats": "SELECT 1"},
+	}
+	info, err := parseDB(context.Background(), env, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := info.Queries["stats"]
+	if len(rows) != 1 || rows[0]["query"] != "SELECT 1" {
+		t.Fatalf("expected one row from the stats query, but got %v", rows)
+	}
+
+	cfg.Driver = dummyDriver{}
+	if _, err := parseDB(context.Background(), env, cfg); err == nil {
+		t.Fatal("expected an error from a driver that doesn't support queries, but got nil")
+	}
+}
+
+func TestExcludeColumns(t *testing.T) {
+	hash := &database.Column{Name: "password_hash"}
+	users := &database.Table{
+		Name: "users",
+		Columns: []*database.Column{
+			{Name: "id"},
+			{Name: "email"},
+			hash,
+			{Name: "deleted_at"},
+		},
+		Indexes: []*database.Index{
+			{Name: "users_hash", Columns: []*database.Column{hash}},
+			{Name: "users_id"},
+		},
+	}
+	other := &database.Table{
+		Name:    "users",
+		Columns: []*database.Column{{Name: "password_hash"}, {Name: "deleted_at"}},
+	}
+	info := &database.Info{
+		Schemas: []*database.Schema{
+			{Name: "public", Tables: []*database.Table{users}},
+			{Name: "other", Tables: []*database.Table{other}},
+		},
+	}
+	exclude := map[string][]string{
+		"public.users": {"password_hash"},
+		"users":        {"deleted_*"},
+	}
+	if err := excludeColumns(info, exclude); err != nil {
+		t.Fatal(err)
+	}
+	names := func(t *database.Table) []string {
+		var out []string
+		for _, c := range t.Columns {
+			out = append(out, c.Name)
+		}
+		return out
+	}
+	if got := strings.Join(names(users), ","); got != "id,email" {
+		t.Errorf("expected public.users columns id,email, but got %s", got)
+	}
+	if got := strings.Join(names(other), ","); got != "password_hash" {
+		t.Errorf("expected other.users columns password_hash, but got %s", got)
+	}
+	if len(users.Indexes) != 1 || users.Indexes[0].Name != "users_id" {
+		t.Errorf("expected only the index without excluded columns to remain, but got %v", users.Indexes)
+	}
+}
+
+type tlsDriver struct {
+	dummyDriver
+	conn string
+}
+
+func (d *tlsDriver) Parse(ctx context.Context, log environ.Logger, conn string, schemaNames []string, filterTables func(schema, table string) bool) (*database.Info, error) {
+	d.conn = conn
+	return d.dummyDriver.Parse(ctx, log, conn, schemaNames, filterTables)
+}
+
+func (*tlsDriver) ConfigureTLS(conn string, t *database.TLS) (string, error) {
+	return conn + " ca=" + t.CAFile, nil
+}
+
+func TestParseDBTLS(t *testing.T) {
+	env := environ.Values{}
+	d := &tlsDriver{}
+	cfg := &Config{
+		Driver: d,
+		TLS:    &database.TLS{CAFile: "ca.pem"},
+	}
+	cfg.ConnStr = "host=db"
+	if _, err := parseDB(context.Background(), env, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "host=db ca=ca.pem"; d.conn != expected {
+		t.Fatalf("expected the driver to get ConnStr %q, but got %q", expected, d.conn)
+	}
+
+	cfg.Driver = dummyDriver{}
+	if _, err := parseDB(context.Background(), env, cfg); err == nil {
+		t.Fatal("expected an error from a driver that doesn't support TLS, but got nil")
+	}
+}
+
+type flakyDriver struct {
+	dummyDriver
+	failures int
+	calls    int
+}
+
+func (d *flakyDriver) Parse(ctx context.Context, log environ.Logger, conn string, schemaNames []string, filterTables func(schema, table string) bool) (*database.Info, error) {
+	d.calls++
+	if d.calls <= d.failures {
+		return nil, errors.New("connection refused")
+	}
+	return d.dummyDriver.Parse(ctx, log, conn, schemaNames, filterTables)
+}
+
+func TestParseDBRetries(t *testing.T) {
+	defer func(d time.Duration) { retryDelay = d }(retryDelay)
+	retryDelay = time.Millisecond
+
+	env := environ.Values{}
+	d := &flakyDriver{failures: 2}
+	cfg := &Config{Driver: d, Retries: 2}
+	if _, err := parseDB(context.Background(), env, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if d.calls != 3 {
+		t.Fatalf("expected 3 calls to Parse, but got %v", d.calls)
+	}
+
+	d = &flakyDriver{failures: 2}
+	cfg = &Config{Driver: d, Retries: 1}
+	if _, err := parseDB(context.Background(), env, cfg); err == nil {
+		t.Fatal("expected an error after running out of retries, but got nil")
+	}
+	if d.calls != 2 {
+		t.Fatalf("expected 2 calls to Parse, but got %v", d.calls)
+	}
+
+	// a cancelled run doesn't wait to retry.
+	retryDelay = time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	d = &flakyDriver{failures: 2}
+	cfg = &Config{Driver: d, Retries: 2}
+	if _, err := parseDB(ctx, env, cfg); err == nil {
+		t.Fatal("expected an error from a cancelled run, but got nil")
+	}
+	if d.calls != 1 {
+		t.Fatalf("expected 1 call to Parse, but got %v", d.calls)
+	}
+}
+
+func TestParseDBDatabases(t *testing.T) {
+	env := environ.Values{}
+	cfg := &Config{
+		Databases: []Database{
+			{Name: "billing", Driver: dummyDriver{}, Schemas: []string{"schema"}},
+			{Name: "users", Driver: dummyDriver{}, Schemas: []string{"schema"}},
+		},
+	}
+	cfg.NameConversion = template.Must(template.New("").Parse("{{.}}"))
+	info, err := parseDB(context.Background(), env, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Schemas) != 2 {
+		t.Fatalf("expected 2 schemas, but got %v", len(info.Schemas))
+	}
+	for x, name := range []string{"billing", "users"} {
+		if info.Schemas[x].Database != name {
+			t.Fatalf("expected schema %v to be from database %q, but got %q", x, name, info.Schemas[x].Database)
+		}
+	}
+	db, err := makeData(context.Background(), env, info, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := db.SchemasByName["users.schema"]; s == nil || s.Database != "users" {
+		t.Fatalf("expected users.schema in SchemasByName, but got %v", db.SchemasByName)
+	}
+}
@@ -0,0 +1,119 @@
+package run
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+
+	"gnorm.org/gnorm/environ"
+)
+
+// postRunQueue runs the PostRun command for generated files on a pool of
+// workers, so that slow commands like formatters run concurrently.
+type postRunQueue struct {
+	env  environ.Values
+	run  *genRun
+	jobs chan postRunJob
+	wg   sync.WaitGroup
+
+	// progress counts the commands run so far.
+	progress *progress
+
+	mu  sync.Mutex
+	err error
+}
+
+// postRunJob is a generated file waiting for PostRun to be run on it.  name is
+// its path relative to the output directory.  old and stat are the contents
+// and info of the file before it was generated, if it existed.
+type postRunJob struct {
+	path string
+	name string
+	old  []byte
+	stat os.FileInfo
+}
+
+// newPostRunQueue starts r.PostRunWorkers workers that run PostRun for the
+// files added to the queue, with ctx.
+func newPostRunQueue(ctx context.Context, env environ.Values, r *genRun) *postRunQueue {
+	// commands write to stdout and stderr concurrently.
+	env = lockOutput(env)
+	q := &postRunQueue{
+		env:      env,
+		run:      r,
+		jobs:     make(chan postRunJob),
+		progress: startProgress(env, "running PostRun", 0),
+	}
+	for x := 0; x < r.PostRunWorkers; x++ {
+		q.wg.Add(1)
+		go q.work(ctx)
+	}
+	return q
+}
+
+func (q *postRunQueue) work(ctx context.Context) {
+	defer q.wg.Done()
+	for job := range q.jobs {
+		q.mu.Lock()
+		failed := q.err != nil
+		q.mu.Unlock()
+		if failed {
+			// don't bother running the rest, the run has already failed.
+			continue
+		}
+		if err := finishFile(ctx, q.env, q.run, job); err != nil {
+			q.mu.Lock()
+			if q.err == nil {
+				q.err = err
+			}
+			q.mu.Unlock()
+		}
+		q.progress.add(1)
+	}
+}
+
+// add queues PostRun to be run for the file.
+func (q *postRunQueue) add(job postRunJob) {
+	q.progress.grow(1)
+	q.jobs <- job
+}
+
+// wait waits for all the queued commands to finish, and returns the first
+// error any of them returned.
+func (q *postRunQueue) wait() error {
+	close(q.jobs)
+	q.wg.Wait()
+	q.progress.finish()
+	return q.err
+}
+
+// finishFile runs PostRun for a generated file, and then keeps its old
+// modification time if PostRun left it unchanged.  If PostRunWarnOnly is set,
+// a failed PostRun command is logged as a warning instead of returned.
+func finishFile(ctx context.Context, env environ.Values, r *genRun, job postRunJob) error {
+	err := doPostRun(ctx, env, job.path, r.PostRun)
+	r.report.postRun(job.name, err != nil)
+	if err != nil {
+		if !r.PostRunWarnOnly {
+			return err
+		}
+		env.Logger().Warnf("%v", err)
+	}
+	if job.stat != nil {
+		return keepModTime(job.path, job.old, job.stat.ModTime())
+	}
+	return nil
+}
+
+// lockedWriter serializes writes to w.
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(b []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(b)
+}
@@ -2,10 +2,13 @@ package run
 
 import (
 	"bytes"
+	"context"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
 	"sort"
+	"strconv"
 	"strings"
 	"text/template"
 
@@ -21,7 +24,7 @@ var previewTpl = template.Must(
 		Funcs(map[string]interface{}{"makeTable": makeTable}).
 		Parse(`
 {{- range .Schemas }}{{$schema := .DBName -}}
-Schema: {{.Name}}({{.DBName}})
+Schema: {{.Name}}({{.DBName}}){{if .Database}} in database {{.Database}}{{end}}
 {{range .Enums}}
 Enum: {{.Name}}({{$schema}}.{{.DBName}})
 {{makeTable .Values "{{.Name}}|{{.DBName}}|{{.Value}}" "Name" "DBName" "Value" }}
@@ -47,19 +50,29 @@ const (
 	PreviewJSON
 	// PreviewTypes just prints out the column types used by the DB.
 	PreviewTypes
+	// PreviewMarkdown shows the data in markdown tables.
+	PreviewMarkdown
+	// PreviewCSV shows the columns of all the tables in CSV.
+	PreviewCSV
+	// PreviewSQL shows the data as the CREATE statements that would make it,
+	// the same as ExportDDL.
+	PreviewSQL
 )
 
 // Preview displays the database info that would be passed to your template
-// based on your configuration.
-func Preview(env environ.Values, cfg *Config, format PreviewFormat) error {
-	info, err := cfg.Driver.Parse(env.Log, cfg.ConnStr, cfg.Schemas, makeFilter(cfg.IncludeTables, cfg.ExcludeTables))
+// based on your configuration.  The dialect is only used by PreviewSQL.
+func Preview(ctx context.Context, env environ.Values, cfg *Config, format PreviewFormat, dialect DDLDialect) error {
+	info, err := parseDB(ctx, env, cfg)
 	if err != nil {
 		return err
 	}
-	data, err := makeData(env.Log, info, cfg)
+	data, err := makeData(ctx, env, info, cfg)
 	if err != nil {
 		return err
 	}
+	if err := pickData(cfg, data); err != nil {
+		return err
+	}
 	switch format {
 	case PreviewTypes:
 		displayTypes(env, data)
@@ -80,6 +93,15 @@ func Preview(env environ.Values, cfg *Config, format PreviewFormat) error {
 		return err
 	case PreviewTabular:
 		return previewTpl.Execute(env.Stdout, data)
+	case PreviewMarkdown:
+		return writeMarkdown(env.Stdout, data)
+	case PreviewCSV:
+		return writeCSV(env.Stdout, data)
+	case PreviewSQL:
+		buf := &bytes.Buffer{}
+		writeDDL(buf, data, dialect)
+		_, err := env.Stdout.Write(buf.Bytes())
+		return err
 	default:
 		return errors.Errorf("Unsupported format: %v", format)
 	}
@@ -169,3 +191,112 @@ func displayTypes(env environ.Values, info *data.DBData) {
 		fmt.Fprintf(env.Stdout, "%q = %q\n", c.DBType, c.Type)
 	}
 }
+
+// columnFields are the titles of the fields of each column shown by the
+// markdown and CSV previews.
+var columnFields = []string{"Name", "DBName", "Type", "DBType", "IsArray", "IsPrimaryKey", "Ordinal", "IsFK", "HasFKRef", "Length", "UserDefined", "Nullable", "HasDefault", "Comment"}
+
+// columnValues returns the values of the column's fields named in
+// columnFields.
+func columnValues(c *data.Column) []string {
+	return []string{
+		c.Name,
+		c.DBName,
+		c.Type,
+		c.DBType,
+		strconv.FormatBool(c.IsArray),
+		strconv.FormatBool(c.IsPrimaryKey),
+		strconv.FormatInt(c.Ordinal, 10),
+		strconv.FormatBool(c.IsFK),
+		strconv.FormatBool(c.HasFKRef),
+		strconv.Itoa(c.Length),
+		strconv.FormatBool(c.UserDefined),
+		strconv.FormatBool(c.Nullable),
+		strconv.FormatBool(c.HasDefault),
+		c.Comment,
+	}
+}
+
+// writeMarkdown writes the data as markdown, with a table for the columns and
+// indexes of each table, and the values of each enum.
+func writeMarkdown(w io.Writer, db *data.DBData) error {
+	buf := &bytes.Buffer{}
+	for _, s := range db.Schemas {
+		fmt.Fprintf(buf, "# Schema: %s (%s)", s.Name, s.DBName)
+		if s.Database != "" {
+			fmt.Fprintf(buf, " in database %s", s.Database)
+		}
+		buf.WriteString("\n\n")
+		for _, e := range s.Enums {
+			fmt.Fprintf(buf, "## Enum: %s (%s.%s)\n\n", e.Name, s.DBName, e.DBName)
+			var rows [][]string
+			for _, v := range e.Values {
+				rows = append(rows, []string{v.Name, v.DBName, strconv.Itoa(v.Value)})
+			}
+			markdownTable(buf, []string{"Name", "DBName", "Value"}, rows)
+		}
+		for _, t := range s.Tables {
+			fmt.Fprintf(buf, "## Table: %s (%s.%s)\n\n", t.Name, s.DBName, t.DBName)
+			if t.Comment != "" {
+				fmt.Fprintf(buf, "%s\n\n", t.Comment)
+			}
+			var rows [][]string
+			for _, c := range t.Columns {
+				rows = append(rows, columnValues(c))
+			}
+			markdownTable(buf, columnFields, rows)
+			if len(t.Indexes) == 0 {
+				continue
+			}
+			buf.WriteString("### Indexes\n\n")
+			rows = nil
+			for _, i := range t.Indexes {
+				rows = append(rows, []string{i.Name, i.DBName, strconv.FormatBool(i.IsUnique), strings.Join(i.Columns.Names(), ", ")})
+			}
+			markdownTable(buf, []string{"Name", "DBName", "IsUnique", "Columns"}, rows)
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// markdownTable writes a markdown table with the given titles and rows to buf,
+// followed by a blank line.
+func markdownTable(buf *bytes.Buffer, titles []string, rows [][]string) {
+	cell := strings.NewReplacer("|", "\\|", "\n", " ")
+	writeRow := func(row []string) {
+		buf.WriteString("|")
+		for _, v := range row {
+			fmt.Fprintf(buf, " %s |", cell.Replace(v))
+		}
+		buf.WriteString("\n")
+	}
+	writeRow(titles)
+	buf.WriteString("|" + strings.Repeat(" --- |", len(titles)) + "\n")
+	for _, row := range rows {
+		writeRow(row)
+	}
+	buf.WriteString("\n")
+}
+
+// writeCSV writes the columns of all the tables as CSV, one row per column,
+// each starting with the names of its schema and table.
+func writeCSV(w io.Writer, db *data.DBData) error {
+	cw := csv.NewWriter(w)
+	titles := append([]string{"Database", "Schema", "Table"}, columnFields...)
+	if err := cw.Write(titles); err != nil {
+		return err
+	}
+	for _, s := range db.Schemas {
+		for _, t := range s.Tables {
+			for _, c := range t.Columns {
+				row := append([]string{s.Database, s.DBName, t.DBName}, columnValues(c)...)
+				if err := cw.Write(row); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
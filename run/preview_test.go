@@ -2,7 +2,7 @@ package run
 
 import (
 	"bytes"
-	"log"
+	"context"
 	"testing"
 	"text/template"
 
@@ -47,7 +47,7 @@ func TestMakeTable(t *testing.T) {
 
 type dummyDriver struct{}
 
-func (dummyDriver) Parse(log *log.Logger, conn string, schemaNames []string, filterTables func(schema, table string) bool) (*database.Info, error) {
+func (dummyDriver) Parse(ctx context.Context, log environ.Logger, conn string, schemaNames []string, filterTables func(schema, table string) bool) (*database.Info, error) {
 	return &database.Info{
 		Schemas: []*database.Schema{{
 			Name: "schema",
@@ -135,9 +135,12 @@ const expectYaml = `schemas:
       dbtype: int
       isarray: false
       length: 0
+      precision: 0
+      scale: 0
       userdefined: false
       nullable: false
       hasdefault: false
+      default: ""
       comment: first column
       isprimarykey: true
       ordinal: 123456
@@ -154,9 +157,12 @@ const expectYaml = `schemas:
       dbtype: '*int'
       isarray: false
       length: 0
+      precision: 0
+      scale: 0
       userdefined: false
       nullable: true
       hasdefault: false
+      default: ""
       comment: ""
       isprimarykey: false
       ordinal: 0
@@ -170,9 +176,12 @@ const expectYaml = `schemas:
       dbtype: string
       isarray: false
       length: 0
+      precision: 0
+      scale: 0
       userdefined: false
       nullable: false
       hasdefault: false
+      default: ""
       comment: ""
       isprimarykey: false
       ordinal: 0
@@ -186,9 +195,12 @@ const expectYaml = `schemas:
       dbtype: '*string'
       isarray: false
       length: 0
+      precision: 0
+      scale: 0
       userdefined: false
       nullable: true
       hasdefault: false
+      default: ""
       comment: ""
       isprimarykey: false
       ordinal: 0
@@ -203,9 +215,12 @@ const expectYaml = `schemas:
       dbtype: int
       isarray: false
       length: 0
+      precision: 0
+      scale: 0
       userdefined: false
       nullable: false
       hasdefault: false
+      default: ""
       comment: first column
       isprimarykey: true
       ordinal: 123456
@@ -227,9 +242,12 @@ const expectYaml = `schemas:
         dbtype: int
         isarray: false
         length: 0
+        precision: 0
+        scale: 0
         userdefined: false
         nullable: false
         hasdefault: false
+        default: ""
         comment: first column
         isprimarykey: true
         ordinal: 123456
@@ -263,9 +281,12 @@ const expectYaml = `schemas:
       dbtype: int
       isarray: false
       length: 0
+      precision: 0
+      scale: 0
       userdefined: false
       nullable: false
       hasdefault: false
+      default: ""
       comment: ""
       isprimarykey: true
       ordinal: 0
@@ -279,9 +300,12 @@ const expectYaml = `schemas:
       dbtype: int
       isarray: false
       length: 0
+      precision: 0
+      scale: 0
       userdefined: false
       nullable: false
       hasdefault: false
+      default: ""
       comment: ""
       isprimarykey: false
       ordinal: 0
@@ -299,9 +323,12 @@ const expectYaml = `schemas:
       dbtype: int
       isarray: false
       length: 0
+      precision: 0
+      scale: 0
       userdefined: false
       nullable: false
       hasdefault: false
+      default: ""
       comment: ""
       isprimarykey: true
       ordinal: 0
@@ -373,10 +400,8 @@ Indexes:
 
 func TestPreviewYAML(t *testing.T) {
 	var out bytes.Buffer
-	var errOut bytes.Buffer
 	env := environ.Values{
 		Stdout: &out,
-		Log:    log.New(&errOut, "test: ", log.Lshortfile),
 	}
 
 	cfg := &Config{
@@ -392,7 +417,7 @@ func TestPreviewYAML(t *testing.T) {
 		Driver: dummyDriver{},
 	}
 	// with yaml
-	if err := Preview(env, cfg, PreviewYAML); err != nil {
+	if err := Preview(context.Background(), env, cfg, PreviewYAML, DDLStandard); err != nil {
 		t.Fatal(err)
 	}
 	v := out.String()
@@ -423,9 +448,12 @@ var expectJSON = `
               "DBType": "int",
               "IsArray": false,
               "Length": 0,
+              "Precision": 0,
+              "Scale": 0,
               "UserDefined": false,
               "Nullable": false,
               "HasDefault": false,
+              "Default": "",
               "Comment": "first column",
               "IsPrimaryKey": true,
               "Ordinal": 123456,
@@ -447,9 +475,12 @@ var expectJSON = `
               "DBType": "*int",
               "IsArray": false,
               "Length": 0,
+              "Precision": 0,
+              "Scale": 0,
               "UserDefined": false,
               "Nullable": true,
               "HasDefault": false,
+              "Default": "",
               "Comment": "",
               "IsPrimaryKey": false,
               "Ordinal": 0,
@@ -465,9 +496,12 @@ var expectJSON = `
               "DBType": "string",
               "IsArray": false,
               "Length": 0,
+              "Precision": 0,
+              "Scale": 0,
               "UserDefined": false,
               "Nullable": false,
               "HasDefault": false,
+              "Default": "",
               "Comment": "",
               "IsPrimaryKey": false,
               "Ordinal": 0,
@@ -483,9 +517,12 @@ var expectJSON = `
               "DBType": "*string",
               "IsArray": false,
               "Length": 0,
+              "Precision": 0,
+              "Scale": 0,
               "UserDefined": false,
               "Nullable": true,
               "HasDefault": false,
+              "Default": "",
               "Comment": "",
               "IsPrimaryKey": false,
               "Ordinal": 0,
@@ -503,9 +540,12 @@ var expectJSON = `
               "DBType": "int",
               "IsArray": false,
               "Length": 0,
+              "Precision": 0,
+              "Scale": 0,
               "UserDefined": false,
               "Nullable": false,
               "HasDefault": false,
+              "Default": "",
               "Comment": "first column",
               "IsPrimaryKey": true,
               "Ordinal": 123456,
@@ -534,9 +574,12 @@ var expectJSON = `
                   "DBType": "int",
                   "IsArray": false,
                   "Length": 0,
+                  "Precision": 0,
+                  "Scale": 0,
                   "UserDefined": false,
                   "Nullable": false,
                   "HasDefault": false,
+                  "Default": "",
                   "Comment": "first column",
                   "IsPrimaryKey": true,
                   "Ordinal": 123456,
@@ -586,9 +629,12 @@ var expectJSON = `
               "DBType": "int",
               "IsArray": false,
               "Length": 0,
+              "Precision": 0,
+              "Scale": 0,
               "UserDefined": false,
               "Nullable": false,
               "HasDefault": false,
+              "Default": "",
               "Comment": "",
               "IsPrimaryKey": true,
               "Ordinal": 0,
@@ -604,9 +650,12 @@ var expectJSON = `
               "DBType": "int",
               "IsArray": false,
               "Length": 0,
+              "Precision": 0,
+              "Scale": 0,
               "UserDefined": false,
               "Nullable": false,
               "HasDefault": false,
+              "Default": "",
               "Comment": "",
               "IsPrimaryKey": false,
               "Ordinal": 0,
@@ -628,9 +677,12 @@ var expectJSON = `
               "DBType": "int",
               "IsArray": false,
               "Length": 0,
+              "Precision": 0,
+              "Scale": 0,
               "UserDefined": false,
               "Nullable": false,
               "HasDefault": false,
+              "Default": "",
               "Comment": "",
               "IsPrimaryKey": true,
               "Ordinal": 0,
@@ -678,10 +730,8 @@ var expectJSON = `
 
 func TestPreviewJSON(t *testing.T) {
 	var out bytes.Buffer
-	var errOut bytes.Buffer
 	env := environ.Values{
 		Stdout: &out,
-		Log:    log.New(&errOut, "test: ", log.Lshortfile),
 	}
 
 	cfg := &Config{
@@ -697,7 +747,7 @@ func TestPreviewJSON(t *testing.T) {
 		Driver: dummyDriver{},
 	}
 	// with json
-	if err := Preview(env, cfg, PreviewJSON); err != nil {
+	if err := Preview(context.Background(), env, cfg, PreviewJSON, DDLStandard); err != nil {
 		t.Fatal(err)
 	}
 	v := out.String()
@@ -708,10 +758,8 @@ func TestPreviewJSON(t *testing.T) {
 
 func TestPreviewTabular(t *testing.T) {
 	var out bytes.Buffer
-	var errOut bytes.Buffer
 	env := environ.Values{
 		Stdout: &out,
-		Log:    log.New(&errOut, "test: ", log.Lshortfile),
 	}
 
 	cfg := &Config{
@@ -728,7 +776,7 @@ func TestPreviewTabular(t *testing.T) {
 	}
 
 	// tabular
-	if err := Preview(env, cfg, PreviewTabular); err != nil {
+	if err := Preview(context.Background(), env, cfg, PreviewTabular, DDLStandard); err != nil {
 		t.Fatal(err)
 	}
 
@@ -750,10 +798,8 @@ var typesOut = `
 
 func TestPreviewTypes(t *testing.T) {
 	var out bytes.Buffer
-	var errOut bytes.Buffer
 	env := environ.Values{
 		Stdout: &out,
-		Log:    log.New(&errOut, "test: ", log.Lshortfile),
 	}
 
 	cfg := &Config{
@@ -768,7 +814,7 @@ func TestPreviewTypes(t *testing.T) {
 		},
 		Driver: dummyDriver{},
 	}
-	if err := Preview(env, cfg, PreviewTypes); err != nil {
+	if err := Preview(context.Background(), env, cfg, PreviewTypes, DDLStandard); err != nil {
 		t.Fatal(err)
 	}
 	v := out.String()
@@ -776,3 +822,197 @@ func TestPreviewTypes(t *testing.T) {
 		t.Errorf("expected %s got %s", typesOut, v)
 	}
 }
+
+func previewConfig() *Config {
+	return &Config{
+		NameConversion: template.Must(template.New("").Funcs(environ.FuncMap).Parse(`{{print "abc " .}}`)),
+		ConfigData: data.ConfigData{
+			NullableTypeMap: map[string]string{
+				"*int": "*INTEGER",
+			},
+			TypeMap: map[string]string{
+				"int": "INTEGER",
+			},
+		},
+		Driver: dummyDriver{},
+	}
+}
+
+const expectMarkdown = `# Schema: abc schema (schema)
+
+## Enum: abc enum (schema.enum)
+
+| Name | DBName | Value |
+| --- | --- | --- |
+| abc enumvalue | enumvalue | 0 |
+
+## Table: abc table (schema.table)
+
+a table
+
+| Name | DBName | Type | DBType | IsArray | IsPrimaryKey | Ordinal | IsFK | HasFKRef | Length | UserDefined | Nullable | HasDefault | Comment |
+| --- | --- | --- | --- | --- | --- | --- | --- | --- | --- | --- | --- | --- | --- |
+| abc col1 | col1 | INTEGER | int | false | true | 123456 | false | true | 0 | false | false | false | first column |
+| abc col2 | col2 | *INTEGER | *int | false | false | 0 | false | false | 0 | false | true | false |  |
+| abc col3 | col3 |  | string | false | false | 0 | false | false | 0 | false | false | false |  |
+| abc col4 | col4 |  | *string | false | false | 0 | false | false | 0 | false | true | false |  |
+
+### Indexes
+
+| Name | DBName | IsUnique | Columns |
+| --- | --- | --- | --- |
+| abc col1_pkey | col1_pkey | true | abc col1 |
+
+## Table: abc tb2 (schema.tb2)
+
+| Name | DBName | Type | DBType | IsArray | IsPrimaryKey | Ordinal | IsFK | HasFKRef | Length | UserDefined | Nullable | HasDefault | Comment |
+| --- | --- | --- | --- | --- | --- | --- | --- | --- | --- | --- | --- | --- | --- |
+| abc col1 | col1 | INTEGER | int | false | true | 0 | false | false | 0 | false | false | false |  |
+| abc col2 | col2 | INTEGER | int | false | false | 0 | true | false | 0 | false | false | false |  |
+
+`
+
+func TestPreviewMarkdown(t *testing.T) {
+	var out bytes.Buffer
+	env := environ.Values{
+		Stdout: &out,
+	}
+	if err := Preview(context.Background(), env, previewConfig(), PreviewMarkdown, DDLStandard); err != nil {
+		t.Fatal(err)
+	}
+	if v := out.String(); v != expectMarkdown {
+		t.Errorf(diff.LineDiff(expectMarkdown, v))
+	}
+}
+
+const expectCSV = `Database,Schema,Table,Name,DBName,Type,DBType,IsArray,IsPrimaryKey,Ordinal,IsFK,HasFKRef,Length,UserDefined,Nullable,HasDefault,Comment
+,schema,table,abc col1,col1,INTEGER,int,false,true,123456,false,true,0,false,false,false,first column
+,schema,table,abc col2,col2,*INTEGER,*int,false,false,0,false,false,0,false,true,false,
+,schema,table,abc col3,col3,,string,false,false,0,false,false,0,false,false,false,
+,schema,table,abc col4,col4,,*string,false,false,0,false,false,0,false,true,false,
+,schema,tb2,abc col1,col1,INTEGER,int,false,true,0,false,false,0,false,false,false,
+,schema,tb2,abc col2,col2,INTEGER,int,false,false,0,true,false,0,false,false,false,
+`
+
+func TestPreviewCSV(t *testing.T) {
+	var out bytes.Buffer
+	env := environ.Values{
+		Stdout: &out,
+	}
+	if err := Preview(context.Background(), env, previewConfig(), PreviewCSV, DDLStandard); err != nil {
+		t.Fatal(err)
+	}
+	if v := out.String(); v != expectCSV {
+		t.Errorf(diff.LineDiff(expectCSV, v))
+	}
+}
+
+const expectSQL = `CREATE TYPE "schema"."enum" AS ENUM ('enumvalue');
+
+CREATE TABLE "schema"."table" (
+  "col1" int NOT NULL,
+  "col2" *int,
+  "col3" string NOT NULL,
+  "col4" *string,
+  PRIMARY KEY ("col1")
+);
+COMMENT ON TABLE "schema"."table" IS 'a table';
+COMMENT ON COLUMN "schema"."table"."col1" IS 'first column';
+
+-- VIEW "schema"."tb2" ("col1" int, "col2" int)
+`
+
+func TestPreviewSQL(t *testing.T) {
+	var out bytes.Buffer
+	env := environ.Values{
+		Stdout: &out,
+	}
+	if err := Preview(context.Background(), env, previewConfig(), PreviewSQL, DDLPostgres); err != nil {
+		t.Fatal(err)
+	}
+	if v := out.String(); v != expectSQL {
+		t.Errorf(diff.LineDiff(expectSQL, v))
+	}
+}
+
+func TestPreviewSQLMatchesExport(t *testing.T) {
+	for _, dialect := range []DDLDialect{DDLStandard, DDLPostgres, DDLMySQL} {
+		var preview, export bytes.Buffer
+		if err := Preview(context.Background(), environ.Values{Stdout: &preview}, previewConfig(), PreviewSQL, dialect); err != nil {
+			t.Fatal(err)
+		}
+		if err := Export(context.Background(), environ.Values{Stdout: &export}, previewConfig(), ExportDDL, dialect); err != nil {
+			t.Fatal(err)
+		}
+		if preview.String() != export.String() {
+			t.Errorf("dialect %v: preview differs from export:\n%s", dialect, diff.LineDiff(export.String(), preview.String()))
+		}
+	}
+}
+
+func TestDDLIdent(t *testing.T) {
+	for name, expected := range map[string]string{
+		"users":     `"users"`,
+		"CamelCase": `"CamelCase"`,
+		`a"b`:       `"a""b"`,
+	} {
+		if s := ddlIdent(DDLStandard, name); s != expected {
+			t.Errorf("expected %q to be %s, but got %s", name, expected, s)
+		}
+	}
+	if s, expected := ddlIdent(DDLMySQL, "a`b"), "`a``b`"; s != expected {
+		t.Errorf("expected mysql identifier %s, but got %s", expected, s)
+	}
+}
+
+// reversedDriver returns the same schema as dummyDriver, with its tables,
+// enums, and indexes in reverse order, the way a database might return them.
+type reversedDriver struct {
+	dummyDriver
+}
+
+func (d reversedDriver) Parse(ctx context.Context, log environ.Logger, conn string, schemaNames []string, filterTables func(schema, table string) bool) (*database.Info, error) {
+	info, err := d.dummyDriver.Parse(ctx, log, conn, schemaNames, filterTables)
+	if err != nil {
+		return nil, err
+	}
+	s := info.Schemas[0]
+	s.Tables[0], s.Tables[1] = s.Tables[1], s.Tables[0]
+	s.Enums = append(s.Enums, &database.Enum{Name: "another"})
+	for _, t := range s.Tables {
+		t.Indexes = append(t.Indexes, &database.Index{Name: "a_index", Columns: t.Columns[:1]})
+	}
+	return info, nil
+}
+
+func TestPreviewStableOrder(t *testing.T) {
+	var out bytes.Buffer
+	env := environ.Values{
+		Stdout: &out,
+	}
+	cfg := previewConfig()
+	cfg.Driver = reversedDriver{}
+	if err := Preview(context.Background(), env, cfg, PreviewSQL, DDLStandard); err != nil {
+		t.Fatal(err)
+	}
+	expected := `CREATE TYPE "schema"."another" AS ENUM ();
+
+CREATE TYPE "schema"."enum" AS ENUM ('enumvalue');
+
+-- a table
+CREATE TABLE "schema"."table" (
+  -- first column
+  "col1" int NOT NULL,
+  "col2" *int,
+  "col3" string NOT NULL,
+  "col4" *string,
+  PRIMARY KEY ("col1")
+);
+CREATE INDEX "a_index" ON "schema"."table" ("col1");
+
+-- VIEW "schema"."tb2" ("col1" int, "col2" int)
+`
+	if v := out.String(); v != expected {
+		t.Errorf(diff.LineDiff(expected, v))
+	}
+}
@@ -0,0 +1,139 @@
+package run
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"gnorm.org/gnorm/database"
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run/data"
+)
+
+// progressInterval is how often the progress of a step is logged.
+var progressInterval = 5 * time.Second
+
+// progress logs how far along a step of a run is at info level, every
+// progressInterval, so that runs against large databases aren't silent for
+// minutes.  Steps that finish within the first interval log nothing.  A nil
+// *progress does nothing.
+type progress struct {
+	env   environ.Values
+	step  string
+	start time.Time
+	stop  chan struct{}
+	done  chan struct{}
+
+	mu     sync.Mutex
+	count  int
+	total  int
+	logged bool
+}
+
+// startProgress starts logging the progress of the step, which is done once
+// total things have been added.  If total isn't known, it may be 0, and grown
+// as it becomes known.
+func startProgress(env environ.Values, step string, total int) *progress {
+	p := &progress{
+		env:   env,
+		step:  step,
+		start: time.Now(),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+		total: total,
+	}
+	go p.run()
+	return p
+}
+
+func (p *progress) run() {
+	defer close(p.done)
+	t := time.NewTicker(progressInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-t.C:
+			p.mu.Lock()
+			p.logged = true
+			p.env.Logger().Infof("%s: %s", p.step, p.message())
+			p.mu.Unlock()
+		}
+	}
+}
+
+// add records that n more things are done.
+func (p *progress) add(n int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.count += n
+}
+
+// grow adds n to the number of things to do.
+func (p *progress) grow(n int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total += n
+}
+
+// message describes the progress so far.  p.mu must be held.
+func (p *progress) message() string {
+	elapsed := time.Since(p.start).Round(time.Second)
+	if p.total == 0 {
+		return fmt.Sprintf("%v elapsed", elapsed)
+	}
+	return fmt.Sprintf("%d of %d (%d%%), %v elapsed", p.count, p.total, p.count*100/p.total, elapsed)
+}
+
+// finish stops logging progress, and logs that the step is done if any
+// progress was logged for it.
+func (p *progress) finish() {
+	if p == nil {
+		return
+	}
+	close(p.stop)
+	<-p.done
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.logged {
+		p.env.Logger().Infof("%s: done, %s", p.step, p.message())
+	}
+}
+
+// countTargets returns the number of files generateFiles renders for db.
+func countTargets(r *genRun, db *data.DBData) int {
+	n := 0
+	for _, s := range db.Schemas {
+		if !pickedSchema(r.Config, s) {
+			continue
+		}
+		if len(r.OnlyTables) == 0 {
+			n += len(r.SchemaPaths) + len(s.Enums)*len(r.EnumPaths)
+		}
+		for _, t := range s.Tables {
+			if pickedTable(r, s, t) {
+				n += len(r.TablePaths)
+			}
+		}
+	}
+	if !partial(r.Config) {
+		n += len(r.DBPaths)
+	}
+	return n
+}
+
+// countTables returns the number of tables in all the schemas in info.
+func countTables(info *database.Info) int {
+	n := 0
+	for _, s := range info.Schemas {
+		n += len(s.Tables)
+	}
+	return n
+}
@@ -0,0 +1,73 @@
+package run
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run/data"
+)
+
+func TestProgress(t *testing.T) {
+	defer func(d time.Duration) { progressInterval = d }(progressInterval)
+	progressInterval = time.Millisecond
+
+	buf := &bytes.Buffer{}
+	env := environ.Values{Log: environ.NewLogger(buf, environ.LevelInfo, environ.LogText)}
+	p := startProgress(env, "generating files", 4)
+	p.add(1)
+	time.Sleep(20 * time.Millisecond)
+	p.add(3)
+	p.finish()
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if first := lines[0]; !strings.HasPrefix(first, "generating files: 1 of 4 (25%), ") {
+		t.Errorf("expected progress to be logged, but got %q", first)
+	}
+	if last := lines[len(lines)-1]; !strings.HasPrefix(last, "generating files: done, 4 of 4 (100%), ") {
+		t.Errorf("expected the step to be logged as done, but got %q", last)
+	}
+
+	// steps that finish before the first interval log nothing.
+	progressInterval = time.Hour
+	buf.Reset()
+	p = startProgress(env, "generating files", 4)
+	p.add(4)
+	p.finish()
+	if buf.Len() != 0 {
+		t.Errorf("expected nothing to be logged, but got %q", buf.String())
+	}
+}
+
+func TestCountTargets(t *testing.T) {
+	target := OutputTarget{}
+	cfg := &Config{
+		SchemaPaths: []OutputTarget{target},
+		EnumPaths:   []OutputTarget{target},
+		TablePaths:  []OutputTarget{target, target},
+		DBPaths:     []OutputTarget{target},
+	}
+	db := &data.DBData{Schemas: []*data.Schema{
+		{DBName: "public", Tables: data.Tables{{DBName: "table"}, {DBName: "other"}}, Enums: data.Enums{{}}},
+		{DBName: "app", Tables: data.Tables{{DBName: "table"}}},
+	}}
+	r := &genRun{Config: cfg}
+	// 2 schemas, 1 enum, 3 tables with 2 targets each, and the database.
+	if n := countTargets(r, db); n != 10 {
+		t.Errorf("expected 10 targets, but got %d", n)
+	}
+	// only the 2 tables named table.
+	cfg.OnlyTables = []string{"table"}
+	r.only, _ = onlyFilter(cfg)
+	if n := countTargets(r, db); n != 4 {
+		t.Errorf("expected 4 targets with OnlyTables, but got %d", n)
+	}
+	// the schema and its enum and tables.
+	cfg.OnlyTables = nil
+	cfg.OnlySchemas = []string{"public"}
+	r.only, _ = onlyFilter(cfg)
+	if n := countTargets(r, db); n != 6 {
+		t.Errorf("expected 6 targets with OnlySchemas, but got %d", n)
+	}
+}
@@ -0,0 +1,242 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/codemodus/kace"
+	"github.com/pkg/errors"
+
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run/data"
+)
+
+// ProtoOptions configures the header of the file that Proto writes.
+type ProtoOptions struct {
+	// Package is the protobuf package of the messages, if set.
+	Package string
+
+	// Options are the file options, such as go_package, in the order they're
+	// written.
+	Options []ProtoOption
+}
+
+// ProtoOption is a file option of a .proto file.  The Value is written as a
+// string, unless it is true, false, a number, or an upper case identifier,
+// such as the SPEED of optimize_for.
+type ProtoOption struct {
+	Name, Value string
+}
+
+// ParseProtoOption parses an option written as name=value.
+func ParseProtoOption(s string) (ProtoOption, error) {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+		return ProtoOption{}, errors.Errorf("proto option %q should be name=value", s)
+	}
+	return ProtoOption{Name: strings.TrimSpace(parts[0]), Value: strings.TrimSpace(parts[1])}, nil
+}
+
+// Proto reads the database the same way Generate does, with the same filters,
+// and writes a proto3 file to env.Stdout with a message for each table and an
+// enum for each enum.  Columns become fields named by their DBName in snake
+// case, numbered as described in protoNumbers.  Nullable columns are optional,
+// and array columns repeated.
+func Proto(ctx context.Context, env environ.Values, cfg *Config, opts ProtoOptions) error {
+	info, err := parseDB(ctx, env, cfg)
+	if err != nil {
+		return err
+	}
+	db, err := makeData(ctx, env, info, cfg)
+	if err != nil {
+		return err
+	}
+	buf := &bytes.Buffer{}
+	writeProto(buf, db, opts)
+	_, err = env.Stdout.Write(buf.Bytes())
+	return err
+}
+
+// protoImports maps the well known types that columns may use to the files
+// that define them.
+var protoImports = map[string]string{
+	"google.protobuf.Timestamp": "google/protobuf/timestamp.proto",
+	"google.protobuf.Value":     "google/protobuf/struct.proto",
+}
+
+func writeProto(buf *bytes.Buffer, db *data.DBData, opts ProtoOptions) {
+	prefix := func(s *data.Schema) string {
+		if len(db.Schemas) > 1 {
+			return s.Name
+		}
+		return ""
+	}
+	messageName := func(t *data.Table) string {
+		return graphqlName(prefix(t.Schema) + t.Name)
+	}
+	enumName := func(e *data.Enum) string {
+		if e.Table != nil && e.Table.DBName != "" {
+			return graphqlName(prefix(e.Schema) + e.Table.Name + e.Name)
+		}
+		return graphqlName(prefix(e.Schema) + e.Name)
+	}
+
+	body := &bytes.Buffer{}
+	imports := map[string]bool{}
+	for _, s := range db.Schemas {
+		for _, e := range s.Enums {
+			name := enumName(e)
+			valuePrefix := graphqlName(kace.SnakeUpper(name)) + "_"
+			fmt.Fprintf(body, "\nenum %s {\n", name)
+			// proto3 enums must start with a zero value, which is what
+			// fields that aren't set read as.
+			fmt.Fprintf(body, "  %sUNSPECIFIED = 0;\n", valuePrefix)
+			keys := make([]int64, len(e.Values))
+			for x, v := range e.Values {
+				keys[x] = int64(v.Value)
+			}
+			nums := protoNumbers(keys)
+			for x, v := range e.Values {
+				fmt.Fprintf(body, "  %s%s = %d;\n", valuePrefix, graphqlName(kace.SnakeUpper(v.DBName)), nums[x])
+			}
+			body.WriteString("}\n")
+		}
+	}
+	for _, s := range db.Schemas {
+		for _, t := range s.Tables {
+			body.WriteString("\n")
+			writeProtoComment(body, "", t.Comment)
+			fmt.Fprintf(body, "message %s {\n", messageName(t))
+			keys := make([]int64, len(t.Columns))
+			for x, c := range t.Columns {
+				keys[x] = c.Ordinal
+			}
+			nums := protoNumbers(keys)
+			for x, c := range t.Columns {
+				var typ string
+				if e := columnEnum(c); e != nil {
+					typ = enumName(e)
+				} else {
+					typ = protoType(c)
+					if file, ok := protoImports[typ]; ok {
+						imports[file] = true
+					}
+				}
+				switch {
+				case c.IsArray:
+					typ = "repeated " + typ
+				case c.Nullable && protoImports[typ] == "":
+					// messages already tell whether they're set.
+					typ = "optional " + typ
+				}
+				writeProtoComment(body, "  ", c.Comment)
+				fmt.Fprintf(body, "  %s %s = %d;\n", typ, graphqlName(kace.Snake(c.DBName)), nums[x])
+			}
+			body.WriteString("}\n")
+		}
+	}
+
+	buf.WriteString("syntax = \"proto3\";\n")
+	if opts.Package != "" {
+		fmt.Fprintf(buf, "\npackage %s;\n", opts.Package)
+	}
+	if len(imports) > 0 {
+		var files []string
+		for file := range imports {
+			files = append(files, file)
+		}
+		sort.Strings(files)
+		buf.WriteString("\n")
+		for _, file := range files {
+			fmt.Fprintf(buf, "import %q;\n", file)
+		}
+	}
+	if len(opts.Options) > 0 {
+		buf.WriteString("\n")
+		for _, o := range opts.Options {
+			fmt.Fprintf(buf, "option %s = %s;\n", o.Name, protoValue(o.Value))
+		}
+	}
+	buf.Write(body.Bytes())
+}
+
+// protoNumbers returns the field numbers of a message's fields, or the numbers
+// of an enum's values, given the columns' Ordinals or the values' Values as
+// keys.  The keys are used as they are if they're all distinct valid numbers,
+// so that dropping a column or adding an enum value doesn't renumber the rest,
+// which would break messages encoded before.  Otherwise, such as for drivers
+// that don't set them, or postgres enum values added before others, which get
+// fractional sort orders, they're numbered in order, starting at 1.
+func protoNumbers(keys []int64) []int64 {
+	nums := make([]int64, len(keys))
+	seen := make(map[int64]bool, len(keys))
+	for x, k := range keys {
+		// 19000 to 19999 are reserved by protobuf itself.
+		if k < 1 || k > 536870911 || (k >= 19000 && k <= 19999) || seen[k] {
+			for x := range nums {
+				nums[x] = int64(x + 1)
+			}
+			return nums
+		}
+		seen[k] = true
+		nums[x] = k
+	}
+	return nums
+}
+
+// protoType returns the protobuf type for values of the column's database
+// type, not counting arrays.  Decimals are strings so that they don't lose
+// precision, and types gnorm doesn't know are google.protobuf.Values, which
+// can hold any JSON value.
+func protoType(c *data.Column) string {
+	s := openAPIType(c)
+	switch {
+	case s.Format == "int32":
+		return "int32"
+	case s.Format == "int64":
+		return "int64"
+	case s.Format == "float":
+		return "float"
+	case s.Format == "double":
+		return "double"
+	case s.Type == "boolean":
+		return "bool"
+	case s.Format == "date-time":
+		return "google.protobuf.Timestamp"
+	case s.Format == "byte":
+		return "bytes"
+	case s.Type == "string", s.Type == "number":
+		return "string"
+	}
+	return "google.protobuf.Value"
+}
+
+// upperIdent matches identifiers in upper case, such as enum values.
+var upperIdent = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// protoValue returns the value of an option as it's written in a .proto file.
+func protoValue(v string) string {
+	if v == "true" || v == "false" || upperIdent.MatchString(v) {
+		return v
+	}
+	if _, err := strconv.ParseFloat(v, 64); err == nil {
+		return v
+	}
+	return strconv.Quote(v)
+}
+
+// writeProtoComment writes the comment, if there is one, as line comments
+// before what it describes.
+func writeProtoComment(buf *bytes.Buffer, indent, comment string) {
+	if comment == "" {
+		return
+	}
+	for _, line := range strings.Split(comment, "\n") {
+		fmt.Fprintf(buf, "%s// %s\n", indent, strings.TrimRight(line, " \t\r"))
+	}
+}
@@ -0,0 +1,194 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"text/template"
+
+	"github.com/andreyvit/diff"
+
+	"gnorm.org/gnorm/database"
+	"gnorm.org/gnorm/environ"
+)
+
+func TestProto(t *testing.T) {
+	cfg := &Config{
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver:         dummyDriver{},
+	}
+	stdout := &bytes.Buffer{}
+	env := environ.Values{Stdout: stdout}
+	if err := Proto(context.Background(), env, cfg, ProtoOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expected := `
+syntax = "proto3";
+
+import "google/protobuf/struct.proto";
+
+enum enum {
+  ENUM_UNSPECIFIED = 0;
+  ENUM_ENUMVALUE = 1;
+}
+
+// a table
+message table {
+  // first column
+  int32 col1 = 1;
+  google.protobuf.Value col2 = 2;
+  google.protobuf.Value col3 = 3;
+  google.protobuf.Value col4 = 4;
+}
+
+message tb2 {
+  int32 col1 = 1;
+  int32 col2 = 2;
+}
+`[1:]
+	if stdout.String() != expected {
+		t.Fatalf("unexpected proto:\n%s", diff.LineDiff(expected, stdout.String()))
+	}
+}
+
+func TestProtoTypes(t *testing.T) {
+	cfg := &Config{NameConversion: template.Must(template.New("").Funcs(environ.FuncMap).Parse(`{{pascal .}}`))}
+	info := &database.Info{Schemas: []*database.Schema{{
+		Name:  "public",
+		Enums: []*database.Enum{{Name: "book_type", Values: []*database.EnumValue{{Name: "fiction", Value: 1}, {Name: "non-fiction", Value: 2}}}},
+		Tables: []*database.Table{{
+			Name:    "books",
+			Comment: "books in the library",
+			Columns: []*database.Column{
+				{Name: "id", Type: "uuid"},
+				{Name: "pages", Type: "integer", Nullable: true, Comment: "may be unknown"},
+				{Name: "price", Type: "numeric"},
+				{Name: "type", Type: "book_type", UserDefined: true, Nullable: true},
+				{Name: "tags", Type: "text", IsArray: true},
+				{Name: "published", Type: "timestamp with time zone", Nullable: true},
+				{Name: "cover", Type: "bytea"},
+				{Name: "extra", Type: "jsonb"},
+			},
+		}},
+	}}}
+	db, err := makeData(context.Background(), environ.Values{}, info, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var opts ProtoOptions
+	opts.Package = "library.v1"
+	for _, s := range []string{"go_package=example.com/app/pb", "optimize_for = SPEED", "java_multiple_files=true"} {
+		o, err := ParseProtoOption(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts.Options = append(opts.Options, o)
+	}
+	if _, err := ParseProtoOption("go_package"); err == nil {
+		t.Fatal("expected an error for an option without a value")
+	}
+	buf := &bytes.Buffer{}
+	writeProto(buf, db, opts)
+	expected := `
+syntax = "proto3";
+
+package library.v1;
+
+import "google/protobuf/struct.proto";
+import "google/protobuf/timestamp.proto";
+
+option go_package = "example.com/app/pb";
+option optimize_for = SPEED;
+option java_multiple_files = true;
+
+enum BookType {
+  BOOK_TYPE_UNSPECIFIED = 0;
+  BOOK_TYPE_FICTION = 1;
+  BOOK_TYPE_NON_FICTION = 2;
+}
+
+// books in the library
+message Books {
+  string id = 1;
+  // may be unknown
+  optional int32 pages = 2;
+  string price = 3;
+  optional BookType type = 4;
+  repeated string tags = 5;
+  google.protobuf.Timestamp published = 6;
+  bytes cover = 7;
+  google.protobuf.Value extra = 8;
+}
+`[1:]
+	if buf.String() != expected {
+		t.Fatalf("unexpected proto:\n%s", diff.LineDiff(expected, buf.String()))
+	}
+}
+
+func TestProtoNumbersStable(t *testing.T) {
+	cfg := &Config{NameConversion: template.Must(template.New("").Parse(`{{.}}`))}
+	proto := func(columns []*database.Column, values []*database.EnumValue) string {
+		info := &database.Info{Schemas: []*database.Schema{{
+			Name:   "public",
+			Enums:  []*database.Enum{{Name: "mood", Values: values}},
+			Tables: []*database.Table{{Name: "users", Columns: columns}},
+		}}}
+		db, err := makeData(context.Background(), environ.Values{}, info, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := &bytes.Buffer{}
+		writeProto(buf, db, ProtoOptions{})
+		return buf.String()
+	}
+
+	// nickname was dropped, and ok added after happy, so the other numbers stay
+	// the same.
+	actual := proto([]*database.Column{
+		{Name: "id", Type: "integer", Ordinal: 1},
+		{Name: "email", Type: "text", Ordinal: 3},
+	}, []*database.EnumValue{{Name: "sad", Value: 1}, {Name: "happy", Value: 2}, {Name: "ok", Value: 3}})
+	expected := `
+syntax = "proto3";
+
+enum mood {
+  MOOD_UNSPECIFIED = 0;
+  MOOD_SAD = 1;
+  MOOD_HAPPY = 2;
+  MOOD_OK = 3;
+}
+
+message users {
+  int32 id = 1;
+  string email = 3;
+}
+`[1:]
+	if actual != expected {
+		t.Fatalf("unexpected proto:\n%s", diff.LineDiff(expected, actual))
+	}
+
+	// without ordinals, and with values that aren't distinct, they're numbered
+	// in order.
+	actual = proto([]*database.Column{
+		{Name: "id", Type: "integer"},
+		{Name: "email", Type: "text"},
+	}, []*database.EnumValue{{Name: "sad", Value: 1}, {Name: "meh", Value: 1}, {Name: "happy", Value: 2}})
+	expected = `
+syntax = "proto3";
+
+enum mood {
+  MOOD_UNSPECIFIED = 0;
+  MOOD_SAD = 1;
+  MOOD_MEH = 2;
+  MOOD_HAPPY = 3;
+}
+
+message users {
+  int32 id = 1;
+  string email = 2;
+}
+`[1:]
+	if actual != expected {
+		t.Fatalf("unexpected proto:\n%s", diff.LineDiff(expected, actual))
+	}
+}
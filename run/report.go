@@ -0,0 +1,152 @@
+package run
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Report describes what a run of gen did.  Paths are relative to the output
+// directory.
+type Report struct {
+	// Written are the files that were created or changed.
+	Written []string
+
+	// Unchanged are the files that were left alone, since their output was
+	// the same as their contents.
+	Unchanged []string
+
+	// Skipped are the files that weren't generated because they match
+	// NoOverwriteGlobs and already exist.
+	Skipped []string
+
+	// Static are the files copied from the static directories.
+	Static []string
+
+	// Deleted are the orphaned files deleted by Prune.
+	Deleted []string
+
+	// PostRuns is the number of files PostRun was run on, and PostRunFailed
+	// are the files it failed for, with PostRunWarnOnly set.
+	PostRuns      int
+	PostRunFailed []string
+
+	// Seconds is how long the run took.
+	Seconds float64
+}
+
+// reporter builds the Report of a run.  It's safe to use from multiple
+// goroutines.  A nil *reporter records nothing.
+type reporter struct {
+	mu     sync.Mutex
+	start  time.Time
+	report Report
+}
+
+func newReporter() *reporter {
+	return &reporter{start: time.Now()}
+}
+
+// add appends path to the list l in the report.
+func (r *reporter) add(l *[]string, path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	*l = append(*l, filepath.ToSlash(path))
+}
+
+func (r *reporter) written(path string) {
+	if r != nil {
+		r.add(&r.report.Written, path)
+	}
+}
+
+func (r *reporter) unchanged(path string) {
+	if r != nil {
+		r.add(&r.report.Unchanged, path)
+	}
+}
+
+func (r *reporter) skipped(path string) {
+	if r != nil {
+		r.add(&r.report.Skipped, path)
+	}
+}
+
+func (r *reporter) static(path string) {
+	if r != nil {
+		r.add(&r.report.Static, path)
+	}
+}
+
+func (r *reporter) deleted(path string) {
+	if r != nil {
+		r.add(&r.report.Deleted, path)
+	}
+}
+
+// postRun records that PostRun was run on a file, and whether it failed.
+func (r *reporter) postRun(path string, failed bool) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.report.PostRuns++
+	if failed {
+		r.report.PostRunFailed = append(r.report.PostRunFailed, filepath.ToSlash(path))
+	}
+}
+
+// finish returns the report, with its lists sorted, since files may be
+// generated in any order.
+func (r *reporter) finish() Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rep := r.report
+	for _, l := range [][]string{rep.Written, rep.Unchanged, rep.Skipped, rep.Static, rep.Deleted, rep.PostRunFailed} {
+		sort.Strings(l)
+	}
+	rep.Seconds = time.Since(r.start).Seconds()
+	return rep
+}
+
+// writeReport prints the report of the run to w, as counts followed by the
+// files written, deleted, and failed by PostRun.
+func writeReport(w io.Writer, rep Report) {
+	fmt.Fprintf(w, "written: %d, unchanged: %d, skipped: %d, static: %d, deleted: %d", len(rep.Written), len(rep.Unchanged), len(rep.Skipped), len(rep.Static), len(rep.Deleted))
+	if rep.PostRuns > 0 {
+		fmt.Fprintf(w, ", postrun: %d (%d failed)", rep.PostRuns, len(rep.PostRunFailed))
+	}
+	fmt.Fprintf(w, ", in %.2fs\n", rep.Seconds)
+	for _, l := range []struct {
+		kind  string
+		files []string
+	}{
+		{"written file", rep.Written},
+		{"deleted file", rep.Deleted},
+		{"postrun failed", rep.PostRunFailed},
+	} {
+		for _, f := range l.files {
+			fmt.Fprintf(w, "%s: %s\n", l.kind, f)
+		}
+	}
+}
+
+// saveReport writes the report as JSON to the file at path.
+func saveReport(path string, rep Report) error {
+	b, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return errors.WithMessage(err, "can't convert report to json")
+	}
+	if err := ioutil.WriteFile(path, append(b, '\n'), 0644); err != nil {
+		return errors.WithMessage(err, "can't write report")
+	}
+	return nil
+}
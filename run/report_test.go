@@ -0,0 +1,111 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run/data"
+)
+
+func TestGenerateReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gnorm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	static := filepath.Join(dir, "static")
+	if err := os.MkdirAll(static, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(static, "README"), []byte("readme"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out")
+	if err := os.MkdirAll(out, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(out, "tb2.perm.txt"), []byte("mine"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	reportFile := filepath.Join(dir, "report.json")
+	stdout := &bytes.Buffer{}
+	env := environ.Values{Stdout: stdout, Stderr: &bytes.Buffer{}}
+	cfg := &Config{
+		ConfigData: data.ConfigData{
+			OutputDir:        out,
+			StaticDir:        static,
+			NoOverwriteGlobs: []string{"*.perm.txt"},
+			PostRun:          []string{filepath.Join(dir, "does-not-exist")},
+		},
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver:         dummyDriver{},
+		TablePaths: []OutputTarget{{
+			Filename: template.Must(template.New("").Parse(`{{.Table}}.txt`)),
+			Contents: template.Must(template.New("").Parse(`{{.Table.Name}}`)),
+		}, {
+			Filename: template.Must(template.New("").Parse(`{{.Table}}.perm.txt`)),
+			Contents: template.Must(template.New("").Parse(`{{.Table.Name}}`)),
+		}},
+		PostRunWarnOnly: true,
+		Report:          true,
+		ReportFile:      reportFile,
+	}
+	if err := Generate(context.Background(), env, cfg); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(reportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rep Report
+	if err := json.Unmarshal(b, &rep); err != nil {
+		t.Fatal(err)
+	}
+	rep.Seconds = 0
+	expected := Report{
+		Written:       []string{"table.perm.txt", "table.txt", "tb2.txt"},
+		Skipped:       []string{"tb2.perm.txt"},
+		Static:        []string{"README"},
+		PostRuns:      3,
+		PostRunFailed: []string{"table.perm.txt", "table.txt", "tb2.txt"},
+	}
+	if !reflect.DeepEqual(rep, expected) {
+		t.Fatalf("expected report:\n%#v\nbut got:\n%#v", expected, rep)
+	}
+	for _, s := range []string{
+		"written: 3, unchanged: 0, skipped: 1, static: 1, deleted: 0, postrun: 3 (3 failed), in ",
+		"\nwritten file: table.txt\n",
+		"\npostrun failed: tb2.txt\n",
+	} {
+		if !strings.Contains(stdout.String(), s) {
+			t.Errorf("expected output to contain %q, but got:\n%s", s, stdout)
+		}
+	}
+
+	// the second run renames the files, and prunes the old ones.
+	stdout.Reset()
+	cfg.PostRun = nil
+	cfg.TablePaths[0].Filename = template.Must(template.New("").Parse(`{{.Table}}.go`))
+	cfg.Prune = true
+	cfg.ReportFile = ""
+	if err := Generate(context.Background(), env, cfg); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"written: 2, unchanged: 0, skipped: 2, static: 1, deleted: 2, in ",
+		"\nwritten file: table.go\nwritten file: tb2.go\ndeleted file: table.txt\ndeleted file: tb2.txt\n",
+	} {
+		if !strings.Contains(stdout.String(), s) {
+			t.Errorf("expected output to contain %q, but got:\n%s", s, stdout)
+		}
+	}
+}
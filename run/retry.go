@@ -0,0 +1,37 @@
+package run
+
+import (
+	"context"
+	"time"
+
+	"gnorm.org/gnorm/environ"
+)
+
+// retryDelay is how long to wait before the first retry.  The delay doubles
+// with each retry after that, up to maxRetryDelay.
+var retryDelay = time.Second
+
+const maxRetryDelay = 30 * time.Second
+
+// withRetries calls f, and if it fails, calls it again up to retries more
+// times, backing off between attempts.  It returns the last error from f,
+// without waiting for the next attempt if ctx is done.
+func withRetries(ctx context.Context, env environ.Values, retries int, f func() error) error {
+	delay := retryDelay
+	for attempt := 0; ; attempt++ {
+		err := f()
+		if err == nil || attempt >= retries {
+			return err
+		}
+		env.Logger().Warnf("attempt %v of %v failed, retrying in %v: %v", attempt+1, retries+1, delay, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+		delay *= 2
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package run
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run/data"
+)
+
+// OutputSink receives the files a run generates, instead of them being written
+// to the OutputDir.  It lets programs that embed gnorm capture the output in
+// memory, or send it somewhere other than the local filesystem.
+type OutputSink interface {
+	// WriteFile is called with the path of each generated or static file,
+	// relative to the output directory and with slashes as separators, its
+	// contents, and its permissions.  It may be called concurrently when
+	// Parallel is set.
+	WriteFile(path string, contents []byte, mode os.FileMode) error
+}
+
+// MemorySink is an OutputSink that keeps the files in memory, by path.
+type MemorySink struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+// WriteFile implements OutputSink.
+func (m *MemorySink) WriteFile(path string, contents []byte, mode os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.files == nil {
+		m.files = map[string][]byte{}
+	}
+	m.files[path] = append([]byte(nil), contents...)
+	return nil
+}
+
+// Files returns a copy of the files written to the sink so far, by path.
+func (m *MemorySink) Files() map[string][]byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	files := make(map[string][]byte, len(m.files))
+	for path, b := range m.files {
+		files[path] = b
+	}
+	return files
+}
+
+// DirSink is an OutputSink that writes the files under a directory, as they
+// are, without the manifest, PostRun, or anything else gnorm does when it
+// writes to the OutputDir.
+type DirSink string
+
+// WriteFile implements OutputSink.
+func (d DirSink) WriteFile(path string, contents []byte, mode os.FileMode) error {
+	path = filepath.Join(string(d), filepath.FromSlash(path))
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return errors.WithMessage(err, "error creating output directory")
+	}
+	if err := ioutil.WriteFile(path, contents, mode); err != nil {
+		return errors.Wrapf(err, "error writing generated file %q", path)
+	}
+	return nil
+}
+
+// checkSink returns an error if cfg has an OutputSink along with options that
+// only work when files are written to the OutputDir.
+func checkSink(cfg *Config) error {
+	if cfg.Sink == nil || cfg.Stdout {
+		return nil
+	}
+	switch {
+	case len(cfg.PostRun) > 0:
+		return errors.New("PostRun can't be used with an output sink")
+	case cfg.Atomic:
+		return errors.New("Atomic can't be used with an output sink")
+	case cfg.Prune:
+		return errors.New("Prune can't be used with an output sink")
+	case cfg.DryRun:
+		return errors.New("DryRun can't be used with an output sink")
+	case cfg.Archive != "":
+		return errors.New("Archive can't be used with an output sink")
+	}
+	return nil
+}
+
+// genSink renders the target and writes it to the Sink as name.
+func genSink(ctx context.Context, env environ.Values, r *genRun, name string, contents interface{}, target OutputTarget) error {
+	out, err := renderOutput(ctx, env, r.Config, name, contents, target)
+	if err != nil {
+		return err
+	}
+	mode := fileMode(r.Config, target)
+	if mode == 0 {
+		mode = 0600
+	}
+	if err := r.Sink.WriteFile(filepath.ToSlash(name), out, mode); err != nil {
+		return err
+	}
+	r.report.written(name)
+	return nil
+}
+
+// generateSink generates the files for db, and writes them and the static
+// files to the Sink.
+func generateSink(ctx context.Context, env environ.Values, r *genRun, db *data.DBData) error {
+	if err := generateFiles(ctx, env, r, db); err != nil {
+		return err
+	}
+	for _, dir := range staticDirs(r.Config) {
+		err := dir.WalkFiles(r.FS, func(path string, info os.FileInfo) error {
+			rel, err := filepath.Rel(dir.Dir, path)
+			if err != nil {
+				return err
+			}
+			rel = filepath.Join(dir.Dest, rel)
+			b, err := renderStatic(r.Config, path)
+			if err != nil {
+				return err
+			}
+			r.report.static(rel)
+			return r.Sink.WriteFile(filepath.ToSlash(rel), b, info.Mode().Perm()|0200)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return finishReport(env, r)
+}
@@ -0,0 +1,76 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"text/template"
+
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run/data"
+)
+
+func TestGenerateSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gnorm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sink := &MemorySink{}
+	env := environ.Values{Stdout: &bytes.Buffer{}}
+	cfg := &Config{
+		ConfigData:     data.ConfigData{OutputDir: dir, StaticDir: "static"},
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver:         dummyDriver{},
+		TablePaths: []OutputTarget{{
+			Filename: template.Must(template.New("").Parse(`tables/{{.Table}}.txt`)),
+			Contents: template.Must(template.New("").Parse(`{{.Table.Name}}`)),
+		}},
+		FS:       fstest.MapFS{"static/README": {Data: []byte("readme")}},
+		Parallel: 2,
+	}
+	WithSink(sink)(cfg)
+	if err := Generate(context.Background(), env, cfg); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]byte{
+		"tables/table.txt": []byte("table"),
+		"tables/tb2.txt":   []byte("tb2"),
+		"README":           []byte("readme"),
+	}
+	if files := sink.Files(); !reflect.DeepEqual(files, expected) {
+		t.Fatalf("expected files %q, but got %q", expected, files)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Fatalf("expected nothing to be written to the OutputDir, but got %v", files[0].Name())
+	}
+
+	cfg.PostRun = []string{"true"}
+	if err := Generate(context.Background(), env, cfg); err == nil {
+		t.Fatal("expected an error for PostRun with a sink, but got nil")
+	}
+
+	// DirSink writes the files as they are.
+	cfg.PostRun = nil
+	cfg.Sink = DirSink(filepath.Join(dir, "out"))
+	cfg.FileMode = 0640
+	if err := Generate(context.Background(), env, cfg); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(filepath.Join(dir, "out", "tables", "tb2.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0640 {
+		t.Fatalf("expected mode 0640, but got %v", fi.Mode().Perm())
+	}
+}
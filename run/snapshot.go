@@ -0,0 +1,156 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+
+	"gnorm.org/gnorm/database"
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run/data"
+)
+
+// SnapshotVersion is the version of the snapshot file format that this version
+// of gnorm writes.  Snapshots with a newer version can't be read.
+const SnapshotVersion = 1
+
+// Snapshot is the schema info read from a database, saved to a file so that it
+// can be compared against or generated from later.
+type Snapshot struct {
+	// Version is the version of the snapshot file format.
+	Version int
+
+	// Info is the schema info read from the database.
+	Info *database.Info
+
+	// Data is the data that was passed to templates, converted from Info with
+	// the config the snapshot was made with.  It's saved for other tools to
+	// read; gnorm only reads Info, and converts it again with the current
+	// config.
+	Data *data.DBData
+}
+
+// SnapshotFormat defines the file formats snapshots can be written in.
+type SnapshotFormat int
+
+const (
+	// SnapshotJSON writes the snapshot as JSON.
+	SnapshotJSON SnapshotFormat = iota
+	// SnapshotYAML writes the snapshot as YAML.
+	SnapshotYAML
+)
+
+// SnapshotFormatOf returns the format of the snapshot file, based on its
+// extension.  Files that don't end in .yaml or .yml are JSON.
+func SnapshotFormatOf(file string) SnapshotFormat {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		return SnapshotYAML
+	}
+	return SnapshotJSON
+}
+
+// Dump reads the database the same way Generate does, and writes a snapshot of
+// its schema info to w in the given format.
+func Dump(ctx context.Context, env environ.Values, cfg *Config, w io.Writer, format SnapshotFormat) error {
+	info, err := parseDB(ctx, env, cfg)
+	if err != nil {
+		return err
+	}
+	data, err := makeData(ctx, env, info, cfg)
+	if err != nil {
+		return err
+	}
+	var b []byte
+	s := Snapshot{Version: SnapshotVersion, Info: info, Data: data}
+	switch format {
+	case SnapshotYAML:
+		b, err = yaml.Marshal(s)
+		if err != nil {
+			return errors.WithMessage(err, "couldn't convert snapshot to yaml")
+		}
+	case SnapshotJSON:
+		b, err = json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			return errors.WithMessage(err, "couldn't convert snapshot to json")
+		}
+		b = append(b, '\n')
+	default:
+		return errors.Errorf("Unsupported format: %v", format)
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// ReadSnapshot reads the schema info saved in the snapshot file, which is
+// decoded as YAML or JSON based on its extension.
+func ReadSnapshot(file string) (*database.Info, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.WithMessage(err, "can't read snapshot")
+	}
+	// only Info is read, the rest of the data is converted again from it.
+	var s struct {
+		Version int
+		Info    *database.Info
+	}
+	if SnapshotFormatOf(file) == SnapshotYAML {
+		err = yaml.Unmarshal(b, &s)
+	} else {
+		err = json.Unmarshal(b, &s)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't parse snapshot %s", file)
+	}
+	if s.Version < 1 || s.Version > SnapshotVersion {
+		return nil, errors.Errorf("snapshot %s has version %d, but this version of gnorm only reads versions up to %d", file, s.Version, SnapshotVersion)
+	}
+	if s.Info == nil {
+		return nil, errors.Errorf("snapshot %s has no schema info", file)
+	}
+	return s.Info, nil
+}
+
+// parseSnapshot reads the schema info from the snapshot file cfg.Snapshot,
+// and filters it the same way parseDatabase filters the schema info it reads
+// from a database.
+func parseSnapshot(env environ.Values, cfg *Config) (*database.Info, error) {
+	env.Logger().Infof("reading snapshot %v", cfg.Snapshot)
+	info, err := ReadSnapshot(cfg.Snapshot)
+	if err != nil {
+		return nil, err
+	}
+	if err := filterSchemaInfo(cfg, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// filterSchemaInfo filters info with the schema and table filters of cfg, for
+// schema info that wasn't read from a driver, which filters the tables it
+// reads itself.
+func filterSchemaInfo(cfg *Config, info *database.Info) error {
+	filter, err := makeFilter(cfg.IncludeTables, cfg.ExcludeTables)
+	if err != nil {
+		return err
+	}
+	for _, s := range info.Schemas {
+		tables := s.Tables[:0]
+		for _, t := range s.Tables {
+			if filter(s.Name, t.Name) {
+				tables = append(tables, t)
+			}
+		}
+		s.Tables = tables
+	}
+	if err := excludeColumns(info, cfg.ExcludeColumns); err != nil {
+		return err
+	}
+	return filterInfo(info, cfg)
+}
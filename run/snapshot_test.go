@@ -0,0 +1,120 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"text/template"
+
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run/data"
+)
+
+func TestReadSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gnorm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "schema.json")
+
+	if err := ioutil.WriteFile(file, []byte(`{"Version": 1, "Info": {"Schemas": [{"Name": "public", "Tables": [{"Name": "users"}]}]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := ReadSnapshot(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Schemas) != 1 || len(info.Schemas[0].Tables) != 1 || info.Schemas[0].Tables[0].Name != "users" {
+		t.Fatalf("expected the public.users table, but got %#v", info.Schemas)
+	}
+
+	if err := ioutil.WriteFile(file, []byte(`{"Version": 99, "Info": {}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadSnapshot(file); err == nil {
+		t.Fatal("expected an error for a snapshot from a newer version of gnorm, but got nil")
+	}
+}
+
+func TestDump(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gnorm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	env := environ.Values{}
+	cfg := &Config{
+		NameConversion: template.Must(template.New("").Funcs(environ.FuncMap).Parse(`{{print "abc " .}}`)),
+		ConfigData: data.ConfigData{
+			NullableTypeMap: map[string]string{"*int": "*INTEGER"},
+			TypeMap:         map[string]string{"int": "INTEGER"},
+		},
+		Driver: dummyDriver{},
+	}
+	expected, err := dummyDriver{}.Parse(context.Background(), env.Logger(), "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"schema.json", "schema.yaml"} {
+		buf := &bytes.Buffer{}
+		if err := Dump(context.Background(), env, cfg, buf, SnapshotFormatOf(name)); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(buf.Bytes(), []byte("abc table")) {
+			t.Fatalf("expected the %s snapshot to include the converted data, but got:\n%s", name, buf)
+		}
+		file := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(file, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		info, err := ReadSnapshot(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := DiffSchemas(expected, info); d != nil {
+			t.Fatalf("expected the %s snapshot to read back the same schema, but got differences %q", name, d)
+		}
+	}
+}
+
+func TestParseSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gnorm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	env := environ.Values{}
+	buf := &bytes.Buffer{}
+	cfg := &Config{
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+		Driver:         dummyDriver{},
+	}
+	if err := Dump(context.Background(), env, cfg, buf, SnapshotJSON); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "schema.json")
+	if err := ioutil.WriteFile(file, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// no driver is set, so this fails if it tries to read the database.
+	cfg = &Config{
+		Snapshot:   file,
+		ConfigData: data.ConfigData{ExcludeTables: map[string][]string{"schema": {"tb2"}}},
+	}
+	info, err := parseDB(context.Background(), env, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tables []string
+	for _, tbl := range info.Schemas[0].Tables {
+		tables = append(tables, tbl.Name)
+	}
+	if len(tables) != 1 || tables[0] != "table" {
+		t.Fatalf("expected only the table not excluded, but got %q", tables)
+	}
+}
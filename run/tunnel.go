@@ -0,0 +1,261 @@
+package run
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"gnorm.org/gnorm/environ"
+)
+
+// TunnelVar is the name of the variable in ConnStr that is replaced with the
+// local address of the SSH tunnel, e.g. "127.0.0.1:53124".
+const TunnelVar = "GNORM_TUNNEL"
+
+// SSHTunnel describes an SSH server (usually a bastion host) through which
+// gnorm connects to the database.  Gnorm establishes the tunnel itself, and
+// replaces $GNORM_TUNNEL in ConnStr with the local address that forwards to
+// Remote.
+type SSHTunnel struct {
+	// Host is the address of the SSH server, as host or host:port.  The port
+	// defaults to 22.
+	Host string
+
+	// User is the user to log into the SSH server as.
+	User string
+
+	// KeyFile is the path to an unencrypted private key used to authenticate
+	// with the SSH server.
+	KeyFile string
+
+	// Agent, if true, authenticates using the keys held by the SSH agent
+	// listening on $SSH_AUTH_SOCK.
+	Agent bool
+
+	// KnownHostsFile is the path to the known_hosts file used to verify the
+	// SSH server's host key.  It defaults to ~/.ssh/known_hosts.
+	KnownHostsFile string
+
+	// InsecureIgnoreHostKey, if true, skips verifying the SSH server's host
+	// key.  Only use this for testing.
+	InsecureIgnoreHostKey bool
+
+	// Remote is the address of the database as seen from the SSH server, as
+	// host:port.
+	Remote string
+}
+
+// tunnel forwards connections made to a local listener to the remote address
+// through an SSH connection.
+type tunnel struct {
+	client   *ssh.Client
+	listener net.Listener
+	remote   string
+	log      environ.Logger
+	wg       sync.WaitGroup
+}
+
+// openTunnel connects to the SSH server described by t and starts forwarding
+// connections to t.Remote.  Connecting gives up once ctx is done, or after
+// timeout if it isn't zero.  The tunnel must be closed by the caller.
+func openTunnel(ctx context.Context, env environ.Values, t *SSHTunnel, timeout time.Duration) (*tunnel, error) {
+	if t.Host == "" || t.User == "" || t.Remote == "" {
+		return nil, errors.New("SSHTunnel requires Host, User, and Remote")
+	}
+	auth, err := t.auth()
+	if err != nil {
+		return nil, err
+	}
+	hostKey, err := t.hostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
+	host := t.Host
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, "22")
+	}
+	env.Logger().Infof("opening ssh tunnel to %v through %v", t.Remote, host)
+	client, err := dialSSH(ctx, host, &ssh.ClientConfig{
+		User:            t.User,
+		Auth:            auth,
+		HostKeyCallback: hostKey,
+		Timeout:         timeout,
+	})
+	if err != nil {
+		return nil, errors.WithMessage(err, "error connecting to ssh server "+host)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		client.Close()
+		return nil, errors.WithMessage(err, "error listening for ssh tunnel")
+	}
+	tun := &tunnel{
+		client:   client,
+		listener: l,
+		remote:   t.Remote,
+		log:      env.Logger(),
+	}
+	go tun.serve()
+	return tun, nil
+}
+
+// dialSSH connects to the SSH server at addr like ssh.Dial, but gives up once
+// ctx is done, and bounds the handshake as well as the dial by config.Timeout.
+func dialSSH(ctx context.Context, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	if config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Timeout)
+		defer cancel()
+	}
+	d := net.Dialer{}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	// the handshake doesn't take a context, so close the connection to stop
+	// it when ctx is done.
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// auth returns the ssh auth methods configured for t.
+func (t *SSHTunnel) auth() ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+	if t.KeyFile != "" {
+		b, err := ioutil.ReadFile(expandHome(t.KeyFile))
+		if err != nil {
+			return nil, errors.WithMessage(err, "can't read SSHTunnel KeyFile")
+		}
+		signer, err := ssh.ParsePrivateKey(b)
+		if err != nil {
+			return nil, errors.WithMessage(err, "can't parse SSHTunnel KeyFile (use Agent for encrypted keys)")
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+	if t.Agent {
+		sock := os.Getenv("SSH_AUTH_SOCK")
+		if sock == "" {
+			return nil, errors.New("SSHTunnel Agent is set, but SSH_AUTH_SOCK is not")
+		}
+		methods = append(methods, ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+			conn, err := net.Dial("unix", sock)
+			if err != nil {
+				return nil, errors.WithMessage(err, "error connecting to ssh agent")
+			}
+			defer conn.Close()
+			return agent.NewClient(conn).Signers()
+		}))
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("SSHTunnel requires KeyFile or Agent")
+	}
+	return methods, nil
+}
+
+// hostKeyCallback returns the callback used to verify the ssh server's host
+// key.
+func (t *SSHTunnel) hostKeyCallback() (ssh.HostKeyCallback, error) {
+	if t.InsecureIgnoreHostKey {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+	file := t.KnownHostsFile
+	if file == "" {
+		file = "~/.ssh/known_hosts"
+	}
+	cb, err := knownhosts.New(expandHome(file))
+	if err != nil {
+		return nil, errors.WithMessage(err, "can't read SSHTunnel KnownHostsFile")
+	}
+	return cb, nil
+}
+
+// Addr returns the local address that forwards to the remote address.
+func (t *tunnel) Addr() string {
+	return t.listener.Addr().String()
+}
+
+// serve accepts local connections until the listener is closed.
+func (t *tunnel) serve() {
+	for {
+		local, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+		t.wg.Add(1)
+		go func() {
+			defer t.wg.Done()
+			t.forward(local)
+		}()
+	}
+}
+
+// forward copies data between the local connection and a new connection to
+// the remote address until either side closes.
+func (t *tunnel) forward(local net.Conn) {
+	defer local.Close()
+	remote, err := t.client.Dial("tcp", t.remote)
+	if err != nil {
+		t.log.Warnf("error dialing %v through ssh tunnel: %v", t.remote, err)
+		return
+	}
+	defer remote.Close()
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(remote, local)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(local, remote)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// Close stops the tunnel and closes the ssh connection.
+func (t *tunnel) Close() error {
+	err := t.listener.Close()
+	if cerr := t.client.Close(); err == nil {
+		err = cerr
+	}
+	t.wg.Wait()
+	return err
+}
+
+// expandHome replaces a leading ~/ in path with the user's home directory.
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[2:])
+}
@@ -0,0 +1,234 @@
+package run
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"gnorm.org/gnorm/environ"
+)
+
+func TestSSHTunnel(t *testing.T) {
+	dir := t.TempDir()
+	env := environ.Values{}
+
+	// the "database" just echoes back what it's sent.
+	db, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	go func() {
+		for {
+			c, err := db.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				io.Copy(c, c)
+			}()
+		}
+	}()
+
+	_, userKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(userKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "id_ed25519")
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	userSigner, err := ssh.NewSignerFromKey(userKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := startSSHServer(t, userSigner.PublicKey())
+	defer server.Close()
+	knownHosts := filepath.Join(dir, "known_hosts")
+
+	tun := &SSHTunnel{
+		Host:           server.Addr().String(),
+		User:           "gnorm",
+		KeyFile:        keyFile,
+		KnownHostsFile: knownHosts,
+		Remote:         db.Addr().String(),
+	}
+
+	// an unknown host key is rejected.
+	if err := ioutil.WriteFile(knownHosts, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openTunnel(context.Background(), env, tun, 0); err == nil {
+		t.Fatal("expected an error for an unknown host key, but got nil")
+	}
+
+	if err := ioutil.WriteFile(knownHosts, []byte(knownhosts.Line([]string{knownhosts.Normalize(tun.Host)}, server.hostKey)+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tn, err := openTunnel(context.Background(), env, tun, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tn.Close()
+
+	c, err := net.Dial("tcp", tn.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := io.WriteString(c, "hello\n"); err != nil {
+		t.Fatal(err)
+	}
+	line, err := bufio.NewReader(c).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "hello\n" {
+		t.Fatalf("expected %q through the tunnel, but got %q", "hello\n", line)
+	}
+}
+
+func TestDialSSHTimeout(t *testing.T) {
+	// a server that accepts connections but never starts the handshake.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+		}
+	}()
+	cfg := &ssh.ClientConfig{
+		User:            "gnorm",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         100 * time.Millisecond,
+	}
+	start := time.Now()
+	if _, err := dialSSH(context.Background(), l.Addr().String(), cfg); err == nil {
+		t.Fatal("expected an error for a server that never answers, but got nil")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("expected the handshake to time out after 100ms, but it took %v", d)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cfg.Timeout = 0
+	time.AfterFunc(100*time.Millisecond, cancel)
+	if _, err := dialSSH(ctx, l.Addr().String(), cfg); err != context.Canceled {
+		t.Errorf("expected context.Canceled, but got %v", err)
+	}
+}
+
+func TestParseDBTunnelVar(t *testing.T) {
+	cfg := &Config{SSHTunnel: &SSHTunnel{Host: "bastion", User: "gnorm", Agent: true, Remote: "db:5432"}}
+	cfg.ConnStr = "dbname=mydb host=db"
+	_, err := parseDB(context.Background(), environ.Values{}, cfg)
+	if err == nil {
+		t.Fatal("expected an error when ConnStr doesn't use $GNORM_TUNNEL, but got nil")
+	}
+}
+
+type sshServer struct {
+	net.Listener
+	hostKey ssh.PublicKey
+}
+
+// startSSHServer starts an ssh server that accepts the given user key and
+// allows direct-tcpip forwarding.
+func startSSHServer(t *testing.T, userKey ssh.PublicKey) *sshServer {
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(userKey.Marshal()) {
+				return nil, ssh.ErrNoAuth
+			}
+			return nil, nil
+		},
+	}
+	cfg.AddHostKey(hostSigner)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveSSH(c, cfg)
+		}
+	}()
+	return &sshServer{Listener: l, hostKey: hostSigner.PublicKey()}
+}
+
+func serveSSH(c net.Conn, cfg *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(c, cfg)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for nc := range chans {
+		if nc.ChannelType() != "direct-tcpip" {
+			nc.Reject(ssh.UnknownChannelType, "unsupported")
+			continue
+		}
+		var req struct {
+			Host     string
+			Port     uint32
+			OrigHost string
+			OrigPort uint32
+		}
+		if err := ssh.Unmarshal(nc.ExtraData(), &req); err != nil {
+			nc.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		remote, err := net.Dial("tcp", net.JoinHostPort(req.Host, strconv.Itoa(int(req.Port))))
+		if err != nil {
+			nc.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		ch, chReqs, err := nc.Accept()
+		if err != nil {
+			remote.Close()
+			continue
+		}
+		go ssh.DiscardRequests(chReqs)
+		go func() {
+			defer ch.Close()
+			defer remote.Close()
+			go io.Copy(remote, ch)
+			io.Copy(ch, remote)
+		}()
+	}
+}
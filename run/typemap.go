@@ -0,0 +1,149 @@
+package run
+
+import (
+	"bytes"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"gnorm.org/gnorm/run/data"
+)
+
+// TypeMapper maps the database types of columns to the types that templates
+// see as their Type.  Programs that embed gnorm can set Config.TypeMapper to
+// map types with logic that's too complex for the type maps, and fall back to
+// the mapper returned by NewTypeMapper for the rest.
+type TypeMapper interface {
+	// MapType returns the type of col, and whether its type is mapped at all.
+	// Every field of col is set except Type, including its Table and the
+	// table's Schema, so the mapping may depend on any of them.  Returning an
+	// error stops the run.
+	MapType(col *data.Column) (string, bool, error)
+}
+
+// NewTypeMapper returns the TypeMapper gnorm uses by default, which maps types
+// with cfg's ColumnTypes, type maps, and NullableWrapper.
+func NewTypeMapper(cfg *Config) (TypeMapper, error) {
+	return newTypeMapper(cfg)
+}
+
+// typeMapper maps database types to the types given by the config's type maps.
+// Keys of the type maps surrounded by slashes, e.g. "/varchar\(\d+\)/", are
+// regular expressions that must match the whole database type.  Their values
+// may refer to the expression's capture groups, e.g. "$1".  Exact keys are
+// checked before patterns.  When several patterns match, the longest one wins,
+// since it's usually the most specific, and patterns of the same length are
+// checked in sorted order.
+type typeMapper struct {
+	cfg      *Config
+	patterns map[string]*regexp.Regexp
+}
+
+// newTypeMapper compiles the patterns in all of cfg's type maps.
+func newTypeMapper(cfg *Config) (*typeMapper, error) {
+	t := &typeMapper{cfg: cfg, patterns: map[string]*regexp.Regexp{}}
+	maps := []map[string]string{cfg.TypeMap, cfg.NullableTypeMap}
+	for _, st := range cfg.SchemaTypeMaps {
+		maps = append(maps, st.TypeMap, st.NullableTypeMap)
+		for _, tm := range st.Tables {
+			maps = append(maps, tm.TypeMap, tm.NullableTypeMap)
+		}
+	}
+	for _, m := range maps {
+		for key := range m {
+			if !isTypePattern(key) || t.patterns[key] != nil {
+				continue
+			}
+			re, err := regexp.Compile("^(?:" + key[1:len(key)-1] + ")$")
+			if err != nil {
+				return nil, errors.WithMessage(err, "bad type map pattern "+key)
+			}
+			t.patterns[key] = re
+		}
+	}
+	return t, nil
+}
+
+// isTypePattern reports whether the type map key is a regular expression.
+func isTypePattern(key string) bool {
+	return len(key) > 1 && strings.HasPrefix(key, "/") && strings.HasSuffix(key, "/")
+}
+
+// MapType implements TypeMapper.  A type in ColumnTypes takes precedence over
+// the type maps, and nullable columns without a type in the nullable type maps
+// get the NullableWrapper of their type, if one is configured.
+func (t *typeMapper) MapType(col *data.Column) (string, bool, error) {
+	schema, table := col.Table.Schema.DBName, col.Table.DBName
+	if typ, ok := t.cfg.ColumnTypes[schema+"."+table+"."+col.DBName]; ok {
+		return typ, true, nil
+	}
+	typ, ok := t.mapType(schema, table, col.DBType, col.Nullable)
+	if !ok && col.Nullable && t.cfg.NullableWrapper != nil {
+		return t.wrapNullable(schema, table, col.DBType)
+	}
+	return typ, ok, nil
+}
+
+// wrapNullable returns the type a nullable column of dbType is mapped to by
+// running the NullableWrapper template with the type dbType is mapped to for
+// columns that aren't nullable.
+func (t *typeMapper) wrapNullable(schema, table, dbType string) (string, bool, error) {
+	typ, ok := t.mapType(schema, table, dbType, false)
+	if !ok {
+		return "", false, nil
+	}
+	buf := &bytes.Buffer{}
+	err := t.cfg.NullableWrapper.Execute(buf, struct{ Type, DBType string }{Type: typ, DBType: dbType})
+	if err != nil {
+		return "", false, errors.WithMessage(err, "failed to run NullableWrapper template")
+	}
+	return buf.String(), true, nil
+}
+
+// mapType returns the type that dbType is mapped to for a column in the given
+// table.  The table's type maps are checked first, then the schema's, then the
+// config's TypeMap or NullableTypeMap.
+func (t *typeMapper) mapType(schema, table, dbType string, nullable bool) (string, bool) {
+	global := TypeMaps{TypeMap: t.cfg.TypeMap, NullableTypeMap: t.cfg.NullableTypeMap}
+	st := t.cfg.SchemaTypeMaps[schema]
+	for _, tm := range []TypeMaps{st.Tables[table], st.TypeMaps, global} {
+		m := tm.TypeMap
+		if nullable {
+			m = tm.NullableTypeMap
+		}
+		if typ, ok := t.lookup(m, dbType); ok {
+			return typ, true
+		}
+	}
+	return "", false
+}
+
+// lookup returns the type that dbType is mapped to in m.
+func (t *typeMapper) lookup(m map[string]string, dbType string) (string, bool) {
+	if typ, ok := m[dbType]; ok {
+		return typ, true
+	}
+	var keys []string
+	for key := range m {
+		if t.patterns[key] != nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+	for _, key := range keys {
+		re := t.patterns[key]
+		match := re.FindStringSubmatchIndex(dbType)
+		if match == nil {
+			continue
+		}
+		return string(re.ExpandString(nil, m[key], dbType, match)), true
+	}
+	return "", false
+}
@@ -0,0 +1,141 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/pkg/errors"
+
+	"gnorm.org/gnorm/database"
+	"gnorm.org/gnorm/environ"
+	"gnorm.org/gnorm/run/data"
+)
+
+func TestTypeMapPatterns(t *testing.T) {
+	cfg := &Config{
+		ConfigData: data.ConfigData{
+			TypeMap: map[string]string{
+				`/varchar\(\d+\)/`:      "string",
+				`/numeric\((\d+),0\)/`:  "int64 // precision $1",
+				`/numeric\(\d+,\d+\)/`:  "float64",
+				"numeric(10,0)":         "exact",
+				`/character varying.*/`: "string",
+			},
+		},
+	}
+	types, err := newTypeMapper(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for dbType, expected := range map[string]string{
+		"varchar(255)":          "string",
+		"numeric(18,0)":         "int64 // precision 18",
+		"numeric(18,2)":         "float64",
+		"numeric(10,0)":         "exact",
+		"character varying(64)": "string",
+	} {
+		typ, ok := types.mapType("public", "t", dbType, false)
+		if !ok || typ != expected {
+			t.Errorf("%s: expected %q, but got %q", dbType, expected, typ)
+		}
+	}
+	// patterns must match the whole type
+	if typ, ok := types.mapType("public", "t", "myvarchar(10)", false); ok {
+		t.Errorf("expected myvarchar(10) to be unmapped, but got %q", typ)
+	}
+
+	cfg.TypeMap = map[string]string{"/(/": "bad"}
+	if _, err := newTypeMapper(cfg); err == nil {
+		t.Fatal("expected an error for a bad pattern, but got nil")
+	}
+}
+
+// lengthMapper maps varchar columns by their length, and everything else with
+// the default mapper.
+type lengthMapper struct {
+	TypeMapper
+}
+
+func (m lengthMapper) MapType(col *data.Column) (string, bool, error) {
+	if col.DBType == "varchar" {
+		if col.Length > 0 {
+			return fmt.Sprintf("[%d]byte", col.Length), true, nil
+		}
+		return "", false, errors.New("varchar without a length")
+	}
+	return m.TypeMapper.MapType(col)
+}
+
+func TestCustomTypeMapper(t *testing.T) {
+	cfg := &Config{
+		ConfigData:     data.ConfigData{TypeMap: map[string]string{"int": "int64"}},
+		NameConversion: template.Must(template.New("").Parse(`{{.}}`)),
+	}
+	def, err := NewTypeMapper(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	WithTypeMapper(lengthMapper{def})(cfg)
+	info := &database.Info{Schemas: []*database.Schema{{
+		Name: "public",
+		Tables: []*database.Table{{
+			Name: "t",
+			Columns: []*database.Column{
+				{Name: "id", Type: "int"},
+				{Name: "code", Type: "varchar", Length: 3},
+				{Name: "other", Type: "text"},
+			},
+		}},
+	}}}
+	db, err := makeData(context.Background(), environ.Values{}, info, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cols := db.Schemas[0].Tables[0].Columns
+	expected := []string{"int64", "[3]byte", ""}
+	for x, col := range cols {
+		if col.Type != expected[x] {
+			t.Errorf("%s: expected type %q, but got %q", col.DBName, expected[x], col.Type)
+		}
+	}
+
+	info.Schemas[0].Tables[0].Columns[1].Length = 0
+	if _, err := makeData(context.Background(), environ.Values{}, info, cfg); err == nil || !strings.Contains(err.Error(), "varchar without a length") {
+		t.Fatalf("expected the type mapper's error, but got %v", err)
+	}
+}
+
+func TestTypeMapPatternPrecedence(t *testing.T) {
+	cfg := &Config{
+		ConfigData: data.ConfigData{
+			TypeMap: map[string]string{
+				`/.*/`:               "any",
+				`/var.*/`:            "var",
+				`/varchar\(\d+\)/`:   "string",
+				`/varchar\((.\d)\)/`: "two $1",
+				`/varchar\((1\d)\)/`: "teen $1",
+			},
+		},
+	}
+	types, err := newTypeMapper(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for dbType, expected := range map[string]string{
+		// the longest pattern that matches wins.
+		"varchar(255)": "string",
+		"varbinary":    "var",
+		"text":         "any",
+		// patterns of the same length are tried in sorted order.
+		"varchar(12)": "two 12",
+		"varchar(25)": "two 25",
+	} {
+		typ, ok := types.mapType("public", "t", dbType, false)
+		if !ok || typ != expected {
+			t.Errorf("%s: expected %q, but got %q", dbType, expected, typ)
+		}
+	}
+}